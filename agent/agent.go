@@ -0,0 +1,373 @@
+// Package agent caches the secret of databases in a separate process, like
+// ssh-agent, so that commands run in a row do not prompt for it every time.
+// It can also hold the keys of wallets, and sign with them for the commands,
+// which then never see their seeds.
+//
+// The agent listens on a unix socket only readable by its owner, in a
+// directory of the user, and forgets each secret and key after a fixed
+// duration. The clients refuse a socket that belongs to another user.
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+)
+
+// DefaultSocket returns the default path of the socket of the agent, in
+// $XDG_RUNTIME_DIR or else in a directory of the user in the temporary
+// directory, which Listen creates
+func DefaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "alfred-agent.sock")
+	}
+
+	return filepath.Join(os.TempDir(), fmt.Sprintf("alfred-%d", os.Getuid()), "agent.sock")
+}
+
+// UnsafeSocketError is returned for a socket that other users could have
+// created or could access
+type UnsafeSocketError struct {
+	Path   string
+	Reason string
+}
+
+func (e *UnsafeSocketError) Error() string {
+	return e.Path + " " + e.Reason
+}
+
+// checkSocket returns an error unless path is a socket of the current user
+// that other users can not access, so that a secret is never sent to an
+// agent run by someone else
+func checkSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case fi.Mode()&os.ModeSocket == 0:
+		return &UnsafeSocketError{Path: path, Reason: "is not a socket"}
+	case !ownedByUser(fi):
+		return &UnsafeSocketError{Path: path, Reason: "belongs to another user"}
+	case fi.Mode().Perm()&0077 != 0:
+		return &UnsafeSocketError{Path: path, Reason: "is accessible by other users"}
+	}
+
+	return nil
+}
+
+// checkDir creates dir if needed and returns an error unless it belongs to
+// the current user and others can not write to it
+func checkDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !fi.IsDir():
+		return fmt.Errorf("%s is not a directory", dir)
+	case !ownedByUser(fi):
+		return fmt.Errorf("%s belongs to another user", dir)
+	case fi.Mode().Perm()&0022 != 0:
+		return fmt.Errorf("%s is writable by other users", dir)
+	}
+
+	return nil
+}
+
+// SetArgs are the arguments of Agent.Set
+type SetArgs struct {
+	DB     string
+	Secret string
+}
+
+// SignArgs are the arguments of Agent.Sign
+type SignArgs struct {
+	Address string
+	// Hash is the hash of the transaction, for its network
+	Hash [32]byte
+}
+
+type entry struct {
+	secret  string
+	expires time.Time
+}
+
+type key struct {
+	kp      *keypair.Full
+	expires time.Time
+}
+
+// Agent holds secrets by database path, and keys by address. It is exposed
+// over net/rpc.
+type Agent struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	secrets map[string]entry
+	keys    map[string]key
+}
+
+// New returns an agent that forgets secrets and keys after ttl
+func New(ttl time.Duration) *Agent {
+	return &Agent{
+		ttl:     ttl,
+		secrets: make(map[string]entry),
+		keys:    make(map[string]key),
+	}
+}
+
+// Get returns the secret of db, or an empty string if it is not cached
+func (a *Agent) Get(db string, secret *string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, ok := a.secrets[db]
+	if ok && time.Now().After(e.expires) {
+		delete(a.secrets, db)
+		ok = false
+	}
+
+	if ok {
+		*secret = e.secret
+	}
+
+	return nil
+}
+
+// Set caches the secret of a database
+func (a *Agent) Set(args SetArgs, _ *struct{}) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.secrets[args.DB] = entry{
+		secret:  args.Secret,
+		expires: time.Now().Add(a.ttl),
+	}
+
+	return nil
+}
+
+// AddKeys holds the keys of seeds
+func (a *Agent) AddKeys(seeds []string, _ *struct{}) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, seed := range seeds {
+		kp, err := keypair.Parse(seed)
+		if err != nil {
+			return err
+		}
+		full, ok := kp.(*keypair.Full)
+		if !ok {
+			return fmt.Errorf("%s is not a seed", kp.Address())
+		}
+
+		a.keys[full.Address()] = key{kp: full, expires: time.Now().Add(a.ttl)}
+	}
+
+	return nil
+}
+
+// Keys returns the addresses of the keys held
+func (a *Agent) Keys(_ struct{}, addresses *[]string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for address, k := range a.keys {
+		if now.Before(k.expires) {
+			*addresses = append(*addresses, address)
+		}
+	}
+
+	return nil
+}
+
+// Sign signs the hash of a transaction with the key of an address
+func (a *Agent) Sign(args SignArgs, sig *xdr.DecoratedSignature) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k, ok := a.keys[args.Address]
+	if !ok || time.Now().After(k.expires) {
+		delete(a.keys, args.Address)
+		return fmt.Errorf("the agent does not hold the key of %s", args.Address)
+	}
+
+	signed, err := k.kp.SignDecorated(args.Hash[:])
+	if err != nil {
+		return err
+	}
+
+	*sig = signed
+	return nil
+}
+
+// Forget removes every cached secret and key
+func (a *Agent) Forget(_ struct{}, _ *struct{}) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.secrets = make(map[string]entry)
+	a.keys = make(map[string]key)
+	return nil
+}
+
+// expire removes the secrets and keys that expired
+func (a *Agent) expire() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for db, e := range a.secrets {
+		if now.After(e.expires) {
+			delete(a.secrets, db)
+		}
+	}
+	for address, k := range a.keys {
+		if now.After(k.expires) {
+			delete(a.keys, address)
+		}
+	}
+}
+
+// Listen creates the socket of the agent at path, replacing a stale one. The
+// directory of path is created if needed and should belong to the user.
+func Listen(path string) (net.Listener, error) {
+	if err := checkDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Lstat(path); err == nil {
+		if err := checkSocket(path); err != nil {
+			return nil, err
+		}
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("an agent is already listening on %s", path)
+		}
+		os.Remove(path)
+	}
+
+	// create the socket with restricted permissions from the start
+	mask := umask(0177)
+	l, err := net.Listen("unix", path)
+	umask(mask)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Serve answers the requests to a on l until it is closed
+func Serve(l net.Listener, a *Agent) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName("Agent", a); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.expire()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeConn(conn)
+	}
+}
+
+// Client talks to a running agent
+type Client struct {
+	rpc *rpc.Client
+}
+
+// Dial connects to the agent listening on path, if its socket belongs to
+// the current user
+func Dial(path string) (*Client, error) {
+	if err := checkSocket(path); err != nil {
+		return nil, err
+	}
+
+	c, err := rpc.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{rpc: c}, nil
+}
+
+// Get returns the cached secret of db, or an empty string
+func (c *Client) Get(db string) (string, error) {
+	var secret string
+	err := c.rpc.Call("Agent.Get", db, &secret)
+	return secret, err
+}
+
+// Set caches the secret of db
+func (c *Client) Set(db, secret string) error {
+	return c.rpc.Call("Agent.Set", SetArgs{DB: db, Secret: secret}, &struct{}{})
+}
+
+// AddKeys makes the agent hold the keys of seeds
+func (c *Client) AddKeys(seeds ...string) error {
+	return c.rpc.Call("Agent.AddKeys", seeds, &struct{}{})
+}
+
+// Keys returns the addresses of the keys held by the agent
+func (c *Client) Keys() ([]string, error) {
+	var addresses []string
+	err := c.rpc.Call("Agent.Keys", struct{}{}, &addresses)
+	return addresses, err
+}
+
+// Sign returns the signature of hash, the hash of a transaction, by the key
+// of address held by the agent
+func (c *Client) Sign(address string, hash [32]byte) (xdr.DecoratedSignature, error) {
+	var sig xdr.DecoratedSignature
+	err := c.rpc.Call("Agent.Sign", SignArgs{Address: address, Hash: hash}, &sig)
+	return sig, err
+}
+
+// Forget removes every cached secret and key
+func (c *Client) Forget() error {
+	return c.rpc.Call("Agent.Forget", struct{}{}, &struct{}{})
+}
+
+// Close closes the connection to the agent
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
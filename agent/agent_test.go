@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alfred-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "agent.sock")
+	l, err := Listen(path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = Listen(path)
+	require.Error(t, err, "an agent is already listening")
+
+	a := New(50 * time.Millisecond)
+	go Serve(l, a)
+
+	c, err := Dial(path)
+	require.NoError(t, err)
+	defer c.Close()
+
+	secret, err := c.Get("/tmp/alfred.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "", secret)
+
+	require.NoError(t, c.Set("/tmp/alfred.yaml", "hello world"))
+	secret, err = c.Get("/tmp/alfred.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "hello world", secret)
+
+	time.Sleep(60 * time.Millisecond)
+	secret, err = c.Get("/tmp/alfred.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "", secret, "secret should have expired")
+
+	require.NoError(t, c.Set("/tmp/alfred.yaml", "hello world"))
+	require.NoError(t, c.Forget())
+	secret, err = c.Get("/tmp/alfred.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "", secret)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	_, err = c.Sign(kp.Address(), [32]byte{1})
+	require.Error(t, err)
+	require.Error(t, c.AddKeys(kp.Address()), "an address is not a key")
+
+	require.NoError(t, c.AddKeys(kp.Seed()))
+	addresses, err := c.Keys()
+	require.NoError(t, err)
+	require.Equal(t, []string{kp.Address()}, addresses)
+
+	sig, err := c.Sign(kp.Address(), [32]byte{1})
+	require.NoError(t, err)
+	require.Equal(t, kp.Hint(), [4]byte(sig.Hint))
+	require.NoError(t, kp.Verify([]byte{1, 31: 0}, sig.Signature))
+
+	time.Sleep(60 * time.Millisecond)
+	addresses, err = c.Keys()
+	require.NoError(t, err)
+	require.Empty(t, addresses, "key should have expired")
+}
+
+func TestSocketChecks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alfred-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the directory of the socket is created for the user only
+	path := filepath.Join(dir, "run", "agent.sock")
+	l, err := Listen(path)
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	go Serve(l, New(time.Minute))
+	c, err := Dial(path)
+	require.NoError(t, err)
+	c.Close()
+
+	// a socket others can access is refused
+	require.NoError(t, os.Chmod(path, 0666))
+	_, err = Dial(path)
+	require.EqualError(t, err, path+" is accessible by other users")
+	_, err = Listen(path)
+	require.Error(t, err)
+	l.Close()
+
+	// so is a socket of another user, which is not replaced
+	if os.Getuid() == 0 {
+		l, err = Listen(path)
+		require.NoError(t, err)
+		require.NoError(t, os.Chown(path, 12345, -1))
+		_, err = Dial(path)
+		require.EqualError(t, err, path+" belongs to another user")
+		_, err = Listen(path)
+		require.EqualError(t, err, path+" belongs to another user")
+		l.Close()
+		os.Remove(path)
+	}
+
+	// and anything else than a socket
+	require.NoError(t, ioutil.WriteFile(path, nil, 0600))
+	_, err = Dial(path)
+	require.EqualError(t, err, path+" is not a socket")
+	_, err = Listen(path)
+	require.EqualError(t, err, path+" is not a socket")
+
+	// the directory should not be writable by others
+	require.NoError(t, os.Chmod(dir, 0777))
+	_, err = Listen(filepath.Join(dir, "agent.sock"))
+	require.EqualError(t, err, dir+" is writable by other users")
+}
@@ -0,0 +1,9 @@
+package agent
+
+import "syscall"
+
+// LockMemory keeps the memory of the process, and the keys it holds, from
+// being swapped to disk
+func LockMemory() error {
+	return syscall.Mlockall(syscall.MCL_CURRENT | syscall.MCL_FUTURE)
+}
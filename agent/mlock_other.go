@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package agent
+
+import "errors"
+
+// LockMemory keeps the memory of the process, and the keys it holds, from
+// being swapped to disk
+func LockMemory() error {
+	return errors.New("locking memory is not supported on this system")
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package agent
+
+import "syscall"
+
+func umask(mask int) int {
+	return syscall.Umask(mask)
+}
@@ -0,0 +1,203 @@
+// Package anchor talks to anchors, the services moving assets between the
+// Stellar network and bank accounts, through the Stellar ecosystem proposals
+// they implement.
+//
+// The services of an anchor are discovered from the stellar.toml file
+// published on its domain.
+package anchor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// Info lists the services of an anchor, as published in its stellar.toml
+type Info struct {
+	// WebAuthEndpoint is the SEP-10 authentication endpoint
+	WebAuthEndpoint string
+	// SigningKey is the account signing the SEP-10 challenges
+	SigningKey string
+	// TransferServer is the SEP-6 deposit and withdrawal server
+	TransferServer string
+	// TransferServerSEP24 is the SEP-24 interactive deposit and withdrawal server
+	TransferServerSEP24 string
+	// KYCServer is the SEP-12 customer information server, TransferServer is
+	// used when it is empty
+	KYCServer string
+	// NetworkPassphrase is the network of the anchor, empty if not published
+	NetworkPassphrase string
+	// Currencies are the assets issued by the anchor
+	Currencies []Currency
+	// Accounts are the accounts controlled by the domain
+	Accounts []string
+}
+
+// Currency is an asset issued by an anchor
+type Currency struct {
+	Code   string
+	Issuer string
+}
+
+// Anchor is an anchor found from its domain
+type Anchor struct {
+	Domain string
+	Info   Info
+
+	http *http.Client
+}
+
+// Discover fetches the stellar.toml of domain.
+// domain can also be a full URL of the file, which is used as is.
+func Discover(client *http.Client, domain string) (*Anchor, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(tomlURL(domain))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch the stellar.toml of %s: %s", domain, resp.Status)
+	}
+
+	tree, err := toml.LoadReader(io.LimitReader(resp.Body, 100*1024))
+	if err != nil {
+		return nil, fmt.Errorf("invalid stellar.toml for %s: %v", domain, err)
+	}
+
+	get := func(key string) string {
+		s, _ := tree.Get(key).(string)
+		return s
+	}
+
+	var currencies []Currency
+	if trees, ok := tree.Get("CURRENCIES").([]*toml.Tree); ok {
+		for _, t := range trees {
+			code, _ := t.Get("code").(string)
+			issuer, _ := t.Get("issuer").(string)
+			currencies = append(currencies, Currency{Code: code, Issuer: issuer})
+		}
+	}
+
+	var accounts []string
+	if list, ok := tree.Get("ACCOUNTS").([]interface{}); ok {
+		for _, account := range list {
+			if account, ok := account.(string); ok {
+				accounts = append(accounts, account)
+			}
+		}
+	}
+
+	return &Anchor{
+		Domain: domain,
+		Info: Info{
+			WebAuthEndpoint:     get("WEB_AUTH_ENDPOINT"),
+			SigningKey:          get("SIGNING_KEY"),
+			TransferServer:      get("TRANSFER_SERVER"),
+			TransferServerSEP24: get("TRANSFER_SERVER_SEP0024"),
+			KYCServer:           get("KYC_SERVER"),
+			NetworkPassphrase:   get("NETWORK_PASSPHRASE"),
+			Currencies:          currencies,
+			Accounts:            accounts,
+		},
+		http: client,
+	}, nil
+}
+
+// Controls returns whether address is one of the accounts of the domain
+func (a *Anchor) Controls(address string) bool {
+	for _, account := range a.Info.Accounts {
+		if account == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Currency returns the asset issued by the anchor with code
+func (a *Anchor) Currency(code string) (Currency, bool) {
+	for _, c := range a.Info.Currencies {
+		if strings.EqualFold(c.Code, code) {
+			return c, true
+		}
+	}
+
+	return Currency{}, false
+}
+
+func tomlURL(domain string) string {
+	if strings.Contains(domain, "://") {
+		return domain
+	}
+
+	return "https://" + strings.TrimSuffix(domain, "/") + "/.well-known/stellar.toml"
+}
+
+// do sends req and decodes the JSON response in v.
+// Errors returned by the anchor are reported with their message.
+func (a *Anchor) do(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var problem struct {
+			Error string `json:"error"`
+			// Type is set when the anchor needs more information from the
+			// customer, the URL of the form is set for interactive types
+			Type   string   `json:"type"`
+			URL    string   `json:"url"`
+			Fields []string `json:"fields"`
+		}
+		if json.Unmarshal(b, &problem) == nil {
+			switch {
+			case problem.Type == "non_interactive_customer_info_needed":
+				return &CustomerInfoNeededError{Domain: a.Domain, Fields: problem.Fields}
+			case problem.Error != "":
+				return fmt.Errorf("%s: %s", a.Domain, problem.Error)
+			case problem.URL != "":
+				return fmt.Errorf("%s: %s, complete it at %s", a.Domain, strings.Replace(problem.Type, "_", " ", -1), problem.URL)
+			case problem.Type != "":
+				return fmt.Errorf("%s: %s", a.Domain, strings.Replace(problem.Type, "_", " ", -1))
+			}
+		}
+		return fmt.Errorf("%s: %s", a.Domain, resp.Status)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%s: invalid response: %v", a.Domain, err)
+	}
+
+	return nil
+}
+
+// checkNetwork returns an error if the anchor works on another network
+func (a *Anchor) checkNetwork(passphrase string) error {
+	if a.Info.NetworkPassphrase != "" && a.Info.NetworkPassphrase != passphrase {
+		return errors.New(a.Domain + " works on another network: " + a.Info.NetworkPassphrase)
+	}
+
+	return nil
+}
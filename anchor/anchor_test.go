@@ -0,0 +1,260 @@
+package anchor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+// challenge builds a SEP-10 challenge for account signed by server
+func challenge(t *testing.T, server *keypair.Full, account string, muts ...build.TransactionMutator) xdr.TransactionEnvelope {
+	muts = append([]build.TransactionMutator{
+		build.SourceAccount{AddressOrSeed: server.Address()},
+		build.Sequence{Sequence: 0},
+		build.TestNetwork,
+		build.SetData("anchor.com auth", []byte("0123456789abcdef"), build.SourceAccount{AddressOrSeed: account}),
+	}, muts...)
+	tx, err := build.Transaction(muts...)
+	require.NoError(t, err)
+	now := uint64(time.Now().Unix())
+	tx.TX.TimeBounds = &xdr.TimeBounds{MinTime: xdr.Uint64(now - 10), MaxTime: xdr.Uint64(now + 300)}
+
+	txe, err := tx.Sign(server.Seed())
+	require.NoError(t, err)
+	return *txe.E
+}
+
+func TestVerifyChallenge(t *testing.T) {
+	server, err := keypair.Random()
+	require.NoError(t, err)
+	client, err := keypair.Random()
+	require.NoError(t, err)
+	other, err := keypair.Random()
+	require.NoError(t, err)
+
+	now := time.Now()
+	pass := network.TestNetworkPassphrase
+
+	txe := challenge(t, server, client.Address())
+	require.NoError(t, VerifyChallenge(txe, server.Address(), client.Address(), pass, now))
+
+	require.Error(t, VerifyChallenge(txe, other.Address(), client.Address(), pass, now))
+	require.Error(t, VerifyChallenge(txe, server.Address(), other.Address(), pass, now))
+	require.Error(t, VerifyChallenge(txe, server.Address(), client.Address(), network.PublicNetworkPassphrase, now))
+	require.Error(t, VerifyChallenge(txe, server.Address(), client.Address(), pass, now.Add(time.Hour)))
+
+	txe.Tx.SeqNum = 1
+	require.Error(t, VerifyChallenge(txe, server.Address(), client.Address(), pass, now))
+
+	txe = challenge(t, server, client.Address(), build.Payment(
+		build.Destination{AddressOrSeed: server.Address()},
+		build.NativeAmount{Amount: "10"},
+		build.SourceAccount{AddressOrSeed: client.Address()},
+	))
+	require.Error(t, VerifyChallenge(txe, server.Address(), client.Address(), pass, now))
+}
+
+func TestAuthenticate(t *testing.T) {
+	server, err := keypair.Random()
+	require.NoError(t, err)
+	client, err := keypair.Random()
+	require.NoError(t, err)
+
+	exp := time.Now().Add(24 * time.Hour).Unix()
+	jwt := "eyJhbGciOiJIUzI1NiJ9." +
+		base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"%s","exp":%d}`, client.Address(), exp))) +
+		".c2ln"
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/.well-known/stellar.toml":
+			fmt.Fprintf(w, "NETWORK_PASSPHRASE=%q\nWEB_AUTH_ENDPOINT=%q\nSIGNING_KEY=%q\nACCOUNTS=[%q]\n\n[[CURRENCIES]]\ncode=\"USD\"\n",
+				network.TestNetworkPassphrase, srv.URL+"/auth", server.Address(), server.Address())
+		case r.URL.Path == "/auth" && r.Method == "GET":
+			require.Equal(t, client.Address(), r.URL.Query().Get("account"))
+			txeB64, err := xdr.MarshalBase64(challenge(t, server, client.Address()))
+			require.NoError(t, err)
+			json.NewEncoder(w).Encode(map[string]string{"transaction": txeB64})
+		case r.URL.Path == "/auth" && r.Method == "POST":
+			var body struct{ Transaction string }
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+			var txe xdr.TransactionEnvelope
+			require.NoError(t, xdr.SafeUnmarshalBase64(body.Transaction, &txe))
+			require.Len(t, txe.Signatures, 2)
+			hash, err := network.HashTransaction(&txe.Tx, network.TestNetworkPassphrase)
+			require.NoError(t, err)
+			require.NoError(t, client.Verify(hash[:], txe.Signatures[1].Signature))
+
+			json.NewEncoder(w).Encode(map[string]string{"token": jwt})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"not found"}`)
+		}
+	}))
+	defer srv.Close()
+
+	a, err := Discover(srv.Client(), srv.URL+"/.well-known/stellar.toml")
+	require.NoError(t, err)
+	require.Equal(t, server.Address(), a.Info.SigningKey)
+	require.True(t, a.Controls(server.Address()))
+	require.False(t, a.Controls(client.Address()))
+
+	token, err := a.Authenticate(client, network.TestNetworkPassphrase)
+	require.NoError(t, err)
+	require.Equal(t, jwt, token.JWT)
+	require.Equal(t, exp, token.Expires.Unix())
+
+	_, err = a.Authenticate(client, network.PublicNetworkPassphrase)
+	require.Error(t, err)
+
+	_, err = Discover(srv.Client(), srv.URL+"/missing.toml")
+	require.Error(t, err)
+}
+
+func TestTokenExpiry(t *testing.T) {
+	now := time.Now()
+	require.Equal(t, now.Add(DefaultTokenLifetime).Unix(), tokenExpiry("not a jwt", now).Unix())
+	require.Equal(t, int64(42), tokenExpiry("a."+base64.RawURLEncoding.EncodeToString([]byte(`{"exp":42}`))+".c", now).Unix())
+}
+
+func TestInteractiveDeposit(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/stellar.toml":
+			fmt.Fprintf(w, "TRANSFER_SERVER_SEP0024=%q\n\n[[CURRENCIES]]\ncode=\"USD\"\nissuer=\"GISSUER\"\n", srv.URL+"/sep24/")
+		case "/sep24/info":
+			fmt.Fprint(w, `{"deposit":{"USD":{"enabled":true,"min_amount":10}},"withdraw":{}}`)
+		case "/sep24/transactions/deposit/interactive":
+			require.Equal(t, "Bearer jwt", r.Header.Get("Authorization"))
+			require.Equal(t, "USD", r.FormValue("asset_code"))
+			require.Equal(t, "GACCOUNT", r.FormValue("account"))
+			require.Equal(t, "100", r.FormValue("amount"))
+			fmt.Fprint(w, `{"type":"interactive_customer_info_needed","url":"https://anchor.com/kyc","id":"42"}`)
+		case "/sep24/transaction":
+			require.Equal(t, "42", r.URL.Query().Get("id"))
+			fmt.Fprint(w, `{"transaction":{"id":"42","kind":"deposit","status":"completed","amount_out":"99"}}`)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error":"forbidden"}`)
+		}
+	}))
+	defer srv.Close()
+
+	a, err := Discover(srv.Client(), srv.URL+"/.well-known/stellar.toml")
+	require.NoError(t, err)
+	cur, ok := a.Currency("usd")
+	require.True(t, ok)
+	require.Equal(t, "GISSUER", cur.Issuer)
+
+	info, err := a.TransferInfo(SEP24)
+	require.NoError(t, err)
+	require.True(t, info.Deposit["USD"].Enabled)
+	require.Equal(t, 10.0, info.Deposit["USD"].MinAmount)
+
+	_, err = a.TransferInfo(SEP6)
+	require.Error(t, err)
+
+	interactive, err := a.InteractiveDeposit("jwt", "USD", "GACCOUNT", "100")
+	require.NoError(t, err)
+	require.Equal(t, "https://anchor.com/kyc", interactive.URL)
+
+	tx, err := a.Transaction(SEP24, "jwt", interactive.ID)
+	require.NoError(t, err)
+	require.True(t, tx.Done())
+	require.Equal(t, "99", tx.AmountOut)
+
+	a.Info.TransferServerSEP24 = srv.URL + "/missing"
+	_, err = a.Transaction(SEP24, "jwt", "42")
+	require.EqualError(t, err, a.Domain+": forbidden")
+}
+
+func TestWithdraw(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/sep6/withdraw", r.URL.Path)
+		q := r.URL.Query()
+		if q.Get("dest") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"type":"non_interactive_customer_info_needed","fields":["first_name"]}`)
+			return
+		}
+
+		require.Equal(t, "USDC", q.Get("asset_code"))
+		require.Equal(t, "bank_account", q.Get("type"))
+		require.Equal(t, "200", q.Get("amount"))
+		fmt.Fprint(w, `{"account_id":"GANCHOR","memo_type":"id","memo":"123","id":"42"}`)
+	}))
+	defer srv.Close()
+
+	a := &Anchor{Domain: "anchor.com", Info: Info{TransferServer: srv.URL + "/sep6"}, http: srv.Client()}
+	req := WithdrawRequest{Code: "USDC", Type: "bank_account", Account: "GACCOUNT", Amount: "200"}
+
+	_, err := a.Withdraw("jwt", req)
+	require.EqualError(t, err, "anchor.com: customer information needed (first_name)")
+	require.IsType(t, &CustomerInfoNeededError{}, err)
+
+	req.Fields = map[string]string{"dest": "123456"}
+	instructions, err := a.Withdraw("jwt", req)
+	require.NoError(t, err)
+	require.Equal(t, "GANCHOR", instructions.AccountID)
+	require.Equal(t, "123", instructions.Memo)
+}
+
+func TestCustomer(t *testing.T) {
+	doc, err := ioutil.TempFile("", "passport")
+	require.NoError(t, err)
+	defer os.Remove(doc.Name())
+	_, err = doc.WriteString("scan")
+	require.NoError(t, err)
+	require.NoError(t, doc.Close())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/kyc/customer", r.URL.Path)
+		require.Equal(t, "Bearer jwt", r.Header.Get("Authorization"))
+
+		switch r.Method {
+		case "GET":
+			require.Equal(t, "GACCOUNT", r.URL.Query().Get("account"))
+			fmt.Fprint(w, `{"status":"NEEDS_INFO","fields":{"first_name":{"type":"string","description":"first name"},"photo_id_front":{"type":"binary"}}}`)
+		case "PUT":
+			require.NoError(t, r.ParseMultipartForm(1024))
+			require.Equal(t, "GACCOUNT", r.FormValue("account"))
+			require.Equal(t, "Jennifer", r.FormValue("first_name"))
+			f, _, err := r.FormFile("photo_id_front")
+			require.NoError(t, err)
+			b, err := ioutil.ReadAll(f)
+			require.NoError(t, err)
+			require.Equal(t, "scan", string(b))
+			fmt.Fprint(w, `{"id":"42"}`)
+		}
+	}))
+	defer srv.Close()
+
+	a := &Anchor{Domain: "anchor.com", Info: Info{KYCServer: srv.URL + "/kyc"}, http: srv.Client()}
+	customer, err := a.Customer("jwt", "GACCOUNT", "")
+	require.NoError(t, err)
+	require.Equal(t, "NEEDS_INFO", customer.Status)
+	require.Equal(t, "binary", customer.Fields["photo_id_front"].Type)
+
+	customer, err = a.PutCustomer("jwt", "GACCOUNT", "", map[string]string{"first_name": "Jennifer"}, map[string]string{"photo_id_front": doc.Name()})
+	require.NoError(t, err)
+	require.Equal(t, "42", customer.ID)
+
+	a.Info.KYCServer = ""
+	_, err = a.Customer("jwt", "GACCOUNT", "")
+	require.Error(t, err)
+}
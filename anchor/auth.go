@@ -0,0 +1,186 @@
+package anchor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// DefaultTokenLifetime is the lifetime assumed for tokens without expiry
+const DefaultTokenLifetime = time.Hour
+
+// Token is a SEP-10 token authenticating an account with an anchor
+type Token struct {
+	JWT     string
+	Expires time.Time
+}
+
+// Authenticate proves to the anchor that kp controls its account (SEP-10).
+// The challenge transaction sent by the anchor is checked, signed and sent
+// back in exchange of a token. It is never submitted to the network.
+func (a *Anchor) Authenticate(kp *keypair.Full, passphrase string) (*Token, error) {
+	if a.Info.WebAuthEndpoint == "" || a.Info.SigningKey == "" {
+		return nil, fmt.Errorf("%s does not support authentication (SEP-10)", a.Domain)
+	}
+
+	if err := a.checkNetwork(passphrase); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(a.Info.WebAuthEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid WEB_AUTH_ENDPOINT: %v", a.Domain, err)
+	}
+	q := endpoint.Query()
+	q.Set("account", kp.Address())
+	endpoint.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var challenge struct {
+		Transaction       string `json:"transaction"`
+		NetworkPassphrase string `json:"network_passphrase"`
+	}
+	if err := a.do(req, &challenge); err != nil {
+		return nil, err
+	}
+
+	if challenge.NetworkPassphrase != "" && challenge.NetworkPassphrase != passphrase {
+		return nil, errors.New(a.Domain + " sent a challenge for another network: " + challenge.NetworkPassphrase)
+	}
+
+	var txe xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(challenge.Transaction, &txe); err != nil {
+		return nil, fmt.Errorf("%s: invalid challenge: %v", a.Domain, err)
+	}
+
+	if err := VerifyChallenge(txe, a.Info.SigningKey, kp.Address(), passphrase, time.Now()); err != nil {
+		return nil, fmt.Errorf("%s: %v", a.Domain, err)
+	}
+
+	hash, err := network.HashTransaction(&txe.Tx, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := kp.SignDecorated(hash[:])
+	if err != nil {
+		return nil, err
+	}
+	txe.Signatures = append(txe.Signatures, sig)
+
+	signed, err := xdr.MarshalBase64(txe)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(map[string]string{"transaction": signed})
+	if err != nil {
+		return nil, err
+	}
+	req, err = http.NewRequest("POST", a.Info.WebAuthEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := a.do(req, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Token == "" {
+		return nil, fmt.Errorf("%s did not return a token", a.Domain)
+	}
+
+	return &Token{
+		JWT:     resp.Token,
+		Expires: tokenExpiry(resp.Token, time.Now()),
+	}, nil
+}
+
+// VerifyChallenge checks that txe is a SEP-10 challenge for account,
+// issued and signed by server and valid at now.
+func VerifyChallenge(txe xdr.TransactionEnvelope, server, account, passphrase string, now time.Time) error {
+	tx := txe.Tx
+	if tx.SourceAccount.Address() != server {
+		return errors.New("challenge not issued by the signing key of the anchor")
+	}
+
+	if tx.SeqNum != 0 {
+		return errors.New("challenge has a sequence number, it could be submitted to the network")
+	}
+
+	if tb := tx.TimeBounds; tb == nil || tb.MaxTime == 0 ||
+		now.Unix() < int64(tb.MinTime) || now.Unix() > int64(tb.MaxTime) {
+		return errors.New("challenge expired or not valid yet")
+	}
+
+	if len(tx.Operations) == 0 {
+		return errors.New("challenge has no operation")
+	}
+
+	for i, op := range tx.Operations {
+		if op.Body.Type != xdr.OperationTypeManageData {
+			return fmt.Errorf("challenge operation %d is not a manage_data", i+1)
+		}
+
+		// the first operation identifies the client, the others the anchor
+		want := server
+		if i == 0 {
+			want = account
+		}
+		if op.SourceAccount == nil || op.SourceAccount.Address() != want {
+			return fmt.Errorf("challenge operation %d has an unexpected source account", i+1)
+		}
+	}
+
+	kp, err := keypair.Parse(server)
+	if err != nil {
+		return fmt.Errorf("invalid signing key: %v", err)
+	}
+
+	hash, err := network.HashTransaction(&tx, passphrase)
+	if err != nil {
+		return err
+	}
+
+	for _, sig := range txe.Signatures {
+		if sig.Hint == xdr.SignatureHint(kp.Hint()) && kp.Verify(hash[:], sig.Signature) == nil {
+			return nil
+		}
+	}
+
+	return errors.New("challenge not signed by the anchor")
+}
+
+// tokenExpiry returns the expiry of a JWT, from its exp claim.
+// The signature is not checked, the anchor does it when the token is used.
+func tokenExpiry(jwt string, now time.Time) time.Time {
+	parts := strings.Split(jwt, ".")
+	if len(parts) == 3 {
+		var claims struct {
+			Exp int64 `json:"exp"`
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err == nil && json.Unmarshal(payload, &claims) == nil && claims.Exp > 0 {
+			return time.Unix(claims.Exp, 0).UTC()
+		}
+	}
+
+	return now.Add(DefaultTokenLifetime).UTC()
+}
@@ -0,0 +1,148 @@
+package anchor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Customer is what an anchor knows about the owner of an account (SEP-12)
+type Customer struct {
+	ID string `json:"id"`
+	// Status is ACCEPTED, PROCESSING, NEEDS_INFO or REJECTED
+	Status string `json:"status"`
+	// Fields are the fields still needed by the anchor
+	Fields map[string]Field `json:"fields"`
+	// ProvidedFields are the fields already received by the anchor
+	ProvidedFields map[string]Field `json:"provided_fields"`
+	Message        string           `json:"message"`
+}
+
+// CustomerInfoNeededError is returned when the anchor needs to know more
+// about the customer before processing a transfer
+type CustomerInfoNeededError struct {
+	Domain string
+	Fields []string
+}
+
+func (e *CustomerInfoNeededError) Error() string {
+	msg := e.Domain + ": customer information needed"
+	if len(e.Fields) > 0 {
+		msg += " (" + strings.Join(e.Fields, ", ") + ")"
+	}
+	return msg
+}
+
+// kycServer returns the SEP-12 server of the anchor
+func (a *Anchor) kycServer() (string, error) {
+	server := a.Info.KYCServer
+	if server == "" {
+		server = a.Info.TransferServer
+	}
+
+	if server == "" {
+		return "", fmt.Errorf("%s does not support customer information (SEP-12)", a.Domain)
+	}
+
+	return strings.TrimSuffix(server, "/"), nil
+}
+
+// Customer returns the fields needed by the anchor for the owner of account.
+// typ is the kind of customer, such as sep6-withdraw, it can be empty.
+func (a *Anchor) Customer(jwt, account, typ string) (*Customer, error) {
+	server, err := a.kycServer()
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{}
+	q.Set("account", account)
+	if typ != "" {
+		q.Set("type", typ)
+	}
+
+	req, err := http.NewRequest("GET", server+"/customer?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	var customer Customer
+	if err := a.do(req, &customer); err != nil {
+		return nil, err
+	}
+
+	return &customer, nil
+}
+
+// PutCustomer sends the information about the owner of account.
+// values are sent as text, files are the paths of the documents to upload.
+func (a *Anchor) PutCustomer(jwt, account, typ string, values, files map[string]string) (*Customer, error) {
+	server, err := a.kycServer()
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	fields := map[string]string{"account": account}
+	if typ != "" {
+		fields["type"] = typ
+	}
+	for name, value := range values {
+		fields[name] = value
+	}
+	for name, value := range fields {
+		if err := w.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// files go last, as required by SEP-12
+	for name, path := range files {
+		if err := writeFile(w, name, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("PUT", server+"/customer", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	var customer Customer
+	if err := a.do(req, &customer); err != nil {
+		return nil, err
+	}
+
+	return &customer, nil
+}
+
+func writeFile(w *multipart.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	part, err := w.CreateFormFile(name, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, f)
+	return err
+}
@@ -0,0 +1,237 @@
+package anchor
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Protocol is a Stellar ecosystem proposal for deposits and withdrawals
+type Protocol int
+
+const (
+	// SEP6 is the programmatic protocol, the fields are sent by the wallet
+	SEP6 Protocol = 6
+	// SEP24 is the interactive protocol, the fields are collected by the
+	// anchor in a web page
+	SEP24 Protocol = 24
+)
+
+func (p Protocol) String() string {
+	return fmt.Sprintf("SEP-%d", int(p))
+}
+
+// AssetInfo describes the deposits or the withdrawals of an asset
+type AssetInfo struct {
+	Enabled   bool    `json:"enabled"`
+	MinAmount float64 `json:"min_amount"`
+	MaxAmount float64 `json:"max_amount"`
+	// Types are the kinds of withdrawal, such as bank_account, with the
+	// fields they need (SEP-6)
+	Types map[string]WithdrawType `json:"types"`
+}
+
+// WithdrawType is a kind of withdrawal
+type WithdrawType struct {
+	Fields map[string]Field `json:"fields"`
+}
+
+// Field is an information needed by the anchor, such as a bank account number
+type Field struct {
+	// Type is string, binary, number or date, only set for SEP-12 fields
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Optional    bool     `json:"optional"`
+	Choices     []string `json:"choices"`
+}
+
+// TransferInfo lists the assets an anchor accepts, by code
+type TransferInfo struct {
+	Deposit  map[string]AssetInfo `json:"deposit"`
+	Withdraw map[string]AssetInfo `json:"withdraw"`
+}
+
+// Transaction is a deposit or a withdrawal followed by an anchor
+type Transaction struct {
+	ID                   string `json:"id"`
+	Kind                 string `json:"kind"`
+	Status               string `json:"status"`
+	AmountIn             string `json:"amount_in"`
+	AmountOut            string `json:"amount_out"`
+	AmountFee            string `json:"amount_fee"`
+	StellarTransactionID string `json:"stellar_transaction_id"`
+	MoreInfoURL          string `json:"more_info_url"`
+	Message              string `json:"message"`
+}
+
+// terminalStatuses are the statuses after which a transaction does not change
+var terminalStatuses = map[string]bool{
+	"completed": true,
+	"refunded":  true,
+	"expired":   true,
+	"error":     true,
+	"no_market": true,
+	"too_small": true,
+	"too_large": true,
+}
+
+// Done reports whether the anchor finished processing the transaction
+func (t *Transaction) Done() bool {
+	return terminalStatuses[t.Status]
+}
+
+// Interactive is the web page where the anchor collects the information it
+// needs to process a transaction
+type Interactive struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	ID   string `json:"id"`
+}
+
+// WithdrawRequest is a withdrawal sent to an anchor with SEP-6
+type WithdrawRequest struct {
+	Code    string
+	Type    string
+	Account string
+	Amount  string
+	// Fields are the values of the fields of the withdrawal type
+	Fields map[string]string
+}
+
+// WithdrawInstructions tell where to send the asset withdrawn
+type WithdrawInstructions struct {
+	AccountID  string  `json:"account_id"`
+	MemoType   string  `json:"memo_type"`
+	Memo       string  `json:"memo"`
+	ID         string  `json:"id"`
+	ETA        int     `json:"eta"`
+	FeeFixed   float64 `json:"fee_fixed"`
+	FeePercent float64 `json:"fee_percent"`
+}
+
+// server returns the transfer server of the anchor for protocol p
+func (a *Anchor) server(p Protocol) (string, error) {
+	server := a.Info.TransferServer
+	if p == SEP24 {
+		server = a.Info.TransferServerSEP24
+	}
+
+	if server == "" {
+		return "", fmt.Errorf("%s does not support deposits and withdrawals with %v", a.Domain, p)
+	}
+
+	return strings.TrimSuffix(server, "/"), nil
+}
+
+// TransferInfo returns the assets accepted by the anchor with protocol p
+func (a *Anchor) TransferInfo(p Protocol) (*TransferInfo, error) {
+	server, err := a.server(p)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", server+"/info", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info TransferInfo
+	if err := a.do(req, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// InteractiveDeposit starts a deposit of code into account (SEP-24).
+// The amount is optional. The deposit continues in the returned web page.
+func (a *Anchor) InteractiveDeposit(jwt, code, account, amount string) (*Interactive, error) {
+	server, err := a.server(SEP24)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("asset_code", code)
+	form.Set("account", account)
+	if amount != "" {
+		form.Set("amount", amount)
+	}
+
+	req, err := http.NewRequest("POST", server+"/transactions/deposit/interactive", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	var interactive Interactive
+	if err := a.do(req, &interactive); err != nil {
+		return nil, err
+	}
+
+	if interactive.URL == "" {
+		return nil, fmt.Errorf("%s did not return the url of the deposit", a.Domain)
+	}
+
+	return &interactive, nil
+}
+
+// Transaction returns the status of a transaction started with protocol p
+func (a *Anchor) Transaction(p Protocol, jwt, id string) (*Transaction, error) {
+	server, err := a.server(p)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", server+"/transaction?id="+url.QueryEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	var resp struct {
+		Transaction Transaction `json:"transaction"`
+	}
+	if err := a.do(req, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp.Transaction, nil
+}
+
+// Withdraw starts a withdrawal (SEP-6). The withdrawal is processed once the
+// asset is sent to the account of the returned instructions, with their memo.
+func (a *Anchor) Withdraw(jwt string, w WithdrawRequest) (*WithdrawInstructions, error) {
+	server, err := a.server(SEP6)
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{}
+	for name, value := range w.Fields {
+		q.Set(name, value)
+	}
+	q.Set("asset_code", w.Code)
+	q.Set("type", w.Type)
+	q.Set("account", w.Account)
+	q.Set("amount", w.Amount)
+
+	req, err := http.NewRequest("GET", server+"/withdraw?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	var instructions WithdrawInstructions
+	if err := a.do(req, &instructions); err != nil {
+		return nil, err
+	}
+
+	if instructions.AccountID == "" {
+		return nil, fmt.Errorf("%s did not return the account receiving the withdrawal", a.Domain)
+	}
+
+	return &instructions, nil
+}
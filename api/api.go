@@ -0,0 +1,228 @@
+// Package api serves the wallets of alfred over HTTP, so that bots and home
+// automation can check balances, send payments and create offers without
+// running the command line. Every request is authenticated with a bearer token.
+//
+// Payments and offers are commands of the grammar of the please command:
+//
+//	POST /send    {"command": "send 10 XLM from master to jennifer"}
+//	POST /offers  {"command": "buy 100 MOBI using XLM with master"}
+//	GET  /balances/{wallet}
+//	GET  /history/{wallet}?limit=20
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/parser"
+)
+
+// ErrNotFound is returned by a backend when a wallet is not found
+var ErrNotFound = errors.New("wallet not found")
+
+// Balance is the balance of an asset held by a wallet
+type Balance struct {
+	Asset   string `json:"asset"`
+	Issuer  string `json:"issuer,omitempty"`
+	Balance string `json:"balance"`
+}
+
+// Operation is an operation of a transaction of a wallet
+type Operation struct {
+	Time        time.Time `json:"time"`
+	Transaction string    `json:"transaction"`
+	// Index is the position of the operation in its transaction, starting at 1
+	Index       int    `json:"index"`
+	Source      string `json:"source"`
+	Type        string `json:"type"`
+	Amount      string `json:"amount,omitempty"`
+	Asset       string `json:"asset,omitempty"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// Backend executes the requests
+type Backend interface {
+	// Balances returns the balances of a wallet, given by name or address
+	Balances(wallet string) ([]Balance, error)
+	// History returns the operations of the latest transactions of a wallet, most recent first
+	History(wallet string, limit int) ([]Operation, error)
+	// Run executes a statement without prompting and returns the hashes of
+	// the transactions submitted
+	Run(statement parser.Statement) ([]string, error)
+}
+
+// Server is the HTTP handler of the API
+type Server struct {
+	// Token authenticates the requests, as Authorization: Bearer <token>
+	Token string
+	// Version is the version of the grammar of the commands
+	Version parser.Version
+	Backend Backend
+}
+
+// commandRequest is the body of the requests running a command
+type commandRequest struct {
+	Command string `json:"command"`
+}
+
+// commandResponse lists the transactions submitted by a command
+type commandResponse struct {
+	Transactions []string `json:"transactions"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// maxLimit is the maximum number of transactions of the history
+const maxLimit = 200
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="alfred"`)
+		writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "balances":
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+
+		balances, err := s.Backend.Balances(parts[1])
+		if err != nil {
+			writeBackendError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, balances)
+	case len(parts) == 2 && parts[0] == "history":
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+
+		limit := 20
+		if l := r.URL.Query().Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 || n > maxLimit {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("limit should be between 1 and %d", maxLimit))
+				return
+			}
+			limit = n
+		}
+
+		ops, err := s.Backend.History(parts[1], limit)
+		if err != nil {
+			writeBackendError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, ops)
+	case path == "send":
+		s.run(w, r, parser.SendKind)
+	case path == "offers":
+		s.run(w, r, parser.BuyOfferKind, parser.SellOfferKind)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown endpoint /%s", path))
+	}
+}
+
+// run parses the command of the body, which should be one of kinds, and runs it
+func (s *Server) run(w http.ResponseWriter, r *http.Request, kinds ...parser.Kind) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+
+	var req commandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %v", err))
+		return
+	}
+
+	statement, err := parser.ParseWithVersion(req.Command, s.Version)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !hasKind(statement, kinds) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("'%s' can not be run by /%s", req.Command, strings.Trim(r.URL.Path, "/")))
+		return
+	}
+
+	if tb := timeBounds(statement); !tb.NotBefore.IsZero() {
+		writeError(w, http.StatusBadRequest, errors.New("scheduled transactions (NOT BEFORE) are not supported by the API"))
+		return
+	}
+
+	hashes, err := s.Backend.Run(statement)
+	if err != nil {
+		writeBackendError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, commandResponse{Transactions: hashes})
+}
+
+func (s *Server) authenticated(r *http.Request) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if s.Token == "" || !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, prefix)), []byte(s.Token)) == 1
+}
+
+func hasKind(statement parser.Statement, kinds []parser.Kind) bool {
+	for _, kind := range kinds {
+		if statement.Kind() == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func timeBounds(statement parser.Statement) parser.TimeBounds {
+	switch s := statement.(type) {
+	case *parser.SendRequest:
+		return s.TimeBounds
+	case *parser.Offer:
+		return s.TimeBounds
+	}
+	return parser.TimeBounds{}
+}
+
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+func writeBackendError(w http.ResponseWriter, status int, err error) {
+	if err == ErrNotFound {
+		status = http.StatusNotFound
+	}
+	writeError(w, status, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celrenheit/alfred/parser"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBackend struct {
+	ran []parser.Statement
+}
+
+func (b *fakeBackend) Balances(wallet string) ([]Balance, error) {
+	if wallet != "master" {
+		return nil, ErrNotFound
+	}
+	return []Balance{{Asset: "XLM", Balance: "100.0000000"}}, nil
+}
+
+func (b *fakeBackend) History(wallet string, limit int) ([]Operation, error) {
+	return []Operation{{Transaction: "abcd", Index: 1, Type: "payment"}}, nil
+}
+
+func (b *fakeBackend) Run(statement parser.Statement) ([]string, error) {
+	b.ran = append(b.ran, statement)
+	return []string{"abcd"}, nil
+}
+
+func do(t *testing.T, s *Server, method, path, token, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	var v map[string]interface{}
+	if strings.HasPrefix(strings.TrimSpace(w.Body.String()), "{") {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v))
+	}
+	return w, v
+}
+
+func TestServer(t *testing.T) {
+	backend := &fakeBackend{}
+	s := &Server{Token: "token", Version: parser.Latest, Backend: backend}
+
+	w, _ := do(t, s, "GET", "/balances/master", "", "")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = do(t, s, "GET", "/balances/master", "wrong", "")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = do(t, s, "GET", "/balances/master", "token", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `[{"asset":"XLM","balance":"100.0000000"}]`, w.Body.String())
+
+	w, v := do(t, s, "GET", "/balances/savings", "token", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, "wallet not found", v["error"])
+
+	w, _ = do(t, s, "GET", "/history/master?limit=5", "token", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w, _ = do(t, s, "GET", "/history/master?limit=0", "token", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, v = do(t, s, "POST", "/send", "token", `{"command": "send 10 XLM from master to jennifer"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []interface{}{"abcd"}, v["transactions"])
+	require.Equal(t, "jennifer", backend.ran[0].(*parser.SendRequest).To)
+
+	w, _ = do(t, s, "POST", "/offers", "token", `{"command": "buy 100 MOBI using XLM with master"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, backend.ran, 2)
+
+	// the statement should match the endpoint
+	w, _ = do(t, s, "POST", "/send", "token", `{"command": "buy 100 MOBI using XLM with master"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = do(t, s, "POST", "/send", "token", `{"command": "send 10 XLM from master to jennifer not before 2030-01-01"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = do(t, s, "POST", "/send", "token", `{"command": "hello"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Len(t, backend.ran, 2)
+
+	w, _ = do(t, s, "GET", "/send", "token", "")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, "POST", w.Header().Get("Allow"))
+	w, _ = do(t, s, "GET", "/unknown", "token", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// without a token, nothing is served
+	s.Token = ""
+	w, _ = do(t, s, "GET", "/balances/master", "", "")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,98 @@
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/stellar/go/crc16"
+	"github.com/stellar/go/xdr"
+)
+
+const (
+	// versionByteContract is the version byte of the strkey of a contract,
+	// which the vendored strkey package does not know
+	versionByteContract = 2 << 3 // Base32-encodes to 'C...'
+
+	// envelopeTypeContractID and contractIDPreimageFromAsset are the XDR
+	// discriminants of the preimage of the id of a Stellar Asset Contract
+	envelopeTypeContractID      = 8
+	contractIDPreimageFromAsset = 1
+)
+
+// ContractID returns the address of the Stellar Asset Contract of a on the
+// network with passphrase, the contract through which Soroban moves a
+// classic asset
+func (a Asset) ContractID(passphrase string) (string, error) {
+	asset, err := a.BuilderAsset.ToXDR()
+	if err != nil {
+		return "", err
+	}
+
+	var preimage bytes.Buffer
+	networkID := sha256.Sum256([]byte(passphrase))
+	binary.Write(&preimage, binary.BigEndian, int32(envelopeTypeContractID))
+	preimage.Write(networkID[:])
+	binary.Write(&preimage, binary.BigEndian, int32(contractIDPreimageFromAsset))
+	if _, err := xdr.Marshal(&preimage, asset); err != nil {
+		return "", err
+	}
+
+	id := sha256.Sum256(preimage.Bytes())
+	return encodeContract(id[:]), nil
+}
+
+// IsContract reports whether s is the address of a contract
+func IsContract(s string) bool {
+	_, err := decodeContract(s)
+	return err == nil
+}
+
+// GetByContract returns the known asset with code whose Stellar Asset
+// Contract is contract on the network with passphrase. A contract which is
+// not the one of a classic asset is a Soroban token, such as a SEP-41 one,
+// which can not be moved by the operations of this version of stellar/go.
+func GetByContract(code, contract, passphrase string) (*Asset, error) {
+	if !IsContract(contract) {
+		return nil, fmt.Errorf("%s is not the address of a contract", contract)
+	}
+
+	candidates := GetAssets(code)
+	if code == "" {
+		candidates = Assets
+	}
+	for _, a := range candidates {
+		id, err := a.ContractID(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		if id == contract {
+			return &a, nil
+		}
+	}
+
+	return nil, fmt.Errorf("contract %s is not the Stellar Asset Contract of a known asset, Soroban tokens such as SEP-41 ones are not supported", contract)
+}
+
+func encodeContract(id []byte) string {
+	raw := append([]byte{versionByteContract}, id...)
+	return base32.StdEncoding.EncodeToString(append(raw, crc16.Checksum(raw)...))
+}
+
+func decodeContract(s string) ([]byte, error) {
+	raw, err := base32.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 1+32+2 || raw[0] != versionByteContract {
+		return nil, errors.New("invalid contract address")
+	}
+	if err := crc16.Validate(raw[:33], raw[33:]); err != nil {
+		return nil, err
+	}
+
+	return raw[1:33], nil
+}
@@ -0,0 +1,30 @@
+package assets
+
+import (
+	"testing"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/network"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContractID(t *testing.T) {
+	xlm := Asset{BuilderAsset: build.NativeAsset()}
+
+	id, err := xlm.ContractID(network.TestNetworkPassphrase)
+	require.NoError(t, err)
+	require.Equal(t, "CDLZFC3SYJYDZT7K67VZ75HPJVIEUVNIXF47ZG2FB2RMQQVU2HHGCYSC", id)
+	id, err = xlm.ContractID(network.PublicNetworkPassphrase)
+	require.NoError(t, err)
+	require.Equal(t, "CAS3J7GYLGXMF6TDJBBYYSE3HQ6BBSMLNUQ34T6TZMYMW2EVH34XOWMA", id)
+
+	a, err := GetByContract("XLM", id, network.PublicNetworkPassphrase)
+	require.NoError(t, err)
+	require.True(t, a.BuilderAsset.Native)
+
+	_, err = GetByContract("XLM", id, network.TestNetworkPassphrase)
+	require.Error(t, err)
+	_, err = GetByContract("XLM", "GA6HCMBLTZS5VYYBCATRBRZ3BZJMAFUDKYYF6AH6MVCMGWMRDNSWJPIH", network.PublicNetworkPassphrase)
+	require.Error(t, err)
+	require.False(t, IsContract("CDLZFC3SYJYDZT7K67VZ75HPJVIEUVNIXF47ZG2FB2RMQQVU2HHGCYSA"))
+}
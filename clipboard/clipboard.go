@@ -0,0 +1,102 @@
+// Package clipboard copies text to the system clipboard and pastes it, with
+// the tools of the platform: pbcopy and pbpaste on macOS, clip and PowerShell
+// on Windows, and wl-clipboard, xclip or xsel on Linux and BSD.
+package clipboard
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// tool is a command copying its standard input to the clipboard, or printing
+// the clipboard
+type tool struct {
+	name string
+	args []string
+}
+
+// run executes a command with stdin as its input and returns its output,
+// replaced in tests
+var run = func(stdin string, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %v: %s", name, err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// lookPath reports whether a command is installed, replaced in tests
+var lookPath = func(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// Copy places text in the clipboard
+func Copy(text string) error {
+	copier, _, err := find(runtime.GOOS, os.Getenv("WAYLAND_DISPLAY") != "")
+	if err != nil {
+		return err
+	}
+	_, err = run(text, copier.name, copier.args...)
+	return err
+}
+
+// Paste returns the text of the clipboard
+func Paste() (string, error) {
+	_, paster, err := find(runtime.GOOS, os.Getenv("WAYLAND_DISPLAY") != "")
+	if err != nil {
+		return "", err
+	}
+	return run("", paster.name, paster.args...)
+}
+
+// find returns the first installed pair of tools copying to the clipboard of
+// goos and pasting from it, the ones of Wayland first under wayland
+func find(goos string, wayland bool) (copier, paster tool, err error) {
+	candidates, err := tools(goos, wayland)
+	if err != nil {
+		return tool{}, tool{}, err
+	}
+
+	var names []string
+	for _, c := range candidates {
+		if lookPath(c[0].name) && lookPath(c[1].name) {
+			return c[0], c[1], nil
+		}
+		names = append(names, c[0].name)
+	}
+	return tool{}, tool{}, fmt.Errorf("no clipboard tool found, install one of %s", strings.Join(names, ", "))
+}
+
+// tools returns the pairs of tools copying to the clipboard of goos and
+// pasting from it, by preference
+func tools(goos string, wayland bool) ([][2]tool, error) {
+	switch goos {
+	case "darwin":
+		return [][2]tool{{{name: "pbcopy"}, {name: "pbpaste"}}}, nil
+	case "windows":
+		return [][2]tool{{{name: "clip"}, {name: "powershell", args: []string{"-NoProfile", "-NonInteractive", "-Command", "Get-Clipboard"}}}}, nil
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		x11 := [][2]tool{
+			{{name: "xclip", args: []string{"-selection", "clipboard", "-in"}}, {name: "xclip", args: []string{"-selection", "clipboard", "-out"}}},
+			{{name: "xsel", args: []string{"--clipboard", "--input"}}, {name: "xsel", args: []string{"--clipboard", "--output"}}},
+		}
+		wl := [2]tool{{name: "wl-copy"}, {name: "wl-paste", args: []string{"--no-newline"}}}
+		if wayland {
+			return append([][2]tool{wl}, x11...), nil
+		}
+		return append(x11, wl), nil
+	}
+
+	return nil, fmt.Errorf("the clipboard is not supported on %s", goos)
+}
@@ -0,0 +1,57 @@
+package clipboard
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	installed := map[string]bool{}
+	lookPath = func(name string) bool { return installed[name] }
+
+	_, _, err := find("linux", false)
+	require.EqualError(t, err, "no clipboard tool found, install one of xclip, xsel, wl-copy")
+
+	installed["xsel"], installed["wl-copy"], installed["wl-paste"] = true, true, true
+	copier, paster, err := find("linux", false)
+	require.NoError(t, err)
+	require.Equal(t, tool{name: "xsel", args: []string{"--clipboard", "--input"}}, copier)
+	require.Equal(t, tool{name: "xsel", args: []string{"--clipboard", "--output"}}, paster)
+
+	copier, paster, err = find("linux", true)
+	require.NoError(t, err)
+	require.Equal(t, "wl-copy", copier.name)
+	require.Equal(t, "wl-paste", paster.name)
+
+	installed["pbcopy"], installed["pbpaste"] = true, true
+	copier, paster, err = find("darwin", false)
+	require.NoError(t, err)
+	require.Equal(t, "pbcopy", copier.name)
+	require.Equal(t, "pbpaste", paster.name)
+
+	_, _, err = find("plan9", false)
+	require.EqualError(t, err, "the clipboard is not supported on plan9")
+}
+
+func TestCopyPaste(t *testing.T) {
+	lookPath = func(string) bool { return true }
+	var clipboard string
+	run = func(stdin string, name string, args ...string) (string, error) {
+		if stdin != "" {
+			clipboard = stdin
+		}
+		return clipboard, nil
+	}
+
+	if _, err := tools(runtime.GOOS, false); err != nil {
+		require.Equal(t, err, Copy("text"))
+		return
+	}
+
+	require.NoError(t, Copy("AAAAAG..."))
+	pasted, err := Paste()
+	require.NoError(t, err)
+	require.Equal(t, "AAAAAG...", pasted)
+}
@@ -0,0 +1,280 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// accountCmd represents the account command
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Show or change the settings of an account",
+	Long: `Show or change the settings of an account kept on the ledger: its home
+domain, inflation destination, authorization flags and master weight. The
+signers are shown by alfred signers.`,
+	Example: `alfred account show master
+alfred account set master --home-domain example.com --inflation-dest bob`,
+}
+
+// accountShowCmd represents the account show command
+var accountShowCmd = &cobra.Command{
+	Use:   "show [wallet or address]",
+	Short: "Show the settings of an account",
+	Example: `alfred account show master
+alfred account show GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		address, err := accountArg(m, args)
+		if err != nil {
+			fatal(err)
+		}
+
+		acc, exists, err := getAccount(getClient(currentNetwork()), address)
+		if err != nil {
+			fatal(err)
+		}
+		if !exists {
+			fatalf("account %s does not exist", address)
+		}
+
+		fmt.Println("Account:", accountName(m, address), address)
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Setting", "Value"))
+		for _, s := range accountSettings(m, acc) {
+			table.Append([]string{s.name, s.value})
+		}
+		table.Render()
+	},
+}
+
+// accountSetCmd represents the account set command
+var accountSetCmd = &cobra.Command{
+	Use:   "set [wallet]",
+	Short: "Change the settings of an account",
+	Long: `Change the settings of an account given as flags, in a single transaction.
+The current and requested values of the settings changed are shown before
+the confirmation.
+
+The authorization flags are set by the issuers of assets: with auth-required,
+the trustlines to their assets should be authorized before holding them, and
+with auth-revocable, they can be deauthorized. An empty home domain removes it.
+
+A master weight of 0 disables the key of the wallet, the account can then
+only be used with its other signers.`,
+	Example: `alfred account set master --home-domain example.com
+alfred account set master --inflation-dest GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6
+alfred account set issuer --auth-required --auth-revocable=false
+alfred account set master --master-weight 2`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		var name string
+		if len(args) == 1 {
+			name = args[0]
+		}
+		src, err := getOrSelectWallet(m, name)
+		if err != nil {
+			fatal(err)
+		}
+
+		client := getClient(currentNetwork())
+		acc, exists, err := getAccount(client, src.Address())
+		if err != nil {
+			fatal(err)
+		}
+		if !exists {
+			fatalf("account %s does not exist", src.Address())
+		}
+
+		muts, changes, err := accountChanges(m, acc, cmd.Flags())
+		if err != nil {
+			fatal(err)
+		}
+		if len(changes) == 0 {
+			fatal("nothing to change, see alfred account set --help for the settings")
+		}
+
+		fmt.Println("Account:", accountName(m, src.Address()), src.Address())
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Setting", "Current", "Requested"))
+		for _, c := range changes {
+			table.Append([]string{c.name, c.value, c.requested})
+		}
+		table.Render()
+
+		seed, err := src.Seed()
+		if err != nil {
+			fatal(err)
+		}
+
+		opts := []build.TransactionMutator{
+			build.SourceAccount{seed},
+			build.AutoSequence{SequenceProvider: client},
+			build.SetOptions(muts...),
+		}
+
+		opts = append(opts, currentNetwork().mutator())
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		err = submitTx(txRequest{
+			client:   client,
+			db:       m,
+			seeds:    []string{seed},
+			opts:     opts,
+			validFor: viper.GetDuration("valid-for"),
+			retries:  viper.GetInt("retries"),
+			yes:      yes || viper.GetBool("yes"),
+		})
+		if err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// accountSetting is a setting of an account, with its requested value when
+// it is changed
+type accountSetting struct {
+	name, value, requested string
+}
+
+// accountSettings returns the settings of acc shown by alfred account show
+func accountSettings(m *wallet.Alfred, acc horizon.Account) []accountSetting {
+	inflation := "none"
+	if acc.InflationDestination != "" {
+		inflation = accountName(m, acc.InflationDestination)
+	}
+
+	return []accountSetting{
+		{name: "Home domain", value: firstNonEmpty(acc.HomeDomain, "none")},
+		{name: "Inflation destination", value: inflation},
+		{name: "Auth required", value: yesNo(acc.Flags.AuthRequired)},
+		{name: "Auth revocable", value: yesNo(acc.Flags.AuthRevocable)},
+		{name: "Master weight", value: strconv.Itoa(int(masterWeight(acc)))},
+	}
+}
+
+// accountChanges returns the mutators of the set options operation applying
+// the flags changed, and the settings they change
+func accountChanges(m *wallet.Alfred, acc horizon.Account, flags *pflag.FlagSet) ([]interface{}, []accountSetting, error) {
+	current := map[string]accountSetting{}
+	for _, s := range accountSettings(m, acc) {
+		current[s.name] = s
+	}
+
+	var (
+		muts    []interface{}
+		changes []accountSetting
+	)
+	change := func(name, requested string, mut interface{}) {
+		s := current[name]
+		if s.value == requested {
+			return
+		}
+		s.requested = requested
+		changes = append(changes, s)
+		muts = append(muts, mut)
+	}
+
+	if flags.Changed("home-domain") {
+		domain, _ := flags.GetString("home-domain")
+		if len(domain) > 32 {
+			return nil, nil, errors.New("the home domain should be at most 32 characters long")
+		}
+		change("Home domain", firstNonEmpty(domain, "none"), build.HomeDomain(domain))
+	}
+
+	if flags.Changed("inflation-dest") {
+		name, _ := flags.GetString("inflation-dest")
+		dest, _, err := resolveDestination(m, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		change("Inflation destination", accountName(m, dest), build.InflationDest(dest))
+	}
+
+	for _, f := range []struct {
+		flag, name string
+		set        build.SetFlag
+		clear      build.ClearFlag
+	}{
+		{"auth-required", "Auth required", build.SetAuthRequired(), build.ClearAuthRequired()},
+		{"auth-revocable", "Auth revocable", build.SetAuthRevocable(), build.ClearAuthRevocable()},
+	} {
+		if !flags.Changed(f.flag) {
+			continue
+		}
+		if on, _ := flags.GetBool(f.flag); on {
+			change(f.name, yesNo(true), f.set)
+		} else {
+			change(f.name, yesNo(false), f.clear)
+		}
+	}
+
+	if flags.Changed("master-weight") {
+		weight, _ := flags.GetUint8("master-weight")
+		if weight == 0 && len(acc.Signers) <= 1 {
+			return nil, nil, errors.New("a master weight of 0 would lock the account, which has no other signer")
+		}
+		change("Master weight", strconv.Itoa(int(weight)), build.MasterWeight(uint32(weight)))
+	}
+
+	return muts, changes, nil
+}
+
+// masterWeight returns the weight of the key of acc
+func masterWeight(acc horizon.Account) int32 {
+	for _, s := range acc.Signers {
+		if signerKey(s) == acc.ID {
+			return s.Weight
+		}
+	}
+	return 0
+}
+
+func init() {
+	RootCmd.AddCommand(accountCmd)
+	accountCmd.AddCommand(accountShowCmd)
+	accountCmd.AddCommand(accountSetCmd)
+
+	accountSetCmd.Flags().String("home-domain", "", "domain publishing the stellar.toml of the account, empty to remove it")
+	accountSetCmd.Flags().String("inflation-dest", "", "wallet, contact or address receiving the inflation votes of the account")
+	accountSetCmd.Flags().Bool("auth-required", false, "whether trustlines to the assets of the account should be authorized")
+	accountSetCmd.Flags().Bool("auth-revocable", false, "whether trustlines to the assets of the account can be deauthorized")
+	accountSetCmd.Flags().Uint8("master-weight", 1, "weight of the key of the account, 0 to disable it")
+	accountSetCmd.Flags().BoolP("yes", "y", false, "if set, no confirmation prompt will be shown")
+}
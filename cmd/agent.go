@@ -0,0 +1,210 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/celrenheit/alfred/agent"
+	"github.com/celrenheit/alfred/tx"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+)
+
+// agentCmd represents the agent command
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Cache the secret for a while so that commands do not prompt for it",
+	Long: `Run an agent caching the secret of databases, like ssh-agent.
+
+While the agent runs, the secret is prompted once and reused by the following
+commands until it expires. The agent listens on a unix socket only accessible
+by the current user, see --agent.
+
+The agent can also hold the keys of wallets, added with alfred agent add. The
+commands then ask the agent to sign with them instead of decrypting their
+seeds, so that a wallet protected with a passphrase is unlocked once for a
+whole script. The memory of the agent is locked so that the keys are not
+swapped to disk, where the system allows it.`,
+	Example: `alfred agent --ttl 15m &
+alfred agent add savings
+alfred agent forget`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ttl, _ := cmd.Flags().GetDuration("ttl")
+		if ttl <= 0 {
+			fatal("ttl should be positive")
+		}
+
+		path := viper.GetString("agent")
+		l, err := agent.Listen(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := agent.LockMemory(); err != nil {
+			logger.Warnf("the memory of the agent is not locked, the keys it holds may be swapped to disk: %v", err)
+		}
+
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			l.Close()
+		}()
+
+		fmt.Printf("Agent listening on %s, secrets and keys are forgotten after %v\n", path, ttl)
+		agent.Serve(l, agent.New(ttl))
+		os.Remove(path)
+	},
+}
+
+// agentAddCmd represents the agent add command
+var agentAddCmd = &cobra.Command{
+	Use:   "add <wallet>...",
+	Short: "Make the agent hold the keys of wallets and sign with them",
+	Long: `Make the agent hold the keys of wallets until it forgets them, see --ttl of
+alfred agent. The commands run meanwhile ask the agent to sign the
+transactions of these wallets, without prompting for their passphrase.`,
+	Example: `alfred agent add savings
+alfred agent add alice bob`,
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		client, err := agent.Dial(viper.GetString("agent"))
+		if err != nil {
+			fatal("no agent running:", err)
+		}
+		defer client.Close()
+
+		var seeds []string
+		for _, name := range args {
+			w := m.WalletByName(name)
+			if w == nil {
+				fatalf("wallet '%s' not found%s", name, suggestName(name, accountNames(m, false)))
+			}
+			kp, err := hotKey(w)
+			if err != nil {
+				fatal(err)
+			}
+			seeds = append(seeds, kp.Seed())
+		}
+
+		if err := client.AddKeys(seeds...); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("The agent holds the keys of %s\n", strings.Join(args, ", "))
+	},
+}
+
+// agentForgetCmd represents the agent forget command
+var agentForgetCmd = &cobra.Command{
+	Use:     "forget",
+	Short:   "Remove every secret and key held by the agent",
+	Example: "alfred agent forget",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := agent.Dial(viper.GetString("agent"))
+		if err != nil {
+			fatal("no agent running:", err)
+		}
+		defer client.Close()
+
+		if err := client.Forget(); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// agentKeys are the addresses of the keys held by the agent, loaded once
+var agentKeys struct {
+	sync.Once
+	held map[string]bool
+}
+
+// agentHolds reports whether the running agent holds the key of address
+func agentHolds(address string) bool {
+	agentKeys.Do(func() {
+		agentKeys.held = make(map[string]bool)
+
+		client, err := agent.Dial(viper.GetString("agent"))
+		if err != nil {
+			return
+		}
+		defer client.Close()
+
+		addresses, err := client.Keys()
+		if err != nil {
+			return
+		}
+		for _, address := range addresses {
+			agentKeys.held[address] = true
+		}
+	})
+
+	return agentKeys.held[address]
+}
+
+// agentSigner is the tx.Signer of a key held by the agent
+type agentSigner struct {
+	address string
+}
+
+// Sign implements tx.Signer
+func (s agentSigner) Sign(hash [32]byte) (xdr.DecoratedSignature, error) {
+	client, err := agent.Dial(viper.GetString("agent"))
+	if err != nil {
+		return xdr.DecoratedSignature{}, err
+	}
+	defer client.Close()
+
+	return client.Sign(s.address, hash)
+}
+
+// splitDelegated returns the seeds whose keys the agent does not hold, and
+// the signers of the other ones, given as addresses
+func splitDelegated(seeds []string) (rest []string, signers []tx.Signer) {
+	for _, seed := range seeds {
+		if kp, err := keypair.Parse(seed); err == nil {
+			if _, ok := kp.(*keypair.FromAddress); ok && agentHolds(seed) {
+				signers = append(signers, agentSigner{seed})
+				continue
+			}
+		}
+		rest = append(rest, seed)
+	}
+
+	return rest, signers
+}
+
+func init() {
+	RootCmd.AddCommand(agentCmd)
+	agentCmd.AddCommand(agentAddCmd)
+	agentCmd.AddCommand(agentForgetCmd)
+
+	agentCmd.Flags().Duration("ttl", 15*time.Minute, "how long a secret or key is held")
+}
@@ -0,0 +1,135 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// aliasCmd represents the alias command
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "List the aliases of the wallets and contacts",
+	Long: `List the other names given to the wallets and contacts. An alias is used
+like the name it stands for, in the please statements as in the arguments of
+the commands, and is unique among the names and aliases.`,
+	Example: `alfred alias add jennifer jen jenny
+alfred alias
+alfred please send 10 XLM from master to jen
+alfred alias remove jenny`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Name", "Kind", "Aliases"))
+		found := false
+		for _, a := range namedAccounts(m) {
+			if len(a.aliases) > 0 {
+				found = true
+				table.Append([]string{a.name, a.kind, strings.Join(a.aliases, ", ")})
+			}
+		}
+		if !found {
+			fmt.Println("No alias, add one with: alfred alias add <wallet or contact> <alias>")
+			return
+		}
+		table.Render()
+	},
+}
+
+// aliasAddCmd represents the alias add command
+var aliasAddCmd = &cobra.Command{
+	Use:     "add <wallet or contact> <alias>...",
+	Short:   "Give aliases to a wallet or contact",
+	Example: "alfred alias add jennifer jen jenny",
+	Args:    cobra.MinimumNArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			for _, alias := range args[1:] {
+				if err := m.AddAlias(args[0], alias); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// aliasRemoveCmd represents the alias remove command
+var aliasRemoveCmd = &cobra.Command{
+	Use:     "remove <alias>...",
+	Short:   "Remove aliases",
+	Example: "alfred alias remove jenny",
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			for _, alias := range args {
+				if err := m.RemoveAlias(alias); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(aliasCmd)
+	aliasCmd.AddCommand(aliasAddCmd)
+	aliasCmd.AddCommand(aliasRemoveCmd)
+}
+
+// namedAccount is a wallet or contact with its aliases and tags
+type namedAccount struct {
+	name, kind, address string
+	aliases, tags       []string
+}
+
+// namedAccounts returns the wallets of m followed by its contacts, in the
+// order of their names
+func namedAccounts(m *wallet.Alfred) []namedAccount {
+	var accounts []namedAccount
+	for _, w := range m.Stellar.Wallets {
+		accounts = append(accounts, namedAccount{w.Name, "wallet", w.Keypair.Address(), w.Aliases, w.Tags})
+	}
+
+	var names []string
+	for name := range m.Stellar.Contacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := m.Stellar.Contacts[name]
+		accounts = append(accounts, namedAccount{name, "contact", c.Address, c.Aliases, c.Tags})
+	}
+	return accounts
+}
@@ -0,0 +1,502 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/anchor"
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// anchorPollInterval is the delay between two checks of the status of a transfer
+var anchorPollInterval = 5 * time.Second
+
+// depositRequest deposits an asset into a wallet through the interactive flow
+// of an anchor (SEP-24): the anchor collects the payment details in a web page
+// and sends the asset once it received the funds.
+func depositRequest(m *wallet.Alfred, client *horizon.Client, req *parser.Transfer) error {
+	kp, err := getOrSelectWallet(m, req.Account)
+	if err != nil {
+		return err
+	}
+
+	a, err := anchor.Discover(anchorHTTP, req.Anchor)
+	if err != nil {
+		return err
+	}
+
+	info, err := a.TransferInfo(anchor.SEP24)
+	if err != nil {
+		return err
+	}
+
+	code, asset, ok := lookupTransferAsset(info.Deposit, req.Currency)
+	if !ok || !asset.Enabled {
+		return i18n.Errorf("%s does not accept deposits of %s", a.Domain, req.Currency)
+	}
+
+	if err := checkTransferAmount(req.Amount, asset); err != nil {
+		return err
+	}
+
+	// the asset can only be received once the wallet trusts it
+	if cur, ok := a.Currency(code); ok && cur.Issuer != "" {
+		acc, exists, err := getAccount(client, kp.Address())
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return i18n.Errorf("%s does not exist, please fund it first", accountName(m, kp.Address()))
+		}
+
+		if !hasTrustline(acc, assets.Asset{BuilderAsset: build.CreditAsset(cur.Code, cur.Issuer)}) {
+			return i18n.Errorf("%s needs to trust %s before receiving it, trust it first with: alfred trust %s %s",
+				accountName(m, kp.Address()), cur.Code, cur.Code, cur.Issuer)
+		}
+	}
+
+	jwt, err := anchorToken(m, a, kp, false)
+	if err != nil {
+		return err
+	}
+
+	interactive, err := a.InteractiveDeposit(jwt, code, kp.Address(), req.Amount)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Continue the deposit in your browser:", interactive.URL)
+	if err := openBrowser(interactive.URL); err != nil {
+		fmt.Println("Unable to open the browser, please open the link above")
+	}
+
+	tx, err := waitTransfer(a, anchor.SEP24, jwt, interactive.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deposit completed: %s %s received by %s\n", firstNonEmpty(tx.AmountOut, req.Amount), code, accountName(m, kp.Address()))
+	if tx.StellarTransactionID != "" {
+		fmt.Println("Transaction:", tx.StellarTransactionID)
+	}
+
+	return nil
+}
+
+// withdrawRequest withdraws an asset from a wallet to a bank account with the
+// programmatic flow of an anchor (SEP-6): the fields needed by the anchor are
+// prompted, then the asset is sent to the account returned by the anchor.
+func withdrawRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *parser.Transfer) error {
+	kp, err := getOrSelectWallet(m, req.Account)
+	if err != nil {
+		return err
+	}
+
+	a, err := anchor.Discover(anchorHTTP, req.Anchor)
+	if err != nil {
+		return err
+	}
+
+	info, err := a.TransferInfo(anchor.SEP6)
+	if err != nil {
+		return err
+	}
+
+	code, asset, ok := lookupTransferAsset(info.Withdraw, req.Currency)
+	if !ok || !asset.Enabled {
+		return i18n.Errorf("%s does not accept withdrawals of %s", a.Domain, req.Currency)
+	}
+
+	if err := checkTransferAmount(req.Amount, asset); err != nil {
+		return err
+	}
+
+	cur, ok := a.Currency(code)
+	if !ok || cur.Issuer == "" {
+		return i18n.Errorf("%s does not publish the issuer of %s in its stellar.toml", a.Domain, code)
+	}
+
+	typ, err := selectWithdrawType(asset, req.Destination)
+	if err != nil {
+		return err
+	}
+
+	given, _ := cmd.Flags().GetStringArray("field")
+	fields, err := collectFields(asset.Types[typ].Fields, given)
+	if err != nil {
+		return err
+	}
+
+	jwt, err := anchorToken(m, a, kp, false)
+	if err != nil {
+		return err
+	}
+
+	withdraw := anchor.WithdrawRequest{
+		Code:    code,
+		Type:    typ,
+		Account: kp.Address(),
+		Amount:  req.Amount,
+		Fields:  fields,
+	}
+	instructions, err := a.Withdraw(jwt, withdraw)
+	if _, ok := err.(*anchor.CustomerInfoNeededError); ok {
+		fmt.Printf("%s needs information about you before processing the withdrawal\n", a.Domain)
+		if _, err := collectKYC(m, a, kp, jwt, ""); err != nil {
+			return err
+		}
+
+		instructions, err = a.Withdraw(jwt, withdraw)
+	}
+	if err != nil {
+		return err
+	}
+
+	memo, err := withdrawMemo(instructions)
+	if err != nil {
+		return i18n.Errorf("%s: %v", a.Domain, err)
+	}
+
+	sent := assets.Asset{BuilderAsset: build.CreditAsset(cur.Code, cur.Issuer)}
+	confirm, err := enforcePolicy(m, client, kp.Address(), &sent, []outgoing{{to: instructions.AccountID, amount: req.Amount}})
+	if err != nil {
+		return err
+	}
+
+	summary := map[string]string{
+		"Amount":      formatAmount(req.Amount, code),
+		"Currency":    code,
+		"Source":      describeAccount(m, kp.Address()),
+		"Destination": describeAccount(m, instructions.AccountID),
+		"Withdrawal":  fmt.Sprintf("to %s via %s", strings.Replace(typ, "_", " ", -1), a.Domain),
+	}
+	if memo != nil {
+		summary["Memo"] = instructions.Memo
+	}
+	if instructions.FeeFixed > 0 || instructions.FeePercent > 0 {
+		summary["Anchor fee"] = fmt.Sprintf("%v %s + %v%%", instructions.FeeFixed, code, instructions.FeePercent)
+	}
+	if instructions.ETA > 0 {
+		summary["Estimated time"] = (time.Duration(instructions.ETA) * time.Second).String()
+	}
+
+	seed, err := kp.Seed()
+	if err != nil {
+		return err
+	}
+
+	opts := []build.TransactionMutator{
+		build.SourceAccount{seed},
+		build.AutoSequence{SequenceProvider: client},
+		build.Payment(
+			build.Destination{AddressOrSeed: instructions.AccountID},
+			build.CreditAmount{Code: cur.Code, Issuer: cur.Issuer, Amount: req.Amount},
+		),
+	}
+	if memo != nil {
+		opts = append(opts, memo.ToTransactionMutator())
+	}
+
+	opts = append(opts, currentNetwork().mutator())
+
+	err = submitTx(txRequest{
+		client:   client,
+		db:       m,
+		seeds:    []string{seed},
+		opts:     opts,
+		summary:  summary,
+		validFor: viper.GetDuration("valid-for"),
+		retries:  viper.GetInt("retries"),
+		yes:      viper.GetBool("yes") && !confirm,
+	})
+	if err != nil {
+		return err
+	}
+
+	if instructions.ID == "" {
+		return nil
+	}
+
+	tx, err := waitTransfer(a, anchor.SEP6, jwt, instructions.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Withdrawal completed: %s %s sent to your %s\n", firstNonEmpty(tx.AmountOut, req.Amount), code, strings.Replace(typ, "_", " ", -1))
+	return nil
+}
+
+// selectWithdrawType returns the kind of withdrawal matching dest, such as
+// bank_account for bank. It is prompted if dest is empty and the anchor
+// accepts several kinds.
+func selectWithdrawType(info anchor.AssetInfo, dest string) (string, error) {
+	var types []string
+	for t := range info.Types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	if len(types) == 0 {
+		return dest, nil
+	}
+
+	if dest != "" {
+		for _, t := range types {
+			if strings.EqualFold(t, dest) || strings.HasPrefix(strings.ToLower(t), strings.ToLower(dest)+"_") {
+				return t, nil
+			}
+		}
+
+		return "", i18n.Errorf("unknown withdrawal to '%s', should be one of %s", dest, strings.Join(types, ", "))
+	}
+
+	if len(types) == 1 {
+		return types[0], nil
+	}
+
+	if err := checkInteractive("withdrawal type", "the anchor accepts several kinds of withdrawal", "TO <kind> in the statement, one of "+strings.Join(types, ", ")); err != nil {
+		return "", err
+	}
+
+	_, t, err := (&promptui.Select{
+		Label: i18n.T("Withdraw to"),
+		Items: types,
+	}).Run()
+	return t, err
+}
+
+// collectFields prompts the fields needed by an anchor, except the ones
+// given as name=value
+func collectFields(fields map[string]anchor.Field, given []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, kv := range given {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, i18n.Errorf("invalid field '%s', should be name=value", kv)
+		}
+		values[parts[0]] = parts[1]
+	}
+
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := values[name]; ok {
+			continue
+		}
+
+		if !interactive && fields[name].Optional {
+			continue
+		}
+		if err := checkInteractive("field", "the anchor needs "+name, "--field "+name+"=<value>"); err != nil {
+			return nil, err
+		}
+
+		value, err := promptField(name, fields[name], "")
+		if err != nil {
+			return nil, err
+		}
+
+		if value != "" {
+			values[name] = value
+		}
+	}
+
+	return values, nil
+}
+
+// promptField prompts the value of a field needed by an anchor, def is the
+// value suggested. Documents (binary fields) are prompted as a file path.
+func promptField(name string, field anchor.Field, def string) (string, error) {
+	label := firstNonEmpty(field.Description, name)
+
+	if len(field.Choices) > 0 {
+		_, value, err := (&promptui.Select{
+			Label: label,
+			Items: field.Choices,
+		}).Run()
+		return value, err
+	}
+
+	if field.Type == "binary" {
+		label += " (path of the file)"
+	}
+
+	return (&promptui.Prompt{
+		Label:   label,
+		Default: def,
+		Validate: func(input string) error {
+			if input == "" {
+				if field.Optional {
+					return nil
+				}
+				return errors.New("should not be empty")
+			}
+
+			switch field.Type {
+			case "binary":
+				if _, err := os.Stat(input); err != nil {
+					return err
+				}
+			case "number":
+				if _, err := strconv.ParseFloat(input, 64); err != nil {
+					return errors.New("should be a number")
+				}
+			case "date":
+				if _, err := time.Parse("2006-01-02", input); err != nil {
+					return errors.New("should be a date such as 2006-01-02")
+				}
+			}
+
+			return nil
+		},
+	}).Run()
+}
+
+// withdrawMemo returns the memo the anchor expects with the payment
+func withdrawMemo(instructions *anchor.WithdrawInstructions) (*wallet.Memo, error) {
+	if instructions.Memo == "" {
+		return nil, nil
+	}
+
+	kinds := map[string]wallet.MemoKind{
+		"":     wallet.MEMO_TEXT,
+		"text": wallet.MEMO_TEXT,
+		"id":   wallet.MEMO_ID,
+		"hash": wallet.MEMO_HASH,
+	}
+	kind, ok := kinds[instructions.MemoType]
+	if !ok {
+		return nil, i18n.Errorf("unsupported memo type '%s'", instructions.MemoType)
+	}
+
+	return wallet.MemoFromString(kind, instructions.Memo)
+}
+
+// waitTransfer polls the status of a transfer until the anchor finishes
+// processing it, it returns an error if the transfer did not complete.
+func waitTransfer(a *anchor.Anchor, p anchor.Protocol, jwt, id string) (*anchor.Transaction, error) {
+	fmt.Println("Waiting for", a.Domain, "(Ctrl-C to stop waiting, the transfer continues)")
+
+	var status string
+	for {
+		tx, err := a.Transaction(p, jwt, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if tx.Status != status {
+			status = tx.Status
+			fmt.Printf("  %s status: %s\n", time.Now().Format("15:04:05"), strings.Replace(status, "_", " ", -1))
+		}
+
+		if tx.Done() {
+			if tx.Status == "completed" {
+				return tx, nil
+			}
+
+			msg := fmt.Sprintf("transfer %s: %s", id, tx.Status)
+			if tx.Message != "" {
+				msg += ", " + tx.Message
+			}
+			if tx.MoreInfoURL != "" {
+				msg += ", see " + tx.MoreInfoURL
+			}
+			return tx, errors.New(msg)
+		}
+
+		time.Sleep(anchorPollInterval)
+	}
+}
+
+// lookupTransferAsset finds the asset with code in the assets accepted by an
+// anchor, regardless of the case
+func lookupTransferAsset(list map[string]anchor.AssetInfo, code string) (string, anchor.AssetInfo, bool) {
+	for c, info := range list {
+		if strings.EqualFold(c, code) {
+			return c, info, true
+		}
+	}
+
+	return "", anchor.AssetInfo{}, false
+}
+
+// checkTransferAmount checks an amount against the limits of an anchor
+func checkTransferAmount(amount string, info anchor.AssetInfo) error {
+	if amount == "" {
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(amount, 64)
+	if err != nil || f <= 0 {
+		return i18n.Errorf("invalid amount '%s'", amount)
+	}
+
+	if info.MinAmount > 0 && f < info.MinAmount {
+		return i18n.Errorf("the minimum amount is %v", info.MinAmount)
+	}
+
+	if info.MaxAmount > 0 && f > info.MaxAmount {
+		return i18n.Errorf("the maximum amount is %v", info.MaxAmount)
+	}
+
+	return nil
+}
+
+// openBrowser opens url in the default browser
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	return cmd.Start()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
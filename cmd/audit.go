@@ -0,0 +1,252 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/explain"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+type auditPriority int
+
+const (
+	priorityHigh auditPriority = iota
+	priorityMedium
+	priorityLow
+)
+
+func (p auditPriority) String() string {
+	return [...]string{"HIGH", "MEDIUM", "LOW"}[p]
+}
+
+type auditFinding struct {
+	Priority auditPriority
+	Subject  string
+	Issue    string
+	Fix      string
+}
+
+var commonSecrets = []string{
+	"password", "passw0rd", "12345678", "123456789", "1234567890", "qwertyui",
+	"qwerty123", "azertyui", "iloveyou", "alfred", "alfredpassword", "stellar", "secret",
+}
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check the security of your setup",
+	Long: `Check the security of your setup and print prioritized remediation steps.
+
+The following is checked: strength of the secret, permissions of the database,
+signers and thresholds of the wallets, balances below the reserve, unknown
+signers, unverified assets and unused trustlines.`,
+	Example: "alfred audit",
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		secret := viper.GetString("secret")
+		m, err := wallet.OpenSecretString(path, secret)
+		if err != nil {
+			fatal(err)
+		}
+
+		var findings []auditFinding
+		findings = append(findings, auditSecret(cmd, secret)...)
+		findings = append(findings, auditFile(path, "database")...)
+		if cfg := viper.ConfigFileUsed(); cfg != "" {
+			findings = append(findings, auditFile(cfg, "config file")...)
+		}
+
+		client := getClient(currentNetwork())
+		wallets := m.Stellar.Wallets
+		inOrder(len(wallets), func(i int) interface{} {
+			acc, exists, err := getAccount(client, wallets[i].Keypair.Address())
+			if err == nil && !exists {
+				return nil
+			}
+			return loadedAccount{acc, err}
+		}, func(i int, v interface{}) {
+			loaded, ok := v.(loadedAccount)
+			switch {
+			case !ok:
+			case loaded.err != nil:
+				findings = append(findings, auditFinding{priorityLow, wallets[i].Name, "unable to load account: " + describeHorizonError(loaded.err), "run the audit again later"})
+			default:
+				findings = append(findings, auditAccount(m, wallets[i], loaded.acc)...)
+			}
+		})
+
+		if len(findings) == 0 {
+			fmt.Println("No issue found")
+			return
+		}
+
+		sort.SliceStable(findings, func(i, j int) bool {
+			return findings[i].Priority < findings[j].Priority
+		})
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Priority", "Subject", "Issue", "Remediation"))
+		table.SetRowLine(true)
+		for _, f := range findings {
+			table.Append([]string{f.Priority.String(), f.Subject, f.Issue, f.Fix})
+		}
+		table.Render()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(auditCmd)
+
+	viper.BindPFlags(auditCmd.Flags())
+}
+
+func auditSecret(cmd *cobra.Command, secret string) (findings []auditFinding) {
+	if f := cmd.Flag("secret"); f != nil && f.Changed {
+		findings = append(findings, auditFinding{priorityMedium, "secret",
+			"the secret was given on the command line and may be stored in your shell history",
+			"omit --secret to be prompted for it, then clear it from your shell history"})
+	}
+
+	weak := ""
+	lower := strings.ToLower(secret)
+	for _, common := range commonSecrets {
+		if strings.Contains(lower, common) {
+			weak = "it contains a common password"
+		}
+	}
+
+	var classes int
+	for _, isClass := range []func(rune) bool{unicode.IsLower, unicode.IsUpper, unicode.IsDigit, unicode.IsPunct} {
+		if strings.IndexFunc(secret, isClass) >= 0 {
+			classes++
+		}
+	}
+
+	switch {
+	case weak != "":
+	case len(secret) < 12:
+		weak = "it is shorter than 12 characters"
+	case classes < 2 && len(secret) < 20:
+		weak = "it uses a single kind of characters"
+	}
+
+	if weak != "" {
+		findings = append(findings, auditFinding{priorityHigh, "secret",
+			"the secret is weak, " + weak,
+			"create a new database protected by a long passphrase with alfred --db new.yaml import, and import your wallets in it"})
+	}
+
+	return findings
+}
+
+func auditFile(path, name string) []auditFinding {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return []auditFinding{{priorityHigh, name,
+			fmt.Sprintf("%s is readable by other users (%04o)", path, perm),
+			fmt.Sprintf("chmod 600 %s", path)}}
+	}
+
+	return nil
+}
+
+func auditAccount(m *wallet.Alfred, w *wallet.Wallet, acc horizon.Account) (findings []auditFinding) {
+	var (
+		masterWeight int32
+		totalWeight  int32
+	)
+	for _, s := range acc.Signers {
+		totalWeight += s.Weight
+		if s.Key == acc.ID || s.PublicKey == acc.ID {
+			masterWeight = s.Weight
+			continue
+		}
+
+		if s.Weight > 0 && accountName(m, s.Key) == wallet.TrimAddress(s.Key) { // neither a wallet nor a contact
+			findings = append(findings, auditFinding{priorityMedium, w.Name,
+				fmt.Sprintf("unknown signer %s with weight %d", s.Key, s.Weight),
+				"if this key is no longer used, remove it with a set options operation setting its weight to 0"})
+		}
+	}
+
+	switch {
+	case totalWeight == 0:
+		findings = append(findings, auditFinding{priorityHigh, w.Name,
+			"the account has no signer left and is locked forever", "do not send anything more to this account"})
+	case totalWeight < int32(acc.Thresholds.MedThreshold):
+		findings = append(findings, auditFinding{priorityHigh, w.Name,
+			fmt.Sprintf("signers weigh %d in total, below the medium threshold %d: payments can no longer be made", totalWeight, acc.Thresholds.MedThreshold),
+			"do not send anything more to this account"})
+	case totalWeight < int32(acc.Thresholds.HighThreshold):
+		findings = append(findings, auditFinding{priorityHigh, w.Name,
+			fmt.Sprintf("signers weigh %d in total, below the high threshold %d: signers and thresholds can no longer be changed", totalWeight, acc.Thresholds.HighThreshold),
+			fmt.Sprintf("move the funds to another wallet: alfred please send <amount> XLM from %s to <wallet>", w.Name)})
+	case len(acc.Signers) > 1 && masterWeight >= int32(acc.Thresholds.HighThreshold) && masterWeight > 0:
+		findings = append(findings, auditFinding{priorityLow, w.Name,
+			"the account is shared but its master key alone can authorize any operation",
+			"lower the master weight below the high threshold if every signer should approve"})
+	}
+
+	minimum := xdr.Int64(explain.MinimumBalance(acc) * amount.One)
+	native, _ := amount.Parse(acc.GetNativeBalance())
+	switch {
+	case native < minimum:
+		findings = append(findings, auditFinding{priorityHigh, w.Name,
+			fmt.Sprintf("balance of %s XLM is below the minimum reserve of %s XLM", formatStroops(native, "XLM"), formatStroops(minimum, "XLM")),
+			fmt.Sprintf("alfred please send %s XLM to %s", amount.String(minimum-native+amount.One), w.Name)})
+	case native < minimum+amount.One:
+		findings = append(findings, auditFinding{priorityMedium, w.Name,
+			fmt.Sprintf("only %s XLM available above the reserve of %s XLM", formatStroops(native-minimum, "XLM"), formatStroops(minimum, "XLM")),
+			fmt.Sprintf("alfred please send 5 XLM to %s", w.Name)})
+	}
+
+	for _, b := range acc.Balances {
+		if b.Asset.Type == "native" {
+			continue
+		}
+
+		if assets.GetByCodeIssuer(b.Asset.Code, b.Asset.Issuer) == nil {
+			findings = append(findings, auditFinding{priorityMedium, w.Name,
+				fmt.Sprintf("holds %s issued by %s which is not a known asset", b.Asset.Code, b.Asset.Issuer),
+				"check the stellar.toml of the issuer's home domain before trading it"})
+		}
+
+		if amount, _ := strconv.ParseFloat(b.Balance, 64); amount == 0 {
+			findings = append(findings, auditFinding{priorityLow, w.Name,
+				fmt.Sprintf("trustline to %s is unused", b.Asset.Code),
+				fmt.Sprintf("remove it (change trust with a limit of 0) to free %.1f XLM of reserve", explain.BaseReserve)})
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,125 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/celrenheit/alfred/anchor"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// anchorHTTP is the client used to talk to anchors
+var anchorHTTP = &http.Client{Timeout: 30 * time.Second}
+
+// authCmd represents the auth command
+var authCmd = &cobra.Command{
+	Use:   "auth <domain> [wallet]",
+	Short: "Authenticate a wallet with an anchor",
+	Long: `Authenticate a wallet with an anchor (SEP-10).
+
+The anchor sends a challenge transaction which is signed by the wallet to
+prove that it controls the account, the challenge is never submitted.
+The token returned by the anchor is stored in the database and reused by
+the deposits and withdrawals until it expires.`,
+	Example: `alfred auth anchor.com master
+alfred auth anchor.com master --refresh`,
+	Args:    cobra.RangeArgs(1, 2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		var from string
+		if len(args) > 1 {
+			from = args[1]
+		}
+		kp, err := getOrSelectWallet(m, from)
+		if err != nil {
+			fatal(err)
+		}
+
+		a, err := anchor.Discover(anchorHTTP, args[0])
+		if err != nil {
+			fatal(err)
+		}
+
+		refresh, _ := cmd.Flags().GetBool("refresh")
+		if _, err := anchorToken(m, a, kp, refresh); err != nil {
+			fatal(err)
+		}
+
+		for _, t := range m.Stellar.Tokens {
+			if t.Domain == a.Domain && t.Account == kp.Address() {
+				fmt.Printf("%s is authenticated with %s until %s\n", accountName(m, kp.Address()), a.Domain, t.Expires.Local().Format(time.RFC822))
+			}
+		}
+	},
+}
+
+// anchorToken returns a token authenticating kp with the anchor, the token
+// stored in the database is used while it is valid unless refresh is set.
+func anchorToken(m *wallet.Alfred, a *anchor.Anchor, kp walletKey, refresh bool) (string, error) {
+	if jwt, ok := m.AuthToken(a.Domain, kp.Address()); ok && !refresh {
+		return jwt, nil
+	}
+
+	if protected, ok := kp.(protectedKey); ok {
+		full, err := unwrap(protected.w)
+		if err != nil {
+			return "", err
+		}
+		kp = fullKey{full}
+	}
+
+	full, ok := kp.(fullKey)
+	if !ok {
+		return "", i18n.Errorf("the challenge of %s should be signed right away, which a cold wallet can not do", a.Domain)
+	}
+
+	token, err := a.Authenticate(full.Full, currentNetwork().Passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	t := wallet.AuthToken{
+		Domain:  a.Domain,
+		Account: kp.Address(),
+		JWT:     token.JWT,
+		Expires: token.Expires,
+	}
+	m.SetAuthToken(t)
+	if _, err := updateDB(func(m *wallet.Alfred) error {
+		m.SetAuthToken(t)
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	return token.JWT, nil
+}
+
+func init() {
+	RootCmd.AddCommand(authCmd)
+
+	authCmd.Flags().Bool("refresh", false, "Authenticate again even if a token is stored")
+}
@@ -0,0 +1,244 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// holdersPageSize is the number of accounts loaded per request
+const holdersPageSize = 200
+
+// holdersCmd represents the holders command
+var holdersCmd = &cobra.Command{
+	Use:   "holders <asset> [issuer]",
+	Short: "List the accounts trusting an asset",
+	Long: `List the accounts with a trustline to an asset, with their balance, limit
+and whether the issuer authorized them to hold it.
+
+The issuer is a wallet or an address, it defaults to the one of a known
+asset. Issuers of assets requiring an authorization authorize the accounts
+with alfred please authorize.`,
+	Example: `alfred holders MOBI
+alfred holders MYTOKEN issuer
+alfred please authorize bob for MYTOKEN with issuer`,
+	Args:    cobra.RangeArgs(1, 2),
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		var asset *assets.Asset
+		if len(args) == 2 {
+			kp := getAddress(m, args[1])
+			if kp == nil {
+				fatalf("issuer '%s' not found", args[1])
+			}
+			asset = &assets.Asset{BuilderAsset: build.CreditAsset(args[0], kp.Address())}
+		} else if asset, err = selectAsset(args[0]); err != nil {
+			fatal(err)
+		}
+		if asset.BuilderAsset.Native {
+			fatal("XLM is the native asset, every account holds it")
+		}
+
+		holders, err := loadHolders(getClient(currentNetwork()), asset.BuilderAsset)
+		if err != nil {
+			fatal(err)
+		}
+		if len(holders) == 0 {
+			fmt.Println("No account trusts", asset.BuilderAsset.Code)
+			return
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Account", "Name", "Balance", "Limit", "Authorized"))
+		for _, h := range holders {
+			table.Append([]string{h.Account, accountName(m, h.Account), h.Balance, h.Limit, yesNo(h.Authorized)})
+		}
+		table.Render()
+		fmt.Printf("%d account(s) trust %s\n", len(holders), asset.BuilderAsset.Code)
+	},
+}
+
+// holder is the trustline of an account to an asset
+type holder struct {
+	Account    string
+	Balance    string
+	Limit      string
+	Authorized bool
+}
+
+// loadHolders loads the trustlines to asset, the balances of horizon.Account
+// lack whether they are authorized
+func loadHolders(client *horizon.Client, asset build.Asset) ([]holder, error) {
+	var holders []holder
+	cursor := ""
+	for {
+		query := url.Values{}
+		query.Set("asset", asset.Code+":"+asset.Issuer)
+		query.Set("limit", strconv.Itoa(holdersPageSize))
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+
+		endpoint := fmt.Sprintf("%s/accounts?%s", strings.TrimRight(client.URL, "/"), query.Encode())
+		resp, err := client.HTTP.Get(endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			herr := &horizon.Error{Response: resp}
+			err := json.NewDecoder(resp.Body).Decode(&herr.Problem)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			return nil, herr
+		}
+
+		var page struct {
+			Embedded struct {
+				Records []struct {
+					ID       string `json:"id"`
+					PT       string `json:"paging_token"`
+					Balances []struct {
+						horizon.Balance
+						IsAuthorized bool `json:"is_authorized"`
+					} `json:"balances"`
+				} `json:"records"`
+			} `json:"_embedded"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		records := page.Embedded.Records
+		for _, r := range records {
+			for _, b := range r.Balances {
+				if b.Code == asset.Code && b.Issuer == asset.Issuer {
+					holders = append(holders, holder{Account: r.ID, Balance: b.Balance.Balance, Limit: b.Limit, Authorized: b.IsAuthorized})
+				}
+			}
+		}
+		if len(records) < holdersPageSize {
+			return holders, nil
+		}
+		cursor = records[len(records)-1].PT
+	}
+}
+
+// authorizeRequest authorizes an account to hold an asset of the issuer, or
+// revokes the authorization, with an allow trust operation
+func authorizeRequest(m *wallet.Alfred, client *horizon.Client, req *parser.AuthorizeRequest) error {
+	issuer, err := getOrSelectWallet(m, req.Issuer)
+	if err != nil {
+		return err
+	}
+
+	trustor, _, err := resolveDestination(m, req.Trustor)
+	if err != nil {
+		return err
+	}
+
+	issuerAcc, exists, err := getAccount(client, issuer.Address())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return i18n.Errorf("account %s does not exist", issuer.Address())
+	}
+
+	name := accountName(m, issuer.Address())
+	switch {
+	case req.Revoke && !issuerAcc.Flags.AuthRevocable:
+		return i18n.Errorf("the authorizations of %s can not be revoked, enable it with alfred account set %s --auth-revocable", name, name)
+	case !req.Revoke && !issuerAcc.Flags.AuthRequired:
+		return i18n.Errorf("the assets of %s need no authorization, require it with alfred account set %s --auth-required", name, name)
+	}
+
+	asset := assets.Asset{BuilderAsset: build.CreditAsset(req.Asset, issuer.Address())}
+	trustorAcc, exists, err := getAccount(client, trustor)
+	if err != nil {
+		return err
+	}
+	if !exists || !hasTrustline(trustorAcc, asset) {
+		return i18n.Errorf("%s does not trust %s issued by %s", accountName(m, trustor), req.Asset, name)
+	}
+
+	action := "Authorize"
+	if req.Revoke {
+		action = "Deauthorize"
+	}
+	summary := map[string]string{
+		"Issuer":  name,
+		"Asset":   req.Asset,
+		"Account": trustor,
+		"Action":  action,
+	}
+
+	seed, err := issuer.Seed()
+	if err != nil {
+		return err
+	}
+
+	opts := []build.TransactionMutator{
+		build.SourceAccount{seed},
+		build.AutoSequence{SequenceProvider: client},
+		build.AllowTrust(
+			build.Trustor{Address: trustor},
+			build.AllowTrustAsset{Code: req.Asset},
+			build.Authorize{Value: !req.Revoke},
+		),
+	}
+
+	opts = append(opts, currentNetwork().mutator())
+
+	return submitTx(txRequest{
+		client:   client,
+		db:       m,
+		seeds:    []string{seed},
+		opts:     opts,
+		summary:  summary,
+		validFor: viper.GetDuration("valid-for"),
+		retries:  viper.GetInt("retries"),
+		presign:  viper.GetBool("presign"),
+		yes:      viper.GetBool("yes"),
+	})
+}
+
+func init() {
+	RootCmd.AddCommand(holdersCmd)
+}
@@ -0,0 +1,352 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Export and import encrypted backups",
+	Long: `Export and import encrypted backups of the wallets, contacts and settings.
+
+A backup is encrypted with its own password, and its integrity is checked
+before importing it. The path and the secret of the database are not part of
+the settings.`,
+	Example: `alfred backup export backup.alfred
+alfred backup import backup.alfred --on-conflict rename`,
+}
+
+// backupExportCmd represents the backup export command
+var backupExportCmd = &cobra.Command{
+	Use:     "export",
+	Short:   "Write an encrypted backup of the wallets, contacts and settings",
+	Example: "alfred backup export backup.alfred",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		settings, err := readSettings()
+		if err != nil {
+			fatal(err)
+		}
+
+		b, err := m.Backup(settings)
+		if err != nil {
+			fatal(err)
+		}
+
+		password, err := backupPassword(cmd, true)
+		if err != nil {
+			fatal(err)
+		}
+
+		f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+
+		if err := wallet.EncodeBackup(f, b, []byte(password)); err != nil {
+			os.Remove(args[0])
+			fatal(err)
+		}
+
+		fmt.Printf("Backup of %d wallet(s), %d contact(s) and %d setting(s) written to %s\n",
+			len(b.Wallets), len(b.Contacts), len(b.Settings), args[0])
+	},
+}
+
+// backupImportCmd represents the backup import command
+var backupImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import an encrypted backup",
+	Long: `Import the wallets, contacts and settings of an encrypted backup.
+
+An entry of the backup conflicts with the database when it has the same name
+but a different content. A conflict is either skipped, imported under another
+name or overwrites the entry of the database. Without --on-conflict, it is
+prompted for each conflict.`,
+	Example: `alfred backup import backup.alfred
+alfred backup import backup.alfred --on-conflict skip`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		resolve := promptConflict
+		if onConflict, _ := cmd.Flags().GetString("on-conflict"); onConflict != "" {
+			res, err := wallet.ParseResolution(onConflict)
+			if err != nil {
+				fatal(err)
+			}
+			resolve = func(wallet.Conflict) (wallet.Resolution, error) { return res, nil }
+		}
+
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+
+		password, err := backupPassword(cmd, false)
+		if err != nil {
+			fatal(err)
+		}
+
+		b, err := wallet.DecodeBackup(f, []byte(password))
+		if err != nil {
+			fatal(err)
+		}
+
+		// the conflicts are resolved before holding the database, and the
+		// backup is restored again on its latest content with the same
+		// resolutions
+		ask, replay := recordResolutions(resolve)
+		if _, err := m.Restore(b, ask); err != nil {
+			fatal(err)
+		}
+		var restored []wallet.Restored
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			restored, err = m.Restore(b, replay)
+			return err
+		}); err != nil {
+			fatal(err)
+		}
+
+		settings, err := restoreSettings(b.Settings, resolve)
+		if err != nil {
+			fmt.Println("Warning: settings not imported:", err)
+		}
+		restored = append(restored, settings...)
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Kind", "Name", "Action"))
+		for _, r := range restored {
+			table.Append([]string{r.Kind, r.Name, r.Action})
+		}
+		table.Render()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupExportCmd)
+	backupCmd.AddCommand(backupImportCmd)
+
+	backupCmd.PersistentFlags().String("password", "", "password of the backup, prompted when not given")
+	backupImportCmd.Flags().String("on-conflict", "", "resolution of the conflicts: skip, rename or overwrite")
+}
+
+// backupPassword returns the password given with --password or prompts for it,
+// twice when confirm is true
+func backupPassword(cmd *cobra.Command, confirm bool) (string, error) {
+	if password, _ := cmd.Flags().GetString("password"); password != "" {
+		return password, nil
+	}
+
+	if err := checkInteractive("password", "the backup is encrypted", "--password"); err != nil {
+		return "", err
+	}
+
+	fmt.Println("Backup password")
+	password, err := promptPassword()
+	if err != nil || !confirm {
+		return password, err
+	}
+
+	fmt.Println("Confirm the backup password")
+	again, err := promptPassword()
+	if err != nil {
+		return "", err
+	}
+	if again != password {
+		return "", errors.New("the passwords do not match")
+	}
+
+	return password, nil
+}
+
+// recordResolutions returns a resolver asking resolve and keeping its
+// answers, and one answering the same for the conflicts already resolved
+func recordResolutions(resolve wallet.Resolver) (ask, replay wallet.Resolver) {
+	answers := make(map[wallet.Conflict]wallet.Resolution)
+	ask = func(c wallet.Conflict) (wallet.Resolution, error) {
+		r, err := resolve(c)
+		if err == nil {
+			answers[c] = r
+		}
+		return r, err
+	}
+	replay = func(c wallet.Conflict) (wallet.Resolution, error) {
+		r, ok := answers[c]
+		if !ok {
+			return r, errors.New("the database was changed meanwhile, run the command again")
+		}
+		return r, nil
+	}
+	return ask, replay
+}
+
+// promptConflict asks how to resolve c
+func promptConflict(c wallet.Conflict) (wallet.Resolution, error) {
+	fmt.Printf("The %s '%s' differs from the backup\n  database: %s\n  backup:   %s\n", c.Kind, c.Name, c.Existing, c.Incoming)
+
+	if err := checkInteractive("conflict", fmt.Sprintf("the %s '%s' differs from the backup", c.Kind, c.Name), "--on-conflict skip, rename or overwrite"); err != nil {
+		return wallet.Skip, err
+	}
+
+	items := []wallet.Resolution{wallet.Skip, wallet.Rename, wallet.Overwrite}
+	idx, _, err := (&promptui.Select{
+		Label: i18n.T("What should be done?"),
+		Items: items,
+	}).Run()
+	if err != nil {
+		return wallet.Skip, err
+	}
+
+	return items[idx], nil
+}
+
+// readSettings returns the settings of the config file, without the path and
+// the secret of the database
+func readSettings() (map[string]interface{}, error) {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return nil, nil
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	settings := v.AllSettings()
+	delete(settings, "db")
+	delete(settings, "secret")
+	return settings, nil
+}
+
+// restoreSettings writes settings into the config file, resolving the
+// settings which differ with resolve. Renaming a setting is skipping it.
+func restoreSettings(settings map[string]interface{}, resolve wallet.Resolver) ([]wallet.Restored, error) {
+	if len(settings) == 0 {
+		return nil, nil
+	}
+
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".alfred.yaml")
+	}
+
+	if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+		return nil, i18n.Errorf("only a yaml config file can be changed, %s is not", path)
+	}
+
+	current := map[string]interface{}{}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(raw, &current); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var restored []wallet.Restored
+	changed := false
+	for _, key := range keys {
+		r := wallet.Restored{Kind: "setting", Name: key}
+		value := settings[key]
+
+		existing, ok := current[key]
+		switch {
+		case key == "db" || key == "secret":
+			continue
+		case !ok:
+			r.Action = "added"
+		case reflect.DeepEqual(existing, value):
+			r.Action = "unchanged"
+		default:
+			res, err := resolve(wallet.Conflict{
+				Kind:     r.Kind,
+				Name:     key,
+				Existing: fmt.Sprint(existing),
+				Incoming: fmt.Sprint(value),
+			})
+			if err != nil {
+				return restored, err
+			}
+			r.Action = "skipped"
+			if res == wallet.Overwrite {
+				r.Action = "overwritten"
+			}
+		}
+
+		if r.Action == "added" || r.Action == "overwritten" {
+			current[key] = value
+			changed = true
+		}
+		restored = append(restored, r)
+	}
+
+	if !changed {
+		return restored, nil
+	}
+
+	out, err := yaml.Marshal(current)
+	if err != nil {
+		return restored, err
+	}
+
+	return restored, ioutil.WriteFile(path, out, 0600)
+}
@@ -16,20 +16,34 @@ package cmd
 
 import (
 	"os"
-	"sync"
-
-	"github.com/olekukonko/tablewriter"
+	"unicode/utf8"
 
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/i18n"
 	"github.com/celrenheit/alfred/wallet"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
 )
 
 // balancesCmd represents the balances command
 var balancesCmd = &cobra.Command{
-	Use:     "balances",
-	Short:   "Display balances",
-	Long:    `Display balances`,
+	Use:   "balances",
+	Short: "Display balances",
+	Long: `Display the balances of every wallet.
+
+The accounts are loaded --max-requests at a time, and the rows of each wallet
+are displayed as soon as it and the wallets above it are loaded.
+
+With --contracts, the address of the Stellar Asset Contract of each asset is
+shown too: Soroban moves the balance of a classic asset through it, so the
+holdings of these tokens are the balances listed.
+
+With --tag, only the wallets with the tag are shown.`,
+	Example: `alfred balances
+alfred balances --contracts
+alfred balances --tag cold`,
 	PreRunE: middlewares(checkDB),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		path := viper.GetString("db")
@@ -39,46 +53,104 @@ var balancesCmd = &cobra.Command{
 			return err
 		}
 
-		table := tablewriter.NewWriter(os.Stdout)
-		header := []string{"Wallet", "Currency", "Balance"}
+		columns := header("Wallet", "Currency", "Balance", "Limit")
+		widths := []int{0, 8, 18, 18}
+		contracts, _ := cmd.Flags().GetBool("contracts")
+		if contracts {
+			columns, widths = append(columns, i18n.T("Token contract")), append(widths, 56)
+		}
+		wallets := taggedWallets(cmd, m)
+		for _, w := range wallets {
+			if n := utf8.RuneCountInString(w.String()); n > widths[0] {
+				widths[0] = n
+			}
+		}
 
-		var rows [][]string
-		for _, w := range m.Stellar.Wallets {
-			balances, err := w.Balances(viper.GetBool("testnet"))
-			if err != nil {
-				row := []string{w.Name, "error", err.Error()}
-				rows = append(rows, row)
-				continue
+		client := getClient(currentNetwork())
+		table := newStreamTable(os.Stdout, columns, widths)
+		inOrder(len(wallets), func(i int) interface{} {
+			acc, _, err := getAccount(client, wallets[i].Keypair.Address())
+			return loadedAccount{acc, err}
+		}, func(i int, v interface{}) {
+			for _, row := range balanceRows(wallets[i], v.(loadedAccount), contracts) {
+				table.Append(row)
 			}
+		})
 
-			var once sync.Once
-			for _, b := range balances {
-				code := b.Asset.Code
-				if b.Asset.Type == "native" {
-					code = "XLM"
-				}
+		return nil
+	},
+}
 
-				name := ""
-				once.Do(func() {
-					name = w.String()
-				})
+// loadedAccount is an account loaded from horizon, or why it could not be
+type loadedAccount struct {
+	acc horizon.Account
+	err error
+}
 
-				row := []string{name, code, b.Balance}
-				rows = append(rows, row)
-			}
+// balanceRows returns the rows of the balances of w, the name of the wallet
+// being on the first one only, with the token contract of each asset if
+// contracts is set
+func balanceRows(w *wallet.Wallet, loaded loadedAccount, contracts bool) [][]string {
+	if loaded.err != nil {
+		row := []string{w.Name, "error", describeHorizonError(loaded.err), ""}
+		if contracts {
+			row = append(row, "")
 		}
+		return [][]string{row}
+	}
 
-		table.SetRowLine(true)
-		table.SetHeader(header)
-		table.AppendBulk(rows)
-		table.Render()
+	balances := loaded.acc.Balances
+	if len(balances) == 0 {
+		balances = []horizon.Balance{{Balance: "0", Asset: horizon.Asset{Type: "native"}}}
+	}
 
-		return nil
-	},
+	var rows [][]string
+	for i, b := range balances {
+		code := b.Asset.Code
+		if b.Asset.Type == "native" {
+			code = "XLM"
+		}
+
+		name := ""
+		if i == 0 {
+			name = w.String()
+		}
+
+		limit := ""
+		if b.Limit != "" {
+			limit = formatAmount(formatLimit(b.Limit), code)
+		}
+
+		row := []string{name, code, formatAmount(b.Balance, code), limit}
+		if contracts {
+			row = append(row, tokenContract(b.Asset))
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// tokenContract returns the address of the Stellar Asset Contract of a on
+// the current network
+func tokenContract(a horizon.Asset) string {
+	asset := assets.Asset{BuilderAsset: build.NativeAsset()}
+	if a.Type != "native" {
+		asset.BuilderAsset = build.CreditAsset(a.Code, a.Issuer)
+	}
+
+	id, err := asset.ContractID(currentNetwork().Passphrase)
+	if err != nil {
+		return ""
+	}
+	return id
 }
 
 func init() {
 	RootCmd.AddCommand(balancesCmd)
 
+	balancesCmd.Flags().Bool("contracts", false, "show the address of the Stellar Asset Contract of each asset")
+	addTagFlag(balancesCmd)
+
 	viper.BindPFlags(balancesCmd.Flags())
 }
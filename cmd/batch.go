@@ -0,0 +1,120 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// batchResult is the line printed for each statement read by please -
+type batchResult struct {
+	Line      int      `json:"line"`
+	Statement string   `json:"statement"`
+	OK        bool     `json:"ok"`
+	Hashes    []string `json:"hashes,omitempty"`
+	// AlreadySubmitted is set when the hashes were submitted by a previous
+	// run with the same idempotency key
+	AlreadySubmitted bool   `json:"already_submitted,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// readsStdin reports whether the statements of please are read from stdin
+func readsStdin(args []string) bool {
+	return len(args) == 1 && args[0] == "-"
+}
+
+// runBatch executes the statements read from r one after the other, blank
+// lines and lines starting with # excepted. The result of each statement is
+// printed to out as a JSON line, the messages of the statements go to stderr.
+// A failed statement does not stop the following ones, runBatch reports
+// whether they all succeeded. The idempotency key of each statement is the
+// one of --idempotency-key followed by its line number, as in rent:3.
+func runBatch(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, parse func(string) (parser.Statement, error), r io.Reader, out io.Writer) (bool, error) {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
+	key := viper.GetString("idempotency-key")
+	defer viper.Set("idempotency-key", key)
+
+	enc := json.NewEncoder(out)
+	ok := true
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if key != "" {
+			viper.Set("idempotency-key", fmt.Sprintf("%s:%d", key, n))
+		}
+		result := runBatchStatement(m, client, cmd, parse, line)
+		result.Line = n
+		ok = ok && result.OK
+		if err := enc.Encode(result); err != nil {
+			return false, err
+		}
+	}
+
+	return ok, scanner.Err()
+}
+
+func runBatchStatement(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, parse func(string) (parser.Statement, error), line string) batchResult {
+	result := batchResult{Statement: line}
+
+	statement, err := parse(line)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	logStatement(statement)
+
+	if entries := previousSubmissions(m); len(entries) > 0 {
+		result.OK, result.AlreadySubmitted = true, true
+		for _, entry := range entries {
+			result.Hashes = append(result.Hashes, entry.Hash)
+		}
+		return result
+	}
+	if p := pendingSubmission(m); p != nil {
+		result.Error = fmt.Sprintf("transaction %d with the idempotency key %s is pending, resume it with: alfred pending retry %d", p.ID, p.IdempotencyKey, p.ID)
+		return result
+	}
+
+	logged := len(m.Stellar.Log)
+	if err := runStatement(m, client, cmd, statement); err != nil {
+		result.Error = describeHorizonError(err)
+	} else {
+		result.OK = true
+	}
+
+	// a statement may have submitted transactions before failing
+	for _, entry := range m.Stellar.Log[logged:] {
+		result.Hashes = append(result.Hashes, entry.Hash)
+	}
+	return result
+}
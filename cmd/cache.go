@@ -0,0 +1,215 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// horizonCache keeps the accounts and order books loaded from horizon for
+// --cache-ttl, concurrent loads of the same resource share a single request.
+// With --cache-dir, they are also stored on disk for the following commands.
+type horizonCache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+}
+
+// cacheKey identifies a resource, such as an account, on the network of a client
+type cacheKey struct {
+	client   *horizon.Client
+	kind, id string
+}
+
+type cacheEntry struct {
+	done   chan struct{}
+	loaded time.Time
+	value  interface{}
+	exists bool
+	err    error
+}
+
+// expired reports whether e was loaded more than ttl ago, a load in progress
+// is shared whatever the ttl
+func (e *cacheEntry) expired(ttl time.Duration) bool {
+	select {
+	case <-e.done:
+		return time.Since(e.loaded) > ttl
+	default:
+		return false
+	}
+}
+
+// diskEntry is a resource stored in --cache-dir
+type diskEntry struct {
+	Exists bool            `json:"exists"`
+	Value  json.RawMessage `json:"value"`
+}
+
+var cached = &horizonCache{entries: make(map[cacheKey]*cacheEntry)}
+
+// get returns the resource of kind and id, and whether it exists. value is a
+// pointer to a zero value of the resource, filled by load when it is not
+// cached. The pointer holding the resource is returned.
+func (c *horizonCache) get(client *horizon.Client, kind, id string, value interface{}, load func(value interface{}) (bool, error)) (interface{}, bool, error) {
+	key := cacheKey{client, kind, id}
+	ttl := viper.GetDuration("cache-ttl")
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && e.expired(ttl) {
+		ok = false
+	}
+	if !ok {
+		e = &cacheEntry{done: make(chan struct{}), loaded: time.Now(), value: value}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	if ok {
+		<-e.done
+		return e.value, e.exists, e.err
+	}
+
+	path := cachePath(key)
+	if exists, ok := readCached(path, ttl, e.value); ok {
+		e.exists = exists
+	} else {
+		e.exists, e.err = load(e.value)
+		if e.err == nil {
+			writeCached(path, e.exists, e.value)
+		}
+	}
+
+	if e.err != nil { // do not cache errors
+		c.mu.Lock()
+		if c.entries[key] == e {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	close(e.done)
+
+	return e.value, e.exists, e.err
+}
+
+// reset forgets every resource, they changed after a transaction was submitted
+func (c *horizonCache) reset() {
+	c.mu.Lock()
+	c.entries = make(map[cacheKey]*cacheEntry)
+	c.mu.Unlock()
+
+	if dir := viper.GetString("cache-dir"); dir != "" {
+		paths, _ := filepath.Glob(filepath.Join(dir, "horizon-*.json"))
+		for _, path := range paths {
+			os.Remove(path)
+		}
+	}
+}
+
+// cachePath returns the file storing the resource of key in --cache-dir, or
+// an empty path when resources are not stored on disk
+func cachePath(key cacheKey) string {
+	dir := viper.GetString("cache-dir")
+	if dir == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(key.client.URL + "\n" + key.kind + "\n" + key.id))
+	return filepath.Join(dir, "horizon-"+hex.EncodeToString(sum[:])+".json")
+}
+
+// readCached decodes the resource stored at path into value, unless it is
+// older than ttl
+func readCached(path string, ttl time.Duration, value interface{}) (exists, ok bool) {
+	if path == "" {
+		return false, false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > ttl {
+		return false, false
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, false
+	}
+
+	var entry diskEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return false, false
+	}
+	if entry.Exists {
+		if err := json.Unmarshal(entry.Value, value); err != nil {
+			return false, false
+		}
+	}
+	return entry.Exists, true
+}
+
+// writeCached stores a resource at path, the cache being only an optimisation
+// a failure is ignored
+func writeCached(path string, exists bool, value interface{}) {
+	if path == "" {
+		return
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	b, err = json.Marshal(diskEntry{Exists: exists, Value: b})
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	ioutil.WriteFile(path, b, configPerm)
+}
+
+// getOrderBook returns the order book of selling against buying, it is
+// loaded once per --cache-ttl
+func getOrderBook(client *horizon.Client, selling, buying horizon.Asset) (horizon.OrderBookSummary, error) {
+	id := assetID(selling) + "/" + assetID(buying)
+	book, _, err := cached.get(client, "order_book", id, new(horizon.OrderBookSummary), func(v interface{}) (bool, error) {
+		book, err := client.LoadOrderBook(selling, buying)
+		*v.(*horizon.OrderBookSummary) = book
+		return true, err
+	})
+	if err != nil {
+		return horizon.OrderBookSummary{}, err
+	}
+	return *book.(*horizon.OrderBookSummary), nil
+}
+
+// assetID identifies an asset in the keys of the cache
+func assetID(a horizon.Asset) string {
+	if a.Type == "native" {
+		return "native"
+	}
+	return a.Code + ":" + a.Issuer
+}
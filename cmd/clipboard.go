@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/celrenheit/alfred/clipboard"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// copyValue places value, the transaction hash, address or XDR printed by the
+// command, in the clipboard with --copy. Failing to is only reported, on
+// stderr not to mix with the output.
+func copyValue(what, value string) {
+	if !viper.GetBool("copy") {
+		return
+	}
+	if err := clipboard.Copy(value); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not copy the", what, "to the clipboard:", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "The", what, "is copied to the clipboard")
+}
+
+// envelopeArgs accepts the transaction envelope followed by up to more other
+// arguments, the envelope being left out with --paste
+func envelopeArgs(more int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if paste, _ := cmd.Flags().GetBool("paste"); paste {
+			if len(args) > more {
+				return i18n.Errorf("the transaction is pasted from the clipboard, %d other argument(s) expected at most, received %d", more, len(args))
+			}
+			return nil
+		}
+		if len(args) == 0 {
+			return errors.New("the transaction is expected, as base64 XDR or the path of a file containing it, unless it is pasted with --paste")
+		}
+		return cobra.RangeArgs(1, 1+more)(cmd, args)
+	}
+}
+
+// envelopeArg returns the transaction envelope given as the first argument,
+// or read from the clipboard with --paste, and the other arguments
+func envelopeArg(cmd *cobra.Command, args []string) (string, []string, error) {
+	if paste, _ := cmd.Flags().GetBool("paste"); paste {
+		txeB64, err := clipboard.Paste()
+		if err != nil {
+			return "", nil, i18n.Errorf("could not paste the transaction: %v", err)
+		}
+		return txeB64, args, nil
+	}
+	return args[0], args[1:], nil
+}
+
+// addPasteFlag adds --paste to cmd, whose transaction envelope is read from
+// the clipboard instead of its first argument
+func addPasteFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("paste", false, "read the transaction from the clipboard instead of the first argument")
+}
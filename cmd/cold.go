@@ -0,0 +1,145 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// coldCmd represents the cold command
+var coldCmd = &cobra.Command{
+	Use:   "cold",
+	Short: "List the cold wallets",
+	Long: `List the cold wallets, whose seed is kept out of alfred: on paper, on a
+hardware wallet or on another machine. Only their address is stored, with
+where their seed lives.
+
+The transactions from a cold wallet are built and signed by the other signers
+as usual, then printed instead of submitted. They are signed where the seed
+lives, for instance with alfred sign, then submitted with alfred submit.`,
+	Example: `alfred cold add vault GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6 "paper in the safe"
+alfred cold mark savings "laptop at home"
+alfred cold`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Name", "Address", "Seed lives at"))
+		found := false
+		for _, w := range m.Stellar.Wallets {
+			if w.IsCold() {
+				found = true
+				table.Append([]string{w.Name, w.Keypair.Address(), w.Cold})
+			}
+		}
+		if !found {
+			fmt.Println("No cold wallet, add one with: alfred cold add <name> <address> <where the seed lives>")
+			return
+		}
+		table.Render()
+	},
+}
+
+// coldAddCmd represents the cold add command
+var coldAddCmd = &cobra.Command{
+	Use:     "add <name> <address> <where the seed lives>",
+	Short:   "Add a cold wallet by its address",
+	Example: `alfred cold add vault GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6 "paper in the safe"`,
+	Args:    cobra.ExactArgs(3),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		w, err := wallet.NewCold(args[0], args[1], args[2])
+		if err != nil {
+			fatal(err)
+		}
+
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			if m.WalletByName(args[0]) != nil {
+				return i18n.Errorf("wallet %s already exists", args[0])
+			}
+			return m.AddWallet(w)
+		}); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// coldMarkCmd represents the cold mark command
+var coldMarkCmd = &cobra.Command{
+	Use:   "mark <wallet> <where the seed lives>",
+	Short: "Remove the seed of a wallet from alfred",
+	Long: `Remove the seed of a wallet from alfred, which keeps its address and where
+its seed now lives. Make sure the seed is stored there first, for instance
+with alfred export-paper: alfred can not give it back.`,
+	Example: `alfred export-paper savings
+alfred cold mark savings "paper in the safe"`,
+	Args:    cobra.ExactArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		w := m.WalletByName(args[0])
+		if w == nil {
+			fatalf("wallet '%s' not found%s", args[0], suggestName(args[0], accountNames(m, false)))
+		}
+		if w.IsCold() {
+			fatalf("wallet %s is already cold, its seed lives at: %s", w.Name, w.Cold)
+		}
+
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes && !viper.GetBool("yes") {
+			if err := checkInteractive("confirmation", "removing a seed needs a confirmation", "--yes"); err != nil {
+				fatal(err)
+			}
+			_, err := (&promptui.Prompt{
+				Label:     fmt.Sprintf("Is the seed of %s stored at %s", w.Name, args[1]),
+				IsConfirm: true,
+			}).Run()
+			if err != nil {
+				fatal(err)
+			}
+		}
+
+		if err := updateWallet(w.Name, func(w *wallet.Wallet) error {
+			return w.MarkCold(args[1])
+		}); err != nil {
+			fatal(err)
+		}
+
+		fmt.Println("The seed of", w.Name, "was removed, its transactions will be exported for offline signing")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(coldCmd)
+	coldCmd.AddCommand(coldAddCmd)
+	coldCmd.AddCommand(coldMarkCmd)
+
+	coldMarkCmd.Flags().BoolP("yes", "y", false, "if set, no confirmation prompt will be shown")
+}
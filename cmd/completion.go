@@ -0,0 +1,295 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:       "completion bash|zsh|fish",
+	Short:     "Print the script completing the commands, wallets, contacts and assets of alfred",
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Long: `Print the completion script of a shell. Besides the commands and flags, it completes
+the wallet and contact names and the asset codes of the statements of the please command,
+read from the database without its secret.`,
+	Example: `source <(alfred completion bash)
+alfred completion zsh > "${fpath[1]}/_alfred"
+alfred completion fish > ~/.config/fish/completions/alfred.fish`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		script, ok := completionScripts[args[0]]
+		if !ok {
+			fatalf("unsupported shell %q, should be bash, zsh or fish", args[0])
+		}
+		fmt.Print(script)
+	},
+}
+
+// completeCmd prints the candidates of the last word of a command line, it
+// is called by the completion scripts
+var completeCmd = &cobra.Command{
+	Use:                "__complete [words...]",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, candidate := range complete(args) {
+			fmt.Println(candidate)
+		}
+	},
+}
+
+// statementVerbs are the first words of the statements of please
+var statementVerbs = []string{"authorize", "buy", "deauthorize", "delete", "deposit", "lower", "place", "remove", "sell", "send", "set", "share", "trust", "withdraw"}
+
+// complete returns the candidates for the last of words, the words following
+// alfred on the command line, the last one being the word being completed
+func complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	words = words[:len(words)-1]
+
+	cmd, rest, err := RootCmd.Find(words)
+	if err != nil {
+		cmd, rest = RootCmd, nil
+	}
+
+	prev := ""
+	if len(words) > 0 {
+		prev = words[len(words)-1]
+	}
+
+	var candidates []string
+	switch {
+	case prev == "--wallet":
+		candidates = walletNames(dbPath(words))
+	case strings.HasPrefix(current, "-"):
+		candidates = flagNames(cmd)
+	case cmd == RootCmd:
+		candidates = commandNames(RootCmd)
+	case cmd == pleaseCmd:
+		candidates = completeStatement(words, statementWords(rest))
+	case len(cmd.ValidArgs) > 0:
+		candidates = cmd.ValidArgs
+	default:
+		candidates = commandNames(cmd)
+	}
+
+	return withPrefix(candidates, current)
+}
+
+// completeStatement returns the candidates of the word following the words
+// of a statement of please, depending on the one before
+func completeStatement(words, statement []string) []string {
+	if len(statement) == 0 {
+		return statementVerbs
+	}
+
+	prev := strings.ToLower(statement[len(statement)-1])
+	switch {
+	case prev == "from" || prev == "into" || prev == "on":
+		return walletNames(dbPath(words))
+	case prev == "to" || prev == "with" || prev == "and" || prev == "signer" || prev == "account" || prev == "authorize" || prev == "deauthorize" || strings.HasSuffix(prev, ","):
+		return names(dbPath(words))
+	case prev == "all" || prev == "of" || prev == "trust" && len(statement) == 1 || prev == "using" || prev == "for" || isAmount(prev):
+		return assetCodes()
+	}
+
+	version, err := parser.ParseVersion(viper.GetString("grammar"))
+	if err != nil {
+		return nil
+	}
+	var keywords []string
+	for _, kw := range parser.Keywords(version) {
+		keywords = append(keywords, strings.ToLower(kw))
+	}
+	return keywords
+}
+
+// statementWords returns the words of a statement, without the flags
+func statementWords(args []string) []string {
+	var words []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--":
+		case strings.HasPrefix(args[i], "-"):
+			if flagTakesValue(args[i]) {
+				i++
+			}
+		default:
+			words = append(words, args[i])
+		}
+	}
+	return words
+}
+
+// flagTakesValue reports whether the flag of arg is followed by its value
+func flagTakesValue(arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+
+	name := strings.TrimLeft(arg, "-")
+	flags := []*pflag.FlagSet{pleaseCmd.Flags(), RootCmd.PersistentFlags()}
+	for _, fs := range flags {
+		f := fs.Lookup(name)
+		if f == nil && len(name) == 1 {
+			f = fs.ShorthandLookup(name)
+		}
+		if f != nil {
+			return f.NoOptDefVal == ""
+		}
+	}
+	return false
+}
+
+func isAmount(word string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSuffix(word, "%"), 64)
+	return err == nil
+}
+
+// dbPath returns the database given with --db in words, or the configured one
+func dbPath(words []string) string {
+	for i, w := range words {
+		switch {
+		case (w == "--db" || w == "-d") && i+1 < len(words):
+			return words[i+1]
+		case strings.HasPrefix(w, "--db="):
+			return strings.TrimPrefix(w, "--db=")
+		}
+	}
+	return viper.GetString("db")
+}
+
+// walletNames returns the names of the wallets of the database at path, see
+// openNames
+func walletNames(path string) []string {
+	m, err := openNames(path)
+	if err != nil {
+		return nil
+	}
+	return accountNames(m, false)
+}
+
+// names returns the names of the wallets and contacts of the database at path
+func names(path string) []string {
+	m, err := openNames(path)
+	if err != nil {
+		return nil
+	}
+	return accountNames(m, true)
+}
+
+// openNames opens the database at path to read its names, which are not
+// encrypted, unless it is private: they are then only completed while the
+// agent caches the secret
+func openNames(path string) (*wallet.Alfred, error) {
+	if needsSecret(path) {
+		return wallet.OpenSecretString(path, cachedSecret(path))
+	}
+	return wallet.Open(path, nil)
+}
+
+// assetCodes returns the codes of the known assets
+func assetCodes() []string {
+	var codes []string
+	for code := range assets.CodeToAsset {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func commandNames(cmd *cobra.Command) []string {
+	var names []string
+	for _, c := range cmd.Commands() {
+		if c.IsAvailableCommand() {
+			names = append(names, c.Name())
+		}
+	}
+	return names
+}
+
+func flagNames(cmd *cobra.Command) []string {
+	var names []string
+	add := func(f *pflag.Flag) {
+		if !f.Hidden {
+			names = append(names, "--"+f.Name)
+		}
+	}
+	cmd.NonInheritedFlags().VisitAll(add)
+	cmd.InheritedFlags().VisitAll(add)
+	return names
+}
+
+// withPrefix returns the candidates starting with prefix, ignoring the case
+// as the keywords of the grammar do
+func withPrefix(candidates []string, prefix string) []string {
+	var matching []string
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if !seen[c] && strings.HasPrefix(strings.ToLower(c), strings.ToLower(prefix)) {
+			matching = append(matching, c)
+			seen[c] = true
+		}
+	}
+	return matching
+}
+
+// completionScripts call alfred __complete with the words of the command line
+var completionScripts = map[string]string{
+	"bash": `# bash completion for alfred
+_alfred() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    COMPREPLY=($(compgen -W "$(alfred __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)" -- "$cur"))
+}
+complete -o default -F _alfred alfred
+`,
+	"zsh": `#compdef alfred
+# zsh completion for alfred
+_alfred() {
+    local -a candidates
+    candidates=("${(@f)$(alfred __complete "${(@)words[2,$CURRENT]}" 2>/dev/null)}")
+    compadd -a candidates
+}
+compdef _alfred alfred
+`,
+	"fish": `# fish completion for alfred
+function __alfred_complete
+    set -l words (commandline -opc)
+    alfred __complete $words[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c alfred -f -a '(__alfred_complete)'
+`,
+}
+
+func init() {
+	RootCmd.AddCommand(completionCmd)
+	RootCmd.AddCommand(completeCmd)
+}
@@ -0,0 +1,133 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/anchor"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// contactsCmd represents the contacts command
+var contactsCmd = &cobra.Command{
+	Use:   "contacts",
+	Short: "Manage the contacts",
+}
+
+// contactsVerifyCmd represents the contacts verify command
+var contactsVerifyCmd = &cobra.Command{
+	Use:   "verify <name>",
+	Short: "Check the address of a contact against its domain",
+	Long: `Check that the owner of the domain of a contact publishes its address, either
+in the ACCOUNTS of its stellar.toml or in a TXT record of _stellar.<domain>.
+
+The domain defaults to the home domain of the account. A verified contact is
+marked as such when choosing a destination and in the confirmation, so that
+an address swapped in the database does not go unnoticed. The mark is removed
+when the address is no longer published.`,
+	Example: `alfred contacts verify bob
+alfred contacts verify bob --domain example.com`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		name, contact, ok := m.Contact(args[0])
+		if !ok {
+			fatalf("contact '%s' not found%s\n", args[0], suggestName(args[0], accountNames(m, true)))
+		}
+
+		domain, _ := cmd.Flags().GetString("domain")
+		if domain == "" {
+			acc, exists, err := getAccount(getClient(currentNetwork()), contact.Address)
+			if err != nil {
+				fatal(err)
+			}
+			if !exists || acc.HomeDomain == "" {
+				fatal(i18n.Errorf("%s has no home domain, give the domain of %s with --domain", contact.Address, name))
+			}
+			domain = acc.HomeDomain
+		}
+
+		v, verr := verifyAddress(contact.Address, domain)
+		if verr != nil && contact.Verified == nil {
+			fatal(verr)
+		}
+
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			return m.VerifyContact(name, v)
+		}); err != nil {
+			fatal(err)
+		}
+
+		if verr != nil {
+			fatal(i18n.Errorf("%v, %s is no longer marked as verified", verr, name))
+		}
+		fmt.Printf("%s (%s) verified with %s\n", name, contact.Address, v)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(contactsCmd)
+	contactsCmd.AddCommand(contactsVerifyCmd)
+
+	contactsVerifyCmd.Flags().String("domain", "", "domain publishing the address, the home domain of the account by default")
+}
+
+// verifyAddress looks for address in the stellar.toml of domain, then in the
+// TXT records of _stellar.<domain>
+func verifyAddress(address, domain string) (*wallet.Verification, error) {
+	v := &wallet.Verification{Domain: domain, Time: time.Now().UTC()}
+
+	a, tomlErr := anchor.Discover(anchorHTTP, domain)
+	if tomlErr == nil && a.Controls(address) {
+		v.Method = wallet.VerifiedByTOML
+		return v, nil
+	}
+
+	records, dnsErr := net.LookupTXT("_stellar." + domain)
+	for _, record := range records {
+		if strings.TrimSpace(record) == address {
+			v.Method = wallet.VerifiedByDNS
+			return v, nil
+		}
+	}
+
+	msg := fmt.Sprintf("%s is neither in the ACCOUNTS of the stellar.toml of %s nor in a TXT record of _stellar.%s", address, domain, domain)
+	if tomlErr != nil && dnsErr != nil {
+		msg += fmt.Sprintf(" (%v, %v)", tomlErr, dnsErr)
+	}
+	return nil, errors.New(msg)
+}
+
+// contactLabel is the name of a contact, marked when it is verified
+func contactLabel(name string, c wallet.Contact) string {
+	if c.Verified == nil {
+		return name
+	}
+	return name + " ✓ verified"
+}
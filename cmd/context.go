@@ -0,0 +1,141 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/failover"
+	"github.com/celrenheit/alfred/ratelimit"
+)
+
+// interruptGrace is the time a command has to stop after Ctrl-C before
+// alfred exits anyway
+const interruptGrace = 2 * time.Second
+
+// errInterrupted is returned by the horizon calls canceled with Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// rootContext is canceled on Ctrl-C, every horizon call is made with it
+var rootContext, cancelRoot = context.WithCancel(context.Background())
+
+// handleInterrupt cancels rootContext on Ctrl-C, so that a command waiting
+// for horizon stops instead of hanging. A command still running after
+// interruptGrace, or a second Ctrl-C, makes alfred exit.
+func handleInterrupt() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancelRoot()
+
+		select {
+		case <-sig:
+		case <-time.After(interruptGrace):
+		}
+		fmt.Println(errInterrupted)
+		os.Exit(130)
+	}()
+}
+
+// contextHTTP is the http client of horizon clients. Its requests are sent
+// to the servers of --horizon under their rate limit, canceled with
+// rootContext and, except for streams, after --timeout.
+type contextHTTP struct {
+	servers *failover.Client
+	limiter *ratelimit.Client
+}
+
+func (c contextHTTP) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.limiter.Do(req.WithContext(rootContext))
+	if err != nil {
+		return nil, c.requestError(err)
+	}
+
+	resp.Body = errorBody{ReadCloser: resp.Body, http: c}
+	return resp, nil
+}
+
+func (c contextHTTP) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c contextHTTP) PostForm(url string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(req)
+}
+
+// requestError returns errInterrupted or a timeoutError when a request failed
+// because it was canceled or took longer than --timeout
+func (c contextHTTP) requestError(err error) error {
+	switch {
+	case rootContext.Err() != nil:
+		return errInterrupted
+	case errors.Is(err, context.DeadlineExceeded):
+		host := "horizon"
+		if uerr := (*url.Error)(nil); errors.As(err, &uerr) {
+			if u, perr := url.Parse(uerr.URL); perr == nil {
+				host = u.Host
+			}
+		}
+		return timeoutError{host: host, timeout: c.servers.Timeout}
+	}
+	return err
+}
+
+// errorBody is the body of a response, read after Do returns
+type errorBody struct {
+	io.ReadCloser
+	http contextHTTP
+}
+
+func (b errorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.http.requestError(err)
+	}
+	return n, err
+}
+
+// timeoutError is returned when horizon does not answer within --timeout.
+// It is a net.Error, so that submissions which timed out are retried.
+type timeoutError struct {
+	host    string
+	timeout time.Duration
+}
+
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("no answer from %s within %v, the timeout can be raised with --timeout", e.host, e.timeout)
+}
+
+func (e timeoutError) Timeout() bool   { return true }
+func (e timeoutError) Temporary() bool { return true }
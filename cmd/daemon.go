@@ -0,0 +1,238 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/decimal"
+	"github.com/celrenheit/alfred/pricing"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// daemonInterval is the delay between two checks of the strategies
+const daemonInterval = time.Minute
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run the strategies of the database and cancel expired offers",
+	Long: `Run the strategies added with alfred strategy when they are due and cancel
+the offers created with EXPIRES IN once expired. The database is checked every
+minute so strategies and offers can be added or removed while the daemon runs.
+
+Nothing is prompted: the transactions are submitted without confirmation. The
+runs missed while the daemon was stopped are not caught up, the strategy runs
+once and is scheduled again from then.`,
+	Example: `alfred daemon
+alfred daemon --once
+alfred daemon --metrics localhost:9200`,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		// check the secret once, rather than on every check
+		if _, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret")); err != nil {
+			fatal(err)
+		}
+
+		interactive = false
+		viper.Set("yes", true)
+		viper.Set("presign", false)
+
+		client := getClient(currentNetwork())
+		startMetrics(cmd, client, allWallets)
+
+		once, _ := cmd.Flags().GetBool("once")
+		for {
+			now := time.Now().UTC()
+			if err := runDueStrategies(client, now); err != nil {
+				fmt.Println("daemon:", err)
+			}
+			if err := cancelExpiredOffers(client, now); err != nil {
+				fmt.Println("daemon:", err)
+			}
+
+			if once {
+				return
+			}
+			time.Sleep(daemonInterval)
+		}
+	},
+}
+
+// runDueStrategies runs the strategies due at now, each run is recorded as soon as it is done
+func runDueStrategies(client *horizon.Client, now time.Time) error {
+	m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range m.DueStrategies(now) {
+		s, err := m.Strategy(id)
+		if err != nil {
+			return err
+		}
+
+		run := runStrategy(m, client, *s)
+		run.Time = now
+
+		line := fmt.Sprintf("strategy %d (%s): %s", s.ID, describeStrategy(*s), run.Status)
+		switch {
+		case run.Hash != "":
+			line += " " + run.Hash
+		case run.Reason != "":
+			line += ", " + run.Reason
+		}
+		fmt.Println(line)
+
+		// the strategy may have been removed while it ran
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			if s, err := m.Strategy(id); err == nil {
+				s.Record(run)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runStrategy(m *wallet.Alfred, client *horizon.Client, s wallet.Strategy) wallet.StrategyRun {
+	switch s.Kind {
+	case wallet.StrategyDCA:
+		return runDCA(m, client, s)
+	}
+
+	return wallet.StrategyRun{Status: wallet.RunFailed, Reason: fmt.Sprintf("unknown kind of strategy '%s'", s.Kind)}
+}
+
+// runDCA creates an offer spending the amount of s at the best price of the
+// order book, raised by the maximum slippage so that it is filled at once
+func runDCA(m *wallet.Alfred, client *horizon.Client, s wallet.Strategy) wallet.StrategyRun {
+	failed := func(format string, args ...interface{}) wallet.StrategyRun {
+		return wallet.StrategyRun{Status: wallet.RunFailed, Reason: fmt.Sprintf(format, args...)}
+	}
+	skipped := func(format string, args ...interface{}) wallet.StrategyRun {
+		return wallet.StrategyRun{Status: wallet.RunSkipped, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	w := m.WalletByName(s.Wallet)
+	if w == nil {
+		return failed("wallet '%s' not found", s.Wallet)
+	}
+	src, err := hotKey(w)
+	if err != nil {
+		return failed("%v", err)
+	}
+
+	selling := assets.GetByCodeIssuer(s.SellingCode, s.SellingIssuer)
+	buying := assets.GetByCodeIssuer(s.BuyingCode, s.BuyingIssuer)
+	if selling == nil || buying == nil {
+		return failed("asset %s or %s is no longer supported", s.SellingCode, s.BuyingCode)
+	}
+
+	amount, err := decimal.Parse(s.Amount)
+	if err != nil {
+		return failed("invalid amount '%s'", s.Amount)
+	}
+
+	// the asks of the asset bought, priced in the asset spent
+	book, err := getOrderBook(client, buying.ToHorizonAsset(), selling.ToHorizonAsset())
+	if err != nil {
+		return failed("%s", describeHorizonError(err))
+	}
+
+	quote, err := pricing.Spend(book.Asks, amount)
+	if err == pricing.ErrThin {
+		return skipped("%v", err)
+	} else if err != nil {
+		return failed("%v", err)
+	}
+
+	max := s.MaxSlippage
+	if max == 0 {
+		max = maxSlippage()
+	}
+	if quote.Slippage.Cmp(percent(max)) > 0 {
+		return skipped("slippage of %s%% above %g%%", quote.Slippage.FloatString(2), max)
+	}
+
+	acc, exists, err := getAccount(client, src.Address())
+	if err != nil {
+		return failed("%s", describeHorizonError(err))
+	}
+	if !exists {
+		return failed("account %s does not exist", src.Address())
+	}
+
+	price, err := decimal.FromRat(pricing.BuyLimit(quote.BestPrice, percent(max)), decimal.Down)
+	if err != nil {
+		return failed("%v", err)
+	}
+	opts := []build.TransactionMutator{
+		build.SourceAccount{src.Seed()},
+		build.AutoSequence{SequenceProvider: client},
+	}
+	if !hasTrustline(acc, *buying) {
+		opts = append(opts, build.Trust(buying.BuilderAsset.Code, buying.BuilderAsset.Issuer))
+	}
+	opts = append(opts, build.CreateOffer(build.Rate{
+		Buying:  buying.BuilderAsset,
+		Selling: selling.BuilderAsset,
+		Price:   build.Price(new(big.Rat).Inv(price.Rat()).RatString()),
+	}, build.Amount(s.Amount)))
+
+	opts = append(opts, currentNetwork().mutator())
+
+	logged := len(m.Stellar.Log)
+	err = submitTx(txRequest{
+		client:   client,
+		db:       m,
+		seeds:    []string{src.Seed()},
+		opts:     opts,
+		validFor: viper.GetDuration("valid-for"),
+		retries:  viper.GetInt("retries"),
+		yes:      true,
+	})
+	if err != nil {
+		return failed("%s", describeHorizonError(err))
+	}
+
+	run := wallet.StrategyRun{
+		Status: wallet.RunDone,
+		Price:  price.String(),
+		Bought: quote.Amount.String(),
+	}
+	if len(m.Stellar.Log) > logged {
+		run.Hash = m.Stellar.Log[len(m.Stellar.Log)-1].Hash
+	}
+
+	return run
+}
+
+func init() {
+	RootCmd.AddCommand(daemonCmd)
+
+	daemonCmd.Flags().Bool("once", false, "run the due strategies and cancel the expired offers once, then exit, such as from cron")
+	addMetricsFlag(daemonCmd)
+}
@@ -0,0 +1,458 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// dashboardPayments is the number of payments the dashboard remembers
+const dashboardPayments = 100
+
+// dashboardCmd represents the dashboard command
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard",
+	Short: "Full-screen view of the balances, payments and offers of the wallets",
+	Long: `Show the balances and open offers of every wallet and their payments as they
+happen, on the whole terminal. The balances and offers are reloaded after every
+payment and every --refresh. With --tag, only the wallets with the tag are
+shown.
+
+The command bar at the bottom takes the statements of the please command, such
+as "send 10 XLM from master to jennifer". What a statement will do is shown
+first, and it is only run once confirmed with y.
+
+  r or refresh   reload the balances and offers
+  q or quit      leave the dashboard, as do Ctrl-C and Ctrl-D`,
+	Example: `alfred dashboard
+alfred dashboard --refresh 1m
+alfred dashboard --tag exchange`,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+		if !terminal.IsTerminal(in) || !terminal.IsTerminal(out) {
+			fatal("the dashboard needs a terminal")
+		}
+
+		parse, err := statementParser()
+		if err != nil {
+			fatal(err)
+		}
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		// the command bar confirms the statements itself
+		interactive = false
+		viper.Set("yes", true)
+		viper.Set("presign", false)
+
+		client := getClient(currentNetwork())
+		d := &dashboard{
+			out:     os.Stdout,
+			fd:      out,
+			client:  client,
+			backend: &serveBackend{client: client},
+			parse:   parse,
+			network: currentNetwork().Name,
+			seen:    make(map[string]bool),
+		}
+		for _, w := range taggedWallets(cmd, m) {
+			d.wallets = append(d.wallets, dashboardWallet{name: w.Name, address: w.Keypair.Address()})
+		}
+		logger.Out = dashboardLog{d}
+
+		state, err := terminal.MakeRaw(in)
+		if err != nil {
+			fatal(err)
+		}
+		// the alternate screen leaves the terminal as it was once left
+		fmt.Fprint(d.out, "\x1b[?1049h")
+		defer func() {
+			fmt.Fprint(d.out, "\x1b[?1049l")
+			terminal.Restore(in, state)
+		}()
+
+		d.message("Loading the balances and offers...")
+		go d.refresh()
+		for _, w := range d.wallets {
+			go d.stream(m, w)
+		}
+		go func() {
+			refresh, _ := cmd.Flags().GetDuration("refresh")
+			if refresh <= 0 {
+				return
+			}
+			for range time.Tick(refresh) {
+				d.refresh()
+			}
+		}()
+
+		d.read(bufio.NewReader(os.Stdin))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(dashboardCmd)
+
+	dashboardCmd.Flags().Duration("refresh", 30*time.Second, "time between two reloads of the balances and offers (0 to only reload them after a payment)")
+	addTagFlag(dashboardCmd)
+}
+
+// dashboardWallet is a wallet shown by the dashboard
+type dashboardWallet struct {
+	name, address string
+}
+
+// dashboardLine is a line of a pane, painted with style when it is drawn
+type dashboardLine struct {
+	text  string
+	style string
+}
+
+// dashboard draws the panes of the dashboard command on the whole terminal
+// and runs the statements of its command bar
+type dashboard struct {
+	out     io.Writer
+	fd      int
+	client  *horizon.Client
+	backend *serveBackend
+	parse   func(string) (parser.Statement, error)
+	network string
+	wallets []dashboardWallet
+
+	mu       sync.Mutex
+	balances []dashboardLine
+	offers   []dashboardLine
+	// payments are the most recent first
+	payments []dashboardLine
+	// seen are the operations already in payments, a payment between two
+	// wallets being streamed twice
+	seen     map[string]bool
+	messages []dashboardLine
+	input    string
+	// pending is the statement waiting for its confirmation
+	pending parser.Statement
+}
+
+// refresh reloads the balances and offers of the wallets
+func (d *dashboard) refresh() {
+	var balances, offers []dashboardLine
+	for _, w := range d.wallets {
+		bs, err := d.backend.Balances(w.name)
+		if err != nil {
+			balances = append(balances, dashboardLine{text: fmt.Sprintf("%-16s %v", w.name, err), style: styleOutgoing})
+			continue
+		}
+		if len(bs) == 0 {
+			balances = append(balances, dashboardLine{text: fmt.Sprintf("%-16s not funded", w.name), style: styleDim})
+		}
+		for _, b := range bs {
+			balances = append(balances, dashboardLine{text: fmt.Sprintf("%-16s %20s %s", w.name, formatAmount(b.Balance, b.Asset), b.Asset)})
+		}
+
+		open, err := loadOffers(d.client, w.address)
+		if err != nil {
+			offers = append(offers, dashboardLine{text: fmt.Sprintf("%-16s %s", w.name, describeHorizonError(err)), style: styleOutgoing})
+			continue
+		}
+		for _, o := range open {
+			offers = append(offers, dashboardLine{text: fmt.Sprintf("%-16s #%d sell %s %s for %s at %s", w.name, o.ID, formatAmount(o.Amount, horizonAssetCode(o.Selling)),
+				horizonAssetCode(o.Selling), horizonAssetCode(o.Buying), formatAmount(o.Price, ""))})
+		}
+	}
+
+	d.mu.Lock()
+	d.balances, d.offers = balances, offers
+	d.mu.Unlock()
+	d.draw()
+}
+
+// stream adds the payments of w to the pane of the payments as they happen
+func (d *dashboard) stream(m *wallet.Alfred, w dashboardWallet) {
+	cursor := horizon.Cursor("now")
+	handler := func(tx horizon.Transaction) {
+		cursor = horizon.Cursor(tx.PagingToken)
+
+		ops, err := operations(tx, func(address string) string { return address })
+		if err != nil {
+			d.message(err.Error())
+			return
+		}
+
+		d.mu.Lock()
+		for _, op := range ops {
+			key := fmt.Sprintf("%s:%d", op.Transaction, op.Index)
+			if op.Amount == "" || op.Destination == "" || d.seen[key] {
+				continue
+			}
+			d.seen[key] = true
+
+			line := dashboardLine{text: fmt.Sprintf("%s  %s -> %s  %s %s", op.Time.Local().Format("Jan 2 15:04:05"), accountLabel(m, op.Source), accountLabel(m, op.Destination), formatAmount(op.Amount, op.Asset), op.Asset)}
+			switch from, to := m.IsWallet(op.Source), m.IsWallet(op.Destination); {
+			case to && !from:
+				line.style = styleIncoming
+			case from && !to:
+				line.style = styleOutgoing
+			}
+			d.payments = append([]dashboardLine{line}, d.payments...)
+		}
+		if len(d.payments) > dashboardPayments {
+			d.payments = d.payments[:dashboardPayments]
+		}
+		d.mu.Unlock()
+
+		d.refresh()
+	}
+
+	// horizon closes the streams from time to time, they are resumed after
+	// the last transaction received
+	for {
+		err := d.client.StreamTransactions(rootContext, w.address, &cursor, handler)
+		if rootContext.Err() != nil {
+			return
+		}
+		if err != nil {
+			d.message(fmt.Sprintf("Stream of %s interrupted: %s", w.name, describeHorizonError(err)))
+		}
+
+		select {
+		case <-rootContext.Done():
+			return
+		case <-time.After(streamRetryDelay):
+		}
+	}
+}
+
+// read edits the command bar with the keys typed, until the dashboard is left
+func (d *dashboard) read(r *bufio.Reader) {
+	d.draw()
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return
+		}
+
+		switch c {
+		case 3, 4: // Ctrl-C, Ctrl-D
+			return
+		case '\r', '\n':
+			d.mu.Lock()
+			line := strings.TrimSpace(d.input)
+			d.input = ""
+			d.mu.Unlock()
+			if !d.execute(line) {
+				return
+			}
+		case 127, '\b':
+			d.mu.Lock()
+			if _, size := utf8.DecodeLastRuneInString(d.input); size > 0 {
+				d.input = d.input[:len(d.input)-size]
+			}
+			d.mu.Unlock()
+		case 27: // escape sequences, such as the arrows, are ignored
+			if next, _ := r.Peek(1); len(next) == 1 && next[0] == '[' {
+				r.ReadByte()
+				r.ReadByte()
+			}
+		default:
+			if c >= ' ' {
+				d.mu.Lock()
+				d.input += string(c)
+				d.mu.Unlock()
+			}
+		}
+		d.draw()
+	}
+}
+
+// execute runs the line entered in the command bar, and reports whether the
+// dashboard goes on
+func (d *dashboard) execute(line string) bool {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+
+	if pending != nil {
+		if line != "y" && line != "yes" {
+			d.message("Cancelled")
+			return true
+		}
+		d.message("Running...")
+		d.run(pending)
+		return true
+	}
+
+	switch line {
+	case "":
+		return true
+	case "q", "quit", "exit":
+		return false
+	case "r", "refresh":
+		d.message("Loading the balances and offers...")
+		go d.refresh()
+		return true
+	}
+
+	statement, err := d.parse(line)
+	if err != nil {
+		d.message(err.Error())
+		return true
+	}
+	logStatement(statement)
+
+	m, err := d.backend.open()
+	if err != nil {
+		d.message(err.Error())
+		return true
+	}
+	var plan bytes.Buffer
+	if err := explainStatement(&plan, m, statement); err != nil {
+		d.message(err.Error())
+		return true
+	}
+
+	d.mu.Lock()
+	d.pending = statement
+	d.mu.Unlock()
+	d.message(strings.TrimSpace(plan.String()) + "\nRun it? (y to confirm, anything else cancels)")
+	return true
+}
+
+// run executes statement, its output is shown instead of being printed over
+// the dashboard
+func (d *dashboard) run(statement parser.Statement) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		d.message(err.Error())
+		return
+	}
+	output := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		output <- b
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	hashes, err := d.backend.Run(statement)
+	os.Stdout = stdout
+	w.Close()
+
+	text := strings.TrimSpace(string(<-output))
+	switch {
+	case err != nil:
+		text = strings.TrimSpace(text + "\n" + err.Error())
+	case len(hashes) == 0 && text == "":
+		text = "Done"
+	}
+	d.message(text)
+	go d.refresh()
+}
+
+// message replaces the lines shown above the command bar with text
+func (d *dashboard) message(text string) {
+	d.mu.Lock()
+	d.messages = nil
+	for _, line := range strings.Split(text, "\n") {
+		d.messages = append(d.messages, dashboardLine{text: strings.TrimRight(line, "\r")})
+	}
+	d.mu.Unlock()
+	d.draw()
+}
+
+// draw redraws the whole screen
+func (d *dashboard) draw() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	width, height, err := terminal.GetSize(d.fd)
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	messages := d.messages
+	if n := height / 4; len(messages) > n {
+		messages = messages[len(messages)-n:]
+	}
+	// the title, the headings of the panes, the blank lines between them and
+	// the command bar
+	rows := height - 8 - len(messages)
+	balances := clip(d.balances, rows/3)
+	offers := clip(d.offers, rows/4)
+	payments := clip(d.payments, rows-len(balances)-len(offers))
+
+	title := fmt.Sprintf("alfred dashboard - %s - %d wallets", d.network, len(d.wallets))
+	lines := []dashboardLine{{text: title}, {text: "q quits, r refreshes, or type a statement such as: send 10 XLM from master to jennifer", style: styleDim}}
+	pane := func(heading string, content []dashboardLine, empty string) {
+		lines = append(lines, dashboardLine{}, dashboardLine{text: heading, style: styleBold})
+		if len(content) == 0 {
+			content = []dashboardLine{{text: empty, style: styleDim}}
+		}
+		lines = append(lines, content...)
+	}
+	pane("Balances", balances, "none yet")
+	pane("Open offers", offers, "none")
+	pane("Recent payments", payments, "waiting for payments...")
+	for len(lines) < height-1-len(messages) {
+		lines = append(lines, dashboardLine{})
+	}
+	lines = append(lines, messages...)
+
+	var screen bytes.Buffer
+	screen.WriteString("\x1b[H\x1b[2J")
+	for _, l := range lines {
+		text := truncate(l.text, width)
+		if l.style != "" && colorAllowed() {
+			text = ansi(l.style, text)
+		}
+		screen.WriteString(text + "\r\n")
+	}
+	screen.WriteString(truncate("> "+d.input, width))
+	d.out.Write(screen.Bytes())
+}
+
+// dashboardLog shows the logs of the horizon clients above the command bar
+type dashboardLog struct {
+	d *dashboard
+}
+
+func (l dashboardLog) Write(p []byte) (int, error) {
+	l.d.message(strings.TrimSpace(string(p)))
+	return len(p), nil
+}
+
+// clip returns the first n lines at most
+func clip(lines []dashboardLine, n int) []dashboardLine {
+	if n < 1 {
+		n = 1
+	}
+	if len(lines) > n {
+		return lines[:n]
+	}
+	return lines
+}
+
+// truncate cuts s to width characters
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
@@ -0,0 +1,254 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// dataCmd represents the data command
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Inspect the data entries of an account",
+	Long: `Inspect the data entries of an account. They are set with
+alfred please set data and removed with alfred please delete data.`,
+	Example: `alfred data list master
+alfred please set data foo = bar
+alfred please delete data foo from master`,
+}
+
+// dataListCmd represents the data list command
+var dataListCmd = &cobra.Command{
+	Use:   "list [wallet or address]",
+	Short: "List the data entries of an account",
+	Long: `List the data entries of an account with their decoded value. Values
+that are not valid UTF-8 text are shown in hexadecimal.
+
+Values larger than 64 bytes, split by alfred please set data into the
+entries key-0, key-1..., are joined back under their key.`,
+	Example: `alfred data list master
+alfred data list GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		address, err := accountArg(m, args)
+		if err != nil {
+			fatal(err)
+		}
+
+		client := getClient(currentNetwork())
+		acc, exists, err := getAccount(client, address)
+		if err != nil {
+			fatal(err)
+		}
+		if !exists {
+			fatalf("account %s does not exist", address)
+		}
+
+		if len(acc.Data) == 0 {
+			fmt.Println("No data entry on", accountName(m, address))
+			return
+		}
+
+		values, chunks, err := joinChunks(acc.Data)
+		if err != nil {
+			fatal(err)
+		}
+
+		var keys []string
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Key", "Type", "Size", "Value"))
+		for _, key := range keys {
+			value := values[key]
+			typ, shown := "text", string(value)
+			if !isText(value) {
+				typ, shown = "binary", hex.EncodeToString(value)
+			}
+			if n := chunks[key]; n > 0 {
+				typ += fmt.Sprintf(" (%d entries)", n)
+			}
+			table.Append([]string{key, typ, strconv.Itoa(len(value)), shown})
+		}
+		table.Render()
+	},
+}
+
+const (
+	// maxDataValue is the size of the largest value of a data entry
+	maxDataValue = 64
+	// maxDataOperations is the number of operations of a transaction
+	maxDataOperations = 100
+)
+
+type dataChunk struct {
+	key   string
+	value []byte
+}
+
+// dataChunks returns the entries storing value under key: key itself if it
+// fits in a single entry, otherwise key-0, key-1... holding 64 bytes each
+func dataChunks(key string, value []byte) ([]dataChunk, error) {
+	if len(value) <= maxDataValue {
+		return []dataChunk{{key, value}}, nil
+	}
+
+	var chunks []dataChunk
+	for i := 0; len(value) > 0; i++ {
+		n := maxDataValue
+		if len(value) < n {
+			n = len(value)
+		}
+
+		name := chunkKey(key, i)
+		if len(name) > maxDataValue {
+			return nil, i18n.Errorf("the key '%s' is too long to split its value into several entries", key)
+		}
+		chunks = append(chunks, dataChunk{name, value[:n]})
+		value = value[n:]
+	}
+	return chunks, nil
+}
+
+func chunkKey(key string, i int) string {
+	return key + "-" + strconv.Itoa(i)
+}
+
+// chunkKeys returns key-0, key-1... as long as they are entries of data,
+// when there are at least two of them
+func chunkKeys(data map[string]string, key string) []string {
+	var keys []string
+	for i := 0; ; i++ {
+		if _, ok := data[chunkKey(key, i)]; !ok {
+			break
+		}
+		keys = append(keys, chunkKey(key, i))
+	}
+
+	if len(keys) < 2 {
+		return nil
+	}
+	return keys
+}
+
+// staleDataKeys returns the entries of acc storing key, whole or split, that
+// are not part of chunks
+func staleDataKeys(acc horizon.Account, key string, chunks []dataChunk) []string {
+	keep := map[string]bool{}
+	for _, c := range chunks {
+		keep[c.key] = true
+	}
+
+	var stale []string
+	if _, ok := acc.Data[key]; ok && !keep[key] {
+		stale = append(stale, key)
+	}
+	for _, k := range chunkKeys(acc.Data, key) {
+		if !keep[k] {
+			stale = append(stale, k)
+		}
+	}
+	return stale
+}
+
+// joinChunks returns the values of data, those split into several entries
+// by dataChunks being joined back under their key
+func joinChunks(data map[string]string) (values map[string][]byte, chunks map[string]int, err error) {
+	values, chunks = map[string][]byte{}, map[string]int{}
+	joined := map[string]bool{}
+
+	for key := range data {
+		i := strings.LastIndex(key, "-")
+		if i < 0 || key[i+1:] != "0" {
+			continue
+		}
+
+		base := key[:i]
+		if _, ok := data[base]; ok {
+			continue
+		}
+		parts := chunkKeys(data, base)
+		if parts == nil {
+			continue
+		}
+
+		for _, part := range parts {
+			v, err := base64.StdEncoding.DecodeString(data[part])
+			if err != nil {
+				return nil, nil, i18n.Errorf("invalid value of '%s': %v", part, err)
+			}
+			values[base] = append(values[base], v...)
+			joined[part] = true
+		}
+		chunks[base] = len(parts)
+	}
+
+	for key, raw := range data {
+		if joined[key] {
+			continue
+		}
+
+		v, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return nil, nil, i18n.Errorf("invalid value of '%s': %v", key, err)
+		}
+		values[key] = v
+	}
+
+	return values, chunks, nil
+}
+
+// isText reports whether data is UTF-8 text without control characters,
+// other than whitespaces
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+
+	for _, r := range string(data) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	RootCmd.AddCommand(dataCmd)
+	dataCmd.AddCommand(dataListCmd)
+}
@@ -0,0 +1,310 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// dbCmd represents the db command
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect the database and set whether it is private",
+}
+
+// dbInfoCmd represents the db info command
+var dbInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the schema version and content of the database",
+	Long: `Show the schema version of the database and what it holds.
+
+A database written by an older alfred is migrated to the current schema the
+first time it is opened, after being copied next to it as <db>.v<version>.bak.
+The migrations are listed with the ones already applied to the database.`,
+	Example: `alfred db info`,
+	Args:    cobra.NoArgs,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		store, err := wallet.OpenStore(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		// the version is read before opening the database, which migrates it
+		version, err := wallet.StoredVersion(store)
+		if err != nil {
+			fatal(err)
+		}
+
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		cold := 0
+		for _, w := range m.Stellar.Wallets {
+			if w.IsCold() {
+				cold++
+			}
+		}
+
+		schema := strconv.Itoa(version)
+		switch {
+		case version < wallet.CurrentVersion:
+			schema += fmt.Sprintf(" (migrated to %d)", wallet.CurrentVersion)
+		case version == wallet.CurrentVersion:
+			schema += " (current)"
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Field", "Value"))
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.Append([]string{"Database", path})
+		if info, err := os.Stat(path); err == nil {
+			table.Append([]string{"Size", fmt.Sprintf("%d bytes", info.Size())})
+		}
+		table.Append([]string{"Schema version", schema})
+		table.Append([]string{"Wallets", fmt.Sprintf("%d (%d cold)", len(m.Stellar.Wallets), cold)})
+		table.Append([]string{"Archived keys", strconv.Itoa(len(m.Stellar.Archived))})
+		table.Append([]string{"Contacts", strconv.Itoa(len(m.Stellar.Contacts) + len(m.Stellar.Synced))})
+		table.Append([]string{"Log entries", strconv.Itoa(len(m.Stellar.Log))})
+		private := "no"
+		if m.IsPrivate() {
+			private = "yes"
+		}
+		table.Append([]string{"Private", private})
+		if backups, _ := filepath.Glob(path + ".v*.bak"); len(backups) > 0 {
+			table.Append([]string{"Backups before migration", strings.Join(backups, ", ")})
+		}
+		table.Render()
+
+		fmt.Println()
+		fmt.Println("Migrations:")
+		table = newTable(os.Stdout)
+		table.SetHeader(header("Version", "Description", "Applied"))
+		for _, mig := range wallet.Migrations {
+			applied := "yes"
+			if mig.Version > version {
+				applied = "now"
+			}
+			table.Append([]string{strconv.Itoa(mig.Version), mig.Description, applied})
+		}
+		table.Render()
+	},
+}
+
+// dbVerifyCmd represents the db verify command
+var dbVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check every entry of the database and repair it",
+	Long: `Check every entry of the database: the seed of each wallet is decrypted and
+must match its address, the addresses must be valid, and two wallets can not
+have the same name nor address. The KYC answers must decrypt too.
+
+Unlike the other commands, the check goes on past an entry which can not be
+read. The problems found can then be repaired, the entries which can not be
+read being removed from the database after a copy of it is kept, or the
+salvageable entries can be exported to a new database. A wallet with the name
+of another one is renamed, a second copy of an address is removed.`,
+	Example: `alfred db verify
+alfred db verify --repair
+alfred db verify --export salvaged.yaml`,
+	Args:    cobra.NoArgs,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		store, err := wallet.OpenStore(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		// the password is not checked against the database, which may not open
+		secret := viper.GetString("secret")
+		if secret == "" {
+			if err := checkInteractive("password", "the database is encrypted", "--secret"); err != nil {
+				fatal(err)
+			}
+			if secret, err = promptPassword(); err != nil {
+				fatal(err)
+			}
+		}
+
+		checked, err := wallet.Check(store, []byte(secret))
+		if err != nil {
+			fatal(err)
+		}
+		if checked.Undecryptable > 0 && !anyHot(checked.Salvaged) {
+			fatal("no seed could be decrypted, the password is probably incorrect")
+		}
+		if len(checked.Problems) == 0 {
+			fmt.Printf("No problem found in %d wallet(s)\n", checked.Wallets)
+			return
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Kind", "Name", "Address", "Issue", "Repair"))
+		table.SetRowLine(true)
+		for _, p := range checked.Problems {
+			table.Append([]string{p.Kind, p.Name, p.Address, p.Issue, p.Repair})
+		}
+		table.Render()
+
+		repair, _ := cmd.Flags().GetBool("repair")
+		export, _ := cmd.Flags().GetString("export")
+		if !repair && export == "" {
+			if err := checkInteractive("repair", fmt.Sprintf("%d problem(s) found", len(checked.Problems)), "--repair or --export <path>"); err != nil {
+				fatal(err)
+			}
+
+			idx, _, err := (&promptui.Select{
+				Label: i18n.T("What should be done?"),
+				Items: []string{"Repair the database, keeping a copy of it", "Export the salvageable entries to a new database", "Nothing"},
+			}).Run()
+			if err != nil {
+				fatal(err)
+			}
+			switch idx {
+			case 0:
+				repair = true
+			case 1:
+				if export, err = (&promptui.Prompt{Label: i18n.T("Path of the new database")}).Run(); err != nil {
+					fatal(err)
+				}
+			default:
+				return
+			}
+		}
+
+		if export != "" {
+			if _, err := os.Stat(export); err == nil {
+				fatalf("%s already exists", export)
+			}
+			if err := wallet.Write(export, checked.Salvaged); err != nil {
+				fatal(err)
+			}
+			fmt.Println("The salvageable entries were exported to", export)
+		}
+
+		if repair {
+			fs, ok := store.(wallet.FileStore)
+			if !ok {
+				fatal("only a database stored in a file can be repaired, export the salvageable entries instead")
+			}
+			// the copy kept is the database replaced, even if written since
+			// it was checked
+			backup := fmt.Sprintf("%s.%s.bak", fs.Path, time.Now().Format("20060102-150405"))
+			if err := wallet.Hold(fs, func(store wallet.Store) error {
+				raw, err := store.Read()
+				if err != nil {
+					return err
+				}
+				if err := ioutil.WriteFile(backup, raw, 0600); err != nil {
+					return err
+				}
+				return wallet.Save(store, checked.Salvaged)
+			}); err != nil {
+				fatal(err)
+			}
+			fmt.Println("The database was repaired, the original is kept as", backup)
+		}
+	},
+}
+
+// dbPrivateCmd represents the db private command
+var dbPrivateCmd = &cobra.Command{
+	Use:   "private",
+	Short: "Encrypt the names and addresses of the wallets too",
+	Long: `Store the names and addresses of the wallets, the contacts and the log only
+encrypted with the secret. The commands which do not sign then need the
+secret too, and --read-only can not be used. The shell completion only
+completes the names while the agent caches the secret.
+
+A database with a decoy is always private.`,
+	Example: `alfred db private`,
+	Args:    cobra.NoArgs,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			m.SetPrivate(true)
+			return nil
+		}); err != nil {
+			fatal(err)
+		}
+		fmt.Println("The database is private, its wallets are only read with its secret")
+	},
+}
+
+// dbPublicCmd represents the db public command
+var dbPublicCmd = &cobra.Command{
+	Use:   "public",
+	Short: "Store the names and addresses of the wallets in clear",
+	Long: `Store the names and addresses of the wallets, the contacts and the log in
+clear, which is the default, so that the commands which do not sign read
+them without the secret. The seeds and the KYC answers stay encrypted.
+
+A database with a decoy stays private, its public content would show the
+main wallets to whoever was given the duress passphrase.`,
+	Example: `alfred db public`,
+	Args:    cobra.NoArgs,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := updateDB(func(m *wallet.Alfred) error {
+			m.SetPrivate(false)
+			return nil
+		})
+		if err != nil {
+			fatal(err)
+		}
+		if m.IsPrivate() {
+			fatal("the database holds another compartment, such as a decoy, it stays private")
+		}
+		fmt.Println("The database is public, its wallets are read without its secret")
+	},
+}
+
+// anyHot reports whether m has a wallet whose seed it holds
+func anyHot(m *wallet.Alfred) bool {
+	for _, w := range append(m.Stellar.Wallets, m.Stellar.Archived...) {
+		if !w.IsCold() {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	RootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbInfoCmd)
+	dbCmd.AddCommand(dbVerifyCmd)
+	dbCmd.AddCommand(dbPrivateCmd)
+	dbCmd.AddCommand(dbPublicCmd)
+
+	dbVerifyCmd.Flags().Bool("repair", false, "repair the database without prompting, a copy of it is kept")
+	dbVerifyCmd.Flags().String("export", "", "export the salvageable entries to a new database at this path")
+}
@@ -0,0 +1,349 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/explain"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// decodeCmd represents the decode command
+var decodeCmd = &cobra.Command{
+	Use:   "decode <xdr>",
+	Short: "Show what a transaction does before signing or submitting it",
+	Long: `Show the content of a transaction envelope: its source, fee, sequence
+number, time bounds and memo, each of its operations, and its signatures with
+the wallets or contacts they belong to.
+
+The names of the wallets, contacts and known assets are shown instead of their
+addresses. The signatures are checked against the hash of the transaction on
+the network selected by --network. The transaction can be given as base64 XDR
+or as the path of a file containing it.`,
+	Example: `alfred decode AAAAAG...
+alfred decode ./payment.xdr --network testnet
+alfred decode --paste`,
+	Args:    envelopeArgs(0),
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		arg, _, err := envelopeArg(cmd, args)
+		if err != nil {
+			fatal(err)
+		}
+		_, txe, err := readEnvelope(arg)
+		if err != nil {
+			fatal(err)
+		}
+
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		n := currentNetwork()
+		hash, err := network.HashTransaction(&txe.Tx, n.Passphrase)
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Println("Transaction:")
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Field", "Value"))
+		table.SetAutoWrapText(false)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.Append([]string{"Hash", hex.EncodeToString(hash[:]) + " (" + n.Name + ")"})
+		table.Append([]string{"Source", describeAccount(m, txe.Tx.SourceAccount.Address())})
+		table.Append([]string{"Fee", fmt.Sprintf("%d stroops (%s XLM)", txe.Tx.Fee, formatStroops(xdr.Int64(txe.Tx.Fee), "XLM"))})
+		table.Append([]string{"Sequence", strconv.FormatInt(int64(txe.Tx.SeqNum), 10)})
+		table.Append([]string{"Valid from", describeTimeBound(txe.Tx.TimeBounds, true)})
+		table.Append([]string{"Valid until", describeTimeBound(txe.Tx.TimeBounds, false)})
+		table.Append([]string{"Memo", describeMemo(txe.Tx.Memo)})
+		table.Render()
+
+		fmt.Println()
+		fmt.Println("Operations:")
+		printOperations(m, txe.Tx.Operations)
+
+		fmt.Println()
+		if len(txe.Signatures) == 0 {
+			fmt.Println("Not signed yet, sign it with: alfred sign <xdr>")
+			return
+		}
+		fmt.Println("Signatures:")
+		table = newTable(os.Stdout)
+		table.SetHeader(header("Hint", "Signer", "Valid"))
+		for _, sig := range txe.Signatures {
+			signer, valid := signatureOwner(m, txe, hash, sig)
+			table.Append([]string{hex.EncodeToString(sig.Hint[:]), signer, valid})
+		}
+		table.Render()
+	},
+}
+
+// printOperations prints ops in order, with their source and what they do
+func printOperations(m *wallet.Alfred, ops []xdr.Operation) {
+	table := newTable(os.Stdout)
+	table.SetHeader(header("#", "Source", "Type", "Details"))
+	table.SetAutoWrapText(false)
+	for i, op := range ops {
+		source := "transaction source"
+		if op.SourceAccount != nil {
+			source = accountLabel(m, op.SourceAccount.Address())
+		}
+		table.Append([]string{strconv.Itoa(i + 1), source, explain.OperationName(op.Body.Type), describeOperation(m, op.Body)})
+	}
+	table.Render()
+}
+
+// describeAccount returns the name of address followed by the address
+func describeAccount(m *wallet.Alfred, address string) string {
+	if name, ok := m.AccountName(address); ok {
+		return fmt.Sprintf("%s (%s)", name, address)
+	}
+	return address
+}
+
+func describeTimeBound(tb *xdr.TimeBounds, min bool) string {
+	switch {
+	case tb == nil:
+		return "no limit"
+	case min && tb.MinTime > 0:
+		return time.Unix(int64(tb.MinTime), 0).Format(time.RFC1123)
+	case !min && tb.MaxTime > 0:
+		return time.Unix(int64(tb.MaxTime), 0).Format(time.RFC1123)
+	}
+	return "no limit"
+}
+
+func describeMemo(memo xdr.Memo) string {
+	switch memo.Type {
+	case xdr.MemoTypeMemoText:
+		return strconv.Quote(*memo.Text) + " (text)"
+	case xdr.MemoTypeMemoId:
+		return strconv.FormatUint(uint64(*memo.Id), 10) + " (id)"
+	case xdr.MemoTypeMemoHash:
+		return hex.EncodeToString(memo.Hash[:]) + " (hash)"
+	case xdr.MemoTypeMemoReturn:
+		return hex.EncodeToString(memo.RetHash[:]) + " (return)"
+	}
+	return "none"
+}
+
+// describeXDRAsset returns the code of a followed by its issuer: the domain of a
+// known asset, or the name of a wallet or contact
+func describeXDRAsset(m *wallet.Alfred, a xdr.Asset) string {
+	var typ xdr.AssetType
+	var code, issuer string
+	if err := a.Extract(&typ, &code, &issuer); err != nil || typ == xdr.AssetTypeAssetTypeNative {
+		return "XLM"
+	}
+	return describeCreditAsset(m, code, issuer)
+}
+
+// describeHorizonAsset is describeXDRAsset for the assets of horizon
+func describeHorizonAsset(m *wallet.Alfred, a horizon.Asset) string {
+	if a.Type == "native" {
+		return "XLM"
+	}
+	return describeCreditAsset(m, a.Code, a.Issuer)
+}
+
+func describeCreditAsset(m *wallet.Alfred, code, issuer string) string {
+	if known := assets.GetByCodeIssuer(code, issuer); known != nil && known.Domain != "" {
+		return fmt.Sprintf("%s (%s)", code, known.Domain)
+	}
+	return fmt.Sprintf("%s (issued by %s)", code, accountLabel(m, issuer))
+}
+
+func describePrice(p xdr.Price) string {
+	if p.D == 0 {
+		return "invalid price"
+	}
+	return big.NewRat(int64(p.N), int64(p.D)).FloatString(7)
+}
+
+// describeOperation returns what body does, with the names of the accounts
+// and assets involved
+func describeOperation(m *wallet.Alfred, body xdr.OperationBody) string {
+	name := func(id xdr.AccountId) string {
+		return accountLabel(m, id.Address())
+	}
+
+	switch body.Type {
+	case xdr.OperationTypeCreateAccount:
+		op := body.CreateAccountOp
+		return fmt.Sprintf("create %s with %s XLM", name(op.Destination), formatStroops(op.StartingBalance, "XLM"))
+	case xdr.OperationTypePayment:
+		op := body.PaymentOp
+		return fmt.Sprintf("pay %s %s to %s", formatStroops(op.Amount, assetName(op.Asset)), describeXDRAsset(m, op.Asset), name(op.Destination))
+	case xdr.OperationTypePathPayment:
+		op := body.PathPaymentOp
+		return fmt.Sprintf("pay %s %s to %s, sending at most %s %s", formatStroops(op.DestAmount, assetName(op.DestAsset)), describeXDRAsset(m, op.DestAsset),
+			name(op.Destination), formatStroops(op.SendMax, assetName(op.SendAsset)), describeXDRAsset(m, op.SendAsset))
+	case xdr.OperationTypeManageOffer:
+		op := body.ManageOfferOp
+		switch {
+		case op.OfferId != 0 && op.Amount == 0:
+			return fmt.Sprintf("cancel offer %d", op.OfferId)
+		case op.OfferId != 0:
+			return fmt.Sprintf("update offer %d: sell %s %s for %s at %s", op.OfferId, formatStroops(op.Amount, assetName(op.Selling)),
+				describeXDRAsset(m, op.Selling), describeXDRAsset(m, op.Buying), describePrice(op.Price))
+		}
+		return fmt.Sprintf("sell %s %s for %s at %s", formatStroops(op.Amount, assetName(op.Selling)), describeXDRAsset(m, op.Selling),
+			describeXDRAsset(m, op.Buying), describePrice(op.Price))
+	case xdr.OperationTypeCreatePassiveOffer:
+		op := body.CreatePassiveOfferOp
+		return fmt.Sprintf("sell %s %s for %s at %s, without taking the offers at the same price", formatStroops(op.Amount, assetName(op.Selling)),
+			describeXDRAsset(m, op.Selling), describeXDRAsset(m, op.Buying), describePrice(op.Price))
+	case xdr.OperationTypeSetOptions:
+		return describeSetOptions(m, body.SetOptionsOp)
+	case xdr.OperationTypeChangeTrust:
+		op := body.ChangeTrustOp
+		if op.Limit == 0 {
+			return "remove the trustline to " + describeXDRAsset(m, op.Line)
+		}
+		return fmt.Sprintf("trust %s up to %s", describeXDRAsset(m, op.Line), formatAmount(formatLimit(amount.String(op.Limit)), assetName(op.Line)))
+	case xdr.OperationTypeAllowTrust:
+		op := body.AllowTrustOp
+		var code string
+		if op.Asset.AssetCode4 != nil {
+			code = strings.TrimRight(string(op.Asset.AssetCode4[:]), "\x00")
+		} else if op.Asset.AssetCode12 != nil {
+			code = strings.TrimRight(string(op.Asset.AssetCode12[:]), "\x00")
+		}
+		if op.Authorize {
+			return fmt.Sprintf("authorize %s to hold %s", name(op.Trustor), code)
+		}
+		return fmt.Sprintf("deauthorize %s from holding %s", name(op.Trustor), code)
+	case xdr.OperationTypeAccountMerge:
+		return fmt.Sprintf("merge the account into %s", name(*body.Destination))
+	case xdr.OperationTypeInflation:
+		return "run the inflation"
+	case xdr.OperationTypeManageData:
+		op := body.ManageDataOp
+		if op.DataValue == nil {
+			return fmt.Sprintf("delete data %s", op.DataName)
+		}
+		return fmt.Sprintf("set data %s to %s", op.DataName, describeDataValue(*op.DataValue))
+	}
+
+	return ""
+}
+
+func describeSetOptions(m *wallet.Alfred, op *xdr.SetOptionsOp) string {
+	var changes []string
+	if op.InflationDest != nil {
+		changes = append(changes, "inflation destination "+accountLabel(m, op.InflationDest.Address()))
+	}
+	flags := func(verb string, f *xdr.Uint32) {
+		if f == nil {
+			return
+		}
+		if *f&xdr.Uint32(xdr.AccountFlagsAuthRequiredFlag) != 0 {
+			changes = append(changes, verb+" auth required")
+		}
+		if *f&xdr.Uint32(xdr.AccountFlagsAuthRevocableFlag) != 0 {
+			changes = append(changes, verb+" auth revocable")
+		}
+	}
+	flags("set", op.SetFlags)
+	flags("clear", op.ClearFlags)
+	if op.MasterWeight != nil {
+		changes = append(changes, fmt.Sprintf("master weight %d", *op.MasterWeight))
+	}
+	for _, t := range []struct {
+		name  string
+		value *xdr.Uint32
+	}{{"low", op.LowThreshold}, {"medium", op.MedThreshold}, {"high", op.HighThreshold}} {
+		if t.value != nil {
+			changes = append(changes, fmt.Sprintf("%s threshold %d", t.name, *t.value))
+		}
+	}
+	if op.HomeDomain != nil {
+		changes = append(changes, "home domain "+firstNonEmpty(string(*op.HomeDomain), "removed"))
+	}
+	if op.Signer != nil {
+		signer := op.Signer.Key.Address()
+		if op.Signer.Weight == 0 {
+			changes = append(changes, "remove signer "+accountLabel(m, signer))
+		} else {
+			changes = append(changes, fmt.Sprintf("signer %s with weight %d", accountLabel(m, signer), op.Signer.Weight))
+		}
+	}
+
+	if len(changes) == 0 {
+		return "nothing"
+	}
+	return strings.Join(changes, ", ")
+}
+
+// describeDataValue returns v as text if it is printable, in base64 otherwise
+func describeDataValue(v xdr.DataValue) string {
+	for _, r := range string(v) {
+		if !unicode.IsPrint(r) {
+			return base64.StdEncoding.EncodeToString(v) + " (base64)"
+		}
+	}
+	return strconv.Quote(string(v))
+}
+
+// signatureOwner returns the wallet or contact whose hint matches sig, and
+// whether sig is its signature of hash
+func signatureOwner(m *wallet.Alfred, txe xdr.TransactionEnvelope, hash [32]byte, sig xdr.DecoratedSignature) (string, string) {
+	candidates := sourceAccounts(txe)
+	for _, w := range m.Stellar.Wallets {
+		candidates = append(candidates, w.Keypair.Address())
+	}
+	for _, c := range m.Stellar.Contacts {
+		candidates = append(candidates, c.Address)
+	}
+
+	for _, address := range candidates {
+		kp, err := keypair.Parse(address)
+		if err != nil || kp.Hint() != [4]byte(sig.Hint) {
+			continue
+		}
+		if kp.Verify(hash[:], sig.Signature) != nil {
+			return describeAccount(m, address), "no, wrong network or transaction"
+		}
+		return describeAccount(m, address), "yes"
+	}
+
+	return "unknown", "unknown"
+}
+
+func init() {
+	RootCmd.AddCommand(decodeCmd)
+
+	addPasteFlag(decodeCmd)
+}
@@ -0,0 +1,109 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/celrenheit/alfred/hardware"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// decoyCmd represents the decoy command
+var decoyCmd = &cobra.Command{
+	Use:   "decoy",
+	Short: "Add a decoy database opened with a duress passphrase",
+	Long: `Add a decoy to the database: another database stored in the same file,
+encrypted with its own passphrase. Given in place of the secret, the duress
+passphrase opens the decoy, in which every command works as usual, for
+instance to hold a few low-value wallets. The main wallets are not visible
+from the decoy, nor the decoy from the main wallets.
+
+Every database holds a compartment of the same size as a small decoy, so the
+file does not tell whether it has one. The database becomes private: the
+names and addresses of the main wallets are no longer stored in clear, so
+the commands which do not sign need the secret, see alfred db private.`,
+	Example: `alfred decoy create
+alfred --secret <duress passphrase> new --name pocket`,
+}
+
+// decoyCreateCmd represents the decoy create command
+var decoyCreateCmd = &cobra.Command{
+	Use:     "create",
+	Short:   "Add an empty decoy opened with a duress passphrase",
+	Example: `alfred decoy create`,
+	Args:    cobra.NoArgs,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		store, err := wallet.OpenStore(path)
+		if err != nil {
+			fatal(err)
+		}
+		m, err := wallet.Load(store, []byte(viper.GetString("secret")))
+		if err != nil {
+			fatal(err)
+		}
+		if m.IsDecoy() {
+			fatal("a decoy is added with the main secret of the database")
+		}
+
+		if err := checkInteractive("passphrase", "the duress passphrase is prompted", ""); err != nil {
+			fatal(err)
+		}
+		fmt.Println("Duress passphrase:")
+		passphrase, err := promptPassword()
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Println("Confirm it:")
+		confirmation, err := promptPassword()
+		if err != nil {
+			fatal(err)
+		}
+		if confirmation != passphrase {
+			fatal("the passphrases do not match")
+		}
+
+		if m.Hardware != nil {
+			response, err := respond(m.Hardware)
+			if err != nil {
+				fatal(err)
+			}
+			passphrase = hardware.Secret(passphrase, response)
+		}
+
+		// the main content is loaded again while the database is held, so
+		// that what was written meanwhile is kept
+		if err := wallet.Hold(store, func(store wallet.Store) error {
+			m, err := wallet.Load(store, []byte(viper.GetString("secret")))
+			if err != nil {
+				return err
+			}
+			_, err = wallet.AddDecoy(store, m, []byte(passphrase))
+			return err
+		}); err != nil {
+			fatal(err)
+		}
+		fmt.Println("The decoy was added and the database is now private, give the duress passphrase in place of the secret to open it and add wallets to it")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(decoyCmd)
+	decoyCmd.AddCommand(decoyCreateCmd)
+}
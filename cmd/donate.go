@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/celrenheit/alfred/i18n"
 	"github.com/celrenheit/alfred/parser"
 	"github.com/celrenheit/alfred/wallet"
 	"github.com/manifoldco/promptui"
@@ -44,8 +45,12 @@ var donateCmd = &cobra.Command{
 		case 1:
 			amount = args[0]
 		default:
+			if err := checkInteractive("amount", "no amount given", "the amount as argument"); err != nil {
+				fatal(err)
+			}
+
 			prompt := promptui.Prompt{
-				Label: "Amount",
+				Label: i18n.T("Amount"),
 				Validate: func(input string) error {
 					_, err := strconv.ParseFloat(input, 64)
 					return err
@@ -59,7 +64,7 @@ var donateCmd = &cobra.Command{
 			}
 		}
 
-		client := getClient(viper.GetBool("testnet"))
+		client := getClient(currentNetwork())
 
 		path := viper.GetString("db")
 		secret := viper.GetString("secret")
@@ -74,7 +79,7 @@ var donateCmd = &cobra.Command{
 			To:       alfredAddress,
 		})
 		if err != nil {
-			fatalf(describeHorizonError(err))
+			fatal(err)
 		}
 		fmt.Println("Thank You ♥️")
 		fmt.Println("Keep on rockin' 🚀")
@@ -50,7 +50,7 @@ var downloadCmd = &cobra.Command{
 
 		kp := getAddress(m, accName)
 
-		client := getClient(viper.GetBool("testnet"))
+		client := getClient(currentNetwork())
 		acc, exists, err := getAccount(client, kp.Address())
 		if err != nil {
 			fatal(err)
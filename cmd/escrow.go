@@ -0,0 +1,474 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/tx"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+// escrowReserve returns the minimum balance of an escrow account, with its
+// master key, the destination and the two pre-signed transactions as
+// signers, plus the fees of the 4 operations it submits
+func escrowReserve(stroops uint64) xdr.Int64 {
+	return amount.MustParse("2.5") + xdr.Int64(4*stroops)
+}
+
+// escrowCmd represents the escrow command
+var escrowCmd = &cobra.Command{
+	Use:   "escrow",
+	Short: "Lock funds until a date for a destination",
+	Long: `List the escrows created with alfred escrow create and whether they are
+still locked.
+
+An escrow is a new account holding the funds, which can only be moved with
+the signatures of both the wallet and the destination. Two transactions are
+signed in advance: after the unlock date, anyone can submit the first one to
+give the account to the destination; if it was not, the second one gives it
+back to the wallet after the recovery date.`,
+	Example: `alfred escrow
+alfred escrow create 1000 XLM from master to bob unlock after 2025-01-01
+alfred escrow unlock 1`,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		network := currentNetwork().Name
+		client := getClient(currentNetwork())
+
+		var escrows []wallet.Escrow
+		for _, e := range m.Stellar.Escrows {
+			if e.Network == network {
+				escrows = append(escrows, e)
+				prefetchAccounts(client, e.Account)
+			}
+		}
+		if len(escrows) == 0 {
+			fmt.Println("No escrow on", network)
+			return
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("ID", "Wallet", "Destination", "Amount", "Unlock after", "Recover after", "Status"))
+		for _, e := range escrows {
+			table.Append([]string{
+				strconv.Itoa(e.ID),
+				e.Wallet,
+				accountName(m, e.Destination),
+				e.Amount + " XLM",
+				e.UnlockAfter.Format(time.RFC1123),
+				e.RecoverAfter.Format(time.RFC1123),
+				escrowStatus(client, e),
+			})
+		}
+		table.Render()
+	},
+}
+
+// escrowCreateCmd represents the escrow create command
+var escrowCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Lock an amount in a new escrow account",
+	Long: `Create an escrow account holding the amount, which the destination can
+claim after the unlock date.
+
+  <amount> XLM [FROM <wallet>] TO <destination> UNLOCK AFTER <date>
+
+The wallet also funds the reserve of the escrow account. The key of the escrow
+account is stored as a wallet named after the escrow, and the pre-signed
+transactions are stored in the database.`,
+	Example: `alfred escrow create 1000 XLM from master to bob unlock after 2025-01-01
+alfred escrow create 50 XLM to GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6 unlock after 2025-06-30T12:00:00Z --recovery 168h`,
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		req, err := parser.ParseEscrow(strings.Join(args, " "))
+		if err != nil {
+			fatal(err)
+		}
+		logStatement(req)
+
+		amt, err := amount.Parse(req.Amount)
+		if err != nil || amt <= 0 {
+			fatalf("invalid amount '%s'", req.Amount)
+		}
+
+		asset, err := selectAsset(req.Currency)
+		if err != nil {
+			fatal(err)
+		}
+		if !asset.BuilderAsset.Native {
+			fatal("only XLM can be held in escrow")
+		}
+
+		if !req.UnlockAfter.After(time.Now()) {
+			fatalf("the unlock date %s is in the past", req.UnlockAfter.Format(time.RFC1123))
+		}
+
+		recovery, _ := cmd.Flags().GetDuration("recovery")
+		if recovery <= 0 {
+			fatal("the recovery delay should be positive")
+		}
+		recoverAfter := req.UnlockAfter.Add(recovery)
+
+		if override, _ := cmd.Flags().GetBool("override-policy"); override {
+			viper.Set("override-policy", true)
+		}
+
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		src, err := getOrSelectWallet(m, req.From)
+		if err != nil {
+			fatal(err)
+		}
+		if err := requireHot(m, src); err != nil {
+			fatal(err)
+		}
+
+		dest, _, err := resolveDestination(m, req.To)
+		if err != nil {
+			fatal(err)
+		}
+		if dest == src.Address() {
+			fatal("the destination should be another account")
+		}
+
+		client := getClient(currentNetwork())
+		if _, exists, err := getAccount(client, dest); err != nil {
+			fatal(err)
+		} else if !exists {
+			fatalf("account %s does not exist", dest)
+		}
+
+		stroops, err := baseFee(client)
+		if err != nil {
+			fatal(err)
+		}
+		reserve := escrowReserve(stroops)
+
+		funding := amount.String(amt + reserve)
+		confirm, err := enforcePolicy(m, client, src.Address(), asset, []outgoing{{to: dest, amount: funding}})
+		if err != nil {
+			fatal(err)
+		}
+
+		kp, err := keypair.Random()
+		if err != nil {
+			fatal(err)
+		}
+
+		e := wallet.Escrow{
+			Network:      currentNetwork().Name,
+			Wallet:       accountName(m, src.Address()),
+			Account:      kp.Address(),
+			Destination:  dest,
+			Amount:       req.Amount,
+			UnlockAfter:  req.UnlockAfter,
+			RecoverAfter: recoverAfter,
+		}
+
+		seed, err := src.Seed()
+		if err != nil {
+			fatal(err)
+		}
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		err = submitTx(txRequest{
+			client: client,
+			seeds:  []string{seed},
+			opts: withNetwork(
+				build.SourceAccount{AddressOrSeed: seed},
+				build.AutoSequence{SequenceProvider: client},
+				build.CreateAccount(build.Destination{AddressOrSeed: kp.Address()}, build.NativeAmount{Amount: funding}),
+			),
+			db: m,
+			summary: map[string]string{
+				"From":          accountName(m, src.Address()),
+				"To":            accountName(m, dest),
+				"Amount":        formatAmount(req.Amount, "XLM") + " XLM",
+				"Reserve":       formatStroops(reserve, "XLM") + " XLM",
+				"Unlock after":  req.UnlockAfter.Format(time.RFC1123),
+				"Recover after": recoverAfter.Format(time.RFC1123),
+			},
+			yes: (yes || viper.GetBool("yes")) && !confirm,
+		})
+		if err != nil {
+			fatal(err)
+		}
+
+		// the escrow account is funded: keep its key before anything else can fail
+		m, err = updateDB(func(m *wallet.Alfred) error {
+			e.ID = m.AddEscrow(e)
+			return m.AddWallet(wallet.New(fmt.Sprintf("escrow-%d", e.ID), kp))
+		})
+		if err != nil {
+			fatal(err)
+		}
+
+		stored, err := m.Escrow(e.ID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := lockEscrow(client, kp, stored); err != nil {
+			fmt.Printf("The escrow account %s was funded but could not be locked, it is still controlled by the wallet escrow-%d\n", kp.Address(), e.ID)
+			fatal(err)
+		}
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			locked, err := m.Escrow(e.ID)
+			if err != nil {
+				return err
+			}
+			*locked = *stored
+			return nil
+		}); err != nil {
+			fatal(err)
+		}
+
+		fmt.Printf("Escrow %d holds %s XLM in %s for %s\n", e.ID, req.Amount, kp.Address(), accountName(m, dest))
+		fmt.Println("After", req.UnlockAfter.Format(time.RFC1123), "anyone can give it to the destination with: alfred escrow unlock", e.ID)
+		fmt.Println("or by submitting this transaction:")
+		fmt.Println()
+		fmt.Println(stored.Unlock)
+		copyValue("transaction", stored.Unlock)
+	},
+}
+
+// lockEscrow signs the unlock and recovery transactions of e in advance, and
+// adds them as signers of the escrow account along with the destination so
+// that its key can no longer move the funds alone
+func lockEscrow(client *horizon.Client, kp *keypair.Full, e *wallet.Escrow) error {
+	acc, _, err := loadAccount(client, kp.Address())
+	if err != nil {
+		return err
+	}
+	seq, err := strconv.ParseUint(acc.Sequence, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	// both use the sequence number following the locking transaction, so
+	// that submitting one of them invalidates the other
+	stroops, err := baseFee(client)
+	if err != nil {
+		return err
+	}
+
+	unlock, unlockKey, err := preAuthorize(kp.Address(), seq+2, stroops, e.UnlockAfter,
+		build.SetOptions(build.MasterWeight(0), build.SetThresholds(1, 1, 1)))
+	if err != nil {
+		return err
+	}
+	recovery, recoveryKey, err := preAuthorize(kp.Address(), seq+2, stroops, e.RecoverAfter,
+		build.SetOptions(build.RemoveSigner(e.Destination), build.SetThresholds(1, 1, 1)))
+	if err != nil {
+		return err
+	}
+
+	err = submitTx(txRequest{
+		client: client,
+		seeds:  []string{kp.Seed()},
+		opts: withNetwork(
+			build.SourceAccount{AddressOrSeed: kp.Address()},
+			build.Sequence{Sequence: seq + 1},
+			build.SetOptions(build.AddSigner(e.Destination, 1)),
+			build.SetOptions(build.AddSigner(unlockKey, 2)),
+			build.SetOptions(build.AddSigner(recoveryKey, 2), build.SetThresholds(2, 2, 2)),
+		),
+		yes: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	e.Unlock, e.Recovery = unlock, recovery
+	return nil
+}
+
+// preAuthorize builds the transaction of account with seq and a fee of
+// stroops, valid from notBefore, and returns it with the pre-authorized
+// signer key of its hash
+func preAuthorize(account string, seq, stroops uint64, notBefore time.Time, op build.TransactionMutator) (string, string, error) {
+	t, err := builder.Build(withNetwork(
+		build.SourceAccount{AddressOrSeed: account},
+		build.Sequence{Sequence: seq},
+		build.BaseFee{Amount: stroops},
+		tx.TimeBounds{MinTime: notBefore},
+		op,
+	))
+	if err != nil {
+		return "", "", err
+	}
+
+	hash, err := t.HashHex()
+	if err != nil {
+		return "", "", err
+	}
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := strkey.Encode(strkey.VersionByteHashTx, raw)
+	if err != nil {
+		return "", "", err
+	}
+
+	txeB64, err := t.Sign()
+	if err != nil {
+		return "", "", err
+	}
+	return txeB64, key, nil
+}
+
+// withNetwork appends the network of the configuration to opts
+func withNetwork(opts ...build.TransactionMutator) []build.TransactionMutator {
+	return append(opts, currentNetwork().mutator())
+}
+
+// escrowStatus tells who controls the escrow account of e
+func escrowStatus(client *horizon.Client, e wallet.Escrow) string {
+	acc, exists, err := getAccount(client, e.Account)
+	switch {
+	case err != nil:
+		return "unknown"
+	case !exists:
+		return "merged"
+	case e.Unlock == "":
+		return "not locked"
+	}
+
+	destination := false
+	for _, s := range acc.Signers {
+		if s.Key == e.Account && s.Weight == 0 {
+			return "unlocked"
+		}
+		if s.Key == e.Destination {
+			destination = true
+		}
+	}
+	if !destination {
+		return "recovered"
+	}
+	if time.Now().After(e.UnlockAfter) {
+		return "unlockable"
+	}
+	return "locked"
+}
+
+// escrowUnlockCmd represents the escrow unlock command
+var escrowUnlockCmd = &cobra.Command{
+	Use:   "unlock <id>",
+	Short: "Give an escrow account to its destination",
+	Long: `Submit the pre-signed transaction removing the key of the escrow account
+from its signers, which leaves the destination in control of the funds. It is
+valid after the unlock date, unless the escrow was recovered.`,
+	Example: "alfred escrow unlock 1",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		submitEscrow(args[0], func(e *wallet.Escrow) (string, time.Time) {
+			return e.Unlock, e.UnlockAfter
+		})
+	},
+}
+
+// escrowRecoverCmd represents the escrow recover command
+var escrowRecoverCmd = &cobra.Command{
+	Use:   "recover <id>",
+	Short: "Take back an escrow account that was not unlocked",
+	Long: `Submit the pre-signed transaction removing the destination from the signers
+of the escrow account, which gives the funds back to the wallet escrow-<id>.
+It is valid after the recovery date, unless the escrow was unlocked.`,
+	Example: "alfred escrow recover 1",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		submitEscrow(args[0], func(e *wallet.Escrow) (string, time.Time) {
+			return e.Recovery, e.RecoverAfter
+		})
+	},
+}
+
+// submitEscrow submits the pre-signed transaction of the escrow with id
+// chosen by pick, which also returns the time from which it is valid
+func submitEscrow(arg string, pick func(e *wallet.Escrow) (string, time.Time)) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		fatalf("invalid escrow id '%s'", arg)
+	}
+
+	m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+	if err != nil {
+		fatal(err)
+	}
+
+	e, err := m.Escrow(id)
+	if err != nil {
+		fatal(err)
+	}
+	if network := currentNetwork().Name; e.Network != network {
+		fatalf("escrow %d is on %s, not %s", id, e.Network, network)
+	}
+
+	txeB64, notBefore := pick(e)
+	if txeB64 == "" {
+		fatalf("escrow %d was not locked, its account is controlled by the wallet escrow-%d", id, id)
+	}
+	if time.Now().Before(notBefore) {
+		fatalf("escrow %d can not be submitted before %s", id, notBefore.Format(time.RFC1123))
+	}
+
+	client := getClient(currentNetwork())
+	resp, err := submitPending(m, client, txeB64)
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Println(resp.Hash)
+	printExplorerLinks(resp.Hash)
+	recordTx(txRequest{db: m}, resp.Hash, txeB64)
+}
+
+func init() {
+	RootCmd.AddCommand(escrowCmd)
+	escrowCmd.AddCommand(escrowCreateCmd)
+	escrowCmd.AddCommand(escrowUnlockCmd)
+	escrowCmd.AddCommand(escrowRecoverCmd)
+
+	escrowCreateCmd.Flags().Duration("recovery", 30*24*time.Hour, "delay after the unlock date from which the wallet can recover the funds")
+	escrowCreateCmd.Flags().BoolP("yes", "y", false, "if set, no confirmation prompt will be shown")
+	escrowCreateCmd.Flags().Bool("override-policy", false, "create escrows denied by the policy of the wallet, the violation is logged")
+}
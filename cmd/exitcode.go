@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/celrenheit/alfred/explain"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// Exit codes of alfred, by category of failure, so that scripts can branch on
+// it. They are documented in the README and must not change.
+const (
+	exitFailure           = 1 // any other failure
+	exitParse             = 2 // invalid statement, flag or argument
+	exitHorizon           = 3 // horizon failed, or rejected the transaction
+	exitInsufficientFunds = 4 // not enough funds or reserve for the transaction
+	exitCancelled         = 5 // declined or cancelled at a prompt, or its input closed
+	exitPolicyDenied      = 6 // denied by the spending policy of the wallet
+	exitPromptRequired    = 7 // a prompt is needed with --non-interactive
+)
+
+// insufficientFundsCodes are the result codes of the transactions failing for
+// lack of funds or reserve
+var insufficientFundsCodes = map[string]bool{
+	"tx_insufficient_balance": true,
+	"op_underfunded":          true,
+	"op_low_reserve":          true,
+}
+
+// codedError is an error of the category of code
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// withExitCode returns err in the category of code, nil if err is nil
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// exitCode returns the exit code of the category of err: the one it was given
+// with withExitCode, or the one of the errors of parser, promptui and horizon
+func exitCode(err error) int {
+	var coded *codedError
+	var syntax *parser.SyntaxError
+	var prompt *promptError
+	var herr *horizon.Error
+	var nerr net.Error
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.As(err, &syntax):
+		return exitParse
+	case errors.As(err, &prompt):
+		return exitPromptRequired
+	case errors.Is(err, promptui.ErrAbort), errors.Is(err, promptui.ErrInterrupt), errors.Is(err, promptui.ErrEOF), errors.Is(err, io.EOF),
+		errors.Is(err, errInterrupted), errors.Is(err, context.Canceled):
+		return exitCancelled
+	case errors.As(err, &herr):
+		if codes, cerr := herr.ResultCodes(); cerr == nil && codes != nil {
+			for _, code := range append([]string{codes.TransactionCode}, codes.OperationCodes...) {
+				if insufficientFundsCodes[code] {
+					return exitInsufficientFunds
+				}
+			}
+		}
+		return exitHorizon
+	case errors.As(err, &nerr):
+		return exitHorizon
+	}
+	return exitFailure
+}
+
+// insufficientFunds reports whether one of the checks missed by a transaction
+// is a lack of funds or reserve
+func insufficientFunds(missing []explain.Explanation) bool {
+	for _, e := range missing {
+		if insufficientFundsCodes[e.Code] {
+			return true
+		}
+	}
+	return false
+}
+
+// describeError returns the message of err, explaining the result codes of a
+// transaction rejected by horizon
+func describeError(err error) string {
+	var herr *horizon.Error
+	if errors.As(err, &herr) {
+		return describeHorizonError(herr)
+	}
+	return err.Error()
+}
+
+// categorizeUsageErrors gives the errors of the flags and arguments of c and
+// its subcommands the exit code of parse errors
+func categorizeUsageErrors(c *cobra.Command) {
+	c.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return withExitCode(exitParse, err)
+	})
+	if args := c.Args; args != nil {
+		c.Args = func(cmd *cobra.Command, a []string) error {
+			return withExitCode(exitParse, args(cmd, a))
+		}
+	}
+	for _, sub := range c.Commands() {
+		categorizeUsageErrors(sub)
+	}
+}
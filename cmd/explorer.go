@@ -0,0 +1,89 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// explorer is a block explorer showing the transactions of a network
+type explorer struct {
+	name    string
+	public  string
+	testnet string
+}
+
+// explorers are the block explorers linked to once a transaction is
+// submitted, the first one is opened by --open
+var explorers = []explorer{
+	{name: "StellarExpert", public: "https://stellar.expert/explorer/public/tx/", testnet: "https://stellar.expert/explorer/testnet/tx/"},
+	{name: "Stellarchain", public: "https://stellarchain.io/transactions/", testnet: "https://testnet.stellarchain.io/transactions/"},
+}
+
+// explorerLink is the page of a transaction on a block explorer
+type explorerLink struct {
+	Name string
+	URL  string
+}
+
+// explorerLinks returns the pages of the transaction hash on the block
+// explorers of the active network. There are none when horizon servers are
+// given with --horizon, they may serve a private network, nor on the networks
+// the explorers do not show.
+func explorerLinks(hash string) []explorerLink {
+	if len(viper.GetStringSlice("horizon")) > 0 {
+		return nil
+	}
+
+	var links []explorerLink
+	for _, e := range explorers {
+		var prefix string
+		switch currentNetwork().Name {
+		case publicNetwork:
+			prefix = e.public
+		case testNetwork:
+			prefix = e.testnet
+		default:
+			return nil
+		}
+		links = append(links, explorerLink{Name: e.name, URL: prefix + hash})
+	}
+
+	return links
+}
+
+// printExplorerLinks prints the pages of the transaction hash on the block
+// explorers, opens the first one in the browser with --open and copies the
+// hash with --copy
+func printExplorerLinks(hash string) {
+	links := explorerLinks(hash)
+	for _, l := range links {
+		fmt.Printf("  %s: %s\n", l.Name, l.URL)
+	}
+	openExplorer(links)
+	copyValue("transaction hash", hash)
+}
+
+// openExplorer opens the first of links in the browser with --open
+func openExplorer(links []explorerLink) {
+	if len(links) == 0 || !viper.GetBool("open") {
+		return
+	}
+	if err := openBrowser(links[0].URL); err != nil {
+		fmt.Println("Could not open the browser:", err)
+	}
+}
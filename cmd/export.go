@@ -49,6 +49,14 @@ var exportCmd = &cobra.Command{
 		rows := make([][]string, 0)
 		rows = append(rows, []string{"name", "address", "seed"})
 		for _, w := range m.Stellar.Wallets {
+			if w.IsCold() { // its seed is not stored
+				continue
+			}
+			if w.IsProtected() {
+				if _, err := unwrap(w); err != nil {
+					fatal(err)
+				}
+			}
 			switch kp := w.Keypair.(type) {
 			case (*keypair.FromAddress):
 				log.Fatal("keypair is not unlocked")
@@ -0,0 +1,80 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/celrenheit/alfred/fee"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// chosenFee is the fee per operation of the transactions, chosen again
+// after feeTTL so that long running commands follow the network
+var chosenFee struct {
+	sync.Mutex
+	stroops uint64
+	at      time.Time
+}
+
+const feeTTL = time.Minute
+
+// baseFee returns the fee per operation set with --fee, the base fee of the
+// network by default
+func baseFee(client *horizon.Client) (uint64, error) {
+	chosenFee.Lock()
+	defer chosenFee.Unlock()
+
+	if !chosenFee.at.IsZero() && time.Since(chosenFee.at) < feeTTL {
+		return chosenFee.stroops, nil
+	}
+
+	stroops, err := chooseFee(client, viper.GetString("fee"))
+	if err != nil {
+		return 0, err
+	}
+	chosenFee.stroops, chosenFee.at = stroops, time.Now()
+	return stroops, nil
+}
+
+// chooseFee resolves the fee given with --fee, loading the fee stats of the
+// network for a level. Without it, a warning is shown in surge pricing.
+func chooseFee(client *horizon.Client, s string) (uint64, error) {
+	if s == "" {
+		if stats, err := fee.Load(client); err == nil && stats.Surge() {
+			fmt.Printf("Warning: the network is in surge pricing, transactions paying the base fee of %d stroops per operation are likely to fail: retry with --fee auto\n", build.DefaultBaseFee)
+		}
+		return build.DefaultBaseFee, nil
+	}
+
+	spec, err := fee.Parse(s)
+	if err != nil {
+		return 0, err
+	}
+	if spec.Level == "" {
+		return spec.Stroops, nil
+	}
+
+	stats, err := fee.Load(client)
+	if err != nil {
+		return 0, i18n.Errorf("unable to load the fee stats: %s", describeHorizonError(err))
+	}
+	return stats.Fee(spec.Level), nil
+}
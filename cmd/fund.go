@@ -29,8 +29,8 @@ var fundCmd = &cobra.Command{
 	Example: "alfred fund GXX",
 	PreRunE: middlewares(checkDB),
 	Run: func(cmd *cobra.Command, args []string) {
-		if !viper.GetBool("testnet") {
-			fatal("not yet supported on main net, stay tuned")
+		if !currentNetwork().isTestNetwork() {
+			fatal("friendbot only funds accounts on the test networks")
 		}
 
 		if len(args) != 1 {
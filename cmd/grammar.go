@@ -0,0 +1,173 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/celrenheit/alfred/parser"
+	"github.com/spf13/cobra"
+)
+
+// grammarCmd represents the grammar command
+var grammarCmd = &cobra.Command{
+	Use:   "grammar",
+	Short: "Inspect the versions of the grammar used by the please command",
+}
+
+// grammarDiffCmd represents the grammar diff command
+var grammarDiffCmd = &cobra.Command{
+	Use:   "diff [script]",
+	Short: "Show the differences between two versions of the grammar",
+	Long: `Show the keywords and the other changes of syntax added or removed between two versions of the grammar.
+
+If a script is given (one statement per line, lines starting with # are ignored),
+every statement is parsed with both versions and the ones that parse differently are reported.`,
+	Example: `alfred grammar diff
+alfred grammar diff --from v1 --to v2 ./payments.txt`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fromStr, _ := cmd.Flags().GetString("from")
+		toStr, _ := cmd.Flags().GetString("to")
+
+		from, err := parser.ParseVersion(fromStr)
+		if err != nil {
+			fatal(err)
+		}
+
+		to, err := parser.ParseVersion(toStr)
+		if err != nil {
+			fatal(err)
+		}
+
+		added, removed := diffKeywords(parser.Keywords(from), parser.Keywords(to))
+		fmt.Printf("Keywords from %s to %s:\n", from, to)
+		for _, kw := range added {
+			fmt.Println("  +", kw)
+		}
+		for _, kw := range removed {
+			fmt.Println("  -", kw)
+		}
+		if len(added) == 0 && len(removed) == 0 {
+			fmt.Println("  no changes")
+		}
+
+		added, removed = diffKeywords(parser.Syntax(from), parser.Syntax(to))
+		fmt.Printf("Syntax from %s to %s:\n", from, to)
+		for _, change := range added {
+			fmt.Println("  +", change)
+		}
+		for _, change := range removed {
+			fmt.Println("  -", change)
+		}
+		if len(added) == 0 && len(removed) == 0 {
+			fmt.Println("  no changes")
+		}
+
+		if len(args) == 0 {
+			return
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+
+		fmt.Println()
+
+		changed := 0
+		scanner := bufio.NewScanner(f)
+		for lineNo := 1; scanner.Scan(); lineNo++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			before, errBefore := parser.ParseWithVersion(line, from)
+			after, errAfter := parser.ParseWithVersion(line, to)
+			if sameParse(before, errBefore, after, errAfter) {
+				continue
+			}
+
+			changed++
+			fmt.Printf("line %d: %s\n", lineNo, line)
+			fmt.Printf("  %s: %s\n", from, describeParse(before, errBefore))
+			fmt.Printf("  %s: %s\n", to, describeParse(after, errAfter))
+		}
+		if err := scanner.Err(); err != nil {
+			fatal(err)
+		}
+
+		if changed == 0 {
+			fmt.Printf("All statements parse identically with %s and %s\n", from, to)
+			return
+		}
+
+		fmt.Printf("%d statement(s) parse differently, run them with --grammar %s to keep the previous behaviour\n", changed, from)
+		os.Exit(1)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(grammarCmd)
+	grammarCmd.AddCommand(grammarDiffCmd)
+
+	grammarDiffCmd.Flags().String("from", parser.V1.String(), "version of the grammar the script was written for")
+	grammarDiffCmd.Flags().String("to", parser.Latest.String(), "version of the grammar to upgrade to")
+}
+
+func diffKeywords(from, to []string) (added, removed []string) {
+	contains := func(list []string, s string) bool {
+		for _, v := range list {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, kw := range to {
+		if !contains(from, kw) {
+			added = append(added, kw)
+		}
+	}
+	for _, kw := range from {
+		if !contains(to, kw) {
+			removed = append(removed, kw)
+		}
+	}
+
+	return added, removed
+}
+
+func sameParse(a parser.Statement, errA error, b parser.Statement, errB error) bool {
+	if errA != nil || errB != nil {
+		return errA != nil && errB != nil
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func describeParse(s parser.Statement, err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+
+	return fmt.Sprintf("%+v", s)
+}
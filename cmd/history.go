@@ -0,0 +1,456 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/api"
+	"github.com/celrenheit/alfred/desktop"
+	"github.com/celrenheit/alfred/explain"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/celrenheit/alfred/webhook"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// streamRetryDelay is the delay before resuming an interrupted stream
+const streamRetryDelay = 5 * time.Second
+
+var historyHeader = []string{"Date", "Transaction", "Op", "Source", "Operation", "Amount", "Asset", "Destination"}
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Display the latest transactions of a wallet",
+	Long: `Display the latest transactions of a wallet.
+
+Each operation of a transaction is displayed on its own row, attributed to the
+source of the operation which is not always the source of the transaction.
+
+With --all, the latest transactions of every wallet are loaded --max-requests
+wallets at a time and displayed together, most recent first. --tag does the
+same for the wallets with a tag.`,
+	Example: `alfred history master
+alfred history master --limit 50
+alfred history --all
+alfred history --tag cold`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("limit")
+		client := getClient(currentNetwork())
+
+		var (
+			m   *wallet.Alfred
+			txs []horizon.Transaction
+		)
+		if all, _ := cmd.Flags().GetBool("all"); all || cmd.Flags().Changed("tag") {
+			m, txs = allTransactions(cmd, client, limit)
+		} else {
+			var kp string
+			m, kp = openAccountArg(args)
+
+			var err error
+			txs, err = loadTransactions(client, kp, limit)
+			if err != nil {
+				fatal(err)
+			}
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header(historyHeader...))
+		table.SetColumnAlignment(amountColumns(len(historyHeader), 5))
+		for _, tx := range txs {
+			rows, err := operationRows(m, tx)
+			if err != nil {
+				fatal(err)
+			}
+			table.AppendBulk(rows)
+		}
+		table.Render()
+	},
+}
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Display the transactions of a wallet as they happen",
+	Long: `Display the transactions of a wallet as they happen, one row per operation.
+
+With --notify, a desktop notification shows each payment received, with its
+amount, sender and memo.
+
+With --webhook, or the webhooks setting, events of the wallet are also posted as
+JSON to the URLs given: payment_received for incoming payments,
+trade_filled when an offer is filled, and low_balance when the XLM balance goes
+below --low-balance. The payloads are signed with the webhook-secret setting,
+see the Webhooks section of the README.`,
+	Example: `alfred watch master
+alfred watch master --notify
+alfred watch master --webhook https://example.com/alfred --low-balance 10`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, kp := openAccountArg(args)
+
+		client := getClient(currentNetwork())
+		cursor := horizon.Cursor("now")
+
+		n := getNotifier(cmd)
+		var low *lowBalance
+		if threshold, _ := cmd.Flags().GetString("low-balance"); threshold != "" {
+			if n == nil {
+				fatal("--low-balance needs a webhook to notify")
+			}
+
+			amnt, err := amount.Parse(threshold)
+			if err != nil {
+				fatalf("invalid low balance '%s': %v", threshold, err)
+			}
+			low = &lowBalance{threshold: amnt}
+			low.check(n, m, client, kp)
+		}
+		if n != nil {
+			go watchTrades(n, m, client, kp)
+		}
+
+		desktopNotify, _ := cmd.Flags().GetBool("notify")
+		if desktopNotify {
+			if err := desktop.Check(); err != nil {
+				fatal(err)
+			}
+		}
+
+		name := args[0]
+		if w := m.WalletByAddress(kp); w != nil {
+			name = w.Name
+		}
+		startMetrics(cmd, client, func() (map[string]string, error) {
+			return map[string]string{name: kp}, nil
+		})
+
+		fmt.Println(strings.Join(header(historyHeader...), " | "))
+		handler := func(tx horizon.Transaction) {
+			cursor = horizon.Cursor(tx.PagingToken)
+
+			rows, err := operationRows(m, tx)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			for _, row := range rows {
+				fmt.Println(strings.Join(row, " | "))
+			}
+
+			if metrics, _ := cmd.Flags().GetString("metrics"); metrics != "" {
+				go balances.refresh(client, name, kp)
+			}
+
+			if n == nil && !desktopNotify {
+				return
+			}
+
+			payments, err := incomingPayments(m, tx, kp)
+			if err != nil {
+				fmt.Println(err)
+			}
+			for _, payment := range payments {
+				if desktopNotify {
+					go notifyDesktop(name, payment)
+				}
+				// the stream is not held up by the retries
+				if n != nil {
+					go notify(n, m, kp, webhook.PaymentReceived, payment)
+				}
+			}
+
+			if low != nil {
+				go low.check(n, m, client, kp)
+			}
+		}
+
+		// horizon closes the streams from time to time, they are resumed after
+		// the last transaction received
+		for {
+			err := client.StreamTransactions(rootContext, kp, &cursor, handler)
+			if rootContext.Err() != nil {
+				return
+			}
+			if err != nil {
+				fmt.Println("Stream interrupted:", describeHorizonError(err))
+			}
+
+			select {
+			case <-rootContext.Done():
+				return
+			case <-time.After(streamRetryDelay):
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+	RootCmd.AddCommand(watchCmd)
+
+	historyCmd.Flags().Int("limit", 20, "number of transactions to display")
+	historyCmd.Flags().Bool("all", false, "display the transactions of every wallet, --limit per wallet")
+	addTagFlag(historyCmd)
+
+	addWebhookFlags(watchCmd)
+	watchCmd.Flags().String("low-balance", "", "XLM balance below which a low_balance event is sent to the webhooks")
+	addMetricsFlag(watchCmd)
+	watchCmd.Flags().Bool("notify", false, "show a desktop notification for each payment received")
+}
+
+// notifyDesktop shows a desktop notification for a payment received by wallet
+func notifyDesktop(wallet string, payment webhook.Payment) {
+	body := fmt.Sprintf("%s %s from %s", payment.Amount, payment.Asset, payment.From)
+	if payment.Memo != "" {
+		body += fmt.Sprintf("\nMemo: %s", payment.Memo)
+	}
+
+	if err := desktop.Notify("Payment received by "+wallet, body); err != nil {
+		fmt.Println("Notification:", err)
+	}
+}
+
+// openAccountArg opens the database and resolves the single argument as an address
+func openAccountArg(args []string) (*wallet.Alfred, string) {
+	if len(args) != 1 {
+		fatal("one argument is expected, either an address or the name of the wallet")
+	}
+
+	path := viper.GetString("db")
+	secret := viper.GetString("secret")
+	m, err := wallet.OpenSecretString(path, secret)
+	if err != nil {
+		fatal(err)
+	}
+
+	kp := getAddress(m, args[0])
+	if kp == nil {
+		fatalf("'%s' not found", args[0])
+	}
+
+	return m, kp.Address()
+}
+
+// allTransactions opens the database and loads the latest transactions of
+// every wallet with the tags of --tag, most recent first. A transaction of
+// several wallets is only returned once.
+func allTransactions(cmd *cobra.Command, client *horizon.Client, limit int) (*wallet.Alfred, []horizon.Transaction) {
+	m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+	if err != nil {
+		fatal(err)
+	}
+
+	var (
+		txs  []horizon.Transaction
+		seen = map[string]bool{}
+	)
+	wallets := taggedWallets(cmd, m)
+	inOrder(len(wallets), func(i int) interface{} {
+		txs, err := loadTransactions(client, wallets[i].Keypair.Address(), limit)
+		if err != nil {
+			return err
+		}
+		return txs
+	}, func(i int, v interface{}) {
+		loaded, ok := v.([]horizon.Transaction)
+		if !ok {
+			fmt.Printf("%s: %s\n", wallets[i].Name, describeHorizonError(v.(error)))
+			return
+		}
+		for _, tx := range loaded {
+			if !seen[tx.Hash] {
+				seen[tx.Hash] = true
+				txs = append(txs, tx)
+			}
+		}
+	})
+
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].LedgerCloseTime.After(txs[j].LedgerCloseTime)
+	})
+	return m, txs
+}
+
+// loadTransactions loads the latest transactions of account, most recent first
+func loadTransactions(client *horizon.Client, account string, limit int) ([]horizon.Transaction, error) {
+	query := url.Values{}
+	query.Set("order", "desc")
+	query.Set("limit", strconv.Itoa(limit))
+
+	endpoint := fmt.Sprintf("%s/accounts/%s/transactions?%s", strings.TrimRight(client.URL, "/"), account, query.Encode())
+	resp, err := client.HTTP.Get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, i18n.Errorf("account '%s' does not exist", account)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		herr := &horizon.Error{Response: resp}
+		if err := json.NewDecoder(resp.Body).Decode(&herr.Problem); err != nil {
+			return nil, err
+		}
+		return nil, herr
+	}
+
+	var page struct {
+		Embedded struct {
+			Records []horizon.Transaction `json:"records"`
+		} `json:"_embedded"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+
+	return page.Embedded.Records, nil
+}
+
+// operationRows decodes the envelope of tx and returns one row per operation,
+// with the accounts labelled by accountLabel
+func operationRows(m *wallet.Alfred, tx horizon.Transaction) ([][]string, error) {
+	ops, err := operations(tx, func(address string) string { return address })
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, op := range ops {
+		// money moving between the wallets is neither received nor sent
+		amnt := formatAmount(op.Amount, op.Asset)
+		switch from, to := m.IsWallet(op.Source), m.IsWallet(op.Destination); {
+		case to && !from:
+			amnt = paintIncoming(amnt)
+		case from && !to && op.Destination != "":
+			amnt = paintOutgoing(amnt)
+		}
+
+		dest := op.Destination
+		if dest != "" {
+			dest = accountLabel(m, dest)
+		}
+		rows = append(rows, []string{
+			op.Time.Local().Format(time.RFC822),
+			paintDim(op.Transaction[:8]),
+			strconv.Itoa(op.Index),
+			accountLabel(m, op.Source),
+			op.Type,
+			amnt,
+			op.Asset,
+			dest,
+		})
+	}
+
+	return rows, nil
+}
+
+// operations decodes the envelope of tx and returns its operations, with the
+// accounts replaced by name, such as the ones of the wallets and contacts for
+// the accountName of a database
+func operations(tx horizon.Transaction, name func(address string) string) ([]api.Operation, error) {
+	var env xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(tx.EnvelopeXdr, &env); err != nil {
+		return nil, err
+	}
+
+	var ops []api.Operation
+	for i, op := range env.Tx.Operations {
+		source := env.Tx.SourceAccount
+		if op.SourceAccount != nil {
+			source = *op.SourceAccount
+		}
+
+		var amnt, asset, dest string
+		body := op.Body
+		switch body.Type {
+		case xdr.OperationTypeCreateAccount:
+			amnt, asset = amount.String(body.CreateAccountOp.StartingBalance), "XLM"
+			dest = name(body.CreateAccountOp.Destination.Address())
+		case xdr.OperationTypePayment:
+			amnt, asset = amount.String(body.PaymentOp.Amount), assetName(body.PaymentOp.Asset)
+			dest = name(body.PaymentOp.Destination.Address())
+		case xdr.OperationTypePathPayment:
+			amnt, asset = amount.String(body.PathPaymentOp.DestAmount), assetName(body.PathPaymentOp.DestAsset)
+			dest = name(body.PathPaymentOp.Destination.Address())
+		case xdr.OperationTypeManageOffer:
+			amnt, asset = amount.String(body.ManageOfferOp.Amount), assetName(body.ManageOfferOp.Selling)
+		case xdr.OperationTypeCreatePassiveOffer:
+			amnt, asset = amount.String(body.CreatePassiveOfferOp.Amount), assetName(body.CreatePassiveOfferOp.Selling)
+		case xdr.OperationTypeChangeTrust:
+			amnt, asset = amount.String(body.ChangeTrustOp.Limit), assetName(body.ChangeTrustOp.Line)
+		case xdr.OperationTypeAccountMerge:
+			dest = name(body.Destination.Address())
+		}
+
+		ops = append(ops, api.Operation{
+			Time:        tx.LedgerCloseTime,
+			Transaction: tx.Hash,
+			Index:       i + 1,
+			Source:      name(source.Address()),
+			Type:        explain.OperationName(body.Type),
+			Amount:      amnt,
+			Asset:       asset,
+			Destination: dest,
+		})
+	}
+
+	return ops, nil
+}
+
+// accountName returns the name of the wallet or contact owning addr, or a shortened address
+func accountName(m *wallet.Alfred, addr string) string {
+	if name, ok := m.AccountName(addr); ok {
+		return name
+	}
+	return wallet.TrimAddress(addr)
+}
+
+// accountLabel returns the name of the wallet or contact owning addr followed
+// by the shortened address, such as jennifer (GABCD...VWXYZ), or the shortened
+// address alone
+func accountLabel(m *wallet.Alfred, addr string) string {
+	if name, ok := m.AccountName(addr); ok {
+		return fmt.Sprintf("%s (%s)", name, wallet.TrimAddress(addr))
+	}
+	return wallet.TrimAddress(addr)
+}
+
+func assetName(a xdr.Asset) string {
+	var typ xdr.AssetType
+	var code, issuer string
+	if err := a.Extract(&typ, &code, &issuer); err != nil || typ == xdr.AssetTypeAssetTypeNative {
+		return "XLM"
+	}
+
+	return code
+}
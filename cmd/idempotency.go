@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/viper"
+)
+
+// previousSubmissions returns the transactions of the log of m already
+// submitted on the current network with the key of --idempotency-key, none
+// without a key
+func previousSubmissions(m *wallet.Alfred) []wallet.LogEntry {
+	key := viper.GetString("idempotency-key")
+	if m == nil || key == "" {
+		return nil
+	}
+	return m.LogByIdempotencyKey(currentNetwork().Name, key)
+}
+
+// pendingSubmission returns the transaction of m pending on the current
+// network with the key of --idempotency-key, nil if there is none
+func pendingSubmission(m *wallet.Alfred) *wallet.PendingTx {
+	key := viper.GetString("idempotency-key")
+	if m == nil || key == "" {
+		return nil
+	}
+
+	network := currentNetwork().Name
+	for i, p := range m.Stellar.Pending {
+		if p.Network == network && p.IdempotencyKey == key {
+			return &m.Stellar.Pending[i]
+		}
+	}
+	return nil
+}
+
+// printPreviousSubmissions prints the hashes of entries, submitted by a
+// previous run with the same idempotency key, instead of submitting again
+func printPreviousSubmissions(entries []wallet.LogEntry) {
+	fmt.Fprintf(os.Stderr, "Already submitted on %s with the idempotency key %s, nothing is submitted again\n", entries[0].Time.Format("2006-01-02 15:04:05"), viper.GetString("idempotency-key"))
+	for _, e := range entries {
+		fmt.Println(e.Hash)
+		printExplorerLinks(e.Hash)
+	}
+}
@@ -15,12 +15,12 @@
 package cmd
 
 import (
-	"fmt"
 	"log"
 	"strings"
 
 	"github.com/stellar/go/keypair"
 
+	"github.com/celrenheit/alfred/i18n"
 	"github.com/celrenheit/alfred/wallet"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
@@ -36,13 +36,17 @@ var importCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		validate := func(input string) error {
 			if !strings.HasPrefix(input, "S") {
-				return fmt.Errorf("this should be a private key")
+				return i18n.Errorf("this should be a private key")
 			}
 			return nil
 		}
 
+		if err := checkInteractive("seed", "the seed of the wallet is prompted", ""); err != nil {
+			fatal(err)
+		}
+
 		prompt := promptui.Prompt{
-			Label:    "What is the seed address ?",
+			Label:    i18n.T("What is the seed address ?"),
 			Validate: validate,
 			Mask:     '*',
 		}
@@ -53,13 +57,6 @@ var importCmd = &cobra.Command{
 			return
 		}
 
-		path := viper.GetString("db")
-		secret := viper.GetString("secret")
-		m, err := wallet.OpenSecretString(path, secret)
-		if err != nil {
-			fatal(err)
-		}
-
 		kp, err := keypair.Parse(seed)
 		if err != nil {
 			fatal(err)
@@ -75,12 +72,9 @@ var importCmd = &cobra.Command{
 		}
 
 		name := cmd.Flag("name").Value.String()
-		err = m.AddWallet(wallet.New(name, kpFull))
-		if err != nil {
-			fatal(err)
-		}
-
-		if err := wallet.Write(path, m); err != nil {
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			return m.AddWallet(wallet.New(name, kpFull))
+		}); err != nil {
 			fatal(err)
 		}
 	},
@@ -0,0 +1,311 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/qr"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// invoiceLookback is the number of past transactions of the wallet searched
+// for the payment of an invoice
+const invoiceLookback = 200
+
+// invoiceCmd represents the invoice command
+var invoiceCmd = &cobra.Command{
+	Use:   "invoice",
+	Short: "List the payments requested with alfred invoice create",
+	Long: `List the payments requested with alfred invoice create and whether they
+were paid, as last checked by alfred invoice status.`,
+	Example: `alfred invoice create 120 USDC to master note "design work"
+alfred invoice
+alfred invoice status 1 --wait`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		network := currentNetwork().Name
+		table := newTable(os.Stdout)
+		table.SetHeader(header("ID", "Wallet", "Amount", "Note", "Memo", "Created", "Status"))
+		found := false
+		for _, inv := range m.Stellar.Invoices {
+			if inv.Network != network {
+				continue
+			}
+			found = true
+			table.Append([]string{
+				strconv.Itoa(inv.ID),
+				inv.Wallet,
+				inv.Amount + " " + inv.Code,
+				inv.Note,
+				inv.Memo,
+				inv.Created.Local().Format(time.RFC822),
+				invoiceStatus(inv),
+			})
+		}
+		if !found {
+			fmt.Println("No invoice on", network)
+			return
+		}
+		table.Render()
+	},
+}
+
+// invoiceCreateCmd represents the invoice create command
+var invoiceCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Request a payment to a wallet",
+	Long: `Request a payment to a wallet, as a SEP-7 payment URI and its QR code that
+wallets can pay in one go.
+
+  <amount> <asset> TO <wallet> [NOTE <text>]
+
+The payment is told apart from the others by a text memo unique to the
+invoice, the note is only shown to the payer. alfred invoice status checks
+whether it was paid.`,
+	Example: `alfred invoice create 120 USDC to master note "design work"
+alfred invoice create 50 XLM to master --small`,
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		req, err := parser.ParseInvoice(strings.Join(args, " "))
+		if err != nil {
+			fatal(err)
+		}
+		logStatement(req)
+
+		path := viper.GetString("db")
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		kp, err := getOrSelectWallet(m, req.To)
+		if err != nil {
+			fatal(err)
+		}
+
+		asset, err := selectAsset(req.Currency)
+		if err != nil {
+			fatal(err)
+		}
+
+		memo, err := invoiceMemo()
+		if err != nil {
+			fatal(err)
+		}
+
+		inv := wallet.Invoice{
+			Network: currentNetwork().Name,
+			Wallet:  m.WalletByAddress(kp.Address()).Name,
+			Address: kp.Address(),
+			Amount:  req.Amount,
+			Code:    asset.CodeString(),
+			Issuer:  asset.BuilderAsset.Issuer,
+			Memo:    memo,
+			Note:    req.Note,
+			Created: time.Now().UTC(),
+		}
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			inv.ID = m.AddInvoice(inv)
+			return nil
+		}); err != nil {
+			fatal(err)
+		}
+
+		uri := assetPayURI(inv.Address, inv.Amount, asset, inv.Memo, inv.Note)
+		code, err := qr.Encode(uri, qr.M)
+		if err != nil {
+			fatal(err)
+		}
+
+		if small, _ := cmd.Flags().GetBool("small"); small {
+			fmt.Print(code.String())
+		} else if err := code.ANSI(os.Stdout); err != nil {
+			fatal(err)
+		}
+		fmt.Println(uri)
+		fmt.Println()
+
+		summary := fmt.Sprintf("Invoice %d: please send %s %s to %s with the memo %s", inv.ID, inv.Amount, inv.Code, inv.Address, inv.Memo)
+		if inv.Note != "" {
+			summary += fmt.Sprintf(", for %s", inv.Note)
+		}
+		fmt.Println(summary)
+		fmt.Println("Check the payment with: alfred invoice status", inv.ID)
+	},
+}
+
+// invoiceStatusCmd represents the invoice status command
+var invoiceStatusCmd = &cobra.Command{
+	Use:   "status <id>",
+	Short: "Check whether an invoice was paid",
+	Long: `Look for the payment of an invoice in the latest transactions of its wallet,
+a payment of the exact amount and asset with the memo of the invoice. The
+invoice is marked as paid once it is found.
+
+With --wait, the transactions of the wallet are watched until the payment
+arrives.`,
+	Example: `alfred invoice status 1
+alfred invoice status 1 --wait`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			fatalf("invalid invoice id '%s'", args[0])
+		}
+		inv, err := m.Invoice(id)
+		if err != nil {
+			fatal(err)
+		}
+		if network := currentNetwork().Name; inv.Network != network {
+			fatalf("invoice %d is on %s, not on %s", inv.ID, inv.Network, network)
+		}
+
+		client := getClient(currentNetwork())
+		if inv.Paid == nil {
+			txs, err := loadTransactions(client, inv.Address, invoiceLookback)
+			if err != nil {
+				fatal(err)
+			}
+			for _, tx := range txs {
+				if payInvoice(m, inv, tx) {
+					break
+				}
+			}
+		}
+
+		if wait, _ := cmd.Flags().GetBool("wait"); wait && inv.Paid == nil {
+			fmt.Printf("Waiting for %s %s with the memo %s...\n", inv.Amount, inv.Code, inv.Memo)
+			if err := waitInvoice(m, client, inv); err != nil {
+				fatal(err)
+			}
+		}
+
+		if inv.Paid != nil {
+			if _, err := updateDB(func(m *wallet.Alfred) error {
+				stored, err := m.Invoice(inv.ID)
+				if err != nil {
+					return err
+				}
+				stored.MarkPaid(inv.Transaction, *inv.Paid)
+				return nil
+			}); err != nil {
+				fatal(err)
+			}
+		}
+
+		fmt.Printf("Invoice %d: %s\n", inv.ID, invoiceStatus(*inv))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(invoiceCmd)
+	invoiceCmd.AddCommand(invoiceCreateCmd)
+	invoiceCmd.AddCommand(invoiceStatusCmd)
+
+	invoiceCreateCmd.Flags().Bool("small", false, "render using unicode blocks instead of colors")
+	invoiceStatusCmd.Flags().Bool("wait", false, "watch the wallet until the invoice is paid")
+}
+
+// invoiceMemo returns a random text memo identifying the payment of an invoice
+func invoiceMemo() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "inv-" + hex.EncodeToString(b), nil
+}
+
+// payInvoice marks inv as paid if tx pays it, and reports whether it does
+func payInvoice(m *wallet.Alfred, inv *wallet.Invoice, tx horizon.Transaction) bool {
+	payments, err := incomingPayments(m, tx, inv.Address)
+	if err != nil {
+		return false
+	}
+
+	for _, p := range payments {
+		if inv.Matches(p.Amount, p.Asset, p.Issuer, p.Memo) {
+			inv.MarkPaid(tx.Hash, tx.LedgerCloseTime)
+			return true
+		}
+	}
+
+	return false
+}
+
+// waitInvoice streams the transactions of the wallet of inv until one pays
+// it, or until Ctrl-C
+func waitInvoice(m *wallet.Alfred, client *horizon.Client, inv *wallet.Invoice) error {
+	ctx, cancel := context.WithCancel(rootContext)
+	defer cancel()
+
+	cursor := horizon.Cursor("now")
+	for {
+		err := client.StreamTransactions(ctx, inv.Address, &cursor, func(tx horizon.Transaction) {
+			cursor = horizon.Cursor(tx.PagingToken)
+			if inv.Paid == nil && payInvoice(m, inv, tx) {
+				cancel()
+			}
+		})
+		if inv.Paid != nil {
+			return nil
+		}
+		if rootContext.Err() != nil {
+			return rootContext.Err()
+		}
+		if err != nil {
+			fmt.Println("Stream interrupted:", describeHorizonError(err))
+		}
+
+		select {
+		case <-rootContext.Done():
+			return rootContext.Err()
+		case <-time.After(streamRetryDelay):
+		}
+	}
+}
+
+func invoiceStatus(inv wallet.Invoice) string {
+	if inv.Paid == nil {
+		return "open"
+	}
+
+	return fmt.Sprintf("paid %s by %s", inv.Paid.Local().Format(time.RFC822), inv.Transaction[:8])
+}
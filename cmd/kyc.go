@@ -0,0 +1,144 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/celrenheit/alfred/anchor"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// kycCmd represents the kyc command
+var kycCmd = &cobra.Command{
+	Use:   "kyc <domain> [wallet]",
+	Short: "Send the information needed by an anchor about you",
+	Long: `Send the information an anchor needs about the owner of a wallet (SEP-12),
+such as a name or an identity document, before processing deposits and withdrawals.
+
+The fields still needed are prompted, documents are given as the path of a file.
+The answers are stored encrypted in the database and suggested the next time.`,
+	Example: `alfred kyc anchor.com master
+alfred kyc anchor.com master --type sep6-withdraw`,
+	Args:    cobra.RangeArgs(1, 2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		var from string
+		if len(args) > 1 {
+			from = args[1]
+		}
+		kp, err := getOrSelectWallet(m, from)
+		if err != nil {
+			fatal(err)
+		}
+
+		a, err := anchor.Discover(anchorHTTP, args[0])
+		if err != nil {
+			fatal(err)
+		}
+
+		jwt, err := anchorToken(m, a, kp, false)
+		if err != nil {
+			fatal(err)
+		}
+
+		typ, _ := cmd.Flags().GetString("type")
+		customer, err := collectKYC(m, a, kp, jwt, typ)
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Printf("%s status: %s\n", a.Domain, firstNonEmpty(customer.Status, "unknown"))
+		if customer.Message != "" {
+			fmt.Println(customer.Message)
+		}
+	},
+}
+
+// collectKYC prompts the fields the anchor still needs about the owner of kp
+// and sends them. The answers are stored in the database.
+func collectKYC(m *wallet.Alfred, a *anchor.Anchor, kp walletKey, jwt, typ string) (*anchor.Customer, error) {
+	customer, err := a.Customer(jwt, kp.Address(), typ)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(customer.Fields) == 0 {
+		return customer, nil
+	}
+
+	var names []string
+	for name := range customer.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stored := m.KYCAnswers(kp.Address())
+	answers := make(map[string]string)
+	values := make(map[string]string)
+	files := make(map[string]string)
+	for _, name := range names {
+		field := customer.Fields[name]
+		value := stored[name]
+		if interactive {
+			value, err = promptField(name, field, stored[name])
+			if err != nil {
+				return nil, err
+			}
+		} else if value == "" && !field.Optional {
+			return nil, checkInteractive("field", "the anchor needs "+name, "an answer stored by running alfred kyc in a terminal")
+		}
+
+		if value == "" {
+			continue
+		}
+
+		answers[name] = value
+		if field.Type == "binary" {
+			files[name] = value
+		} else {
+			values[name] = value
+		}
+	}
+
+	if _, err := a.PutCustomer(jwt, kp.Address(), typ, values, files); err != nil {
+		return nil, err
+	}
+
+	m.SetKYCAnswers(kp.Address(), answers)
+	if _, err := updateDB(func(m *wallet.Alfred) error {
+		m.SetKYCAnswers(kp.Address(), answers)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// the anchor only returns the id of the customer, fetch the new status
+	return a.Customer(jwt, kp.Address(), typ)
+}
+
+func init() {
+	RootCmd.AddCommand(kycCmd)
+
+	kycCmd.Flags().String("type", "", "kind of customer expected by the anchor, such as sep6-withdraw")
+}
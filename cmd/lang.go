@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/spf13/viper"
+)
+
+// languages returns the languages of --lang, sorted: the ones of the messages
+// and the ones of the keywords of please
+func languages() []string {
+	seen := make(map[string]bool)
+	var langs []string
+	for _, lang := range append(i18n.Languages(), parser.Locales()...) {
+		if !seen[lang] {
+			seen[lang] = true
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// language returns the language of --lang, of the lang key of the config
+// file or, without them, the one of the LC_ALL, LC_MESSAGES and LANG
+// environment variables
+func language() string {
+	if f := RootCmd.PersistentFlags().Lookup("lang"); f != nil && f.Changed {
+		return i18n.Normalize(f.Value.String())
+	}
+	if viper.InConfig("lang") {
+		return i18n.Normalize(viper.GetString("lang"))
+	}
+	return i18n.FromEnv(os.Getenv)
+}
+
+// useLanguage translates the messages into the language, or leaves them in
+// English when only the keywords of please are translated into it
+func useLanguage() error {
+	lang := language()
+	for _, known := range languages() {
+		if known != lang {
+			continue
+		}
+		if !i18n.Supported(lang) {
+			lang = i18n.English
+		}
+		return i18n.Use(lang)
+	}
+	return i18n.Errorf("unknown language '%s', should be one of %s", lang, strings.Join(languages(), ", "))
+}
@@ -0,0 +1,161 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// logCmd represents the log command
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Display the transactions submitted by alfred",
+	Long: `Display the transactions submitted by alfred, as recorded in the database.
+
+Every entry is chained to the previous one, use alfred log verify to check that
+the log was not modified and that each transaction is in the ledger.`,
+	Example: `alfred log
+alfred log --limit 50
+alfred log verify`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		entries := m.Stellar.Log
+		if limit, _ := cmd.Flags().GetInt("limit"); limit > 0 && len(entries) > limit {
+			entries = entries[len(entries)-limit:]
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Date", "Network", "Transaction", "Operations", "Destinations"))
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+
+			var dests []string
+			for _, d := range e.Destinations {
+				dests = append(dests, accountName(m, d))
+			}
+
+			table.Append([]string{
+				e.Time.Local().Format(time.RFC822),
+				e.Network,
+				e.Hash,
+				strings.Join(e.Operations, ", "),
+				strings.Join(dests, ", "),
+			})
+		}
+		table.Render()
+	},
+}
+
+// logVerifyCmd represents the log verify command
+var logVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the log against horizon",
+	Long: `Check that the log was not modified and that every transaction it records
+is in the ledger with the same envelope.`,
+	Example: "alfred log verify",
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		failed := false
+		if _, err := m.VerifyLog(); err != nil {
+			fmt.Println(err)
+			failed = true
+		}
+
+		clients := make(map[string]*horizon.Client)
+		for _, n := range knownNetworks {
+			clients[n.Name] = getClient(n)
+		}
+		if n := currentNetwork(); clients[n.Name] == nil {
+			clients[n.Name] = getClient(n)
+		}
+
+		for _, e := range m.Stellar.Log {
+			client, ok := clients[e.Network]
+			if !ok {
+				fmt.Printf("%s: unknown network '%s'\n", e.Hash, e.Network)
+				failed = true
+				continue
+			}
+
+			if err := verifyLogEntry(client, e); err != nil {
+				fmt.Printf("%s: %v\n", e.Hash, err)
+				failed = true
+				continue
+			}
+
+			fmt.Printf("%s: ok\n", e.Hash)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(logCmd)
+	logCmd.AddCommand(logVerifyCmd)
+
+	logCmd.Flags().Int("limit", 0, "number of transactions to display, the most recent first (0 for all)")
+}
+
+// verifyLogEntry checks that the transaction of e is in the ledger with the same envelope
+func verifyLogEntry(client *horizon.Client, e wallet.LogEntry) error {
+	resp, err := client.HTTP.Get(strings.TrimRight(client.URL, "/") + "/transactions/" + e.Hash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return i18n.Errorf("not found on the %s network", e.Network)
+	default:
+		return i18n.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var tx horizon.Transaction
+	if err := json.NewDecoder(resp.Body).Decode(&tx); err != nil {
+		return err
+	}
+
+	if tx.EnvelopeXdr != e.Envelope {
+		return i18n.Errorf("the envelope in the ledger differs from the recorded one")
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/celrenheit/alfred/httplog"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// logger writes the logs of alfred on stderr. With --verbose, they show the
+// horizon server serving each request, the statements parsed and the
+// transactions before they are signed. With --debug-http, they also hold the
+// requests and responses exchanged with horizon, secrets redacted.
+var logger = logrus.New()
+
+// initLogging sets the level of logger from --verbose and --debug-http
+func initLogging() {
+	logger.Out = os.Stderr
+	logger.Level = logrus.WarnLevel
+	switch {
+	case viper.GetBool("debug-http"):
+		logger.Level = logrus.DebugLevel
+	case viper.GetBool("verbose"):
+		logger.Level = logrus.InfoLevel
+	}
+}
+
+// logStatement logs the syntax tree of a statement parsed
+func logStatement(statement interface{}) {
+	logger.WithFields(logrus.Fields{
+		"type": fmt.Sprintf("%T", statement),
+		"ast":  fmt.Sprintf("%+v", statement),
+	}).Info("statement parsed")
+}
+
+// logUnsigned logs the envelope of a transaction before it is signed
+func logUnsigned(unsigned string) {
+	logger.WithField("xdr", unsigned).Info("transaction built")
+}
+
+// logHTTP logs the dump of a request exchanged with horizon, or of its
+// response when resp is set
+func logHTTP(req *http.Request, resp *http.Response, dump string) {
+	entry := logger.WithFields(logrus.Fields{
+		"method": req.Method,
+		"url":    httplog.Redact(req.URL.String()),
+		"dump":   dump,
+	})
+	if resp == nil {
+		entry.Debug("horizon request")
+		return
+	}
+	entry.WithField("status", resp.StatusCode).Debug("horizon response")
+}
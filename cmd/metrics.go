@@ -0,0 +1,223 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celrenheit/alfred/metrics"
+	"github.com/celrenheit/alfred/tx"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// balancesInterval is the delay between two refreshes of the balances exposed as metrics
+const balancesInterval = time.Minute
+
+var (
+	registry = metrics.NewRegistry()
+
+	metricBalance = registry.Gauge("alfred_balance",
+		"Balance of an asset held by a wallet.", "wallet", "asset", "issuer")
+	metricHorizonLatency = registry.Histogram("alfred_horizon_request_duration_seconds",
+		"Duration of the requests to horizon until their response, streams excluded.", metrics.DefBuckets, "method", "endpoint", "status")
+	metricSubmissions = registry.Counter("alfred_submissions_total",
+		"Transactions submitted to the network, by result.", "result")
+	metricStreamReconnects = registry.Counter("alfred_stream_reconnects_total",
+		"Connections to a stream of horizon after the first one.", "stream")
+)
+
+// addMetricsFlag adds the flag serving the metrics of cmd
+func addMetricsFlag(cmd *cobra.Command) {
+	cmd.Flags().String("metrics", "", "address serving Prometheus metrics on /metrics, such as localhost:9200")
+}
+
+// startMetrics serves the metrics if the metrics flag of cmd is set. The
+// requests of client are measured and the balances of the wallets returned by
+// wallets, by name, are refreshed periodically.
+// It should be called before client is used by other goroutines.
+func startMetrics(cmd *cobra.Command, client *horizon.Client, wallets func() (map[string]string, error)) {
+	addr, _ := cmd.Flags().GetString("metrics")
+	if addr == "" {
+		return
+	}
+
+	servers := client.HTTP.(contextHTTP).servers
+	next := servers.HTTP.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	servers.HTTP.Transport = &measuredTransport{next: next, streams: make(map[string]bool)}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fatal(err)
+		}
+	}()
+
+	go func() {
+		for {
+			names, err := wallets()
+			if err != nil {
+				fmt.Println("metrics:", err)
+			}
+			for name, address := range names {
+				balances.refresh(client, name, address)
+			}
+			time.Sleep(balancesInterval)
+		}
+	}()
+
+	fmt.Printf("Serving metrics on %s/metrics\n", addr)
+}
+
+// allWallets returns the wallets of the database, by name
+func allWallets() (map[string]string, error) {
+	m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+	if err != nil {
+		return nil, err
+	}
+
+	wallets := make(map[string]string)
+	for _, w := range m.Stellar.Wallets {
+		wallets[w.Name] = w.Keypair.Address()
+	}
+	return wallets, nil
+}
+
+// balances are the balances exposed as metrics, the assets no longer held are removed
+var balances = &balanceMetrics{assets: make(map[string][][2]string)}
+
+type balanceMetrics struct {
+	mu sync.Mutex
+	// assets are the code and issuer of the assets exposed for each wallet
+	assets map[string][][2]string
+}
+
+func (b *balanceMetrics) refresh(client *horizon.Client, name, address string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	acc, exists, err := loadAccount(client, address)
+	if err != nil {
+		fmt.Println("metrics:", describeHorizonError(err))
+		return
+	}
+
+	var held [][2]string
+	if exists {
+		for _, balance := range acc.Balances {
+			code := balance.Asset.Code
+			if balance.Asset.Type == "native" {
+				code = "XLM"
+			}
+
+			f, err := strconv.ParseFloat(balance.Balance, 64)
+			if err != nil {
+				continue
+			}
+			metricBalance.Set(f, name, code, balance.Asset.Issuer)
+			held = append(held, [2]string{code, balance.Asset.Issuer})
+		}
+	}
+
+	for _, asset := range b.assets[name] {
+		if !containsAsset(held, asset) {
+			metricBalance.Delete(name, asset[0], asset[1])
+		}
+	}
+	b.assets[name] = held
+}
+
+func containsAsset(list [][2]string, asset [2]string) bool {
+	for _, a := range list {
+		if a == asset {
+			return true
+		}
+	}
+	return false
+}
+
+// measuredSubmitter counts the submissions of the transactions by result
+type measuredSubmitter struct {
+	tx.Submitter
+}
+
+func (s measuredSubmitter) SubmitTransaction(txeB64 string) (horizon.TransactionSuccess, error) {
+	resp, err := s.Submitter.SubmitTransaction(txeB64)
+	countSubmission(err)
+	return resp, err
+}
+
+func countSubmission(err error) {
+	if err != nil {
+		metricSubmissions.Inc("failure")
+		return
+	}
+	metricSubmissions.Inc("success")
+}
+
+// measuredTransport measures the requests to horizon, a stream requested again
+// is counted as a reconnection
+type measuredTransport struct {
+	next    http.RoundTripper
+	mu      sync.Mutex
+	streams map[string]bool
+}
+
+func (t *measuredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointName(req.URL.Path)
+	if req.Header.Get("Accept") == "text/event-stream" {
+		t.mu.Lock()
+		if t.streams[endpoint] {
+			metricStreamReconnects.Inc(endpoint)
+		}
+		t.streams[endpoint] = true
+		t.mu.Unlock()
+
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	metricHorizonLatency.Observe(time.Since(start).Seconds(), req.Method, endpoint, status)
+
+	return resp, err
+}
+
+// endpointName replaces the addresses, hashes and ids of a path by {id}, so
+// that there is a series per endpoint rather than per account
+func endpointName(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range parts {
+		if _, err := strconv.ParseUint(part, 10, 64); err == nil || len(part) >= 32 {
+			parts[i] = "{id}"
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
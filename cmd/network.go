@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/network"
+)
+
+const (
+	publicNetwork    = "public"
+	testNetwork      = "testnet"
+	futureNetwork    = "futurenet"
+	customNetworkTag = "custom:"
+
+	futureNetworkPassphrase = "Test SDF Future Network ; October 2022"
+)
+
+// stellarNetwork is a network alfred submits transactions to
+type stellarNetwork struct {
+	// Name is stored in the log of the transactions submitted to it
+	Name       string
+	Passphrase string
+	// Horizon is the horizon server used without --horizon, there is none for
+	// a custom network
+	Horizon string
+}
+
+var knownNetworks = []stellarNetwork{
+	{Name: publicNetwork, Passphrase: network.PublicNetworkPassphrase, Horizon: horizon.DefaultPublicNetClient.URL},
+	{Name: testNetwork, Passphrase: network.TestNetworkPassphrase, Horizon: horizon.DefaultTestNetClient.URL},
+	{Name: futureNetwork, Passphrase: futureNetworkPassphrase, Horizon: "https://horizon-futurenet.stellar.org"},
+}
+
+// parseNetwork returns the network called name: public, testnet, futurenet
+// or custom:<passphrase> for a standalone network
+func parseNetwork(name string) (stellarNetwork, error) {
+	if strings.HasPrefix(name, customNetworkTag) {
+		passphrase := strings.TrimPrefix(name, customNetworkTag)
+		if passphrase == "" {
+			return stellarNetwork{}, i18n.Errorf("the passphrase of the custom network should be given, such as %s'Standalone Network ; February 2017'", customNetworkTag)
+		}
+		for _, n := range knownNetworks {
+			if n.Passphrase == passphrase {
+				return n, nil
+			}
+		}
+		return stellarNetwork{Name: name, Passphrase: passphrase}, nil
+	}
+
+	for _, n := range knownNetworks {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return stellarNetwork{}, i18n.Errorf("unknown network '%s', it should be public, testnet, futurenet or %s<passphrase>", name, customNetworkTag)
+}
+
+// currentNetwork returns the network of --network, testnet with the
+// deprecated --testnet and public by default
+func currentNetwork() stellarNetwork {
+	name := viper.GetString("network")
+	if name == "" {
+		name = publicNetwork
+		if viper.GetBool("testnet") {
+			name = testNetwork
+		}
+	}
+
+	n, err := parseNetwork(name)
+	if err != nil {
+		fatal(err)
+	}
+	if n.Horizon == "" && len(viper.GetStringSlice("horizon")) == 0 {
+		fatalf("the horizon servers of the %s network should be given with --horizon", n.Name)
+	}
+	return n
+}
+
+// isTestNetwork reports whether the lumens of n have no value, friendbot
+// funding its accounts
+func (n stellarNetwork) isTestNetwork() bool { return n.Name != publicNetwork }
+
+// mutator returns the mutator setting the network of a transaction to n
+func (n stellarNetwork) mutator() build.Network {
+	return build.Network{Passphrase: n.Passphrase}
+}
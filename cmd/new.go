@@ -23,8 +23,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/celrenheit/alfred/i18n"
 	"github.com/manifoldco/promptui"
-	"github.com/olekukonko/tablewriter"
 
 	"golang.org/x/sync/errgroup"
 
@@ -48,13 +48,6 @@ var newCmd = &cobra.Command{
 		case len(args) > 1:
 			fmt.Printf("too many arguments '%v'\n", args)
 		case len(args) == 0 || args[0] == "wallet":
-			path := viper.GetString("db")
-			secret := viper.GetString("secret")
-			m, err := wallet.OpenSecretString(path, secret)
-			if err != nil {
-				fatal("error opening backup:", err)
-			}
-
 			prefix := strings.ToUpper(viper.GetString("prefix"))
 			suffix := strings.ToUpper(viper.GetString("suffix"))
 			kp, err := generateKP(prefix, suffix)
@@ -63,34 +56,28 @@ var newCmd = &cobra.Command{
 			}
 
 			name := cmd.Flag("name").Value.String()
-			w := wallet.New(name, kp)
-			err = m.AddWallet(w)
-			if err != nil {
+			if _, err := updateDB(func(m *wallet.Alfred) error {
+				return m.AddWallet(wallet.New(name, kp))
+			}); err != nil {
 				fatal("error opening backup:", err)
 			}
 
 			if viper.GetBool("print-seed") {
-				table := tablewriter.NewWriter(os.Stdout)
-				table.SetHeader([]string{"Address", "Seed"})
+				table := newTable(os.Stdout)
+				table.SetHeader(header("Address", "Seed"))
 				table.Append([]string{kp.Address(), kp.Seed()})
 				table.Render()
 			}
-
-			if err := wallet.Write(path, m); err != nil {
-				fatal("error opening backup:", err)
-			}
+			copyValue("address", kp.Address())
 		case args[0] == "contact":
-			path := viper.GetString("db")
-			secret := viper.GetString("secret")
-			m, err := wallet.OpenSecretString(path, secret)
-			if err != nil {
-				fatal("error opening backup:", err)
-			}
-
 			name := cmd.Flag("name").Value.String()
 			if name == "" {
+				if err := checkInteractive("name", "no name given", "--name"); err != nil {
+					fatal(err)
+				}
+
 				prompt := promptui.Prompt{
-					Label: "Name of the contact",
+					Label: i18n.T("Name of the contact"),
 					Validate: func(input string) error {
 						if len(input) == 0 {
 							return errors.New("should not be empty")
@@ -99,14 +86,19 @@ var newCmd = &cobra.Command{
 						return nil
 					},
 				}
+				var err error
 				name, err = prompt.Run()
 				if err != nil {
 					fatal(err)
 				}
 			}
 
+			if err := checkInteractive("address", "the address of the contact is prompted", ""); err != nil {
+				fatal(err)
+			}
+
 			prompt := promptui.Prompt{
-				Label: "Contact's address",
+				Label: i18n.T("Contact's address"),
 				Validate: func(input string) error {
 					if !strings.HasPrefix(input, "G") {
 						return errors.New("length be greater than 8")
@@ -125,14 +117,9 @@ var newCmd = &cobra.Command{
 				fatal(err)
 			}
 
-			err = m.AddContact(name, addr, memo)
-			if err != nil {
-				fatal("error opening backup:", err)
-			}
-
-			m.Unlock([]byte(secret))
-
-			if err := wallet.Write(path, m); err != nil {
+			if _, err := updateDB(func(m *wallet.Alfred) error {
+				return m.AddContact(name, addr, memo)
+			}); err != nil {
 				fatal("error opening backup:", err)
 			}
 		default:
@@ -143,7 +130,7 @@ var newCmd = &cobra.Command{
 
 func promptMemo() (memo *wallet.Memo, err error) {
 	sl := promptui.Select{
-		Label: "Memo",
+		Label: i18n.T("Memo"),
 		Items: []string{
 			"None",
 			"MEMO_TEXT",
@@ -160,7 +147,7 @@ func promptMemo() (memo *wallet.Memo, err error) {
 	if idx > 0 {
 		kind := wallet.MemoKind(idx)
 		prompt := promptui.Prompt{
-			Label: "Memo",
+			Label: i18n.T("Memo"),
 			Validate: func(input string) error {
 				_, err := wallet.MemoFromString(kind, input)
 				return err
@@ -0,0 +1,266 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// offersPageSize is the number of offers loaded per request
+const offersPageSize = 200
+
+// offersCmd represents the offers command
+var offersCmd = &cobra.Command{
+	Use:   "offers",
+	Short: "Display the open offers of a wallet",
+	Long: `Display the offers of a wallet on the order book.
+
+Offers created with EXPIRES IN show the time left before alfred daemon cancels
+them, the network itself never expires an offer.`,
+	Example: `alfred offers master
+alfred please sell 100 MOBI at 0.5 for XLM expires in 24h with master`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, address := openAccountArg(args)
+
+		client := getClient(currentNetwork())
+		offers, err := loadOffers(client, address)
+		if err != nil {
+			fatal(err)
+		}
+
+		if len(offers) == 0 {
+			fmt.Println("No open offer")
+			return
+		}
+
+		network := currentNetwork().Name
+		table := newTable(os.Stdout)
+		table.SetHeader(header("ID", "Selling", "Buying", "Amount", "Price", "Expires"))
+		table.SetColumnAlignment(amountColumns(6, 3, 4))
+		for _, o := range offers {
+			expires := "never"
+			if t, ok := m.OfferExpiry(network, o.ID); ok {
+				expires = describeExpiry(t, time.Now())
+			}
+
+			table.Append([]string{strconv.FormatInt(o.ID, 10), describeHorizonAsset(m, o.Selling), describeHorizonAsset(m, o.Buying),
+				formatAmount(o.Amount, horizonAssetCode(o.Selling)), formatAmount(o.Price, ""), expires})
+		}
+		table.Render()
+	},
+}
+
+// loadOffers loads every offer of account
+func loadOffers(client *horizon.Client, account string) ([]horizon.Offer, error) {
+	var (
+		offers []horizon.Offer
+		params = []interface{}{horizon.Limit(offersPageSize)}
+	)
+	for {
+		page, err := client.LoadAccountOffers(account, params...)
+		if err != nil {
+			return nil, err
+		}
+
+		records := page.Embedded.Records
+		offers = append(offers, records...)
+		if len(records) < offersPageSize {
+			return offers, nil
+		}
+		params = []interface{}{horizon.Limit(offersPageSize), horizon.Cursor(records[len(records)-1].PT)}
+	}
+}
+
+// offerIDs returns the ids of the offers of account
+func offerIDs(client *horizon.Client, account string) (map[int64]bool, error) {
+	offers, err := loadOffers(client, account)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[int64]bool)
+	for _, o := range offers {
+		ids[o.ID] = true
+	}
+	return ids, nil
+}
+
+// trackOffers records the expiry of the offers of account that are not in
+// known, created by the transaction just submitted. Nothing is tracked when
+// the offer was filled at once.
+func trackOffers(m *wallet.Alfred, client *horizon.Client, account string, known map[int64]bool, expiresIn time.Duration) error {
+	offers, err := loadOffers(client, account)
+	if err != nil {
+		return err
+	}
+
+	w := m.WalletByAddress(account)
+	expires := time.Now().Add(expiresIn).UTC()
+	var tracked []wallet.ExpiringOffer
+	for _, o := range offers {
+		if known[o.ID] {
+			continue
+		}
+
+		e := wallet.ExpiringOffer{
+			ID:      o.ID,
+			Network: currentNetwork().Name,
+			Wallet:  w.Name,
+			Expires: expires,
+		}
+		m.TrackOffer(e)
+		tracked = append(tracked, e)
+		fmt.Printf("Offer %d expires %s, it is cancelled by alfred daemon\n", o.ID, expires.Local().Format(time.RFC1123))
+	}
+
+	if len(tracked) == 0 {
+		fmt.Println("The offer was filled at once, there is nothing to cancel")
+		return nil
+	}
+
+	_, err = updateDB(func(m *wallet.Alfred) error {
+		for _, e := range tracked {
+			m.TrackOffer(e)
+		}
+		return nil
+	})
+	return err
+}
+
+// cancelExpiredOffers cancels the offers created with EXPIRES IN that expired at now.
+// The offers no longer on the order book, filled or cancelled by hand, are forgotten.
+func cancelExpiredOffers(client *horizon.Client, now time.Time) error {
+	m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+	if err != nil {
+		return err
+	}
+
+	network := currentNetwork().Name
+	expired := m.ExpiredOffers(network, now)
+	if len(expired) == 0 {
+		return nil
+	}
+
+	var cancelled []int64
+	for _, e := range expired {
+		if err := cancelOffer(m, client, e); err != nil {
+			fmt.Printf("offer %d: %s\n", e.ID, describeHorizonError(err))
+			continue
+		}
+		cancelled = append(cancelled, e.ID)
+	}
+	if len(cancelled) == 0 {
+		return nil
+	}
+
+	_, err = updateDB(func(m *wallet.Alfred) error {
+		for _, id := range cancelled {
+			m.ForgetOffer(network, id)
+		}
+		return nil
+	})
+	return err
+}
+
+// cancelOffer deletes the offer e from the order book, if it is still there
+func cancelOffer(m *wallet.Alfred, client *horizon.Client, e wallet.ExpiringOffer) error {
+	w := m.WalletByName(e.Wallet)
+	if w == nil {
+		return i18n.Errorf("wallet '%s' not found", e.Wallet)
+	}
+	src, err := hotKey(w)
+	if err != nil {
+		return err
+	}
+
+	offers, err := loadOffers(client, src.Address())
+	if err != nil {
+		return err
+	}
+
+	for _, o := range offers {
+		if o.ID != e.ID {
+			continue
+		}
+
+		opts := []build.TransactionMutator{
+			build.SourceAccount{src.Seed()},
+			build.AutoSequence{SequenceProvider: client},
+			build.DeleteOffer(build.Rate{
+				Selling: builderAsset(o.Selling),
+				Buying:  builderAsset(o.Buying),
+				Price:   build.Price(o.Price),
+			}, build.OfferID(o.ID)),
+		}
+		opts = append(opts, currentNetwork().mutator())
+
+		fmt.Printf("offer %d expired, cancelling it\n", e.ID)
+		return submitTx(txRequest{
+			client:   client,
+			db:       m,
+			seeds:    []string{src.Seed()},
+			opts:     opts,
+			validFor: viper.GetDuration("valid-for"),
+			retries:  viper.GetInt("retries"),
+			yes:      true,
+		})
+	}
+
+	fmt.Printf("offer %d expired but is no longer on the order book\n", e.ID)
+	return nil
+}
+
+// describeExpiry returns the time left until t, or that it expired
+func describeExpiry(t, now time.Time) string {
+	left := t.Sub(now)
+	if left <= 0 {
+		return "expired, waiting for alfred daemon"
+	}
+
+	if left < time.Minute {
+		return "in less than a minute"
+	}
+	return "in " + strings.TrimSuffix(left.Truncate(time.Minute).String(), "0s")
+}
+
+func horizonAssetCode(a horizon.Asset) string {
+	if a.Type == "native" {
+		return "XLM"
+	}
+	return a.Code
+}
+
+func builderAsset(a horizon.Asset) build.Asset {
+	if a.Type == "native" {
+		return build.NativeAsset()
+	}
+	return build.CreditAsset(a.Code, a.Issuer)
+}
+
+func init() {
+	RootCmd.AddCommand(offersCmd)
+}
@@ -0,0 +1,167 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/paper"
+	"github.com/celrenheit/alfred/shamir"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/keypair"
+)
+
+// exportPaperCmd represents the export-paper command
+var exportPaperCmd = &cobra.Command{
+	Use:   "export-paper",
+	Short: "Write a printable paper wallet",
+	Long: `Write a printable paper wallet with the public and secret keys of a wallet
+and their QR codes, as an HTML page or a PDF document.
+
+With --shares, the secret key is split with Shamir's secret sharing and each
+share is printed on its own page instead of the secret key: any --threshold
+of the shares recover it, fewer reveal nothing about it.`,
+	Example: `alfred export-paper master
+alfred export-paper master --output master.pdf
+alfred export-paper master --shares 5 --threshold 3`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		w := m.WalletByName(args[0])
+		if w == nil {
+			fatalf("wallet '%s' not found", args[0])
+		}
+		if w.IsCold() {
+			fatalf("wallet %s is cold, its seed lives at: %s", w.Name, w.Cold)
+		}
+		if w.IsProtected() {
+			if _, err := unwrap(w); err != nil {
+				fatal(err)
+			}
+		}
+		kp, ok := w.Keypair.(*keypair.Full)
+		if !ok {
+			fatal("you need to unlock your wallet")
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		if output == "" {
+			output = w.Name + ".html"
+		}
+
+		shares, _ := cmd.Flags().GetInt("shares")
+		threshold, _ := cmd.Flags().GetInt("threshold")
+		doc, err := paperWallet(w.Name, kp, shares, threshold)
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := writeDocument(doc, output); err != nil {
+			fatal(err)
+		}
+
+		fmt.Printf("Paper wallet written to %s, delete it once printed\n", output)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(exportPaperCmd)
+
+	exportPaperCmd.Flags().String("output", "", "file written, .html or .pdf (default is <wallet>.html)")
+	exportPaperCmd.Flags().Int("shares", 0, "number of shares the secret key is split into (0 to print the secret key)")
+	exportPaperCmd.Flags().Int("threshold", 2, "number of shares needed to recover the secret key")
+}
+
+// writeDocument writes doc to output, as a PDF document or an HTML page
+// depending on its extension
+func writeDocument(doc *paper.Document, output string) error {
+	write := doc.HTML
+	switch ext := strings.ToLower(filepath.Ext(output)); ext {
+	case ".pdf":
+		write = doc.PDF
+	case ".html", ".htm":
+	default:
+		return i18n.Errorf("unsupported format '%s', the output should be a .html or .pdf file", ext)
+	}
+
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := write(f); err != nil {
+		os.Remove(output)
+		return err
+	}
+
+	return nil
+}
+
+// paperWallet returns the pages of the paper wallet of kp, with one page per
+// share of the secret key when shares is not zero
+func paperWallet(name string, kp *keypair.Full, shares, threshold int) (*paper.Document, error) {
+	public, err := paper.NewItem("Public key", kp.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &paper.Document{Title: "Paper wallet: " + name}
+	if shares == 0 {
+		secret, err := paper.NewItem("Secret key", kp.Seed())
+		if err != nil {
+			return nil, err
+		}
+
+		doc.Pages = []paper.Page{{
+			Title: doc.Title,
+			Note:  "Keep this page secret and safe: anyone with the secret key controls the account.",
+			Items: []paper.Item{public, secret},
+		}}
+		return doc, nil
+	}
+
+	texts, err := shamir.SplitText([]byte(kp.Seed()), shares, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, text := range texts {
+		share, err := paper.NewItem(fmt.Sprintf("Share %d of %d of the secret key", i+1, shares), text)
+		if err != nil {
+			return nil, err
+		}
+
+		doc.Pages = append(doc.Pages, paper.Page{
+			Title: fmt.Sprintf("%s, share %d of %d", doc.Title, i+1, shares),
+			Note: fmt.Sprintf("Any %d of the %d shares recover the secret key with alfred secret recover, "+
+				"fewer reveal nothing about it. Keep the shares in different places.", threshold, shares),
+			Items: []paper.Item{public, share},
+		})
+	}
+
+	return doc, nil
+}
@@ -4,13 +4,14 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
-	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/manifoldco/promptui"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/viper"
 	"github.com/stellar/go/build"
-	"github.com/stellar/go/keypair"
 )
 
 var (
@@ -64,7 +65,7 @@ func ParseHeader(key string, value []byte) (*Header, error) {
 
 func ParsePart(prefix string, key string, value []byte) (*Part, error) {
 	if !strings.HasPrefix(key, prefix) {
-		return nil, fmt.Errorf("key: '%s' does not have prefix: '%s'", key, prefix)
+		return nil, i18n.Errorf("key: '%s' does not have prefix: '%s'", key, prefix)
 	}
 
 	offsetStr := strings.TrimPrefix(key, prefix+":")
@@ -118,59 +119,37 @@ func (p *Part) Value() []byte {
 	return p.Data
 }
 
-func submitData(testnet, yes bool, src *keypair.Full, kvs []KVData) error {
+func submitData(m *wallet.Alfred, n stellarNetwork, yes bool, validFor time.Duration, src walletKey, kvs []KVData) error {
 	var sopts []build.TransactionMutator
 	for _, kv := range kvs {
 		sopts = append(sopts, build.SetData(kv.Key(), kv.Value()))
 	}
 
-	client := getClient(testnet)
+	client := getClient(n)
 
-	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
-		build.AutoSequence{SequenceProvider: client},
-	}
-
-	opts = append(opts, sopts...)
-
-	if testnet {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
-	}
-
-	tx, err := build.Transaction(opts...)
-	if err != nil {
-		return err
-	}
-
-	txe, err := tx.Sign(src.Seed())
+	seed, err := src.Seed()
 	if err != nil {
 		return err
 	}
 
-	txeB64, err := txe.Base64()
-	if err != nil {
-		return err
-	}
-
-	if !yes {
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
-		}
+	opts := []build.TransactionMutator{
+		build.SourceAccount{seed},
+		build.AutoSequence{SequenceProvider: client},
 	}
 
-	resp, err := client.SubmitTransaction(txeB64)
-	if err != nil {
-		return err
-	}
+	opts = append(opts, sopts...)
 
-	fmt.Println(resp.Hash)
-	return nil
+	opts = append(opts, n.mutator())
+
+	return submitTx(txRequest{
+		client:   client,
+		db:       m,
+		seeds:    []string{seed},
+		opts:     opts,
+		validFor: validFor,
+		retries:  viper.GetInt("retries"),
+		yes:      yes,
+	})
 }
 
 func GetData(header *Header, parts []*Part) ([]byte, error) {
@@ -181,7 +160,7 @@ func GetData(header *Header, parts []*Part) ([]byte, error) {
 	}
 
 	if !bytes.Equal(header.Checksum, sha256sum(data)) {
-		return nil, fmt.Errorf("checksum invalid")
+		return nil, i18n.Errorf("checksum invalid")
 	}
 
 	return data, nil
@@ -0,0 +1,201 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/keypair"
+)
+
+// passphraseCmd represents the passphrase command
+var passphraseCmd = &cobra.Command{
+	Use:   "passphrase",
+	Short: "Protect the seed of a wallet with its own passphrase",
+	Long: `Protect the seed of a high-value wallet with its own passphrase, on top of the
+secret of the database. The passphrase is asked only when the wallet is the
+source or a signer of a transaction, the other commands use its address.
+
+The passphrase is not stored anywhere: a wallet whose passphrase is lost can
+not sign anymore, back its seed up first with alfred export-paper.`,
+	Example: `alfred passphrase set savings
+alfred passphrase remove savings`,
+}
+
+// passphraseSetCmd represents the passphrase set command
+var passphraseSetCmd = &cobra.Command{
+	Use:     "set <wallet>",
+	Short:   "Encrypt the seed of a wallet with a passphrase",
+	Example: `alfred passphrase set savings`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		w := openWallet(viper.GetString("db"), args[0])
+		if w.IsCold() {
+			fatalf("wallet %s is cold, its seed lives at: %s", w.Name, w.Cold)
+		}
+		if w.IsProtected() {
+			fatalf("wallet %s already has a passphrase, remove it first to change it", w.Name)
+		}
+
+		passphrase := viper.GetString("passphrase")
+		if passphrase == "" {
+			if err := checkInteractive("passphrase", "the passphrase of the wallet is needed", "--passphrase"); err != nil {
+				fatal(err)
+			}
+
+			var err error
+			if passphrase, err = promptPassphrase("New passphrase of " + w.Name); err != nil {
+				fatal(err)
+			}
+			confirmation, err := promptPassphrase("Confirm the passphrase")
+			if err != nil {
+				fatal(err)
+			}
+			if confirmation != passphrase {
+				fatal("the passphrases do not match")
+			}
+		}
+
+		if err := updateWallet(w.Name, func(w *wallet.Wallet) error {
+			return w.Protect(passphrase)
+		}); err != nil {
+			fatal(err)
+		}
+
+		fmt.Println("The seed of", w.Name, "is now encrypted with its passphrase, which is asked when it signs")
+	},
+}
+
+// passphraseRemoveCmd represents the passphrase remove command
+var passphraseRemoveCmd = &cobra.Command{
+	Use:     "remove <wallet>",
+	Short:   "Remove the passphrase of a wallet",
+	Example: `alfred passphrase remove savings`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		w := openWallet(viper.GetString("db"), args[0])
+		if !w.IsProtected() {
+			fatalf("wallet %s has no passphrase", w.Name)
+		}
+
+		passphrase, err := askPassphrase(w)
+		if err != nil {
+			fatal(err)
+		}
+		if err := updateWallet(w.Name, func(w *wallet.Wallet) error {
+			return w.Unprotect(passphrase)
+		}); err != nil {
+			fatal(err)
+		}
+
+		fmt.Println("The seed of", w.Name, "is now only encrypted with the secret of the database")
+	},
+}
+
+// openWallet opens the database at path and returns its wallet called name
+func openWallet(path, name string) *wallet.Wallet {
+	m, err := wallet.OpenSecretString(path, viper.GetString("secret"))
+	if err != nil {
+		fatal(err)
+	}
+
+	w := m.WalletByName(name)
+	if w == nil {
+		fatalf("wallet '%s' not found%s", name, suggestName(name, accountNames(m, false)))
+	}
+	return w
+}
+
+// protectedKey is the walletKey of a wallet protected with a passphrase, the
+// passphrase is asked the first time its seed is needed
+type protectedKey struct {
+	w *wallet.Wallet
+}
+
+// Address returns the address of the wallet
+func (k protectedKey) Address() string { return k.w.Keypair.Address() }
+
+// Seed returns the seed of the wallet, once unlocked with its passphrase. Its
+// address is returned instead when the agent holds its key, the agent then
+// signs for it.
+func (k protectedKey) Seed() (string, error) {
+	if _, ok := k.w.Keypair.(*keypair.Full); !ok && agentHolds(k.Address()) {
+		return k.Address(), nil
+	}
+
+	kp, err := unwrap(k.w)
+	if err != nil {
+		return "", err
+	}
+	return kp.Seed(), nil
+}
+
+// unwrap decrypts the seed of w with its passphrase, --passphrase or the one
+// prompted for
+func unwrap(w *wallet.Wallet) (*keypair.Full, error) {
+	if kp, ok := w.Keypair.(*keypair.Full); ok {
+		return kp, nil
+	}
+
+	passphrase, err := askPassphrase(w)
+	if err != nil {
+		return nil, err
+	}
+	kp, err := w.Unwrap(passphrase)
+	if err != nil {
+		return nil, i18n.Errorf("wallet %s: %v", w.Name, err)
+	}
+	return kp, nil
+}
+
+// askPassphrase returns --passphrase, or prompts for the passphrase of w
+func askPassphrase(w *wallet.Wallet) (string, error) {
+	if passphrase := viper.GetString("passphrase"); passphrase != "" {
+		return passphrase, nil
+	}
+	if err := checkInteractive("passphrase", fmt.Sprintf("wallet %s is protected with a passphrase", w.Name), "--passphrase"); err != nil {
+		return "", err
+	}
+
+	return promptPassphrase(i18n.Sprintf("Passphrase of %s", w.Name))
+}
+
+func promptPassphrase(label string) (string, error) {
+	prompt := promptui.Prompt{
+		Label: label,
+		Validate: func(input string) error {
+			if input == "" {
+				return errors.New(i18n.T("the passphrase should not be empty"))
+			}
+			return nil
+		},
+		Mask: '*',
+	}
+	return prompt.Run()
+}
+
+func init() {
+	RootCmd.AddCommand(passphraseCmd)
+	passphraseCmd.AddCommand(passphraseSetCmd)
+	passphraseCmd.AddCommand(passphraseRemoveCmd)
+}
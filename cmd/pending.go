@@ -0,0 +1,442 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// Outcomes of a pending transaction
+const (
+	pendingLanded  = "went through"
+	pendingExpired = "expired, never went through"
+	pendingBadSeq  = "never went through, its sequence number was used by another transaction"
+	pendingNoSrc   = "never went through, its source account does not exist"
+	pendingLive    = "may still go through"
+)
+
+// pendingCmd represents the pending command
+var pendingCmd = &cobra.Command{
+	Use:   "pending",
+	Short: "List the transactions submitted without knowing whether they went through",
+	Long: `List the transactions submitted without knowing whether they went through.
+
+A signed transaction is kept pending from just before it is submitted until
+horizon tells whether it went through, so that it is not lost when alfred is
+stopped or loses the network in between. Each one is checked against horizon:
+it went through, it can never go through anymore, or it may still go through
+and alfred pending retry submits it again. Submitting it again is safe, a
+transaction can only be applied once.`,
+	Example: `alfred pending
+alfred pending retry
+alfred pending retry 2
+alfred pending discard 2`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		n := currentNetwork()
+		client := getClient(n)
+		table := newTable(os.Stdout)
+		table.SetHeader(header("ID", "Created", "Network", "Transaction", "Status"))
+		found := false
+		for _, p := range m.Stellar.Pending {
+			if p.Network != n.Name {
+				continue
+			}
+			found = true
+			status, err := pendingStatus(client, p)
+			if err != nil {
+				status = "unknown: " + describeError(err)
+			}
+			table.Append([]string{
+				strconv.Itoa(p.ID),
+				p.Created.Local().Format(time.RFC822),
+				p.Network,
+				p.Hash,
+				status,
+			})
+		}
+		if !found {
+			fmt.Println("No pending transaction on", n.Name)
+			return
+		}
+		table.Render()
+	},
+}
+
+// pendingListCmd represents the pending list command
+var pendingListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the transactions submitted without knowing whether they went through",
+	Args:    cobra.NoArgs,
+	PreRunE: middlewares(checkDB),
+	Run:     pendingCmd.Run,
+}
+
+// pendingRetryCmd represents the pending retry command
+var pendingRetryCmd = &cobra.Command{
+	Use:   "retry [id...]",
+	Short: "Resume the pending transactions",
+	Long: `Resume the pending transactions of the current network, all of them when no
+id is given.
+
+The ones that went through are recorded in the log and the ones that can
+never go through are dropped. The others are submitted again as they were
+signed, they stay pending when their outcome is still unknown.`,
+	Example: `alfred pending retry
+alfred pending retry 2 3`,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		pending, err := pendingByIDs(m, args)
+		if err != nil {
+			fatal(err)
+		}
+		if len(pending) == 0 {
+			fmt.Println("No pending transaction on", currentNetwork().Name)
+			return
+		}
+
+		client := getClient(currentNetwork())
+		var failed error
+		for _, p := range pending {
+			landed, err := resumePending(client, p)
+			if err != nil {
+				fmt.Printf("%d: %s, still pending\n", p.ID, describeError(err))
+				failed = err
+				continue
+			}
+			if landed {
+				fmt.Printf("%d: %s\n", p.ID, pendingLanded)
+				fmt.Println(p.Hash)
+				printExplorerLinks(p.Hash)
+			}
+		}
+
+		cached.reset()
+		if failed != nil {
+			fatal(withExitCode(exitHorizon, errors.New("some transactions are still pending")))
+		}
+	},
+}
+
+// pendingDiscardCmd represents the pending discard command
+var pendingDiscardCmd = &cobra.Command{
+	Use:   "discard <id...>",
+	Short: "Forget pending transactions",
+	Long: `Forget pending transactions without checking them. A transaction discarded
+may still go through if it was received by the network, alfred pending shows
+whether it can.`,
+	Example: `alfred pending discard 2`,
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			pending, err := pendingByIDs(m, args)
+			if err != nil {
+				return err
+			}
+			for _, p := range pending {
+				m.RemovePending(p.ID)
+			}
+			return nil
+		}); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// pendingByIDs returns the pending transactions of m with the ids of args,
+// all the ones of the current network without args
+func pendingByIDs(m *wallet.Alfred, args []string) ([]wallet.PendingTx, error) {
+	network := currentNetwork().Name
+	if len(args) == 0 {
+		var pending []wallet.PendingTx
+		for _, p := range m.Stellar.Pending {
+			if p.Network == network {
+				pending = append(pending, p)
+			}
+		}
+		return pending, nil
+	}
+
+	var pending []wallet.PendingTx
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, withExitCode(exitParse, i18n.Errorf("invalid pending transaction id '%s'", arg))
+		}
+		p, err := m.PendingTx(id)
+		if err != nil {
+			return nil, err
+		}
+		if p.Network != network {
+			return nil, i18n.Errorf("pending transaction %d is on %s, not %s", id, p.Network, network)
+		}
+		pending = append(pending, *p)
+	}
+	return pending, nil
+}
+
+// resumePending settles p: it is recorded in the log of the database if it
+// went through, removed if it can never go through, and submitted again
+// otherwise. It stays pending when its outcome is still unknown, with the
+// error returned.
+func resumePending(client *horizon.Client, p wallet.PendingTx) (bool, error) {
+	status, err := pendingStatus(client, p)
+	if err != nil {
+		return false, err
+	}
+
+	switch status {
+	case pendingLanded:
+		return true, recordPending(p)
+	case pendingLive:
+	default:
+		fmt.Printf("%d: %s, discarded\n", p.ID, status)
+		return false, discardPending(p)
+	}
+
+	_, err = client.SubmitTransaction(p.Envelope)
+	countSubmission(err)
+	switch {
+	case err == nil:
+		return true, recordPending(p)
+	case rejected(err):
+		// it may have gone through since it was checked
+		if landed, lerr := transactionLanded(client, p.Hash); lerr == nil && landed {
+			return true, recordPending(p)
+		}
+		fmt.Printf("%d: rejected, discarded: %s\n", p.ID, describeError(err))
+		return false, discardPending(p)
+	}
+	return false, err
+}
+
+// rejected reports whether err is horizon refusing a transaction with a
+// result code, so that it can never go through. A timeout of horizon, or an
+// error without result codes, leaves the outcome unknown.
+func rejected(err error) bool {
+	var herr *horizon.Error
+	if !errors.As(err, &herr) {
+		return false
+	}
+	if herr.Problem.Status == http.StatusGatewayTimeout ||
+		(herr.Response != nil && herr.Response.StatusCode == http.StatusGatewayTimeout) {
+		return false
+	}
+
+	codes, cerr := herr.ResultCodes()
+	return cerr == nil && codes != nil && codes.TransactionCode != ""
+}
+
+// recordPending records p in the log of the database, as went through, and
+// removes it
+func recordPending(p wallet.PendingTx) error {
+	entry, err := newLogEntry(p.Hash, p.Envelope, currentNetwork())
+	logged := err == nil
+	if !logged {
+		fmt.Println("Warning: unable to record the transaction in the log:", err)
+	}
+	entry.IdempotencyKey = p.IdempotencyKey
+
+	_, err = updateDB(func(m *wallet.Alfred) error {
+		if logged {
+			m.AppendLog(entry)
+		}
+		m.RemovePending(p.ID)
+		return nil
+	})
+	return err
+}
+
+// discardPending removes p from the pending transactions of the database
+func discardPending(p wallet.PendingTx) error {
+	_, err := updateDB(func(m *wallet.Alfred) error {
+		m.RemovePending(p.ID)
+		return nil
+	})
+	return err
+}
+
+// pendingStatus checks against horizon whether p went through, can never go
+// through or may still go through
+func pendingStatus(client *horizon.Client, p wallet.PendingTx) (string, error) {
+	var txe xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(p.Envelope, &txe); err != nil {
+		return "", i18n.Errorf("invalid envelope: %v", err)
+	}
+
+	acc, exists, err := loadAccount(client, txe.Tx.SourceAccount.Address())
+	if err != nil {
+		return "", err
+	}
+
+	status := pendingLive
+	switch {
+	case !exists:
+		status = pendingNoSrc
+	case sequenceUsed(acc, txe.Tx.SeqNum):
+		status = pendingBadSeq
+	case txe.Tx.TimeBounds != nil && txe.Tx.TimeBounds.MaxTime != 0 && time.Now().After(time.Unix(int64(txe.Tx.TimeBounds.MaxTime), 0)):
+		status = pendingExpired
+	}
+	if status == pendingLive {
+		return status, nil
+	}
+
+	// it can not go through anymore, unless it already did
+	landed, err := transactionLanded(client, p.Hash)
+	if err != nil {
+		return "", err
+	}
+	if landed {
+		return pendingLanded, nil
+	}
+	return status, nil
+}
+
+// sequenceUsed reports whether the sequence number of acc reached seq, which
+// no transaction can use anymore
+func sequenceUsed(acc horizon.Account, seq xdr.SequenceNumber) bool {
+	current, err := strconv.ParseInt(acc.Sequence, 10, 64)
+	return err == nil && current >= int64(seq)
+}
+
+// transactionLanded reports whether the transaction with hash is in the
+// ledger, with an error when horizon could not tell
+func transactionLanded(client *horizon.Client, hash string) (bool, error) {
+	resp, err := client.HTTP.Get(strings.TrimRight(client.URL, "/") + "/transactions/" + hash)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, withExitCode(exitHorizon, i18n.Errorf("horizon answered %s", resp.Status))
+}
+
+// pendingSubmissions keeps the transactions submitted in the pending queue of
+// db from just before they are submitted until their outcome is known
+type pendingSubmissions struct {
+	db  *wallet.Alfred
+	ids []int
+}
+
+// add stores the transaction with hash in the queue and writes the database,
+// it implements tx.Request.Pending
+func (s *pendingSubmissions) add(hash, txeB64 string) error {
+	if s.db == nil {
+		return nil
+	}
+
+	p := wallet.PendingTx{
+		Network:        currentNetwork().Name,
+		Hash:           hash,
+		Envelope:       txeB64,
+		Created:        time.Now().UTC(),
+		IdempotencyKey: viper.GetString("idempotency-key"),
+	}
+	var id int
+	if _, err := updateDB(func(m *wallet.Alfred) error {
+		id = m.AddPending(p)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	s.ids = append(s.ids, id)
+	return nil
+}
+
+// settle removes the transactions from the queue once horizon answered the
+// submission, which ended with err, and keeps them otherwise
+func (s *pendingSubmissions) settle(err error) {
+	if s.db == nil || len(s.ids) == 0 {
+		return
+	}
+
+	if err != nil && !rejected(err) {
+		fmt.Fprintln(os.Stderr, "The outcome of the transaction is unknown, it is kept pending: alfred pending retry resumes it")
+		return
+	}
+
+	ids := s.ids
+	s.ids = nil
+	if _, err := updateDB(func(m *wallet.Alfred) error {
+		m.RemovePending(ids...)
+		return nil
+	}); err != nil {
+		fmt.Println("Warning: unable to remove the transaction from the pending ones:", err)
+	}
+}
+
+// submitPending submits the signed transaction txeB64, kept pending in m
+// until horizon answers
+func submitPending(m *wallet.Alfred, client *horizon.Client, txeB64 string) (horizon.TransactionSuccess, error) {
+	var txe xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(txeB64, &txe); err != nil {
+		return horizon.TransactionSuccess{}, i18n.Errorf("invalid envelope: %v", err)
+	}
+
+	s := pendingSubmissions{db: m}
+	hash, err := network.HashTransaction(&txe.Tx, currentNetwork().Passphrase)
+	if err != nil {
+		return horizon.TransactionSuccess{}, err
+	}
+	if err := s.add(hex.EncodeToString(hash[:]), txeB64); err != nil {
+		return horizon.TransactionSuccess{}, err
+	}
+
+	resp, err := client.SubmitTransaction(txeB64)
+	countSubmission(err)
+	s.settle(err)
+	return resp, err
+}
+
+func init() {
+	RootCmd.AddCommand(pendingCmd)
+	pendingCmd.AddCommand(pendingListCmd)
+	pendingCmd.AddCommand(pendingRetryCmd)
+	pendingCmd.AddCommand(pendingDiscardCmd)
+}
@@ -0,0 +1,399 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/decimal"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/keypair"
+)
+
+// plan describes what a statement would do, without loading anything from
+// horizon. The names and assets which could not be resolved are its problems.
+type plan struct {
+	m        *wallet.Alfred
+	lines    [][2]string
+	problems []string
+}
+
+// explainStatement prints the plan of statement to w, and returns an error
+// listing its problems, so that scripts can check statements before running
+// them with --yes
+func explainStatement(w io.Writer, m *wallet.Alfred, statement parser.Statement) error {
+	p := &plan{m: m}
+	switch req := statement.(type) {
+	case *parser.SendRequest:
+		p.send(req)
+	case *parser.Offer:
+		p.offer(req)
+	case *parser.ShareAccountRequest:
+		p.add("Statement", "share account")
+		p.add("Account", p.wallet(req.Account))
+		for _, name := range req.AdditionnalSigners {
+			p.operation("set options: add signer %s", p.destination(name))
+		}
+		p.thresholds(req.Required, req.Total)
+	case *parser.RemoveSignerRequest:
+		p.add("Statement", "remove signer")
+		p.add("Account", p.wallet(req.Account))
+		for _, name := range req.Signers {
+			p.operation("set options: remove signer %s", p.destination(name))
+		}
+		p.thresholds(req.Required, req.Total)
+	case *parser.SetDataRequest:
+		p.add("Statement", "set data")
+		p.add("Account", p.wallet(req.Account))
+		var keys []string
+		for key := range req.KVs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entry := req.KVs[key]
+			if entry.Kind == parser.SetDataFromFile {
+				p.operation("manage data: set %q to the content of %s", key, entry.Value)
+			} else {
+				p.operation("manage data: set %q to %q", key, entry.Value)
+			}
+		}
+	case *parser.DeleteDataRequest:
+		p.add("Statement", "delete data")
+		p.add("Account", p.wallet(req.Account))
+		for _, key := range req.Keys {
+			p.operation("manage data: delete %q", key)
+		}
+	case *parser.TrustRequest:
+		p.trust(req)
+	case *parser.AuthorizeRequest:
+		statement, op := "authorize", "allow trust: authorize %s"
+		if req.Revoke {
+			statement, op = "deauthorize", "allow trust: deauthorize %s"
+		}
+		p.add("Statement", statement)
+		p.add("Issuer", p.wallet(req.Issuer))
+		p.add("Asset", strings.ToUpper(req.Asset))
+		p.operation(op, p.destination(req.Trustor))
+	case *parser.Transfer:
+		p.transfer(req)
+	default:
+		return i18n.Errorf("unsupported statement type: %T", statement)
+	}
+
+	width := 0
+	for _, line := range p.lines {
+		if len(line[0])+1 > width {
+			width = len(line[0]) + 1
+		}
+	}
+	for _, line := range p.lines {
+		if line[0] == "" {
+			fmt.Fprintf(w, "%*s  %s\n", width, "", line[1])
+			continue
+		}
+		fmt.Fprintf(w, "%-*s  %s\n", width, line[0]+":", line[1])
+	}
+
+	if len(p.problems) > 0 {
+		return errors.New("not ready to run without prompts:\n  " + strings.Join(p.problems, "\n  "))
+	}
+	return nil
+}
+
+func (p *plan) add(name, value string) {
+	p.lines = append(p.lines, [2]string{name, value})
+}
+
+// operation adds an operation of the transaction, the first one under
+// the Operations label
+func (p *plan) operation(format string, args ...interface{}) {
+	label := "Operations"
+	for _, line := range p.lines {
+		if line[0] == label {
+			label = ""
+		}
+	}
+	p.add(label, fmt.Sprintf(format, args...))
+}
+
+func (p *plan) problem(format string, args ...interface{}) string {
+	msg := fmt.Sprintf(format, args...)
+	p.problems = append(p.problems, msg)
+	return "?? " + msg
+}
+
+func (p *plan) send(req *parser.SendRequest) {
+	p.add("Statement", "send")
+	p.add("Source", p.wallet(req.From))
+	code, asset := "(detected asset)", "detected among the ones held by the source and trusted by the destinations"
+	if req.Currency != "" {
+		code, asset = p.asset(req.Currency)
+	}
+	p.add("Asset", asset)
+
+	amount := req.Amount + " " + code
+	switch {
+	case req.Percent == "100":
+		amount = "all the " + code + " balance"
+		if code == "XLM" {
+			amount += ", minus the minimum balance and the fee"
+		}
+	case req.Percent != "":
+		amount = req.Percent + "% of the " + code + " balance"
+	}
+
+	recipients := req.Recipients()
+	if len(recipients) == 0 {
+		if viper.GetString("qr-file") != "" {
+			recipients = []string{""}
+		} else {
+			p.add("Destination", p.problem("no destination given, it would be prompted for"))
+		}
+	}
+	for _, name := range recipients {
+		to := "the address of " + viper.GetString("qr-file")
+		if name != "" {
+			to = p.destination(name)
+		}
+
+		switch {
+		case req.StartingBalance != "":
+			p.operation("payment of %s to %s, or create account with %s XLM more as starting balance if it does not exist", amount, to, req.StartingBalance)
+		case code == "XLM":
+			p.operation("payment of %s to %s, or create account if it does not exist", amount, to)
+		default:
+			p.operation("payment of %s to %s", amount, to)
+		}
+	}
+
+	if req.Memo != "" {
+		p.add("Memo", fmt.Sprintf("text %q", req.Memo))
+	} else {
+		for _, name := range recipients {
+			_, contact, ok := p.m.Contact(name)
+			if !ok || contact.Memo == nil {
+				continue
+			}
+			if o, err := contact.Memo.MarshalYAML(); err == nil {
+				p.add("Memo", fmt.Sprintf("%v %v, expected by %s", contact.Memo.Type, o.(map[string]interface{})["value"], name))
+			}
+		}
+	}
+	p.timeBounds(req.TimeBounds)
+}
+
+func (p *plan) offer(req *parser.Offer) {
+	kind, first, second := "buy", req.Buying, req.Selling
+	if req.Kind() == parser.SellOfferKind {
+		kind, first, second = "sell", req.Selling, req.Buying
+	}
+	if req.Passive {
+		kind = "passive " + kind
+	}
+
+	p.add("Statement", kind+" offer")
+	p.add("Account", p.wallet(req.Account))
+	_, selling := p.asset(req.Selling)
+	p.add("Selling", selling)
+	_, buying := p.asset(req.Buying)
+	p.add("Buying", buying)
+
+	switch req.AmountKind {
+	case parser.AmountBuyKind:
+		p.add("Amount", req.Amount+" "+strings.ToUpper(first))
+	case parser.AmountSellKind:
+		p.add("Amount", req.Amount+" "+strings.ToUpper(second))
+	}
+
+	if req.Price != "" {
+		p.add("Price", req.Price+" "+strings.ToUpper(second)+" per "+strings.ToUpper(first))
+	} else {
+		p.add("Price", fmt.Sprintf("best price of the order book, within --max-slippage %v%%", viper.GetFloat64("max-slippage")))
+	}
+	per := " " + strings.ToUpper(second) + " per " + strings.ToUpper(first)
+	minPrice, maxPrice, err := priceGuards(req)
+	switch {
+	case err != nil:
+		p.add("Price guard", p.problem("%v", err))
+	case minPrice != nil:
+		p.add("Minimum price", decimal.FormatPrice(minPrice)+per)
+	case maxPrice != nil:
+		p.add("Maximum price", decimal.FormatPrice(maxPrice)+per)
+	}
+
+	switch {
+	case req.Passive:
+		p.operation("create passive sell offer")
+	case req.Kind() == parser.BuyOfferKind:
+		p.operation("manage buy offer")
+	default:
+		p.operation("manage sell offer")
+	}
+	if req.Expires > 0 {
+		p.add("Expires", fmt.Sprintf("in %v, canceled by alfred daemon", req.Expires))
+	}
+	p.timeBounds(req.TimeBounds)
+}
+
+func (p *plan) trust(req *parser.TrustRequest) {
+	statement := "trust"
+	if req.Lower {
+		statement = "lower trust"
+	}
+	p.add("Statement", statement)
+	p.add("Account", p.wallet(req.Account))
+	code, asset := p.asset(req.Asset)
+	if code == "XLM" {
+		asset = p.problem("XLM is the native asset, it needs no trustline")
+	}
+	p.add("Asset", asset)
+
+	switch {
+	case req.Limit == "":
+		p.add("Limit", "max")
+	case req.Limit == "0":
+		p.add("Limit", "0, removes the trustline")
+	default:
+		p.add("Limit", req.Limit+" "+code)
+	}
+	p.operation("change trust")
+}
+
+func (p *plan) transfer(req *parser.Transfer) {
+	amount := req.Amount + " " + strings.ToUpper(req.Currency)
+	if req.Amount == "" {
+		amount = strings.ToUpper(req.Currency) + ", the amount being asked by the anchor"
+	}
+
+	if req.Kind() == parser.WithdrawKind {
+		p.add("Statement", "withdraw")
+		p.add("Account", p.wallet(req.Account))
+		p.add("Anchor", req.Anchor)
+		p.add("Destination", req.Destination)
+		p.operation("payment of %s to the account given by the anchor", amount)
+		return
+	}
+
+	p.add("Statement", "deposit")
+	p.add("Account", p.wallet(req.Account))
+	p.add("Anchor", req.Anchor)
+	p.add("Amount", amount)
+	p.operation("none, the anchor sends the deposit once completed in the browser")
+}
+
+// wallet describes the wallet named name, or the one used when none is given
+func (p *plan) wallet(name string) string {
+	if name == "" {
+		name = viper.GetString("wallet")
+		if name == "" {
+			return p.problem("no wallet given, it would be prompted for")
+		}
+	}
+
+	if addr, err := keypair.Parse(name); err == nil {
+		if w := p.m.WalletByAddress(addr.Address()); w != nil {
+			return fmt.Sprintf("%s (%s)", w.Name, addr.Address())
+		}
+		return p.problem("wallet %s not found", name)
+	}
+
+	if w := p.m.WalletByName(name); w != nil {
+		return fmt.Sprintf("%s (%s)", name, w.Keypair.Address())
+	}
+	return p.problem("wallet '%s' not found%s", name, suggestName(name, accountNames(p.m, false)))
+}
+
+// destination describes the address, wallet or contact named name
+func (p *plan) destination(name string) string {
+	if addr, err := keypair.Parse(name); err == nil {
+		return addr.Address()
+	}
+	if w := p.m.WalletByName(name); w != nil {
+		return fmt.Sprintf("wallet %s (%s)", name, w.Keypair.Address())
+	}
+	if name, contact, ok := p.m.Contact(name); ok {
+		if contact.Verified != nil {
+			return fmt.Sprintf("contact %s (%s), verified with %s", name, contact.Address, contact.Verified)
+		}
+		return fmt.Sprintf("contact %s (%s)", name, contact.Address)
+	}
+	return p.problem("destination '%s' not found%s", name, suggestName(name, accountNames(p.m, true)))
+}
+
+// asset returns the code of an asset and describes what it resolves to
+func (p *plan) asset(code string) (string, string) {
+	if code == "" {
+		return "", p.problem("no asset given")
+	}
+	if strings.ToLower(code) == "lumens" {
+		code = "XLM"
+	}
+	code = strings.ToUpper(code)
+
+	asts := assets.GetAssets(code)
+	switch {
+	case len(asts) == 0:
+		return code, p.problem("asset %s is not supported right now%s", code, suggestName(code, assetCodes()))
+	case len(asts) == 1:
+		return code, code + " " + describeAsset(asts[0])
+	}
+
+	if a := assetByIssuer(asts); a != nil {
+		return code, code + " " + describeAsset(*a) + ", chosen with --issuer"
+	}
+
+	var issuers []string
+	for _, a := range asts {
+		issuers = append(issuers, describeAsset(a))
+	}
+	return code, p.problem("several assets are named %s, choose one with --issuer: %s", code, strings.Join(issuers, "; "))
+}
+
+func describeAsset(a assets.Asset) string {
+	if a.BuilderAsset.Native {
+		return "(native lumens)"
+	}
+	return fmt.Sprintf("issued by %s (%s)", a.BuilderAsset.Issuer, a.Domain)
+}
+
+func (p *plan) thresholds(required, total int) {
+	if total > 0 {
+		p.add("Thresholds", fmt.Sprintf("any %d of the %d signers", required, total))
+		return
+	}
+	p.add("Thresholds", "computed from the signers of the account")
+}
+
+func (p *plan) timeBounds(tb parser.TimeBounds) {
+	if !tb.NotBefore.IsZero() {
+		p.add("Not before", tb.NotBefore.String()+", the transaction is printed pre-signed")
+	}
+	validFor := tb.ValidFor
+	if validFor == 0 {
+		validFor = viper.GetDuration("valid-for")
+	}
+	if validFor > 0 {
+		p.add("Valid for", validFor.String())
+	}
+}
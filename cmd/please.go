@@ -15,23 +15,32 @@
 package cmd
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/decimal"
+	"github.com/celrenheit/alfred/explain"
+	"github.com/celrenheit/alfred/i18n"
 	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/pricing"
 	"github.com/celrenheit/alfred/wallet"
 	"github.com/manifoldco/promptui"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
 	"github.com/stellar/go/build"
 	"github.com/stellar/go/clients/horizon"
 	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
 )
 
 // pleaseCmd represents the import command
@@ -41,14 +50,46 @@ var pleaseCmd = &cobra.Command{
 	Aliases: []string{"p"},
 	Long:    `please command allows to execute command`,
 	Example: `alfred please send 20 XLM from master to jennifer
+alfred please send 20 XLM from master to jennifer memo "dinner"
+alfred please send 20 XLM from master to GBXXX create with 2 XLM starting balance
 alfred please send 33 MOBI from master to jennifer
+alfred please send 10 XLM from master to alice, bob and carol
+alfred please send all XLM from master to backup
+alfred please send 50% of MOBI from master to jennifer
 
 alfred please buy 100 MOBI using XLM (will pick the best price)
 alfred please buy MOBI using 100 XLM (will pick the best price)
 alfred please buy 100 MOBI AT 0.1000 using XLM
 alfred please sell 100 MOBI FOR XLM (will pick the best price)
+
+alfred please deposit 100 USD via anchor.com into master
+alfred please withdraw 200 USDC from master to bank via anchor.com
+
+alfred please send 20 XLM from master --qr-file ./address.png
+
+alfred please send 20 XLM from master to jennifer valid for 10 minutes
+alfred please send 20 XLM from master to jennifer not before 2024-01-01 (prints a pre-signed transaction)
+
+alfred please - --yes < statements.txt (one statement per line, prints a JSON line per statement)
+
+alfred please --explain "send 20 XLM from master to jennifer" (prints what would be done, without doing it)
+
+alfred please --lang fr envoie 20 XLM de master à jennifer
+
+alfred please authorize bob for MYTOKEN with issuer
+alfred please deauthorize bob for MYTOKEN with issuer
 	`,
-	PreRunE: middlewares(checkDB, checkSecret),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		// stdin holds the statements, nobody can answer a prompt
+		if readsStdin(args) {
+			interactive = false
+		}
+		// the names of the wallets are not encrypted, unless the database is private
+		if viper.GetBool("explain") {
+			return middlewares(checkDB)(cmd, args)
+		}
+		return middlewares(checkDB, checkSecret)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		var query string
 		switch {
@@ -58,12 +99,28 @@ alfred please sell 100 MOBI FOR XLM (will pick the best price)
 			query = strings.Join(args, " ")
 		}
 
-		statement, err := parser.Parse(query)
+		parse, err := statementParser()
 		if err != nil {
 			fatal(err)
 		}
 
-		client := getClient(viper.GetBool("testnet"))
+		if viper.GetBool("explain") {
+			statement, err := parse(query)
+			if err != nil {
+				fatal(err)
+			}
+
+			m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+			if err != nil {
+				fatal(err)
+			}
+			if err := explainStatement(os.Stdout, m, statement); err != nil {
+				fatal(err)
+			}
+			return
+		}
+
+		client := getClient(currentNetwork())
 
 		path := viper.GetString("db")
 		secret := viper.GetString("secret")
@@ -72,88 +129,277 @@ alfred please sell 100 MOBI FOR XLM (will pick the best price)
 			fatal(err)
 		}
 
-		switch req := statement.(type) {
-		case *parser.SendRequest:
-			err = sendRequest(m, client, cmd, req)
-		case *parser.ShareAccountRequest:
-			err = shareRequest(m, client, cmd, req)
-		case *parser.SetDataRequest:
-			err = setData(m, client, cmd, req)
-		case *parser.Offer:
-			err = createOffer(m, client, cmd, req)
-		default:
-			fatalf("unsupported statement type: %T", statement.Kind())
+		if readsStdin(args) {
+			ok, err := runBatch(m, client, cmd, parse, os.Stdin, os.Stdout)
+			if err != nil {
+				fatal(err)
+			}
+			if !ok {
+				os.Exit(1)
+			}
+			return
 		}
 
+		statement, err := parse(query)
 		if err != nil {
-			fatalf(describeHorizonError(err))
+			fatal(err)
+		}
+		logStatement(statement)
+
+		if entries := previousSubmissions(m); len(entries) > 0 {
+			printPreviousSubmissions(entries)
+			return
+		}
+		if p := pendingSubmission(m); p != nil {
+			fatalf("transaction %d with the idempotency key %s is pending, resume it with: alfred pending retry %d", p.ID, p.IdempotencyKey, p.ID)
+		}
+		if err := runStatement(m, client, cmd, statement); err != nil {
+			fatal(err)
 		}
 	},
 }
 
+// statementParser returns the parser of the statements of please, with the
+// version of the grammar of --grammar and the keywords of the language of
+// --lang, English ones only for a language without keywords
+func statementParser() (func(string) (parser.Statement, error), error) {
+	version, err := parser.ParseVersion(viper.GetString("grammar"))
+	if err != nil {
+		return nil, err
+	}
+
+	lang := language()
+	if parser.CheckLocale(lang) != nil {
+		lang = i18n.English
+	}
+
+	return func(in string) (parser.Statement, error) {
+		return parser.ParseWithLocale(in, version, lang)
+	}, nil
+}
+
+// runStatement executes a parsed statement
+func runStatement(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, statement parser.Statement) error {
+	switch req := statement.(type) {
+	case *parser.SendRequest:
+		return sendRequest(m, client, cmd, req)
+	case *parser.ShareAccountRequest:
+		return shareRequest(m, client, cmd, req)
+	case *parser.RemoveSignerRequest:
+		return removeSignerRequest(m, client, req)
+	case *parser.SetDataRequest:
+		return setData(m, client, cmd, req)
+	case *parser.DeleteDataRequest:
+		return deleteData(m, client, req)
+	case *parser.TrustRequest:
+		return trustRequest(m, client, req)
+	case *parser.AuthorizeRequest:
+		return authorizeRequest(m, client, req)
+	case *parser.Offer:
+		return createOffer(m, client, cmd, req)
+	case *parser.Transfer:
+		if req.Kind() == parser.WithdrawKind {
+			return withdrawRequest(m, client, cmd, req)
+		}
+		return depositRequest(m, client, req)
+	}
+
+	return i18n.Errorf("unsupported statement type: %T", statement)
+}
+
+// describeHorizonError explains the result codes of a failed transaction, using
+// the balances of the accounts involved when they explain the failure
 func describeHorizonError(err error) string {
-	if err == nil {
-		return ""
+	return explain.Error(err, getClient(currentNetwork()))
+}
+
+// memoRequiredKey is the data entry of the accounts which only accept
+// payments with a memo, such as the deposit accounts of exchanges (SEP-29)
+const memoRequiredKey = "config.memo_required"
+
+// memoRequired reports whether the account addr only accepts payments with a memo
+func memoRequired(client *horizon.Client, addr string) (bool, error) {
+	acc, exists, err := getAccount(client, addr)
+	if err != nil {
+		return false, err
 	}
 
-	e, ok := err.(*horizon.Error)
-	if !ok {
-		return err.Error()
+	return exists && acc.Data[memoRequiredKey] == base64.StdEncoding.EncodeToString([]byte("1")), nil
+}
+
+// checkMemoRequired refuses a payment to several recipients when one of them
+// requires a memo: the single memo of the transaction would be sent to all of them.
+func checkMemoRequired(m *wallet.Alfred, client *horizon.Client, to []string) error {
+	if len(to) < 2 {
+		return nil
+	}
+
+	for _, addr := range to {
+		required, err := memoRequired(client, addr)
+		if err != nil {
+			return err
+		}
+		if required {
+			return i18n.Errorf("%s requires a memo but a transaction has a single memo for all its recipients, send to it separately", accountName(m, addr))
+		}
+	}
+
+	return nil
+}
+
+// requiredMemo prompts for the memo of a payment to one of to requiring one.
+// A number is sent as an id memo, anything else as a text memo.
+func requiredMemo(m *wallet.Alfred, client *horizon.Client, to []string) (*wallet.Memo, error) {
+	for _, addr := range to {
+		required, err := memoRequired(client, addr)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			continue
+		}
+
+		reason := fmt.Sprintf("%s requires a memo, a payment without one may be lost", accountName(m, addr))
+		if err := checkInteractive("memo", reason, "MEMO <memo>"); err != nil {
+			return nil, err
+		}
+
+		fmt.Println(strings.ToUpper(reason[:1]) + reason[1:])
+		value, err := (&promptui.Prompt{
+			Label: i18n.T("Memo"),
+			Validate: func(input string) error {
+				if strings.TrimSpace(input) == "" {
+					return errors.New("should not be empty")
+				}
+				return nil
+			},
+		}).Run()
+		if err != nil {
+			return nil, err
+		}
+
+		value = strings.TrimSpace(value)
+		if _, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return wallet.MemoFromString(wallet.MEMO_ID, value)
+		}
+		return wallet.MemoFromString(wallet.MEMO_TEXT, value)
 	}
-	pb := e.Problem
-	return fmt.Sprintf("%s (%s)", pb.Title, string(pb.Extras["result_codes"]))
+
+	return nil, nil
+}
+
+// checkMemo looks for personal data in a memo before it is written on the public ledger.
+// The check is configured with the memo-guard setting: off, warn or block.
+func checkMemo(memo wallet.Memo) error {
+	guard := viper.GetString("memo-guard")
+	switch guard {
+	case "", "off":
+		return nil
+	case "warn", "block":
+	default:
+		return i18n.Errorf("invalid memo-guard '%s', should be off, warn or block", guard)
+	}
+
+	found := memo.PersonalData()
+	if len(found) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("memo '%s' seems to contain %s, it will be publicly visible on the ledger forever",
+		memo.Value.StringValue, strings.Join(found, " and "))
+	if guard == "block" {
+		return errors.New(msg)
+	}
+
+	fmt.Println("Warning:", msg)
+	return nil
 }
 
 func init() {
 	RootCmd.AddCommand(pleaseCmd)
 
 	pleaseCmd.Flags().BoolP("yes", "y", false, "if set, no confirmation prompt will be shown")
+	pleaseCmd.Flags().String("qr-file", "", "image of a QR code (address or SEP-7 payment URI) used as destination")
+	pleaseCmd.Flags().Bool("presign", false, "print the signed transaction instead of submitting it, see the submit command")
+	pleaseCmd.Flags().Bool("override-policy", false, "send payments denied by the policy of the wallet, the violation is logged")
+	pleaseCmd.Flags().StringArray("field", nil, "field needed by an anchor for a withdrawal, as name=value (prompted otherwise)")
+	pleaseCmd.Flags().Float64("max-slippage", defaultMaxSlippage, "percentage by which the average price of an offer without price may be worse than the best price")
+	pleaseCmd.Flags().String("min-price", "", "lowest price of the sells, in the other asset per asset sold, even when the order book offers less (see NO LOWER THAN)")
+	pleaseCmd.Flags().String("max-price", "", "highest price of the buys, in the other asset per asset bought, even when the order book asks more (see NO HIGHER THAN)")
+	pleaseCmd.Flags().Bool("explain", false, "print what the statement would do, the wallets, contacts and assets it resolves to and the operations, without doing it")
+	pleaseCmd.Flags().String("memo-guard", "off", "check memos for personal data such as emails, phone numbers or names (off, warn, block)")
+	var versions []string
+	for _, v := range parser.Versions {
+		versions = append(versions, v.String())
+	}
+	pleaseCmd.Flags().String("grammar", parser.Latest.String(), "version of the grammar used to parse the command ("+strings.Join(versions, ", ")+")")
 	viper.BindPFlags(pleaseCmd.Flags())
 }
 
 func sendRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *parser.SendRequest) error {
-	// Check choosen currency
-	asset, err := selectAsset(req.Currency)
-	if err != nil {
-		return err
-	}
-
-	// Check trust
-
+	// Check choosen currency, detected once the accounts are known when none
+	// is given
 	var (
-		from = req.From
-		to   = req.To
+		asset *assets.Asset
+		err   error
 	)
+	if req.Currency != "" {
+		if asset, err = selectAsset(req.Currency); err != nil {
+			return err
+		}
+	}
 
-	src, err := getOrSelectWallet(m, from)
+	src, err := getOrSelectWallet(m, req.From)
 	if err != nil {
 		return err
 	}
+	// load the source while the destinations are being selected
+	prefetchAccounts(client, src.Address())
 
-	var memo build.TransactionMutator
-	if to != "" {
-		if addr, err := keypair.Parse(to); err == nil { // to custom address
-			to = addr.Address()
-		} else {
-			if w := m.WalletByName(to); w != nil { // between wallet
-				to = w.Keypair.Address()
-			} else if contact, ok := m.Stellar.Contacts[to]; ok { // to contact
-				to = contact.Address
-				if contact.Memo != nil {
-					memo = contact.Memo.ToTransactionMutator()
+	var (
+		to   []string
+		memo *wallet.Memo
+	)
+	if names := req.Recipients(); len(names) > 0 {
+		var memoFrom string
+		for _, name := range names {
+			addr, contactMemo, err := resolveDestination(m, name)
+			if err != nil {
+				return err
+			}
+
+			if contactMemo != nil {
+				if memo != nil && *memo != *contactMemo && req.Memo == "" {
+					return i18n.Errorf("%s and %s expect different memos but a transaction has a single memo, send them separately", memoFrom, name)
 				}
-			} else {
-				return fmt.Errorf("destination '%s' not found", to)
+				memo, memoFrom = contactMemo, name
 			}
+
+			to = append(to, addr)
+		}
+	} else if qrFile := viper.GetString("qr-file"); qrFile != "" {
+		addr, qrMemo, err := destinationFromQR(qrFile)
+		if err != nil {
+			return err
 		}
+
+		to = []string{addr}
+		memo = qrMemo
 	} else {
+		if err := checkInteractive("destination", "no destination given", "TO <destination> or --qr-file"); err != nil {
+			return err
+		}
+
 		var toList []string
-		for name, _ := range m.Stellar.Contacts {
-			toList = append(toList, name)
+		labels := map[string]string{}
+		for name, contact := range m.Stellar.Contacts {
+			label := contactLabel(name, contact)
+			toList = append(toList, label)
+			labels[label] = name
 		}
 		prompt := promptui.SelectWithAdd{
-			Label:    "Destination",
-			AddLabel: "Another address",
+			Label:    i18n.T("Destination"),
+			AddLabel: i18n.T("Another address"),
 			Items:    toList,
 			Validate: func(input string) error {
 				_, err := keypair.Parse(input)
@@ -161,15 +407,55 @@ func sendRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, r
 			},
 		}
 
-		_, name, err := prompt.Run()
+		_, label, err := prompt.Run()
 		if err != nil {
 			return err
 		}
 
-		contact := m.Stellar.Contacts[name]
-		to = contact.Address
-		if contact.Memo != nil {
-			memo = contact.Memo.ToTransactionMutator()
+		if name, ok := labels[label]; ok {
+			contact := m.Stellar.Contacts[name]
+			to = []string{contact.Address}
+			memo = contact.Memo
+		} else { // another address
+			to = []string{label}
+		}
+	}
+
+	if len(to) > 1 && req.Percent != "" {
+		return errors.New("a percentage of the balance can only be sent to a single recipient")
+	}
+
+	if req.Memo != "" {
+		memo, err = wallet.MemoFromString(wallet.MEMO_TEXT, req.Memo)
+		if err != nil {
+			return err
+		}
+	}
+
+	prefetchAccounts(client, to...)
+
+	if asset == nil {
+		if asset, err = detectAsset(m, client, src.Address(), to); err != nil {
+			return err
+		}
+		resolved := *req // do not modify the statement
+		resolved.Currency = asset.CodeString()
+		req = &resolved
+	}
+
+	if err := checkMemoRequired(m, client, to); err != nil {
+		return err
+	}
+
+	if memo == nil {
+		if memo, err = requiredMemo(m, client, to); err != nil {
+			return err
+		}
+	}
+
+	if memo != nil {
+		if err := checkMemo(*memo); err != nil {
+			return err
 		}
 	}
 
@@ -179,204 +465,606 @@ func sendRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, r
 	}
 
 	if !exists {
-		return fmt.Errorf("source account does exists, please fund it first")
+		return i18n.Errorf("source account does exists, please fund it first")
+	}
+
+	created := make(map[string]bool)
+	destAccs := make(map[string]horizon.Account)
+	for _, addr := range to {
+		destAcc, exists, err := getAccount(client, addr)
+		if err != nil {
+			return err
+		}
+		destAccs[addr] = destAcc
+
+		switch {
+		case exists && !hasTrustline(destAcc, *asset):
+			return i18n.Errorf("destination account %s needs to trust %v", accountName(m, addr), asset)
+		case !exists && !asset.BuilderAsset.Native:
+			return i18n.Errorf("destination account %s does not exist and needs to trust %v before receiving it, create it first with: send 2 XLM to %s", accountName(m, addr), asset, addr)
+		case exists && req.StartingBalance != "":
+			fmt.Printf("Destination %s already exists, the starting balance is ignored\n", accountName(m, addr))
+		}
+
+		created[addr] = !exists
 	}
 
-	destAcc, exists, err := getAccount(client, to)
+	stroops, err := baseFee(client)
 	if err != nil {
 		return err
 	}
 
-	if !hasTrustline(destAcc, *asset) {
-		return fmt.Errorf("destination account needs to trust %v", asset)
+	if req.Percent != "" {
+		ops := 1
+		reserved := xdr.Int64(0)
+		if created[to[0]] && req.StartingBalance != "" {
+			ops++
+			if reserved, err = amount.Parse(req.StartingBalance); err != nil {
+				return err
+			}
+		}
+		reserved += xdr.Int64(stroops) * xdr.Int64(ops)
+
+		resolved := *req // do not modify the statement
+		resolved.Amount, err = percentOfBalance(srcAcc, *asset, req.Percent, reserved)
+		if err != nil {
+			return err
+		}
+		req = &resolved
 	}
 
-	var amount interface{}
-	if asset.BuilderAsset.Native {
-		amount = build.NativeAmount{Amount: req.Amount}
-	} else {
-		amount = build.CreditAmount{
-			Code:   asset.BuilderAsset.Code,
-			Issuer: asset.BuilderAsset.Issuer,
-			Amount: req.Amount,
+	for _, addr := range to {
+		if err := checkLimit(accountName(m, addr), destAccs[addr], *asset, req.Amount); err != nil {
+			return err
 		}
 	}
 
-	var txnMutator build.TransactionMutator
-	if exists {
-		txnMutator = build.Payment(
-			build.Destination{AddressOrSeed: to},
-			amount,
-		)
-	} else {
-		txnMutator = build.CreateAccount(
-			build.Destination{AddressOrSeed: to},
-			amount,
-		)
+	sendAmount := paymentAmount(asset, req.Amount)
+
+	summary := map[string]string{
+		"Amount":   describeAmount(req),
+		"Currency": req.Currency,
+		"Source":   describeAccount(m, src.Address()),
+	}
+
+	var (
+		ops      []build.TransactionMutator
+		payments []outgoing
+	)
+	for _, addr := range to {
+		sent := req.Amount
+		if !created[addr] {
+			ops = append(ops, build.Payment(build.Destination{AddressOrSeed: addr}, sendAmount))
+		} else {
+			// without a CREATE clause the amount sent is the starting balance
+			startingBalance := req.StartingBalance
+			if startingBalance == "" {
+				startingBalance = req.Amount
+			}
+
+			if err := checkStartingBalance(startingBalance); err != nil {
+				return err
+			}
+
+			ops = append(ops, build.CreateAccount(build.Destination{AddressOrSeed: addr}, build.NativeAmount{Amount: startingBalance}))
+			if req.StartingBalance != "" {
+				ops = append(ops, build.Payment(build.Destination{AddressOrSeed: addr}, sendAmount))
+				if sent, err = addAmounts(req.Amount, req.StartingBalance); err != nil {
+					return err
+				}
+			}
+		}
+
+		payments = append(payments, outgoing{to: addr, amount: sent})
+
+		if len(to) == 1 {
+			summary["Destination"] = describeAccount(m, addr)
+			if created[addr] {
+				summary["Starting balance"] = formatAmount(startingBalanceOf(req), "XLM") + " XLM (creates the destination)"
+			}
+			continue
+		}
+
+		line := fmt.Sprintf("%s %s (%s)", formatAmount(req.Amount, req.Currency), req.Currency, addr)
+		if created[addr] {
+			line = fmt.Sprintf("%s %s, created with %s XLM (%s)", formatAmount(req.Amount, req.Currency), req.Currency, formatAmount(startingBalanceOf(req), "XLM"), addr)
+			if req.StartingBalance == "" {
+				line = fmt.Sprintf("%s XLM, creates the account (%s)", formatAmount(req.Amount, "XLM"), addr)
+			}
+		}
+		summary["Recipient "+accountName(m, addr)] = line
+	}
+
+	if len(to) > 1 {
+		total := xdr.Int64(0)
+		for _, p := range payments {
+			a, err := amount.Parse(p.amount)
+			if err != nil {
+				return err
+			}
+			total += a
+		}
+		summary["Amount"] = fmt.Sprintf("%s each, %s in total", formatAmount(req.Amount, req.Currency), formatStroops(total, req.Currency))
+	}
+
+	confirm, err := enforcePolicy(m, client, src.Address(), asset, payments)
+	if err != nil {
+		return err
+	}
+
+	seed, err := src.Seed()
+	if err != nil {
+		return err
 	}
 
 	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
+		build.SourceAccount{seed},
 		build.AutoSequence{SequenceProvider: client},
-		txnMutator,
 	}
+	if !hasTrustline(srcAcc, *asset) {
+		ops = append(ops, build.Trust(asset.BuilderAsset.Code, asset.BuilderAsset.Issuer))
+	}
+	opts = append(opts, ops...)
 	if memo != nil {
-		opts = append(opts, memo)
+		opts = append(opts, memo.ToTransactionMutator())
+	}
+
+	opts = append(opts, currentNetwork().mutator())
+
+	return submitTx(txRequest{
+		client:   client,
+		db:       m,
+		seeds:    []string{seed},
+		opts:     opts,
+		summary:  summary,
+		validFor: viper.GetDuration("valid-for"),
+		retries:  viper.GetInt("retries"),
+		presign:  viper.GetBool("presign"),
+		yes:      viper.GetBool("yes") && !confirm,
+	}.withTimeBounds(req.TimeBounds))
+}
+
+// resolveDestination returns the address of an address, a wallet or a
+// contact, with the memo expected by the contact
+func resolveDestination(m *wallet.Alfred, name string) (string, *wallet.Memo, error) {
+	if addr, err := keypair.Parse(name); err == nil { // to custom address
+		return addr.Address(), nil, nil
 	}
 
-	if !hasTrustline(srcAcc, *asset) {
-		opts = append(opts, build.Trust(asset.BuilderAsset.Code, asset.BuilderAsset.Issuer))
+	if w := m.WalletByName(name); w != nil { // between wallet
+		return w.Keypair.Address(), nil, nil
+	}
+
+	if _, contact, ok := m.Contact(name); ok { // to contact
+		return contact.Address, contact.Memo, nil
 	}
 
-	if viper.GetBool("testnet") {
-		opts = append(opts, build.TestNetwork)
+	return "", nil, i18n.Errorf("destination '%s' not found%s", name, suggestName(name, accountNames(m, true)))
+}
+
+// startingBalanceOf returns the starting balance of the accounts created by req
+func startingBalanceOf(req *parser.SendRequest) string {
+	if req.StartingBalance != "" {
+		return req.StartingBalance
+	}
+	return req.Amount
+}
+
+// percentOfBalance returns percent of the balance of asset held by acc.
+// For XLM, the minimum balance and reserved (fees and starting balance) are
+// deducted from the balance first.
+func percentOfBalance(acc horizon.Account, asset assets.Asset, percent string, reserved xdr.Int64) (string, error) {
+	var balance string
+	if asset.BuilderAsset.Native {
+		balance = acc.GetNativeBalance()
 	} else {
-		opts = append(opts, build.PublicNetwork)
+		balance = acc.GetCreditBalance(asset.BuilderAsset.Code, asset.BuilderAsset.Issuer)
+	}
+
+	available, err := amount.Parse(balance)
+	if err != nil {
+		return "", i18n.Errorf("no %s balance", asset.BuilderAsset.Code)
+	}
+
+	if asset.BuilderAsset.Native {
+		minimum, err := amount.Parse(strconv.FormatFloat(explain.MinimumBalance(acc), 'f', 7, 64))
+		if err != nil {
+			return "", err
+		}
+		available -= minimum + reserved
+	}
+
+	if available <= 0 {
+		return "", i18n.Errorf("nothing to send, the balance of %s is %s", acc.AccountID, balance)
+	}
+
+	share, ok := new(big.Rat).SetString(percent)
+	if !ok {
+		return "", i18n.Errorf("invalid percentage '%s'", percent)
+	}
+	share.Mul(share, big.NewRat(int64(available), 100))
+
+	stroops := new(big.Int).Quo(share.Num(), share.Denom()) // round down
+	if stroops.Sign() <= 0 {
+		return "", i18n.Errorf("nothing to send, %s%% of %s is below the smallest amount", percent, amount.String(available))
+	}
+
+	return amount.String(xdr.Int64(stroops.Int64())), nil
+}
+
+// describeAmount shows how the amount of a percentage was computed
+func describeAmount(req *parser.SendRequest) string {
+	amount := formatAmount(req.Amount, req.Currency)
+	switch req.Percent {
+	case "":
+		return amount
+	case "100":
+		return amount + " (all the balance)"
+	default:
+		return fmt.Sprintf("%s (%s%% of the balance)", amount, req.Percent)
+	}
+}
+
+// checkStartingBalance warns when a new account would be created below the
+// minimum balance, in which case the network rejects the creation
+func checkStartingBalance(startingBalance string) error {
+	f, err := strconv.ParseFloat(startingBalance, 64)
+	if err != nil {
+		return i18n.Errorf("invalid starting balance '%s'", startingBalance)
+	}
+
+	if minimum := explain.MinimumBalance(horizon.Account{}); f < minimum {
+		fmt.Printf("Warning: a starting balance of %s XLM is below the minimum balance of %v XLM, the creation of the account will fail\n", startingBalance, minimum)
+	}
+
+	return nil
+}
+
+// addAmounts sums two amounts without losing precision
+func addAmounts(a, b string) (string, error) {
+	x, err := amount.Parse(a)
+	if err != nil {
+		return "", err
 	}
 
-	tx, err := build.Transaction(opts...)
+	y, err := amount.Parse(b)
+	if err != nil {
+		return "", err
+	}
+
+	return amount.String(x + y), nil
+}
+
+func shareRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *parser.ShareAccountRequest) error {
+	getAddress := func(in string) keypair.KP {
+		if kp, err := keypair.Parse(in); err == nil { // to custom address
+			return kp
+		} else {
+			if w := m.WalletByName(in); w != nil { // between wallet
+				return w.Keypair
+			} else if _, contact, ok := m.Contact(in); ok { // to contact
+				return keypair.MustParse(contact.Address)
+			}
+		}
+		return nil
+	}
+
+	addr := getAddress(req.Account)
+	if addr == nil {
+		return i18n.Errorf("'%v' wallet not found%s", req.Account, suggestName(req.Account, accountNames(m, false)))
+	}
+
+	w := m.WalletByAddress(addr.Address())
+	if w == nil {
+		return i18n.Errorf("'%v' is not a wallet", req.Account)
+	}
+	src := keyOf(w)
+
+	prefetchAccounts(client, addr.Address())
+	for _, name := range req.AdditionnalSigners {
+		if signer := getAddress(name); signer != nil {
+			prefetchAccounts(client, signer.Address())
+		}
+	}
+
+	masterAcc, exists, err := getAccount(client, addr.Address())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return i18n.Errorf("'%v' does not exist, fund it first", req.Account)
+	}
+
+	var newSigners []string
+	for _, name := range req.AdditionnalSigners {
+		addr := getAddress(name)
+		if addr == nil {
+			return i18n.Errorf("address not found for '%v'%s", name, suggestName(name, accountNames(m, true)))
+		}
+
+		_, exists, err := getAccount(client, addr.Address())
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return i18n.Errorf("'%v' does not exist, fund it first", name)
+		}
+
+		newSigners = append(newSigners, addr.Address())
+	}
+
+	share, err := shareWeights(masterAcc, newSigners, req.Required, req.Total)
 	if err != nil {
 		return err
 	}
 
-	txe, err := tx.Sign(src.Seed())
+	return submitSigners(m, client, src, masterAcc, share, req.Required, req.Total)
+}
+
+func removeSignerRequest(m *wallet.Alfred, client *horizon.Client, req *parser.RemoveSignerRequest) error {
+	src, err := getOrSelectWallet(m, req.Account)
+	if err != nil {
+		return err
+	}
+
+	acc, exists, err := getAccount(client, src.Address())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return i18n.Errorf("'%v' does not exist", req.Account)
+	}
+
+	var signers []string
+	for _, name := range req.Signers {
+		addr, _, err := resolveDestination(m, name)
+		if err != nil {
+			return err
+		}
+		signers = append(signers, addr)
+	}
+
+	share, err := removeWeights(acc, signers, req.Required, req.Total)
 	if err != nil {
 		return err
 	}
 
-	txeB64, err := txe.Base64()
+	return submitSigners(m, client, src, acc, share, req.Required, req.Total)
+}
+
+// submitSigners changes the signers and thresholds of acc to share, signed
+// by the keys held in m needed to meet its current high threshold
+func submitSigners(m *wallet.Alfred, client *horizon.Client, src walletKey, acc horizon.Account, share *sharedAccount, required, total int) error {
+	local := int32(0)
+	summary := map[string]string{}
+	for key, weight := range share.weights {
+		if m.WalletByAddress(key) != nil {
+			local += weight
+		}
+		summary["Signer "+accountName(m, key)] = strconv.Itoa(int(weight))
+	}
+	for _, key := range share.removed {
+		summary["Signer "+accountName(m, key)] = "removed"
+	}
+	summary["Thresholds"] = fmt.Sprintf("low %d, medium %d, high %d", share.low, share.medium, share.high)
+
+	if required > 0 {
+		if local < int32(required) {
+			fmt.Printf("Warning: the keys held in the database weigh %d, %d signatures will be needed: transactions will also have to be signed by other signers\n", local, required)
+		}
+		if required == total && total > 1 {
+			fmt.Println("Warning: every signer will be required, losing any of their keys locks the account forever")
+		}
+	}
+
+	seeds, err := signingSeeds(m, acc, src)
 	if err != nil {
 		return err
 	}
 
-	if !viper.GetBool("yes") {
-		printSummaryTable(map[string]string{
-			"Amount":      req.Amount,
-			"Currency":    req.Currency,
-			"Source":      src.Address(),
-			"Destination": to,
-		})
+	// a set options operation adds or removes a single signer, the last one
+	// sets the master weight and thresholds once every signer is in place
+	opts := []build.TransactionMutator{
+		build.SourceAccount{seeds[0]},
+		build.AutoSequence{SequenceProvider: client},
+	}
+	for _, key := range share.removed {
+		opts = append(opts, build.SetOptions(build.RemoveSigner(key)))
+	}
+	for _, key := range share.changed {
+		opts = append(opts, build.SetOptions(build.AddSigner(key, uint32(share.weights[key]))))
+	}
+	opts = append(opts, build.SetOptions(
+		build.MasterWeight(uint32(share.weights[acc.AccountID])),
+		build.SetThresholds(share.low, share.medium, share.high),
+	))
+
+	opts = append(opts, currentNetwork().mutator())
+
+	return submitTx(txRequest{
+		client:   client,
+		db:       m,
+		seeds:    seeds,
+		opts:     opts,
+		summary:  summary,
+		validFor: viper.GetDuration("valid-for"),
+		retries:  viper.GetInt("retries"),
+		presign:  viper.GetBool("presign"),
+		yes:      viper.GetBool("yes"),
+	})
+}
+
+// signingSeeds returns the seed of src, followed by the ones of the other
+// signers of acc held in m until they weigh enough to meet its high threshold
+func signingSeeds(m *wallet.Alfred, acc horizon.Account, src walletKey) ([]string, error) {
+	seed, err := src.Seed()
+	if err != nil {
+		return nil, err
+	}
+
+	seeds := []string{seed}
+	var weight int32
+	for _, s := range acc.Signers {
+		if signerKey(s) == src.Address() {
+			weight = s.Weight
+		}
+	}
+
+	for _, s := range acc.Signers {
+		if weight > 0 && weight >= int32(acc.Thresholds.HighThreshold) {
+			break
+		}
 
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
+		w := m.WalletByAddress(signerKey(s))
+		if w == nil || s.Weight == 0 || signerKey(s) == src.Address() {
+			continue
+		}
+		seed, err := keyOf(w).Seed()
 		if err != nil {
-			return err
+			return nil, err
 		}
+		seeds = append(seeds, seed)
+		weight += s.Weight
 	}
 
-	resp, err := client.SubmitTransaction(txeB64)
-	if err != nil {
-		return err
-	}
+	return seeds, nil
+}
 
-	fmt.Println(resp.Hash)
-	return nil
+// sharedAccount is the weights of the signers and the thresholds of an
+// account once shared
+type sharedAccount struct {
+	weights           map[string]int32
+	low, medium, high uint32
+	// changed lists the signers, other than the account itself, to add or
+	// reweigh, and removed the ones to remove
+	changed, removed []string
 }
 
-func shareRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *parser.ShareAccountRequest) error {
-	getAddress := func(in string) keypair.KP {
-		if kp, err := keypair.Parse(in); err == nil { // to custom address
-			return kp
-		} else {
-			if w := m.WalletByName(in); w != nil { // between wallet
-				return w.Keypair
-			} else if contact, ok := m.Stellar.Contacts[in]; ok { // to contact
-				return keypair.MustParse(contact.Address)
-			}
+// currentSigners returns the signers of acc and their weights, the account
+// itself included. Only keys can be part of an m of n account.
+func currentSigners(acc horizon.Account, mOfN bool) (*sharedAccount, error) {
+	share := &sharedAccount{weights: map[string]int32{}}
+	for _, s := range acc.Signers {
+		key := signerKey(s)
+		if s.Weight == 0 && key != acc.AccountID {
+			continue
 		}
-		return nil
-	}
-
-	addr := getAddress(req.Account)
-	if addr == nil {
-		return fmt.Errorf("'%v' wallet not found", req.Account)
+		if mOfN && s.Type != "" && s.Type != "ed25519_public_key" {
+			return nil, i18n.Errorf("signer %s is a %s, which can not be part of an m of n account", key, s.Type)
+		}
+		share.weights[key] = s.Weight
 	}
+	share.low = uint32(acc.Thresholds.LowThreshold)
+	share.medium = uint32(acc.Thresholds.MedThreshold)
+	share.high = uint32(acc.Thresholds.HighThreshold)
 
-	src := addr.(*keypair.Full)
+	return share, nil
+}
 
-	masterAcc, exists, err := getAccount(client, addr.Address())
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return fmt.Errorf("'%v' does not exist, fund it first", req.Account)
+// requiring makes every signer of acc weigh 1 and sets every threshold to
+// required, once the account has total signers, itself included
+func (share *sharedAccount) requiring(acc horizon.Account, required, total int) error {
+	if len(share.weights) != total {
+		return i18n.Errorf("the account would have %d signers, itself included, not %d", len(share.weights), total)
 	}
 
-	var newSigners []horizon.Account
-	for _, name := range req.AdditionnalSigners {
-		addr := getAddress(name)
-		if addr == nil {
-			return fmt.Errorf("address not found for '%v'", name)
-		}
-
-		acc, exists, err := getAccount(client, addr.Address())
-		if err != nil {
-			return err
-		}
-		if !exists {
-			return fmt.Errorf("'%v' does not exist, fund it first", name)
+	for _, s := range acc.Signers {
+		if key := signerKey(s); key != acc.AccountID && share.weights[key] > 1 {
+			share.weights[key] = 1
+			share.changed = append(share.changed, key)
 		}
-
-		newSigners = append(newSigners, acc)
 	}
+	share.weights[acc.AccountID] = 1
+	share.low, share.medium, share.high = uint32(required), uint32(required), uint32(required)
 
-	threshold := uint32(1 + len(req.AdditionnalSigners) + len(masterAcc.Signers))
+	return nil
+}
 
-	var sopts []interface{}
-	for _, acc := range newSigners {
-		sopts = append(sopts, build.AddSigner(acc.AccountID, 1))
+// shareWeights computes the weights and thresholds of acc shared with signers.
+//
+// With required and total set (REQUIRING m OF n), every signer weighs 1 and
+// every threshold is required. Otherwise any signer can make payments alone
+// but only the account itself can change the signers: its weight is the
+// high threshold, one more than the other signers weigh together.
+func shareWeights(acc horizon.Account, signers []string, required, total int) (*sharedAccount, error) {
+	share, err := currentSigners(acc, required > 0)
+	if err != nil {
+		return nil, err
 	}
-	sopts = append(sopts,
-		build.MasterWeight(threshold),
-		build.SetThresholds(1, 1, threshold),
-	)
 
-	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
-		build.AutoSequence{SequenceProvider: client},
-		build.SetOptions(sopts...),
+	for _, key := range signers {
+		if key == acc.AccountID {
+			return nil, errors.New("an account can not be shared with itself")
+		}
+		if _, ok := share.weights[key]; !ok {
+			share.weights[key] = 1
+			share.changed = append(share.changed, key)
+		}
 	}
 
-	if viper.GetBool("testnet") {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
+	if required > 0 {
+		return share, share.requiring(acc, required, total)
 	}
 
-	tx, err := build.Transaction(opts...)
-	if err != nil {
-		return err
+	var others int32
+	for key, weight := range share.weights {
+		if key != acc.AccountID {
+			others += weight
+		}
 	}
+	share.weights[acc.AccountID] = others + 1
+	share.low, share.medium, share.high = 1, 1, uint32(others+1)
+	return share, nil
+}
 
-	txe, err := tx.Sign(src.Seed())
+// removeWeights computes the weights and thresholds of acc without signers.
+//
+// With required and total set, the signers left become an m of n account.
+// Otherwise the account gets its default weight and thresholds back when it
+// is its only signer left, and the thresholds the signers left can not meet
+// are lowered to their total weight.
+func removeWeights(acc horizon.Account, signers []string, required, total int) (*sharedAccount, error) {
+	share, err := currentSigners(acc, false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	txeB64, err := txe.Base64()
-	if err != nil {
-		return err
+	for _, key := range signers {
+		if key == acc.AccountID {
+			return nil, errors.New("the account can not be removed from its own signers")
+		}
+		if _, ok := share.weights[key]; !ok {
+			return nil, i18n.Errorf("%s is not a signer of the account", key)
+		}
+		delete(share.weights, key)
+		share.removed = append(share.removed, key)
 	}
 
-	if !viper.GetBool("yes") {
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
+	if required > 0 {
+		for key := range share.weights {
+			if _, err := keypair.Parse(key); err != nil {
+				return nil, i18n.Errorf("signer %s can not be part of an m of n account", key)
+			}
 		}
+		return share, share.requiring(acc, required, total)
 	}
 
-	resp, err := client.SubmitTransaction(txeB64)
-	if err != nil {
-		return err
+	if len(share.weights) == 1 {
+		share.weights[acc.AccountID] = 1
+		share.low, share.medium, share.high = 0, 0, 0
+		return share, nil
 	}
 
-	fmt.Println(resp.Hash)
-	return nil
+	var left uint32
+	for _, weight := range share.weights {
+		left += uint32(weight)
+	}
+	for _, threshold := range []*uint32{&share.low, &share.medium, &share.high} {
+		if *threshold > left {
+			*threshold = left
+		}
+	}
+	return share, nil
 }
 
 func setData(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *parser.SetDataRequest) error {
@@ -385,6 +1073,11 @@ func setData(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *
 		return err
 	}
 
+	acc, _, err := getAccount(client, src.Address())
+	if err != nil {
+		return err
+	}
+
 	var sopts []build.TransactionMutator
 	for key, value := range req.KVs {
 		var data []byte
@@ -399,54 +1092,94 @@ func setData(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *
 			}
 		}
 
-		sopts = append(sopts, build.SetData(key, data))
+		// values too large for a single entry are split into key-0..key-n,
+		// the entries left from a previous value are removed
+		chunks, err := dataChunks(key, data)
+		if err != nil {
+			return err
+		}
+		for _, c := range chunks {
+			sopts = append(sopts, build.SetData(c.key, c.value))
+		}
+		for _, stale := range staleDataKeys(acc, key, chunks) {
+			sopts = append(sopts, build.ClearData(stale))
+		}
+	}
+	if len(sopts) > maxDataOperations {
+		return i18n.Errorf("the values need %d operations, a transaction can only hold %d", len(sopts), maxDataOperations)
+	}
+
+	seed, err := src.Seed()
+	if err != nil {
+		return err
 	}
 
 	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
+		build.SourceAccount{seed},
 		build.AutoSequence{SequenceProvider: client},
 	}
 
 	opts = append(opts, sopts...)
 
-	if viper.GetBool("testnet") {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
-	}
+	opts = append(opts, currentNetwork().mutator())
+
+	return submitTx(txRequest{
+		client:   client,
+		db:       m,
+		seeds:    []string{seed},
+		opts:     opts,
+		validFor: viper.GetDuration("valid-for"),
+		retries:  viper.GetInt("retries"),
+		presign:  viper.GetBool("presign"),
+		yes:      viper.GetBool("yes"),
+	})
+}
 
-	tx, err := build.Transaction(opts...)
+func deleteData(m *wallet.Alfred, client *horizon.Client, req *parser.DeleteDataRequest) error {
+	src, err := getOrSelectWallet(m, req.Account)
 	if err != nil {
 		return err
 	}
 
-	txe, err := tx.Sign(src.Seed())
+	acc, exists, err := getAccount(client, src.Address())
 	if err != nil {
 		return err
 	}
+	if !exists {
+		return i18n.Errorf("account %s does not exist", src.Address())
+	}
 
-	txeB64, err := txe.Base64()
+	seed, err := src.Seed()
 	if err != nil {
 		return err
 	}
 
-	if !viper.GetBool("yes") {
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
+	opts := []build.TransactionMutator{
+		build.SourceAccount{seed},
+		build.AutoSequence{SequenceProvider: client},
+	}
+	for _, key := range req.Keys {
+		stale := staleDataKeys(acc, key, nil)
+		if len(stale) == 0 {
+			return i18n.Errorf("no data entry '%s' on %s", key, accountName(m, src.Address()))
+		}
+		for _, k := range stale {
+			opts = append(opts, build.ClearData(k))
 		}
 	}
 
-	resp, err := client.SubmitTransaction(txeB64)
-	if err != nil {
-		return err
-	}
+	opts = append(opts, currentNetwork().mutator())
 
-	fmt.Println(resp.Hash)
-	return nil
+	return submitTx(txRequest{
+		client:   client,
+		db:       m,
+		seeds:    []string{seed},
+		opts:     opts,
+		validFor: viper.GetDuration("valid-for"),
+		retries:  viper.GetInt("retries"),
+		presign:  viper.GetBool("presign"),
+		yes:      viper.GetBool("yes"),
+	})
 }
 
 func createOffer(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *parser.Offer) error {
@@ -465,128 +1198,289 @@ func createOffer(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, r
 		return err
 	}
 
-	amount, err := strconv.ParseFloat(req.Amount, 64)
+	amount, err := decimal.Parse(req.Amount)
 	if err != nil {
 		return err
 	}
 
-	price := req.Price
-	if price == "" {
-		var (
-			book      horizon.OrderBookSummary
-			priceLvls []horizon.PriceLevel
-		)
+	// prices are quoted in the other asset per asset traded, such as XLM per MOBI
+	base, counter := buying, selling
+	if req.Kind() == parser.SellOfferKind {
+		base, counter = selling, buying
+	}
+
+	summary := map[string]string{
+		"Buying":  buying.String(),
+		"Selling": selling.String(),
+	}
+
+	minPrice, maxPrice, err := priceGuards(req)
+	if err != nil {
+		return err
+	}
 
-		book, err = client.LoadOrderBook(selling.ToHorizonAsset(), buying.ToHorizonAsset())
+	var (
+		price *big.Rat
+		quote *pricing.Quote
+	)
+	if req.Price != "" {
+		price, err = decimal.ParsePrice(req.Price)
 		if err != nil {
 			return err
 		}
-
-		switch req.Kind() {
-		case parser.BuyOfferKind:
-			priceLvls = book.Bids
-		case parser.SellOfferKind:
-			priceLvls = book.Bids
+		if err := checkPriceGuards("the price", price, minPrice, maxPrice, base, counter); err != nil {
+			return err
+		}
+	} else {
+		book, err := getOrderBook(client, base.ToHorizonAsset(), counter.ToHorizonAsset())
+		if err != nil {
+			return err
 		}
 
-		if len(priceLvls) == 0 {
-			return errors.New("no offers found in the orderbook, you should specify a price")
+		q, err := quoteOffer(book, req, amount)
+		if err == pricing.ErrThin {
+			return errors.New("the order book can not fill the amount, you should specify a price")
+		} else if err != nil {
+			return err
 		}
 
-		price = priceLvls[0].Price
+		max := maxSlippage()
+		if q.Slippage.Cmp(percent(max)) > 0 {
+			return i18n.Errorf("the average price of %s is %s%% worse than the best price of %s, above the maximum slippage of %g%% (--max-slippage)",
+				formatPrice(q.AveragePrice, base, counter), q.Slippage.FloatString(2), formatPrice(q.BestPrice, base, counter), max)
+		}
 
-		p, err := strconv.ParseFloat(price, 64)
+		// the limit is rounded to the 7 decimals of the network within the slippage
+		limit, err := decimal.FromRat(pricing.SellLimit(q.BestPrice, percent(max)), decimal.Up)
+		if req.Kind() == parser.BuyOfferKind {
+			limit, err = decimal.FromRat(pricing.BuyLimit(q.BestPrice, percent(max)), decimal.Down)
+		}
 		if err != nil {
 			return err
 		}
-		switch req.Kind() {
-		case parser.BuyOfferKind:
-			switch req.AmountKind {
-			case parser.AmountBuyKind:
-				amount /= p
-			case parser.AmountSellKind:
+		price = limit.Rat()
 
-			}
-		case parser.SellOfferKind:
+		// a manipulated book can not make the offer trade beyond the guards
+		if err := checkPriceGuards("the best price of the order book", q.BestPrice, minPrice, maxPrice, base, counter); err != nil {
+			return err
 		}
+		if minPrice != nil && price.Cmp(minPrice) < 0 {
+			price = minPrice
+		}
+		if maxPrice != nil && price.Cmp(maxPrice) > 0 {
+			price = maxPrice
+		}
+		quote = &q
+		summary["Average price"] = fmt.Sprintf("%s (%s%% from the best price)", formatPrice(q.AveragePrice, base, counter), q.Slippage.FloatString(2))
+	}
+	summary["Price"] = fmt.Sprintf("%s %s per %s", formatAmount(decimal.FormatPrice(price), ""), counter.CodeString(), base.CodeString())
+
+	// the offer sells an amount of the selling asset, its price is in buying
+	// per selling. The amount sold is rounded up so that the amount asked is
+	// bought or received, the amount received down.
+	var sold decimal.Amount
+	switch {
+	case req.Kind() == parser.BuyOfferKind && req.AmountKind == parser.AmountSellKind:
+		sold = amount
+	case req.Kind() == parser.BuyOfferKind && quote != nil:
+		sold = quote.Total
+	case req.Kind() == parser.BuyOfferKind:
+		sold, err = amount.Mul(price, decimal.Up)
+	case req.AmountKind == parser.AmountSellKind && quote != nil:
+		sold = quote.Amount
+	case req.AmountKind == parser.AmountSellKind:
+		sold, err = amount.Quo(price, decimal.Up)
+	default:
+		sold = amount
+	}
+	if err != nil {
+		return i18n.Errorf("invalid amount: %v", err)
 	}
 
-	strAmount := strconv.FormatFloat(amount, 'f', 7, 64)
-	var amountDescr string
-	switch req.Kind() {
-	case parser.BuyOfferKind:
-		amountDescr = fmt.Sprintf("%s %s = %f %s", req.Amount, buying.CodeString(), amount, selling.CodeString())
-	case parser.SellOfferKind:
-		amountDescr = fmt.Sprintf("%f %s", amount, selling.CodeString())
+	ratePrice := price
+	if req.Kind() == parser.BuyOfferKind {
+		ratePrice = new(big.Rat).Inv(price)
+	}
+
+	received, err := sold.Mul(ratePrice, decimal.Down)
+	if err != nil {
+		return i18n.Errorf("invalid amount: %v", err)
+	}
+
+	strAmount := sold.String()
+	summary["Amount"] = fmt.Sprintf("%s %s for at least %s %s", formatAmount(strAmount, selling.CodeString()), selling.CodeString(), formatAmount(received.String(), buying.CodeString()), buying.CodeString())
+
+	rate := build.Rate{
+		Buying:  buying.BuilderAsset,
+		Selling: selling.BuilderAsset,
+		// an exact fraction, such as 10/3
+		Price: build.Price(ratePrice.RatString()),
+	}
+	// a passive offer does not take the offers at the same price
+	offer := build.CreateOffer(rate, build.Amount(strAmount))
+	if req.Passive {
+		offer = build.CreatePassiveOffer(rate, build.Amount(strAmount))
+	}
+
+	seed, err := src.Seed()
+	if err != nil {
+		return err
 	}
 
 	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
+		build.SourceAccount{seed},
 		build.AutoSequence{SequenceProvider: client},
-		build.CreateOffer(build.Rate{
-			Buying:  buying.BuilderAsset,
-			Selling: selling.BuilderAsset,
-			Price:   build.Price(price),
-		}, build.Amount(strAmount)),
+		offer,
 	}
 
-	if viper.GetBool("testnet") {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
+	opts = append(opts, currentNetwork().mutator())
+
+	txReq := txRequest{
+		client:   client,
+		db:       m,
+		seeds:    []string{seed},
+		opts:     opts,
+		summary:  summary,
+		validFor: viper.GetDuration("valid-for"),
+		retries:  viper.GetInt("retries"),
+		presign:  viper.GetBool("presign"),
+		yes:      viper.GetBool("yes"),
+	}.withTimeBounds(req.TimeBounds)
+	if req.Passive {
+		txReq.summary["Passive"] = "yes, it does not take the offers at the same price"
 	}
 
-	tx, err := build.Transaction(opts...)
-	if err != nil {
+	if req.Expires == 0 {
+		return submitTx(txReq)
+	}
+
+	if txReq.presign {
+		return errors.New("an offer with EXPIRES IN should be submitted now, so that alfred daemon can cancel it")
+	}
+	if err := requireHot(m, src); err != nil {
 		return err
 	}
+	txReq.summary["Expires"] = time.Now().Add(req.Expires).Format(time.RFC1123)
 
-	txe, err := tx.Sign(src.Seed())
+	known, err := offerIDs(client, src.Address())
 	if err != nil {
 		return err
 	}
 
-	txeB64, err := txe.Base64()
-	if err != nil {
+	if err := submitTx(txReq); err != nil {
 		return err
 	}
 
-	if !viper.GetBool("yes") {
-		printSummaryTable(map[string]string{
-			"Amount":  amountDescr,
-			"Buying":  buying.String(),
-			"Selling": selling.String(),
-			"Price":   price,
-		})
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
+	return trackOffers(m, client, src.Address(), known, req.Expires)
+}
+
+// quoteOffer estimates the trade of req against book, loaded with the asset traded as base
+func quoteOffer(book horizon.OrderBookSummary, req *parser.Offer, amount decimal.Amount) (pricing.Quote, error) {
+	switch {
+	case req.Kind() == parser.BuyOfferKind && req.AmountKind == parser.AmountSellKind:
+		return pricing.Spend(book.Asks, amount)
+	case req.Kind() == parser.BuyOfferKind:
+		return pricing.Buy(book.Asks, amount)
+	case req.AmountKind == parser.AmountSellKind:
+		return pricing.Receive(book.Bids, amount)
+	default:
+		return pricing.Sell(book.Bids, amount)
+	}
+}
+
+// priceGuards returns the lowest price of a sell, from NO LOWER THAN or
+// --min-price, and the highest price of a buy, from NO HIGHER THAN or
+// --max-price, nil when they are not set
+func priceGuards(req *parser.Offer) (minPrice, maxPrice *big.Rat, err error) {
+	if req.Kind() == parser.SellOfferKind {
+		min := req.MinPrice
+		if min == "" {
+			min = viper.GetString("min-price")
 		}
+		if min != "" {
+			minPrice, err = decimal.ParsePrice(min)
+		}
+		return minPrice, nil, err
 	}
 
-	resp, err := client.SubmitTransaction(txeB64)
-	if err != nil {
-		return err
+	max := req.MaxPrice
+	if max == "" {
+		max = viper.GetString("max-price")
+	}
+	if max != "" {
+		maxPrice, err = decimal.ParsePrice(max)
+	}
+	return nil, maxPrice, err
+}
+
+// checkPriceGuards returns an error when price, described by what, is beyond
+// the guards of an offer
+func checkPriceGuards(what string, price, minPrice, maxPrice *big.Rat, base, counter *assets.Asset) error {
+	format := func(r *big.Rat) string {
+		return fmt.Sprintf("%s %s per %s", decimal.FormatPrice(r), counter.CodeString(), base.CodeString())
 	}
 
-	fmt.Println(resp.Hash)
+	switch {
+	case minPrice != nil && price.Cmp(minPrice) < 0:
+		return i18n.Errorf("%s, %s, is below the minimum of %s (NO LOWER THAN or --min-price)", what, format(price), format(minPrice))
+	case maxPrice != nil && price.Cmp(maxPrice) > 0:
+		return i18n.Errorf("%s, %s, is above the maximum of %s (NO HIGHER THAN or --max-price)", what, format(price), format(maxPrice))
+	}
 	return nil
 }
 
+// percent returns a percentage of the settings, such as 0.5, as the fraction
+// written in decimal rather than its float64 approximation
+func percent(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return r
+}
+
+// defaultMaxSlippage is the maximum slippage of the trades, in percent, when the max-slippage setting is not set
+const defaultMaxSlippage = 1.0
+
+// maxSlippage returns the percentage by which the average price of a trade
+// may be worse than the best price of the order book
+func maxSlippage() float64 {
+	return viper.GetFloat64("max-slippage")
+}
+
+// formatPrice formats a price in counter per base, such as 0.2100000 XLM per MOBI
+func formatPrice(price *big.Rat, base, counter *assets.Asset) string {
+	return fmt.Sprintf("%s %s per %s", formatAmount(decimal.FormatPrice(price), ""), counter.CodeString(), base.CodeString())
+}
+
+// paymentAmount returns the amount of asset sent by a payment
+func paymentAmount(asset *assets.Asset, amount string) interface{} {
+	if asset.BuilderAsset.Native {
+		return build.NativeAmount{Amount: amount}
+	}
+
+	return build.CreditAmount{
+		Code:   asset.BuilderAsset.Code,
+		Issuer: asset.BuilderAsset.Issuer,
+		Amount: amount,
+	}
+}
+
 func hasTrustline(acc horizon.Account, asset assets.Asset) bool {
 	if asset.BuilderAsset.Native {
 		return true
 	}
+	_, ok := trustline(acc, asset)
+	return ok
+}
+
+// trustline returns the balance of the trustline of acc to asset, which holds
+// its limit
+func trustline(acc horizon.Account, asset assets.Asset) (horizon.Balance, bool) {
 	for _, b := range acc.Balances {
 		if b.Code == asset.BuilderAsset.Code && b.Issuer == asset.BuilderAsset.Issuer {
-			return true
+			return b, true
 		}
 	}
 
-	return false
+	return horizon.Balance{}, false
 }
 
 func getAddress(m *wallet.Alfred, in string) keypair.KP {
@@ -595,16 +1489,88 @@ func getAddress(m *wallet.Alfred, in string) keypair.KP {
 	} else {
 		if w := m.WalletByName(in); w != nil { // between wallet
 			return w.Keypair
-		} else if contact, ok := m.Stellar.Contacts[in]; ok { // to contact
+		} else if _, contact, ok := m.Contact(in); ok { // to contact
 			return keypair.MustParse(contact.Address)
 		}
 	}
 	return nil
 }
 
-func selectWallet(m *wallet.Alfred) (*keypair.Full, error) {
+// walletKey is the key of a wallet. The seed of a cold wallet is not stored,
+// its Seed returns its address instead: build.SourceAccount accepts both, and
+// submitTx exports the transactions it should sign for offline signing. Seed
+// fails when the seed can not be decrypted, such as with a wrong passphrase.
+type walletKey interface {
+	Address() string
+	Seed() (string, error)
+}
+
+// fullKey is the walletKey of a wallet whose seed is decrypted
+type fullKey struct {
+	*keypair.Full
+}
+
+// Seed returns the seed of the wallet
+func (k fullKey) Seed() (string, error) { return k.Full.Seed(), nil }
+
+// coldKey is the walletKey of a cold wallet
+type coldKey struct {
+	*keypair.FromAddress
+}
+
+// Seed returns the address of the cold wallet
+func (k coldKey) Seed() (string, error) { return k.Address(), nil }
+
+// keyOf returns the key of w
+func keyOf(w *wallet.Wallet) walletKey {
+	if kp, ok := w.Keypair.(*keypair.Full); ok {
+		return fullKey{kp}
+	}
+	if w.IsProtected() {
+		return protectedKey{w}
+	}
+
+	return coldKey{w.Keypair.(*keypair.FromAddress)}
+}
+
+// hotKey returns the full keypair of w, for the commands which can not export
+// their transactions for offline signing
+func hotKey(w *wallet.Wallet) (*keypair.Full, error) {
+	if w.IsCold() {
+		return nil, i18n.Errorf("wallet %s is cold, its seed lives at: %s", w.Name, w.Cold)
+	}
+	if w.IsProtected() {
+		return unwrap(w)
+	}
+
+	return w.Keypair.(*keypair.Full), nil
+}
+
+// requireHot returns an error if kp is the key of a cold wallet, for the
+// commands whose transaction should be submitted right away
+func requireHot(m *wallet.Alfred, kp walletKey) error {
+	if _, ok := kp.(coldKey); ok {
+		return i18n.Errorf("wallet %s is cold, its transactions are signed offline but this one should be submitted right away", accountName(m, kp.Address()))
+	}
+
+	return nil
+}
+
+func selectWallet(m *wallet.Alfred) (walletKey, error) {
+	if name := viper.GetString("wallet"); name != "" { // default wallet, such as the one of the profile
+		w := m.WalletByName(name)
+		if w == nil {
+			return nil, i18n.Errorf("default wallet '%s' not found%s", name, suggestName(name, accountNames(m, false)))
+		}
+		return keyOf(w), nil
+	}
+
+	if err := checkInteractive("wallet", "no wallet given", "FROM <wallet> or --wallet"); err != nil {
+		return nil, err
+	}
+
 	sel := promptui.Select{
-		Label: "Select Wallet",
+		Label: i18n.T("Select Wallet"),
 		Items: m.Stellar.Wallets,
 	}
 
@@ -613,7 +1579,20 @@ func selectWallet(m *wallet.Alfred) (*keypair.Full, error) {
 		return nil, err
 	}
 
-	return m.Stellar.Wallets[idx].Keypair.(*keypair.Full), nil
+	return keyOf(m.Stellar.Wallets[idx]), nil
+}
+
+// assetByIssuer returns the asset of asts issued by one of the issuers set
+// with --issuer, if any
+func assetByIssuer(asts []assets.Asset) *assets.Asset {
+	for _, issuer := range viper.GetStringSlice("issuer") {
+		for _, a := range asts {
+			if a.BuilderAsset.Issuer == issuer {
+				return &a
+			}
+		}
+	}
+	return nil
 }
 
 func selectAsset(cur string) (*assets.Asset, error) {
@@ -621,17 +1600,33 @@ func selectAsset(cur string) (*assets.Asset, error) {
 		cur = "XLM"
 	}
 
+	// a token given by its contract, such as USDC@C..., is the classic asset
+	// whose Stellar Asset Contract it is
+	if i := strings.LastIndex(cur, "@"); i >= 0 && assets.IsContract(cur[i+1:]) {
+		return assets.GetByContract(strings.ToUpper(cur[:i]), cur[i+1:], currentNetwork().Passphrase)
+	}
+
 	asts := assets.GetAssets(cur)
 	if len(asts) == 0 {
-		return nil, fmt.Errorf("asset %v is not supported right now", cur)
+		return nil, i18n.Errorf("asset %v is not supported right now%s", cur, suggestName(cur, assetCodes()))
 	}
 
 	var asset assets.Asset
 	if len(asts) == 1 { // only one we check this
 		asset = asts[0]
+	} else if a := assetByIssuer(asts); a != nil {
+		asset = *a
 	} else { // otherwise, prompt
+		var issuers []string
+		for _, a := range asts {
+			issuers = append(issuers, a.BuilderAsset.Issuer)
+		}
+		if err := checkInteractive("asset", fmt.Sprintf("several assets are named %s", cur), "--issuer, one of "+strings.Join(issuers, ", ")); err != nil {
+			return nil, err
+		}
+
 		idx, _, err := (&promptui.Select{
-			Label: "Choose currency",
+			Label: i18n.T("Choose currency"),
 			Items: asts,
 		}).Run()
 		if err != nil {
@@ -644,7 +1639,92 @@ func selectAsset(cur string) (*assets.Asset, error) {
 	return &asset, nil
 }
 
-func getOrSelectWallet(m *wallet.Alfred, from string) (src *keypair.Full, err error) {
+// detectAsset returns the asset of a payment from source to the addresses of
+// to given without one: the one held by source and trusted by every
+// destination, or the one chosen at a prompt when there are several, XLM
+// being the default
+func detectAsset(m *wallet.Alfred, client *horizon.Client, source string, to []string) (*assets.Asset, error) {
+	srcAcc, exists, err := getAccount(client, source)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("source account does not exist, please fund it first")
+	}
+
+	var (
+		candidates []assets.Asset
+		labels     []string
+	)
+	for _, b := range srcAcc.Balances {
+		if held, err := amount.Parse(b.Balance); err != nil || held <= 0 {
+			continue
+		}
+
+		a := assets.Asset{BuilderAsset: build.NativeAsset()}
+		label := "XLM"
+		if b.Asset.Type != "native" {
+			a = assets.Asset{BuilderAsset: build.CreditAsset(b.Asset.Code, b.Asset.Issuer)}
+			if known := assets.GetByCodeIssuer(b.Asset.Code, b.Asset.Issuer); known != nil {
+				a = *known
+			}
+			label = describeHorizonAsset(m, b.Asset)
+		}
+
+		receivable := true
+		for _, addr := range to {
+			destAcc, exists, err := getAccount(client, addr)
+			if err != nil {
+				return nil, err
+			}
+			if (!exists && !a.BuilderAsset.Native) || (exists && !hasTrustline(destAcc, a)) {
+				receivable = false
+				break
+			}
+		}
+		if !receivable {
+			continue
+		}
+
+		label = fmt.Sprintf("%s, %s held", label, formatAmount(b.Balance, a.CodeString()))
+		// XLM comes first, as the default
+		if a.BuilderAsset.Native {
+			candidates, labels = append([]assets.Asset{a}, candidates...), append([]string{label}, labels...)
+		} else {
+			candidates, labels = append(candidates, a), append(labels, label)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		names := make([]string, len(to))
+		for i, addr := range to {
+			names[i] = accountName(m, addr)
+		}
+		return nil, i18n.Errorf("none of the assets held by %s can be received by %s, give the asset of the payment", accountName(m, source), strings.Join(names, " and "))
+	case 1:
+		return &candidates[0], nil
+	}
+
+	var codes []string
+	for _, a := range candidates {
+		codes = append(codes, a.CodeString())
+	}
+	if err := checkInteractive("asset", "no asset given and several can be sent", "the asset in the statement, one of "+strings.Join(codes, ", ")); err != nil {
+		return nil, err
+	}
+
+	idx, _, err := (&promptui.Select{
+		Label: i18n.T("Choose currency"),
+		Items: labels,
+	}).Run()
+	if err != nil {
+		return nil, err
+	}
+	return &candidates[idx], nil
+}
+
+func getOrSelectWallet(m *wallet.Alfred, from string) (src walletKey, err error) {
 	if from != "" {
 		var w *wallet.Wallet
 		if addr, err := keypair.Parse(from); err == nil {
@@ -654,10 +1734,10 @@ func getOrSelectWallet(m *wallet.Alfred, from string) (src *keypair.Full, err er
 		}
 
 		if w == nil {
-			return nil, fmt.Errorf("wallet '%s' not found", from)
+			return nil, i18n.Errorf("wallet '%s' not found%s", from, suggestName(from, accountNames(m, false)))
 		}
 
-		src = w.Keypair.(*keypair.Full)
+		src = keyOf(w)
 	} else {
 		src, err = selectWallet(m)
 		if err != nil {
@@ -669,17 +1749,14 @@ func getOrSelectWallet(m *wallet.Alfred, from string) (src *keypair.Full, err er
 }
 
 func printSummaryTable(kvs map[string]string) {
-	table := tablewriter.NewWriter(os.Stdout)
+	table := newTable(os.Stdout)
 	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	// the accounts are named with their full address, kept on one line
+	table.SetAutoWrapText(false)
 
-	network := "PUBLIC"
-	if viper.GetBool("testnet") {
-		network = "TESTNET"
-	}
-
-	kvs["Network"] = network
+	kvs["Network"] = strings.ToUpper(currentNetwork().Name)
 	for k, v := range kvs {
-		table.Append([]string{k, v})
+		table.Append([]string{i18n.T(k), v})
 	}
 	table.Render()
 }
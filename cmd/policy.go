@@ -0,0 +1,314 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// policyCmd represents the policy command
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Display the spending policy of a wallet and its violations",
+	Long: `Display the spending policy of a wallet and the payments sent despite it.
+
+A policy can limit the amount of XLM sent per day, always require a
+confirmation above an amount and deny destinations which are not contacts.
+It is checked before sending a payment, --override-policy sends it anyway
+and logs the violation.`,
+	Example: `alfred policy master
+alfred policy set master --max-per-day 500 --confirm-above 100 --contacts-only
+alfred policy clear master`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, w := openPolicyWallet(args)
+		fmt.Printf("%s: %s\n", w.Name, w.Policy)
+
+		var violations []wallet.Violation
+		for _, v := range m.Stellar.Violations {
+			if v.Wallet == w.Name {
+				violations = append(violations, v)
+			}
+		}
+
+		if len(violations) == 0 {
+			return
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Date", "Amount", "Destination", "Rule"))
+		for _, v := range violations {
+			table.Append([]string{v.Time.Local().Format(time.RFC822), v.Amount, accountName(m, v.Destination), v.Rule})
+		}
+		table.Render()
+	},
+}
+
+// policySetCmd represents the policy set command
+var policySetCmd = &cobra.Command{
+	Use:     "set",
+	Short:   "Change the spending policy of a wallet",
+	Long:    `Change the spending policy of a wallet, only the given flags are changed.`,
+	Example: "alfred policy set master --max-per-day 500 --confirm-above 100 --contacts-only",
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		w := updatePolicyWallet(args, func(w *wallet.Wallet) {
+			if flags.Changed("max-per-day") {
+				w.Policy.MaxPerDay, _ = flags.GetFloat64("max-per-day")
+			}
+			if flags.Changed("confirm-above") {
+				w.Policy.ConfirmAbove, _ = flags.GetFloat64("confirm-above")
+			}
+			if flags.Changed("contacts-only") {
+				w.Policy.ContactsOnly, _ = flags.GetBool("contacts-only")
+			}
+		})
+
+		fmt.Printf("%s: %s\n", w.Name, w.Policy)
+	},
+}
+
+// policyClearCmd represents the policy clear command
+var policyClearCmd = &cobra.Command{
+	Use:     "clear",
+	Short:   "Remove the spending policy of a wallet",
+	Example: "alfred policy clear master",
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		updatePolicyWallet(args, func(w *wallet.Wallet) {
+			w.Policy = wallet.Policy{}
+		})
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policySetCmd)
+	policyCmd.AddCommand(policyClearCmd)
+
+	policySetCmd.Flags().Float64("max-per-day", 0, "maximum amount of XLM sent over 24 hours (0 for no limit)")
+	policySetCmd.Flags().Float64("confirm-above", 0, "always require a confirmation for payments above this amount of XLM (0 to disable)")
+	policySetCmd.Flags().Bool("contacts-only", false, "deny destinations which are neither a contact nor a wallet")
+}
+
+func openPolicyWallet(args []string) (*wallet.Alfred, *wallet.Wallet) {
+	if len(args) != 1 {
+		fatal("one argument is expected, the name of the wallet")
+	}
+
+	m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+	if err != nil {
+		fatal(err)
+	}
+
+	w := m.WalletByName(args[0])
+	if w == nil {
+		fatalf("wallet '%s' not found", args[0])
+	}
+
+	return m, w
+}
+
+// updatePolicyWallet applies fn to the wallet named by args and writes the
+// database, see updateDB. It returns the wallet as written.
+func updatePolicyWallet(args []string, fn func(w *wallet.Wallet)) *wallet.Wallet {
+	if len(args) != 1 {
+		fatal("one argument is expected, the name of the wallet")
+	}
+
+	var w *wallet.Wallet
+	if _, err := updateDB(func(m *wallet.Alfred) error {
+		if w = m.WalletByName(args[0]); w == nil {
+			return i18n.Errorf("wallet '%s' not found", args[0])
+		}
+		fn(w)
+		return nil
+	}); err != nil {
+		fatal(err)
+	}
+
+	return w
+}
+
+// outgoing is a payment checked by enforcePolicy
+type outgoing struct {
+	to string
+	// amount of the asset sent, including the starting balance of a new account
+	amount string
+}
+
+// enforcePolicy checks the payments of a transaction against the policy of the
+// source wallet and returns whether they must be confirmed.
+// Payments breaking the policy are denied, unless --override-policy is set
+// in which case the violations are logged in the database.
+func enforcePolicy(m *wallet.Alfred, client *horizon.Client, from string, asset *assets.Asset, payments []outgoing) (bool, error) {
+	w := m.WalletByAddress(from)
+	if w == nil || w.Policy.IsZero() {
+		return false, nil
+	}
+
+	var spent float64
+	if w.Policy.MaxPerDay > 0 {
+		var err error
+		spent, err = spentToday(client, from)
+		if err != nil {
+			return false, i18n.Errorf("unable to check the policy of %s: %v", w.Name, err)
+		}
+	}
+
+	code := "XLM"
+	if !asset.BuilderAsset.Native {
+		code = asset.BuilderAsset.Code
+	}
+
+	var (
+		confirm    bool
+		rules      []string
+		violations []wallet.Violation
+	)
+	for _, p := range payments {
+		payment := wallet.Payment{
+			Destination: p.to,
+			Known:       accountName(m, p.to) != wallet.TrimAddress(p.to),
+		}
+		if asset.BuilderAsset.Native {
+			f, err := strconv.ParseFloat(p.amount, 64)
+			if err != nil {
+				return false, i18n.Errorf("invalid amount '%s'", p.amount)
+			}
+			payment.Amount = f
+		}
+
+		broken, c := w.Policy.Check(payment, spent)
+		spent += payment.Amount
+		confirm = confirm || c
+
+		for _, rule := range broken {
+			rules = append(rules, rule)
+			violations = append(violations, wallet.Violation{
+				Time:        time.Now().UTC(),
+				Wallet:      w.Name,
+				Rule:        rule,
+				Destination: p.to,
+				Amount:      p.amount + " " + code,
+			})
+		}
+	}
+
+	if len(violations) == 0 {
+		return confirm, nil
+	}
+
+	if !viper.GetBool("override-policy") {
+		return false, withExitCode(exitPolicyDenied, i18n.Errorf("payment denied by the policy of %s: %s (use --override-policy to send it anyway)",
+			w.Name, strings.Join(rules, ", ")))
+	}
+
+	for _, v := range violations {
+		fmt.Println("Policy overridden:", v.Rule)
+		m.LogViolation(v)
+	}
+
+	if _, err := updateDB(func(stored *wallet.Alfred) error {
+		for _, v := range violations {
+			stored.LogViolation(v)
+		}
+		return nil
+	}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// spentToday returns the amount of XLM sent by account over the last 24 hours
+func spentToday(client *horizon.Client, account string) (float64, error) {
+	endpoint := fmt.Sprintf("%s/accounts/%s/payments?order=desc&limit=200", strings.TrimRight(client.URL, "/"), account)
+	resp, err := client.HTTP.Get(endpoint)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		herr := &horizon.Error{Response: resp}
+		if err := json.NewDecoder(resp.Body).Decode(&herr.Problem); err != nil {
+			return 0, err
+		}
+		return 0, herr
+	}
+
+	var page struct {
+		Embedded struct {
+			Records []struct {
+				Type            string    `json:"type"`
+				CreatedAt       time.Time `json:"created_at"`
+				Funder          string    `json:"funder"`
+				StartingBalance string    `json:"starting_balance"`
+				From            string    `json:"from"`
+				AssetType       string    `json:"asset_type"`
+				Amount          string    `json:"amount"`
+				SourceAmount    string    `json:"source_amount"`
+				SourceAssetType string    `json:"source_asset_type"`
+			} `json:"records"`
+		} `json:"_embedded"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return 0, err
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	var spent float64
+	for _, r := range page.Embedded.Records {
+		if r.CreatedAt.Before(since) {
+			break
+		}
+
+		var amnt string
+		switch {
+		case r.Type == "create_account" && r.Funder == account:
+			amnt = r.StartingBalance
+		case r.Type == "payment" && r.From == account && r.AssetType == "native":
+			amnt = r.Amount
+		case r.Type == "path_payment" && r.From == account && r.SourceAssetType == "native":
+			amnt = r.SourceAmount
+		default:
+			continue
+		}
+
+		f, _ := strconv.ParseFloat(amnt, 64)
+		spent += f
+	}
+
+	return spent, nil
+}
@@ -0,0 +1,130 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// inOrder calls fetch for 0 to n-1 on at most --max-requests goroutines, and
+// emit with each result in order, as soon as it and the previous ones are
+// fetched
+func inOrder(n int, fetch func(i int) interface{}, emit func(i int, v interface{})) {
+	workers := viper.GetInt("max-requests")
+	if workers <= 0 || workers > n {
+		workers = n
+	}
+
+	results := make([]interface{}, n)
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				results[i] = fetch(i)
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < n; i++ {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	for i := 0; i < n; i++ {
+		<-done[i]
+		emit(i, results[i])
+	}
+}
+
+// streamTable prints a table row by row, in the style of tablewriter with row
+// lines, so that the first rows show while the next ones load. The width of
+// the columns is set upfront, longer cells push the borders of their row.
+type streamTable struct {
+	w      io.Writer
+	widths []int
+}
+
+// newStreamTable prints the header of a table whose columns are at least
+// widths wide
+func newStreamTable(w io.Writer, header []string, widths []int) *streamTable {
+	t := &streamTable{w: w, widths: make([]int, len(header))}
+	for i, h := range header {
+		t.widths[i] = utf8.RuneCountInString(h)
+		if i < len(widths) && widths[i] > t.widths[i] {
+			t.widths[i] = widths[i]
+		}
+	}
+
+	t.line()
+	cells := make([]string, len(header))
+	for i, h := range header {
+		h = strings.ToUpper(h)
+		left := (t.widths[i] - utf8.RuneCountInString(h)) / 2
+		cells[i] = strings.Repeat(" ", left) + pad(h, t.widths[i]-left, false)
+	}
+	t.cells(cells)
+	t.line()
+	return t
+}
+
+// Append prints row followed by a row line
+func (t *streamTable) Append(row []string) {
+	cells := make([]string, len(t.widths))
+	for i := range cells {
+		var cell string
+		if i < len(row) {
+			cell = row[i]
+		}
+		cells[i] = pad(cell, t.widths[i], isFormattedAmount(cell))
+	}
+	t.cells(cells)
+	t.line()
+}
+
+func (t *streamTable) cells(cells []string) {
+	fmt.Fprintf(t.w, "| %s |\n", strings.Join(cells, " | "))
+}
+
+func (t *streamTable) line() {
+	parts := make([]string, len(t.widths))
+	for i, w := range t.widths {
+		parts[i] = strings.Repeat("-", w+2)
+	}
+	fmt.Fprintf(t.w, "+%s+\n", strings.Join(parts, "+"))
+}
+
+// pad pads s with spaces up to width, on the left if right is set
+func pad(s string, width int, right bool) string {
+	n := width - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return s
+	}
+	if right {
+		return strings.Repeat(" ", n) + s
+	}
+	return s + strings.Repeat(" ", n)
+}
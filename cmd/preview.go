@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/olekukonko/tablewriter"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// printPreview prints the transaction of the unsigned envelope before it is
+// confirmed: its source, sequence number, fee, time bounds and memo, the
+// balances of its source once applied and every operation in order. The
+// balances are left out when the source account can not be loaded.
+func printPreview(m *wallet.Alfred, client *horizon.Client, unsigned string) error {
+	var txe xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(unsigned, &txe); err != nil {
+		return err
+	}
+	if m == nil {
+		m = &wallet.Alfred{}
+	}
+	source := txe.Tx.SourceAccount.Address()
+
+	fmt.Println(i18n.T("Transaction:"))
+	table := newTable(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Append([]string{i18n.T("Source"), describeAccount(m, source)})
+	table.Append([]string{i18n.T("Sequence"), strconv.FormatInt(int64(txe.Tx.SeqNum), 10)})
+	table.Append([]string{i18n.T("Fee"), i18n.Sprintf("%s XLM (%d stroops for %d operations)", formatStroops(xdr.Int64(txe.Tx.Fee), "XLM"), txe.Tx.Fee, len(txe.Tx.Operations))})
+	table.Append([]string{i18n.T("Valid from"), describeTimeBound(txe.Tx.TimeBounds, true)})
+	table.Append([]string{i18n.T("Valid until"), describeTimeBound(txe.Tx.TimeBounds, false)})
+	table.Append([]string{i18n.T("Memo"), describeMemo(txe.Tx.Memo)})
+	if client != nil {
+		if acc, exists, err := getAccount(client, source); err == nil && exists {
+			for _, b := range balancesAfter(acc, txe.Tx) {
+				table.Append([]string{i18n.T("Balance after"), b})
+			}
+		}
+	}
+	table.Render()
+
+	fmt.Println(i18n.T("Operations:"))
+	printOperations(m, txe.Tx.Operations)
+	return nil
+}
+
+// balancesAfter returns the balances of acc, the source account of tx, once
+// tx is applied: XLM and the assets it sends or receives. A path payment is
+// counted as sending its maximum.
+func balancesAfter(acc horizon.Account, tx xdr.Transaction) []string {
+	source := tx.SourceAccount.Address()
+	changes := map[string]*balanceChange{"native": {account: source, asset: "XLM", delta: -xdr.Int64(tx.Fee)}}
+	change := func(a xdr.Asset, delta xdr.Int64) {
+		var typ xdr.AssetType
+		var code, issuer string
+		key := "native"
+		if err := a.Extract(&typ, &code, &issuer); err == nil && typ != xdr.AssetTypeAssetTypeNative {
+			key = code + ":" + issuer
+		}
+		if changes[key] == nil {
+			changes[key] = &balanceChange{account: source, asset: code}
+		}
+		changes[key].delta += delta
+	}
+
+	for _, op := range tx.Operations {
+		from := source
+		if op.SourceAccount != nil {
+			from = op.SourceAccount.Address()
+		}
+
+		switch body := op.Body; body.Type {
+		case xdr.OperationTypeCreateAccount:
+			if from == source {
+				change(xdr.Asset{Type: xdr.AssetTypeAssetTypeNative}, -body.CreateAccountOp.StartingBalance)
+			}
+		case xdr.OperationTypePayment:
+			p := body.PaymentOp
+			if from == source {
+				change(p.Asset, -p.Amount)
+			}
+			if p.Destination.Address() == source {
+				change(p.Asset, p.Amount)
+			}
+		case xdr.OperationTypePathPayment:
+			p := body.PathPaymentOp
+			if from == source {
+				change(p.SendAsset, -p.SendMax)
+			}
+			if p.Destination.Address() == source {
+				change(p.DestAsset, p.DestAmount)
+			}
+		case xdr.OperationTypeAccountMerge:
+			if from == source {
+				return []string{"none, the account is merged"}
+			}
+		}
+	}
+
+	current := make(map[string]xdr.Int64)
+	for _, b := range acc.Balances {
+		key := "native"
+		if b.Asset.Type != "native" {
+			key = b.Asset.Code + ":" + b.Asset.Issuer
+		}
+		if balance, err := amount.Parse(b.Balance); err == nil {
+			current[key] = balance
+		}
+	}
+
+	var keys []string
+	for key := range changes {
+		if key != "native" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	keys = append([]string{"native"}, keys...)
+
+	var lines []string
+	for _, key := range keys {
+		c := changes[key]
+		if key != "native" && c.delta == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (now %s)", formatStroops(current[key]+c.delta, c.asset), c.asset, formatStroops(current[key], c.asset)))
+	}
+	return lines
+}
@@ -0,0 +1,240 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/celrenheit/alfred/profile"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Display the profiles, such as personal and business",
+	Long: `Display the profiles, the current one is marked with a star.
+
+A profile has its own database, network and default wallet. The current profile,
+or the one given with --profile, is used for every command: its settings replace
+the ones of the config file, and are replaced by the flags given.
+The profiles are stored in $HOME/.alfred-profiles.yaml.`,
+	Example: `alfred profile set business --db ~/business.yaml --wallet payroll
+alfred profile set testnet --db ~/testnet.yaml --network testnet
+alfred profile use business
+alfred --profile testnet please send 10 XLM to jennifer
+alfred profile`,
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles := loadProfiles()
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("", "Name", "Database", "Network", "Wallet"))
+		for _, name := range profiles.Names() {
+			p := profiles.Profiles[name]
+
+			current := ""
+			if name == profiles.Current {
+				current = "*"
+			}
+			network := p.Network
+			if network == "" {
+				network = publicNetwork
+			}
+			table.Append([]string{current, name, p.DB, network, p.Wallet})
+		}
+		table.Render()
+	},
+}
+
+// profileSetCmd represents the profile set command
+var profileSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Create or change a profile",
+	Long: `Create or change a profile with the --db, --network and --wallet flags,
+only the given flags are changed.`,
+	Example: "alfred profile set business --db ~/business.yaml --wallet payroll",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles := loadProfiles()
+
+		name := args[0]
+		p := profiles.Profiles[name]
+
+		flags := cmd.Flags()
+		if flags.Changed("db") {
+			db, _ := flags.GetString("db")
+			abs, err := filepath.Abs(db)
+			if err != nil {
+				fatal(err)
+			}
+			p.DB = abs
+		}
+		if flags.Changed("network") {
+			p.Network, _ = flags.GetString("network")
+			if _, err := parseNetwork(p.Network); err != nil {
+				fatal(err)
+			}
+		} else if flags.Changed("testnet") {
+			p.Network = ""
+			if testnet, _ := flags.GetBool("testnet"); testnet {
+				p.Network = testNetwork
+			}
+		}
+		if flags.Changed("wallet") {
+			p.Wallet, _ = flags.GetString("wallet")
+		}
+
+		if p.DB == "" {
+			fatal("the database of the profile should be given with --db")
+		}
+
+		if err := profiles.Set(name, p); err != nil {
+			fatal(err)
+		}
+		saveProfiles(profiles)
+	},
+}
+
+// profileUseCmd represents the profile use command
+var profileUseCmd = &cobra.Command{
+	Use:   "use",
+	Short: "Change the current profile",
+	Long: `Change the current profile, used when --profile is not given.
+Without a name, the flags and the config file are used again.`,
+	Example: `alfred profile use business
+alfred profile use`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles := loadProfiles()
+
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+
+		if err := profiles.Use(name); err != nil {
+			fatal(err)
+		}
+		saveProfiles(profiles)
+	},
+}
+
+// profileRemoveCmd represents the profile remove command
+var profileRemoveCmd = &cobra.Command{
+	Use:     "remove",
+	Short:   "Remove a profile",
+	Long:    `Remove a profile, its database is kept.`,
+	Example: "alfred profile remove testnet",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles := loadProfiles()
+
+		if err := profiles.Remove(args[0]); err != nil {
+			fatal(err)
+		}
+		saveProfiles(profiles)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileSetCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileRemoveCmd)
+}
+
+// profilesPath returns the path of the file storing the profiles
+func profilesPath() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".alfred-profiles.yaml"), nil
+}
+
+func loadProfiles() *profile.Profiles {
+	path, err := profilesPath()
+	if err != nil {
+		fatal(err)
+	}
+
+	profiles, err := profile.Load(path)
+	if err != nil {
+		fatal(err)
+	}
+
+	return profiles
+}
+
+func saveProfiles(profiles *profile.Profiles) {
+	path, err := profilesPath()
+	if err != nil {
+		fatal(err)
+	}
+
+	if err := profiles.Save(path); err != nil {
+		fatal(err)
+	}
+}
+
+// applyProfile replaces the settings by the ones of the profile given with
+// --profile, or of the current one, unless they were given as flags
+func applyProfile() error {
+	path, err := profilesPath()
+	if err != nil {
+		return err
+	}
+
+	profiles, err := profile.Load(path)
+	if err != nil {
+		return err
+	}
+
+	name := viper.GetString("profile")
+	if name == "" {
+		name = profiles.Current
+	}
+	if name == "" {
+		return nil
+	}
+
+	p, err := profiles.Get(name)
+	if err != nil && name == profiles.Current {
+		// still allow to change it
+		fmt.Printf("Warning: the current %v, see alfred profile use\n", err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	flags := RootCmd.PersistentFlags()
+	if !flags.Changed("db") && p.DB != "" {
+		viper.Set("db", p.DB)
+	}
+	if !flags.Changed("network") && !flags.Changed("testnet") {
+		viper.Set("network", p.Network)
+		viper.Set("testnet", false)
+	}
+	if !flags.Changed("wallet") && p.Wallet != "" {
+		viper.Set("wallet", p.Wallet)
+	}
+
+	return nil
+}
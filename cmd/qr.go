@@ -0,0 +1,205 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"  // register gif decoder for --qr-file
+	_ "image/jpeg" // register jpeg decoder for --qr-file
+	_ "image/png"  // register png decoder for --qr-file
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/qr"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/keypair"
+)
+
+const sep7PayPrefix = "web+stellar:pay?"
+
+// qrCmd represents the qr command
+var qrCmd = &cobra.Command{
+	Use:   "qr",
+	Short: "Display the address of a wallet as a QR code",
+	Long: `Display the address of a wallet as a QR code.
+
+If an amount, an asset or a memo is given, a SEP-7 payment URI is encoded instead.`,
+	Example: `alfred qr master
+alfred qr master --amount 20 --asset MOBI --memo "dinner"`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fatal("one argument is expected, either an address or the name of the wallet")
+		}
+
+		path := viper.GetString("db")
+		secret := viper.GetString("secret")
+		m, err := wallet.OpenSecretString(path, secret)
+		if err != nil {
+			fatal(err)
+		}
+
+		kp := getAddress(m, args[0])
+		if kp == nil {
+			fatalf("'%s' not found", args[0])
+		}
+
+		amount, _ := cmd.Flags().GetString("amount")
+		assetCode, _ := cmd.Flags().GetString("asset")
+		memo, _ := cmd.Flags().GetString("memo")
+		forceURI, _ := cmd.Flags().GetBool("uri")
+
+		content := kp.Address()
+		if forceURI || amount != "" || assetCode != "" || memo != "" {
+			content, err = payURI(kp.Address(), amount, assetCode, memo)
+			if err != nil {
+				fatal(err)
+			}
+		}
+
+		code, err := qr.Encode(content, qr.M)
+		if err != nil {
+			fatal(err)
+		}
+
+		if small, _ := cmd.Flags().GetBool("small"); small {
+			fmt.Print(code.String())
+		} else if err := code.ANSI(os.Stdout); err != nil {
+			fatal(err)
+		}
+
+		fmt.Println(content)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(qrCmd)
+
+	qrCmd.Flags().String("amount", "", "amount requested in the payment URI")
+	qrCmd.Flags().String("asset", "", "asset requested in the payment URI (defaults to XLM)")
+	qrCmd.Flags().String("memo", "", "text memo of the payment URI")
+	qrCmd.Flags().Bool("uri", false, "always encode a SEP-7 payment URI")
+	qrCmd.Flags().Bool("small", false, "render using unicode blocks instead of colors")
+}
+
+// payURI builds a SEP-7 payment request
+func payURI(destination, amount, code, memo string) (string, error) {
+	var asset *assets.Asset
+	if code != "" {
+		var err error
+		if asset, err = selectAsset(code); err != nil {
+			return "", err
+		}
+	}
+
+	return assetPayURI(destination, amount, asset, memo, ""), nil
+}
+
+// assetPayURI builds a SEP-7 payment request of asset, XLM when it is nil,
+// msg is shown to the payer
+func assetPayURI(destination, amount string, asset *assets.Asset, memo, msg string) string {
+	v := url.Values{}
+	v.Set("destination", destination)
+	if amount != "" {
+		v.Set("amount", amount)
+	}
+
+	if asset != nil && !asset.BuilderAsset.Native {
+		v.Set("asset_code", asset.BuilderAsset.Code)
+		v.Set("asset_issuer", asset.BuilderAsset.Issuer)
+	}
+
+	if memo != "" {
+		v.Set("memo", memo)
+		v.Set("memo_type", "MEMO_TEXT")
+	}
+
+	if msg != "" {
+		v.Set("msg", msg)
+	}
+
+	return sep7PayPrefix + v.Encode()
+}
+
+// destinationFromQR decodes the QR code stored in the image at path.
+// It contains either a plain address or a SEP-7 payment URI.
+func destinationFromQR(path string) (string, *wallet.Memo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return "", nil, err
+	}
+
+	content, err := qr.Decode(img)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return parseDestination(content)
+}
+
+func parseDestination(content string) (string, *wallet.Memo, error) {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, sep7PayPrefix) {
+		kp, err := keypair.Parse(content)
+		if err != nil {
+			return "", nil, i18n.Errorf("qr code does not contain an address: '%s'", content)
+		}
+		return kp.Address(), nil, nil
+	}
+
+	v, err := url.ParseQuery(strings.TrimPrefix(content, sep7PayPrefix))
+	if err != nil {
+		return "", nil, err
+	}
+
+	kp, err := keypair.Parse(v.Get("destination"))
+	if err != nil {
+		return "", nil, i18n.Errorf("invalid destination in payment URI: %v", err)
+	}
+
+	var memo *wallet.Memo
+	if m := v.Get("memo"); m != "" {
+		kinds := map[string]wallet.MemoKind{
+			"":            wallet.MEMO_TEXT,
+			"MEMO_TEXT":   wallet.MEMO_TEXT,
+			"MEMO_ID":     wallet.MEMO_ID,
+			"MEMO_HASH":   wallet.MEMO_HASH,
+			"MEMO_RETURN": wallet.MEMO_RETURN,
+		}
+		kind, ok := kinds[v.Get("memo_type")]
+		if !ok {
+			return "", nil, i18n.Errorf("unsupported memo type '%s' in payment URI", v.Get("memo_type"))
+		}
+
+		memo, err = wallet.MemoFromString(kind, m)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	return kp.Address(), memo, nil
+}
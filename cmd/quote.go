@@ -0,0 +1,228 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// quoteCmd represents the quote command
+var quoteCmd = &cobra.Command{
+	Use:   "quote",
+	Short: "Find the paths a payment can take through the order books",
+	Long: `Find the paths converting an asset sent from a wallet into the assets the
+destination holds, with the amount received, the assets each path goes
+through and the implied rate.
+
+  <amount> <asset> [FROM <wallet>] TO <destination>
+
+The amount is sent (strict send). With --receive, it is the amount the
+destination receives and the paths start from the assets of the wallet
+(strict receive). Nothing is submitted.`,
+	Example: `alfred quote 100 XLM from master to GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6
+alfred quote 50 MOBI from master to bob --receive`,
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		req, err := parser.ParseQuote(strings.Join(args, " "))
+		if err != nil {
+			fatal(err)
+		}
+		logStatement(req)
+
+		amount, err := strconv.ParseFloat(req.Amount, 64)
+		if err != nil || amount <= 0 {
+			fatalf("invalid amount '%s'", req.Amount)
+		}
+
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		src, err := getOrSelectWallet(m, req.From)
+		if err != nil {
+			fatal(err)
+		}
+
+		dest, _, err := resolveDestination(m, req.To)
+		if err != nil {
+			fatal(err)
+		}
+
+		asset, err := selectAsset(req.Currency)
+		if err != nil {
+			fatal(err)
+		}
+
+		query := url.Values{}
+		endpoint := "strict-send"
+		receive, _ := cmd.Flags().GetBool("receive")
+		if receive {
+			endpoint = "strict-receive"
+			setAssetParams(query, "destination", asset.ToHorizonAsset())
+			query.Set("destination_amount", req.Amount)
+			query.Set("source_account", src.Address())
+		} else {
+			setAssetParams(query, "source", asset.ToHorizonAsset())
+			query.Set("source_amount", req.Amount)
+			query.Set("destination_account", dest)
+		}
+
+		client := getClient(currentNetwork())
+		paths, err := loadPaths(client, endpoint, query)
+		if err != nil {
+			fatal(err)
+		}
+
+		if receive {
+			fmt.Printf("Paying %s %s to %s from the assets of %s\n", req.Amount, asset.CodeString(), dest, src.Address())
+		} else {
+			fmt.Printf("Sending %s %s from %s to %s\n", req.Amount, asset.CodeString(), src.Address(), dest)
+		}
+
+		if len(paths) == 0 {
+			fmt.Println("No path found, the order books can not fill the amount")
+			return
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Send", "Receive", "Path", "Hops", "Rate"))
+		for _, p := range paths {
+			source, destination := horizonAssetCode(p.source()), horizonAssetCode(p.destination())
+			table.Append([]string{
+				p.SourceAmount + " " + source,
+				p.DestinationAmount + " " + destination,
+				p.describe(),
+				strconv.Itoa(p.hops()),
+				p.rate() + " " + destination + " per " + source,
+			})
+		}
+		table.Render()
+	},
+}
+
+// paymentPath is a path found by horizon, through which a path payment can
+// convert the source asset into the destination asset
+type paymentPath struct {
+	SourceAssetType        string          `json:"source_asset_type"`
+	SourceAssetCode        string          `json:"source_asset_code"`
+	SourceAssetIssuer      string          `json:"source_asset_issuer"`
+	SourceAmount           string          `json:"source_amount"`
+	DestinationAssetType   string          `json:"destination_asset_type"`
+	DestinationAssetCode   string          `json:"destination_asset_code"`
+	DestinationAssetIssuer string          `json:"destination_asset_issuer"`
+	DestinationAmount      string          `json:"destination_amount"`
+	Path                   []horizon.Asset `json:"path"`
+}
+
+func (p paymentPath) source() horizon.Asset {
+	return horizon.Asset{Type: p.SourceAssetType, Code: p.SourceAssetCode, Issuer: p.SourceAssetIssuer}
+}
+
+func (p paymentPath) destination() horizon.Asset {
+	return horizon.Asset{Type: p.DestinationAssetType, Code: p.DestinationAssetCode, Issuer: p.DestinationAssetIssuer}
+}
+
+// hops returns the number of conversions, none when the asset is sent as is
+func (p paymentPath) hops() int {
+	if p.source() == p.destination() {
+		return 0
+	}
+	return len(p.Path) + 1
+}
+
+// describe returns the assets of the path, such as XLM -> USD -> MOBI
+func (p paymentPath) describe() string {
+	if p.hops() == 0 {
+		return horizonAssetCode(p.source())
+	}
+
+	codes := []string{horizonAssetCode(p.source())}
+	for _, a := range p.Path {
+		codes = append(codes, horizonAssetCode(a))
+	}
+	codes = append(codes, horizonAssetCode(p.destination()))
+	return strings.Join(codes, " -> ")
+}
+
+// rate returns the amount of destination asset received per source asset sent
+func (p paymentPath) rate() string {
+	sent, err := strconv.ParseFloat(p.SourceAmount, 64)
+	if err != nil || sent == 0 {
+		return "?"
+	}
+	received, err := strconv.ParseFloat(p.DestinationAmount, 64)
+	if err != nil {
+		return "?"
+	}
+
+	return strconv.FormatFloat(received/sent, 'f', 7, 64)
+}
+
+// setAssetParams sets the query parameters of asset, such as source_asset_type
+func setAssetParams(query url.Values, prefix string, asset horizon.Asset) {
+	query.Set(prefix+"_asset_type", asset.Type)
+	if asset.Type != "native" {
+		query.Set(prefix+"_asset_code", asset.Code)
+		query.Set(prefix+"_asset_issuer", asset.Issuer)
+	}
+}
+
+// loadPaths calls the strict-send or strict-receive path finding endpoint
+func loadPaths(client *horizon.Client, endpoint string, query url.Values) ([]paymentPath, error) {
+	resp, err := client.HTTP.Get(fmt.Sprintf("%s/paths/%s?%s", strings.TrimRight(client.URL, "/"), endpoint, query.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		herr := &horizon.Error{Response: resp}
+		if err := json.NewDecoder(resp.Body).Decode(&herr.Problem); err != nil {
+			return nil, err
+		}
+		return nil, herr
+	}
+
+	var page struct {
+		Embedded struct {
+			Records []paymentPath `json:"records"`
+		} `json:"_embedded"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+
+	return page.Embedded.Records, nil
+}
+
+func init() {
+	RootCmd.AddCommand(quoteCmd)
+
+	quoteCmd.Flags().Bool("receive", false, "the amount is received by the destination instead of sent")
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/celrenheit/alfred/decimal"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/mattn/go-isatty"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/xdr"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// compactWidth is the width of the terminal under which tables are compact
+const compactWidth = 120
+
+// ANSI styles of the output
+const (
+	styleIncoming = "32" // green
+	styleOutgoing = "31" // red
+	styleDim      = "2"
+	styleBold     = "1"
+)
+
+// colored reports whether the output is colored: on a terminal, unless
+// --no-color or the NO_COLOR environment variable is set
+func colored() bool {
+	return colorAllowed() && isatty.IsTerminal(os.Stdout.Fd())
+}
+
+func colorAllowed() bool {
+	return !viper.GetBool("no-color") && os.Getenv("NO_COLOR") == ""
+}
+
+// paint returns s with the ANSI style, if the output is colored
+func paint(style, s string) string {
+	if s == "" || !colored() {
+		return s
+	}
+	return ansi(style, s)
+}
+
+func ansi(style, s string) string {
+	return fmt.Sprintf("\x1b[%sm%s\x1b[0m", style, s)
+}
+
+// paintIncoming returns s painted as money received
+func paintIncoming(s string) string { return paint(styleIncoming, s) }
+
+// paintOutgoing returns s painted as money sent
+func paintOutgoing(s string) string { return paint(styleOutgoing, s) }
+
+// paintDim returns s painted as a secondary information
+func paintDim(s string) string { return paint(styleDim, s) }
+
+// paintDelta returns s, the text of delta, painted by the sign of delta
+func paintDelta(delta xdr.Int64, s string) string {
+	switch {
+	case delta > 0:
+		return paintIncoming(s)
+	case delta < 0:
+		return paintOutgoing(s)
+	}
+	return s
+}
+
+// compactTables reports whether tables are printed without borders: with
+// --table compact, or with --table auto on a terminal narrower than
+// compactWidth
+func compactTables() bool {
+	switch viper.GetString("table") {
+	case "compact":
+		return true
+	case "wide":
+		return false
+	}
+
+	width, _, err := terminal.GetSize(int(os.Stdout.Fd()))
+	return err == nil && width > 0 && width < compactWidth
+}
+
+// newTable returns a table printed to w, compact or wide, see compactTables
+func newTable(w io.Writer) *tablewriter.Table {
+	table := tablewriter.NewWriter(w)
+	if compactTables() {
+		table.SetBorder(false)
+		table.SetHeaderLine(false)
+		table.SetColumnSeparator("")
+		table.SetCenterSeparator("")
+		table.SetRowSeparator("")
+	}
+	return table
+}
+
+// header returns the columns of the header of a table, in the language of
+// the messages
+func header(columns ...string) []string {
+	translated := make([]string, len(columns))
+	for i, c := range columns {
+		translated[i] = i18n.T(c)
+	}
+	return translated
+}
+
+// formatAmount returns amt, an amount of the asset with code such as the
+// 12.5000000 of horizon, as shown in the tables and summaries: rounded to the
+// decimals of the asset in the precision setting, without trailing zeros and
+// with the separators of the language of the messages
+func formatAmount(amt, code string) string {
+	return amountFormat().String(amt, code)
+}
+
+// formatStroops is formatAmount for an amount in stroops
+func formatStroops(amt xdr.Int64, code string) string {
+	return amountFormat().Amount(decimal.Amount(amt), code)
+}
+
+// amountFormat returns the format of the amounts, see formatAmount
+func amountFormat() decimal.Format {
+	n := i18n.CurrentNumbers()
+	f := decimal.Format{Point: n.Point, Thousands: n.Thousands, Precision: make(map[string]int)}
+	for code, decimals := range viper.GetStringMap("precision") {
+		if d, err := cast.ToIntE(decimals); err == nil {
+			// the keys of the settings are lowercased
+			f.Precision[strings.ToUpper(code)] = d
+		}
+	}
+	return f
+}
+
+// isFormattedAmount reports whether s is a number, as formatted by formatAmount
+func isFormattedAmount(s string) bool {
+	n := i18n.CurrentNumbers()
+	s = strings.Replace(strings.TrimSpace(s), n.Thousands, "", -1)
+	_, err := strconv.ParseFloat(strings.Replace(s, n.Point, ".", 1), 64)
+	return err == nil
+}
+
+// amountColumns returns the alignments of a table of n columns aligning the
+// columns of formatted amounts to the right, as tablewriter does only for
+// numbers without separators
+func amountColumns(n int, columns ...int) []int {
+	align := make([]int, n)
+	for _, c := range columns {
+		align[c] = tablewriter.ALIGN_RIGHT
+	}
+	return align
+}
+
+// printNetworkBanner prints a dim banner on stderr when the network is not
+// the public one, so that test funds are not mistaken for real ones
+func printNetworkBanner() {
+	name := viper.GetString("network")
+	if name == "" && viper.GetBool("testnet") {
+		name = testNetwork
+	}
+	n, err := parseNetwork(name)
+	if name == "" || err != nil || !n.isTestNetwork() || !isatty.IsTerminal(os.Stderr.Fd()) {
+		return
+	}
+
+	banner := fmt.Sprintf("-- %s --", n.Name)
+	if colorAllowed() {
+		banner = ansi(styleDim, banner)
+	}
+	fmt.Fprintln(os.Stderr, banner)
+}
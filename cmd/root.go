@@ -17,7 +17,14 @@ package cmd
 import (
 	"fmt"
 	"os"
-
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/agent"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/ratelimit"
+	// registers the sqlite:// databases, which need cgo
+	_ "github.com/celrenheit/alfred/wallet/sqlite"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -37,9 +44,11 @@ var RootCmd = &cobra.Command{
 }
 
 func Execute() {
+	handleInterrupt()
+	categorizeUsageErrors(RootCmd)
 	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Println(i18n.T(describeError(err)))
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -47,8 +56,35 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	RootCmd.PersistentFlags().StringP("secret", "s", "", "secret used for encryption of the wallet")
-	RootCmd.PersistentFlags().StringP("db", "d", "alfred.yaml", "path of file where everything will be stored")
+	RootCmd.PersistentFlags().String("agent", agent.DefaultSocket(), "path of the socket of the agent caching the secret, see alfred agent")
+	RootCmd.PersistentFlags().StringP("db", "d", "alfred.yaml", "path of file where everything will be stored, or scheme://location for another store such as sqlite:///path/to/alfred.db")
+	RootCmd.PersistentFlags().String("passphrase", "", "passphrase of the wallets protected with one, see alfred passphrase")
+	RootCmd.PersistentFlags().String("network", "", "network to use: public (default), testnet, futurenet or custom:<passphrase> for a standalone network, whose horizon servers are given with --horizon")
 	RootCmd.PersistentFlags().Bool("testnet", false, "use testnet")
+	RootCmd.PersistentFlags().MarkDeprecated("testnet", "use --network testnet")
+	RootCmd.PersistentFlags().Bool("read-only", false, "open the database without its secret, for the commands which do not sign: balances, history, watch, offers, quote, signers, account show")
+	RootCmd.PersistentFlags().StringSlice("horizon", nil, "urls of the horizon servers to use instead of the one of the network, such as the one of alfred selftest --serve; requests fail over to the next one when a server fails")
+	RootCmd.PersistentFlags().String("idempotency-key", "", "key of what please or submit submits: run again with the same key on the same network, they print the hashes submitted the first time instead of submitting again")
+	RootCmd.PersistentFlags().Int("retries", 3, "number of times a failed submission is retried (expired transaction, bad sequence or timeout)")
+	RootCmd.PersistentFlags().String("fee", "", "fee per operation: auto, low, medium or high from the fee stats of the network, or a number of stroops (default is the base fee)")
+	RootCmd.PersistentFlags().Duration("timeout", 30*time.Second, "time horizon has to answer each request before the command fails (0 for no limit)")
+	RootCmd.PersistentFlags().Int("max-requests", ratelimit.DefaultConcurrency, "number of requests sent to horizon at once, they are also paced to stay under its rate limit")
+	RootCmd.PersistentFlags().Duration("cache-ttl", 30*time.Second, "time the accounts and order books loaded from horizon are reused (0 to disable)")
+	RootCmd.PersistentFlags().String("cache-dir", "", "directory where the accounts and order books loaded are also stored, for the following commands")
+	RootCmd.PersistentFlags().Duration("valid-for", 5*time.Minute, "validity of submitted transactions, they are rebuilt if they expire before submission (0 to disable)")
+	RootCmd.PersistentFlags().String("profile", "", "profile to use instead of the current one, see alfred profile")
+	RootCmd.PersistentFlags().String("wallet", "", "wallet used when none is given, instead of prompting for it")
+	RootCmd.PersistentFlags().StringSlice("issuer", nil, "issuers chosen when several assets have the same code, instead of prompting for them")
+	RootCmd.PersistentFlags().Bool("copy", false, "copy the transaction hash, address or XDR printed by the command to the clipboard")
+	RootCmd.PersistentFlags().Bool("open", false, "open the submitted transaction on a block explorer in the browser")
+	RootCmd.PersistentFlags().Bool("skip-preflight", false, "submit transactions without first checking the balances, trustlines and signatures they need")
+	RootCmd.PersistentFlags().String("reserve-margin", "1", "XLM a wallet should keep above its minimum balance, a warning is shown before a transaction leaving less")
+	RootCmd.PersistentFlags().Bool("non-interactive", false, "fail instead of prompting, with a message telling which flag to pass, for scripts and CI")
+	RootCmd.PersistentFlags().Bool("verbose", false, "log the horizon server serving each request, the statements parsed and the transactions before they are signed, and show the output of the commands run by selftest")
+	RootCmd.PersistentFlags().Bool("no-color", false, "print without colors, also with the NO_COLOR environment variable or when the output is not a terminal")
+	RootCmd.PersistentFlags().String("lang", "", "language of the messages and of the keywords of please: "+strings.Join(languages(), ", ")+" (default from LC_ALL, LC_MESSAGES or LANG)")
+	RootCmd.PersistentFlags().String("table", "auto", "layout of the tables: wide with borders, compact without, or auto for compact on a terminal narrower than 120 columns")
+	RootCmd.PersistentFlags().Bool("debug-http", false, "log the requests and responses exchanged with horizon, secrets redacted")
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.alfred.yaml)")
 
 	viper.BindPFlags(RootCmd.PersistentFlags())
@@ -77,16 +113,59 @@ func initConfig() {
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		// on stderr, not to mix with the output of the command
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	}
+
+	if err := applyProfile(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := useLanguage(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	initLogging()
+	printNetworkBanner()
+
+	if viper.GetBool("non-interactive") {
+		interactive = false
+		// the error alone, on a single line
+		RootCmd.SilenceUsage = true
+		RootCmd.SilenceErrors = true
 	}
 }
 
+// fatal prints a, translated when it is a single message, and exits with the
+// exit code of the error in a, see exitCode
 func fatal(a ...interface{}) {
+	code := fatalCode(a)
+	if len(a) == 1 {
+		if err, ok := a[0].(error); ok {
+			a[0] = i18n.T(describeError(err))
+		} else if msg, ok := a[0].(string); ok {
+			a[0] = i18n.T(msg)
+		}
+	}
 	fmt.Println(a...)
-	os.Exit(1)
+	os.Exit(code)
 }
 
+// fatalf prints the message formatted with the translation of format and exits
+// with the exit code of the error in args, see exitCode
 func fatalf(format string, args ...interface{}) {
-	fmt.Println(fmt.Sprintf(format, args...))
-	os.Exit(1)
+	fmt.Println(i18n.Sprintf(format, args...))
+	os.Exit(fatalCode(args))
+}
+
+// fatalCode returns the exit code of the first error of a
+func fatalCode(a []interface{}) int {
+	for _, v := range a {
+		if err, ok := v.(error); ok {
+			return exitCode(err)
+		}
+	}
+	return exitFailure
 }
@@ -0,0 +1,296 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/celrenheit/alfred/explain"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+)
+
+// maxOperations is the maximum number of operations of a transaction
+const maxOperations = 100
+
+// rotateCmd represents the rotate command
+var rotateCmd = &cobra.Command{
+	Use:   "rotate [wallet]",
+	Short: "Move a wallet to a new key",
+	Long: `Move a wallet to a new key, when its seed may have leaked. A new account is
+created and, in a single transaction, it receives the trustlines, balances,
+data entries, home domain and inflation destination of the wallet, whose
+account is then merged into it.
+
+The new key is saved as <wallet>-next before the transaction is submitted.
+Once it succeeded, it takes the name and policy of the wallet, and the old key
+is archived in the database. If the transaction failed, nothing changed on
+the ledger and running alfred rotate again reuses the same new key; if it went
+through after all, alfred rotate finishes the rotation.
+
+The account should have no offers nor other signers, and assets issued by it
+can not be moved to another account.`,
+	Example: `alfred rotate master`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		var name string
+		if len(args) == 1 {
+			name = args[0]
+		}
+		old, err := getOrSelectWallet(m, name)
+		if err != nil {
+			fatal(err)
+		}
+		if err := requireHot(m, old); err != nil {
+			fatal(err)
+		}
+		name = m.WalletByAddress(old.Address()).Name
+		pending := name + "-next"
+
+		client := getClient(currentNetwork())
+		acc, exists, err := loadAccount(client, old.Address())
+		if err != nil {
+			fatal(err)
+		}
+
+		var next *keypair.Full
+		if w := m.WalletByName(pending); w != nil {
+			if next, err = hotKey(w); err != nil {
+				fatal(err)
+			}
+		}
+
+		if !exists {
+			if next == nil {
+				fatalf("account %s does not exist", old.Address())
+			}
+			if _, merged, err := loadAccount(client, next.Address()); err != nil || !merged {
+				fatalf("neither %s nor %s exist, the rotation can not be finished", old.Address(), next.Address())
+			}
+			finishRotation(name, pending, next)
+			return
+		}
+
+		if err := checkRotation(acc); err != nil {
+			fatal(err)
+		}
+
+		if next == nil {
+			if next, err = keypair.Random(); err != nil {
+				fatal(err)
+			}
+		}
+
+		stroops, err := baseFee(client)
+		if err != nil {
+			fatal(err)
+		}
+
+		ops, summary, err := rotationOps(acc, next.Address(), stroops)
+		if err != nil {
+			fatal(err)
+		}
+		summary["Wallet"] = name
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		if !yes && !viper.GetBool("yes") {
+			if err := confirmSummary(summary); err != nil {
+				fatal(err)
+			}
+		}
+
+		if m.WalletByName(pending) == nil {
+			if err := m.AddWallet(wallet.New(pending, next)); err != nil {
+				fatal(err)
+			}
+			if _, err := updateDB(func(m *wallet.Alfred) error {
+				return m.AddWallet(wallet.New(pending, next))
+			}); err != nil {
+				fatal(err)
+			}
+			fmt.Println("The new key is saved as", pending)
+		}
+
+		seed, err := old.Seed()
+		if err != nil {
+			fatal(err)
+		}
+
+		opts := append([]build.TransactionMutator{
+			build.SourceAccount{seed},
+			build.AutoSequence{SequenceProvider: client},
+		}, ops...)
+
+		opts = append(opts, currentNetwork().mutator())
+
+		err = submitTx(txRequest{
+			client:   client,
+			db:       m,
+			seeds:    []string{seed, next.Seed()},
+			opts:     opts,
+			validFor: viper.GetDuration("valid-for"),
+			retries:  viper.GetInt("retries"),
+			yes:      true,
+		})
+		if err != nil {
+			fmt.Println(describeHorizonError(err))
+			fatalf("%s still controls %s unless the transaction is applied later: alfred rotate %s reuses the key saved as %s, or finishes the rotation if it went through", name, old.Address(), name, pending)
+		}
+
+		finishRotation(name, pending, next)
+	},
+}
+
+// checkRotation returns why the account acc can not be merged into a new
+// one, if it can not
+func checkRotation(acc horizon.Account) error {
+	if len(acc.Signers) > 1 {
+		return errors.New("the account has other signers, remove them first or replace the signer of the leaked key instead")
+	}
+
+	if acc.Flags.AuthRequired || acc.Flags.AuthRevocable {
+		return errors.New("the account issues assets, which can not be moved to another account")
+	}
+
+	offers := acc.SubentryCount - int32(len(acc.Balances)-1) - int32(len(acc.Data))
+	if offers > 0 {
+		return i18n.Errorf("the account has %d offer(s), cancel them first", offers)
+	}
+
+	return nil
+}
+
+// rotationOps returns the operations moving the account acc to next, whose
+// source is acc unless stated otherwise, and their summary
+func rotationOps(acc horizon.Account, next string, stroops uint64) ([]build.TransactionMutator, map[string]string, error) {
+	reserve := float64(2+len(acc.Balances)-1+len(acc.Data)) * explain.BaseReserve
+	startingBalance, err := amount.Parse(strconv.FormatFloat(reserve, 'f', 7, 64))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		ops        []build.TransactionMutator
+		trustlines []string
+		asNext     = build.SourceAccount{AddressOrSeed: next}
+	)
+	ops = append(ops, build.CreateAccount(build.Destination{AddressOrSeed: next}, build.NativeAmount{Amount: amount.String(startingBalance)}))
+
+	for _, b := range acc.Balances {
+		if b.Asset.Type == "native" {
+			continue
+		}
+		trustlines = append(trustlines, b.Asset.Code)
+
+		ops = append(ops, build.Trust(b.Asset.Code, b.Asset.Issuer, build.Limit(b.Limit), asNext))
+		if balance, err := amount.Parse(b.Balance); err == nil && balance > 0 {
+			ops = append(ops, build.Payment(
+				build.Destination{AddressOrSeed: next},
+				build.CreditAmount{Code: b.Asset.Code, Issuer: b.Asset.Issuer, Amount: b.Balance},
+			))
+		}
+		ops = append(ops, build.RemoveTrust(b.Asset.Code, b.Asset.Issuer))
+	}
+
+	keys := make([]string, 0, len(acc.Data))
+	for k := range acc.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value, err := base64.StdEncoding.DecodeString(acc.Data[k])
+		if err != nil {
+			return nil, nil, i18n.Errorf("invalid value of data entry %s: %v", k, err)
+		}
+		ops = append(ops, build.SetData(k, value, asNext), build.ClearData(k))
+	}
+
+	var sopts []interface{}
+	if acc.HomeDomain != "" {
+		sopts = append(sopts, build.HomeDomain(acc.HomeDomain))
+	}
+	if acc.InflationDestination != "" {
+		sopts = append(sopts, build.InflationDest(acc.InflationDestination))
+	}
+	if len(sopts) > 0 {
+		ops = append(ops, build.SetOptions(append(sopts, asNext)...))
+	}
+
+	ops = append(ops, build.AccountMerge(build.Destination{AddressOrSeed: next}))
+	if len(ops) > maxOperations {
+		return nil, nil, i18n.Errorf("moving the account takes %d operations, more than the %d of a transaction: remove unused trustlines and data entries first", len(ops), maxOperations)
+	}
+
+	native, err := amount.Parse(acc.GetNativeBalance())
+	if err != nil {
+		return nil, nil, err
+	}
+	minimum, err := amount.Parse(strconv.FormatFloat(explain.MinimumBalance(acc), 'f', 7, 64))
+	if err != nil {
+		return nil, nil, err
+	}
+	if needed := minimum + startingBalance + xdr.Int64(stroops)*xdr.Int64(len(ops)); native < needed {
+		return nil, nil, i18n.Errorf("the account needs %s XLM to fund the new one, %s XLM more", amount.String(needed), amount.String(needed-native))
+	}
+
+	summary := map[string]string{
+		"Account":          acc.AccountID,
+		"New account":      next,
+		"Starting balance": formatStroops(startingBalance, "XLM") + " XLM",
+		"Trustlines":       firstNonEmpty(strings.Join(trustlines, ", "), "none"),
+		"Data entries":     strconv.Itoa(len(acc.Data)),
+		"Operations":       strconv.Itoa(len(ops)),
+		"Base fee":         fmt.Sprintf("%d stroops per operation", stroops),
+	}
+
+	return ops, summary, nil
+}
+
+// finishRotation gives the name of the wallet to the key saved as pending and
+// archives its old key
+func finishRotation(name, pending string, next *keypair.Full) {
+	if _, err := updateDB(func(m *wallet.Alfred) error {
+		return m.Rotate(name, pending)
+	}); err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf("%s is now %s, its old key is archived\n", name, next.Address())
+	fmt.Println("Share the new address with the senders of the wallet, the old account no longer exists")
+}
+
+func init() {
+	RootCmd.AddCommand(rotateCmd)
+
+	rotateCmd.Flags().BoolP("yes", "y", false, "if set, no confirmation prompt will be shown")
+}
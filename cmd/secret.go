@@ -0,0 +1,290 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/celrenheit/alfred/agent"
+	"github.com/celrenheit/alfred/hardware"
+	"github.com/celrenheit/alfred/paper"
+	"github.com/celrenheit/alfred/shamir"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// secretCmd represents the secret command
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Split the secret of the database into shares, recover it, or derive it from a hardware key",
+	Long: `Split the secret of the database with Shamir's secret sharing, so that it
+can be recovered from some of the shares instead of a single copy.
+
+Any --threshold of the shares recover the secret, fewer reveal nothing about it.
+Keep the shares in different places, or give them to different people.`,
+	Example: `alfred secret split --shares 5 --threshold 3
+alfred secret split --shares 5 --threshold 3 --output shares.pdf
+alfred secret recover`,
+}
+
+// secretSplitCmd represents the secret split command
+var secretSplitCmd = &cobra.Command{
+	Use:     "split",
+	Short:   "Split the secret of the database into shares",
+	Example: "alfred secret split --shares 5 --threshold 3",
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, secret := viper.GetString("db"), viper.GetString("secret")
+		if _, err := wallet.OpenSecretString(path, secret); err != nil {
+			fatal(err)
+		}
+
+		shares, _ := cmd.Flags().GetInt("shares")
+		threshold, _ := cmd.Flags().GetInt("threshold")
+		texts, err := shamir.SplitText([]byte(secret), shares, threshold)
+		if err != nil {
+			fatal(err)
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		if output == "" {
+			fmt.Printf("Any %d of these %d shares recover the secret of %s with alfred secret recover:\n", threshold, shares, path)
+			for i, text := range texts {
+				fmt.Printf("Share %d: %s\n", i+1, text)
+			}
+			return
+		}
+
+		doc := &paper.Document{Title: "Secret of the database"}
+		for i, text := range texts {
+			item, err := paper.NewItem(fmt.Sprintf("Share %d of %d", i+1, shares), text)
+			if err != nil {
+				fatal(err)
+			}
+
+			doc.Pages = append(doc.Pages, paper.Page{
+				Title: fmt.Sprintf("%s, share %d of %d", doc.Title, i+1, shares),
+				Note: fmt.Sprintf("Any %d of the %d shares recover the secret of %s with alfred secret recover, "+
+					"fewer reveal nothing about it. Keep the shares in different places.", threshold, shares, path),
+				Items: []paper.Item{item},
+			})
+		}
+
+		if err := writeDocument(doc, output); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("Shares written to %s, one per page, delete it once printed\n", output)
+	},
+}
+
+// secretRecoverCmd represents the secret recover command
+var secretRecoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Recover the secret of the database from its shares",
+	Long: `Recover the secret of the database from its shares, which are prompted
+until enough of them are given. The secret is checked against the database,
+when there is one.`,
+	Example: "alfred secret recover",
+	Run: func(cmd *cobra.Command, args []string) {
+		var texts []string
+		// the threshold is known from the first share
+		label, threshold := "Share 1", 2
+		if err := checkInteractive("share", "the shares of the secret are prompted", ""); err != nil {
+			fatal(err)
+		}
+
+		for len(texts) < threshold {
+			text, err := (&promptui.Prompt{
+				Label: label,
+				Validate: func(input string) error {
+					_, err := shamir.Threshold(input)
+					return err
+				},
+				Mask: '*',
+			}).Run()
+			if err != nil {
+				fatal(err)
+			}
+
+			threshold, _ = shamir.Threshold(text)
+			texts = append(texts, text)
+			label = fmt.Sprintf("Share %d of %d", len(texts)+1, threshold)
+		}
+
+		secret, err := shamir.CombineText(texts)
+		if err != nil {
+			fatal(err)
+		}
+
+		if path := viper.GetString("db"); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				if _, err := wallet.OpenSecretString(path, string(secret)); err != nil {
+					fatalf("the secret recovered does not decrypt %s: %v", path, err)
+				}
+				fmt.Printf("The secret recovered decrypts %s\n", path)
+			}
+		}
+
+		fmt.Printf("Secret: %s\n", secret)
+	},
+}
+
+// secretHardwareCmd represents the secret hardware command
+var secretHardwareCmd = &cobra.Command{
+	Use:   "hardware",
+	Short: "Derive the secret of the database from a hardware key",
+	Long: `Derive the secret of the database from its password and the response of a
+hardware key, so that the database file and its password are not enough to
+decrypt it. The database is encrypted again with the derived secret.
+
+A YubiKey answers with the HMAC-SHA1 challenge-response of one of its slots,
+configured with ykman otp chalresp or ykpersonalize, through ykchalresp. A
+FIDO2 key answers with its hmac-secret extension through fido2-cred and
+fido2-assert, of libfido2. Split the secret first, or keep a second key
+configured the same way: a database whose key is lost can not be decrypted.
+The secret split after enabling a hardware key is the derived one, which
+decrypts the database without the key.`,
+	Example: `alfred secret hardware enable --yubikey-slot 2
+alfred secret hardware enable --fido2 /dev/hidraw0
+alfred secret hardware disable`,
+}
+
+// secretHardwareEnableCmd represents the secret hardware enable command
+var secretHardwareEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Derive the secret of the database from a hardware key",
+	Example: `alfred secret hardware enable --yubikey-slot 2
+alfred secret hardware enable --fido2 /dev/hidraw0`,
+	Args:    cobra.NoArgs,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, password := viper.GetString("db"), viper.GetString("secret")
+		m, err := wallet.OpenSecretString(path, password)
+		if err != nil {
+			fatal(err)
+		}
+		if m.IsDecoy() {
+			fatal("the hardware key is set with the main secret of the database")
+		}
+		if m.Hardware != nil {
+			fatalf("the secret is already derived from a %s, disable it first", m.Hardware)
+		}
+
+		slot, _ := cmd.Flags().GetInt("yubikey-slot")
+		device, _ := cmd.Flags().GetString("fido2")
+		var key *hardware.Key
+		switch {
+		case slot != 0 && device != "":
+			fatal("either --yubikey-slot or --fido2 should be set, not both")
+		case slot != 0:
+			key, err = hardware.NewYubiKey(slot)
+		case device != "":
+			fmt.Fprintln(os.Stderr, "Making a credential for alfred on the FIDO2 key, touch it if it blinks")
+			key, err = hardware.NewFIDO2(device)
+		default:
+			fatal("the hardware key should be set with --yubikey-slot or --fido2")
+		}
+		if err != nil {
+			fatal(err)
+		}
+
+		response, err := respond(key)
+		if err != nil {
+			fatal(err)
+		}
+
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			m.Hardware = key
+			return m.Unlock([]byte(hardware.Secret(password, response)))
+		}); err != nil {
+			fatal(err)
+		}
+		forgetSecret()
+
+		fmt.Printf("The secret of %s is now derived from its password and the %s\n", path, key)
+	},
+}
+
+// secretHardwareDisableCmd represents the secret hardware disable command
+var secretHardwareDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Encrypt the database with its password only",
+	Long: `Encrypt the database with its password only, instead of the secret derived
+from its password and its hardware key. The password is prompted again.`,
+	Example: `alfred secret hardware disable`,
+	Args:    cobra.NoArgs,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+		if m.IsDecoy() {
+			fatal("the hardware key is set with the main secret of the database")
+		}
+		if m.Hardware == nil {
+			fatal("the secret is not derived from a hardware key")
+		}
+
+		if err := checkInteractive("password", "the database is encrypted with its password again", ""); err != nil {
+			fatal(err)
+		}
+		password, err := promptPassword()
+		if err != nil {
+			fatal(err)
+		}
+
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			m.Hardware = nil
+			return m.Unlock([]byte(password))
+		}); err != nil {
+			fatal(err)
+		}
+		forgetSecret()
+
+		fmt.Printf("The secret of %s is now its password\n", path)
+	},
+}
+
+// forgetSecret removes the secrets cached by the agent, if one is running,
+// after the secret of the database changed
+func forgetSecret() {
+	client, err := agent.Dial(viper.GetString("agent"))
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	client.Forget()
+}
+
+func init() {
+	RootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretSplitCmd)
+	secretCmd.AddCommand(secretRecoverCmd)
+	secretCmd.AddCommand(secretHardwareCmd)
+	secretHardwareCmd.AddCommand(secretHardwareEnableCmd)
+	secretHardwareCmd.AddCommand(secretHardwareDisableCmd)
+
+	secretSplitCmd.Flags().Int("shares", 5, "number of shares")
+	secretSplitCmd.Flags().Int("threshold", 3, "number of shares needed to recover the secret")
+	secretSplitCmd.Flags().String("output", "", "write the shares with their QR codes to a .html or .pdf file, one per page")
+	secretHardwareEnableCmd.Flags().Int("yubikey-slot", 0, "slot of the YubiKey configured for HMAC-SHA1 challenge-response, 1 or 2")
+	secretHardwareEnableCmd.Flags().String("fido2", "", "device of the FIDO2 key, as listed by fido2-token -L")
+}
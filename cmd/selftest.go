@@ -0,0 +1,368 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/celrenheit/alfred/explain"
+	"github.com/celrenheit/alfred/horizontest"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// selftestCmd represents the selftest command
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check alfred against a local fake horizon",
+	Long: `selftest starts a fake horizon backed by an in-memory ledger and runs send,
+offer and share commands against it, with a temporary database.
+It exits with a non zero status if one of them does not behave as expected.
+
+With --serve, only the fake horizon is started, on the given address, so that
+scripts wrapping alfred can be tested with --network testnet and --horizon.
+Accounts are funded with alfred fund, the ledger is lost when it stops.`,
+	Example: `alfred selftest
+alfred selftest --serve 127.0.0.1:8000
+alfred --network testnet --horizon http://127.0.0.1:8000 fund GXXX`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if addr, _ := cmd.Flags().GetString("serve"); addr != "" {
+			fmt.Println("Serving a fake horizon of the test network on http://" + addr)
+			ledger := horizontest.NewLedger(network.TestNetworkPassphrase)
+			if surge, _ := cmd.Flags().GetInt64("surge-fee"); surge > 0 {
+				ledger.SetSurgeFee(xdr.Int64(surge))
+			}
+			if err := http.ListenAndServe(addr, ledger); err != nil {
+				fatal(err)
+			}
+			return
+		}
+
+		failed, err := runSelftest(cmd, viper.GetBool("verbose"))
+		if err != nil {
+			fatal(err)
+		}
+		if failed > 0 {
+			fatalf("%d check(s) failed", failed)
+		}
+		fmt.Println("All checks passed")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(selftestCmd)
+
+	selftestCmd.Flags().String("serve", "", "only serve the fake horizon on this address")
+	selftestCmd.Flags().Int64("surge-fee", 0, "with --serve, refuse the transactions paying less than this fee per operation, as in surge pricing")
+}
+
+// selftestCheck is a step of the selftest
+type selftestCheck struct {
+	name string
+	// statement is run as with alfred please, unless run is set
+	statement string
+	run       func() error
+	// fails is set when the step should fail with an error containing it
+	fails string
+	// want checks the ledger after the step, if set
+	want func() error
+}
+
+// runSelftest runs the checks against a fake horizon, it returns how many failed
+func runSelftest(cmd *cobra.Command, verbose bool) (int, error) {
+	srv := horizontest.NewServer()
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "alfred-selftest")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+	path, secret := filepath.Join(dir, "alfred.yaml"), hex.EncodeToString(b)
+
+	viper.Set("db", path)
+	viper.Set("secret", secret)
+	viper.Set("network", testNetwork)
+	viper.Set("horizon", srv.URL)
+	viper.Set("yes", true)
+	viper.Set("presign", false)
+	viper.Set("memo-guard", "off")
+	viper.Set("grammar", "")
+
+	m, err := wallet.OpenSecretString(path, secret)
+	if err != nil {
+		return 0, err
+	}
+
+	kps := make(map[string]*keypair.Full)
+	for _, name := range []string{"master", "alice", "bob"} {
+		if kps[name], err = keypair.Random(); err != nil {
+			return 0, err
+		}
+		if err := m.AddWallet(wallet.New(name, kps[name])); err != nil {
+			return 0, err
+		}
+	}
+	if err := wallet.Write(path, m); err != nil {
+		return 0, err
+	}
+
+	for _, name := range []string{"master", "alice"} {
+		if err := srv.Ledger.Fund(kps[name].Address(), horizontest.FriendbotAmount); err != nil {
+			return 0, err
+		}
+	}
+
+	client := getClient(currentNetwork())
+	ledger := srv.Ledger
+	account := func(name string) (horizon.Account, error) {
+		acc, ok := ledger.Account(kps[name].Address())
+		if !ok {
+			return acc, i18n.Errorf("%s does not exist", name)
+		}
+		return acc, nil
+	}
+	nativeBalance := func(name, want string) func() error {
+		return func() error {
+			acc, err := account(name)
+			if err != nil {
+				return err
+			}
+			if got := acc.GetNativeBalance(); got != want {
+				return i18n.Errorf("%s has %s XLM, expected %s", name, got, want)
+			}
+			return nil
+		}
+	}
+
+	checks := []selftestCheck{
+		{
+			name:      "send",
+			statement: "send 100 XLM from master to alice",
+			want:      nativeBalance("alice", "10100.0000000"),
+		},
+		{
+			name:      "send creating the destination",
+			statement: "send 20 XLM from master to bob create with 2 XLM starting balance",
+			want:      nativeBalance("bob", "22.0000000"),
+		},
+		{
+			name:      "send to several recipients",
+			statement: "send 10 XLM from alice to master and bob",
+			want:      nativeBalance("bob", "32.0000000"),
+		},
+		{
+			name:      "payment above the balance",
+			statement: "send 50000 XLM from alice to master",
+			fails:     "op_underfunded",
+		},
+		{
+			name: "trust",
+			run: func() error {
+				mobi, err := selectAsset("MOBI")
+				if err != nil {
+					return err
+				}
+				return addTrustline(m, client, fullKey{kps["master"]}, *mobi)
+			},
+			want: func() error {
+				acc, err := account("master")
+				if err != nil {
+					return err
+				}
+				for _, b := range acc.Balances {
+					if b.Code == "MOBI" {
+						return nil
+					}
+				}
+				return errors.New("master does not trust MOBI")
+			},
+		},
+		{
+			name:      "offer",
+			statement: "sell 100 XLM for MOBI at 0.5 with master",
+			want: func() error {
+				offers := ledger.Offers(kps["master"].Address())
+				if len(offers) != 1 || offers[0].Amount != "100.0000000" || offers[0].Price != "0.5000000" {
+					return i18n.Errorf("unexpected offers %+v", offers)
+				}
+				return nil
+			},
+		},
+		{
+			name:      "share account",
+			statement: "share account master with alice",
+			want: func() error {
+				acc, err := account("master")
+				if err != nil {
+					return err
+				}
+				// master weighs one more than alice, so that only it can change the signers
+				if acc.Thresholds.HighThreshold != 2 {
+					return i18n.Errorf("high threshold is %d, expected 2", acc.Thresholds.HighThreshold)
+				}
+				for _, s := range acc.Signers {
+					if s.Key == kps["alice"].Address() && s.Weight == 1 {
+						return nil
+					}
+				}
+				return errors.New("alice is not a signer of master")
+			},
+		},
+		{
+			name: "memo required",
+			run: func() error {
+				viper.Set("wallet", "bob")
+				defer viper.Set("wallet", "")
+				return runStatement(m, client, cmd, &parser.SetDataRequest{
+					KVs: map[string]parser.DataEntry{memoRequiredKey: {Kind: parser.SetDataFromString, Value: "1"}},
+				})
+			},
+			want: func() error {
+				acc, err := account("bob")
+				if err != nil {
+					return err
+				}
+				if _, ok := acc.Data[memoRequiredKey]; !ok {
+					return errors.New("bob does not require a memo")
+				}
+				return nil
+			},
+		},
+		{
+			name:      "several recipients with one requiring a memo",
+			statement: "send 10 XLM from alice to master and bob memo lunch",
+			fails:     "requires a memo",
+		},
+	}
+
+	failed, submitted := 0, 0
+	for _, c := range checks {
+		run := c.run
+		if run == nil {
+			run = func() error {
+				statement, err := parser.Parse(c.statement)
+				if err != nil {
+					return err
+				}
+				return runStatement(m, client, cmd, statement)
+			}
+		}
+
+		step := run
+		if !verbose {
+			step = func() error { return quiet(run) }
+		}
+
+		err := step()
+		// the sequence numbers changed, even if the transaction failed
+		cached.reset()
+		if err := selftestResult(c, err); err != nil {
+			fmt.Printf("FAIL %s: %v\n", c.name, err)
+			failed++
+			continue
+		}
+
+		if c.fails == "" {
+			submitted++
+		}
+		fmt.Println("ok  ", c.name)
+	}
+
+	// every transaction submitted is recorded in the log of the database
+	err = func() error {
+		m, err := wallet.OpenSecretString(path, secret)
+		if err != nil {
+			return err
+		}
+		if _, err := m.VerifyLog(); err != nil {
+			return err
+		}
+		if len(m.Stellar.Log) != submitted {
+			return i18n.Errorf("%d transactions in the log, expected %d", len(m.Stellar.Log), submitted)
+		}
+		for _, e := range m.Stellar.Log {
+			if _, ok := ledger.Transaction(e.Hash); !ok {
+				return i18n.Errorf("transaction %s of the log is not in the ledger", e.Hash)
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		fmt.Printf("FAIL log: %v\n", err)
+		failed++
+	} else {
+		fmt.Println("ok   log")
+	}
+
+	return failed, nil
+}
+
+// selftestResult checks the outcome of a step
+func selftestResult(c selftestCheck, err error) error {
+	if c.fails != "" {
+		if err == nil {
+			return i18n.Errorf("succeeded, expected %s", c.fails)
+		}
+		if msg := explain.Error(err, nil); !strings.Contains(msg, c.fails) {
+			return i18n.Errorf("failed with %s, expected %s", msg, c.fails)
+		}
+		return nil
+	}
+
+	if err != nil {
+		return errors.New(explain.Error(err, nil))
+	}
+
+	if c.want != nil {
+		return c.want()
+	}
+	return nil
+}
+
+// quiet runs fn with the standard output discarded
+func quiet(fn func() error) error {
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer devnull.Close()
+
+	stdout := os.Stdout
+	os.Stdout = devnull
+	defer func() { os.Stdout = stdout }()
+
+	return fn()
+}
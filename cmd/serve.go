@@ -0,0 +1,223 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/celrenheit/alfred/api"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve balances, history, payments and offers over HTTP",
+	Long: `Serve the wallets over HTTP, so that bots and home automation can use
+alfred without running the command line.
+
+Every request is authenticated with the token given by --token, or the token
+setting, as an Authorization: Bearer <token> header. Without one, a random token
+is generated and printed. Nothing is prompted: commands should name their
+wallet, unless a default wallet is set, and the payments needing a
+confirmation by the policy of their wallet are refused.
+
+  GET  /balances/{wallet}
+  GET  /history/{wallet}?limit=20
+  POST /send    {"command": "send 10 XLM from master to jennifer"}
+  POST /offers  {"command": "buy 100 MOBI using XLM with master"}
+
+The endpoints answer in JSON, POST /send and POST /offers with the hashes of
+the transactions submitted.`,
+	Example: `alfred serve --listen :8200 --token $TOKEN
+curl -H "Authorization: Bearer $TOKEN" localhost:8200/balances/master
+curl -H "Authorization: Bearer $TOKEN" localhost:8200/send -d '{"command": "send 10 XLM from master to jennifer"}'`,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		version, err := parser.ParseVersion(viper.GetString("grammar"))
+		if err != nil {
+			fatal(err)
+		}
+
+		// check the secret once, rather than on every request
+		if _, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret")); err != nil {
+			fatal(err)
+		}
+
+		token := viper.GetString("token")
+		if token == "" {
+			b := make([]byte, 24)
+			if _, err := rand.Read(b); err != nil {
+				fatal(err)
+			}
+			token = hex.EncodeToString(b)
+			fmt.Println("Token:", token)
+		}
+
+		interactive = false
+		viper.Set("yes", true)
+		viper.Set("presign", false)
+
+		client := getClient(currentNetwork())
+		startMetrics(cmd, client, allWallets)
+
+		listen, _ := cmd.Flags().GetString("listen")
+		srv := &http.Server{
+			Addr: listen,
+			Handler: &api.Server{
+				Token:   token,
+				Version: version,
+				Backend: &serveBackend{client: client},
+			},
+		}
+
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			srv.Close()
+		}()
+
+		fmt.Printf("Serving on %s (%s)\n", listen, currentNetwork().Name)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("listen", "localhost:8200", "address listened on, such as :8200 for every interface")
+	serveCmd.Flags().String("token", "", "token authenticating the requests (default is a random token, printed)")
+	viper.BindPFlag("token", serveCmd.Flags().Lookup("token"))
+	addMetricsFlag(serveCmd)
+}
+
+// serveBackend executes the requests of the serve command one at a time, as
+// they share the database and the sequence numbers of the wallets
+type serveBackend struct {
+	mu     sync.Mutex
+	client *horizon.Client
+}
+
+// open reads the database again, so that the changes made by other commands are seen.
+// The accounts loaded by a previous request are forgotten.
+func (b *serveBackend) open() (*wallet.Alfred, error) {
+	cached.reset()
+	return wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+}
+
+func (b *serveBackend) Balances(name string) ([]api.Balance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+
+	kp := getAddress(m, name)
+	if kp == nil {
+		return nil, api.ErrNotFound
+	}
+
+	acc, exists, err := getAccount(b.client, kp.Address())
+	if err != nil {
+		return nil, errors.New(describeHorizonError(err))
+	}
+
+	balances := []api.Balance{}
+	if !exists {
+		return balances, nil
+	}
+
+	for _, balance := range acc.Balances {
+		code := balance.Asset.Code
+		if balance.Asset.Type == "native" {
+			code = "XLM"
+		}
+		balances = append(balances, api.Balance{Asset: code, Issuer: balance.Asset.Issuer, Balance: balance.Balance})
+	}
+
+	return balances, nil
+}
+
+func (b *serveBackend) History(name string, limit int) ([]api.Operation, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+
+	kp := getAddress(m, name)
+	if kp == nil {
+		return nil, api.ErrNotFound
+	}
+
+	txs, err := loadTransactions(b.client, kp.Address(), limit)
+	if err != nil {
+		return nil, errors.New(describeHorizonError(err))
+	}
+
+	ops := []api.Operation{}
+	for _, tx := range txs {
+		txOps, err := operations(tx, func(address string) string { return accountName(m, address) })
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, txOps...)
+	}
+
+	return ops, nil
+}
+
+// Run executes statement like the please command, the transactions submitted
+// are the ones added to the log of the database
+func (b *serveBackend) Run(statement parser.Statement) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+
+	logged := len(m.Stellar.Log)
+	if err := runStatement(m, b.client, serveCmd, statement); err != nil {
+		return nil, errors.New(describeHorizonError(err))
+	}
+
+	hashes := []string{}
+	for _, entry := range m.Stellar.Log[logged:] {
+		hashes = append(hashes, entry.Hash)
+	}
+
+	return hashes, nil
+}
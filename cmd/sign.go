@@ -0,0 +1,121 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/celrenheit/alfred/swap"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/xdr"
+)
+
+// signCmd represents the sign command
+var signCmd = &cobra.Command{
+	Use:   "sign <xdr> [wallet]",
+	Short: "Sign a transaction exported for offline signing",
+	Long: `Sign a transaction exported for a cold wallet, on the machine holding its
+seed, then print it for alfred submit.
+
+Without a wallet, it is signed by every wallet which is the source of the
+transaction or of one of its operations and did not sign it yet. The
+transaction can be given as base64 XDR or as the path of a file containing it.`,
+	Example: `alfred sign AAAAAG...
+alfred sign ./payment.xdr vault
+alfred sign --paste --copy`,
+	Args:    envelopeArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		arg, args, err := envelopeArg(cmd, args)
+		if err != nil {
+			fatal(err)
+		}
+		_, txe, err := readEnvelope(arg)
+		if err != nil {
+			fatal(err)
+		}
+
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		passphrase := currentNetwork().Passphrase
+		var signers []*wallet.Wallet
+		if len(args) == 1 {
+			w := m.WalletByName(args[0])
+			if w == nil {
+				fatalf("wallet '%s' not found%s", args[0], suggestName(args[0], accountNames(m, false)))
+			}
+			signers = append(signers, w)
+		} else {
+			for _, address := range sourceAccounts(txe) {
+				if w := m.WalletByAddress(address); w != nil && !w.IsCold() && !swap.SignedBy(txe, address, passphrase) {
+					signers = append(signers, w)
+				}
+			}
+		}
+		if len(signers) == 0 {
+			fatal("none of your wallets should sign this transaction, give the wallet signing it")
+		}
+
+		for _, w := range signers {
+			kp, err := hotKey(w)
+			if err != nil {
+				fatal(err)
+			}
+			if err := swap.Sign(&txe, kp, passphrase); err != nil {
+				fatal(err)
+			}
+			fmt.Println("Signed by", w.Name, "on", currentNetwork().Name)
+		}
+
+		txeB64, err := xdr.MarshalBase64(txe)
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Println("Submit it with: alfred submit <xdr>")
+		fmt.Println()
+		fmt.Println(txeB64)
+		copyValue("transaction", txeB64)
+	},
+}
+
+// sourceAccounts returns the source of txe followed by the other sources of
+// its operations
+func sourceAccounts(txe xdr.TransactionEnvelope) []string {
+	sources := []string{txe.Tx.SourceAccount.Address()}
+	seen := map[string]bool{sources[0]: true}
+	for _, op := range txe.Tx.Operations {
+		if op.SourceAccount == nil {
+			continue
+		}
+		if address := op.SourceAccount.Address(); !seen[address] {
+			seen[address] = true
+			sources = append(sources, address)
+		}
+	}
+
+	return sources
+}
+
+func init() {
+	RootCmd.AddCommand(signCmd)
+
+	addPasteFlag(signCmd)
+}
@@ -0,0 +1,138 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// signersCmd represents the signers command
+var signersCmd = &cobra.Command{
+	Use:   "signers [wallet or address]",
+	Short: "Show the signers and thresholds of an account",
+	Long: `Show the master weight, the additional signers and the thresholds of an
+account, and whether the keys held in the database weigh enough to meet each
+threshold.
+
+The low threshold applies to trustline authorizations and sequence bumps, the
+high one to changes of signers and thresholds and to merges, and the medium
+one to every other operation, such as payments and offers.`,
+	Example: `alfred signers master
+alfred signers GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		address, err := accountArg(m, args)
+		if err != nil {
+			fatal(err)
+		}
+
+		client := getClient(currentNetwork())
+		acc, exists, err := getAccount(client, address)
+		if err != nil {
+			fatal(err)
+		}
+		if !exists {
+			fatalf("account %s does not exist", address)
+		}
+
+		fmt.Println("Account:", accountName(m, address), address)
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Signer", "Name", "Type", "Weight", "Held locally"))
+		for _, s := range acc.Signers {
+			key, name := signerKey(s), accountName(m, signerKey(s))
+			if key == acc.ID {
+				name = "master key"
+			}
+			table.Append([]string{key, name, s.Type, strconv.Itoa(int(s.Weight)), yesNo(m.WalletByAddress(key) != nil)})
+		}
+		table.Render()
+
+		local := localWeight(m, acc)
+		table = newTable(os.Stdout)
+		table.SetHeader(header("Threshold", "Weight", "Operations", "Met locally"))
+		for _, t := range []struct {
+			name, ops string
+			weight    byte
+		}{
+			{"low", "allow trust, bump sequence", acc.Thresholds.LowThreshold},
+			{"medium", "payments, offers and others", acc.Thresholds.MedThreshold},
+			{"high", "signers, thresholds, merge", acc.Thresholds.HighThreshold},
+		} {
+			table.Append([]string{t.name, strconv.Itoa(int(t.weight)), t.ops, yesNo(local > 0 && local >= int32(t.weight))})
+		}
+		table.Render()
+
+		fmt.Printf("The keys held locally weigh %d\n", local)
+	},
+}
+
+// signerKey returns the key of s, older horizon versions only set its public key
+func signerKey(s horizon.Signer) string {
+	if s.Key != "" {
+		return s.Key
+	}
+	return s.PublicKey
+}
+
+// localWeight returns the total weight of the signers of acc held in m
+func localWeight(m *wallet.Alfred, acc horizon.Account) int32 {
+	var weight int32
+	for _, s := range acc.Signers {
+		if m.WalletByAddress(signerKey(s)) != nil {
+			weight += s.Weight
+		}
+	}
+	return weight
+}
+
+// accountArg returns the account of a wallet, contact or address given as
+// the only argument, or of the selected wallet without argument
+func accountArg(m *wallet.Alfred, args []string) (string, error) {
+	if len(args) == 1 {
+		address, _, err := resolveDestination(m, args[0])
+		return address, err
+	}
+
+	kp, err := selectWallet(m)
+	if err != nil {
+		return "", err
+	}
+	return kp.Address(), nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func init() {
+	RootCmd.AddCommand(signersCmd)
+}
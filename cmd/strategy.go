@@ -0,0 +1,256 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// strategyCmd represents the strategy command
+var strategyCmd = &cobra.Command{
+	Use:   "strategy",
+	Short: "List the trading strategies run by the daemon",
+	Long: `List the trading strategies stored in the database, they are run by
+alfred daemon.
+
+A DCA (dollar-cost averaging) strategy spends a fixed amount on an asset at
+regular intervals, whatever its price. A run is skipped when the order book
+can not fill the amount or when filling it would move the price by more than
+the maximum slippage, a percentage set with --max-slippage or the
+max-slippage setting (1 by default).`,
+	Example: `alfred strategy dca buy 50 XLM of MOBI every week with master
+alfred strategy
+alfred strategy log 1
+alfred strategy remove 1`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		if len(m.Stellar.Strategies) == 0 {
+			fmt.Println("No strategy, add one with: alfred strategy dca buy 50 XLM of MOBI every week")
+			return
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("ID", "Wallet", "Strategy", "Max slippage", "Next run", "Last run"))
+		for _, s := range m.Stellar.Strategies {
+			slippage := "default"
+			if s.MaxSlippage > 0 {
+				slippage = strconv.FormatFloat(s.MaxSlippage, 'f', -1, 64) + "%"
+			}
+
+			last := "never"
+			if n := len(s.Runs); n > 0 {
+				last = s.Runs[n-1].Status + " " + s.Runs[n-1].Time.Local().Format(time.RFC822)
+			}
+
+			table.Append([]string{strconv.Itoa(s.ID), s.Wallet, describeStrategy(s), slippage, s.Next.Local().Format(time.RFC822), last})
+		}
+		table.Render()
+	},
+}
+
+// strategyDCACmd represents the strategy dca command
+var strategyDCACmd = &cobra.Command{
+	Use:   "dca",
+	Short: "Buy an asset with a fixed amount at regular intervals",
+	Long: `Store a DCA strategy buying an asset with a fixed amount of another one at
+regular intervals, the first run happens on the next check of the daemon.
+
+  BUY <amount> <asset> OF <asset> EVERY <duration> [WITH <wallet>]
+
+The duration is a unit (hour, day, week) or a number followed by a unit, it
+should be at least an hour.`,
+	Example: `alfred strategy dca buy 50 XLM of MOBI every week with master
+alfred strategy dca buy 10 XLM of SLT every 3 days --max-slippage 0.5`,
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		req, err := parser.ParseDCA(strings.Join(args, " "))
+		if err != nil {
+			fatal(err)
+		}
+		logStatement(req)
+
+		if _, err := strconv.ParseFloat(req.Amount, 64); err != nil {
+			fatal(err)
+		}
+
+		path := viper.GetString("db")
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		src, err := getOrSelectWallet(m, req.Account)
+		if err != nil {
+			fatal(err)
+		}
+
+		selling, err := selectAsset(req.Spending)
+		if err != nil {
+			fatal(err)
+		}
+
+		buying, err := selectAsset(req.Buying)
+		if err != nil {
+			fatal(err)
+		}
+
+		if selling.BuilderAsset == buying.BuilderAsset {
+			fatal("the assets bought and spent should be different")
+		}
+
+		slippage, _ := cmd.Flags().GetFloat64("max-slippage")
+		if slippage < 0 || slippage >= 100 {
+			fatal("the maximum slippage should be a percentage between 0 and 100")
+		}
+
+		s := wallet.Strategy{
+			Kind:          wallet.StrategyDCA,
+			Wallet:        m.WalletByAddress(src.Address()).Name,
+			Amount:        req.Amount,
+			SellingCode:   selling.CodeString(),
+			SellingIssuer: selling.BuilderAsset.Issuer,
+			BuyingCode:    buying.CodeString(),
+			BuyingIssuer:  buying.BuilderAsset.Issuer,
+			Every:         req.Every,
+			MaxSlippage:   slippage,
+			Next:          time.Now().UTC(),
+		}
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			s.ID = m.AddStrategy(s)
+			return nil
+		}); err != nil {
+			fatal(err)
+		}
+
+		fmt.Printf("Strategy %d: %s with %s\n", s.ID, describeStrategy(s), s.Wallet)
+		fmt.Println("It is run by: alfred daemon")
+	},
+}
+
+// strategyLogCmd represents the strategy log command
+var strategyLogCmd = &cobra.Command{
+	Use:     "log <id>",
+	Short:   "Show the runs of a strategy",
+	Example: "alfred strategy log 1",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		s, err := strategyByArg(m, args[0])
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Printf("Strategy %d: %s with %s\n", s.ID, describeStrategy(*s), s.Wallet)
+		if len(s.Runs) == 0 {
+			fmt.Println("It has not run yet, next run:", s.Next.Local().Format(time.RFC822))
+			return
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Date", "Status", "Price", "Bought", "Details"))
+		for _, run := range s.Runs {
+			details := strings.Join(strings.Fields(run.Reason), " ")
+			if run.Hash != "" {
+				details = run.Hash
+			}
+			table.Append([]string{run.Time.Local().Format(time.RFC822), run.Status, run.Price, run.Bought, details})
+		}
+		table.Render()
+	},
+}
+
+// strategyRemoveCmd represents the strategy remove command
+var strategyRemoveCmd = &cobra.Command{
+	Use:   "remove <id>",
+	Short: "Remove a strategy",
+	Long: `Remove a strategy, the daemon stops running it. The offers it already
+created are left on the order book.`,
+	Example: "alfred strategy remove 1",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			s, err := strategyByArg(m, args[0])
+			if err != nil {
+				return err
+			}
+			return m.RemoveStrategy(s.ID)
+		}); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func strategyByArg(m *wallet.Alfred, arg string) (*wallet.Strategy, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, i18n.Errorf("invalid strategy id '%s'", arg)
+	}
+
+	return m.Strategy(id)
+}
+
+// describeStrategy returns s as the command that created it
+func describeStrategy(s wallet.Strategy) string {
+	return fmt.Sprintf("buy %s %s of %s every %s", s.Amount, s.SellingCode, s.BuyingCode, describeInterval(s.Every))
+}
+
+func describeInterval(d time.Duration) string {
+	for _, unit := range []struct {
+		name string
+		d    time.Duration
+	}{{"week", 7 * 24 * time.Hour}, {"day", 24 * time.Hour}, {"hour", time.Hour}} {
+		if d%unit.d != 0 {
+			continue
+		}
+
+		if n := d / unit.d; n > 1 {
+			return fmt.Sprintf("%d %ss", n, unit.name)
+		}
+		return unit.name
+	}
+
+	return d.String()
+}
+
+func init() {
+	RootCmd.AddCommand(strategyCmd)
+	strategyCmd.AddCommand(strategyDCACmd)
+	strategyCmd.AddCommand(strategyLogCmd)
+	strategyCmd.AddCommand(strategyRemoveCmd)
+
+	strategyDCACmd.Flags().Float64("max-slippage", 0, "percentage the average price may be worse than the best one (0 for the max-slippage setting)")
+}
@@ -0,0 +1,133 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/celrenheit/alfred/webhook"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// submitCmd represents the submit command
+var submitCmd = &cobra.Command{
+	Use:   "submit",
+	Short: "Submit a pre-signed transaction",
+	Long: `Submit a transaction previously signed with --presign or a NOT BEFORE clause.
+
+The transaction can be given as base64 XDR or as the path of a file containing it.
+When the submission fails, a scheduled_payment_failed event is posted to the
+webhooks given by --webhook or the webhooks setting.`,
+	Example: `alfred submit AAAAAG...
+alfred submit ./rent.xdr --wait
+alfred submit ./rent.xdr --wait --webhook https://example.com/alfred
+alfred submit --paste --copy`,
+	Args:    envelopeArgs(0),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		arg, _, err := envelopeArg(cmd, args)
+		if err != nil {
+			fatal(err)
+		}
+		txeB64, txe, err := readEnvelope(arg)
+		if err != nil {
+			fatal(err)
+		}
+
+		if tb := txe.Tx.TimeBounds; tb != nil {
+			minTime := time.Unix(int64(tb.MinTime), 0)
+			if tb.MaxTime != 0 && time.Now().After(time.Unix(int64(tb.MaxTime), 0)) {
+				fatalf("the transaction expired on %s", time.Unix(int64(tb.MaxTime), 0).Format(time.RFC1123))
+			}
+
+			if wait := time.Until(minTime); wait > 0 {
+				if w, _ := cmd.Flags().GetBool("wait"); !w {
+					fatalf("the transaction is not valid before %s, use --wait to submit it then", minTime.Format(time.RFC1123))
+				}
+
+				fmt.Println("Waiting until", minTime.Format(time.RFC1123))
+				time.Sleep(wait)
+			}
+		}
+
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		if entries := previousSubmissions(m); len(entries) > 0 {
+			printPreviousSubmissions(entries)
+			return
+		}
+		if p := pendingSubmission(m); p != nil {
+			fatalf("transaction %d with the idempotency key %s is pending, resume it with: alfred pending retry %d", p.ID, p.IdempotencyKey, p.ID)
+		}
+
+		client := getClient(currentNetwork())
+		resp, err := submitPending(m, client, txeB64)
+		if err != nil {
+			if n := getNotifier(cmd); n != nil {
+				failure := webhook.Failure{Error: describeHorizonError(err)}
+				if hash, err := network.HashTransaction(&txe.Tx, currentNetwork().Passphrase); err == nil {
+					failure.Transaction = hex.EncodeToString(hash[:])
+				}
+				notify(n, m, txe.Tx.SourceAccount.Address(), webhook.ScheduledPaymentFailed, failure)
+			}
+			fatal(err)
+		}
+
+		fmt.Println(resp.Hash)
+		printExplorerLinks(resp.Hash)
+		recordTx(txRequest{db: m}, resp.Hash, txeB64)
+	},
+}
+
+// readEnvelope decodes a transaction envelope given as base64 XDR or as the path of a file containing it
+func readEnvelope(arg string) (string, xdr.TransactionEnvelope, error) {
+	txeB64 := arg
+	if _, err := os.Stat(txeB64); err == nil {
+		b, err := ioutil.ReadFile(txeB64)
+		if err != nil {
+			return "", xdr.TransactionEnvelope{}, err
+		}
+		txeB64 = string(b)
+	}
+	txeB64 = strings.TrimSpace(txeB64)
+
+	var txe xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(txeB64, &txe); err != nil {
+		return "", xdr.TransactionEnvelope{}, i18n.Errorf("invalid transaction: %v", err)
+	}
+
+	return txeB64, txe, nil
+}
+
+func init() {
+	RootCmd.AddCommand(submitCmd)
+
+	submitCmd.Flags().Bool("wait", false, "wait until the transaction becomes valid before submitting it")
+	addWebhookFlags(submitCmd)
+	addPasteFlag(submitCmd)
+}
@@ -0,0 +1,313 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/swap"
+	"github.com/celrenheit/alfred/tx"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/xdr"
+)
+
+// swapCmd represents the swap command
+var swapCmd = &cobra.Command{
+	Use:   "swap",
+	Short: "Exchange assets with someone without trusting them",
+	Long: `Exchange assets with a counterparty in a single transaction holding both
+payments, so that either both are made or none of them.
+
+The proposer builds and signs the transaction with alfred swap propose and
+sends the printed XDR to the counterparty, who checks it, signs it and submits
+it with alfred swap accept.`,
+	Example: `alfred swap propose 100 MOBI for 50 XLM with bob from master
+alfred swap accept AAAAAG...`,
+}
+
+// swapProposeCmd represents the swap propose command
+var swapProposeCmd = &cobra.Command{
+	Use:   "propose",
+	Short: "Sign a swap for the counterparty to accept",
+	Long: `Build a transaction paying an amount to the counterparty and an amount back
+from it, sign it and print it for the counterparty to accept.
+
+  <amount> <asset> FOR <amount> <asset> WITH <counterparty> [FROM <wallet>]
+
+The wallet pays the fee. Any other transaction submitted by the wallet before
+the swap is accepted invalidates it, as they use the same sequence number.`,
+	Example: `alfred swap propose 100 MOBI for 50 XLM with bob from master
+alfred swap propose 10 XLM for 25 SLT with GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6 --expires 1h`,
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		req, err := parser.ParseSwap(strings.Join(args, " "))
+		if err != nil {
+			fatal(err)
+		}
+		logStatement(req)
+
+		for _, a := range []string{req.Amount, req.Price} {
+			if f, err := strconv.ParseFloat(a, 64); err != nil || f <= 0 {
+				fatalf("invalid amount '%s'", a)
+			}
+		}
+
+		if override, _ := cmd.Flags().GetBool("override-policy"); override {
+			viper.Set("override-policy", true)
+		}
+
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		src, err := getOrSelectWallet(m, req.Account)
+		if err != nil {
+			fatal(err)
+		}
+		if err := requireHot(m, src); err != nil {
+			fatal(err)
+		}
+
+		counterparty, _, err := resolveDestination(m, req.Counterparty)
+		if err != nil {
+			fatal(err)
+		}
+		if counterparty == src.Address() {
+			fatal("the counterparty should be another account")
+		}
+
+		selling, err := selectAsset(req.Selling)
+		if err != nil {
+			fatal(err)
+		}
+
+		buying, err := selectAsset(req.Buying)
+		if err != nil {
+			fatal(err)
+		}
+
+		if selling.BuilderAsset == buying.BuilderAsset {
+			fatal("the assets exchanged should be different")
+		}
+
+		client := getClient(currentNetwork())
+		acc, exists, err := getAccount(client, src.Address())
+		if err != nil {
+			fatal(err)
+		}
+		if !exists {
+			fatalf("account %s does not exist", src.Address())
+		}
+		if !hasTrustline(acc, *buying) {
+			fatalf("%s does not trust %s, add a trustline first with: alfred trust %s", accountName(m, src.Address()), buying.CodeString(), buying.CodeString())
+		}
+
+		confirm, err := enforcePolicy(m, client, src.Address(), selling, []outgoing{{to: counterparty, amount: req.Amount}})
+		if err != nil {
+			fatal(err)
+		}
+
+		stroops, err := baseFee(client)
+		if err != nil {
+			fatal(err)
+		}
+
+		seed, err := src.Seed()
+		if err != nil {
+			fatal(err)
+		}
+
+		opts := []build.TransactionMutator{
+			build.SourceAccount{seed},
+			build.AutoSequence{SequenceProvider: client},
+			build.BaseFee{Amount: stroops},
+			build.Payment(build.Destination{AddressOrSeed: counterparty}, paymentAmount(selling, req.Amount)),
+			build.Payment(build.SourceAccount{AddressOrSeed: counterparty}, build.Destination{AddressOrSeed: src.Address()}, paymentAmount(buying, req.Price)),
+		}
+		opts = append(opts, currentNetwork().mutator())
+
+		expires, _ := cmd.Flags().GetDuration("expires")
+		r := tx.Request{
+			Builder:  builder,
+			Seeds:    []string{seed},
+			Opts:     opts,
+			ValidFor: expires,
+			Presign:  true,
+			Trace:    logUnsigned,
+		}
+		if confirm {
+			summary := map[string]string{
+				"You pay":      req.Amount + " " + selling.CodeString(),
+				"You receive":  req.Price + " " + buying.CodeString(),
+				"Counterparty": counterparty,
+			}
+			r.Confirm = func(unsigned string) error {
+				return confirmPreview(summary, m, client, unsigned)
+			}
+		}
+
+		res, err := tx.Submit(r)
+		if err != nil {
+			fatal(err)
+		}
+
+		name := accountName(m, src.Address())
+		fmt.Printf("%s pays %s %s to %s\n", name, req.Amount, selling.CodeString(), req.Counterparty)
+		fmt.Printf("%s pays %s %s to %s\n", req.Counterparty, req.Price, buying.CodeString(), name)
+		if !res.Built.Bounds.MaxTime.IsZero() {
+			fmt.Println("The swap can be accepted until", res.Built.Bounds.MaxTime.Format(time.RFC1123))
+		}
+		fmt.Println("Any other transaction submitted by", name, "before it will invalidate it, as they use the same sequence number.")
+		fmt.Println("Send it to the counterparty, who accepts it with: alfred swap accept <xdr>")
+		fmt.Println()
+		fmt.Println(res.Envelope)
+		copyValue("transaction", res.Envelope)
+	},
+}
+
+// swapAcceptCmd represents the swap accept command
+var swapAcceptCmd = &cobra.Command{
+	Use:   "accept <xdr>",
+	Short: "Sign and submit a swap proposed to one of your wallets",
+	Long: `Check a swap proposed with alfred swap propose, show what is paid and
+received, then sign it with the wallet of the counterparty and submit it.
+
+The transaction is refused unless it holds exactly the two payments of a swap
+and is signed by its proposer. It can be given as base64 XDR or as the path of
+a file containing it.`,
+	Example: `alfred swap accept AAAAAG...
+alfred swap accept ./swap.xdr --yes
+alfred swap accept --paste`,
+	Args:    envelopeArgs(0),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		arg, _, err := envelopeArg(cmd, args)
+		if err != nil {
+			fatal(err)
+		}
+		_, txe, err := readEnvelope(arg)
+		if err != nil {
+			fatal(err)
+		}
+
+		s, err := swap.Parse(txe)
+		if err != nil {
+			fatalf("not a swap: %v", err)
+		}
+
+		if !s.Expires.IsZero() && time.Now().After(s.Expires) {
+			fatalf("the swap expired on %s", s.Expires.Format(time.RFC1123))
+		}
+
+		if override, _ := cmd.Flags().GetBool("override-policy"); override {
+			viper.Set("override-policy", true)
+		}
+
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		w := m.WalletByAddress(s.Counterparty)
+		if w == nil {
+			fatalf("the swap is proposed to %s, which is not one of your wallets", s.Counterparty)
+		}
+
+		passphrase := currentNetwork().Passphrase
+		if !swap.SignedBy(txe, s.Proposer, passphrase) {
+			fatalf("the swap is not signed by its proposer %s on this network", s.Proposer)
+		}
+
+		client := getClient(currentNetwork())
+		confirm, err := enforcePolicy(m, client, s.Counterparty, legAsset(s.Take.Asset), []outgoing{{to: s.Proposer, amount: s.Take.Amount}})
+		if err != nil {
+			fatal(err)
+		}
+
+		summary := map[string]string{
+			"Wallet":      w.Name,
+			"You pay":     s.Take.Amount + " " + assetName(s.Take.Asset),
+			"You receive": s.Give.Amount + " " + assetName(s.Give.Asset),
+			"Proposer":    accountName(m, s.Proposer),
+		}
+		if !s.Expires.IsZero() {
+			summary["Valid until"] = s.Expires.Format(time.RFC1123)
+		}
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		if !(yes || viper.GetBool("yes")) || confirm {
+			if err := confirmSummary(summary); err != nil {
+				fatal(err)
+			}
+		}
+
+		kp, err := hotKey(w)
+		if err != nil {
+			fatal(err)
+		}
+		if err := swap.Sign(&txe, kp, passphrase); err != nil {
+			fatal(err)
+		}
+		txeB64, err := xdr.MarshalBase64(txe)
+		if err != nil {
+			fatal(err)
+		}
+
+		resp, err := submitPending(m, client, txeB64)
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Println(resp.Hash)
+		printExplorerLinks(resp.Hash)
+		recordTx(txRequest{db: m}, resp.Hash, txeB64)
+	},
+}
+
+// legAsset returns a, which may not be one of the supported assets
+func legAsset(a xdr.Asset) *assets.Asset {
+	var typ xdr.AssetType
+	var code, issuer string
+	if err := a.Extract(&typ, &code, &issuer); err != nil || typ == xdr.AssetTypeAssetTypeNative {
+		return &assets.Asset{BuilderAsset: build.NativeAsset()}
+	}
+
+	if asset := assets.GetByCodeIssuer(code, issuer); asset != nil {
+		return asset
+	}
+	return &assets.Asset{BuilderAsset: build.CreditAsset(code, issuer)}
+}
+
+func init() {
+	RootCmd.AddCommand(swapCmd)
+	swapCmd.AddCommand(swapProposeCmd)
+	swapCmd.AddCommand(swapAcceptCmd)
+
+	swapProposeCmd.Flags().Duration("expires", 24*time.Hour, "time the counterparty has to accept the swap (0 for no limit)")
+	swapProposeCmd.Flags().Bool("override-policy", false, "propose swaps denied by the policy of the wallet, the violation is logged")
+	swapAcceptCmd.Flags().BoolP("yes", "y", false, "if set, no confirmation prompt will be shown")
+	swapAcceptCmd.Flags().Bool("override-policy", false, "accept swaps denied by the policy of the wallet, the violation is logged")
+	addPasteFlag(swapAcceptCmd)
+}
@@ -0,0 +1,123 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// tagCmd represents the tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag [tag...]",
+	Short: "List the tags of the wallets and contacts",
+	Long: `List the wallets and contacts with their tags, only the ones with every tag
+given if any. Tags are free words grouping the wallets and contacts, such as
+#exchange or #cold, written with or without the #.
+
+The balances, history and dashboard take --tag to only show the wallets with
+a tag.`,
+	Example: `alfred tag add vault cold savings
+alfred tag add kraken #exchange
+alfred tag
+alfred tag cold
+alfred balances --tag cold
+alfred tag remove vault savings`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Name", "Kind", "Address", "Tags"))
+		found := false
+		for _, a := range namedAccounts(m) {
+			if len(a.tags) > 0 && wallet.HasTags(a.tags, args...) {
+				found = true
+				table.Append([]string{a.name, a.kind, a.address, "#" + strings.Join(a.tags, " #")})
+			}
+		}
+		if !found {
+			if len(args) > 0 {
+				fmt.Println("No wallet or contact tagged", strings.Join(args, " "))
+				return
+			}
+			fmt.Println("No tag, add one with: alfred tag add <wallet or contact> <tag>")
+			return
+		}
+		table.Render()
+	},
+}
+
+// tagAddCmd represents the tag add command
+var tagAddCmd = &cobra.Command{
+	Use:     "add <wallet or contact> <tag>...",
+	Short:   "Tag a wallet or contact",
+	Example: "alfred tag add vault cold savings",
+	Args:    cobra.MinimumNArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			return m.Tag(args[0], args[1:]...)
+		}); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// tagRemoveCmd represents the tag remove command
+var tagRemoveCmd = &cobra.Command{
+	Use:     "remove <wallet or contact> <tag>...",
+	Short:   "Remove tags from a wallet or contact",
+	Example: "alfred tag remove vault savings",
+	Args:    cobra.MinimumNArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			return m.Untag(args[0], args[1:]...)
+		}); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(tagCmd)
+	tagCmd.AddCommand(tagAddCmd)
+	tagCmd.AddCommand(tagRemoveCmd)
+}
+
+// addTagFlag adds --tag to cmd, restricting it to the wallets with the tags
+func addTagFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("tag", nil, "only the wallets with this tag, repeated for the ones with every tag")
+}
+
+// taggedWallets returns the wallets of m with the tags of --tag, failing when
+// there is none
+func taggedWallets(cmd *cobra.Command, m *wallet.Alfred) []*wallet.Wallet {
+	tags, _ := cmd.Flags().GetStringSlice("tag")
+	ws := m.WalletsTagged(tags...)
+	if len(ws) == 0 && len(tags) > 0 {
+		fatalf("no wallet tagged %s", strings.Join(tags, " "))
+	}
+	return ws
+}
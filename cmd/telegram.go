@@ -0,0 +1,448 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/telegram"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// pendingExpiry is the time left to confirm a payment requested to the bot
+const pendingExpiry = 10 * time.Minute
+
+// telegramCmd represents the telegram command
+var telegramCmd = &cobra.Command{
+	Use:   "telegram",
+	Short: "Answer balance queries and payment requests sent to a Telegram bot",
+	Long: `Run a Telegram bot answering the chats allowed by --chat, or the
+telegram.chats setting. Messages of the other chats are ignored, their id is
+printed so that they can be allowed.
+
+  balance master
+  send 10 XLM from master to jennifer
+
+Payments use the grammar of the please command and are only submitted once
+confirmed with the button of the answer. Each chat can be limited to an amount
+of XLM per day with --limit, or per chat with the telegram.limits setting; chats
+with a limit can only send XLM.
+
+Create the bot and get its token by talking to @BotFather.`,
+	Example: `alfred telegram --token 123456:ABC-DEF --chat 987654321 --limit 100`,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		parse, err := statementParser()
+		if err != nil {
+			fatal(err)
+		}
+
+		token := viper.GetString("telegram.token")
+		if token == "" {
+			fatal("the token of the bot is needed, given by --token or the telegram.token setting")
+		}
+
+		chats := make(map[int64]bool)
+		for _, c := range viper.GetStringSlice("telegram.chats") {
+			id, err := strconv.ParseInt(c, 10, 64)
+			if err != nil {
+				fatalf("invalid chat id '%s'", c)
+			}
+			chats[id] = true
+		}
+		if len(chats) == 0 {
+			fmt.Println("No chat is allowed yet, send a message to the bot to get the id of your chat")
+		}
+
+		if _, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret")); err != nil {
+			fatal(err)
+		}
+
+		interactive = false
+		viper.Set("yes", true)
+		viper.Set("presign", false)
+
+		client := getClient(currentNetwork())
+		startMetrics(cmd, client, allWallets)
+
+		b := &telegramBot{
+			client:  &telegram.Client{Token: token, URL: viper.GetString("telegram.api"), HTTP: &http.Client{Timeout: time.Minute}},
+			backend: &serveBackend{client: client},
+			parse:   parse,
+			chats:   chats,
+			pending: make(map[int]pendingPayment),
+			spent:   make(map[int64][]spending),
+		}
+
+		fmt.Printf("Answering Telegram messages (%s)\n", currentNetwork().Name)
+		b.run()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(telegramCmd)
+
+	telegramCmd.Flags().String("token", "", "token of the bot, given by @BotFather")
+	telegramCmd.Flags().StringSlice("chat", nil, "id of a chat allowed to use the bot, can be repeated")
+	telegramCmd.Flags().Float64("limit", 0, "XLM each chat can send over 24 hours, 0 for no limit")
+	telegramCmd.Flags().String("api", telegram.DefaultURL, "URL of the Bot API, such as a local Bot API server")
+	viper.BindPFlag("telegram.token", telegramCmd.Flags().Lookup("token"))
+	viper.BindPFlag("telegram.chats", telegramCmd.Flags().Lookup("chat"))
+	viper.BindPFlag("telegram.limit", telegramCmd.Flags().Lookup("limit"))
+	viper.BindPFlag("telegram.api", telegramCmd.Flags().Lookup("api"))
+	addMetricsFlag(telegramCmd)
+}
+
+// pendingPayment is a payment waiting for the confirmation of its chat
+type pendingPayment struct {
+	chat      int64
+	command   string
+	statement *parser.SendRequest
+	amount    float64
+	expires   time.Time
+}
+
+// spending is an amount of XLM sent by a chat
+type spending struct {
+	time   time.Time
+	amount float64
+}
+
+// telegramBot answers the updates one at a time, so its state is not locked
+type telegramBot struct {
+	client  *telegram.Client
+	backend *serveBackend
+	parse   func(string) (parser.Statement, error)
+	chats   map[int64]bool
+
+	// pending are the payments waiting for a confirmation, by id
+	pending map[int]pendingPayment
+	lastID  int
+	// spent lists the XLM sent by each chat over the last 24 hours
+	spent map[int64][]spending
+}
+
+func (b *telegramBot) run() {
+	offset := 0
+	for {
+		updates, err := b.client.Updates(offset, 30*time.Second)
+		if err != nil {
+			if e, ok := err.(*telegram.Error); ok && e.Code == http.StatusUnauthorized {
+				fatal("the token of the bot is invalid")
+			}
+
+			fmt.Println(err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.ID + 1
+			switch {
+			case u.Message != nil:
+				b.message(u.Message)
+			case u.CallbackQuery != nil:
+				b.button(u.CallbackQuery)
+			}
+		}
+	}
+}
+
+func (b *telegramBot) reply(chat int64, text string) {
+	if _, err := b.client.Send(chat, text, nil); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (b *telegramBot) message(msg *telegram.Message) {
+	chat := msg.Chat.ID
+	if !b.chats[chat] {
+		fmt.Printf("Ignored a message from chat %d, allow it with --chat %d\n", chat, chat)
+		return
+	}
+
+	text := strings.TrimPrefix(strings.TrimSpace(msg.Text), "/")
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "start", "help":
+		b.reply(chat, telegramHelp)
+		return
+	case "balance", "balances":
+		name := viper.GetString("wallet")
+		if len(fields) > 1 {
+			name = fields[1]
+		}
+		if name == "" {
+			b.reply(chat, "Which wallet? Such as: balance master")
+			return
+		}
+
+		b.reply(chat, b.balances(name))
+		return
+	}
+
+	statement, err := b.parse(text)
+	if err != nil {
+		b.reply(chat, fmt.Sprintf("%v\n\nSend help for examples.", err))
+		return
+	}
+	logStatement(statement)
+
+	req, ok := statement.(*parser.SendRequest)
+	if !ok {
+		b.reply(chat, "Only balances and payments can be requested here.")
+		return
+	}
+
+	amount, err := b.check(chat, req)
+	if err != nil {
+		b.reply(chat, err.Error())
+		return
+	}
+
+	for id, p := range b.pending {
+		if time.Now().After(p.expires) {
+			delete(b.pending, id)
+		}
+	}
+
+	b.lastID++
+	b.pending[b.lastID] = pendingPayment{
+		chat:      chat,
+		command:   text,
+		statement: req,
+		amount:    amount,
+		expires:   time.Now().Add(pendingExpiry),
+	}
+
+	keyboard := &telegram.Keyboard{Buttons: [][]telegram.Button{{
+		{Text: "Confirm", Data: fmt.Sprintf("confirm:%d", b.lastID)},
+		{Text: "Cancel", Data: fmt.Sprintf("cancel:%d", b.lastID)},
+	}}}
+	if _, err := b.client.Send(chat, describePayment(req)+"?", keyboard); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// button handles the confirmation or the cancellation of a pending payment
+func (b *telegramBot) button(q *telegram.CallbackQuery) {
+	if q.Message == nil || !b.chats[q.Message.Chat.ID] {
+		b.client.Answer(q.ID, "This chat is not allowed")
+		return
+	}
+	chat, msg := q.Message.Chat.ID, q.Message.ID
+
+	parts := strings.SplitN(q.Data, ":", 2)
+	id := 0
+	if len(parts) == 2 {
+		id, _ = strconv.Atoi(parts[1])
+	}
+
+	p, ok := b.pending[id]
+	if !ok || p.chat != chat {
+		b.client.Answer(q.ID, "This payment is no longer pending")
+		return
+	}
+	delete(b.pending, id)
+
+	b.client.Answer(q.ID, "")
+	switch {
+	case parts[0] != "confirm":
+		b.edit(chat, msg, "Cancelled: "+p.command)
+		return
+	case time.Now().After(p.expires):
+		b.edit(chat, msg, "Expired: "+p.command)
+		return
+	}
+
+	// the limit may have been reached by another payment in the meantime
+	if _, err := b.check(chat, p.statement); err != nil {
+		b.edit(chat, msg, fmt.Sprintf("Refused: %s\n%v", p.command, err))
+		return
+	}
+
+	hashes, err := b.backend.Run(p.statement)
+	if err != nil {
+		b.edit(chat, msg, fmt.Sprintf("Failed: %s\n%v", p.command, err))
+		return
+	}
+
+	if p.amount > 0 {
+		b.spent[chat] = append(b.spent[chat], spending{time: time.Now(), amount: p.amount})
+	}
+	b.edit(chat, msg, fmt.Sprintf("Sent: %s\nTransaction %s", p.command, strings.Join(hashes, ", ")))
+}
+
+func (b *telegramBot) edit(chat int64, msg int, text string) {
+	if err := b.client.Edit(chat, msg, text); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// check returns an error if req can not be requested by chat, and the amount
+// of XLM it counts against the limit of the chat
+func (b *telegramBot) check(chat int64, req *parser.SendRequest) (float64, error) {
+	if req.From == "" && viper.GetString("wallet") == "" {
+		return 0, i18n.Errorf("Which wallet pays? Such as: send %s %s from master to jennifer", req.Amount, req.Currency)
+	}
+	if len(req.Recipients()) == 0 {
+		return 0, i18n.Errorf("Who is paid? Such as: send %s %s from master to jennifer", req.Amount, req.Currency)
+	}
+	if req.Currency == "" {
+		return 0, i18n.Errorf("Which asset? Such as: send %s XLM to %s", req.Amount, req.To)
+	}
+	if !req.NotBefore.IsZero() {
+		return 0, errors.New("Scheduled payments (NOT BEFORE) can not be requested here.")
+	}
+
+	limit := b.limit(chat)
+	if limit == 0 {
+		return 0, nil
+	}
+
+	if !strings.EqualFold(req.Currency, "XLM") {
+		return 0, errors.New("The spending limit of this chat only allows XLM payments.")
+	}
+	if req.Percent != "" {
+		return 0, errors.New("The spending limit of this chat needs an amount, not a share of the balance.")
+	}
+
+	amount, err := strconv.ParseFloat(req.Amount, 64)
+	if err != nil {
+		return 0, i18n.Errorf("invalid amount '%s'", req.Amount)
+	}
+	if req.StartingBalance != "" {
+		// the destinations may exist, but this is only known when submitting
+		startingBalance, err := strconv.ParseFloat(req.StartingBalance, 64)
+		if err != nil {
+			return 0, i18n.Errorf("invalid starting balance '%s'", req.StartingBalance)
+		}
+		amount += startingBalance
+	}
+	amount *= float64(len(req.Recipients()))
+
+	spent := b.spentToday(chat)
+	if spent+amount > limit {
+		return 0, i18n.Errorf("This chat can send %s XLM per day, %s XLM were already sent today.",
+			formatAmount(strconv.FormatFloat(limit, 'f', 7, 64), "XLM"), formatAmount(strconv.FormatFloat(spent, 'f', 7, 64), "XLM"))
+	}
+
+	return amount, nil
+}
+
+// limit returns the XLM chat can send over 24 hours, zero for no limit
+func (b *telegramBot) limit(chat int64) float64 {
+	key := fmt.Sprintf("telegram.limits.%d", chat)
+	if viper.IsSet(key) {
+		return viper.GetFloat64(key)
+	}
+	return viper.GetFloat64("telegram.limit")
+}
+
+// spentToday returns the XLM sent by chat over the last 24 hours and forgets the older payments
+func (b *telegramBot) spentToday(chat int64) float64 {
+	var (
+		recent []spending
+		total  float64
+	)
+	for _, s := range b.spent[chat] {
+		if time.Since(s.time) < 24*time.Hour {
+			recent = append(recent, s)
+			total += s.amount
+		}
+	}
+
+	b.spent[chat] = recent
+	return total
+}
+
+func (b *telegramBot) balances(name string) string {
+	balances, err := b.backend.Balances(name)
+	if err != nil {
+		return err.Error()
+	}
+	if len(balances) == 0 {
+		return fmt.Sprintf("%s is not funded yet", name)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Balances of %s:", name)
+	for _, balance := range balances {
+		fmt.Fprintf(&buf, "\n%s %s", balance.Balance, balance.Asset)
+	}
+	return buf.String()
+}
+
+// describePayment describes req in plain words, such as "Send 10 XLM from master to jennifer"
+func describePayment(req *parser.SendRequest) string {
+	amount := req.Amount + " " + req.Currency
+	switch req.Percent {
+	case "":
+	case "100":
+		amount = "all the " + req.Currency
+	default:
+		amount = fmt.Sprintf("%s%% of the %s", req.Percent, req.Currency)
+	}
+
+	from := req.From
+	if from == "" {
+		from = viper.GetString("wallet")
+	}
+
+	to := req.Recipients()
+	recipients := to[0]
+	if len(to) > 1 {
+		recipients = strings.Join(to[:len(to)-1], ", ") + " and " + to[len(to)-1]
+	}
+
+	s := fmt.Sprintf("Send %s from %s to %s", amount, from, recipients)
+	if len(to) > 1 && req.Percent == "" {
+		s += " each"
+	}
+	if req.StartingBalance != "" {
+		s += fmt.Sprintf(", created with %s XLM if they do not exist", req.StartingBalance)
+	}
+	if req.Memo != "" {
+		s += fmt.Sprintf(" with memo \"%s\"", req.Memo)
+	}
+	if req.ValidFor > 0 {
+		s += fmt.Sprintf(", valid for %s", req.ValidFor)
+	}
+
+	return s
+}
+
+const telegramHelp = `Ask for a balance or a payment:
+
+balance master
+send 10 XLM from master to jennifer
+send 20 XLM from master to jennifer memo "dinner"
+send 5 XLM from master to alice, bob and carol
+
+Payments are only sent once confirmed with the button of the answer.`
@@ -0,0 +1,215 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// templateCmd represents the template command
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "List the please statements saved as templates",
+	Long: `List the please statements saved under a name, for the payments made again
+and again. The variables of a template are written between braces, such as
+{amount}, and given when it is run.`,
+	Example: `alfred template save rent "send 500 XLM from master to landlord memo 'rent'"
+alfred template save tip "send {amount} XLM from master to {to}"
+alfred template
+alfred template run rent
+alfred template run tip --var amount=5 --var to=bob
+alfred template remove rent`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		if len(m.Stellar.Templates) == 0 {
+			fmt.Println("No template, add one with: alfred template save rent \"send 500 XLM from master to landlord\"")
+			return
+		}
+
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Name", "Statement", "Variables"))
+		for _, t := range m.Stellar.Templates {
+			table.Append([]string{t.Name, t.Statement, strings.Join(t.Variables(), ", ")})
+		}
+		table.Render()
+	},
+}
+
+// templateSaveCmd represents the template save command
+var templateSaveCmd = &cobra.Command{
+	Use:   "save <name> <statement>",
+	Short: "Save a please statement under a name",
+	Long: `Save a please statement under a name. A statement without variables is
+checked when it is saved, one with variables when it is run.`,
+	Example: `alfred template save rent "send 500 XLM from master to landlord memo 'rent'"
+alfred template save tip send {amount} XLM from master to {to}`,
+	Args:    cobra.MinimumNArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, statement := args[0], strings.Join(args[1:], " ")
+
+		t := wallet.Template{Name: name, Statement: statement}
+		if len(t.Variables()) == 0 {
+			parse, err := statementParser()
+			if err != nil {
+				fatal(err)
+			}
+			if _, err := parse(statement); err != nil {
+				fatal(err)
+			}
+		}
+
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			return m.AddTemplate(name, statement)
+		}); err != nil {
+			fatal(err)
+		}
+
+		fmt.Println("It is run with: alfred template run", name)
+	},
+}
+
+// templateRunCmd represents the template run command
+var templateRunCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Run a saved please statement",
+	Long: `Run a saved please statement like the please command. The variables not
+given with --var are prompted.`,
+	Example: `alfred template run rent
+alfred template run tip --var amount=5 --var to=bob --yes`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		t, err := m.Template(args[0])
+		if err != nil {
+			fatal(err)
+		}
+
+		given, _ := cmd.Flags().GetStringArray("var")
+		values, err := collectVariables(*t, given)
+		if err != nil {
+			fatal(err)
+		}
+
+		query, err := t.Expand(values)
+		if err != nil {
+			fatal(err)
+		}
+
+		parse, err := statementParser()
+		if err != nil {
+			fatal(err)
+		}
+
+		statement, err := parse(query)
+		if err != nil {
+			fatal(err)
+		}
+		logStatement(statement)
+
+		if yes, _ := cmd.Flags().GetBool("yes"); yes {
+			viper.Set("yes", true)
+		}
+
+		if err := runStatement(m, getClient(currentNetwork()), cmd, statement); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// templateRemoveCmd represents the template remove command
+var templateRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Short:   "Remove a template",
+	Example: "alfred template remove rent",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := updateDB(func(m *wallet.Alfred) error {
+			return m.RemoveTemplate(args[0])
+		}); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// collectVariables returns the values of the variables of t, given as
+// name=value or prompted
+func collectVariables(t wallet.Template, given []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, kv := range given {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, i18n.Errorf("invalid variable '%s', should be name=value", kv)
+		}
+		values[parts[0]] = parts[1]
+	}
+
+	for _, name := range t.Variables() {
+		if _, ok := values[name]; ok {
+			continue
+		}
+
+		if err := checkInteractive("variable", fmt.Sprintf("template %s needs {%s}", t.Name, name), "--var "+name+"=<value>"); err != nil {
+			return nil, err
+		}
+
+		value, err := (&promptui.Prompt{
+			Label: name,
+			Validate: func(input string) error {
+				if strings.TrimSpace(input) == "" {
+					return errors.New("should not be empty")
+				}
+				return nil
+			},
+		}).Run()
+		if err != nil {
+			return nil, err
+		}
+		values[name] = strings.TrimSpace(value)
+	}
+
+	return values, nil
+}
+
+func init() {
+	RootCmd.AddCommand(templateCmd)
+	templateCmd.AddCommand(templateSaveCmd)
+	templateCmd.AddCommand(templateRunCmd)
+	templateCmd.AddCommand(templateRemoveCmd)
+
+	templateRunCmd.Flags().StringArray("var", nil, "value of a variable of the template, as name=value (prompted otherwise)")
+	templateRunCmd.Flags().BoolP("yes", "y", false, "if set, no confirmation prompt will be shown")
+}
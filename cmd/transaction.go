@@ -0,0 +1,473 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/celrenheit/alfred/explain"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// txCmd represents the tx command
+var txCmd = &cobra.Command{
+	Use:   "tx <hash>",
+	Short: "Show what happened to a submitted transaction",
+	Long: `Show a transaction submitted to the network: when and in which ledger it
+was applied, the fee charged, the result of each of its operations, the
+balances it changed and its effects, with links to the transaction on block
+explorers. --open opens the first one in the browser.
+
+The balance changes are decoded from the result meta of the transaction, or
+computed from its effects when the meta can not be decoded.`,
+	Example: `alfred tx 3389e9f0f1a65f19736cacf544c2e825313e8447f569233bb8db39aa607c8889
+alfred tx 3389e9f0f1a65f19736cacf544c2e825313e8447f569233bb8db39aa607c8889 --network testnet`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := wallet.OpenSecretString(viper.GetString("db"), viper.GetString("secret"))
+		if err != nil {
+			fatal(err)
+		}
+
+		client := getClient(currentNetwork())
+		tx, err := loadTransaction(client, args[0])
+		if err != nil {
+			fatal(err)
+		}
+
+		var (
+			txe    xdr.TransactionEnvelope
+			result xdr.TransactionResult
+		)
+		if err := xdr.SafeUnmarshalBase64(tx.EnvelopeXdr, &txe); err != nil {
+			fatalf("invalid envelope: %v", err)
+		}
+		if err := xdr.SafeUnmarshalBase64(tx.ResultXdr, &result); err != nil {
+			fatalf("invalid result: %v", err)
+		}
+
+		effects, err := loadEffects(client, tx.Hash)
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Println("Transaction:")
+		table := newTable(os.Stdout)
+		table.SetHeader(header("Field", "Value"))
+		table.SetAutoWrapText(false)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.Append([]string{"Hash", tx.Hash + " (" + currentNetwork().Name + ")"})
+		links := explorerLinks(tx.Hash)
+		for _, l := range links {
+			table.Append([]string{l.Name, l.URL})
+		}
+		table.Append([]string{"Ledger", strconv.Itoa(int(tx.Ledger))})
+		table.Append([]string{"Applied at", tx.LedgerCloseTime.Local().Format(time.RFC822)})
+		table.Append([]string{"Source", describeAccount(m, tx.Account)})
+		table.Append([]string{"Fee charged", fmt.Sprintf("%d stroops (%s XLM)", result.FeeCharged, formatStroops(result.FeeCharged, "XLM"))})
+		table.Append([]string{"Memo", describeMemo(txe.Tx.Memo)})
+		table.Append([]string{"Result", describeTxResult(result.Result.Code)})
+		table.Render()
+
+		var results []xdr.OperationResult
+		if result.Result.Results != nil {
+			results = *result.Result.Results
+		}
+
+		fmt.Println()
+		fmt.Println("Operations:")
+		table = newTable(os.Stdout)
+		table.SetHeader(header("#", "Type", "Details", "Result"))
+		table.SetAutoWrapText(false)
+		for i, op := range txe.Tx.Operations {
+			outcome := "not applied"
+			if i < len(results) {
+				outcome = describeOpResult(op.Body.Type, results[i])
+			}
+			table.Append([]string{strconv.Itoa(i + 1), explain.OperationName(op.Body.Type), describeOperation(m, op.Body), outcome})
+		}
+		table.Render()
+
+		changes, ok := metaBalanceChanges(tx.ResultMetaXdr)
+		if !ok {
+			changes = effectBalanceChanges(effects)
+		}
+		if len(changes) > 0 {
+			fmt.Println()
+			fmt.Println("Balance changes:")
+			table = newTable(os.Stdout)
+			table.SetHeader(header("Account", "Asset", "Change"))
+			for _, c := range changes {
+				table.Append([]string{accountLabel(m, c.account), c.asset, paintDelta(c.delta, signedAmount(c.delta, c.asset))})
+			}
+			table.Render()
+		}
+
+		if len(effects) > 0 {
+			fmt.Println()
+			fmt.Println("Effects:")
+			table = newTable(os.Stdout)
+			table.SetHeader(header("Account", "Effect", "Details"))
+			table.SetAutoWrapText(false)
+			for _, e := range effects {
+				table.Append([]string{accountLabel(m, e.Account), strings.Replace(e.Type, "_", " ", -1), e.details()})
+			}
+			table.Render()
+		}
+
+		openExplorer(links)
+	},
+}
+
+// effect is an effect of a transaction as returned by horizon
+type effect struct {
+	Type            string `json:"type"`
+	Account         string `json:"account"`
+	Amount          string `json:"amount"`
+	AssetType       string `json:"asset_type"`
+	AssetCode       string `json:"asset_code"`
+	StartingBalance string `json:"starting_balance"`
+	Limit           string `json:"limit"`
+	SoldAmount      string `json:"sold_amount"`
+	SoldAssetType   string `json:"sold_asset_type"`
+	SoldAssetCode   string `json:"sold_asset_code"`
+	BoughtAmount    string `json:"bought_amount"`
+	BoughtAssetType string `json:"bought_asset_type"`
+	BoughtAssetCode string `json:"bought_asset_code"`
+}
+
+// details returns the amounts of e
+func (e effect) details() string {
+	switch {
+	case e.StartingBalance != "":
+		return e.StartingBalance + " XLM"
+	case e.SoldAmount != "":
+		return fmt.Sprintf("sold %s %s for %s %s", e.SoldAmount, effectAsset(e.SoldAssetType, e.SoldAssetCode), e.BoughtAmount, effectAsset(e.BoughtAssetType, e.BoughtAssetCode))
+	case e.Amount != "":
+		return e.Amount + " " + effectAsset(e.AssetType, e.AssetCode)
+	case e.Limit != "":
+		return fmt.Sprintf("%s with limit %s", effectAsset(e.AssetType, e.AssetCode), e.Limit)
+	}
+	return ""
+}
+
+func effectAsset(typ, code string) string {
+	if typ == "native" {
+		return "XLM"
+	}
+	return code
+}
+
+// balanceChange is the change of the balance of an asset held by an account
+type balanceChange struct {
+	account string
+	asset   string
+	delta   xdr.Int64
+}
+
+// loadTransaction loads the transaction whose hash is hash
+func loadTransaction(client *horizon.Client, hash string) (horizon.Transaction, error) {
+	var tx horizon.Transaction
+	err := getJSON(client, "/transactions/"+hash, &tx)
+	if herr, ok := err.(*horizon.Error); ok && herr.Response.StatusCode == http.StatusNotFound {
+		return tx, i18n.Errorf("transaction '%s' not found on %s", hash, currentNetwork().Name)
+	}
+
+	return tx, err
+}
+
+// loadEffects loads the effects of the transaction whose hash is hash
+func loadEffects(client *horizon.Client, hash string) ([]effect, error) {
+	var page struct {
+		Embedded struct {
+			Records []effect `json:"records"`
+		} `json:"_embedded"`
+	}
+	if err := getJSON(client, "/transactions/"+hash+"/effects?limit=200", &page); err != nil {
+		return nil, err
+	}
+
+	return page.Embedded.Records, nil
+}
+
+// getJSON decodes the response of horizon to a GET on path into v
+func getJSON(client *horizon.Client, path string, v interface{}) error {
+	resp, err := client.HTTP.Get(strings.TrimRight(client.URL, "/") + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		herr := &horizon.Error{Response: resp}
+		if err := json.NewDecoder(resp.Body).Decode(&herr.Problem); err != nil {
+			return err
+		}
+		return herr
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// describeTxResult returns the result code of a transaction, explained when
+// it failed
+func describeTxResult(code xdr.TransactionResultCode) string {
+	name := resultCodeName(code.String(), "TransactionResultCode", "")
+	switch code {
+	case xdr.TransactionResultCodeTxSuccess:
+		return name
+	case xdr.TransactionResultCodeTxNoAccount:
+		name = "tx_no_source_account"
+	}
+	return explained(explain.Transaction(name))
+}
+
+// describeOpResult returns the result code of an operation of type t,
+// explained when it failed
+func describeOpResult(t xdr.OperationType, r xdr.OperationResult) string {
+	switch r.Code {
+	case xdr.OperationResultCodeOpBadAuth:
+		return explained(explain.Operation(t, "op_bad_auth"))
+	case xdr.OperationResultCodeOpNoAccount:
+		return explained(explain.Operation(t, "op_no_source_account"))
+	}
+	if r.Tr == nil {
+		return "unknown"
+	}
+
+	var code fmt.Stringer
+	switch tr := r.Tr; tr.Type {
+	case xdr.OperationTypeCreateAccount:
+		code = tr.CreateAccountResult.Code
+	case xdr.OperationTypePayment:
+		code = tr.PaymentResult.Code
+	case xdr.OperationTypePathPayment:
+		code = tr.PathPaymentResult.Code
+	case xdr.OperationTypeManageOffer:
+		code = tr.ManageOfferResult.Code
+	case xdr.OperationTypeCreatePassiveOffer:
+		code = tr.CreatePassiveOfferResult.Code
+	case xdr.OperationTypeSetOptions:
+		code = tr.SetOptionsResult.Code
+	case xdr.OperationTypeChangeTrust:
+		code = tr.ChangeTrustResult.Code
+	case xdr.OperationTypeAllowTrust:
+		code = tr.AllowTrustResult.Code
+	case xdr.OperationTypeAccountMerge:
+		code = tr.AccountMergeResult.Code
+	case xdr.OperationTypeInflation:
+		code = tr.InflationResult.Code
+	case xdr.OperationTypeManageData:
+		code = tr.ManageDataResult.Code
+	default:
+		return "unknown"
+	}
+
+	// The names of the codes are like PaymentResultCodePaymentUnderfunded
+	name := code.String()
+	i := strings.Index(name, "ResultCode")
+	if i < 0 {
+		return name
+	}
+	name = resultCodeName(name, name[:i+len("ResultCode")], name[:i])
+	if name == "op_success" {
+		return name
+	}
+	if name == "op_already_exist" {
+		name = "op_already_exists"
+	}
+	return explained(explain.Operation(t, name))
+}
+
+func explained(e explain.Explanation) string {
+	return e.Code + ": " + e.Message
+}
+
+// resultCodeName converts the name of an xdr result code, such as
+// PaymentResultCodePaymentUnderfunded, into the one used by horizon, such as
+// op_underfunded
+func resultCodeName(name, prefix, typ string) string {
+	name = strings.TrimPrefix(strings.TrimPrefix(name, prefix), typ)
+	if typ != "" {
+		name = "Op" + name
+	}
+
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// metaBalanceChanges returns the balances changed by the operations of a
+// transaction according to its result meta, false if it can not be decoded
+func metaBalanceChanges(metaB64 string) ([]balanceChange, bool) {
+	var meta xdr.TransactionMeta
+	if err := xdr.SafeUnmarshalBase64(metaB64, &meta); err != nil || meta.Operations == nil {
+		return nil, false
+	}
+
+	type balances struct {
+		account, asset string
+		before, after  xdr.Int64
+		changed        bool
+	}
+	var (
+		order []string
+		byKey = map[string]*balances{}
+	)
+	get := func(account string, asset xdr.Asset) *balances {
+		key := account + "/" + asset.String()
+		b, ok := byKey[key]
+		if !ok {
+			b = &balances{account: account, asset: assetName(asset)}
+			byKey[key] = b
+			order = append(order, key)
+		}
+		return b
+	}
+	native := xdr.Asset{Type: xdr.AssetTypeAssetTypeNative}
+
+	for _, op := range *meta.Operations {
+		for _, c := range op.Changes {
+			var entry *xdr.LedgerEntry
+			switch c.Type {
+			case xdr.LedgerEntryChangeTypeLedgerEntryState:
+				entry = c.State
+			case xdr.LedgerEntryChangeTypeLedgerEntryCreated:
+				entry = c.Created
+			case xdr.LedgerEntryChangeTypeLedgerEntryUpdated:
+				entry = c.Updated
+			case xdr.LedgerEntryChangeTypeLedgerEntryRemoved:
+				var b *balances
+				switch key := c.Removed; key.Type {
+				case xdr.LedgerEntryTypeAccount:
+					b = get(key.Account.AccountId.Address(), native)
+				case xdr.LedgerEntryTypeTrustline:
+					b = get(key.TrustLine.AccountId.Address(), key.TrustLine.Asset)
+				default:
+					continue
+				}
+				b.after, b.changed = 0, true
+				continue
+			}
+
+			var (
+				b       *balances
+				balance xdr.Int64
+			)
+			switch data := entry.Data; data.Type {
+			case xdr.LedgerEntryTypeAccount:
+				b, balance = get(data.Account.AccountId.Address(), native), data.Account.Balance
+			case xdr.LedgerEntryTypeTrustline:
+				b, balance = get(data.TrustLine.AccountId.Address(), data.TrustLine.Asset), data.TrustLine.Balance
+			default:
+				continue
+			}
+
+			if c.Type == xdr.LedgerEntryChangeTypeLedgerEntryState {
+				if !b.changed {
+					b.before, b.after = balance, balance
+				}
+				continue
+			}
+			b.after, b.changed = balance, true
+		}
+	}
+
+	var changes []balanceChange
+	for _, key := range order {
+		if b := byKey[key]; b.changed && b.after != b.before {
+			changes = append(changes, balanceChange{account: b.account, asset: b.asset, delta: b.after - b.before})
+		}
+	}
+
+	return changes, true
+}
+
+// effectBalanceChanges returns the balances changed by effects
+func effectBalanceChanges(effects []effect) []balanceChange {
+	var changes []balanceChange
+	add := func(account, asset, value string, sign xdr.Int64) {
+		delta, err := amount.Parse(value)
+		if err != nil || delta == 0 {
+			return
+		}
+		for i := range changes {
+			if changes[i].account == account && changes[i].asset == asset {
+				changes[i].delta += sign * delta
+				return
+			}
+		}
+		changes = append(changes, balanceChange{account: account, asset: asset, delta: sign * delta})
+	}
+
+	for _, e := range effects {
+		switch e.Type {
+		case "account_created":
+			add(e.Account, "XLM", e.StartingBalance, 1)
+		case "account_credited":
+			add(e.Account, effectAsset(e.AssetType, e.AssetCode), e.Amount, 1)
+		case "account_debited":
+			add(e.Account, effectAsset(e.AssetType, e.AssetCode), e.Amount, -1)
+		case "trade":
+			add(e.Account, effectAsset(e.SoldAssetType, e.SoldAssetCode), e.SoldAmount, -1)
+			add(e.Account, effectAsset(e.BoughtAssetType, e.BoughtAssetCode), e.BoughtAmount, 1)
+		}
+	}
+
+	var nonZero []balanceChange
+	for _, c := range changes {
+		if c.delta != 0 {
+			nonZero = append(nonZero, c)
+		}
+	}
+
+	return nonZero
+}
+
+// signedAmount returns delta, an amount of the asset with code, with its sign
+func signedAmount(delta xdr.Int64, code string) string {
+	if delta < 0 {
+		return "-" + formatStroops(-delta, code)
+	}
+	return "+" + formatStroops(delta, code)
+}
+
+func init() {
+	RootCmd.AddCommand(txCmd)
+}
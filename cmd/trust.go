@@ -16,14 +16,16 @@ package cmd
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/parser"
 	"github.com/celrenheit/alfred/wallet"
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
 	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
 )
 
 // trustCmd represents the import command
@@ -69,13 +71,16 @@ func init() {
 }
 
 func trust(m *wallet.Alfred, asset assets.Asset) error {
-	client := getClient(viper.GetBool("testnet"))
-
 	src, err := selectWallet(m)
 	if err != nil {
 		return err
 	}
 
+	return addTrustline(m, getClient(currentNetwork()), src, asset)
+}
+
+// addTrustline makes src trust asset
+func addTrustline(m *wallet.Alfred, client *horizon.Client, src walletKey, asset assets.Asset) error {
 	acc, exists, err := getAccount(client, src.Address())
 	if err != nil {
 		return err
@@ -89,48 +94,161 @@ func trust(m *wallet.Alfred, asset assets.Asset) error {
 		return errors.New("account already has this trustline")
 	}
 
+	seed, err := src.Seed()
+	if err != nil {
+		return err
+	}
+
 	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
+		build.SourceAccount{seed},
 		build.AutoSequence{SequenceProvider: client},
 		build.Trust(asset.BuilderAsset.Code, asset.BuilderAsset.Issuer),
 	}
 
-	if viper.GetBool("testnet") {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
+	opts = append(opts, currentNetwork().mutator())
+
+	return submitTx(txRequest{
+		client:   client,
+		db:       m,
+		seeds:    []string{seed},
+		opts:     opts,
+		validFor: viper.GetDuration("valid-for"),
+		retries:  viper.GetInt("retries"),
+		yes:      viper.GetBool("yes"),
+	})
+}
+
+// trustRequest adds the trustline of a TRUST statement or changes its limit
+func trustRequest(m *wallet.Alfred, client *horizon.Client, req *parser.TrustRequest) error {
+	src, err := getOrSelectWallet(m, req.Account)
+	if err != nil {
+		return err
+	}
+
+	asset, err := selectAsset(req.Asset)
+	if err != nil {
+		return err
+	}
+	if asset.BuilderAsset.Native {
+		return errors.New("XLM is the native asset, it needs no trustline")
 	}
 
-	tx, err := build.Transaction(opts...)
+	acc, exists, err := getAccount(client, src.Address())
 	if err != nil {
 		return err
 	}
+	if !exists {
+		return i18n.Errorf("account %s does not exist", src.Address())
+	}
 
-	txe, err := tx.Sign(src.Seed())
+	limit, err := newLimit(accountName(m, src.Address()), acc, *asset, req)
 	if err != nil {
 		return err
 	}
 
-	txeB64, err := txe.Base64()
+	seed, err := src.Seed()
 	if err != nil {
 		return err
 	}
 
-	if !viper.GetBool("yes") {
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
+	opts := []build.TransactionMutator{
+		build.SourceAccount{seed},
+		build.AutoSequence{SequenceProvider: client},
+		build.Trust(asset.BuilderAsset.Code, asset.BuilderAsset.Issuer, limit),
+	}
+
+	opts = append(opts, currentNetwork().mutator())
+
+	return submitTx(txRequest{
+		client:   client,
+		db:       m,
+		seeds:    []string{seed},
+		opts:     opts,
+		validFor: viper.GetDuration("valid-for"),
+		retries:  viper.GetInt("retries"),
+		presign:  viper.GetBool("presign"),
+		yes:      viper.GetBool("yes"),
+	})
+}
+
+// newLimit returns the limit of the trustline of name to asset once req is
+// applied, checking it against the current limit and balance
+func newLimit(name string, acc horizon.Account, asset assets.Asset, req *parser.TrustRequest) (build.Limit, error) {
+	limit := build.MaxLimit
+	if req.Limit != "" {
+		limit = build.Limit(req.Limit)
+	}
+	next, err := amount.Parse(string(limit))
+	if err != nil {
+		return "", err
+	}
+
+	line, ok := trustline(acc, asset)
+	if !ok {
+		switch {
+		case req.Lower:
+			return "", i18n.Errorf("%s does not trust %s, add a trustline first with: trust %s up to %s on %s", name, asset.CodeString(), req.Asset, req.Limit, name)
+		case next == 0:
+			return "", i18n.Errorf("the limit of a new trustline should be above 0")
 		}
+		return limit, nil
 	}
 
-	resp, err := client.SubmitTransaction(txeB64)
+	current, err := amount.Parse(line.Limit)
+	if err != nil {
+		return "", err
+	}
+	balance, err := amount.Parse(line.Balance)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case req.Lower && next >= current:
+		return "", i18n.Errorf("%s trusts %s up to %s, LOWER TRUST should go below it, raise it with: trust %s up to %s on %s", name, asset.CodeString(), formatLimit(line.Limit), req.Asset, req.Limit, name)
+	case next == current:
+		return "", i18n.Errorf("%s already trusts %s up to %s", name, asset.CodeString(), formatLimit(line.Limit))
+	case next == 0 && balance > 0:
+		return "", i18n.Errorf("%s holds %s %s, send or sell them before removing the trustline", name, line.Balance, asset.CodeString())
+	case next < balance:
+		return "", i18n.Errorf("%s holds %s %s, the limit of its trustline can not be below its balance", name, line.Balance, asset.CodeString())
+	}
+
+	return limit, nil
+}
+
+// checkLimit returns an error when receiving amt of asset would exceed the
+// limit of the trustline of acc, named name
+func checkLimit(name string, acc horizon.Account, asset assets.Asset, amt string) error {
+	line, ok := trustline(acc, asset)
+	if asset.BuilderAsset.Native || !ok {
+		return nil
+	}
+
+	limit, err := amount.Parse(line.Limit)
+	if err != nil {
+		return err
+	}
+	balance, err := amount.Parse(line.Balance)
+	if err != nil {
+		return err
+	}
+	received, err := amount.Parse(amt)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(resp.Hash)
+	if received > limit-balance {
+		return i18n.Errorf("%s trusts %s up to %s and holds %s, receiving %s would exceed the limit of its trustline: send at most %s or ask the recipient to raise it with: trust %s up to <limit>",
+			name, asset.CodeString(), formatLimit(line.Limit), line.Balance, amt, amount.String(limit-balance), asset.CodeString())
+	}
 	return nil
 }
+
+// formatLimit formats the limit of a trustline, the largest one being "max"
+func formatLimit(limit string) string {
+	if limit == string(build.MaxLimit) {
+		return "max"
+	}
+	return limit
+}
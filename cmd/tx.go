@@ -0,0 +1,377 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/celrenheit/alfred/explain"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/tx"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+)
+
+// txRequest describes a transaction to build, sign, confirm and submit
+type txRequest struct {
+	client *horizon.Client
+	// seeds sign the transaction, the addresses of cold wallets among them
+	// sign it offline
+	seeds []string
+	opts  []build.TransactionMutator
+
+	// db records the submitted transaction in its log, if set
+	db *wallet.Alfred
+
+	// summary is shown before the confirmation prompt, if set
+	summary map[string]string
+	// validFor limits the validity of the transaction, zero means no limit
+	validFor time.Duration
+	// notBefore is the time before which the transaction is invalid, zero means no limit
+	notBefore time.Time
+	// presign prints the signed transaction instead of submitting it
+	presign bool
+	// retries is the number of times a failed submission is retried
+	retries int
+	// yes skips the confirmation prompt
+	yes bool
+}
+
+// withTimeBounds applies the VALID FOR and NOT BEFORE clauses of a statement.
+// A transaction scheduled for later is only limited by its own VALID FOR clause
+// and is printed for later submission instead of being submitted.
+func (req txRequest) withTimeBounds(tb parser.TimeBounds) txRequest {
+	if !tb.NotBefore.IsZero() {
+		req.notBefore = tb.NotBefore
+		req.validFor = 0
+		if tb.NotBefore.After(time.Now()) {
+			req.presign = true
+		}
+	}
+
+	if tb.ValidFor > 0 {
+		req.validFor = tb.ValidFor
+	}
+
+	return req
+}
+
+// submitTx builds, signs and submits the transaction described by req, then
+// prints its hash, see tx.Submit for the retries. The summary is shown before
+// the confirmation prompt unless req.yes is set. A transaction which cold
+// wallets should sign is exported for offline signing instead.
+func submitTx(req txRequest) error {
+	seeds, signers := splitDelegated(req.seeds)
+	seeds, cold := splitCold(seeds)
+	if len(cold) > 0 {
+		req.presign = true
+	}
+
+	stroops, err := baseFee(req.client)
+	if err != nil {
+		return err
+	}
+	req.opts = append(req.opts, build.BaseFee{Amount: stroops})
+
+	r := tx.Request{
+		Builder:   builder,
+		Submitter: measuredSubmitter{tx.NewHorizon(req.client)},
+		Accounts:  req.client,
+		Seeds:     seeds,
+		Signers:   signers,
+		Opts:      req.opts,
+		ValidFor:  req.validFor,
+		NotBefore: req.notBefore,
+		Presign:   req.presign,
+		Retries:   req.retries,
+		Trace:     logUnsigned,
+	}
+	if !req.presign && !viper.GetBool("skip-preflight") {
+		r.Preflight = preflight(req)
+	}
+	if !req.yes {
+		r.Confirm = func(unsigned string) error {
+			return confirmPreview(req.summary, req.db, req.client, unsigned)
+		}
+	}
+
+	pending := pendingSubmissions{db: req.db}
+	r.Pending = pending.add
+
+	res, err := tx.Submit(r)
+	pending.settle(err)
+	if err != nil {
+		return err
+	}
+
+	if len(cold) > 0 {
+		return printCold(req.db, res.Built, res.Envelope, cold)
+	}
+	if res.Presigned {
+		return printPresigned(res.Built, res.Envelope)
+	}
+
+	fmt.Println(res.Hash)
+	printExplorerLinks(res.Hash)
+	cached.reset()
+	recordTx(req, res.Hash, res.Envelope)
+	return nil
+}
+
+// preflight returns the check of the transactions of req before they are
+// signed, which lists what is missing instead of waiting for the result codes
+// of horizon
+func preflight(req txRequest) func(unsigned string) error {
+	p := explain.Preflight{Accounts: cachedAccounts{req.client}}
+	for _, seed := range req.seeds {
+		if kp, err := keypair.Parse(seed); err == nil {
+			p.Signers = append(p.Signers, kp.Address())
+		}
+	}
+	if req.db != nil {
+		p.Name = func(address string) string {
+			return accountName(req.db, address)
+		}
+	}
+
+	return func(unsigned string) error {
+		var txe xdr.TransactionEnvelope
+		if err := xdr.SafeUnmarshalBase64(unsigned, &txe); err != nil {
+			return err
+		}
+
+		missing, err := p.Check(&txe)
+		if err != nil {
+			return err
+		}
+		if len(missing) == 0 {
+			return warnReserves(p, &txe)
+		}
+		err = errors.New(explain.Checklist(missing) + "\n(--skip-preflight submits it anyway)")
+		if insufficientFunds(missing) {
+			return withExitCode(exitInsufficientFunds, err)
+		}
+		return err
+	}
+}
+
+// warnReserves warns about the minimum balances of the sources of txe once it
+// is applied, which rise with the entries it adds and should stay
+// --reserve-margin below their balances
+func warnReserves(p explain.Preflight, txe *xdr.TransactionEnvelope) error {
+	margin, err := amount.Parse(viper.GetString("reserve-margin"))
+	if err != nil {
+		return withExitCode(exitParse, i18n.Errorf("invalid reserve margin '%s'", viper.GetString("reserve-margin")))
+	}
+
+	warnings, err := p.Reserves(txe, margin)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		fmt.Println("Warning:", w)
+	}
+	return nil
+}
+
+// cachedAccounts loads the accounts through the cache of the commands
+type cachedAccounts struct {
+	client *horizon.Client
+}
+
+// LoadAccount implements explain.AccountLoader
+func (c cachedAccounts) LoadAccount(id string) (horizon.Account, error) {
+	acc, exists, err := getAccount(c.client, id)
+	if err == nil && !exists {
+		err = explain.ErrAccountNotFound
+	}
+	return acc, err
+}
+
+// confirmSummary shows summary, if set, and asks for a confirmation
+func confirmSummary(summary map[string]string) error {
+	return confirmPreview(summary, nil, nil, "")
+}
+
+// confirmPreview shows summary, if set, and the preview of the unsigned
+// transaction, if set, then asks for a confirmation
+func confirmPreview(summary map[string]string, m *wallet.Alfred, client *horizon.Client, unsigned string) error {
+	if err := checkInteractive("confirmation", "the transaction needs a confirmation", "--yes, unless the policy of the wallet asks for it"); err != nil {
+		return err
+	}
+
+	if summary != nil {
+		printSummaryTable(summary)
+	}
+	if unsigned != "" {
+		if err := printPreview(m, client, unsigned); err != nil {
+			return err
+		}
+	}
+
+	_, err := (&promptui.Prompt{
+		Label:     i18n.T("Are you sure"),
+		IsConfirm: true,
+	}).Run()
+	return err
+}
+
+// builder builds the transactions of the commands
+var builder tx.Builder = tx.StellarBuilder{}
+
+func printPresigned(tb tx.Built, txeB64 string) error {
+	hash, err := tb.HashHex()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Transaction", hash, "was signed but not submitted.")
+	if !tb.Bounds.MinTime.IsZero() {
+		fmt.Println("It is valid from", tb.Bounds.MinTime.Format(time.RFC1123))
+	}
+	if !tb.Bounds.MaxTime.IsZero() {
+		fmt.Println("It is valid until", tb.Bounds.MaxTime.Format(time.RFC1123))
+	}
+	fmt.Println("Any other transaction submitted by the source account before it will invalidate it, as they use the same sequence number.")
+	fmt.Println("Submit it with: alfred submit <xdr>")
+	fmt.Println()
+	fmt.Println(txeB64)
+	copyValue("transaction", txeB64)
+
+	return nil
+}
+
+// splitCold returns the seeds among seeds, and the addresses of the cold
+// wallets standing in for theirs
+func splitCold(seeds []string) (hot, cold []string) {
+	for _, seed := range seeds {
+		if kp, err := keypair.Parse(seed); err == nil {
+			if _, ok := kp.(*keypair.FromAddress); ok {
+				cold = append(cold, seed)
+				continue
+			}
+		}
+		hot = append(hot, seed)
+	}
+
+	return hot, cold
+}
+
+// printCold prints the transaction tb, signed by the other signers, for the
+// cold wallets of addresses to sign it offline
+func printCold(m *wallet.Alfred, tb tx.Built, txeB64 string, addresses []string) error {
+	hash, err := tb.HashHex()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Transaction", hash, "should be signed offline by:")
+	for _, address := range addresses {
+		location := "unknown"
+		name := address
+		if m != nil {
+			if w := m.WalletByAddress(address); w != nil {
+				name, location = w.Name, w.Cold
+			}
+		}
+		fmt.Printf("  %s, whose seed lives at: %s\n", name, location)
+	}
+	if !tb.Bounds.MaxTime.IsZero() {
+		fmt.Println("It is valid until", tb.Bounds.MaxTime.Format(time.RFC1123), "(a longer --valid-for leaves more time)")
+	}
+	fmt.Println("Sign it where the seed lives, for instance with: alfred sign <xdr>")
+	fmt.Println("Then submit it with: alfred submit <xdr>")
+	fmt.Println()
+	fmt.Println(txeB64)
+	copyValue("transaction", txeB64)
+
+	return nil
+}
+
+// recordTx appends a submitted transaction to the log of req.db.
+// The transaction is already in the ledger, so failing to record it is only reported.
+func recordTx(req txRequest, hash, txeB64 string) {
+	if req.db == nil {
+		return
+	}
+
+	entry, err := newLogEntry(hash, txeB64, currentNetwork())
+	if err == nil {
+		entry.IdempotencyKey = viper.GetString("idempotency-key")
+		req.db.AppendLog(entry)
+		_, err = updateDB(func(m *wallet.Alfred) error {
+			m.AppendLog(entry)
+			return nil
+		})
+	}
+
+	if err != nil {
+		fmt.Println("Warning: unable to record the transaction in the log:", err)
+	}
+}
+
+func newLogEntry(hash, txeB64 string, n stellarNetwork) (wallet.LogEntry, error) {
+	var txe xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(txeB64, &txe); err != nil {
+		return wallet.LogEntry{}, err
+	}
+
+	entry := wallet.LogEntry{
+		Time:     time.Now().UTC(),
+		Network:  n.Name,
+		Hash:     hash,
+		Envelope: txeB64,
+	}
+
+	for _, op := range txe.Tx.Operations {
+		entry.Operations = append(entry.Operations, explain.OperationName(op.Body.Type))
+
+		var dest *xdr.AccountId
+		switch op.Body.Type {
+		case xdr.OperationTypeCreateAccount:
+			dest = &op.Body.CreateAccountOp.Destination
+		case xdr.OperationTypePayment:
+			dest = &op.Body.PaymentOp.Destination
+		case xdr.OperationTypePathPayment:
+			dest = &op.Body.PathPaymentOp.Destination
+		case xdr.OperationTypeAccountMerge:
+			dest = op.Body.Destination
+		}
+
+		if dest != nil {
+			entry.Destinations = appendUnique(entry.Destinations, dest.Address())
+		}
+	}
+
+	return entry, nil
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, e := range list {
+		if e == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -70,7 +70,7 @@ var uploadCmd = &cobra.Command{
 			fatal(err)
 		}
 
-		err = submitData(viper.GetBool("testnet"), viper.GetBool("yes"), src, kvs)
+		err = submitData(m, currentNetwork(), viper.GetBool("yes"), viper.GetDuration("valid-for"), src, kvs)
 		if err != nil {
 			fatal(err)
 		}
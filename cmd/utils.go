@@ -2,14 +2,32 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/celrenheit/alfred/agent"
+	"github.com/celrenheit/alfred/failover"
+	"github.com/celrenheit/alfred/hardware"
+	"github.com/celrenheit/alfred/httplog"
+	"github.com/celrenheit/alfred/i18n"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/celrenheit/alfred/ratelimit"
+	"github.com/celrenheit/alfred/wallet"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stellar/go/clients/horizon"
 )
 
+// passwordAttempts is the number of times the password is prompted before giving up
+const passwordAttempts = 3
+
 type handler func() error
 type middleware func(handler) handler
 
@@ -30,30 +48,242 @@ func checkDB(next handler) handler {
 			return errors.New("db path should be set")
 		}
 
+		// the database is opened locked, with the addresses of the wallets
+		// only, unless it is private
+		private := needsSecret(viper.GetString("db"))
+		if viper.GetBool("read-only") {
+			if private {
+				return errors.New("the database is private, its wallets can not be read without its secret, which is not used with --read-only")
+			}
+			viper.Set("secret", "")
+		} else if private || viper.GetString("secret") != "" {
+			if err := unlock(); err != nil {
+				return err
+			}
+		}
+
 		return next()
 	}
 }
 
 func checkSecret(next handler) handler {
 	return func() error {
-		if viper.GetString("secret") == "" {
-			secret, err := promptPassword()
-			if err != nil {
-				return err
-			}
-			viper.Set("secret", secret)
+		if viper.GetBool("read-only") {
+			return errors.New("this command needs the secret of the database, which is not used with --read-only")
+		}
+
+		if err := unlock(); err != nil {
+			return err
 		}
 
 		return next()
 	}
 }
 
+// unlocked is set once the secret of the database is known, see unlock
+var unlocked bool
+
+// unlock sets the secret of the database, prompted for unless given, and
+// derived from the response of its hardware key if it has one
+func unlock() error {
+	if unlocked {
+		return nil
+	}
+
+	if viper.GetString("secret") == "" {
+		secret, err := unlockSecret(viper.GetString("db"))
+		if err != nil {
+			return err
+		}
+		viper.Set("secret", secret)
+	} else if key := hardwareKey(viper.GetString("db")); key != nil {
+		// --secret is the password, the secret is derived from it
+		response, err := respond(key)
+		if err != nil {
+			return err
+		}
+		viper.Set("secret", hardware.Secret(viper.GetString("secret"), response))
+	}
+
+	unlocked = true
+	return nil
+}
+
+// updateDB applies fn to the database, unlocked with its secret, and writes
+// it, holding the database meanwhile so that the writes of the daemon and of
+// the other commands are not lost, see wallet.Update. fn should not write
+// to the database.
+func updateDB(fn func(m *wallet.Alfred) error) (*wallet.Alfred, error) {
+	var secret []byte
+	if s := viper.GetString("secret"); s != "" {
+		secret = []byte(s)
+	}
+
+	return wallet.Update(viper.GetString("db"), secret, fn)
+}
+
+// updateWallet applies fn to the wallet called name and writes the
+// database, see updateDB
+func updateWallet(name string, fn func(w *wallet.Wallet) error) error {
+	_, err := updateDB(func(m *wallet.Alfred) error {
+		w := m.WalletByName(name)
+		if w == nil {
+			return i18n.Errorf("wallet '%s' not found%s", name, suggestName(name, accountNames(m, false)))
+		}
+		return fn(w)
+	})
+	return err
+}
+
+// needsSecret reports whether the database at path can only be read with
+// its secret, see wallet.NeedsSecret
+func needsSecret(path string) bool {
+	s, err := wallet.OpenStore(path)
+	if err != nil {
+		return false
+	}
+	needs, err := wallet.NeedsSecret(s)
+	return err == nil && needs
+}
+
+// unlockSecret returns the secret of the database at path, either from a
+// running agent or by prompting for it until it decrypts the database.
+// A prompted secret is cached by the agent, if one is running. The secret of a
+// database with a hardware key is derived from the password prompted and the
+// response of the key.
+func unlockSecret(path string) (string, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	client, err := agent.Dial(viper.GetString("agent"))
+	if _, ok := err.(*agent.UnsafeSocketError); ok {
+		logger.Warnf("the agent is not used: %v", err)
+	}
+	if err == nil {
+		defer client.Close()
+
+		secret, err := client.Get(path)
+		if err == nil && secret != "" {
+			if _, err := wallet.OpenSecretString(path, secret); err == nil {
+				return secret, nil
+			}
+		}
+	}
+
+	if err := checkInteractive("password", "the database is encrypted", "--secret, or a running agent (see alfred agent)"); err != nil {
+		return "", err
+	}
+
+	key := hardwareKey(path)
+	var response []byte
+	for attempt := 1; ; attempt++ {
+		secret, err := promptPassword()
+		if err != nil {
+			return "", err
+		}
+		if key != nil {
+			if response == nil {
+				if response, err = respond(key); err != nil {
+					return "", err
+				}
+			}
+			secret = hardware.Secret(secret, response)
+		}
+
+		_, err = wallet.OpenSecretString(path, secret)
+		if err == nil {
+			if client != nil {
+				client.Set(path, secret)
+			}
+			return secret, nil
+		}
+
+		if attempt == passwordAttempts {
+			return "", errors.New("unable to decrypt the database, the password is probably incorrect")
+		}
+		fmt.Printf("Wrong password, %d attempt(s) left\n", passwordAttempts-attempt)
+	}
+}
+
+// cachedSecret returns the secret of the database at path cached by the
+// agent, an empty string if no agent caches it
+func cachedSecret(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	client, err := agent.Dial(viper.GetString("agent"))
+	if err != nil {
+		return ""
+	}
+	defer client.Close()
+
+	secret, err := client.Get(path)
+	if err != nil {
+		return ""
+	}
+	return secret
+}
+
+// hardwareKey returns the hardware key of the database at path, nil if it
+// has none
+func hardwareKey(path string) *hardware.Key {
+	m, err := wallet.OpenSecretString(path, "")
+	if err != nil {
+		return nil
+	}
+	return m.Hardware
+}
+
+// respond returns the response of key, which may have to be touched
+func respond(key *hardware.Key) ([]byte, error) {
+	fmt.Fprintf(os.Stderr, "Waiting for the %s, touch it if it blinks\n", key)
+	response, err := key.Respond()
+	if err != nil {
+		return nil, i18n.Errorf("the hardware key did not respond: %v", err)
+	}
+	return response, nil
+}
+
+// interactive is false when nobody can answer a prompt, such as in the serve
+// command or with --non-interactive, prompting is then an error
+var interactive = true
+
+// promptError is returned instead of prompting when alfred is not
+// interactive. Its message is a single line of key=value pairs, so that
+// scripts can tell what was missing and what to pass instead.
+type promptError struct {
+	// prompt is what would have been prompted, such as wallet
+	prompt string
+	reason string
+	// instead is the flag or argument avoiding the prompt, if any
+	instead string
+}
+
+func (e *promptError) Error() string {
+	msg := fmt.Sprintf("error=prompt_required prompt=%s reason=%q", e.prompt, e.reason)
+	if e.instead != "" {
+		msg += fmt.Sprintf(" instead=%q", e.instead)
+	}
+	return msg
+}
+
+// checkInteractive returns a promptError when alfred is not interactive,
+// prompt is what would be prompted, reason why and instead what avoids it
+func checkInteractive(prompt, reason, instead string) error {
+	if interactive {
+		return nil
+	}
+	return &promptError{prompt: prompt, reason: reason, instead: instead}
+}
+
 func promptPassword() (string, error) {
 	prompt := promptui.Prompt{
-		Label: "Password",
+		Label: i18n.T("Password"),
 		Validate: func(input string) error {
 			if len(input) < 8 {
-				return errors.New("length be greater than 8")
+				return errors.New(i18n.T("length be greater than 8"))
 			}
 			return nil
 		},
@@ -62,16 +292,76 @@ func promptPassword() (string, error) {
 	return prompt.Run()
 }
 
-func getClient(testnet bool) *horizon.Client {
-	client := horizon.DefaultPublicNetClient
-	if testnet {
-		client = horizon.DefaultTestNetClient
+// clients are the horizon clients, by list of urls
+var clients = struct {
+	sync.Mutex
+	byURLs map[string]*horizon.Client
+}{byURLs: make(map[string]*horizon.Client)}
+
+// getClient returns the client of the horizon servers set with --horizon, or
+// the one of network n. The same client is returned for a
+// list of servers so that the accounts loaded from it are cached. Its requests
+// fail over to the next server when one fails, and are canceled with Ctrl-C
+// and after --timeout.
+func getClient(n stellarNetwork) *horizon.Client {
+	var urls []string
+	for _, url := range viper.GetStringSlice("horizon") {
+		if url = strings.TrimRight(strings.TrimSpace(url), "/"); url != "" {
+			urls = append(urls, url)
+		}
 	}
+	if len(urls) == 0 {
+		urls = []string{n.Horizon}
+	}
+
+	clients.Lock()
+	defer clients.Unlock()
 
+	key := strings.Join(urls, ",")
+	client, ok := clients.byURLs[key]
+	if !ok {
+		servers := failover.New(urls...)
+		servers.HTTP = &http.Client{}
+		servers.Timeout = viper.GetDuration("timeout")
+		servers.Logf = logger.Infof
+		if viper.GetBool("debug-http") {
+			servers.HTTP.Transport = &httplog.Transport{Log: logHTTP}
+		}
+
+		limiter := ratelimit.New(servers)
+		limiter.Concurrency = viper.GetInt("max-requests")
+		limiter.Notify = func(wait time.Duration) {
+			logger.Warnf("horizon rate limit reached, waiting %v", wait.Round(time.Second))
+		}
+
+		client = &horizon.Client{URL: servers.URLs[0], HTTP: contextHTTP{servers: servers, limiter: limiter}}
+		clients.byURLs[key] = client
+	}
 	return client
 }
 
+// prefetchAccounts starts loading accounts in the background, so that the
+// following calls to getAccount do not wait for them one after the other
+func prefetchAccounts(client *horizon.Client, accounts ...string) {
+	for _, account := range accounts {
+		go getAccount(client, account)
+	}
+}
+
+// getAccount returns an account and whether it exists, it is loaded once per --cache-ttl
 func getAccount(client *horizon.Client, account string) (horizon.Account, bool, error) {
+	acc, exists, err := cached.get(client, "account", account, new(horizon.Account), func(v interface{}) (bool, error) {
+		acc, exists, err := loadAccount(client, account)
+		*v.(*horizon.Account) = acc
+		return exists, err
+	})
+	if err != nil {
+		return horizon.Account{}, false, err
+	}
+	return *acc.(*horizon.Account), exists, nil
+}
+
+func loadAccount(client *horizon.Client, account string) (horizon.Account, bool, error) {
 	hAccount, err := client.LoadAccount(account)
 	if err != nil {
 		if err, ok := err.(*horizon.Error); ok && err.Response.StatusCode == http.StatusNotFound {
@@ -84,9 +374,41 @@ func getAccount(client *horizon.Client, account string) (horizon.Account, bool,
 }
 
 func friendbotFund(addr string) {
-	friendBotResp, err := http.Get("https://horizon-testnet.stellar.org/friendbot?addr=" + addr)
+	client := getClient(currentNetwork())
+	friendBotResp, err := client.HTTP.Get(client.URL + "/friendbot?addr=" + addr)
 	if err != nil {
 		fatal(err)
 	}
 	defer friendBotResp.Body.Close()
 }
+
+// accountNames returns the names and aliases of the wallets of m, followed by
+// the ones of its contacts if contacts is true
+func accountNames(m *wallet.Alfred, contacts bool) []string {
+	var names, aliases []string
+	for _, w := range m.Stellar.Wallets {
+		names = append(names, w.Name)
+		aliases = append(aliases, w.Aliases...)
+	}
+	names = append(names, aliases...)
+	if !contacts {
+		return names
+	}
+
+	var others []string
+	for name, c := range m.Stellar.Contacts {
+		others = append(others, name)
+		others = append(others, c.Aliases...)
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// suggestName returns the end of an error suggesting the names close to a
+// misspelled name, such as ", did you mean 'master'?", or nothing
+func suggestName(name string, names []string) string {
+	if suggestions := parser.Suggest(name, names); len(suggestions) > 0 {
+		return ", " + parser.DidYouMean(suggestions)
+	}
+	return ""
+}
@@ -0,0 +1,292 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/celrenheit/alfred/webhook"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// tradePollInterval is the delay between two checks of the trades of a watched wallet
+const tradePollInterval = 15 * time.Second
+
+// addWebhookFlags adds the flags configuring the webhooks of cmd
+func addWebhookFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("webhook", nil, "URL notified of the events, can be repeated (default is the webhooks setting)")
+	cmd.Flags().String("webhook-secret", "", "secret signing the notifications (default is the webhook-secret setting)")
+}
+
+// getNotifier returns the notifier of the webhooks given to cmd or set in the
+// configuration, nil when there are none
+func getNotifier(cmd *cobra.Command) *webhook.Notifier {
+	urls, _ := cmd.Flags().GetStringArray("webhook")
+	if len(urls) == 0 {
+		urls = viper.GetStringSlice("webhooks")
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	for _, u := range urls {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			fatalf("invalid webhook '%s', it should be an http or https URL", u)
+		}
+	}
+
+	secret, _ := cmd.Flags().GetString("webhook-secret")
+	if secret == "" {
+		secret = viper.GetString("webhook-secret")
+	}
+
+	return &webhook.Notifier{
+		URLs:    urls,
+		Secret:  []byte(secret),
+		Retries: 5,
+		Backoff: time.Second,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// notify sends an event about account, errors are only printed
+func notify(n *webhook.Notifier, m *wallet.Alfred, account, typ string, data interface{}) {
+	e := webhook.NewEvent(typ, data)
+	e.Network = currentNetwork().Name
+	e.Account = account
+	if w := m.WalletByAddress(account); w != nil {
+		e.Wallet = w.Name
+	}
+
+	if err := n.Notify(e); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// incomingPayments returns the payments of tx received by account from another account
+func incomingPayments(m *wallet.Alfred, tx horizon.Transaction, account string) ([]webhook.Payment, error) {
+	var env xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(tx.EnvelopeXdr, &env); err != nil {
+		return nil, err
+	}
+
+	var payments []webhook.Payment
+	for _, op := range env.Tx.Operations {
+		source := env.Tx.SourceAccount
+		if op.SourceAccount != nil {
+			source = *op.SourceAccount
+		}
+		if source.Address() == account {
+			continue
+		}
+
+		var dest string
+		var amnt xdr.Int64
+		asset := xdr.Asset{Type: xdr.AssetTypeAssetTypeNative}
+		body := op.Body
+		switch body.Type {
+		case xdr.OperationTypeCreateAccount:
+			dest, amnt = body.CreateAccountOp.Destination.Address(), body.CreateAccountOp.StartingBalance
+		case xdr.OperationTypePayment:
+			dest, amnt, asset = body.PaymentOp.Destination.Address(), body.PaymentOp.Amount, body.PaymentOp.Asset
+		case xdr.OperationTypePathPayment:
+			dest, amnt, asset = body.PathPaymentOp.Destination.Address(), body.PathPaymentOp.DestAmount, body.PathPaymentOp.DestAsset
+		default:
+			continue
+		}
+		if dest != account {
+			continue
+		}
+
+		var typ xdr.AssetType
+		var code, issuer string
+		asset.Extract(&typ, &code, &issuer)
+		if typ == xdr.AssetTypeAssetTypeNative {
+			code = "XLM"
+		}
+
+		payments = append(payments, webhook.Payment{
+			Transaction: tx.Hash,
+			From:        accountName(m, source.Address()),
+			Amount:      amount.String(amnt),
+			Asset:       code,
+			Issuer:      issuer,
+			Memo:        tx.Memo,
+		})
+	}
+
+	return payments, nil
+}
+
+// trade is a trade as returned by horizon, the base account sold the base
+// asset to the counter account
+type trade struct {
+	ID             string `json:"id"`
+	PagingToken    string `json:"paging_token"`
+	BaseAccount    string `json:"base_account"`
+	BaseAmount     string `json:"base_amount"`
+	BaseAssetType  string `json:"base_asset_type"`
+	BaseAssetCode  string `json:"base_asset_code"`
+	CounterAccount string `json:"counter_account"`
+	CounterAmount  string `json:"counter_amount"`
+	CounterType    string `json:"counter_asset_type"`
+	CounterCode    string `json:"counter_asset_code"`
+}
+
+// event returns the trade from the point of view of account
+func (t trade) event(m *wallet.Alfred, account string) webhook.Trade {
+	base, counter := t.BaseAssetCode, t.CounterCode
+	if t.BaseAssetType == "native" {
+		base = "XLM"
+	}
+	if t.CounterType == "native" {
+		counter = "XLM"
+	}
+
+	e := webhook.Trade{
+		ID:           t.ID,
+		Counterparty: accountName(m, t.CounterAccount),
+		SoldAmount:   t.BaseAmount,
+		SoldAsset:    base,
+		BoughtAmount: t.CounterAmount,
+		BoughtAsset:  counter,
+	}
+	if t.CounterAccount == account {
+		e.Counterparty = accountName(m, t.BaseAccount)
+		e.SoldAmount, e.BoughtAmount = e.BoughtAmount, e.SoldAmount
+		e.SoldAsset, e.BoughtAsset = e.BoughtAsset, e.SoldAsset
+	}
+
+	return e
+}
+
+// loadTrades loads the trades of account after cursor, oldest first. With an
+// empty cursor, only the latest trade is returned.
+func loadTrades(client *horizon.Client, account, cursor string) ([]trade, error) {
+	query := url.Values{}
+	if cursor == "" {
+		query.Set("order", "desc")
+		query.Set("limit", "1")
+	} else {
+		query.Set("order", "asc")
+		query.Set("cursor", cursor)
+		query.Set("limit", "200")
+	}
+
+	endpoint := fmt.Sprintf("%s/accounts/%s/trades?%s", strings.TrimRight(client.URL, "/"), account, query.Encode())
+	resp, err := client.HTTP.Get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		herr := &horizon.Error{Response: resp}
+		if err := json.NewDecoder(resp.Body).Decode(&herr.Problem); err != nil {
+			return nil, err
+		}
+		return nil, herr
+	}
+
+	var page struct {
+		Embedded struct {
+			Records []trade `json:"records"`
+		} `json:"_embedded"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+
+	return page.Embedded.Records, nil
+}
+
+// watchTrades notifies the trades of account as they are found, it never returns
+func watchTrades(n *webhook.Notifier, m *wallet.Alfred, client *horizon.Client, account string) {
+	// the trades made before watching are not notified
+	var cursor string
+	for {
+		trades, err := loadTrades(client, account, cursor)
+		switch {
+		case err != nil:
+			fmt.Fprintln(os.Stderr, "trades:", describeHorizonError(err))
+		case cursor == "" && len(trades) == 0:
+			cursor = "0"
+		case cursor == "":
+			cursor = trades[0].PagingToken
+		default:
+			for _, t := range trades {
+				notify(n, m, account, webhook.TradeFilled, t.event(m, account))
+				cursor = t.PagingToken
+			}
+		}
+
+		time.Sleep(tradePollInterval)
+	}
+}
+
+// lowBalance notifies once when the XLM balance of an account goes below a
+// threshold, and again only after it went back above it
+type lowBalance struct {
+	mu        sync.Mutex
+	threshold xdr.Int64
+	below     bool
+}
+
+func (l *lowBalance) check(n *webhook.Notifier, m *wallet.Alfred, client *horizon.Client, account string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cached.reset()
+	acc, exists, err := getAccount(client, account)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "balance:", describeHorizonError(err))
+		return
+	}
+
+	balance := xdr.Int64(0)
+	if exists {
+		b, err := amount.Parse(acc.GetNativeBalance())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "balance:", err)
+			return
+		}
+		balance = b
+	}
+
+	if balance >= l.threshold {
+		l.below = false
+		return
+	}
+
+	if !l.below {
+		l.below = true
+		notify(n, m, account, webhook.LowBalance, webhook.Balance{
+			Balance:   amount.String(balance),
+			Threshold: amount.String(l.threshold),
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Package decimal computes the amounts of the network exactly, as integers
+// of stroops (the 7 decimals of the network), and its prices as fractions.
+// The results of multiplications and divisions are rounded explicitly, so
+// that an amount is never rounded silently.
+package decimal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Decimals is the precision of the amounts of the network
+const Decimals = 7
+
+// One is the amount of one unit of an asset, in stroops
+const One Amount = 10000000
+
+// Amount is an amount of an asset, in stroops
+type Amount int64
+
+// Rounding is the way a result with more than 7 decimals is rounded
+type Rounding int
+
+const (
+	// Exact fails when the result has more than 7 decimals
+	Exact Rounding = iota
+	// Down rounds towards zero
+	Down
+	// Up rounds away from zero
+	Up
+	// Nearest rounds to the nearest stroop, half away from zero
+	Nearest
+)
+
+var (
+	// ErrPrecision is returned when an amount has more than 7 decimals
+	ErrPrecision = errors.New("more than 7 decimals")
+	// ErrRange is returned when an amount does not fit in the 64 bits of the network
+	ErrRange = errors.New("out of the range of the amounts of the network")
+)
+
+var (
+	one      = big.NewRat(int64(One), 1)
+	maxInt64 = new(big.Int).SetInt64(math.MaxInt64)
+)
+
+// Parse parses an amount such as 12.5, which should have at most 7 decimals
+func Parse(s string) (Amount, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || strings.ContainsAny(s, "/eE") {
+		return 0, fmt.Errorf("invalid amount '%s'", s)
+	}
+
+	a, err := FromRat(r, Exact)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount '%s': %v", s, err)
+	}
+	return a, nil
+}
+
+// FromRat returns the amount of r, rounded with mode
+func FromRat(r *big.Rat, mode Rounding) (Amount, error) {
+	scaled := new(big.Rat).Mul(r, one)
+	num, denom := scaled.Num(), scaled.Denom()
+
+	q, m := new(big.Int).QuoRem(num, denom, new(big.Int))
+	if m.Sign() != 0 {
+		switch mode {
+		case Exact:
+			return 0, ErrPrecision
+		case Up:
+			q.Add(q, big.NewInt(int64(num.Sign())))
+		case Nearest:
+			// |2m| >= denom rounds away from zero
+			if new(big.Int).Abs(new(big.Int).Lsh(m, 1)).Cmp(denom) >= 0 {
+				q.Add(q, big.NewInt(int64(num.Sign())))
+			}
+		}
+	}
+
+	if new(big.Int).Abs(q).Cmp(maxInt64) > 0 {
+		return 0, ErrRange
+	}
+	return Amount(q.Int64()), nil
+}
+
+// Rat returns a as a fraction of units
+func (a Amount) Rat() *big.Rat {
+	return big.NewRat(int64(a), int64(One))
+}
+
+// Mul returns a multiplied by r, rounded with mode
+func (a Amount) Mul(r *big.Rat, mode Rounding) (Amount, error) {
+	return FromRat(new(big.Rat).Mul(a.Rat(), r), mode)
+}
+
+// Quo returns a divided by r, rounded with mode
+func (a Amount) Quo(r *big.Rat, mode Rounding) (Amount, error) {
+	if r.Sign() == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return FromRat(new(big.Rat).Quo(a.Rat(), r), mode)
+}
+
+// String formats a with its 7 decimals, such as 12.5000000
+func (a Amount) String() string {
+	return a.Rat().FloatString(Decimals)
+}
+
+// ParsePrice parses a positive price, such as 0.25 or 1/4
+func ParsePrice(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid price '%s'", s)
+	}
+	return r, nil
+}
+
+// FormatPrice formats a price with 7 decimals, such as 0.2500000
+func FormatPrice(r *big.Rat) string {
+	return r.FloatString(Decimals)
+}
@@ -0,0 +1,131 @@
+package decimal
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		input   string
+		want    Amount
+		wantErr bool
+	}{
+		{"1", One, false},
+		{"12.5", 125000000, false},
+		{"0.0000001", 1, false},
+		{"922337203685.4775807", Amount(1<<63 - 1), false},
+		{"922337203685.4775808", 0, true},
+		{"0.00000001", 0, true},
+		{"1e3", 0, true},
+		{"1/3", 0, true},
+		{"abc", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			a, err := Parse(test.input)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.want, a)
+		})
+	}
+}
+
+func TestRounding(t *testing.T) {
+	third := big.NewRat(1, 3)
+
+	a, err := One.Mul(third, Down)
+	require.NoError(t, err)
+	require.Equal(t, "0.3333333", a.String())
+
+	a, err = One.Mul(third, Up)
+	require.NoError(t, err)
+	require.Equal(t, "0.3333334", a.String())
+
+	a, err = One.Quo(big.NewRat(3, 2), Nearest)
+	require.NoError(t, err)
+	require.Equal(t, "0.6666667", a.String())
+
+	_, err = One.Mul(third, Exact)
+	require.Equal(t, ErrPrecision, err)
+
+	_, err = Amount(1<<62).Mul(big.NewRat(4, 1), Down)
+	require.Equal(t, ErrRange, err)
+
+	// large amounts keep their last stroop, unlike float64
+	a, err = Parse("900000000000.0000001")
+	require.NoError(t, err)
+	a, err = a.Mul(big.NewRat(1, 1), Exact)
+	require.NoError(t, err)
+	require.Equal(t, "900000000000.0000001", a.String())
+}
+
+func config() *quick.Config {
+	return &quick.Config{
+		MaxCount: 2000,
+		Rand:     rand.New(rand.NewSource(1)),
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	f := func(a int64) bool {
+		b, err := Parse(Amount(a).String())
+		return err == nil && b == Amount(a)
+	}
+	require.NoError(t, quick.Check(f, config()))
+}
+
+func TestRoundingBounds(t *testing.T) {
+	// the rounded result is within a stroop of the exact one, on the side asked
+	f := func(a uint32, n, d uint16) bool {
+		if n == 0 || d == 0 {
+			return true
+		}
+		amount, r := Amount(a), big.NewRat(int64(n), int64(d))
+		exact := new(big.Rat).Mul(amount.Rat(), r)
+
+		down, err := amount.Mul(r, Down)
+		if err != nil || down.Rat().Cmp(exact) > 0 {
+			return false
+		}
+		up, err := amount.Mul(r, Up)
+		if err != nil || up.Rat().Cmp(exact) < 0 || up-down > 1 {
+			return false
+		}
+		nearest, err := amount.Mul(r, Nearest)
+		if err != nil || (nearest != down && nearest != up) {
+			return false
+		}
+
+		diff := new(big.Rat).Sub(exact, nearest.Rat())
+		return diff.Abs(diff).Cmp(big.NewRat(1, 2*int64(One))) <= 0
+	}
+	require.NoError(t, quick.Check(f, config()))
+}
+
+func TestQuoInverse(t *testing.T) {
+	// dividing what was multiplied, rounded down, never gives more than the start
+	f := func(a uint32, n, d uint16) bool {
+		if n == 0 || d == 0 {
+			return true
+		}
+		amount, r := Amount(a), big.NewRat(int64(n), int64(d))
+
+		product, err := amount.Mul(r, Down)
+		if err != nil {
+			return false
+		}
+		back, err := product.Quo(r, Down)
+		return err == nil && back <= amount
+	}
+	require.NoError(t, quick.Check(f, config()))
+}
@@ -0,0 +1,69 @@
+package decimal
+
+import (
+	"strings"
+)
+
+// Format is the way amounts are shown to the user: rounded to the display
+// precision of their asset, without trailing zeros and with the separators of
+// a language, such as 1,234.5 in English and 1 234,5 in French
+type Format struct {
+	// Point separates the decimals, "." when empty
+	Point string
+	// Thousands groups the digits of the units by three, none when empty
+	Thousands string
+	// Precision is the number of decimals shown by asset code, all the 7
+	// decimals of the network for the ones missing
+	Precision map[string]int
+}
+
+// Amount formats a, an amount of the asset with code. An amount rounded to
+// zero by the precision of its asset keeps all its decimals, so that a
+// balance is never shown empty when it is not.
+func (f Format) Amount(a Amount, code string) string {
+	decimals := Decimals
+	if p, ok := f.Precision[code]; ok && p >= 0 && p < Decimals {
+		decimals = p
+	}
+	if rounded := a.Rat().FloatString(decimals); decimals < Decimals && a != 0 && strings.Trim(rounded, "-0.") == "" {
+		decimals = Decimals
+	}
+
+	s := a.Rat().FloatString(decimals)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	units, decs := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		units, decs = s[:i], strings.TrimRight(s[i+1:], "0")
+	}
+	if f.Thousands != "" {
+		var grouped []string
+		for len(units) > 3 {
+			grouped = append([]string{units[len(units)-3:]}, grouped...)
+			units = units[:len(units)-3]
+		}
+		units = strings.Join(append([]string{units}, grouped...), f.Thousands)
+	}
+	if decs == "" {
+		return sign + units
+	}
+
+	point := f.Point
+	if point == "" {
+		point = "."
+	}
+	return sign + units + point + decs
+}
+
+// String formats s, an amount of the asset with code such as the 12.5000000
+// of horizon, and returns it as is when it is not an amount
+func (f Format) String(s, code string) string {
+	a, err := Parse(s)
+	if err != nil {
+		return s
+	}
+	return f.Amount(a, code)
+}
@@ -0,0 +1,37 @@
+package decimal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	en := Format{Thousands: ",", Precision: map[string]int{"USD": 2}}
+	fr := Format{Point: ",", Thousands: " "}
+
+	var tests = []struct {
+		f      Format
+		amount string
+		code   string
+		want   string
+	}{
+		{Format{}, "12.5000000", "XLM", "12.5"},
+		{Format{}, "1234567.0000000", "XLM", "1234567"},
+		{en, "1234567.0000000", "XLM", "1,234,567"},
+		{en, "123.0000001", "XLM", "123.0000001"},
+		{en, "-1234.5", "XLM", "-1,234.5"},
+		{en, "1234.5678", "USD", "1,234.57"},
+		{en, "1234.995", "USD", "1,235"},
+		{en, "-0.005", "USD", "-0.01"},
+		// not shown as zero
+		{en, "0.0012", "USD", "0.0012"},
+		{en, "0", "USD", "0"},
+		{fr, "1234567.25", "XLM", "1 234 567,25"},
+		{fr, "max", "XLM", "max"},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.want, test.f.String(test.amount, test.code), test.amount)
+	}
+}
@@ -0,0 +1,87 @@
+// Package desktop shows notifications with the tools of the platform:
+// notify-send on Linux and BSD, Notification Center on macOS and toasts on
+// Windows.
+package desktop
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// run executes a command, replaced in tests
+var run = func(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
+
+// Notify shows a notification with title and body
+func Notify(title, body string) error {
+	name, args, err := command(runtime.GOOS, title, body)
+	if err != nil {
+		return err
+	}
+	return run(name, args...)
+}
+
+// Check returns an error if notifications can not be shown on this platform
+func Check() error {
+	name, _, err := command(runtime.GOOS, "", "")
+	if err != nil {
+		return err
+	}
+
+	if _, err := exec.LookPath(name); err != nil {
+		if name == "notify-send" {
+			return fmt.Errorf("notify-send not found, it is usually part of the libnotify package")
+		}
+		return err
+	}
+	return nil
+}
+
+// command returns the command showing a notification on goos
+func command(goos, title, body string) (string, []string, error) {
+	switch goos {
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		return "notify-send", []string{"--app-name=alfred", title, body}, nil
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		return "osascript", []string{"-e", script}, nil
+	case "windows":
+		return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", toastScript(title, body)}, nil
+	}
+
+	return "", nil, fmt.Errorf("notifications are not supported on %s", goos)
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// toastScript returns a PowerShell script showing a toast with title and body
+func toastScript(title, body string) string {
+	var text bytes.Buffer
+	text.WriteString(`<toast><visual><binding template="ToastGeneric"><text>`)
+	xml.EscapeText(&text, []byte(title))
+	text.WriteString(`</text><text>`)
+	xml.EscapeText(&text, []byte(body))
+	text.WriteString(`</text></binding></visual></toast>`)
+
+	// the escaped text has no single quote ending the PowerShell string
+	template := text.String()
+
+	return `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml('` + template + `')
+$toast = New-Object Windows.UI.Notifications.ToastNotification $xml
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('alfred').Show($toast)`
+}
@@ -0,0 +1,48 @@
+package desktop
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand(t *testing.T) {
+	name, args, err := command("linux", "Payment received", "10 XLM from jennifer")
+	require.NoError(t, err)
+	require.Equal(t, "notify-send", name)
+	require.Equal(t, []string{"--app-name=alfred", "Payment received", "10 XLM from jennifer"}, args)
+
+	name, args, err = command("darwin", "Payment received", `memo "rent" \ june`)
+	require.NoError(t, err)
+	require.Equal(t, "osascript", name)
+	require.Equal(t, []string{"-e", `display notification "memo \"rent\" \\ june" with title "Payment received"`}, args)
+
+	name, args, err = command("windows", "Payment received", "it's <10> XLM & more")
+	require.NoError(t, err)
+	require.Equal(t, "powershell", name)
+	script := args[len(args)-1]
+	require.Contains(t, script, `$xml.LoadXml('<toast><visual><binding template="ToastGeneric"><text>Payment received</text><text>it&#39;s &lt;10&gt; XLM &amp; more</text>`)
+	require.Equal(t, 1, strings.Count(script, "LoadXml("))
+
+	_, _, err = command("plan9", "title", "body")
+	require.EqualError(t, err, "notifications are not supported on plan9")
+}
+
+func TestNotify(t *testing.T) {
+	var ran []string
+	run = func(name string, args ...string) error {
+		ran = append([]string{name}, args...)
+		return nil
+	}
+
+	name, args, err := command(runtime.GOOS, "title", "body")
+	if err != nil {
+		require.Equal(t, err, Notify("title", "body"))
+		return
+	}
+
+	require.NoError(t, Notify("title", "body"))
+	require.Equal(t, append([]string{name}, args...), ran)
+}
@@ -0,0 +1,304 @@
+// Package explain turns the result codes returned by horizon into human
+// readable sentences with a suggested fix.
+package explain
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// BaseReserve is the amount of XLM locked for each entry of an account
+const BaseReserve = 0.5
+
+// Explanation describes a result code
+type Explanation struct {
+	Code    string
+	Message string
+	Fix     string
+}
+
+// AccountLoader loads the state of an account, it is implemented by *horizon.Client
+type AccountLoader interface {
+	LoadAccount(accountID string) (horizon.Account, error)
+}
+
+var transactionCodes = map[string]Explanation{
+	"tx_success":              {Message: "the transaction succeeded"},
+	"tx_failed":               {Message: "one of the operations failed", Fix: "see the operations below"},
+	"tx_too_early":            {Message: "the transaction is not valid yet", Fix: "submit it again after the start of its time bounds"},
+	"tx_too_late":             {Message: "the transaction expired before reaching the ledger", Fix: "try again with a longer --valid-for"},
+	"tx_missing_operation":    {Message: "the transaction has no operation", Fix: "add at least one operation"},
+	"tx_bad_seq":              {Message: "the sequence number is not the next one of the source account, another transaction was probably submitted in the meantime", Fix: "try again, the sequence number will be refreshed"},
+	"tx_bad_auth":             {Message: "the signatures are not enough to authorize the transaction, or the network is wrong", Fix: "check that every required signer signed it and that --testnet matches the account's network"},
+	"tx_insufficient_balance": {Message: "the fee would bring the source account below its minimum reserve", Fix: "send some XLM to the source account first"},
+	"tx_no_source_account":    {Message: "the source account does not exist", Fix: "fund the source account first, with alfred fund on testnet"},
+	"tx_insufficient_fee":     {Message: "the fee is lower than the network minimum", Fix: "try again later or with a higher fee, such as --fee auto"},
+	"tx_bad_auth_extra":       {Message: "the transaction has signatures that are not needed", Fix: "remove the extra signatures"},
+	"tx_internal_error":       {Message: "horizon hit an internal error", Fix: "try again later"},
+}
+
+// operationCodes are the explanations shared by every operation type
+var operationCodes = map[string]Explanation{
+	"op_inner":                  {Message: "the operation failed"},
+	"op_success":                {Message: "the operation succeeded"},
+	"op_bad_auth":               {Message: "the signatures are not enough to authorize the operation", Fix: "have the signers of the operation's source account sign the transaction"},
+	"op_no_source_account":      {Message: "the source account of the operation does not exist", Fix: "fund the source account of the operation first"},
+	"op_not_supported":          {Message: "the operation is not supported by the network", Fix: "use another operation"},
+	"op_too_many_subentries":    {Message: "the account has too many trustlines, offers, signers and data entries", Fix: "remove unused trustlines, offers or data entries"},
+	"op_exceeded_work_limit":    {Message: "the operation needed too much work to be processed", Fix: "split it into smaller operations"},
+	"op_malformed":              {Message: "the operation is invalid", Fix: "check the amounts, assets and addresses"},
+	"op_underfunded":            {Message: "the source account does not have enough funds", Fix: "send a smaller amount or fund the account first"},
+	"op_low_reserve":            {Message: "the account would go below its minimum reserve", Fix: "send some XLM to the account first"},
+	"op_src_not_authorized":     {Message: "the source account is not authorized by the issuer to send this asset", Fix: "ask the issuer to authorize the account"},
+	"op_src_no_trust":           {Message: "the source account does not trust this asset", Fix: "add a trustline with alfred please trust <asset> on <wallet>"},
+	"op_no_destination":         {Message: "the destination account does not exist", Fix: "create it by sending at least 1 XLM to it"},
+	"op_no_trust":               {Message: "the destination account does not trust this asset", Fix: "ask the recipient to add a trustline to the asset"},
+	"op_not_authorized":         {Message: "the destination account is not authorized by the issuer to hold this asset", Fix: "ask the recipient to get authorized by the issuer"},
+	"op_line_full":              {Message: "the destination would exceed the limit of its trustline", Fix: "send a smaller amount or ask the recipient to raise the limit with alfred please trust <asset> up to <limit>"},
+	"op_no_issuer":              {Message: "the issuer of the asset does not exist", Fix: "check the issuer's address"},
+	"op_too_few_offers":         {Message: "there is no path with enough offers to convert the assets", Fix: "send a smaller amount or another asset"},
+	"op_offer_cross_self":       {Message: "the path would cross an offer of the source account", Fix: "cancel your offers on these assets first"},
+	"op_over_source_max":        {Message: "the conversion would cost more than the maximum allowed", Fix: "raise the maximum amount to send"},
+	"op_under_dest_min":         {Message: "the conversion would deliver less than the minimum allowed", Fix: "lower the minimum amount to receive"},
+	"op_already_exists":         {Message: "the account already exists", Fix: "send a payment instead"},
+	"op_sell_no_trust":          {Message: "the account does not trust the asset it sells", Fix: "add a trustline to the asset sold"},
+	"op_buy_no_trust":           {Message: "the account does not trust the asset it buys", Fix: "add a trustline to the asset bought"},
+	"op_sell_not_authorized":    {Message: "the account is not authorized to sell this asset", Fix: "ask the issuer to authorize the account"},
+	"op_buy_not_authorized":     {Message: "the account is not authorized to buy this asset", Fix: "ask the issuer to authorize the account"},
+	"op_cross_self":             {Message: "the offer would cross another offer of the same account", Fix: "cancel the opposite offer first"},
+	"op_sell_no_issuer":         {Message: "the issuer of the asset sold does not exist", Fix: "check the issuer's address"},
+	"op_buy_no_issuer":          {Message: "the issuer of the asset bought does not exist", Fix: "check the issuer's address"},
+	"op_not_found":              {Message: "the offer to update does not exist", Fix: "check the offer id"},
+	"op_too_many_signers":       {Message: "the account already has the maximum of 20 signers", Fix: "remove a signer first"},
+	"op_bad_flags":              {Message: "the flags to set and to clear conflict", Fix: "set and clear different flags"},
+	"op_invalid_inflation":      {Message: "the inflation destination does not exist", Fix: "choose an existing account"},
+	"op_cant_change":            {Message: "the flags cannot be changed because the account is immutable", Fix: "nothing, the account cannot be changed anymore"},
+	"op_unknown_flag":           {Message: "an unknown flag was set", Fix: "only use the auth required, revocable and immutable flags"},
+	"op_threshold_out_of_range": {Message: "a weight or threshold is above 255", Fix: "use values between 0 and 255"},
+	"op_bad_signer":             {Message: "the account cannot be its own additional signer", Fix: "change the master weight instead"},
+	"op_invalid_home_domain":    {Message: "the home domain is invalid", Fix: "use a domain name of at most 32 characters"},
+	"op_invalid_limit":          {Message: "the limit is below the current balance", Fix: "send the balance away before lowering the limit"},
+	"op_self_not_allowed":       {Message: "an issuer cannot trust its own asset", Fix: "use another account"},
+	"op_no_trust_line":          {Message: "the trustor does not trust this asset", Fix: "ask the trustor to add a trustline first"},
+	"op_trust_not_required":     {Message: "the issuer does not require authorization", Fix: "set the auth required flag on the issuer first"},
+	"op_cant_revoke":            {Message: "the issuer cannot revoke authorization", Fix: "set the auth revocable flag on the issuer first"},
+	"op_no_account":             {Message: "the destination account does not exist", Fix: "check the destination address"},
+	"op_immutable_set":          {Message: "the account is immutable and cannot be merged", Fix: "nothing, the account cannot be merged"},
+	"op_has_sub_entries":        {Message: "the account still has trustlines, offers, signers or data entries", Fix: "remove them before merging the account"},
+	"op_not_time":               {Message: "inflation cannot run yet", Fix: "try again next week"},
+	"op_not_supported_yet":      {Message: "data entries are not supported yet", Fix: "try again later"},
+	"op_name_not_found":         {Message: "the data entry to remove does not exist", Fix: "check its name"},
+	"op_invalid_name":           {Message: "the data entry name is invalid", Fix: "use a name of at most 64 characters"},
+	"op_bad_seq":                {Message: "the sequence number cannot be bumped to this value", Fix: "use a sequence number above the current one"},
+}
+
+// operationTypeCodes are explanations specific to an operation type
+var operationTypeCodes = map[xdr.OperationType]map[string]Explanation{
+	xdr.OperationTypeCreateAccount: {
+		"op_malformed":   {Message: "the starting balance is invalid or the destination is the source", Fix: "use a positive starting balance and another destination"},
+		"op_underfunded": {Message: "the source account cannot afford the starting balance", Fix: "use a smaller starting balance or fund the account first"},
+		"op_low_reserve": {Message: "the starting balance is below the minimum reserve of 1 XLM", Fix: "use a starting balance of at least 1 XLM"},
+	},
+	xdr.OperationTypeManageOffer: {
+		"op_underfunded": {Message: "the account does not hold enough of the asset sold", Fix: "sell a smaller amount"},
+		"op_low_reserve": {Message: "the account cannot afford the reserve of another offer", Fix: "send some XLM to the account first"},
+	},
+	xdr.OperationTypeCreatePassiveOffer: {
+		"op_underfunded": {Message: "the account does not hold enough of the asset sold", Fix: "sell a smaller amount"},
+		"op_low_reserve": {Message: "the account cannot afford the reserve of another offer", Fix: "send some XLM to the account first"},
+	},
+	xdr.OperationTypeChangeTrust: {
+		"op_malformed":   {Message: "the asset is invalid", Fix: "check the code and issuer of the asset"},
+		"op_low_reserve": {Message: "the account cannot afford the reserve of another trustline", Fix: "send some XLM to the account first"},
+	},
+	xdr.OperationTypeAllowTrust: {
+		"op_malformed": {Message: "the asset is invalid or native", Fix: "check the code of the asset"},
+	},
+	xdr.OperationTypeAccountMerge: {
+		"op_malformed": {Message: "an account cannot be merged into itself", Fix: "choose another destination"},
+	},
+	xdr.OperationTypeManageData: {
+		"op_low_reserve": {Message: "the account cannot afford the reserve of another data entry", Fix: "send some XLM to the account first"},
+	},
+	xdr.OperationTypeSetOptions: {
+		"op_low_reserve": {Message: "the account cannot afford the reserve of another signer", Fix: "send some XLM to the account first"},
+	},
+}
+
+// Transaction explains a transaction result code such as tx_bad_seq
+func Transaction(code string) Explanation {
+	e, ok := transactionCodes[code]
+	if !ok {
+		e = Explanation{Message: "unknown transaction error"}
+	}
+	e.Code = code
+
+	return e
+}
+
+// Operation explains the result code of an operation of type t, such as op_underfunded
+func Operation(t xdr.OperationType, code string) Explanation {
+	e, ok := operationTypeCodes[t][code]
+	if !ok {
+		e, ok = operationCodes[code]
+	}
+	if !ok {
+		e = Explanation{Message: "unknown operation error"}
+	}
+	e.Code = code
+
+	return e
+}
+
+// Error returns a description of err, explaining the result codes of a failed
+// transaction with one line per failed operation.
+// accounts is used to look up the balances of the accounts involved, it can be nil.
+func Error(err error, accounts AccountLoader) string {
+	if err == nil {
+		return ""
+	}
+
+	herr, ok := err.(*horizon.Error)
+	if !ok {
+		return err.Error()
+	}
+
+	codes, cerr := herr.ResultCodes()
+	if cerr != nil || codes == nil || codes.TransactionCode == "" {
+		pb := herr.Problem
+		if pb.Detail != "" {
+			return fmt.Sprintf("%s: %s", pb.Title, pb.Detail)
+		}
+		return pb.Title
+	}
+
+	var env *xdr.TransactionEnvelope
+	if e, err := herr.Envelope(); err == nil {
+		env = e
+	}
+
+	var b bytes.Buffer
+	tx := Transaction(codes.TransactionCode)
+	fmt.Fprintf(&b, "%s (%s): %s", herr.Problem.Title, tx.Code, tx.Message)
+	if tx.Fix != "" && len(codes.OperationCodes) == 0 {
+		fmt.Fprintf(&b, "\n  fix: %s", tx.Fix)
+	}
+
+	for i, code := range codes.OperationCodes {
+		if code == "op_success" {
+			continue
+		}
+
+		var op *xdr.Operation
+		if env != nil && i < len(env.Tx.Operations) {
+			op = &env.Tx.Operations[i]
+		}
+
+		e, name := operationExplanation(env, op, code, accounts)
+		fmt.Fprintf(&b, "\n  operation %d (%s): %s: %s", i+1, name, e.Code, e.Message)
+		if e.Fix != "" {
+			fmt.Fprintf(&b, "\n    fix: %s", e.Fix)
+		}
+	}
+
+	return b.String()
+}
+
+func operationExplanation(env *xdr.TransactionEnvelope, op *xdr.Operation, code string, accounts AccountLoader) (Explanation, string) {
+	if op == nil {
+		return Operation(-1, code), "unknown"
+	}
+
+	e := Operation(op.Body.Type, code)
+	if accounts == nil || (code != "op_underfunded" && code != "op_low_reserve") {
+		return e, OperationName(op.Body.Type)
+	}
+
+	source := env.Tx.SourceAccount
+	if op.SourceAccount != nil {
+		source = *op.SourceAccount
+	}
+
+	acc, err := accounts.LoadAccount(source.Address())
+	if err != nil {
+		return e, OperationName(op.Body.Type)
+	}
+
+	if available, asset, ok := availableBalance(acc, op, code); ok {
+		e.Message = fmt.Sprintf("%s, your balance after reserve is only %s %s", e.Message, strconv.FormatFloat(available, 'f', -1, 64), asset)
+	}
+
+	return e, OperationName(op.Body.Type)
+}
+
+// availableBalance returns the balance the operation can spend: the native
+// balance above the minimum reserve, or the balance of the asset sent
+func availableBalance(acc horizon.Account, op *xdr.Operation, code string) (float64, string, bool) {
+	var asset *xdr.Asset
+	if code == "op_underfunded" {
+		switch op.Body.Type {
+		case xdr.OperationTypePayment:
+			asset = &op.Body.PaymentOp.Asset
+		case xdr.OperationTypePathPayment:
+			asset = &op.Body.PathPaymentOp.SendAsset
+		case xdr.OperationTypeManageOffer:
+			asset = &op.Body.ManageOfferOp.Selling
+		case xdr.OperationTypeCreatePassiveOffer:
+			asset = &op.Body.CreatePassiveOfferOp.Selling
+		}
+	}
+
+	if asset != nil {
+		var typ xdr.AssetType
+		var code, issuer string
+		if err := asset.Extract(&typ, &code, &issuer); err != nil {
+			return 0, "", false
+		}
+
+		if typ != xdr.AssetTypeAssetTypeNative {
+			balance, err := strconv.ParseFloat(acc.GetCreditBalance(code, issuer), 64)
+			if err != nil {
+				return 0, "", false
+			}
+			return balance, code, true
+		}
+	}
+
+	native, err := strconv.ParseFloat(acc.GetNativeBalance(), 64)
+	if err != nil {
+		return 0, "", false
+	}
+
+	available := native - MinimumBalance(acc)
+	if available < 0 {
+		available = 0
+	}
+
+	// round to the 7 decimals of stellar amounts
+	available, _ = strconv.ParseFloat(strconv.FormatFloat(available, 'f', 7, 64), 64)
+	return available, "XLM", true
+}
+
+// MinimumBalance returns the amount of XLM acc must hold, given its number of entries
+func MinimumBalance(acc horizon.Account) float64 {
+	return float64(2+acc.SubentryCount) * BaseReserve
+}
+
+// OperationName converts OperationTypeCreateAccount to create_account
+func OperationName(t xdr.OperationType) string {
+	name := strings.TrimPrefix(t.String(), "OperationType")
+	var b bytes.Buffer
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+
+	return strings.ToLower(b.String())
+}
@@ -0,0 +1,101 @@
+package explain
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+type accounts map[string]horizon.Account
+
+func (a accounts) LoadAccount(id string) (horizon.Account, error) {
+	acc, ok := a[id]
+	if !ok {
+		return acc, ErrAccountNotFound
+	}
+	return acc, nil
+}
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		typ     xdr.OperationType
+		code    string
+		message string
+	}{
+		{xdr.OperationTypePayment, "op_underfunded", "the source account does not have enough funds"},
+		{xdr.OperationTypeManageOffer, "op_underfunded", "the account does not hold enough of the asset sold"},
+		{xdr.OperationTypeCreateAccount, "op_low_reserve", "the starting balance is below the minimum reserve of 1 XLM"},
+		{xdr.OperationTypePayment, "op_no_destination", "the destination account does not exist"},
+		{xdr.OperationTypePayment, "op_unheard_of", "unknown operation error"},
+	}
+
+	for _, test := range tests {
+		e := Operation(test.typ, test.code)
+		require.Equal(t, test.code, e.Code)
+		require.Equal(t, test.message, e.Message)
+	}
+
+	require.Equal(t, "tx_bad_seq", Transaction("tx_bad_seq").Code)
+	require.Equal(t, "unknown transaction error", Transaction("tx_unheard_of").Message)
+}
+
+func TestError(t *testing.T) {
+	src, err := keypair.Random()
+	require.NoError(t, err)
+	dest, err := keypair.Random()
+	require.NoError(t, err)
+	tx, err := build.Transaction(
+		build.SourceAccount{AddressOrSeed: src.Address()},
+		build.Sequence{Sequence: 1},
+		build.TestNetwork,
+		build.Payment(build.Destination{AddressOrSeed: dest.Address()}, build.NativeAmount{Amount: "1"}),
+		build.Payment(build.Destination{AddressOrSeed: dest.Address()}, build.NativeAmount{Amount: "10"}),
+	)
+	require.NoError(t, err)
+	txe, err := tx.Sign(src.Seed())
+	require.NoError(t, err)
+	txeB64, err := txe.Base64()
+	require.NoError(t, err)
+
+	envelope, err := json.Marshal(txeB64)
+	require.NoError(t, err)
+	herr := &horizon.Error{Problem: horizon.Problem{
+		Title: "Transaction Failed",
+		Extras: map[string]json.RawMessage{
+			"envelope_xdr": envelope,
+			"result_codes": json.RawMessage(`{"transaction":"tx_failed","operations":["op_success","op_underfunded"]}`),
+		},
+	}}
+
+	var acc horizon.Account
+	acc.SubentryCount = 1
+	acc.Balances = []horizon.Balance{{Balance: "3.3000000", Asset: horizon.Asset{Type: "native"}}}
+
+	msg := Error(herr, accounts{src.Address(): acc})
+	lines := strings.Split(msg, "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, "Transaction Failed (tx_failed): one of the operations failed", lines[0])
+	require.Equal(t, "  operation 2 (payment): op_underfunded: the source account does not have enough funds, your balance after reserve is only 1.8 XLM", lines[1])
+	require.Equal(t, "    fix: send a smaller amount or fund the account first", lines[2])
+
+	msg = Error(herr, nil)
+	require.Contains(t, msg, "operation 2 (payment): op_underfunded: the source account does not have enough funds\n")
+
+	herr.Problem.Extras["result_codes"] = json.RawMessage(`{"transaction":"tx_bad_seq"}`)
+	require.Equal(t, "Transaction Failed (tx_bad_seq): the sequence number is not the next one of the source account, another transaction was probably submitted in the meantime\n  fix: try again, the sequence number will be refreshed", Error(herr, nil))
+
+	require.Equal(t, "boom", Error(errors.New("boom"), nil))
+	require.Equal(t, "", Error(nil, nil))
+}
+
+func TestOperationName(t *testing.T) {
+	require.Equal(t, "create_account", OperationName(xdr.OperationTypeCreateAccount))
+	require.Equal(t, "manage_data", OperationName(xdr.OperationTypeManageData))
+}
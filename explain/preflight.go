@@ -0,0 +1,489 @@
+package explain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// baseReserve is BaseReserve in stroops
+const baseReserve = xdr.Int64(BaseReserve * amount.One)
+
+// thresholds of the operations
+const (
+	thresholdLow = iota
+	thresholdMed
+	thresholdHigh
+)
+
+var thresholdNames = []string{"low", "medium", "high"}
+
+// ErrAccountNotFound can be returned by the AccountLoader of a Preflight for
+// an account which does not exist, as well as the 404 of horizon
+var ErrAccountNotFound = errors.New("account not found")
+
+// Preflight checks a transaction against the accounts it involves before it
+// is submitted, so that what is missing can be fixed at once instead of one
+// result code at a time
+type Preflight struct {
+	Accounts AccountLoader
+	// Signers are the addresses of the keys signing the transaction
+	Signers []string
+	// Name returns the name of an address used in the messages, the
+	// address itself if nil
+	Name func(address string) string
+}
+
+// preflightAccount is the state of an account while the operations are checked
+type preflightAccount struct {
+	acc    horizon.Account
+	exists bool
+	// created is set when a previous operation creates the account
+	created bool
+	// native is the XLM sent and fee the fee paid, in stroops
+	native, fee xdr.Int64
+	// entries is the change of the number of entries, which need a reserve
+	entries int32
+	// sent and received are the credits spent and received, by asset
+	sent, received map[string]xdr.Int64
+	// threshold is the highest threshold of the operations of the account
+	threshold int
+	source    bool
+}
+
+// Check returns what would make env fail, as the explanations of the result
+// codes horizon would return, with a message using the balances involved.
+// It returns no explanation when the transaction can be submitted.
+func (p Preflight) Check(env *xdr.TransactionEnvelope) ([]Explanation, error) {
+	accounts, order, missing, err := p.apply(env)
+	if err != nil || len(order) == 0 {
+		return missing, err
+	}
+
+	for _, id := range order {
+		missing = append(missing, p.balances(accounts[id])...)
+	}
+	for _, id := range order {
+		if e := p.signatures(accounts[id]); e != nil {
+			missing = append(missing, *e)
+		}
+	}
+
+	return missing, nil
+}
+
+// Reserves returns warnings about the minimum balances of the sources of env,
+// base reserve × (2 + entries), once it is applied: the entries it adds raise
+// them, and an account left with less than margin XLM above its own can
+// hardly pay anything else. What would make env fail is left to Check.
+func (p Preflight) Reserves(env *xdr.TransactionEnvelope, margin xdr.Int64) ([]string, error) {
+	accounts, order, _, err := p.apply(env)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	for _, id := range order {
+		a := accounts[id]
+		if !a.source || !a.exists {
+			continue
+		}
+
+		before := xdr.Int64(2+a.acc.SubentryCount) * baseReserve
+		after := xdr.Int64(2+a.acc.SubentryCount+a.entries) * baseReserve
+		if a.entries > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s locks %s XLM more in reserve for the entries it adds, its minimum balance rises from %s XLM to %s XLM",
+				p.name(id), amount.String(after-before), amount.String(before), amount.String(after)))
+		}
+
+		native, _ := amount.Parse(a.acc.GetNativeBalance())
+		left := native - a.native - a.fee - after
+		if left >= 0 && left < margin {
+			warnings = append(warnings, fmt.Sprintf("%s keeps %s XLM, only %s XLM above its minimum balance of %s XLM",
+				p.name(id), amount.String(native-a.native-a.fee), amount.String(left), amount.String(after)))
+		}
+	}
+
+	return warnings, nil
+}
+
+// apply loads the accounts involved in env in order and records the amounts
+// and entries of its operations, returning what makes them fail. No account
+// is returned when the source of env does not exist.
+func (p Preflight) apply(env *xdr.TransactionEnvelope) (map[string]*preflightAccount, []string, []Explanation, error) {
+	var (
+		order    []string
+		accounts = make(map[string]*preflightAccount)
+	)
+	load := func(id string) (*preflightAccount, error) {
+		if a, ok := accounts[id]; ok {
+			return a, nil
+		}
+
+		a := &preflightAccount{sent: make(map[string]xdr.Int64), received: make(map[string]xdr.Int64)}
+		acc, err := p.Accounts.LoadAccount(id)
+		switch {
+		case err == nil:
+			a.acc, a.exists = acc, true
+		case !notFound(err):
+			return nil, err
+		}
+		a.acc.ID = id
+
+		accounts[id] = a
+		order = append(order, id)
+		return a, nil
+	}
+
+	var missing []Explanation
+	src, err := load(env.Tx.SourceAccount.Address())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !src.exists {
+		e := Transaction("tx_no_source_account")
+		e.Message = fmt.Sprintf("%s does not exist", p.name(src.acc.ID))
+		return nil, nil, append(missing, e), nil
+	}
+	src.source = true
+	src.fee = xdr.Int64(env.Tx.Fee)
+
+	for _, op := range env.Tx.Operations {
+		source := src
+		if op.SourceAccount != nil {
+			if source, err = load(op.SourceAccount.Address()); err != nil {
+				return nil, nil, nil, err
+			}
+			if !source.exists && !source.created {
+				e := Operation(op.Body.Type, "op_no_source_account")
+				e.Message = fmt.Sprintf("%s does not exist", p.name(source.acc.ID))
+				missing = append(missing, e)
+				continue
+			}
+		}
+		source.source = true
+		if level := operationThreshold(op); level > source.threshold {
+			source.threshold = level
+		}
+
+		e, err := p.operation(op, source, load)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if e != nil {
+			missing = append(missing, *e)
+		}
+	}
+
+	return accounts, order, missing, nil
+}
+
+// operation records the amounts and entries of op, and checks its destination
+func (p Preflight) operation(op xdr.Operation, source *preflightAccount, load func(string) (*preflightAccount, error)) (*Explanation, error) {
+	body := op.Body
+	switch body.Type {
+	case xdr.OperationTypeCreateAccount:
+		o := body.CreateAccountOp
+		dest, err := load(o.Destination.Address())
+		if err != nil {
+			return nil, err
+		}
+		if dest.exists || dest.created {
+			e := Operation(body.Type, "op_already_exists")
+			e.Message = fmt.Sprintf("%s already exists", p.name(dest.acc.ID))
+			return &e, nil
+		}
+		if o.StartingBalance < 2*baseReserve {
+			e := Operation(body.Type, "op_low_reserve")
+			e.Message = fmt.Sprintf("the starting balance of %s is %s XLM, below the minimum reserve of %s XLM", p.name(dest.acc.ID), amount.String(o.StartingBalance), amount.String(2*baseReserve))
+			return &e, nil
+		}
+		dest.created = true
+		source.native += o.StartingBalance
+	case xdr.OperationTypePayment:
+		o := body.PaymentOp
+		source.spend(o.Asset, o.Amount)
+		return p.receive(body.Type, o.Destination.Address(), o.Asset, o.Amount, load)
+	case xdr.OperationTypePathPayment:
+		o := body.PathPaymentOp
+		source.spend(o.SendAsset, o.SendMax)
+		return p.receive(body.Type, o.Destination.Address(), o.DestAsset, o.DestAmount, load)
+	case xdr.OperationTypeManageOffer:
+		switch o := body.ManageOfferOp; {
+		case o.OfferId == 0 && o.Amount > 0:
+			source.entries++
+		case o.OfferId != 0 && o.Amount == 0:
+			source.entries--
+		}
+	case xdr.OperationTypeCreatePassiveOffer:
+		source.entries++
+	case xdr.OperationTypeChangeTrust:
+		o := body.ChangeTrustOp
+		_, ok := source.line(o.Line)
+		switch {
+		case !ok && o.Limit > 0:
+			source.entries++
+		case ok && o.Limit == 0:
+			source.entries--
+		}
+	case xdr.OperationTypeManageData:
+		o := body.ManageDataOp
+		_, ok := source.acc.Data[string(o.DataName)]
+		switch {
+		case !ok && o.DataValue != nil:
+			source.entries++
+		case ok && o.DataValue == nil:
+			source.entries--
+		}
+	case xdr.OperationTypeSetOptions:
+		o := body.SetOptionsOp
+		if o.Signer == nil {
+			break
+		}
+		ok := source.hasSigner(o.Signer.Key.Address())
+		switch {
+		case !ok && o.Signer.Weight > 0:
+			source.entries++
+		case ok && o.Signer.Weight == 0:
+			source.entries--
+		}
+	}
+
+	return nil, nil
+}
+
+// receive checks that the destination of a payment exists and trusts asset
+func (p Preflight) receive(t xdr.OperationType, id string, asset xdr.Asset, amt xdr.Int64, load func(string) (*preflightAccount, error)) (*Explanation, error) {
+	dest, err := load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dest.exists && !dest.created {
+		e := Operation(t, "op_no_destination")
+		e.Message = fmt.Sprintf("%s does not exist", p.name(id))
+		e.Fix = "create it first, by sending it at least 1 XLM"
+		return &e, nil
+	}
+
+	code, issuer, native := assetCode(asset)
+	if native || issuer == id {
+		return nil, nil
+	}
+
+	if _, ok := dest.line(asset); !ok {
+		e := Operation(t, "op_no_trust")
+		e.Message = fmt.Sprintf("%s does not trust %s", p.name(id), code)
+		e.Fix = fmt.Sprintf("ask the recipient to add a trustline with alfred please trust %s on <wallet>", code)
+		return &e, nil
+	}
+
+	dest.received[asset.String()] += amt
+	return nil, nil
+}
+
+// balances checks that a can afford what it spends and receive what it is sent
+func (p Preflight) balances(a *preflightAccount) []Explanation {
+	var missing []Explanation
+	if a.source && a.exists {
+		native, _ := amount.Parse(a.acc.GetNativeBalance())
+		entries := a.acc.SubentryCount + a.entries
+		reserve := xdr.Int64(2+entries) * baseReserve
+		if needed := a.native + a.fee + reserve; native < needed {
+			var e Explanation
+			switch {
+			case a.native > 0:
+				e = Operation(xdr.OperationTypePayment, "op_underfunded")
+			case a.entries > 0:
+				e = Operation(xdr.OperationTypeChangeTrust, "op_low_reserve")
+			default:
+				e = Transaction("tx_insufficient_balance")
+			}
+			e.Message = fmt.Sprintf("%s holds %s XLM and needs %s XLM: %s XLM sent, a fee of %s XLM and a reserve of %s XLM for %d entries",
+				p.name(a.acc.ID), amount.String(native), amount.String(needed), amount.String(a.native), amount.String(a.fee), amount.String(reserve), entries)
+			e.Fix = fmt.Sprintf("send at least %s XLM to %s first, with alfred fund on testnet", amount.String(needed-native), p.name(a.acc.ID))
+			missing = append(missing, e)
+		}
+	}
+
+	for _, b := range a.acc.Balances {
+		if b.Asset.Type == "native" {
+			continue
+		}
+		key := balanceKey(b)
+		balance, _ := amount.Parse(b.Balance)
+		if sent := a.sent[key]; balance < sent {
+			e := Operation(xdr.OperationTypePayment, "op_underfunded")
+			e.Message = fmt.Sprintf("%s holds %s %s and sends %s", p.name(a.acc.ID), b.Balance, b.Code, amount.String(sent))
+			e.Fix = fmt.Sprintf("send a smaller amount, or get %s more %s first", amount.String(sent-balance), b.Code)
+			missing = append(missing, e)
+		}
+		delete(a.sent, key)
+
+		limit, err := amount.Parse(b.Limit)
+		if err != nil {
+			continue
+		}
+		if received := a.received[key]; received > limit-balance {
+			e := Operation(xdr.OperationTypePayment, "op_line_full")
+			e.Message = fmt.Sprintf("%s trusts %s up to %s and holds %s, receiving %s would exceed the limit", p.name(a.acc.ID), b.Code, b.Limit, b.Balance, amount.String(received))
+			e.Fix = fmt.Sprintf("send at most %s, or ask the recipient to raise the limit with alfred please trust %s up to <limit>", amount.String(limit-balance), b.Code)
+			missing = append(missing, e)
+		}
+	}
+
+	// the credits left are sent without a trustline
+	var keys []string
+	for key := range a.sent {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		code := strings.Split(key, "/")[1]
+		e := Operation(xdr.OperationTypePayment, "op_src_no_trust")
+		e.Message = fmt.Sprintf("%s does not trust %s, so it holds none", p.name(a.acc.ID), code)
+		missing = append(missing, e)
+	}
+
+	return missing
+}
+
+// signatures checks that the signers meet the highest threshold of the
+// operations of a
+func (p Preflight) signatures(a *preflightAccount) *Explanation {
+	if !a.source || !a.exists {
+		return nil
+	}
+
+	var (
+		weight int32
+		others []string
+	)
+	for _, s := range a.acc.Signers {
+		if contains(p.Signers, s.Key) {
+			weight += s.Weight
+		} else if s.Weight > 0 {
+			others = append(others, fmt.Sprintf("%s (weight %d)", p.name(s.Key), s.Weight))
+		}
+	}
+
+	needed := []byte{a.acc.Thresholds.LowThreshold, a.acc.Thresholds.MedThreshold, a.acc.Thresholds.HighThreshold}[a.threshold]
+	if weight > 0 && weight >= int32(needed) {
+		return nil
+	}
+
+	e := Transaction("tx_bad_auth")
+	e.Message = fmt.Sprintf("%s needs signatures weighing %d (%s threshold), the keys signing weigh %d", p.name(a.acc.ID), needed, thresholdNames[a.threshold], weight)
+	e.Fix = "sign with the keys of more signers, or collect their signatures on the transaction printed with --presign"
+	if len(others) > 0 {
+		e.Fix = fmt.Sprintf("sign with the keys of more signers: %s, or collect their signatures on the transaction printed with --presign", strings.Join(others, ", "))
+	}
+	return &e
+}
+
+// Checklist formats what is missing, one item per line followed by its fix
+func Checklist(missing []Explanation) string {
+	var b bytes.Buffer
+	b.WriteString("the transaction would fail, fix the following first:")
+	for _, e := range missing {
+		fmt.Fprintf(&b, "\n  [ ] %s (%s)", e.Message, e.Code)
+		if e.Fix != "" {
+			fmt.Fprintf(&b, "\n      fix: %s", e.Fix)
+		}
+	}
+
+	return b.String()
+}
+
+func (p Preflight) name(address string) string {
+	if p.Name == nil {
+		return address
+	}
+	return p.Name(address)
+}
+
+// spend records amt of asset spent by a
+func (a *preflightAccount) spend(asset xdr.Asset, amt xdr.Int64) {
+	_, issuer, native := assetCode(asset)
+	switch {
+	case native:
+		a.native += amt
+	case issuer != a.acc.ID: // the issuer creates its asset
+		a.sent[asset.String()] += amt
+	}
+}
+
+// line returns the trustline of a to asset
+func (a *preflightAccount) line(asset xdr.Asset) (horizon.Balance, bool) {
+	key := asset.String()
+	for _, b := range a.acc.Balances {
+		if b.Asset.Type != "native" && balanceKey(b) == key {
+			return b, true
+		}
+	}
+	return horizon.Balance{}, false
+}
+
+func (a *preflightAccount) hasSigner(key string) bool {
+	for _, s := range a.acc.Signers {
+		if s.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// balanceKey returns the key of the asset of b, as xdr.Asset.String does
+func balanceKey(b horizon.Balance) string {
+	return fmt.Sprintf("%s/%s/%s", b.Asset.Type, b.Asset.Code, b.Asset.Issuer)
+}
+
+func assetCode(asset xdr.Asset) (code, issuer string, native bool) {
+	var typ xdr.AssetType
+	if err := asset.Extract(&typ, &code, &issuer); err != nil || typ == xdr.AssetTypeAssetTypeNative {
+		return "XLM", "", true
+	}
+	return code, issuer, false
+}
+
+// operationThreshold returns the threshold the signers of the source of op meet
+func operationThreshold(op xdr.Operation) int {
+	switch op.Body.Type {
+	case xdr.OperationTypeAccountMerge:
+		return thresholdHigh
+	case xdr.OperationTypeAllowTrust, xdr.OperationTypeInflation:
+		return thresholdLow
+	case xdr.OperationTypeSetOptions:
+		o := op.Body.SetOptionsOp
+		if o.MasterWeight != nil || o.LowThreshold != nil || o.MedThreshold != nil || o.HighThreshold != nil || o.Signer != nil {
+			return thresholdHigh
+		}
+	}
+	return thresholdMed
+}
+
+// notFound reports whether err is the 404 returned by horizon for an account
+// which does not exist
+func notFound(err error) bool {
+	if err == ErrAccountNotFound {
+		return true
+	}
+	herr, ok := err.(*horizon.Error)
+	return ok && herr.Response != nil && herr.Response.StatusCode == http.StatusNotFound
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package explain
+
+import (
+	"testing"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+func envelope(t *testing.T, src string, ops ...build.TransactionMutator) *xdr.TransactionEnvelope {
+	opts := []build.TransactionMutator{
+		build.SourceAccount{AddressOrSeed: src},
+		build.Sequence{Sequence: 1},
+		build.TestNetwork,
+		build.BaseFee{Amount: 100},
+	}
+	tx, err := build.Transaction(append(opts, ops...)...)
+	require.NoError(t, err)
+
+	return &xdr.TransactionEnvelope{Tx: *tx.TX}
+}
+
+func account(id, native string, balances ...horizon.Balance) horizon.Account {
+	var acc horizon.Account
+	acc.ID = id
+	acc.Balances = append(balances, horizon.Balance{Balance: native, Asset: horizon.Asset{Type: "native"}})
+	acc.Signers = []horizon.Signer{{Key: id, PublicKey: id, Weight: 1}}
+	return acc
+}
+
+func TestPreflight(t *testing.T) {
+	src, err := keypair.Random()
+	require.NoError(t, err)
+	dest, err := keypair.Random()
+	require.NoError(t, err)
+	issuer, err := keypair.Random()
+	require.NoError(t, err)
+	other, err := keypair.Random()
+	require.NoError(t, err)
+
+	mobi := horizon.Asset{Type: "credit_alphanum4", Code: "MOBI", Issuer: issuer.Address()}
+	sendMOBI := func(amt string) build.TransactionMutator {
+		return build.Payment(build.Destination{AddressOrSeed: dest.Address()}, build.CreditAmount{Code: "MOBI", Issuer: issuer.Address(), Amount: amt})
+	}
+	sendXLM := build.Payment(build.Destination{AddressOrSeed: dest.Address()}, build.NativeAmount{Amount: "10"})
+
+	names := map[string]string{src.Address(): "master", dest.Address(): "bob"}
+	p := Preflight{
+		Signers: []string{src.Address()},
+		Name: func(address string) string {
+			return names[address]
+		},
+	}
+
+	tests := []struct {
+		name     string
+		accounts accounts
+		env      *xdr.TransactionEnvelope
+		codes    []string
+		message  string
+	}{
+		{
+			name: "ready",
+			accounts: accounts{
+				src.Address():  account(src.Address(), "100", horizon.Balance{Balance: "50", Limit: "1000", Asset: mobi}),
+				dest.Address(): account(dest.Address(), "1", horizon.Balance{Balance: "0", Limit: "1000", Asset: mobi}),
+			},
+			env: envelope(t, src.Address(), sendXLM, sendMOBI("50")),
+		},
+		{
+			name:     "no source",
+			accounts: accounts{},
+			env:      envelope(t, src.Address(), sendXLM),
+			codes:    []string{"tx_no_source_account"},
+			message:  "master does not exist",
+		},
+		{
+			name: "underfunded",
+			accounts: accounts{
+				src.Address():  account(src.Address(), "10.5"),
+				dest.Address(): account(dest.Address(), "1"),
+			},
+			env:     envelope(t, src.Address(), sendXLM),
+			codes:   []string{"op_underfunded"},
+			message: "master holds 10.5000000 XLM and needs 11.0000100 XLM: 10.0000000 XLM sent, a fee of 0.0000100 XLM and a reserve of 1.0000000 XLM for 0 entries",
+		},
+		{
+			name: "reserve of a trustline",
+			accounts: accounts{
+				src.Address(): account(src.Address(), "1.2"),
+			},
+			env:   envelope(t, src.Address(), build.Trust("MOBI", issuer.Address())),
+			codes: []string{"op_low_reserve"},
+		},
+		{
+			name: "destination",
+			accounts: accounts{
+				src.Address(): account(src.Address(), "100", horizon.Balance{Balance: "50", Limit: "1000", Asset: mobi}),
+			},
+			env:     envelope(t, src.Address(), sendMOBI("10")),
+			codes:   []string{"op_no_destination"},
+			message: "bob does not exist",
+		},
+		{
+			name: "trustlines",
+			accounts: accounts{
+				src.Address():  account(src.Address(), "100"),
+				dest.Address(): account(dest.Address(), "1"),
+			},
+			env:   envelope(t, src.Address(), sendMOBI("10")),
+			codes: []string{"op_no_trust", "op_src_no_trust"},
+		},
+		{
+			name: "amounts of the asset",
+			accounts: accounts{
+				src.Address():  account(src.Address(), "100", horizon.Balance{Balance: "50", Limit: "1000", Asset: mobi}),
+				dest.Address(): account(dest.Address(), "1", horizon.Balance{Balance: "90", Limit: "100", Asset: mobi}),
+			},
+			env:     envelope(t, src.Address(), sendMOBI("30"), sendMOBI("30")),
+			codes:   []string{"op_underfunded", "op_line_full"},
+			message: "master holds 50 MOBI and sends 60.0000000",
+		},
+		{
+			name: "signatures",
+			accounts: accounts{
+				src.Address(): func() horizon.Account {
+					acc := account(src.Address(), "100")
+					acc.Thresholds.HighThreshold = 2
+					acc.Signers = append(acc.Signers, horizon.Signer{Key: other.Address(), PublicKey: other.Address(), Weight: 1})
+					return acc
+				}(),
+			},
+			env:     envelope(t, src.Address(), build.SetOptions(build.MasterWeight(2))),
+			codes:   []string{"tx_bad_auth"},
+			message: "master needs signatures weighing 2 (high threshold), the keys signing weigh 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p.Accounts = test.accounts
+			missing, err := p.Check(test.env)
+			require.NoError(t, err)
+
+			var codes []string
+			for _, e := range missing {
+				codes = append(codes, e.Code)
+			}
+			require.Equal(t, test.codes, codes)
+			if test.message != "" {
+				require.Equal(t, test.message, missing[0].Message)
+			}
+		})
+	}
+}
+
+func TestReserves(t *testing.T) {
+	src, err := keypair.Random()
+	require.NoError(t, err)
+	dest, err := keypair.Random()
+	require.NoError(t, err)
+	issuer, err := keypair.Random()
+	require.NoError(t, err)
+
+	p := Preflight{
+		Accounts: accounts{
+			src.Address():  account(src.Address(), "12"),
+			dest.Address(): account(dest.Address(), "1"),
+		},
+		Name: func(address string) string {
+			if address == src.Address() {
+				return "master"
+			}
+			return address
+		},
+	}
+	margin := xdr.Int64(amount.One)
+
+	warnings, err := p.Reserves(envelope(t, src.Address(), build.Payment(build.Destination{AddressOrSeed: dest.Address()}, build.NativeAmount{Amount: "5"})), margin)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+
+	warnings, err = p.Reserves(envelope(t, src.Address(), build.Payment(build.Destination{AddressOrSeed: dest.Address()}, build.NativeAmount{Amount: "10.5"})), margin)
+	require.NoError(t, err)
+	require.Equal(t, []string{"master keeps 1.4999900 XLM, only 0.4999900 XLM above its minimum balance of 1.0000000 XLM"}, warnings)
+
+	warnings, err = p.Reserves(envelope(t, src.Address(), build.Trust("MOBI", issuer.Address()), build.Trust("USD", issuer.Address())), margin)
+	require.NoError(t, err)
+	require.Equal(t, []string{"master locks 1.0000000 XLM more in reserve for the entries it adds, its minimum balance rises from 1.0000000 XLM to 2.0000000 XLM"}, warnings)
+
+	// failing, left to Check
+	warnings, err = p.Reserves(envelope(t, src.Address(), build.Payment(build.Destination{AddressOrSeed: dest.Address()}, build.NativeAmount{Amount: "11.5"})), margin)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+}
+
+func TestChecklist(t *testing.T) {
+	msg := Checklist([]Explanation{
+		{Code: "op_no_trust", Message: "bob does not trust MOBI", Fix: "ask the recipient to add a trustline"},
+		{Code: "tx_bad_auth", Message: "master needs signatures weighing 2"},
+	})
+	require.Equal(t, `the transaction would fail, fix the following first:
+  [ ] bob does not trust MOBI (op_no_trust)
+      fix: ask the recipient to add a trustline
+  [ ] master needs signatures weighing 2 (tx_bad_auth)`, msg)
+}
@@ -0,0 +1,261 @@
+// Package failover sends the requests to horizon to the first of several
+// servers that is up. A server answering with a 5xx status, timing out or
+// unreachable is put aside for a while and the request is sent to the next
+// one, so that a failing server goes unnoticed by the commands.
+//
+// A server put aside is health checked before being used again.
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCooldown is the time a failing server is put aside
+const DefaultCooldown = 30 * time.Second
+
+// Client is an http client spreading requests over URLs. It implements the
+// HTTP interface of horizon clients, whose URL should be the first of URLs.
+type Client struct {
+	// URLs are the base urls of the servers, by order of preference
+	URLs []string
+	// HTTP makes the requests, http.DefaultClient if nil
+	HTTP *http.Client
+	// Timeout limits each attempt, streams excepted, no limit if zero
+	Timeout time.Duration
+	// Cooldown is the time a failing server is put aside, DefaultCooldown if zero
+	Cooldown time.Duration
+	// Logf receives the server serving each request and the failovers, if set
+	Logf func(format string, args ...interface{})
+
+	mu sync.Mutex
+	// down are the servers put aside, with the time they can be checked again
+	down map[string]time.Time
+}
+
+// New returns a client for the servers of urls, by order of preference
+func New(urls ...string) *Client {
+	c := &Client{}
+	for _, u := range urls {
+		c.URLs = append(c.URLs, strings.TrimRight(u, "/"))
+	}
+	return c
+}
+
+// Do sends req to the first server up. The request is retried on the next
+// server when a server fails, down to the servers put aside, and the response
+// or error of the last one is returned when they all fail.
+//
+// Requests to urls of none of the servers, such as the links returned by
+// horizon, are sent as is.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	path, ok := c.relative(req.URL.String())
+	if !ok {
+		return c.attempt(req)
+	}
+
+	servers := c.servers(req.Context())
+	for i, server := range servers {
+		r, err := c.rewrite(req, server+path)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		resp, err := c.attempt(r)
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			c.setUp(server)
+			c.logf("%s %s served by %s (%d in %v)", req.Method, path, server, resp.StatusCode, since(start))
+			return resp, nil
+		}
+
+		c.setDown(server)
+		if i == len(servers)-1 {
+			return resp, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = failure(err, c.Timeout)
+		} else {
+			reason = resp.Status
+			resp.Body.Close()
+		}
+		c.logf("%s %s failed on %s (%s), trying the next server", req.Method, path, server, reason)
+	}
+
+	// unreachable, the last server always returns
+	return nil, fmt.Errorf("no horizon server to send %s to", path)
+}
+
+func (c *Client) Get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *Client) PostForm(rawURL string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, rawURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(req)
+}
+
+// relative returns the part of rawURL after the base url of the first server
+func (c *Client) relative(rawURL string) (string, bool) {
+	if len(c.URLs) == 0 || !strings.HasPrefix(rawURL, c.URLs[0]) {
+		return "", false
+	}
+	path := strings.TrimPrefix(rawURL, c.URLs[0])
+	if path != "" && !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "?") {
+		return "", false
+	}
+	return path, true
+}
+
+// servers returns the servers up followed by the ones put aside, which are
+// still tried when the others fail. A server whose cooldown is over is health
+// checked first, it is put aside again if it still fails.
+func (c *Client) servers(ctx context.Context) []string {
+	var up, down []string
+	for _, server := range c.URLs {
+		c.mu.Lock()
+		until, isDown := c.down[server]
+		c.mu.Unlock()
+
+		if isDown && (time.Now().Before(until) || !c.check(ctx, server)) {
+			down = append(down, server)
+			continue
+		}
+		up = append(up, server)
+	}
+	return append(up, down...)
+}
+
+// check reports whether server answers its root url
+func (c *Client) check(ctx context.Context, server string) bool {
+	req, err := http.NewRequest(http.MethodGet, server, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := c.attempt(req.WithContext(ctx))
+	if err == nil {
+		resp.Body.Close()
+	}
+	if err != nil || resp.StatusCode != http.StatusOK {
+		c.setDown(server)
+		return false
+	}
+
+	c.setUp(server)
+	c.logf("%s is back up", server)
+	return true
+}
+
+func (c *Client) setUp(server string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.down, server)
+}
+
+func (c *Client) setDown(server string) {
+	cooldown := c.Cooldown
+	if cooldown == 0 {
+		cooldown = DefaultCooldown
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down == nil {
+		c.down = make(map[string]time.Time)
+	}
+	c.down[server] = time.Now().Add(cooldown)
+}
+
+// rewrite returns a copy of req sent to rawURL, with a fresh body
+func (c *Client) rewrite(req *http.Request, rawURL string) (*http.Request, error) {
+	r, err := http.NewRequest(req.Method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(req.Context())
+	r.Header = req.Header.Clone()
+	if req.GetBody != nil {
+		if r.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+		r.ContentLength = req.ContentLength
+	}
+	return r, nil
+}
+
+// attempt sends req once, within Timeout unless it is a stream
+func (c *Client) attempt(req *http.Request) (*http.Response, error) {
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if c.Timeout <= 0 || req.Header.Get("Accept") == "text/event-stream" {
+		return client.Do(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), c.Timeout)
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the body is read after Do returns, the context lives until it is closed
+	resp.Body = cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+// cancelBody releases the context of a response once its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// failure describes err without the url, which is already logged
+func failure(err error, timeout time.Duration) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Sprintf("no answer within %v", timeout)
+	}
+	if uerr, ok := err.(*url.Error); ok {
+		return uerr.Err.Error()
+	}
+	return err.Error()
+}
+
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Millisecond)
+}
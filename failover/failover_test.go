@@ -0,0 +1,146 @@
+package failover
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// server answers with its status after its delay and counts its requests
+type server struct {
+	*httptest.Server
+	hits   int32
+	status atomic.Value
+	delay  time.Duration
+}
+
+func newServer(t *testing.T, status int) *server {
+	s := &server{}
+	s.status.Store(status)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.hits, 1)
+		time.Sleep(s.delay)
+		w.WriteHeader(s.status.Load().(int))
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + string(body)))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func get(t *testing.T, c *Client, path string) (int, string) {
+	resp, err := c.Get(c.URLs[0] + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestFailover(t *testing.T) {
+	a, b := newServer(t, http.StatusServiceUnavailable), newServer(t, http.StatusOK)
+	c := New(a.URL+"/", b.URL)
+
+	status, body := get(t, c, "/accounts/GA?limit=1")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "GET /accounts/GA?limit=1 ", body)
+	require.EqualValues(t, 1, a.hits)
+	require.EqualValues(t, 1, b.hits)
+
+	// a is put aside
+	get(t, c, "/")
+	require.EqualValues(t, 1, a.hits)
+	require.EqualValues(t, 2, b.hits)
+
+	// not found is an answer, not a failure
+	b.status.Store(http.StatusNotFound)
+	status, _ = get(t, c, "/accounts/GB")
+	require.Equal(t, http.StatusNotFound, status)
+	require.EqualValues(t, 3, b.hits)
+}
+
+func TestFailoverPost(t *testing.T) {
+	a, b := newServer(t, http.StatusBadGateway), newServer(t, http.StatusOK)
+	c := New(a.URL, b.URL)
+
+	resp, err := c.PostForm(a.URL+"/transactions", url.Values{"tx": {"AAAA"}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "POST /transactions tx=AAAA", string(body))
+}
+
+func TestFailoverTimeout(t *testing.T) {
+	a, b := newServer(t, http.StatusOK), newServer(t, http.StatusOK)
+	c := New(a.URL, b.URL)
+	c.Timeout = 50 * time.Millisecond
+
+	var logs []string
+	c.Logf = func(format string, args ...interface{}) {
+		logs = append(logs, format)
+	}
+
+	a.delay = 200 * time.Millisecond
+	b.status.Store(http.StatusTeapot)
+	status, _ := get(t, c, "/")
+	require.Equal(t, http.StatusTeapot, status)
+	require.Len(t, logs, 2)
+}
+
+func TestAllDown(t *testing.T) {
+	a, b := newServer(t, http.StatusServiceUnavailable), newServer(t, http.StatusGatewayTimeout)
+	c := New(a.URL, b.URL)
+
+	// the answer of the last server is returned
+	status, _ := get(t, c, "/")
+	require.Equal(t, http.StatusGatewayTimeout, status)
+
+	// they are still tried, by order of preference
+	a.status.Store(http.StatusOK)
+	status, _ = get(t, c, "/")
+	require.Equal(t, http.StatusOK, status)
+	require.EqualValues(t, 2, a.hits)
+	require.EqualValues(t, 1, b.hits)
+}
+
+func TestBackUp(t *testing.T) {
+	a, b := newServer(t, http.StatusInternalServerError), newServer(t, http.StatusOK)
+	c := New(a.URL, b.URL)
+	c.Cooldown = time.Millisecond
+
+	get(t, c, "/")
+	require.EqualValues(t, 1, a.hits)
+
+	// still failing the health check
+	time.Sleep(5 * time.Millisecond)
+	get(t, c, "/")
+	require.EqualValues(t, 2, a.hits)
+	require.EqualValues(t, 2, b.hits)
+
+	a.status.Store(http.StatusOK)
+	time.Sleep(5 * time.Millisecond)
+	get(t, c, "/")
+	require.EqualValues(t, 4, a.hits)
+	require.EqualValues(t, 2, b.hits)
+}
+
+func TestOtherURL(t *testing.T) {
+	a, b := newServer(t, http.StatusServiceUnavailable), newServer(t, http.StatusOK)
+	c := New(a.URL)
+
+	// links to other servers are followed as is
+	resp, err := c.Get(b.URL + "/transactions/abc")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 0, a.hits)
+}
@@ -0,0 +1,158 @@
+// Package fee chooses the base fee of transactions, the fee paid per
+// operation, from the fee statistics of horizon.
+//
+// When more transactions are submitted than a ledger can hold, the network is
+// in surge pricing: the transactions paying the highest fees are included
+// first and the ones paying the base fee of the network are likely to fail.
+package fee
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/stellar/go/clients/horizon"
+)
+
+// Levels of fee, from the cheapest to the most likely to be included
+const (
+	Auto   = "auto"
+	Low    = "low"
+	Medium = "medium"
+	High   = "high"
+)
+
+// SurgeCapacity is the usage of the capacity of the last ledgers from which
+// the network is considered in surge pricing
+const SurgeCapacity = 0.9
+
+// Stats are the fees per operation charged in the last ledgers, in stroops
+type Stats struct {
+	// BaseFee is the minimum fee per operation of the network
+	BaseFee uint64
+	// CapacityUsage is the share of the capacity of the last ledgers used, from 0 to 1
+	CapacityUsage float64
+	// P10, P50 and P90 are percentiles of the fees charged
+	P10, P50, P90 uint64
+}
+
+// Surge reports whether the network is in surge pricing
+func (s Stats) Surge() bool {
+	return s.CapacityUsage >= SurgeCapacity || s.P10 > s.BaseFee
+}
+
+// Fee returns the base fee of level: low, medium and high are the 10th, 50th
+// and 90th percentiles of the fees charged, and auto is the base fee of the
+// network unless it is in surge pricing, in which case it is high
+func (s Stats) Fee(level string) uint64 {
+	var fee uint64
+	switch level {
+	case Low:
+		fee = s.P10
+	case Medium:
+		fee = s.P50
+	case High:
+		fee = s.P90
+	case Auto:
+		if s.Surge() {
+			fee = s.P90
+		}
+	}
+
+	if fee < s.BaseFee {
+		return s.BaseFee
+	}
+	return fee
+}
+
+// Spec is a fee given by the user: either a level or a number of stroops
+type Spec struct {
+	Level   string
+	Stroops uint64
+}
+
+// Parse parses auto, low, medium, high or a number of stroops
+func Parse(s string) (Spec, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case Auto, Low, Medium, High:
+		return Spec{Level: s}, nil
+	}
+
+	stroops, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || stroops == 0 {
+		return Spec{}, fmt.Errorf("invalid fee '%s', should be auto, low, medium, high or a number of stroops", s)
+	}
+	return Spec{Stroops: stroops}, nil
+}
+
+// rawStats is the response of the fee stats endpoint. Its values are strings,
+// newer versions of horizon group the percentiles under fee_charged.
+type rawStats struct {
+	LastLedgerBaseFee   json.Number `json:"last_ledger_base_fee"`
+	LedgerCapacityUsage json.Number `json:"ledger_capacity_usage"`
+	FeeCharged          *struct {
+		P10 json.Number `json:"p10"`
+		P50 json.Number `json:"p50"`
+		P90 json.Number `json:"p90"`
+	} `json:"fee_charged"`
+	P10AcceptedFee json.Number `json:"p10_accepted_fee"`
+	P50AcceptedFee json.Number `json:"p50_accepted_fee"`
+	P90AcceptedFee json.Number `json:"p90_accepted_fee"`
+}
+
+// Decode decodes the response of the fee stats endpoint
+func Decode(data []byte) (Stats, error) {
+	var raw rawStats
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Stats{}, err
+	}
+
+	p10, p50, p90 := raw.P10AcceptedFee, raw.P50AcceptedFee, raw.P90AcceptedFee
+	if raw.FeeCharged != nil {
+		p10, p50, p90 = raw.FeeCharged.P10, raw.FeeCharged.P50, raw.FeeCharged.P90
+	}
+
+	var s Stats
+	var err error
+	for _, v := range []struct {
+		n   json.Number
+		dst *uint64
+	}{{raw.LastLedgerBaseFee, &s.BaseFee}, {p10, &s.P10}, {p50, &s.P50}, {p90, &s.P90}} {
+		if *v.dst, err = strconv.ParseUint(v.n.String(), 10, 64); err != nil {
+			return Stats{}, fmt.Errorf("invalid fee stats: %v", err)
+		}
+	}
+	if raw.LedgerCapacityUsage != "" {
+		if s.CapacityUsage, err = raw.LedgerCapacityUsage.Float64(); err != nil {
+			return Stats{}, fmt.Errorf("invalid fee stats: %v", err)
+		}
+	}
+
+	return s, nil
+}
+
+// Load loads the fee stats of the network of client
+func Load(client *horizon.Client) (Stats, error) {
+	resp, err := client.HTTP.Get(strings.TrimRight(client.URL, "/") + "/fee_stats")
+	if err != nil {
+		return Stats{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		herr := &horizon.Error{Response: resp}
+		if err := json.NewDecoder(resp.Body).Decode(&herr.Problem); err != nil {
+			return Stats{}, err
+		}
+		return Stats{}, herr
+	}
+
+	var data json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return Stats{}, err
+	}
+	return Decode(data)
+}
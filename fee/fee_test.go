@@ -0,0 +1,60 @@
+package fee
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func loadStats(t *testing.T, name string) Stats {
+	b, err := ioutil.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+
+	s, err := Decode(b)
+	require.NoError(t, err)
+	return s
+}
+
+func TestDecode(t *testing.T) {
+	require.Equal(t, Stats{BaseFee: 100, CapacityUsage: 0.97, P10: 120, P50: 250, P90: 1000}, loadStats(t, "fee_stats.json"))
+	require.Equal(t, Stats{BaseFee: 100, CapacityUsage: 0.12, P10: 100, P50: 100, P90: 150}, loadStats(t, "fee_stats_legacy.json"))
+
+	_, err := Decode([]byte(`{"last_ledger_base_fee": "abc"}`))
+	require.Error(t, err)
+}
+
+func TestFee(t *testing.T) {
+	surge := loadStats(t, "fee_stats.json")
+	require.True(t, surge.Surge())
+	require.Equal(t, uint64(120), surge.Fee(Low))
+	require.Equal(t, uint64(250), surge.Fee(Medium))
+	require.Equal(t, uint64(1000), surge.Fee(High))
+	require.Equal(t, uint64(1000), surge.Fee(Auto))
+
+	calm := loadStats(t, "fee_stats_legacy.json")
+	require.False(t, calm.Surge())
+	require.Equal(t, uint64(100), calm.Fee(Auto))
+	require.Equal(t, uint64(150), calm.Fee(High))
+
+	// never below the base fee of the network
+	require.Equal(t, uint64(100), Stats{BaseFee: 100}.Fee(Low))
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Spec{
+		"auto":  {Level: Auto},
+		"HIGH":  {Level: High},
+		" low ": {Level: Low},
+		"500":   {Stroops: 500},
+	} {
+		got, err := Parse(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "0", "-1", "fast", "1.5", "99999999999"} {
+		_, err := Parse(in)
+		require.Error(t, err, in)
+	}
+}
@@ -0,0 +1,181 @@
+// Package hardware derives the secret of the database from a hardware key, so
+// that the database file and its password are not enough to decrypt it.
+//
+// It talks to the keys with the tools of their vendors: ykchalresp, of
+// yubikey-personalization, for the HMAC-SHA1 challenge-response of a YubiKey,
+// and fido2-cred and fido2-assert, of libfido2, for the hmac-secret extension
+// of a FIDO2 key.
+package hardware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// YubiKey is the kind of a YubiKey slot configured for HMAC-SHA1
+	// challenge-response
+	YubiKey = "yubikey"
+	// FIDO2 is the kind of a FIDO2 key supporting the hmac-secret extension
+	FIDO2 = "fido2"
+
+	// RelyingParty is the relying party of the FIDO2 credentials of alfred
+	RelyingParty = "alfred"
+)
+
+// run executes a command with stdin, replaced in tests
+var run = func(stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.Error); ok {
+			return nil, fmt.Errorf("%s not found, it is needed to talk to the hardware key", name)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %v: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return out, nil
+}
+
+// Key is a hardware key and what it needs to answer the same response every
+// time. It holds no secret and is stored in clear in the database.
+type Key struct {
+	Kind string `yaml:"kind"`
+	// Challenge is sent to the key, as the salt of the hmac-secret of a FIDO2
+	// key, encoded in hex
+	Challenge string `yaml:"challenge"`
+	// Slot is the slot of a YubiKey, 1 or 2
+	Slot int `yaml:"slot,omitempty"`
+	// Device is the path of a FIDO2 key, as listed by fido2-token -L
+	Device string `yaml:"device,omitempty"`
+	// Credential is the FIDO2 credential made for alfred, encoded in base64
+	Credential string `yaml:"credential,omitempty"`
+}
+
+// NewYubiKey returns the key of a YubiKey slot, with a random challenge
+func NewYubiKey(slot int) (*Key, error) {
+	if slot != 1 && slot != 2 {
+		return nil, errors.New("the slot of a YubiKey is 1 or 2")
+	}
+
+	challenge, err := random(32)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Kind: YubiKey, Slot: slot, Challenge: hex.EncodeToString(challenge)}, nil
+}
+
+// NewFIDO2 makes a credential for alfred on the FIDO2 key at device, and
+// returns it with a random salt. The key usually asks to be touched.
+func NewFIDO2(device string) (*Key, error) {
+	if device == "" {
+		return nil, errors.New("the device of the FIDO2 key should be set, list them with fido2-token -L")
+	}
+
+	clientData, err := random(32)
+	if err != nil {
+		return nil, err
+	}
+	user, err := random(32)
+	if err != nil {
+		return nil, err
+	}
+
+	// client data hash, relying party, user name and user id
+	in := lines(base64.StdEncoding.EncodeToString(clientData), RelyingParty, RelyingParty, base64.StdEncoding.EncodeToString(user))
+	out, err := run(in, "fido2-cred", "-M", "-h", device)
+	if err != nil {
+		return nil, err
+	}
+
+	// client data hash, relying party, format, authenticator data, credential
+	// id, then the attestation
+	fields := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(fields) < 5 {
+		return nil, errors.New("fido2-cred: unexpected output")
+	}
+
+	salt, err := random(32)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Kind: FIDO2, Device: device, Credential: strings.TrimSpace(fields[4]), Challenge: hex.EncodeToString(salt)}, nil
+}
+
+// Respond returns the response of the key to its challenge. The key may ask
+// to be touched.
+func (k *Key) Respond() ([]byte, error) {
+	challenge, err := hex.DecodeString(k.Challenge)
+	if err != nil {
+		return nil, fmt.Errorf("invalid challenge: %v", err)
+	}
+
+	switch k.Kind {
+	case YubiKey:
+		out, err := run(nil, "ykchalresp", fmt.Sprintf("-%d", k.Slot), "-H", "-x", k.Challenge)
+		if err != nil {
+			return nil, err
+		}
+		return hex.DecodeString(strings.TrimSpace(string(out)))
+
+	case FIDO2:
+		clientData, err := random(32)
+		if err != nil {
+			return nil, err
+		}
+
+		// client data hash, relying party, credential id and salt
+		in := lines(base64.StdEncoding.EncodeToString(clientData), RelyingParty, k.Credential, base64.StdEncoding.EncodeToString(challenge))
+		out, err := run(in, "fido2-assert", "-G", "-h", k.Device)
+		if err != nil {
+			return nil, err
+		}
+
+		// the hmac-secret is the last line
+		fields := strings.Split(strings.TrimSpace(string(out)), "\n")
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(fields[len(fields)-1]))
+	}
+
+	return nil, fmt.Errorf("unknown hardware key %s", k.Kind)
+}
+
+// String describes the key
+func (k *Key) String() string {
+	if k.Kind == YubiKey {
+		return fmt.Sprintf("YubiKey, slot %d", k.Slot)
+	}
+	return fmt.Sprintf("FIDO2 key %s", k.Device)
+}
+
+// Secret returns the secret of the database derived from its password and
+// the response of its hardware key
+func Secret(password string, response []byte) string {
+	mac := hmac.New(sha256.New, response)
+	mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func random(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(rand.Reader, b)
+	return b, err
+}
+
+func lines(fields ...string) []byte {
+	return []byte(strings.Join(fields, "\n") + "\n")
+}
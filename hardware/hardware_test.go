@@ -0,0 +1,67 @@
+package hardware
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestYubiKey(t *testing.T) {
+	_, err := NewYubiKey(3)
+	require.Error(t, err)
+
+	k, err := NewYubiKey(2)
+	require.NoError(t, err)
+	require.Len(t, k.Challenge, 64)
+
+	var ran []string
+	run = func(stdin []byte, name string, args ...string) ([]byte, error) {
+		ran = append([]string{name}, args...)
+		return []byte("0102030405060708090a0b0c0d0e0f1011121314\n"), nil
+	}
+
+	response, err := k.Respond()
+	require.NoError(t, err)
+	require.Equal(t, []string{"ykchalresp", "-2", "-H", "-x", k.Challenge}, ran)
+	require.Len(t, response, 20)
+	require.Equal(t, "YubiKey, slot 2", k.String())
+}
+
+func TestFIDO2(t *testing.T) {
+	var stdins []string
+	run = func(stdin []byte, name string, args ...string) ([]byte, error) {
+		stdins = append(stdins, string(stdin))
+		require.Equal(t, "/dev/hidraw0", args[len(args)-1])
+		switch name {
+		case "fido2-cred":
+			return []byte("cdh\nalfred\npacked\nauthdata\nY3JlZGVudGlhbA==\nsig\n"), nil
+		case "fido2-assert":
+			return []byte("cdh\nalfred\nauthdata\nsig\n" + base64.StdEncoding.EncodeToString([]byte("hmac-secret")) + "\n"), nil
+		}
+		return nil, nil
+	}
+
+	_, err := NewFIDO2("")
+	require.Error(t, err)
+
+	k, err := NewFIDO2("/dev/hidraw0")
+	require.NoError(t, err)
+	require.Equal(t, "Y3JlZGVudGlhbA==", k.Credential)
+
+	response, err := k.Respond()
+	require.NoError(t, err)
+	require.Equal(t, "hmac-secret", string(response))
+
+	// the credential and the salt are sent to the key
+	assertion := strings.Split(stdins[1], "\n")
+	require.Equal(t, RelyingParty, assertion[1])
+	require.Equal(t, k.Credential, assertion[2])
+}
+
+func TestSecret(t *testing.T) {
+	require.Equal(t, Secret("hello", []byte{1}), Secret("hello", []byte{1}))
+	require.NotEqual(t, Secret("hello", []byte{1}), Secret("hello", []byte{2}))
+	require.NotEqual(t, Secret("hello", []byte{1}), Secret("hellp", []byte{1}))
+}
@@ -0,0 +1,319 @@
+// Package horizontest runs an in-memory ledger behind the horizon API, so that
+// alfred and the scripts wrapping it can be exercised without a network.
+//
+// Submitted transactions are checked like on the network: sequence numbers,
+// time bounds, fees, signatures, balances and reserves. The operations used
+// by alfred are applied to the ledger, offers are recorded but never cross
+// and the issuers of the assets do not need to exist.
+package horizontest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+const (
+	// BaseReserve is the amount of XLM locked for each entry of an account
+	BaseReserve = xdr.Int64(5000000)
+	// BaseFee is the minimum fee of an operation
+	BaseFee = xdr.Int64(100)
+)
+
+// Ledger is the state of an in-memory network, it is safe for concurrent use
+type Ledger struct {
+	// Passphrase is the passphrase of the network the transactions are signed for
+	Passphrase string
+
+	mu       sync.Mutex
+	sequence int32
+	state    *state
+	txs      map[string]horizon.Transaction
+	// surgeFee is the minimum fee of an operation in surge pricing, zero otherwise
+	surgeFee xdr.Int64
+}
+
+// NewLedger returns an empty ledger for the network identified by passphrase
+func NewLedger(passphrase string) *Ledger {
+	return &Ledger{
+		Passphrase: passphrase,
+		sequence:   1,
+		state:      &state{accounts: make(map[string]*account)},
+		txs:        make(map[string]horizon.Transaction),
+	}
+}
+
+// Fund credits address with an amount of XLM, the account is created if needed
+func (l *Ledger) Fund(address, xlm string) error {
+	if _, err := keypair.Parse(address); err != nil {
+		return err
+	}
+
+	amt, err := amount.Parse(xlm)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if acc, ok := l.state.accounts[address]; ok {
+		acc.native().amount += amt
+		return nil
+	}
+
+	l.state.accounts[address] = newAccount(address, amt, l.sequence)
+	return nil
+}
+
+// Account returns the account with address as horizon shows it, and whether it exists
+func (l *Ledger) Account(address string) (horizon.Account, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	acc, ok := l.state.accounts[address]
+	if !ok {
+		return horizon.Account{}, false
+	}
+
+	return acc.horizon(), true
+}
+
+// Offers returns the offers of seller
+func (l *Ledger) Offers(seller string) []horizon.Offer {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var offers []horizon.Offer
+	for _, o := range l.state.offers {
+		if o.seller == seller {
+			offers = append(offers, o.horizon())
+		}
+	}
+
+	return offers
+}
+
+// Transaction returns the transaction with hash, and whether it is in the ledger
+func (l *Ledger) Transaction(hash string) (horizon.Transaction, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tx, ok := l.txs[hash]
+	return tx, ok
+}
+
+// TxError is a transaction rejected by the ledger, with its result codes
+type TxError struct {
+	Code       string
+	Operations []string
+}
+
+func (e *TxError) Error() string {
+	if len(e.Operations) > 0 {
+		return fmt.Sprintf("%s %v", e.Code, e.Operations)
+	}
+	return e.Code
+}
+
+// SetSurgeFee puts the ledger in surge pricing: transactions paying less
+// than fee per operation are refused. Zero ends it.
+func (l *Ledger) SetSurgeFee(fee xdr.Int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.surgeFee = fee
+}
+
+// minFee returns the minimum fee of an operation
+func (l *Ledger) minFee() xdr.Int64 {
+	if l.surgeFee > BaseFee {
+		return l.surgeFee
+	}
+	return BaseFee
+}
+
+// FeeStats returns the fee stats of the ledger, in the format of horizon
+func (l *Ledger) FeeStats() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	usage, charged := "0.1", strconv.FormatInt(int64(l.minFee()), 10)
+	if l.surgeFee > BaseFee {
+		usage = "1.0"
+	}
+
+	return map[string]interface{}{
+		"last_ledger":           strconv.Itoa(int(l.sequence)),
+		"last_ledger_base_fee":  strconv.FormatInt(int64(BaseFee), 10),
+		"ledger_capacity_usage": usage,
+		"fee_charged": map[string]string{
+			"min": charged, "mode": charged, "p10": charged, "p50": charged, "p90": charged, "p99": charged,
+		},
+	}
+}
+
+// Submit validates txe and applies it to the ledger.
+// The fee and the sequence number are consumed even if an operation fails,
+// the operations are either all applied or none of them.
+func (l *Ledger) Submit(txe xdr.TransactionEnvelope) (horizon.Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tx := txe.Tx
+	hash, err := network.HashTransaction(&tx, l.Passphrase)
+	if err != nil {
+		return horizon.Transaction{}, err
+	}
+
+	src, ok := l.state.accounts[tx.SourceAccount.Address()]
+	if !ok {
+		return horizon.Transaction{}, &TxError{Code: "tx_no_account"}
+	}
+
+	if tx.SeqNum != src.seq+1 {
+		return horizon.Transaction{}, &TxError{Code: "tx_bad_seq"}
+	}
+
+	now := time.Now().Unix()
+	if tb := tx.TimeBounds; tb != nil {
+		if int64(tb.MinTime) > now {
+			return horizon.Transaction{}, &TxError{Code: "tx_too_early"}
+		}
+		if tb.MaxTime != 0 && int64(tb.MaxTime) < now {
+			return horizon.Transaction{}, &TxError{Code: "tx_too_late"}
+		}
+	}
+
+	if len(tx.Operations) == 0 {
+		return horizon.Transaction{}, &TxError{Code: "tx_missing_operation"}
+	}
+
+	fee := xdr.Int64(tx.Fee)
+	if fee < l.minFee()*xdr.Int64(len(tx.Operations)) {
+		return horizon.Transaction{}, &TxError{Code: "tx_insufficient_fee"}
+	}
+
+	if src.native().amount < fee {
+		return horizon.Transaction{}, &TxError{Code: "tx_insufficient_balance"}
+	}
+
+	if !l.state.authorized(hash, txe) {
+		return horizon.Transaction{}, &TxError{Code: "tx_bad_auth"}
+	}
+
+	src.native().amount -= fee
+	src.seq = tx.SeqNum
+	l.state.removePreAuth(hash)
+	l.sequence++
+
+	next := l.state.clone()
+	codes, failed := next.apply(tx, l.sequence)
+	if failed {
+		return horizon.Transaction{}, &TxError{Code: "tx_failed", Operations: codes}
+	}
+	l.state = next
+
+	txeB64, err := xdr.MarshalBase64(txe)
+	if err != nil {
+		return horizon.Transaction{}, err
+	}
+
+	hashHex := hex.EncodeToString(hash[:])
+	record := horizon.Transaction{
+		ID:              hashHex,
+		PagingToken:     fmt.Sprint(int64(l.sequence) << 32),
+		Hash:            hashHex,
+		Ledger:          l.sequence,
+		LedgerCloseTime: time.Now().UTC(),
+		Account:         tx.SourceAccount.Address(),
+		AccountSequence: fmt.Sprint(int64(tx.SeqNum)),
+		FeePaid:         int32(fee),
+		OperationCount:  int32(len(tx.Operations)),
+		EnvelopeXdr:     txeB64,
+		MemoType:        memoType(tx.Memo),
+	}
+	for _, sig := range txe.Signatures {
+		record.Signatures = append(record.Signatures, fmt.Sprintf("%x", sig.Signature))
+	}
+	l.txs[hashHex] = record
+
+	return record, nil
+}
+
+// OrderBook returns the offers between selling and buying, the best prices first.
+// Asks sell the selling asset, bids buy it.
+func (l *Ledger) OrderBook(selling, buying horizon.Asset) horizon.OrderBookSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	book := horizon.OrderBookSummary{Selling: selling, Buying: buying}
+	var asks, bids []*offer
+	for _, o := range l.state.offers {
+		s, b := horizonAsset(o.selling), horizonAsset(o.buying)
+		switch {
+		case s == selling && b == buying:
+			asks = append(asks, o)
+		case s == buying && b == selling:
+			bids = append(bids, o)
+		}
+	}
+
+	sort.SliceStable(asks, func(i, j int) bool { return asks[i].price() < asks[j].price() })
+	sort.SliceStable(bids, func(i, j int) bool { return bids[i].price() < bids[j].price() })
+
+	for _, o := range asks {
+		book.Asks = append(book.Asks, priceLevel(o.amount, o.p))
+	}
+	for _, o := range bids {
+		inverted := o.p
+		inverted.Invert()
+		book.Bids = append(book.Bids, priceLevel(o.amount, inverted))
+	}
+
+	return book
+}
+
+func priceLevel(amt xdr.Int64, p xdr.Price) horizon.PriceLevel {
+	return horizon.PriceLevel{
+		PriceR: horizon.Price{N: int32(p.N), D: int32(p.D)},
+		Price:  p.String(),
+		Amount: amount.String(amt),
+	}
+}
+
+func memoType(memo xdr.Memo) string {
+	switch memo.Type {
+	case xdr.MemoTypeMemoText:
+		return "text"
+	case xdr.MemoTypeMemoId:
+		return "id"
+	case xdr.MemoTypeMemoHash:
+		return "hash"
+	case xdr.MemoTypeMemoReturn:
+		return "return"
+	}
+	return "none"
+}
+
+func horizonAsset(a xdr.Asset) horizon.Asset {
+	var h horizon.Asset
+	a.MustExtract(&h.Type, &h.Code, &h.Issuer)
+	return h
+}
+
+// issuer returns the issuer of a, or an empty string for XLM
+func issuer(a xdr.Asset) string {
+	var typ, code, iss string
+	a.MustExtract(&typ, &code, &iss)
+	return iss
+}
@@ -0,0 +1,233 @@
+package horizontest
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/celrenheit/alfred/fee"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/stretchr/testify/require"
+)
+
+func randomKP(t *testing.T) *keypair.Full {
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	return kp
+}
+
+// submit signs a transaction from src with seeds and submits it to the server
+func submit(t *testing.T, client *horizon.Client, src *keypair.Full, seeds []string, muts ...build.TransactionMutator) error {
+	muts = append([]build.TransactionMutator{
+		build.SourceAccount{AddressOrSeed: src.Address()},
+		build.AutoSequence{SequenceProvider: client},
+		build.TestNetwork,
+	}, muts...)
+	tx, err := build.Transaction(muts...)
+	require.NoError(t, err)
+
+	txe, err := tx.Sign(seeds...)
+	require.NoError(t, err)
+	txeB64, err := txe.Base64()
+	require.NoError(t, err)
+
+	_, err = client.SubmitTransaction(txeB64)
+	return err
+}
+
+func resultCodes(t *testing.T, err error) *horizon.TransactionResultCodes {
+	herr, ok := err.(*horizon.Error)
+	require.True(t, ok, "%v", err)
+	codes, err := herr.ResultCodes()
+	require.NoError(t, err)
+	return codes
+}
+
+func TestPayments(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	alice, bob := randomKP(t), randomKP(t)
+	require.NoError(t, srv.Ledger.Fund(alice.Address(), "100"))
+
+	_, err := client.LoadAccount(bob.Address())
+	require.Equal(t, 404, err.(*horizon.Error).Response.StatusCode)
+
+	err = submit(t, client, alice, []string{alice.Seed()},
+		build.CreateAccount(build.Destination{AddressOrSeed: bob.Address()}, build.NativeAmount{Amount: "10"}),
+		build.Payment(build.Destination{AddressOrSeed: bob.Address()}, build.NativeAmount{Amount: "5"}),
+	)
+	require.NoError(t, err)
+
+	acc, err := client.LoadAccount(bob.Address())
+	require.NoError(t, err)
+	require.Equal(t, "15.0000000", acc.GetNativeBalance())
+	acc, err = client.LoadAccount(alice.Address())
+	require.NoError(t, err)
+	require.Equal(t, "84.9999800", acc.GetNativeBalance())
+
+	// the minimum balance of alice cannot be spent
+	err = submit(t, client, alice, []string{alice.Seed()},
+		build.Payment(build.Destination{AddressOrSeed: bob.Address()}, build.NativeAmount{Amount: "84.5"}),
+	)
+	require.Equal(t, []string{"op_underfunded"}, resultCodes(t, err).OperationCodes)
+
+	err = submit(t, client, alice, []string{bob.Seed()},
+		build.Payment(build.Destination{AddressOrSeed: bob.Address()}, build.NativeAmount{Amount: "1"}),
+	)
+	require.Equal(t, "tx_bad_auth", resultCodes(t, err).TransactionCode)
+
+	err = submit(t, client, alice, []string{alice.Seed()},
+		build.Sequence{Sequence: 1},
+		build.Payment(build.Destination{AddressOrSeed: bob.Address()}, build.NativeAmount{Amount: "1"}),
+	)
+	require.Equal(t, "tx_bad_seq", resultCodes(t, err).TransactionCode)
+}
+
+func TestCreditAndOffers(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	issuer, alice := randomKP(t), randomKP(t)
+	require.NoError(t, srv.Ledger.Fund(issuer.Address(), "100"))
+	require.NoError(t, srv.Ledger.Fund(alice.Address(), "100"))
+
+	pay := build.Payment(build.Destination{AddressOrSeed: alice.Address()}, build.CreditAmount{Code: "USD", Issuer: issuer.Address(), Amount: "50"})
+	err := submit(t, client, issuer, []string{issuer.Seed()}, pay)
+	require.Equal(t, []string{"op_no_trust"}, resultCodes(t, err).OperationCodes)
+
+	require.NoError(t, submit(t, client, alice, []string{alice.Seed()}, build.Trust("USD", issuer.Address())))
+	require.NoError(t, submit(t, client, issuer, []string{issuer.Seed()}, pay))
+
+	acc, ok := srv.Ledger.Account(alice.Address())
+	require.True(t, ok)
+	require.Equal(t, "50.0000000", acc.GetCreditBalance("USD", issuer.Address()))
+	require.Equal(t, int32(1), acc.SubentryCount)
+
+	usd := build.CreditAsset("USD", issuer.Address())
+	err = submit(t, client, alice, []string{alice.Seed()},
+		build.CreateOffer(build.Rate{Selling: usd, Buying: build.NativeAsset(), Price: "2"}, "20"),
+	)
+	require.NoError(t, err)
+	require.Len(t, srv.Ledger.Offers(alice.Address()), 1)
+
+	book, err := client.LoadOrderBook(horizon.Asset{Type: "native"}, horizon.Asset{Type: "credit_alphanum4", Code: "USD", Issuer: issuer.Address()})
+	require.NoError(t, err)
+	require.Empty(t, book.Asks)
+	require.Len(t, book.Bids, 1)
+	require.Equal(t, "0.5000000", book.Bids[0].Price)
+
+	// a failed operation leaves the ledger unchanged
+	err = submit(t, client, alice, []string{alice.Seed()},
+		build.Payment(build.Destination{AddressOrSeed: issuer.Address()}, build.CreditAmount{Code: "USD", Issuer: issuer.Address(), Amount: "10"}),
+		build.Payment(build.Destination{AddressOrSeed: issuer.Address()}, build.NativeAmount{Amount: "1000"}),
+	)
+	require.Equal(t, []string{"op_success", "op_underfunded"}, resultCodes(t, err).OperationCodes)
+	acc, _ = srv.Ledger.Account(alice.Address())
+	require.Equal(t, "50.0000000", acc.GetCreditBalance("USD", issuer.Address()))
+}
+
+func TestSigners(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	alice, bob := randomKP(t), randomKP(t)
+	require.NoError(t, srv.Ledger.Fund(alice.Address(), "100"))
+	require.NoError(t, srv.Ledger.Fund(bob.Address(), "100"))
+
+	err := submit(t, client, alice, []string{alice.Seed()}, build.SetOptions(
+		build.AddSigner(bob.Address(), 1),
+		build.MasterWeight(2),
+		build.SetThresholds(1, 1, 3),
+	))
+	require.NoError(t, err)
+
+	acc, _ := srv.Ledger.Account(alice.Address())
+	require.Len(t, acc.Signers, 2)
+	require.Equal(t, byte(3), acc.Thresholds.HighThreshold)
+
+	data := build.SetData("key", []byte("value"))
+	require.NoError(t, submit(t, client, alice, []string{bob.Seed()}, data))
+
+	merge := build.AccountMerge(build.Destination{AddressOrSeed: bob.Address()})
+	err = submit(t, client, alice, []string{alice.Seed()}, merge)
+	require.Equal(t, "tx_bad_auth", resultCodes(t, err).TransactionCode)
+
+	err = submit(t, client, alice, []string{alice.Seed(), bob.Seed()}, merge)
+	require.Equal(t, []string{"op_has_sub_entries"}, resultCodes(t, err).OperationCodes)
+}
+
+func TestPreAuthorized(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	alice, bob := randomKP(t), randomKP(t)
+	require.NoError(t, srv.Ledger.Fund(alice.Address(), "100"))
+	require.NoError(t, srv.Ledger.Fund(bob.Address(), "100"))
+
+	acc, _ := srv.Ledger.Account(alice.Address())
+	seq, err := strconv.ParseUint(acc.Sequence, 10, 64)
+	require.NoError(t, err)
+
+	// a payment submitted after the transaction adding its signer
+	pay, err := build.Transaction(
+		build.SourceAccount{AddressOrSeed: alice.Address()},
+		build.Sequence{Sequence: seq + 2},
+		build.TestNetwork,
+		build.Payment(build.Destination{AddressOrSeed: bob.Address()}, build.NativeAmount{Amount: "5"}),
+	)
+	require.NoError(t, err)
+	hash, err := pay.Hash()
+	require.NoError(t, err)
+
+	preAuth := strkey.MustEncode(strkey.VersionByteHashTx, hash[:])
+	require.NoError(t, submit(t, client, alice, []string{alice.Seed()}, build.SetOptions(build.AddSigner(preAuth, 1))))
+	acc, _ = srv.Ledger.Account(alice.Address())
+	require.Len(t, acc.Signers, 2)
+	require.Equal(t, "preauth_tx", acc.Signers[0].Type)
+
+	// it needs no signature, and its signer is removed once used
+	txe, err := pay.Sign()
+	require.NoError(t, err)
+	txeB64, err := txe.Base64()
+	require.NoError(t, err)
+	_, err = client.SubmitTransaction(txeB64)
+	require.NoError(t, err)
+
+	acc, _ = srv.Ledger.Account(alice.Address())
+	require.Len(t, acc.Signers, 1)
+	require.Equal(t, int32(0), acc.SubentryCount)
+	acc, _ = srv.Ledger.Account(bob.Address())
+	require.Equal(t, "105.0000000", acc.GetNativeBalance())
+}
+
+func TestSurgePricing(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	alice, bob := randomKP(t), randomKP(t)
+	require.NoError(t, srv.Ledger.Fund(alice.Address(), "100"))
+	require.NoError(t, srv.Ledger.Fund(bob.Address(), "100"))
+
+	stats, err := fee.Load(client)
+	require.NoError(t, err)
+	require.False(t, stats.Surge())
+
+	srv.Ledger.SetSurgeFee(500)
+	stats, err = fee.Load(client)
+	require.NoError(t, err)
+	require.True(t, stats.Surge())
+	require.Equal(t, uint64(500), stats.Fee(fee.Auto))
+
+	pay := build.Payment(build.Destination{AddressOrSeed: bob.Address()}, build.NativeAmount{Amount: "5"})
+	err = submit(t, client, alice, []string{alice.Seed()}, pay)
+	require.Equal(t, "tx_insufficient_fee", resultCodes(t, err).TransactionCode)
+	require.NoError(t, submit(t, client, alice, []string{alice.Seed()}, pay, build.BaseFee{Amount: 500}))
+}
@@ -0,0 +1,262 @@
+package horizontest
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// Path is a way to convert an asset into another one through the order
+// books, as returned by the path finding endpoints
+type Path struct {
+	SourceAssetType        string          `json:"source_asset_type"`
+	SourceAssetCode        string          `json:"source_asset_code,omitempty"`
+	SourceAssetIssuer      string          `json:"source_asset_issuer,omitempty"`
+	SourceAmount           string          `json:"source_amount"`
+	DestinationAssetType   string          `json:"destination_asset_type"`
+	DestinationAssetCode   string          `json:"destination_asset_code,omitempty"`
+	DestinationAssetIssuer string          `json:"destination_asset_issuer,omitempty"`
+	DestinationAmount      string          `json:"destination_amount"`
+	Path                   []horizon.Asset `json:"path"`
+}
+
+// Paths finds the paths converting one of sources into one of destinations,
+// going through at most one other asset. With send, amt of the source asset
+// is spent, otherwise amt of the destination asset is received. The paths
+// the order books can not fill are left out.
+func (l *Ledger) Paths(sources, destinations []xdr.Asset, amt xdr.Int64, send bool) []Path {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// the assets of the order books, through which a path can go
+	var traded []xdr.Asset
+	for _, o := range l.state.offers {
+		for _, a := range []xdr.Asset{o.selling, o.buying} {
+			if !containsAsset(traded, a) {
+				traded = append(traded, a)
+			}
+		}
+	}
+
+	var paths []Path
+	for _, src := range sources {
+		for _, dst := range destinations {
+			if src.Equals(dst) {
+				paths = append(paths, newPath(src, dst, amt, amt, nil))
+				continue
+			}
+
+			candidates := [][]xdr.Asset{nil}
+			for _, via := range traded {
+				if !via.Equals(src) && !via.Equals(dst) {
+					candidates = append(candidates, []xdr.Asset{via})
+				}
+			}
+
+			for _, via := range candidates {
+				if other, ok := l.state.convert(append(append([]xdr.Asset{src}, via...), dst), amt, send); ok {
+					if send {
+						paths = append(paths, newPath(src, dst, amt, other, via))
+					} else {
+						paths = append(paths, newPath(src, dst, other, amt, via))
+					}
+				}
+			}
+		}
+	}
+
+	return paths
+}
+
+// convert walks the assets of hops, spending amt of the first one when send
+// is set and returning the amount of the last one received, or receiving amt
+// of the last one and returning the amount of the first one spent
+func (s *state) convert(hops []xdr.Asset, amt xdr.Int64, send bool) (xdr.Int64, bool) {
+	if send {
+		for i := 0; i < len(hops)-1; i++ {
+			var ok bool
+			if amt, ok = s.fill(hops[i], hops[i+1], amt, true); !ok {
+				return 0, false
+			}
+		}
+		return amt, true
+	}
+
+	for i := len(hops) - 1; i > 0; i-- {
+		var ok bool
+		if amt, ok = s.fill(hops[i-1], hops[i], amt, false); !ok {
+			return 0, false
+		}
+	}
+	return amt, true
+}
+
+// fill converts from into to with the offers selling to, the best prices
+// first. With send, amt of from is spent and the amount of to received is
+// returned, otherwise amt of to is received and the amount of from spent is returned.
+func (s *state) fill(from, to xdr.Asset, amt xdr.Int64, send bool) (xdr.Int64, bool) {
+	var offers []*offer
+	for _, o := range s.offers {
+		if o.selling.Equals(to) && o.buying.Equals(from) {
+			offers = append(offers, o)
+		}
+	}
+	sort.SliceStable(offers, func(i, j int) bool { return offers[i].price() < offers[j].price() })
+
+	left, result := float64(amt), 0.0
+	for _, o := range offers {
+		// the price of an offer is in from per to
+		available := float64(o.amount)
+		if send {
+			available *= o.price()
+		}
+
+		take := math.Min(available, left)
+		left -= take
+		if send {
+			result += take / o.price()
+		} else {
+			result += take * o.price()
+		}
+
+		if left <= 0 {
+			return xdr.Int64(math.Round(result)), true
+		}
+	}
+
+	return 0, false
+}
+
+func newPath(src, dst xdr.Asset, srcAmount, dstAmount xdr.Int64, via []xdr.Asset) Path {
+	s, d := horizonAsset(src), horizonAsset(dst)
+	p := Path{
+		SourceAssetType:        s.Type,
+		SourceAssetCode:        s.Code,
+		SourceAssetIssuer:      s.Issuer,
+		SourceAmount:           amount.String(srcAmount),
+		DestinationAssetType:   d.Type,
+		DestinationAssetCode:   d.Code,
+		DestinationAssetIssuer: d.Issuer,
+		DestinationAmount:      amount.String(dstAmount),
+		Path:                   []horizon.Asset{},
+	}
+	for _, a := range via {
+		p.Path = append(p.Path, horizonAsset(a))
+	}
+
+	return p
+}
+
+func containsAsset(list []xdr.Asset, a xdr.Asset) bool {
+	for _, b := range list {
+		if b.Equals(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// paths serves the strict send and strict receive path finding endpoints.
+// The destination, or source, assets are the ones of an account or a list.
+func (l *Ledger) paths(w http.ResponseWriter, q url.Values, send bool) {
+	badRequest := func(detail string) {
+		writeProblem(w, horizon.Problem{Type: "bad_request", Title: "Bad Request", Status: http.StatusBadRequest, Detail: detail})
+	}
+
+	fixed, other, amountParam := "source", "destination", "source_amount"
+	if !send {
+		fixed, other, amountParam = "destination", "source", "destination_amount"
+	}
+
+	asset, err := parseAsset(q.Get(fixed+"_asset_type"), q.Get(fixed+"_asset_code"), q.Get(fixed+"_asset_issuer"))
+	if err != nil {
+		badRequest(err.Error())
+		return
+	}
+
+	amt, err := amount.Parse(q.Get(amountParam))
+	if err != nil || amt <= 0 {
+		badRequest("invalid " + amountParam)
+		return
+	}
+
+	var others []xdr.Asset
+	if account := q.Get(other + "_account"); account != "" {
+		var ok bool
+		if others, ok = l.assets(account); !ok {
+			notFound(w)
+			return
+		}
+	} else {
+		// such as native,MOBI:GA6HCMBLTZS5VYYBCATRBRZ3BZJMAFUDKYYF6AH6MVCMGWMRDNSWJPIH
+		for _, s := range strings.Split(q.Get(other+"_assets"), ",") {
+			typ, code, issuer := s, "", ""
+			if parts := strings.SplitN(s, ":", 2); len(parts) == 2 {
+				typ, code, issuer = "credit", parts[0], parts[1]
+			}
+
+			a, err := parseAsset(typ, code, issuer)
+			if err != nil {
+				badRequest(err.Error())
+				return
+			}
+			others = append(others, a)
+		}
+	}
+
+	var page struct {
+		Embedded struct {
+			Records []Path `json:"records"`
+		} `json:"_embedded"`
+	}
+	if send {
+		page.Embedded.Records = l.Paths([]xdr.Asset{asset}, others, amt, true)
+	} else {
+		page.Embedded.Records = l.Paths(others, []xdr.Asset{asset}, amt, false)
+	}
+	if page.Embedded.Records == nil {
+		page.Embedded.Records = []Path{}
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// assets returns the assets held by address, and whether the account exists
+func (l *Ledger) assets(address string) ([]xdr.Asset, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	acc, ok := l.state.accounts[address]
+	if !ok {
+		return nil, false
+	}
+
+	var assets []xdr.Asset
+	for _, b := range acc.balances {
+		assets = append(assets, b.asset)
+	}
+	return assets, true
+}
+
+func parseAsset(typ, code, issuer string) (xdr.Asset, error) {
+	var a xdr.Asset
+	if typ == "native" {
+		return a, a.SetNative()
+	}
+
+	var id xdr.AccountId
+	if err := id.SetAddress(issuer); err != nil {
+		return a, fmt.Errorf("invalid issuer '%s'", issuer)
+	}
+	if err := a.SetCredit(code, id); err != nil {
+		return a, fmt.Errorf("invalid asset code '%s'", code)
+	}
+	return a, nil
+}
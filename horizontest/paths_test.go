@@ -0,0 +1,69 @@
+package horizontest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stellar/go/build"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaths(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	issuer, alice, bob := randomKP(t), randomKP(t), randomKP(t)
+	for _, kp := range []string{issuer.Address(), alice.Address(), bob.Address()} {
+		require.NoError(t, srv.Ledger.Fund(kp, "100"))
+	}
+
+	usd, eur := build.CreditAsset("USD", issuer.Address()), build.CreditAsset("EUR", issuer.Address())
+	require.NoError(t, submit(t, client, alice, []string{alice.Seed()}, build.Trust("USD", issuer.Address()), build.Trust("EUR", issuer.Address())))
+	require.NoError(t, submit(t, client, bob, []string{bob.Seed()}, build.Trust("EUR", issuer.Address())))
+	require.NoError(t, submit(t, client, issuer, []string{issuer.Seed()},
+		build.Payment(build.Destination{AddressOrSeed: alice.Address()}, build.CreditAmount{Code: "USD", Issuer: issuer.Address(), Amount: "50"}),
+		build.Payment(build.Destination{AddressOrSeed: alice.Address()}, build.CreditAmount{Code: "EUR", Issuer: issuer.Address(), Amount: "50"}),
+	))
+
+	// 20 USD at 2 XLM each, 10 EUR at 1.25 USD each
+	require.NoError(t, submit(t, client, alice, []string{alice.Seed()},
+		build.CreateOffer(build.Rate{Selling: usd, Buying: build.NativeAsset(), Price: "2"}, "20"),
+		build.CreateOffer(build.Rate{Selling: eur, Buying: usd, Price: "1.25"}, "10"),
+	))
+
+	get := func(endpoint string) []Path {
+		resp, err := client.HTTP.Get(srv.URL + endpoint)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var page struct {
+			Embedded struct {
+				Records []Path `json:"records"`
+			} `json:"_embedded"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		return page.Embedded.Records
+	}
+
+	// bob holds XLM and EUR, 10 XLM buy 5 USD which buy 4 EUR
+	paths := get("/paths/strict-send?source_asset_type=native&source_amount=10&destination_account=" + bob.Address())
+	require.Len(t, paths, 2)
+	require.Equal(t, "native", paths[0].DestinationAssetType)
+	require.Equal(t, "10.0000000", paths[0].DestinationAmount)
+	require.Empty(t, paths[0].Path)
+	require.Equal(t, "EUR", paths[1].DestinationAssetCode)
+	require.Equal(t, "4.0000000", paths[1].DestinationAmount)
+	require.Len(t, paths[1].Path, 1)
+	require.Equal(t, "USD", paths[1].Path[0].Code)
+
+	// receiving 4 EUR costs 10 XLM, the order book can not fill 20 EUR
+	paths = get("/paths/strict-receive?destination_asset_type=credit_alphanum4&destination_asset_code=EUR&destination_asset_issuer=" + issuer.Address() + "&destination_amount=4&source_assets=native")
+	require.Len(t, paths, 1)
+	require.Equal(t, "10.0000000", paths[0].SourceAmount)
+
+	paths = get("/paths/strict-receive?destination_asset_type=credit_alphanum4&destination_asset_code=EUR&destination_asset_issuer=" + issuer.Address() + "&destination_amount=20&source_assets=native")
+	require.Empty(t, paths)
+}
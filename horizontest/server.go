@@ -0,0 +1,154 @@
+package horizontest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// FriendbotAmount is the amount of XLM given by the friendbot endpoint
+const FriendbotAmount = "10000"
+
+// Server is a horizon server backed by a Ledger, listening on a local address
+type Server struct {
+	*httptest.Server
+	Ledger *Ledger
+}
+
+// NewServer starts a server backed by an empty ledger of the test network.
+// It should be closed when done.
+func NewServer() *Server {
+	ledger := NewLedger(network.TestNetworkPassphrase)
+	return &Server{Server: httptest.NewServer(ledger), Ledger: ledger}
+}
+
+// Client returns a horizon client of the server
+func (s *Server) Client() *horizon.Client {
+	return &horizon.Client{URL: s.URL, HTTP: s.Server.Client()}
+}
+
+// ServeHTTP implements http.Handler with the endpoints of horizon used by alfred
+func (l *Ledger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.URL.Path == "/":
+		l.mu.Lock()
+		root := horizon.Root{
+			HorizonVersion:    "horizontest",
+			HorizonSequence:   l.sequence,
+			CoreSequence:      l.sequence,
+			NetworkPassphrase: l.Passphrase,
+		}
+		l.mu.Unlock()
+		writeJSON(w, http.StatusOK, root)
+	case len(parts) == 2 && parts[0] == "accounts" && r.Method == "GET":
+		acc, ok := l.Account(parts[1])
+		if !ok {
+			notFound(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, acc)
+	case len(parts) == 3 && parts[0] == "accounts" && parts[2] == "offers" && r.Method == "GET":
+		var page horizon.OffersPage
+		page.Embedded.Records = l.Offers(parts[1])
+		writeJSON(w, http.StatusOK, page)
+	case len(parts) == 1 && parts[0] == "transactions" && r.Method == "POST":
+		l.submit(w, r.FormValue("tx"))
+	case len(parts) == 2 && parts[0] == "transactions" && r.Method == "GET":
+		tx, ok := l.Transaction(parts[1])
+		if !ok {
+			notFound(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, tx)
+	case len(parts) == 1 && parts[0] == "order_book" && r.Method == "GET":
+		q := r.URL.Query()
+		selling := horizon.Asset{Type: q.Get("selling_asset_type"), Code: q.Get("selling_asset_code"), Issuer: q.Get("selling_asset_issuer")}
+		buying := horizon.Asset{Type: q.Get("buying_asset_type"), Code: q.Get("buying_asset_code"), Issuer: q.Get("buying_asset_issuer")}
+		writeJSON(w, http.StatusOK, l.OrderBook(selling, buying))
+	case len(parts) == 2 && parts[0] == "paths" && (parts[1] == "strict-send" || parts[1] == "strict-receive") && r.Method == "GET":
+		l.paths(w, r.URL.Query(), parts[1] == "strict-send")
+	case len(parts) == 1 && parts[0] == "fee_stats" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, l.FeeStats())
+	case len(parts) == 1 && parts[0] == "friendbot":
+		addr := r.FormValue("addr")
+		if _, ok := l.Account(addr); ok {
+			writeProblem(w, horizon.Problem{Type: "bad_request", Title: "Bad Request", Status: http.StatusBadRequest, Detail: "account already funded"})
+			return
+		}
+		if err := l.Fund(addr, FriendbotAmount); err != nil {
+			writeProblem(w, horizon.Problem{Type: "bad_request", Title: "Bad Request", Status: http.StatusBadRequest, Detail: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"account": addr})
+	default:
+		notFound(w)
+	}
+}
+
+func (l *Ledger) submit(w http.ResponseWriter, txeB64 string) {
+	var txe xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(txeB64, &txe); err != nil {
+		writeProblem(w, horizon.Problem{
+			Type:   "transaction_malformed",
+			Title:  "Transaction Malformed",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+		})
+		return
+	}
+
+	tx, err := l.Submit(txe)
+	if err != nil {
+		txErr, ok := err.(*TxError)
+		if !ok {
+			writeProblem(w, horizon.Problem{Type: "server_error", Title: "Internal Server Error", Status: http.StatusInternalServerError, Detail: err.Error()})
+			return
+		}
+
+		codes, _ := json.Marshal(horizon.TransactionResultCodes{TransactionCode: txErr.Code, OperationCodes: txErr.Operations})
+		envelope, _ := json.Marshal(txeB64)
+		writeProblem(w, horizon.Problem{
+			Type:   "transaction_failed",
+			Title:  "Transaction Failed",
+			Status: http.StatusBadRequest,
+			Detail: "The transaction failed when submitted to the stellar network.",
+			Extras: map[string]json.RawMessage{
+				"envelope_xdr": envelope,
+				"result_codes": codes,
+			},
+		})
+		return
+	}
+
+	var resp horizon.TransactionSuccess
+	resp.Hash = tx.Hash
+	resp.Ledger = tx.Ledger
+	resp.Env = tx.EnvelopeXdr
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func notFound(w http.ResponseWriter) {
+	writeProblem(w, horizon.Problem{
+		Type:   "not_found",
+		Title:  "Resource Missing",
+		Status: http.StatusNotFound,
+		Detail: "The resource at the url requested was not found.",
+	})
+}
+
+func writeProblem(w http.ResponseWriter, p horizon.Problem) {
+	writeJSON(w, p.Status, p)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
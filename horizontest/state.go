@@ -0,0 +1,598 @@
+package horizontest
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+const opSuccess = "op_success"
+
+// threshold levels
+const (
+	thresholdLow = iota
+	thresholdMed
+	thresholdHigh
+)
+
+// state holds the entries of the ledger
+type state struct {
+	accounts    map[string]*account
+	offers      []*offer
+	lastOfferID int64
+}
+
+type account struct {
+	id  string
+	seq xdr.SequenceNumber
+	// balances start with the native balance
+	balances     []*balance
+	masterWeight int32
+	// thresholds are the low, medium and high thresholds
+	thresholds [3]uint32
+	signers    []signer
+	data       map[string][]byte
+	subentries int32
+	homeDomain string
+}
+
+type balance struct {
+	asset  xdr.Asset
+	amount xdr.Int64
+	// limit is zero for the native balance
+	limit xdr.Int64
+}
+
+type signer struct {
+	key    string
+	weight int32
+}
+
+type offer struct {
+	id              int64
+	seller          string
+	selling, buying xdr.Asset
+	amount          xdr.Int64
+	p               xdr.Price
+}
+
+func newAccount(id string, native xdr.Int64, ledger int32) *account {
+	var asset xdr.Asset
+	asset.SetNative()
+
+	return &account{
+		id:           id,
+		seq:          xdr.SequenceNumber(int64(ledger) << 32),
+		balances:     []*balance{{asset: asset, amount: native}},
+		masterWeight: 1,
+		data:         make(map[string][]byte),
+	}
+}
+
+func (acc *account) native() *balance {
+	return acc.balances[0]
+}
+
+func (acc *account) balance(asset xdr.Asset) *balance {
+	for _, b := range acc.balances {
+		if b.asset.Equals(asset) {
+			return b
+		}
+	}
+	return nil
+}
+
+// minBalance is the amount of XLM the account cannot spend
+func (acc *account) minBalance() xdr.Int64 {
+	return xdr.Int64(2+acc.subentries) * BaseReserve
+}
+
+// canAddSubentry reports whether the account has enough XLM for one more entry
+func (acc *account) canAddSubentry() bool {
+	return acc.native().amount >= acc.minBalance()+BaseReserve
+}
+
+// available is the amount of asset the account can spend
+func (acc *account) available(asset xdr.Asset) xdr.Int64 {
+	b := acc.balance(asset)
+	if b == nil {
+		return 0
+	}
+
+	if asset.Type == xdr.AssetTypeAssetTypeNative {
+		return b.amount - acc.minBalance()
+	}
+	return b.amount
+}
+
+// debit removes amt of asset, the issuer of the asset can send any amount
+func (acc *account) debit(asset xdr.Asset, amt xdr.Int64) string {
+	if issuer(asset) == acc.id {
+		return opSuccess
+	}
+
+	b := acc.balance(asset)
+	switch {
+	case b == nil:
+		return "op_src_no_trust"
+	case acc.available(asset) < amt:
+		return "op_underfunded"
+	}
+
+	b.amount -= amt
+	return opSuccess
+}
+
+// credit adds amt of asset, within the limit of the trustline
+func (acc *account) credit(asset xdr.Asset, amt xdr.Int64) string {
+	if issuer(asset) == acc.id {
+		return opSuccess
+	}
+
+	b := acc.balance(asset)
+	switch {
+	case b == nil:
+		return "op_no_trust"
+	case b.limit > 0 && b.amount+amt > b.limit:
+		return "op_line_full"
+	}
+
+	b.amount += amt
+	return opSuccess
+}
+
+// weight returns the weight of the signatures of hash made by the signers of acc
+func (acc *account) weight(hash [32]byte, sigs []xdr.DecoratedSignature) int32 {
+	signers := append([]signer{{key: acc.id, weight: acc.masterWeight}}, acc.signers...)
+
+	preAuth := preAuthKey(hash)
+	var total int32
+	for _, s := range signers {
+		if s.weight == 0 {
+			continue
+		}
+		if s.key == preAuth {
+			total += s.weight
+			continue
+		}
+
+		kp, err := keypair.Parse(s.key)
+		if err != nil {
+			continue
+		}
+
+		hint := kp.Hint()
+		for _, sig := range sigs {
+			if [4]byte(sig.Hint) == hint && kp.Verify(hash[:], sig.Signature) == nil {
+				total += s.weight
+				break
+			}
+		}
+	}
+
+	return total
+}
+
+// preAuthKey returns the signer key of the transaction with hash, which is
+// authorized without signatures
+func preAuthKey(hash [32]byte) string {
+	return strkey.MustEncode(strkey.VersionByteHashTx, hash[:])
+}
+
+// removePreAuth removes the signers authorizing the transaction with hash,
+// once it consumed its sequence number
+func (s *state) removePreAuth(hash [32]byte) {
+	key := preAuthKey(hash)
+	for _, acc := range s.accounts {
+		for i, signer := range acc.signers {
+			if signer.key == key {
+				acc.signers = append(acc.signers[:i], acc.signers[i+1:]...)
+				acc.subentries--
+				break
+			}
+		}
+	}
+}
+
+func (acc *account) horizon() horizon.Account {
+	h := horizon.Account{
+		HistoryAccount: horizon.HistoryAccount{ID: acc.id, PT: acc.id, AccountID: acc.id},
+		Sequence:       fmt.Sprint(int64(acc.seq)),
+		SubentryCount:  acc.subentries,
+		HomeDomain:     acc.homeDomain,
+		Thresholds: horizon.AccountThresholds{
+			LowThreshold:  byte(acc.thresholds[thresholdLow]),
+			MedThreshold:  byte(acc.thresholds[thresholdMed]),
+			HighThreshold: byte(acc.thresholds[thresholdHigh]),
+		},
+		Data: make(map[string]string),
+	}
+
+	for _, b := range acc.balances {
+		hb := horizon.Balance{Balance: amount.String(b.amount), Asset: horizonAsset(b.asset)}
+		if b.limit > 0 {
+			hb.Limit = amount.String(b.limit)
+		}
+		h.Balances = append(h.Balances, hb)
+	}
+
+	for _, s := range acc.signers {
+		typ := "ed25519_public_key"
+		if v, err := strkey.Version(s.key); err == nil && v == strkey.VersionByteHashTx {
+			typ = "preauth_tx"
+		}
+		h.Signers = append(h.Signers, horizon.Signer{PublicKey: s.key, Key: s.key, Weight: s.weight, Type: typ})
+	}
+	h.Signers = append(h.Signers, horizon.Signer{PublicKey: acc.id, Key: acc.id, Weight: acc.masterWeight, Type: "ed25519_public_key"})
+
+	for k, v := range acc.data {
+		h.Data[k] = base64.StdEncoding.EncodeToString(v)
+	}
+
+	return h
+}
+
+func (o *offer) price() float64 {
+	return float64(o.p.N) / float64(o.p.D)
+}
+
+func (o *offer) horizon() horizon.Offer {
+	return horizon.Offer{
+		ID:      o.id,
+		PT:      fmt.Sprint(o.id),
+		Seller:  o.seller,
+		Selling: horizonAsset(o.selling),
+		Buying:  horizonAsset(o.buying),
+		Amount:  amount.String(o.amount),
+		PriceR:  horizon.Price{N: int32(o.p.N), D: int32(o.p.D)},
+		Price:   o.p.String(),
+	}
+}
+
+// clone returns a deep copy of s, on which operations can be applied without
+// changing s if one of them fails
+func (s *state) clone() *state {
+	c := &state{
+		accounts:    make(map[string]*account, len(s.accounts)),
+		lastOfferID: s.lastOfferID,
+	}
+
+	for id, acc := range s.accounts {
+		cp := *acc
+		cp.balances = nil
+		for _, b := range acc.balances {
+			bcp := *b
+			cp.balances = append(cp.balances, &bcp)
+		}
+		cp.signers = append([]signer(nil), acc.signers...)
+		cp.data = make(map[string][]byte, len(acc.data))
+		for k, v := range acc.data {
+			cp.data[k] = v
+		}
+		c.accounts[id] = &cp
+	}
+
+	for _, o := range s.offers {
+		cp := *o
+		c.offers = append(c.offers, &cp)
+	}
+
+	return c
+}
+
+// authorized reports whether the signatures of txe meet the thresholds of the
+// source account of the transaction and of its operations
+func (s *state) authorized(hash [32]byte, txe xdr.TransactionEnvelope) bool {
+	needed := map[string]int{txe.Tx.SourceAccount.Address(): thresholdLow}
+	for _, op := range txe.Tx.Operations {
+		source := txe.Tx.SourceAccount.Address()
+		if op.SourceAccount != nil {
+			source = op.SourceAccount.Address()
+		}
+
+		level := opThreshold(op)
+		if current, ok := needed[source]; !ok || level > current {
+			needed[source] = level
+		}
+	}
+
+	for id, level := range needed {
+		acc, ok := s.accounts[id]
+		if !ok {
+			// the operation fails with op_no_source_account
+			continue
+		}
+
+		weight := acc.weight(hash, txe.Signatures)
+		if weight == 0 || uint32(weight) < acc.thresholds[level] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func opThreshold(op xdr.Operation) int {
+	switch op.Body.Type {
+	case xdr.OperationTypeAccountMerge:
+		return thresholdHigh
+	case xdr.OperationTypeAllowTrust, xdr.OperationTypeInflation:
+		return thresholdLow
+	case xdr.OperationTypeSetOptions:
+		o := op.Body.SetOptionsOp
+		if o.MasterWeight != nil || o.LowThreshold != nil || o.MedThreshold != nil || o.HighThreshold != nil || o.Signer != nil {
+			return thresholdHigh
+		}
+	}
+	return thresholdMed
+}
+
+// apply applies the operations of tx, it returns their result codes and
+// whether one of them failed
+func (s *state) apply(tx xdr.Transaction, ledger int32) ([]string, bool) {
+	var (
+		codes  []string
+		failed bool
+	)
+
+	for _, op := range tx.Operations {
+		source := tx.SourceAccount.Address()
+		if op.SourceAccount != nil {
+			source = op.SourceAccount.Address()
+		}
+
+		code := "op_no_source_account"
+		if src, ok := s.accounts[source]; ok {
+			code = s.applyOp(src, op.Body, ledger)
+		}
+
+		codes = append(codes, code)
+		failed = failed || code != opSuccess
+	}
+
+	return codes, failed
+}
+
+func (s *state) applyOp(src *account, body xdr.OperationBody, ledger int32) string {
+	switch body.Type {
+	case xdr.OperationTypeCreateAccount:
+		return s.createAccount(src, body.CreateAccountOp, ledger)
+	case xdr.OperationTypePayment:
+		return s.payment(src, body.PaymentOp)
+	case xdr.OperationTypeManageOffer:
+		return s.manageOffer(src, *body.ManageOfferOp)
+	case xdr.OperationTypeCreatePassiveOffer:
+		o := body.CreatePassiveOfferOp
+		return s.manageOffer(src, xdr.ManageOfferOp{Selling: o.Selling, Buying: o.Buying, Amount: o.Amount, Price: o.Price})
+	case xdr.OperationTypeSetOptions:
+		return s.setOptions(src, body.SetOptionsOp)
+	case xdr.OperationTypeChangeTrust:
+		return s.changeTrust(src, body.ChangeTrustOp)
+	case xdr.OperationTypeAccountMerge:
+		return s.accountMerge(src, body.Destination.Address())
+	case xdr.OperationTypeManageData:
+		return s.manageData(src, body.ManageDataOp)
+	}
+
+	return "op_not_supported"
+}
+
+func (s *state) createAccount(src *account, op *xdr.CreateAccountOp, ledger int32) string {
+	dest := op.Destination.Address()
+	switch {
+	case op.StartingBalance <= 0:
+		return "op_malformed"
+	case s.accounts[dest] != nil:
+		return "op_already_exists"
+	case op.StartingBalance < 2*BaseReserve:
+		return "op_low_reserve"
+	}
+
+	var native xdr.Asset
+	native.SetNative()
+	if code := src.debit(native, op.StartingBalance); code != opSuccess {
+		return code
+	}
+
+	s.accounts[dest] = newAccount(dest, op.StartingBalance, ledger)
+	return opSuccess
+}
+
+func (s *state) payment(src *account, op *xdr.PaymentOp) string {
+	dest, ok := s.accounts[op.Destination.Address()]
+	switch {
+	case op.Amount <= 0:
+		return "op_malformed"
+	case !ok:
+		return "op_no_destination"
+	}
+
+	if code := src.debit(op.Asset, op.Amount); code != opSuccess {
+		return code
+	}
+
+	return dest.credit(op.Asset, op.Amount)
+}
+
+func (s *state) changeTrust(src *account, op *xdr.ChangeTrustOp) string {
+	switch {
+	case op.Line.Type == xdr.AssetTypeAssetTypeNative || op.Limit < 0:
+		return "op_malformed"
+	case issuer(op.Line) == src.id:
+		return "op_self_not_allowed"
+	}
+
+	b := src.balance(op.Line)
+	switch {
+	case b == nil && op.Limit == 0:
+		return "op_invalid_limit"
+	case b == nil:
+		if !src.canAddSubentry() {
+			return "op_low_reserve"
+		}
+		src.balances = append(src.balances, &balance{asset: op.Line, limit: op.Limit})
+		src.subentries++
+	case op.Limit < b.amount:
+		return "op_invalid_limit"
+	case op.Limit == 0:
+		for i, other := range src.balances {
+			if other == b {
+				src.balances = append(src.balances[:i], src.balances[i+1:]...)
+				break
+			}
+		}
+		src.subentries--
+	default:
+		b.limit = op.Limit
+	}
+
+	return opSuccess
+}
+
+func (s *state) manageOffer(src *account, op xdr.ManageOfferOp) string {
+	if op.Amount < 0 || op.Price.N <= 0 || op.Price.D <= 0 || op.Selling.Equals(op.Buying) {
+		return "op_malformed"
+	}
+
+	if op.OfferId != 0 {
+		for i, o := range s.offers {
+			if o.id != int64(op.OfferId) || o.seller != src.id {
+				continue
+			}
+
+			if op.Amount == 0 {
+				s.offers = append(s.offers[:i], s.offers[i+1:]...)
+				src.subentries--
+				return opSuccess
+			}
+
+			o.selling, o.buying, o.amount, o.p = op.Selling, op.Buying, op.Amount, op.Price
+			return opSuccess
+		}
+		return "op_offer_not_found"
+	}
+
+	switch {
+	case op.Amount == 0:
+		return "op_malformed"
+	case op.Selling.Type != xdr.AssetTypeAssetTypeNative && issuer(op.Selling) != src.id && src.balance(op.Selling) == nil:
+		return "op_sell_no_trust"
+	case op.Buying.Type != xdr.AssetTypeAssetTypeNative && issuer(op.Buying) != src.id && src.balance(op.Buying) == nil:
+		return "op_buy_no_trust"
+	case issuer(op.Selling) != src.id && src.available(op.Selling) <= 0:
+		return "op_underfunded"
+	case !src.canAddSubentry():
+		return "op_low_reserve"
+	}
+
+	s.lastOfferID++
+	s.offers = append(s.offers, &offer{
+		id:      s.lastOfferID,
+		seller:  src.id,
+		selling: op.Selling,
+		buying:  op.Buying,
+		amount:  op.Amount,
+		p:       op.Price,
+	})
+	src.subentries++
+
+	return opSuccess
+}
+
+func (s *state) setOptions(src *account, op *xdr.SetOptionsOp) string {
+	if op.InflationDest != nil && s.accounts[op.InflationDest.Address()] == nil {
+		return "op_invalid_inflation"
+	}
+
+	if op.MasterWeight != nil {
+		src.masterWeight = int32(*op.MasterWeight)
+	}
+	for level, t := range []*xdr.Uint32{op.LowThreshold, op.MedThreshold, op.HighThreshold} {
+		if t != nil {
+			src.thresholds[level] = uint32(*t)
+		}
+	}
+	if op.HomeDomain != nil {
+		src.homeDomain = string(*op.HomeDomain)
+	}
+
+	if op.Signer == nil {
+		return opSuccess
+	}
+
+	key := op.Signer.Key.Address()
+	if key == src.id {
+		return "op_bad_signer"
+	}
+
+	weight := int32(op.Signer.Weight)
+	for i, existing := range src.signers {
+		if existing.key != key {
+			continue
+		}
+
+		if weight == 0 {
+			src.signers = append(src.signers[:i], src.signers[i+1:]...)
+			src.subentries--
+		} else {
+			src.signers[i].weight = weight
+		}
+		return opSuccess
+	}
+
+	if weight == 0 {
+		return opSuccess
+	}
+	if !src.canAddSubentry() {
+		return "op_low_reserve"
+	}
+
+	src.signers = append(src.signers, signer{key: key, weight: weight})
+	src.subentries++
+	return opSuccess
+}
+
+func (s *state) manageData(src *account, op *xdr.ManageDataOp) string {
+	name := string(op.DataName)
+	_, exists := src.data[name]
+
+	if op.DataValue == nil {
+		if !exists {
+			return "op_name_not_found"
+		}
+		delete(src.data, name)
+		src.subentries--
+		return opSuccess
+	}
+
+	if !exists {
+		if !src.canAddSubentry() {
+			return "op_low_reserve"
+		}
+		src.subentries++
+	}
+
+	src.data[name] = []byte(*op.DataValue)
+	return opSuccess
+}
+
+func (s *state) accountMerge(src *account, destination string) string {
+	dest, ok := s.accounts[destination]
+	switch {
+	case destination == src.id:
+		return "op_malformed"
+	case !ok:
+		return "op_no_account"
+	case src.subentries > 0:
+		return "op_has_sub_entries"
+	}
+
+	dest.native().amount += src.native().amount
+	delete(s.accounts, src.id)
+	return opSuccess
+}
@@ -0,0 +1,61 @@
+// Package httplog dumps the requests sent by an http client and the responses
+// received, with the secrets they may hold redacted: secret seeds, tokens,
+// passwords and the authorization and cookie headers.
+package httplog
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the secrets of a dump
+const Redacted = "REDACTED"
+
+var (
+	seedPattern   = regexp.MustCompile(`\bS[A-Z2-7]{55}\b`)
+	headerPattern = regexp.MustCompile(`(?im)^((?:proxy-)?authorization|cookie|set-cookie):[^\r\n]*`)
+	// fields such as secret=..., "token": "..." or password: ...
+	fieldPattern = regexp.MustCompile(`(?i)("?[a-z_]*(?:secret|seed|password|token|jwt)[a-z_]*"?\s*[:=]\s*"?)[^"&\s,}]+`)
+)
+
+// Redact returns dump with its secrets replaced by Redacted
+func Redact(dump string) string {
+	dump = headerPattern.ReplaceAllString(dump, "$1: "+Redacted)
+	dump = fieldPattern.ReplaceAllString(dump, "${1}"+Redacted)
+	return seedPattern.ReplaceAllString(dump, Redacted)
+}
+
+// Transport is an http.RoundTripper passing the dump of each request sent
+// through Next, and of its response, to Log. The body of streams is not
+// dumped, as it only ends with the stream.
+type Transport struct {
+	// Next sends the requests, http.DefaultTransport if nil
+	Next http.RoundTripper
+	// Log receives the redacted dumps
+	Log func(req *http.Request, resp *http.Response, dump string)
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if dump, err := httputil.DumpRequestOut(req, true); err == nil {
+		t.Log(req, nil, Redact(string(dump)))
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body := !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	if dump, err := httputil.DumpResponse(resp, body); err == nil {
+		t.Log(req, resp, Redact(string(dump)))
+	}
+	return resp, nil
+}
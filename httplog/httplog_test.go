@@ -0,0 +1,57 @@
+package httplog
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const seed = "SBZVMB74Z76QZ3ZOY7UTDFYKMEGKW5XFJEB6PFKBF4UYSSWHG4EDH7PY"
+
+func TestRedact(t *testing.T) {
+	for in, want := range map[string]string{
+		"Authorization: Bearer abc\r\nHost: x":     "Authorization: REDACTED\r\nHost: x",
+		"set-cookie: session=1":                    "set-cookie: REDACTED",
+		`{"token": "eyJhbGciOi", "account": "GA"}`: `{"token": "REDACTED", "account": "GA"}`,
+		"secret=s3cret&addr=GA":                    "secret=REDACTED&addr=GA",
+		"webhook_secret: abc":                      "webhook_secret: REDACTED",
+		"seed " + seed + " here":                   "seed REDACTED here",
+		"tx=AAAAAGL8HQvQkbK2HA3WVjRrKmjX00fG8sLI":  "tx=AAAAAGL8HQvQkbK2HA3WVjRrKmjX00fG8sLI",
+	} {
+		require.Equal(t, want, Redact(in), in)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Write([]byte(`{"hash": "abc", "seed": "` + r.Form.Get("tx") + `"}`))
+	}))
+	defer srv.Close()
+
+	var dumps []string
+	client := &http.Client{Transport: &Transport{Log: func(req *http.Request, resp *http.Response, dump string) {
+		dumps = append(dumps, dump)
+	}}}
+
+	resp, err := client.PostForm(srv.URL+"/transactions", url.Values{"tx": {seed}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// the body is still read by the client
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), seed)
+
+	require.Len(t, dumps, 2)
+	require.True(t, strings.HasPrefix(dumps[0], "POST /transactions HTTP/1.1"), dumps[0])
+	require.Contains(t, dumps[0], "tx=REDACTED")
+	require.True(t, strings.HasPrefix(dumps[1], "HTTP/1.1 200 OK"), dumps[1])
+	require.Contains(t, dumps[1], `"seed": "REDACTED"`)
+	require.NotContains(t, strings.Join(dumps, ""), seed)
+}
@@ -0,0 +1,267 @@
+package i18n
+
+func init() {
+	RegisterNumbers("fr", Numbers{Point: ",", Thousands: "\u00a0"})
+
+	Register("fr", Catalog{
+		// headers of the tables
+		"#":              "#",
+		"Account":        "Compte",
+		"Action":         "Action",
+		"Address":        "Adresse",
+		"Amount":         "Montant",
+		"Applied":        "Appliquée",
+		"Asset":          "Actif",
+		"Authorized":     "Autorisé",
+		"Balance":        "Solde",
+		"Bought":         "Acheté",
+		"Buying":         "Achat",
+		"Change":         "Variation",
+		"Created":        "Créée",
+		"Currency":       "Devise",
+		"Current":        "Actuel",
+		"Database":       "Base de données",
+		"Date":           "Date",
+		"Description":    "Description",
+		"Destination":    "Destination",
+		"Destinations":   "Destinations",
+		"Details":        "Détails",
+		"Effect":         "Effet",
+		"Expires":        "Expire",
+		"Field":          "Champ",
+		"Held locally":   "Détenue localement",
+		"Hint":           "Indice",
+		"Hops":           "Étapes",
+		"ID":             "ID",
+		"Issue":          "Problème",
+		"Key":            "Clé",
+		"Kind":           "Sorte",
+		"Last run":       "Dernière exécution",
+		"Limit":          "Limite",
+		"Max slippage":   "Glissement max",
+		"Memo":           "Mémo",
+		"Met locally":    "Atteint localement",
+		"Name":           "Nom",
+		"Network":        "Réseau",
+		"Next run":       "Prochaine exécution",
+		"Note":           "Note",
+		"Op":             "Op",
+		"Operation":      "Opération",
+		"Operations":     "Opérations",
+		"Path":           "Chemin",
+		"Price":          "Prix",
+		"Priority":       "Priorité",
+		"Rate":           "Taux",
+		"Receive":        "Reçu",
+		"Recover after":  "Récupérable après",
+		"Remediation":    "Correction",
+		"Repair":         "Réparation",
+		"Requested":      "Demandé",
+		"Result":         "Résultat",
+		"Rule":           "Règle",
+		"Seed":           "Seed",
+		"Seed lives at":  "Seed conservée à",
+		"Selling":        "Vente",
+		"Send":           "Envoi",
+		"Setting":        "Paramètre",
+		"Signer":         "Signataire",
+		"Size":           "Taille",
+		"Source":         "Source",
+		"Statement":      "Commande",
+		"Status":         "Statut",
+		"Strategy":       "Stratégie",
+		"Subject":        "Sujet",
+		"Threshold":      "Seuil",
+		"Token contract": "Contrat du jeton",
+		"Transaction":    "Transaction",
+		"Type":           "Type",
+		"Unlock after":   "Déverrouillé après",
+		"Valid":          "Valide",
+		"Value":          "Valeur",
+		"Variables":      "Variables",
+		"Version":        "Version",
+		"Wallet":         "Portefeuille",
+		"Weight":         "Poids",
+
+		// summaries and previews of the transactions
+		"Anchor fee":                            "Frais de l'ancre",
+		"Average price":                         "Prix moyen",
+		"Balance after":                         "Solde après",
+		"Estimated time":                        "Durée estimée",
+		"Fee":                                   "Frais",
+		"Operations:":                           "Opérations :",
+		"Passive":                               "Passive",
+		"Sequence":                              "Séquence",
+		"Starting balance":                      "Solde initial",
+		"Thresholds":                            "Seuils",
+		"Transaction:":                          "Transaction :",
+		"Valid from":                            "Valide à partir de",
+		"Valid until":                           "Valide jusqu'à",
+		"%s XLM (%d stroops for %d operations)": "%s XLM (%d stroops pour %d opérations)",
+
+		// prompts
+		"Another address":                    "Autre adresse",
+		"Are you sure":                       "Êtes-vous sûr",
+		"Choose currency":                    "Choisissez la devise",
+		"Contact's address":                  "Adresse du contact",
+		"Name of the contact":                "Nom du contact",
+		"Passphrase of %s":                   "Phrase secrète de %s",
+		"Password":                           "Mot de passe",
+		"Path of the new database":           "Chemin de la nouvelle base de données",
+		"Select Wallet":                      "Choisissez le portefeuille",
+		"What is the seed address ?":         "Quelle est la seed ?",
+		"What should be done?":               "Que faut-il faire ?",
+		"Withdraw to":                        "Retirer vers",
+		"length be greater than 8":           "la longueur doit dépasser 8",
+		"the passphrase should not be empty": "la phrase secrète ne doit pas être vide",
+
+		// errors
+		"%d check(s) failed":                      "%d vérification(s) en échec",
+		"%d transactions in the log, expected %d": "%d transactions dans le journal, %d attendues",
+		"%s already exists":                       "%s existe déjà",
+		"%s already trusts %s up to %s":           "%s fait déjà confiance à %s jusqu'à %s",
+		"%s and %s expect different memos but a transaction has a single memo, send them separately": "%s et %s attendent des mémos différents mais une transaction n'a qu'un mémo, envoyez-les séparément",
+		"%s does not accept deposits of %s":                                                                    "%s n'accepte pas les dépôts de %s",
+		"%s does not accept withdrawals of %s":                                                                 "%s n'accepte pas les retraits de %s",
+		"%s does not exist":                                                                                    "%s n'existe pas",
+		"%s does not exist, please fund it first":                                                              "%s n'existe pas, approvisionnez-le d'abord",
+		"%s does not publish the issuer of %s in its stellar.toml":                                             "%s ne publie pas l'émetteur de %s dans son stellar.toml",
+		"%s does not trust %s issued by %s":                                                                    "%s ne fait pas confiance à %s émis par %s",
+		"%s does not trust %s, add a trustline first with: alfred trust %s":                                    "%s ne fait pas confiance à %s, ajoutez d'abord une ligne de confiance avec : alfred trust %s",
+		"%s does not trust %s, add a trustline first with: trust %s up to %s on %s":                            "%s ne fait pas confiance à %s, ajoutez d'abord une ligne de confiance avec : trust %s up to %s on %s",
+		"%s has %s XLM, expected %s":                                                                           "%s a %s XLM, %s attendus",
+		"%s has no home domain, give the domain of %s with --domain":                                           "%s n'a pas de domaine, donnez le domaine de %s avec --domain",
+		"%s holds %s %s, send or sell them before removing the trustline":                                      "%s détient %s %s, envoyez-les ou vendez-les avant de retirer la ligne de confiance",
+		"%s holds %s %s, the limit of its trustline can not be below its balance":                              "%s détient %s %s, la limite de sa ligne de confiance ne peut pas être inférieure à son solde",
+		"%s is not a signer of the account":                                                                    "%s n'est pas un signataire du compte",
+		"%s needs to trust %s before receiving it, trust it first with: alfred trust %s %s":                    "%s doit faire confiance à %s avant de le recevoir, faites-lui d'abord confiance avec : alfred trust %s %s",
+		"%s requires a memo but a transaction has a single memo for all its recipients, send to it separately": "%s exige un mémo mais une transaction n'a qu'un mémo pour tous ses destinataires, envoyez-lui séparément",
+		"%s still controls %s unless the transaction is applied later: alfred rotate %s reuses the key saved as %s, or finishes the rotation if it went through":                  "%s contrôle toujours %s, sauf si la transaction est appliquée plus tard : alfred rotate %s réutilise la clé enregistrée sous %s, ou termine la rotation si elle est passée",
+		"%s trusts %s up to %s and holds %s, receiving %s would exceed the limit of its trustline: send at most %s or ask the recipient to raise it with: trust %s up to <limit>": "%s fait confiance à %s jusqu'à %s et détient %s, recevoir %s dépasserait la limite de sa ligne de confiance : envoyez au plus %s ou demandez au destinataire de la relever avec : trust %s up to <limite>",
+		"%s trusts %s up to %s, LOWER TRUST should go below it, raise it with: trust %s up to %s on %s":                                                                           "%s fait confiance à %s jusqu'à %s, LOWER TRUST doit descendre en dessous, relevez-la avec : trust %s up to %s on %s",
+		"%s, %s, is above the maximum of %s (NO HIGHER THAN or --max-price)":                                                                                                      "%s, %s, dépasse le maximum de %s (NO HIGHER THAN ou --max-price)",
+		"%s, %s, is below the minimum of %s (NO LOWER THAN or --min-price)":                                                                                                       "%s, %s, est sous le minimum de %s (NO LOWER THAN ou --min-price)",
+		"%s: %v":                                 "%s : %v",
+		"%v, %s is no longer marked as verified": "%v, %s n'est plus marqué comme vérifié",
+		"'%s' not found":                         "'%s' introuvable",
+		"'%v' does not exist":                    "'%v' n'existe pas",
+		"'%v' does not exist, fund it first":     "'%v' n'existe pas, approvisionnez-le d'abord",
+		"'%v' is not a wallet":                   "'%v' n'est pas un portefeuille",
+		"'%v' wallet not found%s":                "portefeuille '%v' introuvable%s",
+		"This chat can send %s XLM per day, %s XLM were already sent today.": "Cette discussion peut envoyer %s XLM par jour, %s XLM ont déjà été envoyés aujourd'hui.",
+		"Which asset? Such as: send %s XLM to %s":                            "Quel actif ? Par exemple : send %s XLM to %s",
+		"Which wallet pays? Such as: send %s %s from master to jennifer":     "Quel portefeuille paie ? Par exemple : send %s %s from master to jennifer",
+		"Who is paid? Such as: send %s %s from master to jennifer":           "Qui est payé ? Par exemple : send %s %s from master to jennifer",
+		"account %s does not exist":                                          "le compte %s n'existe pas",
+		"account '%s' does not exist":                                        "le compte '%s' n'existe pas",
+		"address not found for '%v'%s":                                       "aucune adresse pour '%v'%s",
+		"asset %v is not supported right now%s":                              "l'actif %v n'est pas pris en charge pour le moment%s",
+		"checksum invalid":                                                   "somme de contrôle invalide",
+		"contact '%s' not found%s\n":                                         "contact '%s' introuvable%s\n",
+		"could not paste the transaction: %v":                                "impossible de coller la transaction : %v",
+		"default wallet '%s' not found%s":                                    "portefeuille par défaut '%s' introuvable%s",
+		"destination '%s' not found%s":                                       "destination '%s' introuvable%s",
+		"destination account %s does not exist and needs to trust %v before receiving it, create it first with: send 2 XLM to %s": "le compte destinataire %s n'existe pas et doit faire confiance à %v avant de le recevoir, créez-le d'abord avec : send 2 XLM to %s",
+		"destination account %s needs to trust %v":                                    "le compte destinataire %s doit faire confiance à %v",
+		"escrow %d can not be submitted before %s":                                    "le séquestre %d ne peut pas être soumis avant %s",
+		"escrow %d is on %s, not %s":                                                  "le séquestre %d est sur %s, pas sur %s",
+		"escrow %d was not locked, its account is controlled by the wallet escrow-%d": "le séquestre %d n'a pas été verrouillé, son compte est contrôlé par le portefeuille escrow-%d",
+		"failed with %s, expected %s":                                                 "échec avec %s, %s attendu",
+		"high threshold is %d, expected 2":                                            "le seuil haut est %d, 2 attendu",
+		"horizon answered %s":                                                         "horizon a répondu %s",
+		"invalid amount '%s'":                                                         "montant '%s' invalide",
+		"invalid amount: %v":                                                          "montant invalide : %v",
+		"invalid chat id '%s'":                                                        "identifiant de discussion '%s' invalide",
+		"invalid destination in payment URI: %v":                                      "destination invalide dans l'URI de paiement : %v",
+		"invalid envelope: %v":                                                        "enveloppe invalide : %v",
+		"invalid escrow id '%s'":                                                      "identifiant de séquestre '%s' invalide",
+		"invalid field '%s', should be name=value":                                    "champ '%s' invalide, doit être nom=valeur",
+		"invalid invoice id '%s'":                                                     "identifiant de facture '%s' invalide",
+		"invalid memo-guard '%s', should be off, warn or block":                       "memo-guard '%s' invalide, doit être off, warn ou block",
+		"invalid pending transaction id '%s'":                                         "identifiant de transaction en attente '%s' invalide",
+		"invalid low balance '%s': %v":                                                "solde bas '%s' invalide : %v",
+		"invalid percentage '%s'":                                                     "pourcentage '%s' invalide",
+		"invalid reserve margin '%s'":                                                 "marge de réserve '%s' invalide",
+		"invalid result: %v":                                                          "résultat invalide : %v",
+		"invalid starting balance '%s'":                                               "solde initial '%s' invalide",
+		"invalid strategy id '%s'":                                                    "identifiant de stratégie '%s' invalide",
+		"invalid transaction: %v":                                                     "transaction invalide : %v",
+		"invalid value of '%s': %v":                                                   "valeur de '%s' invalide : %v",
+		"invalid value of data entry %s: %v":                                          "valeur de l'entrée de données %s invalide : %v",
+		"invalid variable '%s', should be name=value":                                 "variable '%s' invalide, doit être nom=valeur",
+		"invalid webhook '%s', it should be an http or https URL":                     "webhook '%s' invalide, ce doit être une URL http ou https",
+		"invoice %d is on %s, not on %s":                                              "la facture %d est sur %s, pas sur %s",
+		"key: '%s' does not have prefix: '%s'":                                        "clé : '%s' n'a pas le préfixe : '%s'",
+		"moving the account takes %d operations, more than the %d of a transaction: remove unused trustlines and data entries first": "déplacer le compte prend %d opérations, plus que les %d d'une transaction : retirez d'abord les lignes de confiance et les entrées de données inutilisées",
+		"no %s balance":               "aucun solde en %s",
+		"no data entry '%s' on %s":    "aucune entrée de données '%s' sur %s",
+		"not found on the %s network": "introuvable sur le réseau %s",
+		"nothing to send, %s%% of %s is below the smallest amount":                           "rien à envoyer, %s%% de %s est sous le plus petit montant",
+		"nothing to send, the balance of %s is %s":                                           "rien à envoyer, le solde de %s est %s",
+		"only a yaml config file can be changed, %s is not":                                  "seul un fichier de configuration yaml peut être modifié, %s n'en est pas un",
+		"payment denied by the policy of %s: %s (use --override-policy to send it anyway)":   "paiement refusé par la politique de %s : %s (utilisez --override-policy pour l'envoyer quand même)",
+		"pending transaction %d is on %s, not %s":                                            "la transaction en attente %d est sur %s, pas sur %s",
+		"issuer '%s' not found":                                                              "émetteur '%s' introuvable",
+		"neither %s nor %s exist, the rotation can not be finished":                          "ni %s ni %s n'existent, la rotation ne peut pas être terminée",
+		"no wallet tagged %s":                                                                "aucun portefeuille avec l'étiquette %s",
+		"none of the assets held by %s can be received by %s, give the asset of the payment": "aucun des actifs détenus par %s ne peut être reçu par %s, donnez l'actif du paiement",
+		"not a swap: %v": "pas un échange : %v",
+		"qr code does not contain an address: '%s'":                                                                            "le code QR ne contient pas d'adresse : '%s'",
+		"signer %s can not be part of an m of n account":                                                                       "le signataire %s ne peut pas faire partie d'un compte m sur n",
+		"signer %s is a %s, which can not be part of an m of n account":                                                        "le signataire %s est un %s, qui ne peut pas faire partie d'un compte m sur n",
+		"source account does exists, please fund it first":                                                                     "le compte source n'existe pas, approvisionnez-le d'abord",
+		"succeeded, expected %s":                                                                                               "réussi, %s attendu",
+		"the account has %d offer(s), cancel them first":                                                                       "le compte a %d offre(s), annulez-les d'abord",
+		"the account needs %s XLM to fund the new one, %s XLM more":                                                            "le compte a besoin de %s XLM pour approvisionner le nouveau, %s XLM de plus",
+		"the account would have %d signers, itself included, not %d":                                                           "le compte aurait %d signataires, lui compris, et non %d",
+		"the assets of %s need no authorization, require it with alfred account set %s --auth-required":                        "les actifs de %s ne nécessitent pas d'autorisation, exigez-la avec alfred account set %s --auth-required",
+		"the authorizations of %s can not be revoked, enable it with alfred account set %s --auth-revocable":                   "les autorisations de %s ne peuvent pas être révoquées, activez-le avec alfred account set %s --auth-revocable",
+		"the average price of %s is %s%% worse than the best price of %s, above the maximum slippage of %g%% (--max-slippage)": "le prix moyen de %s est %s%% moins bon que le meilleur prix de %s, au-delà du glissement maximum de %g%% (--max-slippage)",
+		"the challenge of %s should be signed right away, which a cold wallet can not do":                                      "le défi de %s doit être signé immédiatement, ce qu'un portefeuille froid ne peut pas faire",
+		"the envelope in the ledger differs from the recorded one":                                                             "l'enveloppe du registre diffère de celle enregistrée",
+		"the hardware key did not respond: %v":                                                                                 "la clé matérielle n'a pas répondu : %v",
+		"the key '%s' is too long to split its value into several entries":                                                     "la clé '%s' est trop longue pour répartir sa valeur sur plusieurs entrées",
+		"the limit of a new trustline should be above 0":                                                                       "la limite d'une nouvelle ligne de confiance doit être supérieure à 0",
+		"the maximum amount is %v":                                                                                             "le montant maximum est %v",
+		"the minimum amount is %v":                                                                                             "le montant minimum est %v",
+		"the passphrase of the custom network should be given, such as %s'Standalone Network ; February 2017'":                 "la phrase secrète du réseau personnalisé doit être donnée, comme %s'Standalone Network ; February 2017'",
+		"the transaction is pasted from the clipboard, %d other argument(s) expected at most, received %d":                     "la transaction est collée depuis le presse-papiers, %d autre(s) argument(s) attendu(s) au plus, %d reçu(s)",
+		"the values need %d operations, a transaction can only hold %d":                                                        "les valeurs nécessitent %d opérations, une transaction ne peut en contenir que %d",
+		"this should be a private key":                                                                                         "ce doit être une clé privée",
+		"transaction %d with the idempotency key %s is pending, resume it with: alfred pending retry %d":                       "la transaction %d avec la clé d'idempotence %s est en attente, reprenez-la avec : alfred pending retry %d",
+		"the horizon servers of the %s network should be given with --horizon":                                                 "les serveurs horizon du réseau %s doivent être donnés avec --horizon",
+		"the secret is already derived from a %s, disable it first":                                                            "le secret est déjà dérivé d'un %s, désactivez-le d'abord",
+		"the secret recovered does not decrypt %s: %v":                                                                         "le secret récupéré ne déchiffre pas %s : %v",
+		"the swap expired on %s":                                                                                               "l'échange a expiré le %s",
+		"the swap is not signed by its proposer %s on this network":                                                            "l'échange n'est pas signé par son auteur %s sur ce réseau",
+		"the swap is proposed to %s, which is not one of your wallets":                                                         "l'échange est proposé à %s, qui n'est pas l'un de vos portefeuilles",
+		"the transaction expired on %s":                                                                                        "la transaction a expiré le %s",
+		"the transaction is not valid before %s, use --wait to submit it then":                                                 "la transaction n'est pas valide avant %s, utilisez --wait pour la soumettre à ce moment",
+		"the unlock date %s is in the past":                                                                                    "la date de déverrouillage %s est passée",
+		"transaction %s of the log is not in the ledger":                                                                       "la transaction %s du journal n'est pas dans le registre",
+		"transaction '%s' not found on %s":                                                                                     "transaction '%s' introuvable sur %s",
+		"unable to check the policy of %s: %v":                                                                                 "impossible de vérifier la politique de %s : %v",
+		"unable to load the fee stats: %s":                                                                                     "impossible de charger les statistiques des frais : %s",
+		"unable to write the contacts: %v":                                                                                     "impossible d'écrire les contacts : %v",
+		"unexpected offers %+v":                                                                                                "offres inattendues %+v",
+		"unexpected status %s":                                                                                                 "statut inattendu %s",
+		"unknown argument '%s'\n":                                                                                              "argument '%s' inconnu\n",
+		"unknown network '%s', it should be public, testnet, futurenet or %s<passphrase>":                                      "réseau '%s' inconnu, il doit être public, testnet, futurenet ou %s<phrase secrète>",
+		"unknown withdrawal to '%s', should be one of %s":                                                                      "retrait vers '%s' inconnu, doit être l'un de %s",
+		"unsupported format '%s', the output should be a .html or .pdf file":                                                   "format '%s' non pris en charge, la sortie doit être un fichier .html ou .pdf",
+		"unsupported memo type '%s'":                                                                                           "type de mémo '%s' non pris en charge",
+		"unsupported memo type '%s' in payment URI":                                                                            "type de mémo '%s' non pris en charge dans l'URI de paiement",
+		"unsupported shell %q, should be bash, zsh or fish":                                                                    "shell %q non pris en charge, doit être bash, zsh ou fish",
+		"unsupported statement type: %T":                                                                                       "type d'instruction non pris en charge : %T",
+		"wallet %s already exists":                                                                                             "le portefeuille %s existe déjà",
+		"wallet %s already has a passphrase, remove it first to change it":                                                     "le portefeuille %s a déjà une phrase secrète, retirez-la d'abord pour la changer",
+		"wallet %s has no passphrase":                                                                                          "le portefeuille %s n'a pas de phrase secrète",
+		"wallet %s is already cold, its seed lives at: %s":                                                                     "le portefeuille %s est déjà froid, sa seed est conservée à : %s",
+		"wallet %s is cold, its seed lives at: %s":                                                                             "le portefeuille %s est froid, sa seed est conservée à : %s",
+		"unknown language '%s', should be one of %s":                                                                           "langue '%s' inconnue, doit être l'une de %s",
+		"wallet %s is cold, its transactions are signed offline but this one should be submitted right away":                   "le portefeuille %s est froid, ses transactions sont signées hors ligne mais celle-ci doit être soumise immédiatement",
+		"wallet %s: %v":           "portefeuille %s : %v",
+		"wallet '%s' not found":   "portefeuille '%s' introuvable",
+		"wallet '%s' not found%s": "portefeuille '%s' introuvable%s",
+	})
+}
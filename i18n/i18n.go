@@ -0,0 +1,160 @@
+// Package i18n translates the messages of alfred: its errors, prompts and the
+// headers of its tables, and gives the separators of the numbers. The messages are written in English in the code and
+// are the keys of the catalogs of the other languages, so that a message
+// missing from a catalog is printed in English.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// English is the language of the messages in the code
+const English = "en"
+
+// Catalog maps English messages to their translation in a language. The
+// messages with verbs, such as "wallet '%s' not found", are translated with
+// the same verbs in the same order.
+type Catalog map[string]string
+
+// Numbers are the separators of the numbers of a language
+type Numbers struct {
+	// Point separates the decimals
+	Point string
+	// Thousands groups the digits of the units by three
+	Thousands string
+}
+
+var (
+	mu       sync.RWMutex
+	catalogs = map[string]Catalog{English: {}}
+	numbers  = map[string]Numbers{English: {Point: ".", Thousands: ","}}
+	current  = English
+)
+
+// Register makes the catalog available under lang, such as "fr", adding its
+// messages to the ones already registered under lang
+func Register(lang string, c Catalog) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lang = strings.ToLower(lang)
+	if catalogs[lang] == nil {
+		catalogs[lang] = make(Catalog)
+	}
+	for msg, translation := range c {
+		catalogs[lang][msg] = translation
+	}
+}
+
+// RegisterNumbers sets the separators of the numbers of lang
+func RegisterNumbers(lang string, n Numbers) {
+	mu.Lock()
+	defer mu.Unlock()
+	numbers[strings.ToLower(lang)] = n
+}
+
+// Languages returns the languages with a catalog, English included, sorted
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var langs []string
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Supported reports whether lang has a catalog
+func Supported(lang string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := catalogs[Normalize(lang)]
+	return ok
+}
+
+// Use sets the language of the messages, lang being a language with a catalog
+// or a locale such as fr_FR.UTF-8
+func Use(lang string) error {
+	lang = Normalize(lang)
+	if !Supported(lang) {
+		return fmt.Errorf("unknown language '%s', should be one of %s", lang, strings.Join(Languages(), ", "))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	current = lang
+	return nil
+}
+
+// Current returns the language of the messages
+func Current() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// CurrentNumbers returns the separators of the numbers of the current
+// language, the English ones when it has none
+func CurrentNumbers() Numbers {
+	mu.RLock()
+	defer mu.RUnlock()
+	if n, ok := numbers[current]; ok {
+		return n
+	}
+	return numbers[English]
+}
+
+// Normalize returns the language of a locale, such as fr for fr_FR.UTF-8, and
+// English for the C and POSIX locales
+func Normalize(locale string) string {
+	lang := strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "" || lang == "c" || lang == "posix" {
+		return English
+	}
+	return lang
+}
+
+// FromEnv returns the language of the first locale set among LC_ALL,
+// LC_MESSAGES and LANG, read with getenv. It is English when none is set or
+// when the language has no catalog.
+func FromEnv(getenv func(string) string) string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := getenv(key); locale != "" {
+			if lang := Normalize(locale); Supported(lang) {
+				return lang
+			}
+			return English
+		}
+	}
+	return English
+}
+
+// T returns msg in the current language, or msg itself when it has no
+// translation
+func T(msg string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if translation, ok := catalogs[current][msg]; ok {
+		return translation
+	}
+	return msg
+}
+
+// Sprintf formats args with the translation of format
+func Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// Errorf returns an error formatting args with the translation of format
+func Errorf(format string, args ...interface{}) error {
+	return fmt.Errorf(T(format), args...)
+}
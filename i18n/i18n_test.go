@@ -0,0 +1,68 @@
+package i18n
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	for locale, lang := range map[string]string{
+		"":            "en",
+		"C":           "en",
+		"POSIX":       "en",
+		"fr":          "fr",
+		"fr_FR.UTF-8": "fr",
+		"FR-ca":       "fr",
+		"de_DE@euro":  "de",
+	} {
+		require.Equal(t, lang, Normalize(locale), locale)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	require.Equal(t, "en", FromEnv(env(nil)))
+	require.Equal(t, "fr", FromEnv(env(map[string]string{"LANG": "fr_FR.UTF-8"})))
+	require.Equal(t, "fr", FromEnv(env(map[string]string{"LC_ALL": "fr_CA.UTF-8", "LANG": "en_US.UTF-8"})))
+	require.Equal(t, "en", FromEnv(env(map[string]string{"LC_MESSAGES": "C", "LANG": "fr_FR.UTF-8"})))
+	// no catalog, the messages are in English
+	require.Equal(t, "en", FromEnv(env(map[string]string{"LANG": "de_DE.UTF-8"})))
+}
+
+func TestTranslate(t *testing.T) {
+	defer Use(English)
+
+	require.Equal(t, "Balance", T("Balance"))
+	require.Equal(t, Numbers{Point: ".", Thousands: ","}, CurrentNumbers())
+	require.NoError(t, Use("fr_FR.UTF-8"))
+	require.Equal(t, "fr", Current())
+	require.Equal(t, "Solde", T("Balance"))
+	require.Equal(t, "portefeuille 'bob' introuvable", Sprintf("wallet '%s' not found", "bob"))
+	require.EqualError(t, Errorf("account %s does not exist", "GABC"), "le compte GABC n'existe pas")
+	// missing from the catalog
+	require.Equal(t, "Unknown message", T("Unknown message"))
+
+	require.Equal(t, Numbers{Point: ",", Thousands: "\u00a0"}, CurrentNumbers())
+
+	require.Error(t, Use("de"))
+	require.Equal(t, "fr", Current())
+	require.Contains(t, Languages(), "en")
+	require.Contains(t, Languages(), "fr")
+}
+
+// TestCatalogs checks that the translations have the verbs of the messages,
+// in the same order
+func TestCatalogs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+	for lang, c := range catalogs {
+		for msg, translation := range c {
+			require.Equal(t, fmt.Sprint(verbs.FindAllString(msg, -1)), fmt.Sprint(verbs.FindAllString(translation, -1)), "%s: %s", lang, msg)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Package metrics exposes counters, gauges and histograms in the text format
+// of Prometheus, so that long running commands such as watch and serve can be
+// monitored.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default buckets of histograms, in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metrics and serves them over HTTP
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// family is a metric and its series, one per combination of label values
+type family struct {
+	name, help, typ string
+	labels          []string
+	buckets         []float64
+	series          map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	// counts are the cumulative counts of the buckets of a histogram
+	counts []uint64
+	count  uint64
+}
+
+func (r *Registry) register(name, help, typ string, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, f := range r.families {
+		if f.name == name {
+			panic(fmt.Sprintf("metrics: %s registered twice", name))
+		}
+	}
+
+	f := &family{name: name, help: help, typ: typ, labels: labels, buckets: buckets, series: make(map[string]*series)}
+	r.families = append(r.families, f)
+	return f
+}
+
+// get returns the series of values, the registry should be locked
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: values, counts: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter is a value which only goes up
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// Counter registers a counter with the given labels
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r: r, f: r.register(name, help, "counter", nil, labels)}
+}
+
+// Inc adds one to the series of values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which should not be negative, to the series of values
+func (c *Counter) Add(v float64, values ...string) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.f.get(values).value += v
+}
+
+// Gauge is a value which goes up and down
+type Gauge struct {
+	r *Registry
+	f *family
+}
+
+// Gauge registers a gauge with the given labels
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r: r, f: r.register(name, help, "gauge", nil, labels)}
+}
+
+// Set sets the series of values to v
+func (g *Gauge) Set(v float64, values ...string) {
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+	g.f.get(values).value = v
+}
+
+// Delete removes the series of values, such as the balance of a removed trustline
+func (g *Gauge) Delete(values ...string) {
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+	delete(g.f.series, strings.Join(values, "\xff"))
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	r *Registry
+	f *family
+}
+
+// Histogram registers a histogram with the given upper bounds of its buckets, in increasing order
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{r: r, f: r.register(name, help, "histogram", buckets, labels)}
+}
+
+// Observe adds v to the series of values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+
+	s := h.f.get(values)
+	for i, bound := range h.f.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// WriteTo writes the metrics in the text format of Prometheus
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range r.families {
+		fmt.Fprintf(cw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(cw, "# TYPE %s %s\n", f.name, f.typ)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			if f.typ != "histogram" {
+				fmt.Fprintf(cw, "%s%s %s\n", f.name, labels(f.labels, s.values, "", ""), formatFloat(s.value))
+				continue
+			}
+
+			for i, bound := range f.buckets {
+				fmt.Fprintf(cw, "%s_bucket%s %d\n", f.name, labels(f.labels, s.values, "le", formatFloat(bound)), s.counts[i])
+			}
+			fmt.Fprintf(cw, "%s_bucket%s %d\n", f.name, labels(f.labels, s.values, "le", "+Inf"), s.count)
+			fmt.Fprintf(cw, "%s_sum%s %s\n", f.name, labels(f.labels, s.values, "", ""), formatFloat(s.value))
+			fmt.Fprintf(cw, "%s_count%s %d\n", f.name, labels(f.labels, s.values, "", ""), s.count)
+		}
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// ServeHTTP serves the metrics, usually on /metrics
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// labels formats the labels of a series, with an extra label if name is not empty
+func labels(names, values []string, name, value string) string {
+	var pairs []string
+	for i := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, names[i], escapeValue(values[i])))
+	}
+	if name != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, value))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	valueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeValue(s string) string {
+	return valueEscaper.Replace(s)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
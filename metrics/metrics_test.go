@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	submissions := r.Counter("alfred_submissions_total", "Transactions submitted.", "result")
+	balance := r.Gauge("alfred_balance", "Balance of an asset held by a wallet.", "wallet", "asset")
+	latency := r.Histogram("alfred_horizon_request_duration_seconds", "Latency of the requests to horizon.", []float64{0.1, 1}, "endpoint")
+
+	submissions.Inc("success")
+	submissions.Inc("success")
+	submissions.Inc("failure")
+	balance.Set(100.5, "master", "XLM")
+	balance.Set(3, "savings", `M"O\BI`)
+	balance.Set(1, "removed", "USD")
+	balance.Delete("removed", "USD")
+	latency.Observe(0.05, "/accounts/{id}")
+	latency.Observe(0.5, "/accounts/{id}")
+	latency.Observe(2, "/accounts/{id}")
+
+	var buf bytes.Buffer
+	_, err := r.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, `# HELP alfred_submissions_total Transactions submitted.
+# TYPE alfred_submissions_total counter
+alfred_submissions_total{result="failure"} 1
+alfred_submissions_total{result="success"} 2
+# HELP alfred_balance Balance of an asset held by a wallet.
+# TYPE alfred_balance gauge
+alfred_balance{wallet="master",asset="XLM"} 100.5
+alfred_balance{wallet="savings",asset="M\"O\\BI"} 3
+# HELP alfred_horizon_request_duration_seconds Latency of the requests to horizon.
+# TYPE alfred_horizon_request_duration_seconds histogram
+alfred_horizon_request_duration_seconds_bucket{endpoint="/accounts/{id}",le="0.1"} 1
+alfred_horizon_request_duration_seconds_bucket{endpoint="/accounts/{id}",le="1"} 2
+alfred_horizon_request_duration_seconds_bucket{endpoint="/accounts/{id}",le="+Inf"} 3
+alfred_horizon_request_duration_seconds_sum{endpoint="/accounts/{id}"} 2.55
+alfred_horizon_request_duration_seconds_count{endpoint="/accounts/{id}"} 3
+`, buf.String())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, buf.String(), w.Body.String())
+	require.Contains(t, w.Header().Get("Content-Type"), "version=0.0.4")
+
+	require.Panics(t, func() { balance.Set(1, "master") })
+	require.Panics(t, func() { r.Gauge("alfred_balance", "again") })
+}
@@ -0,0 +1,61 @@
+package paper
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+
+	"github.com/celrenheit/alfred/qr"
+)
+
+var htmlTemplate = template.Must(template.New("paper").Funcs(template.FuncMap{"svg": svg}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.page { page-break-after: always; }
+.page:last-child { page-break-after: auto; }
+.item { display: flex; align-items: center; margin: 2em 0; }
+.item svg { width: 160px; height: 160px; margin-right: 2em; flex-shrink: 0; }
+.text { font-family: monospace; font-size: 1.1em; word-break: break-all; }
+</style>
+</head>
+<body>
+{{range .Pages}}<div class="page">
+<h1>{{.Title}}</h1>
+{{if .Note}}<p>{{.Note}}</p>
+{{end}}{{range .Items}}<div class="item">
+{{if .Code}}{{svg .Code}}
+{{end}}<div><h2>{{.Label}}</h2><div class="text">{{.Text}}</div></div>
+</div>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// HTML writes d as a web page, with a page break between its pages
+func (d *Document) HTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, d)
+}
+
+// svg draws c as an inline SVG image, a square of one unit per module
+func svg(c *qr.Code) template.HTML {
+	size := c.Size + 2*quietZone
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, size, size)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+
+	return template.HTML(b.String())
+}
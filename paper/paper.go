@@ -0,0 +1,75 @@
+// Package paper renders printable documents, such as paper wallets, made of
+// labelled texts with their QR codes, as HTML or PDF.
+package paper
+
+import (
+	"strings"
+
+	"github.com/celrenheit/alfred/qr"
+)
+
+// Document is a printable document, each page is printed on its own sheet
+type Document struct {
+	Title string
+	Pages []Page
+}
+
+// Page is a sheet of a document
+type Page struct {
+	Title string
+	// Note is printed below the title
+	Note  string
+	Items []Item
+}
+
+// Item is a labelled text printed next to its QR code
+type Item struct {
+	Label string
+	Text  string
+	// Code is the QR code of the text, it is omitted when nil
+	Code *qr.Code
+}
+
+// NewItem returns an item with the QR code of text
+func NewItem(label, text string) (Item, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return Item{}, err
+	}
+
+	return Item{Label: label, Text: text, Code: code}, nil
+}
+
+// quietZone is the number of light modules around a QR code
+const quietZone = 4
+
+// wrap splits s into lines of at most width characters, at spaces when possible
+func wrap(s string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, word[:width])
+				word = word[width:]
+			}
+
+			switch {
+			case line == "":
+				line = word
+			case len(line)+1+len(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
@@ -0,0 +1,74 @@
+package paper
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func document(t *testing.T, items int) *Document {
+	page := Page{Title: "Paper wallet: <master>", Note: "Keep this page (and its copies) safe"}
+	for i := 0; i < items; i++ {
+		item, err := NewItem(fmt.Sprintf("Item %d", i), "GCFXHS4GXL6BVUCXBWXGTITROWLVYXQKQLF4YH5O5JT3YZXCYPAFBJZB")
+		require.NoError(t, err)
+		page.Items = append(page.Items, item)
+	}
+
+	return &Document{Title: "Paper wallet", Pages: []Page{page, {Title: "Notes", Items: []Item{{Label: "Without code", Text: "text"}}}}}
+}
+
+func TestHTML(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, document(t, 2).HTML(&b))
+
+	html := b.String()
+	require.Contains(t, html, "Paper wallet: &lt;master&gt;")
+	require.Contains(t, html, "GCFXHS4GXL6BVUCXBWXGTITROWLVYXQKQLF4YH5O5JT3YZXCYPAFBJZB")
+	require.Equal(t, 2, strings.Count(html, "<svg"))
+	require.Equal(t, 2, strings.Count(html, `class="page"`))
+}
+
+func TestPDF(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, document(t, 7).PDF(&b))
+
+	pdf := b.String()
+	require.True(t, strings.HasPrefix(pdf, "%PDF-1.4\n"))
+	require.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+	require.Contains(t, pdf, `(Paper wallet: <master>) Tj`)
+	require.Contains(t, pdf, `(Keep this page \(and its copies\) safe) Tj`)
+	// the items do not fit on one page
+	require.Contains(t, pdf, "/Count 3")
+
+	// the cross reference table points at the objects
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	require.NotNil(t, startxref)
+	xref, err := strconv.Atoi(startxref[1])
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(pdf[xref:], "xref\n"))
+
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(pdf[xref:], -1)
+	require.Len(t, offsets, 5+2*3)
+	for i, offset := range offsets {
+		n, err := strconv.Atoi(offset[1])
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(pdf[n:], fmt.Sprintf("%d 0 obj\n", i+1)))
+	}
+
+	for _, stream := range regexp.MustCompile(`/Length (\d+) >>\nstream\n`).FindAllStringSubmatchIndex(pdf, -1) {
+		length, err := strconv.Atoi(pdf[stream[2]:stream[3]])
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(pdf[stream[1]+length:], "\nendstream"))
+	}
+}
+
+func TestWrap(t *testing.T) {
+	require.Equal(t, []string{"any 3 of", "the", "shares"}, wrap("any 3 of the shares", 8))
+	require.Equal(t, []string{"2-1-0a1b", "2c3d end"}, wrap("2-1-0a1b2c3d end", 8))
+	require.Equal(t, []string{""}, wrap("", 8))
+}
@@ -0,0 +1,158 @@
+package paper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Layout of the PDF pages, in points, on A4 paper
+const (
+	pdfWidth     = 595
+	pdfHeight    = 842
+	pdfMargin    = 50
+	pdfCodeSize  = 140
+	pdfItemSpace = 20
+	// pdfTextWidth is the number of characters of an item text per line, in Courier
+	pdfTextWidth = 50
+	// pdfNoteWidth is the number of characters of a note per line, in Helvetica
+	pdfNoteWidth = 95
+)
+
+// PDF writes d as a PDF document on A4 paper. Only the standard fonts are used,
+// so texts should be ASCII. The items which do not fit on a page continue on
+// the next one.
+func (d *Document) PDF(w io.Writer) error {
+	var contents []string
+	for _, p := range d.Pages {
+		contents = append(contents, pdfPage(p)...)
+	}
+
+	var b bytes.Buffer
+	var offsets []int
+	object := func(format string, args ...interface{}) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\nendobj\n")
+	}
+
+	// objects 1 to 5 are followed by a page and its content per page
+	const firstPage = 6
+	var kids []string
+	for i := range contents {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+2*i))
+	}
+
+	b.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(contents))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	for i, content := range contents {
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> /Contents %d 0 R >>",
+			pdfWidth, pdfHeight, firstPage+2*i+1)
+		object("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// pdfPage returns the content streams of p, more than one when its items do not fit
+func pdfPage(p Page) []string {
+	var contents []string
+	var c bytes.Buffer
+
+	y := pdfHeight - pdfMargin - 18
+	pdfText(&c, "F2", 18, pdfMargin, y, p.Title)
+	y -= 10
+	if p.Note != "" {
+		for _, line := range wrap(p.Note, pdfNoteWidth) {
+			y -= 14
+			pdfText(&c, "F1", 10, pdfMargin, y, line)
+		}
+	}
+	y -= pdfItemSpace
+
+	for _, item := range p.Items {
+		lines := wrap(item.Text, pdfTextWidth)
+		height := 16 + 12*len(lines)
+		if item.Code != nil && height < pdfCodeSize {
+			height = pdfCodeSize
+		}
+
+		if y-height < pdfMargin && c.Len() > 0 {
+			contents = append(contents, c.String())
+			c.Reset()
+			y = pdfHeight - pdfMargin
+		}
+
+		x := pdfMargin
+		if item.Code != nil {
+			pdfCode(&c, item, x, y)
+			x += pdfCodeSize + pdfItemSpace
+		}
+
+		textY := y - 20
+		pdfText(&c, "F2", 12, x, textY, item.Label)
+		for _, line := range lines {
+			textY -= 12
+			pdfText(&c, "F3", 9, x, textY, line)
+		}
+
+		y -= height + pdfItemSpace
+	}
+
+	return append(contents, c.String())
+}
+
+// pdfCode draws the QR code of item with its top left corner at x, y
+func pdfCode(c *bytes.Buffer, item Item, x, y int) {
+	code := item.Code
+	scale := float64(pdfCodeSize) / float64(code.Size+2*quietZone)
+
+	c.WriteString("0 g\n")
+	for row := 0; row < code.Size; row++ {
+		for col := 0; col < code.Size; col++ {
+			if code.Black(col, row) {
+				fmt.Fprintf(c, "%.2f %.2f %.2f %.2f re\n",
+					float64(x)+float64(col+quietZone)*scale,
+					float64(y)-float64(row+quietZone+1)*scale,
+					scale, scale)
+			}
+		}
+	}
+	c.WriteString("f\n")
+}
+
+func pdfText(c *bytes.Buffer, font string, size, x, y int, text string) {
+	fmt.Fprintf(c, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, x, y, pdfEscape(text))
+}
+
+// pdfEscape escapes text for a PDF string, replacing the characters which
+// are not printable ASCII
+func pdfEscape(text string) string {
+	var b bytes.Buffer
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
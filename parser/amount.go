@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// amountDecimals is the precision of the amounts of the network
+const amountDecimals = 7
+
+// amountSuffixes multiply the number they follow, as in 1.5k
+var amountSuffixes = map[byte]int64{
+	'k': 1000,
+	'm': 1000000,
+}
+
+// isAmountExpression reports whether value is an amount written with a
+// suffix or an operator, such as 1.5k or 100+50, rather than a currency
+func isAmountExpression(value string) bool {
+	if value == "" || !strings.ContainsAny(value[:1], "0123456789.(") {
+		return false
+	}
+	return strings.ContainsAny(strings.ToLower(value), "+-*/()km")
+}
+
+// evalAmount evaluates an amount such as 1.5k, 100+50 or (10+5)*2 with exact
+// decimal arithmetic. The result should be positive and have at most the 7
+// decimals of the network.
+func evalAmount(expr string) (string, error) {
+	e := &amountExpr{in: strings.ToLower(expr)}
+	r, err := e.sum()
+	if err == nil && e.pos < len(e.in) {
+		err = fmt.Errorf("unexpected '%c'", e.in[e.pos])
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid amount '%s': %v", expr, err)
+	}
+
+	return formatAmount(expr, r)
+}
+
+// amountToken returns tok as a number when it is an amount expression,
+// which the grammar has since V18
+func amountToken(l *lexer, tok *token) (*token, error) {
+	if tok.kind != tokenIdent || !available(V18, l.version) || !isAmountExpression(tok.value) {
+		return tok, nil
+	}
+
+	value, err := evalAmount(tok.value)
+	if err != nil {
+		return nil, err
+	}
+	return &token{kind: tokenNumber, value: value, pos: tok.pos, end: tok.end}, nil
+}
+
+// stroopsToXLM returns the amount of XLM of an amount of stroops
+func stroopsToXLM(stroops string) (string, error) {
+	r, ok := new(big.Rat).SetString(stroops)
+	if !ok {
+		return "", fmt.Errorf("invalid amount '%s'", stroops)
+	}
+	return formatAmount(stroops+" stroops", r.Quo(r, big.NewRat(10000000, 1)))
+}
+
+// formatAmount formats r as a decimal, without trailing zeros
+func formatAmount(expr string, r *big.Rat) (string, error) {
+	if r.Sign() <= 0 {
+		return "", fmt.Errorf("amount '%s' should be positive", expr)
+	}
+
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(amountDecimals), nil)))
+	if !scaled.IsInt() {
+		return "", fmt.Errorf("amount '%s' has more than %d decimals", expr, amountDecimals)
+	}
+
+	s := r.FloatString(amountDecimals)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, "."), nil
+}
+
+// amountExpr evaluates sums of products of numbers, with parentheses
+type amountExpr struct {
+	in  string
+	pos int
+}
+
+func (e *amountExpr) peek() byte {
+	if e.pos < len(e.in) {
+		return e.in[e.pos]
+	}
+	return 0
+}
+
+func (e *amountExpr) sum() (*big.Rat, error) {
+	r, err := e.product()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := e.peek(); op == '+' || op == '-'; op = e.peek() {
+		e.pos++
+		other, err := e.product()
+		if err != nil {
+			return nil, err
+		}
+		if op == '+' {
+			r.Add(r, other)
+		} else {
+			r.Sub(r, other)
+		}
+	}
+	return r, nil
+}
+
+func (e *amountExpr) product() (*big.Rat, error) {
+	r, err := e.factor()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := e.peek(); op == '*' || op == '/'; op = e.peek() {
+		e.pos++
+		other, err := e.factor()
+		if err != nil {
+			return nil, err
+		}
+		if op == '*' {
+			r.Mul(r, other)
+			continue
+		}
+		if other.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		r.Quo(r, other)
+	}
+	return r, nil
+}
+
+func (e *amountExpr) factor() (*big.Rat, error) {
+	if e.peek() == '(' {
+		e.pos++
+		r, err := e.sum()
+		if err != nil {
+			return nil, err
+		}
+		if e.peek() != ')' {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		e.pos++
+		return r, nil
+	}
+
+	start := e.pos
+	for c := e.peek(); (c >= '0' && c <= '9') || c == '.'; c = e.peek() {
+		e.pos++
+	}
+	if start == e.pos {
+		if e.pos == len(e.in) {
+			return nil, fmt.Errorf("missing number at the end")
+		}
+		return nil, fmt.Errorf("unexpected '%c'", e.in[e.pos])
+	}
+
+	r, ok := new(big.Rat).SetString(e.in[start:e.pos])
+	if !ok {
+		return nil, fmt.Errorf("invalid number '%s'", e.in[start:e.pos])
+	}
+
+	if multiplier, ok := amountSuffixes[e.peek()]; ok {
+		e.pos++
+		r.Mul(r, big.NewRat(multiplier, 1))
+	}
+	return r, nil
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DCA is a purchase repeated on a schedule (dollar-cost averaging), such as
+// BUY 50 XLM OF MOBI EVERY week WITH master, which spends 50 XLM on MOBI
+// every week
+type DCA struct {
+	// Amount of Spending spent on each purchase
+	Amount   string
+	Spending string
+	Buying   string
+	Every    time.Duration
+	Account  string
+}
+
+// minDCAInterval keeps the purchases from flooding the network
+const minDCAInterval = time.Hour
+
+// ParseDCA parses the schedule of a DCA strategy
+func ParseDCA(in string) (*DCA, error) {
+	l := &lexer{reader: strings.NewReader(in), version: Latest}
+	s := &DCA{}
+
+	if _, err := parseExpect(l, tokenBUY); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if s.Amount, err = parseExpect(l, tokenNumber); err != nil {
+		return nil, err
+	}
+	if s.Spending, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+	if _, err := parseExpect(l, tokenOF); err != nil {
+		return nil, err
+	}
+	if s.Buying, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+
+	every, err := parseExpect(l, tokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(every, "every") {
+		return nil, fmt.Errorf("expected 'EVERY' but got '%s'", every)
+	}
+
+	if s.Every, err = parseInterval(l); err != nil {
+		return nil, err
+	}
+	if s.Every < minDCAInterval {
+		return nil, errors.New("purchases should be at least an hour apart")
+	}
+
+	tok, err := parseTokenExpect(l, tokenWith, tokenEof)
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == tokenWith {
+		if s.Account, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+			return nil, err
+		}
+		if _, err := parseExpect(l, tokenEof); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// parseInterval parses a duration such as "2 days" or "1h", or a single unit such as "week"
+func parseInterval(l *lexer) (time.Duration, error) {
+	tok, err := parseTokenExpect(l, tokenNumber, tokenIdent)
+	if err != nil {
+		return 0, err
+	}
+
+	if tok.kind == tokenIdent {
+		if d, ok := durationUnits[strings.ToLower(tok.value)]; ok {
+			return d, nil
+		}
+	}
+
+	return parseDurationFrom(l, tok)
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SyntaxError is returned when a statement can not be parsed. It locates the
+// token at fault in the query and suggests the keywords close to it, when it
+// looks like a misspelled keyword.
+type SyntaxError struct {
+	Query string
+	// Pos and End are the offsets of the token at fault in Query
+	Pos, End int
+	// Token is the token at fault, empty at the end of the query
+	Token string
+	// Msg describes the error
+	Msg string
+	// Suggestions are the keywords the token may have been meant to be
+	Suggestions []string
+}
+
+// Error returns the message followed by the query, the token at fault marked
+// with carets, and the suggestions:
+//
+//	unexpected token 'IDENT' for 'frm', should be only FROM, TO, ... keywords
+//	  send 10 XLM frm a to b
+//	              ^^^
+//	did you mean 'from'?
+func (e *SyntaxError) Error() string {
+	width := e.End - e.Pos
+	if width < 1 {
+		width = 1
+	}
+
+	msg := fmt.Sprintf("%s\n  %s\n  %s%s", e.Msg, e.Query, strings.Repeat(" ", e.Pos), strings.Repeat("^", width))
+	if len(e.Suggestions) > 0 {
+		msg += "\n" + DidYouMean(e.Suggestions)
+	}
+	return msg
+}
+
+// DidYouMean formats suggestions, such as "did you mean 'from' or 'for'?"
+func DidYouMean(suggestions []string) string {
+	var quoted []string
+	for _, s := range suggestions {
+		quoted = append(quoted, "'"+s+"'")
+	}
+
+	if len(quoted) < 2 {
+		return "did you mean " + strings.Join(quoted, "") + "?"
+	}
+	return "did you mean " + strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1] + "?"
+}
+
+// statementKeywords start the statements
+var statementKeywords = []tokenKind{tokenSend, tokenSHARE, tokenSET, tokenBUY, tokenSELL, tokenPLACE, tokenDEPOSIT, tokenWITHDRAW, tokenREMOVE, tokenDELETE, tokenTRUST, tokenLOWER, tokenAUTHORIZE, tokenDEAUTHORIZE}
+
+// syntaxError locates err at the last token read by l
+func (l *lexer) syntaxError(err error) error {
+	if _, ok := err.(*SyntaxError); ok {
+		return err
+	}
+
+	query := make([]byte, l.reader.Size())
+	l.reader.ReadAt(query, 0)
+
+	e := &SyntaxError{Query: string(query), Msg: err.Error(), Pos: l.offset(), End: l.offset()}
+	if l.last == nil {
+		return e
+	}
+
+	e.Pos, e.End = l.last.pos, l.last.end
+	if l.last.kind != tokenEof {
+		e.Token = string(query[e.Pos:e.End])
+	}
+
+	if l.last.kind == tokenIdent {
+		kinds := statementKeywords
+		if l.read > 1 {
+			kinds = nil
+			for i := _tokStartKeywords + 1; i < _tokEndKeywords; i++ {
+				kinds = append(kinds, i)
+			}
+		}
+
+		var keywords []string
+		for _, kind := range kinds {
+			if keywordAvailable(kind, l.version) {
+				keywords = append(keywords, strings.ToLower(kind.String()))
+				keywords = append(keywords, l.locale.words(kind)...)
+			}
+		}
+		e.Suggestions = Suggest(l.last.value, keywords)
+	}
+
+	return e
+}
+
+// Suggest returns the candidates close to word, such as send for sned,
+// closest first. The case is ignored.
+func Suggest(word string, candidates []string) []string {
+	word = strings.ToLower(word)
+	if len(word) < 2 {
+		return nil
+	}
+
+	limit := len(word) / 3
+	if limit < 1 {
+		limit = 1
+	}
+
+	type match struct {
+		candidate string
+		distance  int
+	}
+	var matches []match
+	for _, c := range candidates {
+		d := distance(word, strings.ToLower(c))
+		if d > 0 && d <= limit {
+			matches = append(matches, match{c, d})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+
+	var suggestions []string
+	for _, m := range matches {
+		suggestions = append(suggestions, m.candidate)
+	}
+	return suggestions
+}
+
+// distance is the number of insertions, deletions, substitutions and
+// transpositions of adjacent letters turning a into b
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(min(d[i-1][j]+1, d[i][j-1]+1), d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
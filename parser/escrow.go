@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Escrow is an amount locked in an escrow account until a date, such as
+// 1000 XLM FROM master TO bob UNLOCK AFTER 2025-01-01
+type Escrow struct {
+	Amount      string
+	Currency    string
+	From, To    string
+	UnlockAfter time.Time
+}
+
+// ParseEscrow parses the creation of an escrow
+func ParseEscrow(in string) (*Escrow, error) {
+	l := &lexer{reader: strings.NewReader(in), version: Latest}
+	e := &Escrow{}
+
+	var err error
+	if e.Amount, err = parseExpect(l, tokenNumber); err != nil {
+		return nil, err
+	}
+	if e.Currency, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+
+	tok, err := parseTokenExpect(l, tokenFrom, tokenTo)
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == tokenFrom {
+		if e.From, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+			return nil, err
+		}
+		if _, err := parseExpect(l, tokenTo); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.To, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+
+	for _, word := range []string{"unlock", "after"} {
+		value, err := parseExpect(l, tokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(value, word) {
+			return nil, fmt.Errorf("expected '%s' but got '%s'", strings.ToUpper(word), value)
+		}
+	}
+
+	value, err := parseExpect(l, tokenIdent, tokenSTRING)
+	if err != nil {
+		return nil, err
+	}
+	if e.UnlockAfter, err = parseDate(value); err != nil {
+		return nil, err
+	}
+
+	if _, err := parseExpect(l, tokenEof); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Invoice is a payment requested to a wallet, such as
+// 120 USDC TO master NOTE "design work"
+type Invoice struct {
+	Amount   string
+	Currency string
+	To       string
+	// Note describes what is paid, it is not sent with the payment
+	Note string
+}
+
+// ParseInvoice parses the payment requested by an invoice
+func ParseInvoice(in string) (*Invoice, error) {
+	l := &lexer{reader: strings.NewReader(in), version: Latest}
+	inv := &Invoice{}
+
+	var err error
+	if inv.Amount, err = parseExpect(l, tokenNumber); err != nil {
+		return nil, err
+	}
+	if inv.Currency, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+	if _, err := parseExpect(l, tokenTo); err != nil {
+		return nil, err
+	}
+	if inv.To, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+
+	tok, err := parseTokenExpect(l, tokenIdent, tokenEof)
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == tokenEof {
+		return inv, nil
+	}
+	if !strings.EqualFold(tok.value, "note") {
+		return nil, fmt.Errorf("expected 'NOTE' but got '%s'", tok.value)
+	}
+
+	if inv.Note, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+	if _, err := parseExpect(l, tokenEof); err != nil {
+		return nil, err
+	}
+
+	return inv, nil
+}
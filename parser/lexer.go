@@ -8,23 +8,44 @@ import (
 	"unicode"
 )
 
-var (
-	tokenEOF = &token{kind: tokenEof}
-)
-
 type lexer struct {
-	reader *strings.Reader
+	reader  *strings.Reader
+	version Version
+	// locale has other words for the keywords, nil for English only
+	locale *locale
+	// last is the last token read, where errors are located
+	last *token
+	// read is the number of tokens read
+	read int
 }
 
 func (l *lexer) Next() (*token, error) {
+	tok, err := l.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	tok.end = l.offset()
+	l.last = tok
+	l.read++
+	return tok, nil
+}
+
+// offset returns the position of the reader in the query
+func (l *lexer) offset() int {
+	return int(l.reader.Size()) - l.reader.Len()
+}
+
+func (l *lexer) scan() (*token, error) {
 	if err := l.skipWhitespaces(); err != nil {
 		if err == io.EOF {
-			return tokenEOF, nil
+			return &token{kind: tokenEof, pos: l.offset()}, nil
 		}
 
 		return nil, err
 	}
 
+	pos := l.offset()
 	r, _, err := l.reader.ReadRune()
 	if err != nil {
 		return nil, err
@@ -33,9 +54,9 @@ func (l *lexer) Next() (*token, error) {
 	switch r {
 	// check quotes, commas, etc..
 	case ',':
-		return &token{kind: tokenCOMMA, value: string(r)}, nil
+		return &token{kind: tokenCOMMA, value: string(r), pos: pos}, nil
 	case '=':
-		return &token{kind: tokenEQUAL, value: string(r)}, nil
+		return &token{kind: tokenEQUAL, value: string(r), pos: pos}, nil
 	case '"', '\'':
 		value, err := l.scanUntil(r)
 		if err != nil {
@@ -48,7 +69,7 @@ func (l *lexer) Next() (*token, error) {
 			return nil, err
 		}
 
-		return &token{kind: tokenSTRING, value: value}, nil
+		return &token{kind: tokenSTRING, value: value, pos: pos}, nil
 	}
 
 	if err := l.reader.UnreadRune(); err != nil {
@@ -66,20 +87,28 @@ func (l *lexer) Next() (*token, error) {
 	}
 
 	for _, kind := range kinds {
+		if !keywordAvailable(kind, l.version) {
+			continue
+		}
+
 		if strings.ToUpper(value) == kind.String() {
-			return &token{kind: kind, value: value}, nil
+			return &token{kind: kind, value: value, pos: pos}, nil
 		}
 	}
 
+	if kind, ok := l.locale.keyword(value); ok && keywordAvailable(kind, l.version) {
+		return &token{kind: kind, value: value, pos: pos}, nil
+	}
+
 	if _, err := strconv.ParseFloat(value, 64); err == nil {
-		return &token{kind: tokenNumber, value: value}, nil
+		return &token{kind: tokenNumber, value: value, pos: pos}, nil
 	}
 
 	if value == "" {
 		return nil, fmt.Errorf("unable to find token for '%s'", value)
 	}
 
-	return &token{kind: tokenIdent, value: value}, nil
+	return &token{kind: tokenIdent, value: value, pos: pos}, nil
 }
 
 func (l *lexer) scanIdent() (string, error) {
@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Locale is a set of words read as the keywords of the grammar, such as the
+// French "envoie" for SEND. The English keywords remain available, and the
+// words of a locale are only keywords in the versions of the grammar that
+// have the keywords they stand for.
+type Locale struct {
+	// Keywords maps words to the keywords they stand for, such as "envoie" to "SEND"
+	Keywords map[string]string
+	// Units maps words to the duration units they stand for, such as "heure" to "hour"
+	Units map[string]string
+}
+
+// locale is a Locale indexed by the lexer
+type locale struct {
+	keywords map[string]tokenKind
+	units    map[string]time.Duration
+}
+
+var locales = map[string]*locale{}
+
+// RegisterLocale makes the locale available under name, such as "fr", to
+// ParseWithLocale
+func RegisterLocale(name string, l Locale) error {
+	kinds := make(map[string]tokenKind)
+	for i := _tokStartKeywords + 1; i < _tokEndKeywords; i++ {
+		kinds[i.String()] = i
+	}
+
+	indexed := &locale{keywords: make(map[string]tokenKind), units: make(map[string]time.Duration)}
+	for word, keyword := range l.Keywords {
+		kind, ok := kinds[strings.ToUpper(keyword)]
+		if !ok {
+			return fmt.Errorf("parser: locale %s: unknown keyword '%s' for '%s'", name, keyword, word)
+		}
+		indexed.keywords[strings.ToLower(word)] = kind
+	}
+	for word, unit := range l.Units {
+		d, ok := durationUnits[unit]
+		if !ok {
+			return fmt.Errorf("parser: locale %s: unknown duration unit '%s' for '%s'", name, unit, word)
+		}
+		indexed.units[strings.ToLower(word)] = d
+	}
+
+	locales[strings.ToLower(name)] = indexed
+	return nil
+}
+
+// Locales returns the names of the registered locales, sorted
+func Locales() []string {
+	var names []string
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupLocale returns the locale of name, nil for English ("" or "en")
+func lookupLocale(name string) (*locale, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "en" {
+		return nil, nil
+	}
+
+	l, ok := locales[name]
+	if !ok {
+		return nil, fmt.Errorf("parser: unknown language '%s', should be en or one of %s", name, strings.Join(Locales(), ", "))
+	}
+	return l, nil
+}
+
+// CheckLocale returns an error if no locale is registered under name
+func CheckLocale(name string) error {
+	_, err := lookupLocale(name)
+	return err
+}
+
+// keyword returns the keyword word stands for in l
+func (l *locale) keyword(word string) (tokenKind, bool) {
+	if l == nil {
+		return 0, false
+	}
+	kind, ok := l.keywords[strings.ToLower(word)]
+	return kind, ok
+}
+
+// unit returns the duration unit word stands for in l
+func (l *locale) unit(word string) (time.Duration, bool) {
+	if l == nil {
+		return 0, false
+	}
+	d, ok := l.units[strings.TrimSuffix(strings.ToLower(word), "s")]
+	return d, ok
+}
+
+// words returns the words of l standing for kind
+func (l *locale) words(kind tokenKind) []string {
+	if l == nil {
+		return nil
+	}
+
+	var words []string
+	for word, k := range l.keywords {
+		if k == kind {
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
+
+func init() {
+	// envoie 20 XLM de master à jennifer
+	RegisterLocale("fr", Locale{
+		Keywords: map[string]string{
+			"envoie": "SEND", "envoyer": "SEND",
+			"de": "FROM", "depuis": "FROM",
+			"à": "TO", "vers": "TO",
+			"et":     "AND",
+			"mémo":   "MEMO",
+			"achète": "BUY", "acheter": "BUY",
+			"vends": "SELL", "vendre": "SELL",
+			"contre":     "USING",
+			"pour":       "FOR",
+			"au":         "AT",
+			"avec":       "WITH",
+			"tout":       "ALL",
+			"des":        "OF",
+			"valable":    "VALID",
+			"pas":        "NOT",
+			"avant":      "BEFORE",
+			"crée":       "CREATE",
+			"dépose":     "DEPOSIT",
+			"sur":        "INTO",
+			"retire":     "WITHDRAW",
+			"expire":     "EXPIRES",
+			"dans":       "IN",
+			"partage":    "SHARE",
+			"compte":     "ACCOUNT",
+			"exigeant":   "REQUIRING",
+			"enlève":     "REMOVE",
+			"signataire": "SIGNER",
+			"définis":    "SET",
+			"données":    "DATA",
+			"supprime":   "DELETE",
+			"offre":      "OFFER",
+			"passive":    "PASSIVE",
+		},
+		Units: map[string]string{
+			"seconde": "second", "minute": "minute", "heure": "hour", "jour": "day", "semaine": "week",
+		},
+	})
+
+	// envía 20 XLM desde master para jennifer
+	RegisterLocale("es", Locale{
+		Keywords: map[string]string{
+			"envía": "SEND", "envia": "SEND", "enviar": "SEND",
+			"desde":  "FROM",
+			"para":   "TO",
+			"y":      "AND",
+			"compra": "BUY", "comprar": "BUY",
+			"vende": "SELL", "vender": "SELL",
+			"usando": "USING",
+			"por":    "FOR",
+			"al":     "AT",
+			"con":    "WITH",
+			"todo":   "ALL",
+			"del":    "OF",
+			"válido": "VALID", "valido": "VALID",
+			"no":       "NOT",
+			"antes":    "BEFORE",
+			"crea":     "CREATE",
+			"deposita": "DEPOSIT",
+			"en":       "INTO",
+			"retira":   "WITHDRAW",
+			"comparte": "SHARE",
+			"cuenta":   "ACCOUNT",
+			"quita":    "REMOVE",
+			"firmante": "SIGNER",
+			"define":   "SET",
+			"datos":    "DATA",
+			"borra":    "DELETE",
+			"oferta":   "OFFER",
+			"pasiva":   "PASSIVE",
+		},
+		Units: map[string]string{
+			"segundo": "second", "minuto": "minute", "hora": "hour", "día": "day", "dia": "day", "semana": "week",
+		},
+	})
+}
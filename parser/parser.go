@@ -10,7 +10,37 @@ func Parse(in string) (Statement, error) {
 }
 
 func ParseReader(reader *strings.Reader) (s Statement, err error) {
-	l := &lexer{reader}
+	return ParseReaderVersion(reader, Latest)
+}
+
+// ParseWithVersion parses in using the keywords available in version v
+func ParseWithVersion(in string, v Version) (Statement, error) {
+	return ParseReaderVersion(strings.NewReader(in), v)
+}
+
+// ParseReaderVersion parses reader using the keywords available in version v.
+// The errors are *SyntaxError, locating the token at fault.
+func ParseReaderVersion(reader *strings.Reader, v Version) (s Statement, err error) {
+	return parse(&lexer{reader: reader, version: v})
+}
+
+// ParseWithLocale parses in using the keywords available in version v, which
+// can also be written with the words of the locale lang, such as "fr"
+func ParseWithLocale(in string, v Version, lang string) (Statement, error) {
+	locale, err := lookupLocale(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	return parse(&lexer{reader: strings.NewReader(in), version: v, locale: locale})
+}
+
+func parse(l *lexer) (s Statement, err error) {
+	defer func() {
+		if err != nil {
+			err = l.syntaxError(err)
+		}
+	}()
 
 	tok, err := l.Next()
 	if err != nil {
@@ -38,6 +68,31 @@ func ParseReader(reader *strings.Reader) (s Statement, err error) {
 		s = &Offer{kind: BuyOfferKind}
 	case tokenSELL:
 		s = &Offer{kind: SellOfferKind}
+	case tokenPLACE:
+		offer, err := parsePassiveOffer(l)
+		if err != nil {
+			return nil, err
+		}
+		s = offer
+	case tokenDEPOSIT:
+		s = &Transfer{kind: DepositKind}
+	case tokenWITHDRAW:
+		s = &Transfer{kind: WithdrawKind}
+	case tokenREMOVE:
+		s = &RemoveSignerRequest{}
+	case tokenDELETE:
+		s = &DeleteDataRequest{}
+	case tokenTRUST:
+		s = &TrustRequest{}
+	case tokenLOWER:
+		if _, err := parseExpect(l, tokenTRUST); err != nil {
+			return nil, err
+		}
+		s = &TrustRequest{Lower: true}
+	case tokenAUTHORIZE:
+		s = &AuthorizeRequest{}
+	case tokenDEAUTHORIZE:
+		s = &AuthorizeRequest{Revoke: true}
 	default:
 		return nil, fmt.Errorf("parser: unknown statement '%s' got: '%v'", tok.value, tok)
 	}
@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -24,6 +26,59 @@ func TestParser(t *testing.T) {
 		{"SEND 2 XLM FROM TO", nil, true},
 		{"SEND XLM FROM TO", nil, true},
 		{"SEND 2 XLM jennifer", nil, true},
+		{`SEND 2 XLM TO jennifer MEMO "dinner"`, &SendRequest{
+			Amount:   "2",
+			Currency: "XLM",
+			To:       "jennifer",
+			Memo:     "dinner",
+		}, false},
+		{"SEND 2 XLM TO jennifer MEMO", nil, true},
+		{"SEND 2 XLM TO jennifer VALID FOR 10 minutes", &SendRequest{
+			Amount:     "2",
+			Currency:   "XLM",
+			To:         "jennifer",
+			TimeBounds: TimeBounds{ValidFor: 10 * time.Minute},
+		}, false},
+		{"SEND 2 XLM TO jennifer NOT BEFORE 2024-01-01 VALID FOR 1h30m", &SendRequest{
+			Amount:   "2",
+			Currency: "XLM",
+			To:       "jennifer",
+			TimeBounds: TimeBounds{
+				ValidFor:  90 * time.Minute,
+				NotBefore: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+			},
+		}, false},
+		{"SEND 2 XLM TO jennifer VALID FOR 10 fortnights", nil, true},
+		{"SEND 2 XLM TO jennifer VALID 10 minutes", nil, true},
+		{"SEND 2 XLM TO jennifer NOT BEFORE tomorrow", nil, true},
+		{"send 10 XLM from master to jennifer create with 5 XLM starting balance", &SendRequest{
+			Amount:          "10",
+			Currency:        "XLM",
+			From:            "master",
+			To:              "jennifer",
+			StartingBalance: "5",
+		}, false},
+		{"SEND 10 XLM TO jennifer CREATE WITH 5 MOBI STARTING BALANCE", nil, true},
+		{"SEND 10 XLM TO jennifer CREATE WITH 5 XLM", nil, true},
+		{"send all XLM from master to backup", &SendRequest{
+			Percent:  "100",
+			Currency: "XLM",
+			From:     "master",
+			To:       "backup",
+		}, false},
+		{"send 50% of MOBI from master to jennifer", &SendRequest{
+			Percent:  "50",
+			Currency: "MOBI",
+			From:     "master",
+			To:       "jennifer",
+		}, false},
+		{"send 12.5% MOBI to jennifer", &SendRequest{
+			Percent:  "12.5",
+			Currency: "MOBI",
+			To:       "jennifer",
+		}, false},
+		{"send 150% of MOBI to jennifer", nil, true},
+		{"send all 10 XLM to jennifer", nil, true},
 		{"SEND 2 XLM TO jennifer", &SendRequest{
 			Amount:   "2",
 			Currency: "XLM",
@@ -47,6 +102,38 @@ func TestParser(t *testing.T) {
 		{"SHARE ACCOUNT master WITH", nil, true},
 		{"SHARE ACCOUNT master WITH ,", nil, true},
 		{"SHARE ACCOUNT master", nil, true},
+		{"SHARE master WITH bob and carol REQUIRING 2 OF 3", &ShareAccountRequest{
+			Account:            "master",
+			AdditionnalSigners: []string{"bob", "carol"},
+			Required:           2,
+			Total:              3,
+		}, false},
+		{"SHARE ACCOUNT master WITH bob REQUIRING 1 OF 2", &ShareAccountRequest{
+			Account:            "master",
+			AdditionnalSigners: []string{"bob"},
+			Required:           1,
+			Total:              2,
+		}, false},
+		{"SHARE master WITH bob REQUIRING 3 OF 2", nil, true},
+		{"SHARE master WITH bob REQUIRING 0 OF 2", nil, true},
+		{"SHARE master WITH bob REQUIRING 2", nil, true},
+		{"SHARE master WITH bob, REQUIRING 1 OF 2", nil, true},
+		{"SHARE master WITH bob REQUIRING 1 OF 2 now", nil, true},
+		{"REMOVE SIGNER bob FROM master", &RemoveSignerRequest{
+			Account: "master",
+			Signers: []string{"bob"},
+		}, false},
+		{"remove signer bob and carol from master requiring 1 of 1", &RemoveSignerRequest{
+			Account:  "master",
+			Signers:  []string{"bob", "carol"},
+			Required: 1,
+			Total:    1,
+		}, false},
+		{"REMOVE SIGNER FROM master", nil, true},
+		{"REMOVE SIGNER bob", nil, true},
+		{"REMOVE SIGNER bob FROM", nil, true},
+		{"REMOVE bob FROM master", nil, true},
+		{"REMOVE SIGNER bob FROM master TO alice", nil, true},
 		{"SET DATA foo = bar", &SetDataRequest{
 			KVs: map[string]DataEntry{
 				"foo": {SetDataFromString, "bar"},
@@ -77,6 +164,17 @@ func TestParser(t *testing.T) {
 		{"SET DATA foo = ", nil, true},
 		{`SET DATA foo = "`, nil, true},
 		{`SET DATA foo from`, nil, true},
+		{"DELETE DATA foo FROM master", &DeleteDataRequest{
+			Account: "master",
+			Keys:    []string{"foo"},
+		}, false},
+		{`delete data foo, "hello world" and bar`, &DeleteDataRequest{
+			Keys: []string{"foo", "hello world", "bar"},
+		}, false},
+		{"DELETE DATA", nil, true},
+		{"DELETE DATA foo FROM", nil, true},
+		{"DELETE DATA foo FROM master TO bob", nil, true},
+		{"DELETE foo", nil, true},
 		{`BUY 100 MOBI AT 0.1000 USING XLM`, &Offer{
 			kind:       BuyOfferKind,
 			Amount:     "100",
@@ -112,11 +210,93 @@ func TestParser(t *testing.T) {
 			Selling:    "XLM",
 		}, false},
 		{`BUY 200 MOBI USING 100 XLM`, nil, true},
+		{`SELL 100 MOBI FOR XLM VALID FOR 2 days`, &Offer{
+			kind:       SellOfferKind,
+			Amount:     "100",
+			AmountKind: AmountBuyKind,
+			Buying:     "XLM",
+			Selling:    "MOBI",
+			TimeBounds: TimeBounds{ValidFor: 48 * time.Hour},
+		}, false},
+		{`BUY 100 MOBI AT 0.5 USING XLM EXPIRES IN 24h WITH master`, &Offer{
+			kind:       BuyOfferKind,
+			Amount:     "100",
+			AmountKind: AmountBuyKind,
+			Buying:     "MOBI",
+			Selling:    "XLM",
+			Price:      "0.5",
+			Account:    "master",
+			Expires:    24 * time.Hour,
+		}, false},
+		{`SELL 100 MOBI FOR XLM expires in 2 days`, &Offer{
+			kind:       SellOfferKind,
+			Amount:     "100",
+			AmountKind: AmountBuyKind,
+			Buying:     "XLM",
+			Selling:    "MOBI",
+			Expires:    48 * time.Hour,
+		}, false},
+		{`SELL 100 MOBI FOR XLM EXPIRES 2 days`, nil, true},
+		{`PLACE PASSIVE OFFER SELL 100 MOBI AT 0.5 FOR XLM WITH maker`, &Offer{
+			kind:       SellOfferKind,
+			Amount:     "100",
+			AmountKind: AmountBuyKind,
+			Buying:     "XLM",
+			Selling:    "MOBI",
+			Price:      "0.5",
+			Account:    "maker",
+			Passive:    true,
+		}, false},
+		{`place passive offer buy 100 MOBI at 2 using XLM`, &Offer{
+			kind:       BuyOfferKind,
+			Amount:     "100",
+			AmountKind: AmountBuyKind,
+			Buying:     "MOBI",
+			Selling:    "XLM",
+			Price:      "2",
+			Passive:    true,
+		}, false},
+		{`PLACE PASSIVE OFFER SELL 100 MOBI FOR XLM`, nil, true},
+		{`PLACE OFFER SELL 100 MOBI AT 0.5 FOR XLM`, nil, true},
+		{`PLACE PASSIVE OFFER SEND 100 MOBI AT 0.5 FOR XLM`, nil, true},
+		{"DEPOSIT 100 USD VIA anchor.com INTO master", &Transfer{
+			kind:     DepositKind,
+			Amount:   "100",
+			Currency: "USD",
+			Anchor:   "anchor.com",
+			Account:  "master",
+		}, false},
+		{"DEPOSIT USD INTO master VIA anchor.com", &Transfer{
+			kind:     DepositKind,
+			Currency: "USD",
+			Anchor:   "anchor.com",
+			Account:  "master",
+		}, false},
+		{"DEPOSIT 100 USD INTO master", nil, true},
+		{"DEPOSIT 100 VIA anchor.com", nil, true},
+		{"DEPOSIT 100 USD VIA anchor.com TO master", nil, true},
+		{"WITHDRAW 200 USDC FROM master TO bank VIA anchor.com", &Transfer{
+			kind:        WithdrawKind,
+			Amount:      "200",
+			Currency:    "USDC",
+			Anchor:      "anchor.com",
+			Account:     "master",
+			Destination: "bank",
+		}, false},
+		{"WITHDRAW 200 USDC VIA anchor.com", &Transfer{
+			kind:     WithdrawKind,
+			Amount:   "200",
+			Currency: "USDC",
+			Anchor:   "anchor.com",
+		}, false},
+		{"WITHDRAW USDC FROM master VIA anchor.com", nil, true},
+		{"WITHDRAW 200 USDC INTO master VIA anchor.com", nil, true},
 	}
 
+	// the statements of the versions after V15 are in TestParserVersions
 	for _, test := range tests {
 		t.Run(test.input, func(t *testing.T) {
-			statement, err := Parse(test.input)
+			statement, err := ParseWithVersion(test.input, V15)
 			if test.wantErr {
 				require.Error(t, err)
 				return
@@ -137,3 +317,598 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParserVersions(t *testing.T) {
+	var tests = []struct {
+		input    string
+		version  Version
+		wantData Statement
+		wantErr  bool
+	}{
+		{"SEND 2 XLM FROM memo TO jennifer", V1, &SendRequest{
+			Amount:   "2",
+			Currency: "XLM",
+			From:     "memo",
+			To:       "jennifer",
+		}, false},
+		{"SEND 2 XLM FROM memo TO jennifer", V2, nil, true},
+		{`SEND 2 XLM TO jennifer MEMO "dinner"`, V1, nil, true},
+		{`SEND 2 XLM TO jennifer MEMO "dinner"`, V2, &SendRequest{
+			Amount:   "2",
+			Currency: "XLM",
+			To:       "jennifer",
+			Memo:     "dinner",
+		}, false},
+		{"SEND 2 XLM FROM valid TO jennifer", V2, &SendRequest{
+			Amount:   "2",
+			Currency: "XLM",
+			From:     "valid",
+			To:       "jennifer",
+		}, false},
+		{"SEND 2 XLM FROM valid TO jennifer", V3, nil, true},
+		{"SEND 2 XLM TO jennifer VALID FOR 10 minutes", V2, nil, true},
+		{"SEND 2 XLM FROM balance TO jennifer", V3, &SendRequest{
+			Amount:   "2",
+			Currency: "XLM",
+			From:     "balance",
+			To:       "jennifer",
+		}, false},
+		{"SEND 2 XLM TO jennifer CREATE WITH 1 XLM STARTING BALANCE", V3, nil, true},
+		{"SEND 2 XLM FROM all TO jennifer", V4, &SendRequest{
+			Amount:   "2",
+			Currency: "XLM",
+			From:     "all",
+			To:       "jennifer",
+		}, false},
+		{"SEND 2 XLM FROM via TO jennifer", V5, &SendRequest{
+			Amount:   "2",
+			Currency: "XLM",
+			From:     "via",
+			To:       "jennifer",
+		}, false},
+		{"DEPOSIT 100 USD VIA anchor.com INTO master", V5, nil, true},
+		{"WITHDRAW 200 USDC FROM master TO bank VIA anchor.com", V6, nil, true},
+		{"SELL 100 MOBI FOR XLM EXPIRES IN 24h", V7, nil, true},
+		{"SEND 2 XLM FROM in TO jennifer", V7, &SendRequest{
+			Amount:   "2",
+			Currency: "XLM",
+			From:     "in",
+			To:       "jennifer",
+		}, false},
+		{"SEND 2 XLM FROM in TO jennifer", V8, nil, true},
+		{"PLACE PASSIVE OFFER SELL 100 MOBI AT 0.5 FOR XLM", V8, nil, true},
+		{"SEND 2 XLM FROM offer TO jennifer", V8, &SendRequest{
+			Amount:   "2",
+			Currency: "XLM",
+			From:     "offer",
+			To:       "jennifer",
+		}, false},
+		{"SHARE master WITH bob and carol REQUIRING 2 OF 3", V9, nil, true},
+		{"REMOVE SIGNER bob FROM master", V10, nil, true},
+		{"DELETE DATA foo", V11, nil, true},
+		{"SHARE ACCOUNT master WITH bob and requiring", V9, &ShareAccountRequest{
+			Account:            "master",
+			AdditionnalSigners: []string{"bob", "requiring"},
+		}, false},
+		{"SEND 2 FROM master TO jennifer", V16, &SendRequest{
+			Amount: "2",
+			From:   "master",
+			To:     "jennifer",
+		}, false},
+		{"send 20 to jennifer from master", V16, &SendRequest{
+			Amount: "20",
+			To:     "jennifer",
+			From:   "master",
+		}, false},
+		{"send all from master to backup", V15, nil, true},
+		{"send all from master to backup", V16, &SendRequest{
+			Percent: "100",
+			From:    "master",
+			To:      "backup",
+		}, false},
+		{"send 20", V16, &SendRequest{Amount: "20"}, false},
+		{"send 50% of to jennifer", V16, nil, true},
+		{"send 20 memo lunch to jennifer", V16, nil, true},
+		{"send 10 XLM from master to alice, bob and carol memo lunch", V16, nil, true},
+		{"send 10 XLM from master to alice, bob and carol memo lunch", V17, &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			From:     "master",
+			To:       "alice",
+			AlsoTo:   []string{"bob", "carol"},
+			Memo:     "lunch",
+		}, false},
+		{"send 10 XLM to alice and bob from master", V17, &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			From:     "master",
+			To:       "alice",
+			AlsoTo:   []string{"bob"},
+		}, false},
+		{"send 10 XLM from master, bob to alice", V17, nil, true},
+		{"send 10 XLM to alice,", V17, nil, true},
+		{"send 2500000 stroops to bob", V17, &SendRequest{
+			Amount:   "2500000",
+			Currency: "stroops",
+			To:       "bob",
+		}, false},
+		{"buy 1.5k MOBI using XLM", V17, nil, true},
+		{"trust MOBI on master up to 5k", V17, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version.String()+" "+test.input, func(t *testing.T) {
+			statement, err := ParseWithVersion(test.input, test.version)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.wantData, statement)
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for in, want := range map[string]Version{"": Latest, "v1": V1, "V2": V2, "1": V1} {
+		v, err := ParseVersion(in)
+		require.NoError(t, err)
+		require.Equal(t, want, v)
+	}
+
+	_, err := ParseVersion("v0")
+	require.Error(t, err)
+
+	require.NotContains(t, Keywords(V1), "MEMO")
+	require.Contains(t, Keywords(V2), "MEMO")
+
+	require.Empty(t, Syntax(V15))
+	require.Len(t, Syntax(V16), 1)
+	require.Len(t, Syntax(V17), 2)
+	require.Len(t, Syntax(V18), 5)
+}
+
+func TestParseDCA(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    *DCA
+		wantErr bool
+	}{
+		{"BUY 50 XLM OF MOBI EVERY week", &DCA{Amount: "50", Spending: "XLM", Buying: "MOBI", Every: 7 * 24 * time.Hour}, false},
+		{"buy 10.5 XLM of MOBI every 2 days with master", &DCA{Amount: "10.5", Spending: "XLM", Buying: "MOBI", Every: 48 * time.Hour, Account: "master"}, false},
+		{"BUY 1 XLM OF MOBI EVERY 12h", &DCA{Amount: "1", Spending: "XLM", Buying: "MOBI", Every: 12 * time.Hour}, false},
+		{"BUY 1 XLM OF MOBI EVERY 10 minutes", nil, true},
+		{"BUY 1 XLM OF MOBI EACH week", nil, true},
+		{"BUY XLM OF MOBI EVERY week", nil, true},
+		{"BUY 1 XLM OF MOBI EVERY week WITH", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			dca, err := ParseDCA(test.input)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.want, dca)
+		})
+	}
+}
+
+func TestParseQuote(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    *Quote
+		wantErr bool
+	}{
+		{"100 USDC FROM master TO bob", &Quote{Amount: "100", Currency: "USDC", From: "master", To: "bob"}, false},
+		{"2.5 XLM to GBXWJ2P4NDSXRKIGQXMLO6WBGPETTEARZPIGRJ4AYONOR7IRHG2K42WA", &Quote{Amount: "2.5", Currency: "XLM", To: "GBXWJ2P4NDSXRKIGQXMLO6WBGPETTEARZPIGRJ4AYONOR7IRHG2K42WA"}, false},
+		{"100 USDC FROM master", nil, true},
+		{"USDC FROM master TO bob", nil, true},
+		{"100 USDC TO bob WITH master", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			q, err := ParseQuote(test.input)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.want, q)
+		})
+	}
+}
+
+func TestParseInvoice(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    *Invoice
+		wantErr bool
+	}{
+		{"120 USDC TO master NOTE \"design work\"", &Invoice{Amount: "120", Currency: "USDC", To: "master", Note: "design work"}, false},
+		{"2.5 XLM to master", &Invoice{Amount: "2.5", Currency: "XLM", To: "master"}, false},
+		{"120 USDC FROM master", nil, true},
+		{"120 USDC TO master MEMO \"design work\"", nil, true},
+		{"120 USDC TO master NOTE", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			inv, err := ParseInvoice(test.input)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.want, inv)
+		})
+	}
+}
+
+func TestParseSwap(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    *Swap
+		wantErr bool
+	}{
+		{"100 MOBI FOR 50 XLM WITH bob", &Swap{Amount: "100", Selling: "MOBI", Price: "50", Buying: "XLM", Counterparty: "bob"}, false},
+		{"2.5 xlm for 10 mobi with bob from master", &Swap{Amount: "2.5", Selling: "xlm", Price: "10", Buying: "mobi", Counterparty: "bob", Account: "master"}, false},
+		{"100 MOBI FOR XLM WITH bob", nil, true},
+		{"100 MOBI FOR 50 XLM", nil, true},
+		{"100 MOBI FOR 50 XLM WITH bob FROM", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			s, err := ParseSwap(test.input)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.want, s)
+		})
+	}
+}
+
+func TestParseEscrow(t *testing.T) {
+	newYear := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		input   string
+		want    *Escrow
+		wantErr bool
+	}{
+		{"1000 XLM FROM master TO bob UNLOCK AFTER 2025-01-01", &Escrow{Amount: "1000", Currency: "XLM", From: "master", To: "bob", UnlockAfter: newYear}, false},
+		{"10 xlm to bob unlock after 2025-01-01", &Escrow{Amount: "10", Currency: "xlm", To: "bob", UnlockAfter: newYear}, false},
+		{"1000 XLM FROM master TO bob UNLOCK 2025-01-01", nil, true},
+		{"1000 XLM FROM master TO bob UNLOCK AFTER tomorrow", nil, true},
+		{"1000 XLM FROM master UNLOCK AFTER 2025-01-01", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			e, err := ParseEscrow(test.input)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.want, e)
+		})
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	var tests = []struct {
+		input       string
+		token       string
+		pos         int
+		suggestions []string
+	}{
+		{"sned 10 XLM from master to bob", "sned", 0, []string{"send"}},
+		{"send 10 XLM frm master to bob", "frm", 12, []string{"from"}},
+		{"send 10 XLM from master tp bob", "tp", 24, []string{"to", "up"}},
+		{"send 10 XLM from master to", "", 26, nil},
+		{"withdrw 10 USD via anchor.com", "withdrw", 0, []string{"withdraw"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, err := Parse(test.input)
+			require.Error(t, err)
+
+			serr, ok := err.(*SyntaxError)
+			require.True(t, ok, "%T", err)
+			require.Equal(t, test.input, serr.Query)
+			require.Equal(t, test.token, serr.Token)
+			require.Equal(t, test.pos, serr.Pos)
+			require.Equal(t, test.suggestions, serr.Suggestions)
+		})
+	}
+}
+
+func TestSyntaxErrorMessage(t *testing.T) {
+	_, err := Parse("send 10 XLM frm a to b")
+	require.Error(t, err)
+
+	lines := strings.Split(err.Error(), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, "  send 10 XLM frm a to b", lines[1])
+	require.Equal(t, "              ^^^", lines[2])
+	require.Equal(t, "did you mean 'from'?", lines[3])
+}
+
+func TestSuggest(t *testing.T) {
+	require.Equal(t, []string{"master"}, Suggest("mastr", []string{"savings", "master"}))
+	require.Equal(t, []string{"MOBI"}, Suggest("mobj", []string{"XLM", "MOBI"}))
+	require.Equal(t, []string{"sell", "set"}, Suggest("sel", []string{"send", "sell", "set"}))
+	require.Empty(t, Suggest("x", []string{"xlm"}))
+	require.Empty(t, Suggest("master", []string{"master"}))
+}
+
+func TestParseWithLocale(t *testing.T) {
+	var tests = []struct {
+		input    string
+		lang     string
+		wantData Statement
+	}{
+		{"envoie 20 XLM de master à jennifer mémo \"dîner\" valable pour 10 minutes", "fr", &SendRequest{
+			Amount:     "20",
+			Currency:   "XLM",
+			From:       "master",
+			To:         "jennifer",
+			Memo:       "dîner",
+			TimeBounds: TimeBounds{ValidFor: 10 * time.Minute},
+		}},
+		{"Envoie 50% des MOBI de master à alice et bob", "fr", &SendRequest{
+			Percent:  "50",
+			Currency: "MOBI",
+			From:     "master",
+			To:       "alice",
+			AlsoTo:   []string{"bob"},
+		}},
+		{"achète 100 MOBI contre XLM avec master expire dans 2 jours", "fr", &Offer{
+			kind:       BuyOfferKind,
+			Amount:     "100",
+			AmountKind: AmountBuyKind,
+			Buying:     "MOBI",
+			Selling:    "XLM",
+			Account:    "master",
+			Expires:    48 * time.Hour,
+		}},
+		{"envía 20 XLM desde master para jennifer", "es", &SendRequest{
+			Amount:   "20",
+			Currency: "XLM",
+			From:     "master",
+			To:       "jennifer",
+		}},
+		// English keywords remain available
+		{"send 20 XLM desde master to jennifer", "es", &SendRequest{
+			Amount:   "20",
+			Currency: "XLM",
+			From:     "master",
+			To:       "jennifer",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			statement, err := ParseWithLocale(test.input, Latest, test.lang)
+			require.NoError(t, err)
+			require.Equal(t, test.wantData, statement)
+		})
+	}
+
+	// the words of a locale are identifiers in English
+	_, err := ParseWithLocale("envoie 20 XLM de master à jennifer", Latest, "en")
+	require.Error(t, err)
+
+	_, err = ParseWithLocale("send 20 XLM", Latest, "xx")
+	require.Error(t, err)
+
+	// a misspelled word of the locale is suggested
+	_, err = ParseWithLocale("envoie 20 XLM dpuis master à jennifer", Latest, "fr")
+	require.Error(t, err)
+	require.Contains(t, err.(*SyntaxError).Suggestions, "depuis")
+}
+
+func TestRegisterLocale(t *testing.T) {
+	require.Error(t, RegisterLocale("xx", Locale{Keywords: map[string]string{"foo": "BAR"}}))
+	require.Error(t, RegisterLocale("xx", Locale{Units: map[string]string{"foo": "year"}}))
+	require.NotContains(t, Locales(), "xx")
+}
+
+func TestAmounts(t *testing.T) {
+	var tests = []struct {
+		input    string
+		wantData Statement
+		wantErr  bool
+	}{
+		{"send 1.5k XLM from master to bob", &SendRequest{Amount: "1500", Currency: "XLM", From: "master", To: "bob"}, false},
+		{"send 100+50 XLM to bob", &SendRequest{Amount: "150", Currency: "XLM", To: "bob"}, false},
+		{"send (10+5)*2.5/3 MOBI to bob", &SendRequest{Amount: "12.5", Currency: "MOBI", To: "bob"}, false},
+		{"send 0.1+0.2 XLM to bob", &SendRequest{Amount: "0.3", Currency: "XLM", To: "bob"}, false},
+		{"send 2m XLM to bob", &SendRequest{Amount: "2000000", Currency: "XLM", To: "bob"}, false},
+		{"send 2500000 stroops to bob", &SendRequest{Amount: "0.25", Currency: "XLM", To: "bob"}, false},
+		{"send 1 stroop to bob", &SendRequest{Amount: "0.0000001", Currency: "XLM", To: "bob"}, false},
+		{"send 1k stroops to bob", &SendRequest{Amount: "0.0001", Currency: "XLM", To: "bob"}, false},
+		{"buy 1.5k MOBI using XLM", &Offer{kind: BuyOfferKind, Amount: "1500", AmountKind: AmountBuyKind, Buying: "MOBI", Selling: "XLM"}, false},
+		{"buy MOBI using 50+50 XLM", &Offer{kind: BuyOfferKind, Amount: "100", AmountKind: AmountSellKind, Buying: "MOBI", Selling: "XLM"}, false},
+		{"send 100/3 XLM to bob", nil, true},
+		{"send 0.5 stroops to bob", nil, true},
+		{"send 10-20 XLM to bob", nil, true},
+		{"send 10/0 XLM to bob", nil, true},
+		{"send 10+ XLM to bob", nil, true},
+		{"send (10+5 XLM to bob", nil, true},
+		{"send 50% stroops to bob", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			statement, err := Parse(test.input)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.wantData, statement)
+		})
+	}
+}
+
+func TestPriceGuards(t *testing.T) {
+	var tests = []struct {
+		input    string
+		version  Version
+		wantData Statement
+		wantErr  bool
+	}{
+		{"sell 100 MOBI for XLM no lower than 0.09", Latest, &Offer{
+			kind:       SellOfferKind,
+			Amount:     "100",
+			AmountKind: AmountBuyKind,
+			Selling:    "MOBI",
+			Buying:     "XLM",
+			MinPrice:   "0.09",
+		}, false},
+		{"buy 100 MOBI using XLM not higher than 0.2 with master", Latest, &Offer{
+			kind:       BuyOfferKind,
+			Amount:     "100",
+			AmountKind: AmountBuyKind,
+			Buying:     "MOBI",
+			Selling:    "XLM",
+			MaxPrice:   "0.2",
+			Account:    "master",
+		}, false},
+		{"sell 100 MOBI for XLM no lower than 0.09 not before 2024-01-01", Latest, &Offer{
+			kind:       SellOfferKind,
+			Amount:     "100",
+			AmountKind: AmountBuyKind,
+			Selling:    "MOBI",
+			Buying:     "XLM",
+			MinPrice:   "0.09",
+			TimeBounds: TimeBounds{NotBefore: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)},
+		}, false},
+		{"sell 100 MOBI for XLM no higher than 0.09", Latest, nil, true},
+		{"buy 100 MOBI using XLM no lower than 0.09", Latest, nil, true},
+		{"sell 100 MOBI for XLM no lower 0.09", Latest, nil, true},
+		{"sell 100 MOBI for XLM no before 2024-01-01", Latest, nil, true},
+		{"sell 100 MOBI for XLM no lower than 0.09", V12, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			statement, err := ParseWithVersion(test.input, test.version)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.wantData, statement)
+		})
+	}
+}
+
+func TestTrust(t *testing.T) {
+	var tests = []struct {
+		input    string
+		version  Version
+		wantData Statement
+		wantErr  bool
+	}{
+		{"trust MOBI up to 5000 on master", Latest, &TrustRequest{
+			Account: "master",
+			Asset:   "MOBI",
+			Limit:   "5000",
+		}, false},
+		{"trust MOBI on master up to 5k", Latest, &TrustRequest{
+			Account: "master",
+			Asset:   "MOBI",
+			Limit:   "5000",
+		}, false},
+		{"trust MOBI", Latest, &TrustRequest{Asset: "MOBI"}, false},
+		{"lower trust of MOBI on master to 100", Latest, &TrustRequest{
+			Account: "master",
+			Asset:   "MOBI",
+			Limit:   "100",
+			Lower:   true,
+		}, false},
+		{"lower trust of MOBI to 0", Latest, &TrustRequest{
+			Asset: "MOBI",
+			Limit: "0",
+			Lower: true,
+		}, false},
+		{"lower trust of MOBI on master", Latest, nil, true},
+		{"lower trust of MOBI up to 100", Latest, nil, true},
+		{"trust MOBI to 100", Latest, nil, true},
+		{"trust MOBI up to XLM", Latest, nil, true},
+		{"trust MOBI up to 5000 on master", V13, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			statement, err := ParseWithVersion(test.input, test.version)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.wantData, statement)
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	var tests = []struct {
+		input    string
+		version  Version
+		wantData Statement
+		wantErr  bool
+	}{
+		{"authorize bob for MYTOKEN", Latest, &AuthorizeRequest{
+			Trustor: "bob",
+			Asset:   "MYTOKEN",
+		}, false},
+		{"AUTHORIZE GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6 FOR MYTOKEN WITH issuer", Latest, &AuthorizeRequest{
+			Trustor: "GDFFR7EZ3AYX6KWZFHDUCUTVYZFVMQX4XKBNUD5BLEWTG3UISJWM6SA6",
+			Asset:   "MYTOKEN",
+			Issuer:  "issuer",
+		}, false},
+		{"deauthorize bob for MYTOKEN with issuer", Latest, &AuthorizeRequest{
+			Trustor: "bob",
+			Asset:   "MYTOKEN",
+			Issuer:  "issuer",
+			Revoke:  true,
+		}, false},
+		{"authorize bob", Latest, nil, true},
+		{"authorize bob for", Latest, nil, true},
+		{"authorize bob for MYTOKEN with", Latest, nil, true},
+		{"authorize bob for MYTOKEN on issuer", Latest, nil, true},
+		{"authorize bob for MYTOKEN", V14, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			statement, err := ParseWithVersion(test.input, test.version)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.wantData, statement)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package parser
+
+import "strings"
+
+// Quote is a payment to price before sending it through a path, such as
+// 100 USDC FROM master TO bob
+type Quote struct {
+	Amount   string
+	Currency string
+	From, To string
+}
+
+// ParseQuote parses the payment of a quote
+func ParseQuote(in string) (*Quote, error) {
+	l := &lexer{reader: strings.NewReader(in), version: Latest}
+	q := &Quote{}
+
+	var err error
+	if q.Amount, err = parseExpect(l, tokenNumber); err != nil {
+		return nil, err
+	}
+	if q.Currency, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+
+	tok, err := parseTokenExpect(l, tokenFrom, tokenTo)
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == tokenFrom {
+		if q.From, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+			return nil, err
+		}
+		if _, err := parseExpect(l, tokenTo); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.To, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+	if _, err := parseExpect(l, tokenEof); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
@@ -0,0 +1,41 @@
+package parser
+
+// AuthorizeRequest lets an account hold an asset of the issuer, or revokes it:
+// AUTHORIZE GXXX FOR MYTOKEN [WITH issuer]
+// DEAUTHORIZE GXXX FOR MYTOKEN [WITH issuer]
+type AuthorizeRequest struct {
+	// Trustor is the account holding the trustline
+	Trustor string
+	Asset   string
+	// Issuer is the wallet issuing the asset
+	Issuer string
+	// Revoke is set by DEAUTHORIZE
+	Revoke bool
+}
+
+func (s *AuthorizeRequest) Kind() Kind {
+	return AuthorizeKind
+}
+
+func (s *AuthorizeRequest) parse(l *lexer) (err error) {
+	if s.Trustor, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return err
+	}
+	if _, err := parseExpect(l, tokenFOR); err != nil {
+		return err
+	}
+	if s.Asset, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return err
+	}
+
+	tok, err := parseTokenExpect(l, tokenWith, tokenEof)
+	if err != nil || tok.kind == tokenEof {
+		return err
+	}
+
+	if s.Issuer, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return err
+	}
+	_, err = parseExpect(l, tokenEof)
+	return err
+}
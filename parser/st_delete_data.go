@@ -0,0 +1,31 @@
+package parser
+
+// DeleteDataRequest removes data entries from an account:
+// DELETE DATA foo, "hello world" [FROM master]
+type DeleteDataRequest struct {
+	Account string
+	Keys    []string
+}
+
+func (s *DeleteDataRequest) Kind() Kind {
+	return DeleteDataKind
+}
+
+func (s *DeleteDataRequest) parse(l *lexer) error {
+	if _, err := parseExpect(l, tokenDATA); err != nil {
+		return err
+	}
+
+	var err error
+	var end tokenKind
+	if s.Keys, end, err = parseList(l, []tokenKind{tokenIdent, tokenSTRING}, tokenFrom); err != nil || end != tokenFrom {
+		return err
+	}
+
+	if s.Account, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return err
+	}
+
+	_, err = parseExpect(l, tokenEof)
+	return err
+}
@@ -2,6 +2,8 @@ package parser
 
 import (
 	"fmt"
+	"strings"
+	"time"
 )
 
 type Offer struct {
@@ -11,7 +13,19 @@ type Offer struct {
 	Buying     string
 	Selling    string
 	Price      string
-	kind       Kind
+	// Expires is how long the offer stays on the order book before alfred
+	// daemon cancels it, zero means until it is filled
+	Expires time.Duration
+	// Passive offers do not take the offers at the same price, so that
+	// market makers can place both sides
+	Passive bool
+	// MinPrice is the lowest price of a SELL, set by NO LOWER THAN, and
+	// MaxPrice the highest price of a BUY, set by NO HIGHER THAN. They are
+	// in the same unit as Price.
+	MinPrice, MaxPrice string
+	kind               Kind
+
+	TimeBounds
 }
 
 type AmountOfferKind int
@@ -27,6 +41,9 @@ func (s *Offer) Kind() Kind {
 
 func (s *Offer) parse(l *lexer) (err error) {
 	tok, err := l.Next()
+	if err == nil {
+		tok, err = amountToken(l, tok)
+	}
 	if err != nil {
 		return err
 	}
@@ -69,6 +86,9 @@ loop:
 		case tokenUSING, tokenFOR:
 		checkCurrency:
 			tok, err := parseTokenExpect(l, tokenIdent, tokenSTRING, tokenNumber)
+			if err == nil {
+				tok, err = amountToken(l, tok)
+			}
 			if err != nil {
 				return err
 			}
@@ -92,6 +112,14 @@ loop:
 			}
 		case tokenWith:
 			s.Account, err = parseExpect(l, tokenIdent, tokenSTRING)
+		case tokenVALID:
+			err = parseTimeBounds(l, tok, &s.TimeBounds)
+		case tokenNOT, tokenIdent:
+			err = s.parseNot(l, tok)
+		case tokenEXPIRES:
+			if _, err = parseExpect(l, tokenIN); err == nil {
+				s.Expires, err = parseDuration(l)
+			}
 		case tokenEof:
 			break loop
 		default:
@@ -102,9 +130,79 @@ loop:
 		}
 	}
 
+	if s.Passive && s.Price == "" {
+		return fmt.Errorf("the price of a passive offer should be given with %v", tokenAT)
+	}
+
 	return nil
 }
 
+// parseNot parses NOT BEFORE, or the price guards NO LOWER THAN and NO
+// HIGHER THAN, tok being NOT or NO
+func (s *Offer) parseNot(l *lexer, tok *token) error {
+	guards := keywordAvailable(tokenLOWER, l.version)
+	if tok.kind == tokenIdent && (!guards || !strings.EqualFold(tok.value, "no")) {
+		return fmt.Errorf("expected '%v' or '%v' but got '%s'", tokenAT, tokenUSING, tok)
+	}
+
+	expected := []tokenKind{tokenBEFORE}
+	if tok.kind == tokenIdent {
+		expected = nil
+	}
+	if guards {
+		expected = append(expected, tokenLOWER, tokenHIGHER)
+	}
+
+	next, err := parseTokenExpect(l, expected...)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case next.kind == tokenBEFORE:
+		return parseNotBefore(l, &s.TimeBounds)
+	case next.kind == tokenLOWER && s.kind != SellOfferKind:
+		return fmt.Errorf("NO LOWER THAN limits the price of SELL, the price of BUY is limited with NO HIGHER THAN")
+	case next.kind == tokenHIGHER && s.kind != BuyOfferKind:
+		return fmt.Errorf("NO HIGHER THAN limits the price of BUY, the price of SELL is limited with NO LOWER THAN")
+	}
+
+	if _, err := parseExpect(l, tokenTHAN); err != nil {
+		return err
+	}
+	price, err := parseExpect(l, tokenNumber)
+	if err != nil {
+		return err
+	}
+
+	if next.kind == tokenLOWER {
+		s.MinPrice = price
+	} else {
+		s.MaxPrice = price
+	}
+	return nil
+}
+
+// parsePassiveOffer parses the start of PLACE PASSIVE OFFER BUY or SELL, PLACE being read
+func parsePassiveOffer(l *lexer) (*Offer, error) {
+	if _, err := parseExpect(l, tokenPASSIVE); err != nil {
+		return nil, err
+	}
+	if _, err := parseExpect(l, tokenOFFER); err != nil {
+		return nil, err
+	}
+
+	tok, err := parseTokenExpect(l, tokenBUY, tokenSELL)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.kind == tokenBUY {
+		return &Offer{kind: BuyOfferKind, Passive: true}, nil
+	}
+	return &Offer{kind: SellOfferKind, Passive: true}, nil
+}
+
 func parseExpect(l *lexer, expected ...tokenKind) (string, error) {
 	tok, err := parseTokenExpect(l, expected...)
 	if err != nil {
@@ -0,0 +1,45 @@
+package parser
+
+import "fmt"
+
+// RemoveSignerRequest removes signers from an account, the inverse of SHARE:
+// REMOVE SIGNER bob, carol FROM master [REQUIRING <m> OF <n>]
+type RemoveSignerRequest struct {
+	Account string
+	Signers []string
+
+	// Required and Total are set by REQUIRING <m> OF <n>, otherwise the
+	// thresholds are only lowered when the signers left can not meet them
+	Required, Total int
+}
+
+func (s *RemoveSignerRequest) Kind() Kind {
+	return RemoveSignerKind
+}
+
+func (s *RemoveSignerRequest) parse(l *lexer) error {
+	if _, err := parseExpect(l, tokenSIGNER); err != nil {
+		return err
+	}
+
+	var err error
+	var end tokenKind
+	if s.Signers, end, err = parseList(l, []tokenKind{tokenIdent}, tokenFrom); err != nil {
+		return err
+	}
+	if end != tokenFrom {
+		return fmt.Errorf("expected '%v' after the signers", tokenFrom)
+	}
+
+	if s.Account, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return err
+	}
+
+	tok, err := parseTokenExpect(l, tokenEof, tokenREQUIRING)
+	if err != nil || tok.kind == tokenEof {
+		return err
+	}
+
+	s.Required, s.Total, err = parseRequiring(l)
+	return err
+}
@@ -1,52 +1,185 @@
 package parser
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type SendRequest struct {
-	Amount   string
+	Amount string
+	// Percent is the percentage of the balance sent instead of Amount,
+	// such as "50" for 50% OF or "100" for ALL
+	Percent string
+	// Currency is empty when it is not given, such as in SEND 20 TO jennifer
+	// since V16, to be detected from the accounts
 	Currency string
 	From, To string
+	// AlsoTo lists the other recipients, from TO alice, bob AND carol since
+	// V17. Each of them receives Amount.
+	AlsoTo []string
+	Memo   string
+	// StartingBalance is the amount of XLM used to create the destination
+	// if it does not exist, in addition to the amount sent
+	StartingBalance string
+
+	TimeBounds
 }
 
 func (s *SendRequest) Kind() Kind {
 	return SendKind
 }
 
+// Recipients returns every recipient, starting with To
+func (s *SendRequest) Recipients() []string {
+	if s.To == "" {
+		return nil
+	}
+
+	return append([]string{s.To}, s.AlsoTo...)
+}
+
 func (s *SendRequest) parse(l *lexer) error {
-	for i := 0; i < 2; i++ {
+	var (
+		of bool
+		// clause is the FROM, TO or end read in place of the currency
+		clause *token
+	)
+	for i := 0; i < 2 && clause == nil; i++ {
 		tok, err := l.Next()
+		if err == nil {
+			tok, err = amountToken(l, tok)
+		}
 		if err != nil {
 			return err
 		}
 
-		switch tok.kind {
-		case tokenNumber:
+		switch {
+		case tok.kind == tokenNumber:
 			s.Amount = tok.value
-		case tokenIdent:
+		case tok.kind == tokenALL:
+			s.Percent = "100"
+		case tok.kind == tokenIdent && strings.HasSuffix(tok.value, "%") && keywordAvailable(tokenOF, l.version):
+			s.Percent, err = parsePercent(tok.value)
+			if err != nil {
+				return err
+			}
+		case tok.kind == tokenOF && s.Percent != "" && s.Currency == "":
+			i-- // 50% OF MOBI
+			of = true
+		case i == 1 && !of && available(V16, l.version) && (tok.kind == tokenFrom || tok.kind == tokenTo || tok.kind == tokenEof):
+			clause = tok
+		case tok.kind == tokenIdent:
 			s.Currency = tok.value
 		default:
 			return fmt.Errorf("unexpected token '%v' for '%s', should be AMOUNT CURRENCY", tok.kind, tok.value)
 		}
 	}
 
+	if s.Amount != "" && s.Percent != "" {
+		return fmt.Errorf("either an amount or a percentage should be given, got '%s' and '%s%%'", s.Amount, s.Percent)
+	}
+
+	if available(V18, l.version) && (strings.EqualFold(s.Currency, "stroops") || strings.EqualFold(s.Currency, "stroop")) {
+		if s.Amount == "" {
+			return fmt.Errorf("an amount of stroops should be given, got '%s%%'", s.Percent)
+		}
+
+		var err error
+		if s.Amount, err = stroopsToXLM(s.Amount); err != nil {
+			return err
+		}
+		s.Currency = "XLM"
+	}
+
 	var (
-		tok *token
-		err error
+		tok  = clause
+		err  error
+		prev tokenKind
 	)
-	for tok, err = l.Next(); err == nil && tok.kind != tokenEof; tok, err = l.Next() {
+	unexpected := func(tok *token) error {
+		return fmt.Errorf("unexpected token '%v' for '%s', should be only %s keywords", tok.kind, tok.value, keywordList(l.version, tokenFrom, tokenTo, tokenMEMO, tokenVALID, tokenNOT, tokenCREATE))
+	}
+	if tok == nil {
+		tok, err = l.Next()
+	}
+	for ; err == nil && tok.kind != tokenEof; tok, err = l.Next() {
 		switch tok.kind {
 		case tokenFrom:
 			s.From, err = parseIdent(l)
 		case tokenTo:
 			s.To, err = parseIdent(l)
+		case tokenCOMMA, tokenAND:
+			if !available(V17, l.version) {
+				return unexpected(tok)
+			}
+			if prev != tokenTo {
+				return fmt.Errorf("unexpected token '%v' for '%s', recipients should follow '%v'", tok.kind, tok.value, tokenTo)
+			}
+
+			to, err := parseIdent(l)
+			if err != nil {
+				return err
+			}
+			s.AlsoTo = append(s.AlsoTo, to)
+			continue // more recipients can follow
+		case tokenMEMO:
+			s.Memo, err = parseExpect(l, tokenSTRING, tokenIdent, tokenNumber)
+		case tokenVALID, tokenNOT:
+			err = parseTimeBounds(l, tok, &s.TimeBounds)
+		case tokenCREATE:
+			s.StartingBalance, err = parseStartingBalance(l)
 		default:
-			return fmt.Errorf("unexpected token '%v' for '%s', should be only FROM and TO keywords", tok.kind, tok.value)
+			return unexpected(tok)
 		}
 
 		if err != nil {
 			return err
 		}
+		prev = tok.kind
 	}
 
 	return nil
 }
+
+// parseStartingBalance parses WITH AMOUNT XLM STARTING BALANCE, following CREATE
+func parseStartingBalance(l *lexer) (string, error) {
+	if _, err := parseExpect(l, tokenWith); err != nil {
+		return "", err
+	}
+
+	amount, err := parseExpect(l, tokenNumber)
+	if err != nil {
+		return "", err
+	}
+
+	currency, err := parseExpect(l, tokenIdent)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.EqualFold(currency, "XLM") {
+		return "", fmt.Errorf("starting balance should be in XLM, got '%s'", currency)
+	}
+
+	if _, err := parseExpect(l, tokenSTARTING); err != nil {
+		return "", err
+	}
+
+	if _, err := parseExpect(l, tokenBALANCE); err != nil {
+		return "", err
+	}
+
+	return amount, nil
+}
+
+// parsePercent parses a percentage such as 50% and returns 50
+func parsePercent(value string) (string, error) {
+	percent := strings.TrimSuffix(value, "%")
+	f, err := strconv.ParseFloat(percent, 64)
+	if err != nil || f <= 0 || f > 100 {
+		return "", fmt.Errorf("invalid percentage '%s', should be between 0%% and 100%%", value)
+	}
+
+	return percent, nil
+}
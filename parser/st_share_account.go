@@ -2,11 +2,16 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
 )
 
 type ShareAccountRequest struct {
 	Account            string
 	AdditionnalSigners []string
+
+	// Required and Total are set by REQUIRING <m> OF <n>: any Required of the
+	// Total signers, the account itself included, can authorize any operation
+	Required, Total int
 }
 
 func (s *ShareAccountRequest) Kind() Kind {
@@ -19,17 +24,18 @@ func (s *ShareAccountRequest) parse(l *lexer) error {
 		return err
 	}
 
-	switch tok.kind {
-	case tokenACCOUNT:
+	switch {
+	case tok.kind == tokenACCOUNT:
+		s.Account, err = parseIdent(l)
+		if err != nil {
+			return err
+		}
+	case tok.kind == tokenIdent && keywordAvailable(tokenREQUIRING, l.version): // ACCOUNT is optional since V10
+		s.Account = tok.value
 	default:
 		return fmt.Errorf("unexpected token '%v' for '%s', should be ACCOUNT", tok.kind, tok.value)
 	}
 
-	s.Account, err = parseIdent(l)
-	if err != nil {
-		return err
-	}
-
 	tok, err = l.Next()
 	if err != nil {
 		return err
@@ -41,12 +47,49 @@ func (s *ShareAccountRequest) parse(l *lexer) error {
 		return fmt.Errorf("unexpected token '%v' for '%s', should be WITH", tok.kind, tok.value)
 	}
 
-	s.AdditionnalSigners, err = parseList(l, tokenIdent)
+	var end tokenKind
+	s.AdditionnalSigners, end, err = parseList(l, []tokenKind{tokenIdent}, tokenREQUIRING)
+	if err != nil || end != tokenREQUIRING {
+		return err
+	}
 
+	s.Required, s.Total, err = parseRequiring(l)
 	return err
 }
 
-func parseList(l *lexer, kind tokenKind) (list []string, err error) {
+// parseRequiring parses <m> OF <n> after REQUIRING, which ends the statement
+func parseRequiring(l *lexer) (required, total int, err error) {
+	m, err := parseExpect(l, tokenNumber)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := parseExpect(l, tokenOF); err != nil {
+		return 0, 0, err
+	}
+	n, err := parseExpect(l, tokenNumber)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := parseExpect(l, tokenEof); err != nil {
+		return 0, 0, err
+	}
+
+	if required, err = strconv.Atoi(m); err != nil || required < 1 {
+		return 0, 0, fmt.Errorf("invalid number of required signers '%s'", m)
+	}
+	if total, err = strconv.Atoi(n); err != nil || total < 1 {
+		return 0, 0, fmt.Errorf("invalid number of signers '%s'", n)
+	}
+	if required > total {
+		return 0, 0, fmt.Errorf("requiring %d of %d signers would lock the account", required, total)
+	}
+
+	return required, total, nil
+}
+
+// parseList parses a list of kinds separated by commas or AND, until the end
+// of the input or one of stop, which is returned
+func parseList(l *lexer, kinds []tokenKind, stop ...tokenKind) (list []string, end tokenKind, err error) {
 
 	var (
 		tok *token
@@ -58,40 +101,41 @@ loop:
 
 		tok, err = l.Next()
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
-		switch tok.kind {
-		case kind:
+		switch {
+		case containsKind(tok.kind, kinds):
 			list = append(list, tok.value)
-		case tokenEof:
+		case tok.kind == tokenEof:
 			if len(list) > 0 {
-				return nil, fmt.Errorf("unexpected token: '%v', should be: '%v'", tok, tokenIdent)
+				return nil, 0, fmt.Errorf("unexpected token: '%v', should be: '%v'", tok, tokenIdent)
 			}
 			break loop
 		default:
-			return nil, fmt.Errorf("unexpected token: '%v', should be: '%v'", tok, tokenIdent)
+			return nil, 0, fmt.Errorf("unexpected token: '%v', should be: '%v'", tok, tokenIdent)
 		}
 
 		// separator
 
 		tok, err = l.Next()
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
-		switch tok.kind {
-		case tokenCOMMA, tokenAND:
+		switch {
+		case tok.kind == tokenCOMMA, tok.kind == tokenAND:
 			// continue
-		case tokenEof:
+		case tok.kind == tokenEof, containsKind(tok.kind, stop):
+			end = tok.kind
 			break loop
 		default:
-			return nil, fmt.Errorf("unexpected token: '%v', should be: '%v' or '%v'", tok, tokenCOMMA, tokenAND)
+			return nil, 0, fmt.Errorf("unexpected token: '%v', should be: '%v' or '%v'", tok, tokenCOMMA, tokenAND)
 		}
 	}
 
 	if len(list) == 0 {
-		return nil, fmt.Errorf("got empty list")
+		return nil, 0, fmt.Errorf("got empty list")
 	}
 
 	return
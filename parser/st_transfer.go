@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"fmt"
+)
+
+// Transfer moves an asset between a bank account and a wallet through an
+// anchor, such as DEPOSIT 100 USD VIA anchor.com INTO master or
+// WITHDRAW 200 USDC FROM master TO bank VIA anchor.com
+type Transfer struct {
+	// Amount is optional for deposits, the anchor asks for it when it is not given
+	Amount   string
+	Currency string
+	// Anchor is the domain of the anchor
+	Anchor string
+	// Account is the wallet receiving the deposit or sending the withdrawal
+	Account string
+	// Destination is the kind of account receiving a withdrawal, such as bank
+	Destination string
+	kind        Kind
+}
+
+func (s *Transfer) Kind() Kind {
+	return s.kind
+}
+
+func (s *Transfer) parse(l *lexer) error {
+	tok, err := parseTokenExpect(l, tokenNumber, tokenIdent)
+	if err != nil {
+		return err
+	}
+
+	if tok.kind == tokenNumber {
+		s.Amount = tok.value
+		if s.Currency, err = parseIdent(l); err != nil {
+			return err
+		}
+	} else {
+		s.Currency = tok.value
+	}
+
+	for tok, err = l.Next(); err == nil && tok.kind != tokenEof; tok, err = l.Next() {
+		switch {
+		case tok.kind == tokenVIA:
+			s.Anchor, err = parseIdent(l)
+		case tok.kind == tokenINTO && s.kind == DepositKind:
+			s.Account, err = parseIdent(l)
+		case tok.kind == tokenFrom && s.kind == WithdrawKind:
+			s.Account, err = parseIdent(l)
+		case tok.kind == tokenTo && s.kind == WithdrawKind:
+			s.Destination, err = parseIdent(l)
+		case s.kind == WithdrawKind:
+			return fmt.Errorf("unexpected token '%v' for '%s', should be %s", tok.kind, tok.value, keywordList(l.version, tokenFrom, tokenTo, tokenVIA))
+		default:
+			return fmt.Errorf("unexpected token '%v' for '%s', should be %s", tok.kind, tok.value, keywordList(l.version, tokenVIA, tokenINTO))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.Anchor == "" {
+		return fmt.Errorf("the anchor should be given with %v", tokenVIA)
+	}
+
+	if s.kind == WithdrawKind && s.Amount == "" {
+		return fmt.Errorf("the amount to withdraw should be given, such as WITHDRAW 200 %s", s.Currency)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package parser
+
+import "fmt"
+
+// TrustRequest adds a trustline or changes its limit:
+// TRUST MOBI [UP TO 5000] ON master
+// LOWER TRUST OF MOBI ON master TO 100
+type TrustRequest struct {
+	Account string
+	Asset   string
+	// Limit is the highest balance of the asset the account accepts, empty
+	// for the largest limit of the network
+	Limit string
+	// Lower is set by LOWER TRUST, whose limit should be below the current one
+	Lower bool
+}
+
+func (s *TrustRequest) Kind() Kind {
+	return TrustKind
+}
+
+func (s *TrustRequest) parse(l *lexer) (err error) {
+	if s.Lower {
+		if _, err := parseExpect(l, tokenOF); err != nil {
+			return err
+		}
+	}
+
+	if s.Asset, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return err
+	}
+
+	expected := []tokenKind{tokenON, tokenUP, tokenEof}
+	if s.Lower {
+		expected = []tokenKind{tokenON, tokenTo, tokenEof}
+	}
+
+	for {
+		tok, err := parseTokenExpect(l, expected...)
+		if err != nil {
+			return err
+		}
+
+		switch tok.kind {
+		case tokenON:
+			s.Account, err = parseExpect(l, tokenIdent, tokenSTRING)
+		case tokenUP:
+			if _, err = parseExpect(l, tokenTo); err == nil {
+				s.Limit, err = parseLimit(l)
+			}
+		case tokenTo:
+			s.Limit, err = parseLimit(l)
+		case tokenEof:
+			if s.Lower && s.Limit == "" {
+				return fmt.Errorf("the new limit of LOWER TRUST should be given with %v", tokenTo)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseLimit parses the limit of a trustline, which can be zero to remove it
+func parseLimit(l *lexer) (string, error) {
+	tok, err := l.Next()
+	if err == nil {
+		tok, err = amountToken(l, tok)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if tok.kind != tokenNumber {
+		return "", fmt.Errorf("expected the limit of the trustline but got '%s'", tok)
+	}
+	return tok.value, nil
+}
@@ -12,6 +12,12 @@ const (
 	SetDataKind
 	BuyOfferKind
 	SellOfferKind
+	DepositKind
+	WithdrawKind
+	RemoveSignerKind
+	DeleteDataKind
+	TrustKind
+	AuthorizeKind
 )
 
 type Statement interface {
@@ -0,0 +1,61 @@
+package parser
+
+import "strings"
+
+// Swap is an exchange of two payments proposed to a counterparty, such as
+// 100 MOBI FOR 50 XLM WITH bob FROM master, where master pays 100 MOBI to
+// bob and bob pays 50 XLM to master in the same transaction
+type Swap struct {
+	// Amount of Selling paid to the counterparty
+	Amount  string
+	Selling string
+	// Price is the amount of Buying paid by the counterparty
+	Price        string
+	Buying       string
+	Counterparty string
+	Account      string
+}
+
+// ParseSwap parses the proposal of a swap
+func ParseSwap(in string) (*Swap, error) {
+	l := &lexer{reader: strings.NewReader(in), version: Latest}
+	s := &Swap{}
+
+	var err error
+	if s.Amount, err = parseExpect(l, tokenNumber); err != nil {
+		return nil, err
+	}
+	if s.Selling, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+	if _, err := parseExpect(l, tokenFOR); err != nil {
+		return nil, err
+	}
+	if s.Price, err = parseExpect(l, tokenNumber); err != nil {
+		return nil, err
+	}
+	if s.Buying, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+	if _, err := parseExpect(l, tokenWith); err != nil {
+		return nil, err
+	}
+	if s.Counterparty, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+		return nil, err
+	}
+
+	tok, err := parseTokenExpect(l, tokenFrom, tokenEof)
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == tokenFrom {
+		if s.Account, err = parseExpect(l, tokenIdent, tokenSTRING); err != nil {
+			return nil, err
+		}
+		if _, err := parseExpect(l, tokenEof); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeBounds holds the VALID FOR and NOT BEFORE clauses of a statement
+type TimeBounds struct {
+	// ValidFor is how long the transaction stays valid, zero means not set
+	ValidFor time.Duration
+	// NotBefore is the time before which the transaction is invalid, zero means not set
+	NotBefore time.Time
+}
+
+var durationUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTimeBounds parses the clause starting with tok, which is either VALID or NOT
+func parseTimeBounds(l *lexer, tok *token, tb *TimeBounds) error {
+	switch tok.kind {
+	case tokenVALID:
+		if _, err := parseExpect(l, tokenFOR); err != nil {
+			return err
+		}
+
+		d, err := parseDuration(l)
+		if err != nil {
+			return err
+		}
+		tb.ValidFor = d
+	case tokenNOT:
+		if _, err := parseExpect(l, tokenBEFORE); err != nil {
+			return err
+		}
+		return parseNotBefore(l, tb)
+	default:
+		return fmt.Errorf("expected '%v' or '%v' but got '%s'", tokenVALID, tokenNOT, tok)
+	}
+
+	return nil
+}
+
+// parseNotBefore parses the date following NOT BEFORE
+func parseNotBefore(l *lexer, tb *TimeBounds) error {
+	value, err := parseExpect(l, tokenIdent, tokenSTRING)
+	if err != nil {
+		return err
+	}
+
+	t, err := parseDate(value)
+	if err != nil {
+		return err
+	}
+	tb.NotBefore = t
+	return nil
+}
+
+// parseDuration parses either a number followed by a unit (10 minutes) or a
+// duration such as 1h30m
+func parseDuration(l *lexer) (time.Duration, error) {
+	tok, err := parseTokenExpect(l, tokenNumber, tokenIdent)
+	if err != nil {
+		return 0, err
+	}
+
+	return parseDurationFrom(l, tok)
+}
+
+// parseDurationFrom parses a duration starting with tok, already read from l
+func parseDurationFrom(l *lexer, tok *token) (time.Duration, error) {
+	if tok.kind == tokenIdent {
+		d, err := time.ParseDuration(tok.value)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("invalid duration '%s'", tok.value)
+		}
+		return d, nil
+	}
+
+	n, err := strconv.ParseFloat(tok.value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid duration '%s'", tok.value)
+	}
+
+	unit, err := parseExpect(l, tokenIdent)
+	if err != nil {
+		return 0, err
+	}
+
+	d, ok := durationUnits[strings.TrimSuffix(strings.ToLower(unit), "s")]
+	if !ok {
+		d, ok = l.locale.unit(unit)
+	}
+	if !ok {
+		return 0, fmt.Errorf("unknown duration unit '%s', should be seconds, minutes, hours, days or weeks", unit)
+	}
+
+	return time.Duration(n * float64(d)), nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date '%s', should be like 2006-01-02 or 2006-01-02T15:04:05Z", value)
+}
@@ -26,16 +26,47 @@ const (
 	tokenFrom // FROM
 	tokenTo   // TO
 
-	tokenWith  // WITH
-	tokenWhere // WHERE
-	tokenAND   // AND
-	tokenSET   // SET
-	tokenDATA  // DATA
-	tokenBUY   // BUY
-	tokenAT    // AT
-	tokenFOR   // FOR
-	tokenSELL  // SELL
-	tokenUSING // USING
+	tokenWith      // WITH
+	tokenWhere     // WHERE
+	tokenAND       // AND
+	tokenSET       // SET
+	tokenDATA      // DATA
+	tokenBUY       // BUY
+	tokenAT        // AT
+	tokenFOR       // FOR
+	tokenSELL      // SELL
+	tokenUSING     // USING
+	tokenMEMO      // MEMO
+	tokenVALID     // VALID
+	tokenNOT       // NOT
+	tokenBEFORE    // BEFORE
+	tokenCREATE    // CREATE
+	tokenSTARTING  // STARTING
+	tokenBALANCE   // BALANCE
+	tokenALL       // ALL
+	tokenOF        // OF
+	tokenDEPOSIT   // DEPOSIT
+	tokenVIA       // VIA
+	tokenINTO      // INTO
+	tokenWITHDRAW  // WITHDRAW
+	tokenEXPIRES   // EXPIRES
+	tokenIN        // IN
+	tokenPLACE     // PLACE
+	tokenPASSIVE   // PASSIVE
+	tokenOFFER     // OFFER
+	tokenREQUIRING // REQUIRING
+	tokenREMOVE    // REMOVE
+	tokenSIGNER    // SIGNER
+	tokenDELETE    // DELETE
+	tokenLOWER     // LOWER
+	tokenHIGHER    // HIGHER
+	tokenTHAN      // THAN
+	tokenTRUST     // TRUST
+	tokenUP        // UP
+	tokenON        // ON
+
+	tokenAUTHORIZE   // AUTHORIZE
+	tokenDEAUTHORIZE // DEAUTHORIZE
 
 	_tokEndKeywords
 
@@ -50,6 +81,8 @@ const (
 type token struct {
 	kind  tokenKind
 	value string
+	// pos and end are the offsets of the token in the query
+	pos, end int
 }
 
 func (t token) String() string {
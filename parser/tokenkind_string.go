@@ -4,9 +4,9 @@ package parser
 
 import "strconv"
 
-const _tokenKind_name = "tokenUnknownEOFIDENTSTRING_tokStartKeywordsSELECTSENDSHAREACCOUNTFROMTOWITHWHEREANDSETDATABUYATFORSELLUSING_tokEndKeywordsNUMBERCOMMAEQUALQUOTES"
+const _tokenKind_name = "tokenUnknownEOFIDENTSTRING_tokStartKeywordsSELECTSENDSHAREACCOUNTFROMTOWITHWHEREANDSETDATABUYATFORSELLUSINGMEMOVALIDNOTBEFORECREATESTARTINGBALANCEALLOFDEPOSITVIAINTOWITHDRAWEXPIRESINPLACEPASSIVEOFFERREQUIRINGREMOVESIGNERDELETELOWERHIGHERTHANTRUSTUPONAUTHORIZEDEAUTHORIZE_tokEndKeywordsNUMBERCOMMAEQUALQUOTES"
 
-var _tokenKind_index = [...]uint8{0, 12, 15, 20, 26, 43, 49, 53, 58, 65, 69, 71, 75, 80, 83, 86, 90, 93, 95, 98, 102, 107, 122, 128, 133, 138, 144}
+var _tokenKind_index = [...]uint16{0, 12, 15, 20, 26, 43, 49, 53, 58, 65, 69, 71, 75, 80, 83, 86, 90, 93, 95, 98, 102, 107, 111, 116, 119, 125, 131, 139, 146, 149, 151, 158, 161, 165, 173, 180, 182, 187, 194, 199, 208, 214, 220, 226, 231, 237, 241, 246, 248, 250, 259, 270, 285, 291, 296, 301, 307}
 
 func (i tokenKind) String() string {
 	if i < 0 || i >= tokenKind(len(_tokenKind_index)-1) {
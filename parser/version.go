@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Version identifies a revision of the grammar.
+//
+// Keywords are tied to the version that introduced them. When parsing with an
+// older version, newer keywords are read as plain identifiers so that scripts
+// written against that version keep parsing identically.
+type Version int
+
+const (
+	// V1 is the original grammar: SEND, SHARE ACCOUNT, SET DATA, BUY and SELL
+	V1 Version = iota + 1
+	// V2 adds the MEMO clause to SEND
+	V2
+	// V3 adds the VALID FOR and NOT BEFORE clauses to SEND, BUY and SELL
+	V3
+	// V4 adds the CREATE WITH ... STARTING BALANCE clause to SEND
+	V4
+	// V5 adds ALL and percentages (50% OF) as amounts of SEND
+	V5
+	// V6 adds the DEPOSIT statement
+	V6
+	// V7 adds the WITHDRAW statement
+	V7
+	// V8 adds the EXPIRES IN clause to BUY and SELL
+	V8
+	// V9 adds PLACE PASSIVE OFFER before BUY and SELL
+	V9
+	// V10 adds the REQUIRING m OF n clause to SHARE, whose ACCOUNT keyword becomes optional
+	V10
+	// V11 adds the REMOVE SIGNER statement
+	V11
+	// V12 adds the DELETE DATA statement
+	V12
+	// V13 adds the NO LOWER THAN and NO HIGHER THAN clauses to SELL and BUY
+	V13
+	// V14 adds the TRUST and LOWER TRUST statements
+	V14
+	// V15 adds the AUTHORIZE and DEAUTHORIZE statements
+	V15
+	// V16 makes the asset of SEND optional, as in SEND 20 TO jennifer
+	V16
+	// V17 adds the lists of recipients to SEND, as in TO alice, bob AND carol
+	V17
+	// V18 adds the amounts written with the k and m suffixes, in stroops or as expressions such as 100+50
+	V18
+
+	// Latest is the version used by Parse
+	Latest = V18
+)
+
+// Versions lists every known version, oldest first
+var Versions = []Version{V1, V2, V3, V4, V5, V6, V7, V8, V9, V10, V11, V12, V13, V14, V15, V16, V17, V18}
+
+// keywordSince records the version that introduced a keyword.
+// Keywords not listed here are part of V1.
+var keywordSince = map[tokenKind]Version{
+	tokenMEMO:      V2,
+	tokenVALID:     V3,
+	tokenNOT:       V3,
+	tokenBEFORE:    V3,
+	tokenCREATE:    V4,
+	tokenSTARTING:  V4,
+	tokenBALANCE:   V4,
+	tokenALL:       V5,
+	tokenOF:        V5,
+	tokenDEPOSIT:   V6,
+	tokenVIA:       V6,
+	tokenINTO:      V6,
+	tokenWITHDRAW:  V7,
+	tokenEXPIRES:   V8,
+	tokenIN:        V8,
+	tokenPLACE:     V9,
+	tokenPASSIVE:   V9,
+	tokenOFFER:     V9,
+	tokenREQUIRING: V10,
+	tokenREMOVE:    V11,
+	tokenSIGNER:    V11,
+	tokenDELETE:    V12,
+	tokenLOWER:     V13,
+	tokenHIGHER:    V13,
+	tokenTHAN:      V13,
+	tokenTRUST:     V14,
+	tokenUP:        V14,
+	tokenON:        V14,
+
+	tokenAUTHORIZE:   V15,
+	tokenDEAUTHORIZE: V15,
+}
+
+// syntaxSince records the version that introduced a change of the grammar
+// which is not a keyword, as described by Syntax.
+var syntaxSince = map[string]Version{
+	"SEND without an asset, such as SEND 20 TO jennifer":          V16,
+	"SEND to several recipients, such as TO alice, bob AND carol": V17,
+	"amounts with the k and m suffixes, such as 1.5k":             V18,
+	"amounts as expressions, such as 100+50 or (10+5)*2":          V18,
+	"amounts of SEND in stroops, such as SEND 2500000 stroops":    V18,
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("v%d", int(v))
+}
+
+// ParseVersion parses a version such as "v1" or "2".
+// An empty string returns Latest.
+func ParseVersion(s string) (Version, error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "v")
+	if s == "" {
+		return Latest, nil
+	}
+
+	for _, v := range Versions {
+		if fmt.Sprint(int(v)) == s {
+			return v, nil
+		}
+	}
+
+	return 0, fmt.Errorf("parser: unknown grammar version '%s'", s)
+}
+
+// Keywords returns the keywords available in version v, sorted alphabetically.
+func Keywords(v Version) []string {
+	var keywords []string
+	for i := _tokStartKeywords + 1; i < _tokEndKeywords; i++ {
+		if keywordAvailable(i, v) {
+			keywords = append(keywords, i.String())
+		}
+	}
+	sort.Strings(keywords)
+
+	return keywords
+}
+
+// Syntax returns the changes of the grammar other than keywords available in
+// version v, sorted alphabetically.
+func Syntax(v Version) []string {
+	var syntax []string
+	for change, since := range syntaxSince {
+		if available(since, v) {
+			syntax = append(syntax, change)
+		}
+	}
+	sort.Strings(syntax)
+
+	return syntax
+}
+
+// keywordList formats the keywords available in version v, such as "FROM, TO and MEMO"
+func keywordList(v Version, kinds ...tokenKind) string {
+	var names []string
+	for _, kind := range kinds {
+		if keywordAvailable(kind, v) {
+			names = append(names, kind.String())
+		}
+	}
+
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+func keywordAvailable(kind tokenKind, v Version) bool {
+	since, ok := keywordSince[kind]
+	return !ok || available(since, v)
+}
+
+// available reports whether what was introduced in version since can be used in version v
+func available(since, v Version) bool {
+	if v == 0 {
+		v = Latest
+	}
+
+	return since <= v
+}
@@ -0,0 +1,177 @@
+// Package pricing estimates the price of a trade from an order book, walking
+// its levels so that large amounts get the depth-weighted average price.
+//
+// The order book should be loaded with the traded asset as selling (base)
+// and the other one as buying (counter): the prices are in counter per base,
+// the asks are the offers selling the base with amounts in base, and the bids
+// are the offers buying it with amounts in counter, as returned by horizon.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/celrenheit/alfred/decimal"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// ErrThin is returned when the order book can not fill the amount
+var ErrThin = errors.New("the order book is too thin to fill the amount")
+
+// Quote is the estimated result of a trade
+type Quote struct {
+	// Amount of base and Total of counter traded. The one computed from the
+	// other is rounded in favor of the order book, up when it is paid and
+	// down when it is received.
+	Amount decimal.Amount
+	Total  decimal.Amount
+	// BestPrice is the price of the first level and AveragePrice the one of the whole trade
+	BestPrice    *big.Rat
+	AveragePrice *big.Rat
+	// Slippage is the percentage by which the average price is worse than the best one
+	Slippage *big.Rat
+}
+
+// Buy estimates buying amount of base from the asks
+func Buy(asks []horizon.PriceLevel, amount decimal.Amount) (Quote, error) {
+	return walk(asks, amount, true, false)
+}
+
+// Spend estimates buying base from the asks with total of counter
+func Spend(asks []horizon.PriceLevel, total decimal.Amount) (Quote, error) {
+	return walk(asks, total, true, true)
+}
+
+// Sell estimates selling amount of base to the bids
+func Sell(bids []horizon.PriceLevel, amount decimal.Amount) (Quote, error) {
+	return walk(bids, amount, false, false)
+}
+
+// Receive estimates selling base to the bids until total of counter is received
+func Receive(bids []horizon.PriceLevel, total decimal.Amount) (Quote, error) {
+	return walk(bids, total, false, true)
+}
+
+// walk fills want, in base or in counter if inCounter is set, with the levels
+// best price first. The amounts of the asks are in base, the ones of the bids in counter.
+func walk(levels []horizon.PriceLevel, want decimal.Amount, asks, inCounter bool) (Quote, error) {
+	if want <= 0 {
+		return Quote{}, errors.New("the amount should be positive")
+	}
+
+	var (
+		best          *big.Rat
+		amount, total = new(big.Rat), new(big.Rat)
+		left          = want.Rat()
+	)
+	for _, level := range levels {
+		price, err := levelPrice(level)
+		if err != nil {
+			return Quote{}, err
+		}
+		available, err := decimal.Parse(level.Amount)
+		if err != nil {
+			return Quote{}, fmt.Errorf("invalid amount '%s' in the order book", level.Amount)
+		}
+		if price.Sign() <= 0 || available <= 0 {
+			continue
+		}
+
+		if best == nil {
+			best = price
+		}
+
+		base := available.Rat()
+		if !asks {
+			base.Quo(base, price)
+		}
+
+		take := base
+		if inCounter {
+			take = new(big.Rat).Mul(base, price)
+		}
+		if take.Cmp(left) >= 0 {
+			take, left = left, new(big.Rat)
+		} else {
+			left.Sub(left, take)
+		}
+
+		if inCounter {
+			total.Add(total, take)
+			amount.Add(amount, new(big.Rat).Quo(take, price))
+		} else {
+			amount.Add(amount, take)
+			total.Add(total, new(big.Rat).Mul(take, price))
+		}
+
+		if left.Sign() <= 0 {
+			break
+		}
+	}
+
+	if left.Sign() > 0 {
+		return Quote{}, ErrThin
+	}
+
+	// the side computed is paid to the asks when buying the amount, and to
+	// the bids when receiving the total
+	rounding := decimal.Down
+	if asks != inCounter {
+		rounding = decimal.Up
+	}
+
+	q := Quote{
+		BestPrice:    best,
+		AveragePrice: new(big.Rat).Quo(total, amount),
+	}
+	var err error
+	if q.Amount, err = decimal.FromRat(amount, rounding); err != nil {
+		return Quote{}, err
+	}
+	if q.Total, err = decimal.FromRat(total, rounding); err != nil {
+		return Quote{}, err
+	}
+	if inCounter {
+		q.Total = want
+	} else {
+		q.Amount = want
+	}
+
+	q.Slippage = new(big.Rat).Sub(q.AveragePrice, best)
+	q.Slippage.Quo(q.Slippage, best).Mul(q.Slippage, big.NewRat(100, 1))
+	if !asks {
+		q.Slippage.Neg(q.Slippage)
+	}
+	return q, nil
+}
+
+// levelPrice returns the price of level, as an exact fraction when horizon gives it
+func levelPrice(level horizon.PriceLevel) (*big.Rat, error) {
+	if level.PriceR.D > 0 {
+		return big.NewRat(int64(level.PriceR.N), int64(level.PriceR.D)), nil
+	}
+
+	price, err := decimal.ParsePrice(level.Price)
+	if err != nil {
+		return nil, fmt.Errorf("invalid price '%s' in the order book", level.Price)
+	}
+	return price, nil
+}
+
+// BuyLimit returns the highest price accepted when buying at best with at most maxSlippage percent
+func BuyLimit(best, maxSlippage *big.Rat) *big.Rat {
+	return limit(best, maxSlippage, 1)
+}
+
+// SellLimit returns the lowest price accepted when selling at best with at most maxSlippage percent
+func SellLimit(best, maxSlippage *big.Rat) *big.Rat {
+	return limit(best, maxSlippage, -1)
+}
+
+// limit returns best moved by maxSlippage percent, up if sign is 1 and down if it is -1
+func limit(best, maxSlippage *big.Rat, sign int64) *big.Rat {
+	factor := new(big.Rat).Quo(maxSlippage, big.NewRat(100*sign, 1))
+	factor.Add(factor, big.NewRat(1, 1))
+	return factor.Mul(factor, best)
+}
@@ -0,0 +1,120 @@
+package pricing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/celrenheit/alfred/decimal"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stretchr/testify/require"
+)
+
+// loadBook reads an order book in the format returned by horizon
+func loadBook(t *testing.T, name string) horizon.OrderBookSummary {
+	b, err := ioutil.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+
+	var book horizon.OrderBookSummary
+	require.NoError(t, json.Unmarshal(b, &book))
+	return book
+}
+
+// amount parses an amount of the tests
+func amount(t *testing.T, s string) decimal.Amount {
+	a, err := decimal.Parse(s)
+	require.NoError(t, err)
+	return a
+}
+
+// rat parses a price or a percentage of the tests
+func rat(s string) *big.Rat {
+	r, _ := new(big.Rat).SetString(s)
+	return r
+}
+
+func TestBuy(t *testing.T) {
+	book := loadBook(t, "mobi_xlm.json")
+
+	q, err := Buy(book.Asks, amount(t, "50"))
+	require.NoError(t, err)
+	require.Equal(t, amount(t, "10.5"), q.Total)
+	require.Equal(t, rat("0.21"), q.AveragePrice)
+	require.Equal(t, 0, q.Slippage.Sign())
+
+	// 100 at 0.21 then 300 at 0.22
+	q, err = Buy(book.Asks, amount(t, "400"))
+	require.NoError(t, err)
+	require.Equal(t, amount(t, "87"), q.Total)
+	require.Equal(t, rat("0.21"), q.BestPrice)
+	require.Equal(t, rat("0.2175"), q.AveragePrice)
+	require.Equal(t, rat("25/7"), q.Slippage)
+
+	_, err = Buy(book.Asks, amount(t, "5501"))
+	require.Equal(t, ErrThin, err)
+
+	_, err = Buy(nil, amount(t, "1"))
+	require.Equal(t, ErrThin, err)
+
+	_, err = Buy(book.Asks, 0)
+	require.Error(t, err)
+}
+
+func TestSpend(t *testing.T) {
+	book := loadBook(t, "mobi_xlm.json")
+
+	// 21 XLM for 100 MOBI, then 22 XLM for 100 MOBI
+	q, err := Spend(book.Asks, amount(t, "43"))
+	require.NoError(t, err)
+	require.Equal(t, amount(t, "200"), q.Amount)
+	require.Equal(t, amount(t, "43"), q.Total)
+	require.Equal(t, rat("0.215"), q.AveragePrice)
+
+	// 1/0.21 MOBI is rounded down, it is received
+	q, err = Spend(book.Asks, amount(t, "1"))
+	require.NoError(t, err)
+	require.Equal(t, amount(t, "4.7619047"), q.Amount)
+}
+
+func TestSell(t *testing.T) {
+	book := loadBook(t, "mobi_xlm.json")
+
+	// the first bid buys 100 MOBI for 20 XLM
+	q, err := Sell(book.Bids, amount(t, "100"))
+	require.NoError(t, err)
+	require.Equal(t, amount(t, "20"), q.Total)
+	require.Equal(t, 0, q.Slippage.Sign())
+
+	// 100 at 0.2 then 500 at 0.19
+	q, err = Sell(book.Bids, amount(t, "600"))
+	require.NoError(t, err)
+	require.Equal(t, amount(t, "115"), q.Total)
+	require.Equal(t, rat("0.2"), q.BestPrice)
+	require.Equal(t, rat("23/120"), q.AveragePrice)
+	require.Equal(t, rat("25/6"), q.Slippage)
+
+	_, err = Sell(book.Bids, amount(t, "10601"))
+	require.Equal(t, ErrThin, err)
+}
+
+func TestReceive(t *testing.T) {
+	book := loadBook(t, "mobi_xlm.json")
+
+	q, err := Receive(book.Bids, amount(t, "115"))
+	require.NoError(t, err)
+	require.Equal(t, amount(t, "600"), q.Amount)
+
+	// 1/0.2 MOBI then 0.1/0.19 MOBI, rounded up as it is sold
+	q, err = Receive(book.Bids, amount(t, "20.1"))
+	require.NoError(t, err)
+	require.Equal(t, amount(t, "100.5263158"), q.Amount)
+
+	_, err = Receive(book.Bids, amount(t, "1916"))
+	require.Equal(t, ErrThin, err)
+}
+
+func TestLimits(t *testing.T) {
+	require.Equal(t, rat("0.2121"), BuyLimit(rat("0.21"), rat("1")))
+	require.Equal(t, rat("0.198"), SellLimit(rat("0.2"), rat("1")))
+}
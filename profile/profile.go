@@ -0,0 +1,125 @@
+// Package profile stores named sets of settings, such as personal, business or
+// testnet, each with its own database, network and default wallet.
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Profile is a set of settings used instead of the flags that are not given
+type Profile struct {
+	// DB is the path of the database
+	DB string `yaml:"db,omitempty"`
+	// Network is the network, as given to --network, public when empty
+	Network string `yaml:"network,omitempty"`
+	// Testnet is only read from the profiles stored before Network
+	Testnet bool `yaml:"testnet,omitempty"`
+	// Wallet is the wallet used when none is given, instead of prompting for it
+	Wallet string `yaml:"wallet,omitempty"`
+}
+
+// Profiles are the profiles of a user, one of them can be the current one
+type Profiles struct {
+	Current  string             `yaml:"current,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+// Load reads the profiles stored at path, a missing file has no profiles
+func Load(path string) (*Profiles, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Profiles{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var p Profiles
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("invalid profiles in %s: %v", path, err)
+	}
+	for name, profile := range p.Profiles {
+		if profile.Testnet && profile.Network == "" {
+			profile.Network, profile.Testnet = "testnet", false
+			p.Profiles[name] = profile
+		}
+	}
+
+	return &p, nil
+}
+
+// Save writes the profiles at path
+func (p *Profiles) Save(path string) error {
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// Get returns the profile with name
+func (p *Profiles) Get(name string) (Profile, error) {
+	profile, ok := p.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile '%s' not found", name)
+	}
+
+	return profile, nil
+}
+
+// Set creates or replaces the profile with name
+func (p *Profiles) Set(name string, profile Profile) error {
+	if name == "" {
+		return errors.New("the name of a profile should not be empty")
+	}
+
+	if p.Profiles == nil {
+		p.Profiles = make(map[string]Profile)
+	}
+	p.Profiles[name] = profile
+
+	return nil
+}
+
+// Use makes the profile with name the current one, an empty name unsets it
+func (p *Profiles) Use(name string) error {
+	if name != "" {
+		if _, err := p.Get(name); err != nil {
+			return err
+		}
+	}
+
+	p.Current = name
+	return nil
+}
+
+// Remove deletes the profile with name, it is no longer current
+func (p *Profiles) Remove(name string) error {
+	if _, err := p.Get(name); err != nil {
+		return err
+	}
+
+	delete(p.Profiles, name)
+	if p.Current == name {
+		p.Current = ""
+	}
+
+	return nil
+}
+
+// Names returns the names of the profiles, sorted
+func (p *Profiles) Names() []string {
+	var names []string
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
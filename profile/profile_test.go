@@ -0,0 +1,55 @@
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "profiles.yaml")
+
+	p, err := Load(path)
+	require.NoError(t, err)
+	require.Empty(t, p.Names())
+
+	require.NoError(t, p.Set("personal", Profile{DB: "/home/alfred/personal.yaml"}))
+	require.NoError(t, p.Set("business", Profile{DB: "/home/alfred/business.yaml", Wallet: "payroll"}))
+	require.Error(t, p.Set("", Profile{}))
+	require.Error(t, p.Use("testnet"))
+	require.NoError(t, p.Use("business"))
+	require.NoError(t, p.Save(path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	p, err = Load(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"business", "personal"}, p.Names())
+	require.Equal(t, "business", p.Current)
+
+	business, err := p.Get("business")
+	require.NoError(t, err)
+	require.Equal(t, "payroll", business.Wallet)
+
+	require.NoError(t, p.Remove("business"))
+	require.Empty(t, p.Current)
+	require.Error(t, p.Remove("business"))
+	_, err = p.Get("business")
+	require.EqualError(t, err, "profile 'business' not found")
+
+	// profiles stored before --network
+	require.NoError(t, ioutil.WriteFile(path, []byte("profiles:\n  test:\n    db: test.yaml\n    testnet: true\n"), 0600))
+	p, err = Load(path)
+	require.NoError(t, err)
+	test, err := p.Get("test")
+	require.NoError(t, err)
+	require.Equal(t, Profile{DB: "test.yaml", Network: "testnet"}, test)
+}
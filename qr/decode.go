@@ -0,0 +1,334 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+const alphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+var (
+	// ErrNotFound is returned when no QR code could be located in the image
+	ErrNotFound = errors.New("qr: no code found in image")
+)
+
+// Decode reads the content of the QR code found in img.
+//
+// The code must be upright and undistorted, surrounded by a light quiet zone,
+// and be the only dark shape in the image.
+func Decode(img image.Image) (string, error) {
+	g := newGrid(img)
+
+	minX, minY, maxX, maxY, ok := g.bounds()
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	// the top edge of the top left finder pattern is 7 modules wide
+	run := 0
+	for x := minX; x <= maxX && g.dark(x, minY); x++ {
+		run++
+	}
+	moduleSize := float64(run) / 7
+	if moduleSize < 1 {
+		return "", ErrNotFound
+	}
+
+	width := float64(maxX - minX + 1)
+	height := float64(maxY - minY + 1)
+	dim := int(math.Floor(width/moduleSize + 0.5))
+	version := int(math.Floor(float64(dim-17)/4 + 0.5))
+	if version < minVersion || version > maxVersion {
+		return "", ErrNotFound
+	}
+	dim = version*4 + 17
+
+	sample := func(x, y int) bool {
+		px := float64(minX) + (float64(x)+0.5)*width/float64(dim)
+		py := float64(minY) + (float64(y)+0.5)*height/float64(dim)
+		return g.dark(int(px), int(py))
+	}
+
+	level, mask, err := readFormat(dim, sample)
+	if err != nil {
+		return "", err
+	}
+
+	c := newCode(version, level)
+	c.drawFunctionPatterns()
+
+	var bb bitBuffer
+	c.walk(func(x, y int) {
+		bb = append(bb, sample(x, y) != masked(mask, x, y))
+	})
+
+	raw := bb.bytes()[:numRawDataModules(version)/8]
+	data, err := deinterleave(raw, version, level)
+	if err != nil {
+		return "", err
+	}
+
+	return parseSegments(data, version)
+}
+
+type grid struct {
+	img       image.Image
+	threshold uint32
+}
+
+func newGrid(img image.Image) *grid {
+	b := img.Bounds()
+	lo, hi := uint32(math.MaxUint32), uint32(0)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			l := luminance(img, x, y)
+			if l < lo {
+				lo = l
+			}
+			if l > hi {
+				hi = l
+			}
+		}
+	}
+
+	return &grid{img: img, threshold: lo + (hi-lo)/2}
+}
+
+func luminance(img image.Image, x, y int) uint32 {
+	r, g, b, a := img.At(x, y).RGBA()
+	if a == 0 { // transparent pixels are considered as background
+		return math.MaxUint16
+	}
+	return (299*r + 587*g + 114*b) / 1000
+}
+
+func (g *grid) dark(x, y int) bool {
+	if !(image.Point{x, y}).In(g.img.Bounds()) {
+		return false
+	}
+	return luminance(g.img, x, y) < g.threshold
+}
+
+func (g *grid) bounds() (minX, minY, maxX, maxY int, ok bool) {
+	b := g.img.Bounds()
+	minX, minY, maxX, maxY = b.Max.X, b.Max.Y, b.Min.X-1, b.Min.Y-1
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !g.dark(x, y) {
+				continue
+			}
+			if x < minX {
+				minX = x
+			}
+			if x > maxX {
+				maxX = x
+			}
+			if y < minY {
+				minY = y
+			}
+			if y > maxY {
+				maxY = y
+			}
+		}
+	}
+
+	return minX, minY, maxX, maxY, maxX >= minX && maxY >= minY
+}
+
+func readFormat(dim int, sample func(x, y int) bool) (Level, int, error) {
+	var first, second int
+	set := func(v *int, i int, dark bool) {
+		if dark {
+			*v |= 1 << uint(i)
+		}
+	}
+
+	for i := 0; i <= 5; i++ {
+		set(&first, i, sample(8, i))
+	}
+	set(&first, 6, sample(8, 7))
+	set(&first, 7, sample(8, 8))
+	set(&first, 8, sample(7, 8))
+	for i := 9; i < 15; i++ {
+		set(&first, i, sample(14-i, 8))
+	}
+
+	for i := 0; i < 8; i++ {
+		set(&second, i, sample(dim-1-i, 8))
+	}
+	for i := 8; i < 15; i++ {
+		set(&second, i, sample(8, dim-15+i))
+	}
+
+	bestDist := 16
+	var (
+		bestLevel Level
+		bestMask  int
+	)
+	for _, level := range []Level{L, M, Q, H} {
+		for mask := 0; mask < 8; mask++ {
+			want := formatInfo(level, mask)
+			for _, got := range []int{first, second} {
+				if d := popcount(want ^ got); d < bestDist {
+					bestDist, bestLevel, bestMask = d, level, mask
+				}
+			}
+		}
+	}
+
+	if bestDist > 3 {
+		return 0, 0, errors.New("qr: unable to read format information")
+	}
+
+	return bestLevel, bestMask, nil
+}
+
+// deinterleave reverses addECCAndInterleave, correcting errors in each block.
+func deinterleave(raw []byte, version int, level Level) ([]byte, error) {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	blockECCLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := len(raw)
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+	shortDataLen := shortBlockLen - blockECCLen
+
+	blocks := make([][]byte, numBlocks)
+	for i := range blocks {
+		blocks[i] = make([]byte, shortBlockLen+1)
+	}
+
+	k := 0
+	for i := 0; i <= shortBlockLen; i++ {
+		for j := range blocks {
+			if i != shortDataLen || j >= numShortBlocks {
+				blocks[j][i] = raw[k]
+				k++
+			}
+		}
+	}
+
+	var data []byte
+	for j, blk := range blocks {
+		if j < numShortBlocks {
+			blk = append(blk[:shortDataLen], blk[shortDataLen+1:]...)
+		}
+		if err := rsCorrect(blk, blockECCLen); err != nil {
+			return nil, err
+		}
+		data = append(data, blk[:len(blk)-blockECCLen]...)
+	}
+
+	return data, nil
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) available() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.pos>>3 < len(r.data) && r.data[r.pos>>3]&(1<<uint(7-r.pos&7)) != 0 {
+			v |= 1
+		}
+		r.pos++
+	}
+	return v
+}
+
+func parseSegments(data []byte, version int) (string, error) {
+	r := &bitReader{data: data}
+	var out []byte
+
+	for r.available() >= 4 {
+		mode := r.read(4)
+		if mode == modeTerminator {
+			break
+		}
+
+		if mode == modeECI {
+			// only single byte designators are supported, content is read as is
+			if r.available() < 8 {
+				break
+			}
+			r.read(8)
+			continue
+		}
+
+		n := charCountBits(mode, version)
+		if n == 0 || mode == modeKanji {
+			return "", fmt.Errorf("qr: unsupported segment mode %d", mode)
+		}
+		if r.available() < n {
+			break
+		}
+		count := r.read(n)
+
+		switch mode {
+		case modeNumeric:
+			for ; count >= 3; count -= 3 {
+				out = append(out, []byte(fmt.Sprintf("%03d", r.read(10)))...)
+			}
+			switch count {
+			case 2:
+				out = append(out, []byte(fmt.Sprintf("%02d", r.read(7)))...)
+			case 1:
+				out = append(out, []byte(fmt.Sprintf("%d", r.read(4)))...)
+			}
+		case modeAlphanumeric:
+			for ; count >= 2; count -= 2 {
+				v := r.read(11)
+				if v/45 >= len(alphanumericCharset) {
+					return "", errors.New("qr: invalid alphanumeric segment")
+				}
+				out = append(out, alphanumericCharset[v/45], alphanumericCharset[v%45])
+			}
+			if count == 1 {
+				v := r.read(6)
+				if v >= len(alphanumericCharset) {
+					return "", errors.New("qr: invalid alphanumeric segment")
+				}
+				out = append(out, alphanumericCharset[v])
+			}
+		case modeByte:
+			if r.available() < count*8 {
+				return "", errors.New("qr: truncated byte segment")
+			}
+			for i := 0; i < count; i++ {
+				out = append(out, byte(r.read(8)))
+			}
+		}
+	}
+
+	return string(out), nil
+}
+
+func popcount(x int) int {
+	n := 0
+	for ; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
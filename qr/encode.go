@@ -0,0 +1,254 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qr
+
+// segment modes
+const (
+	modeTerminator   = 0x0
+	modeNumeric      = 0x1
+	modeAlphanumeric = 0x2
+	modeByte         = 0x4
+	modeECI          = 0x7
+	modeKanji        = 0x8
+)
+
+// Encode encodes content in byte mode using the smallest version that fits.
+func Encode(content string, level Level) (*Code, error) {
+	data := []byte(content)
+
+	version := minVersion
+	for ; version <= maxVersion; version++ {
+		if 4+charCountBits(modeByte, version)+len(data)*8 <= numDataCodewords(version, level)*8 {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrTooLong
+	}
+
+	var bb bitBuffer
+	bb.append(modeByte, 4)
+	bb.append(len(data), charCountBits(modeByte, version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	capacity := numDataCodewords(version, level) * 8
+	bb.append(0, min(4, capacity-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	codewords := addECCAndInterleave(bb.bytes(), version, level)
+
+	c := newCode(version, level)
+	c.drawFunctionPatterns()
+
+	i := 0
+	c.walk(func(x, y int) {
+		if i < len(codewords)*8 {
+			c.modules[y][x] = bit(int(codewords[i>>3]), 7-i&7)
+			i++
+		}
+	})
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(formatInfo(level, mask))
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // undo
+	}
+
+	c.applyMask(best)
+	c.drawFormat(formatInfo(level, best))
+
+	return c, nil
+}
+
+func charCountBits(mode, version int) int {
+	idx := 2
+	switch {
+	case version <= 9:
+		idx = 0
+	case version <= 26:
+		idx = 1
+	}
+
+	switch mode {
+	case modeNumeric:
+		return [...]int{10, 12, 14}[idx]
+	case modeAlphanumeric:
+		return [...]int{9, 11, 13}[idx]
+	case modeByte:
+		return [...]int{8, 16, 16}[idx]
+	case modeKanji:
+		return [...]int{8, 10, 12}[idx]
+	}
+
+	return 0
+}
+
+// addECCAndInterleave splits data into blocks, appends the error correction
+// codewords of each block and interleaves the result.
+func addECCAndInterleave(data []byte, version int, level Level) []byte {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	blockECCLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := rsDivisor(blockECCLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i < numBlocks; i++ {
+		n := shortBlockLen - blockECCLen
+		if i >= numShortBlocks {
+			n++
+		}
+		dat := append([]byte{}, data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(dat, divisor)
+		if i < numShortBlocks {
+			dat = append(dat, 0)
+		}
+		blocks[i] = append(dat, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, blk := range blocks {
+			if i != shortBlockLen-blockECCLen || j >= numShortBlocks {
+				result = append(result, blk[i])
+			}
+		}
+	}
+
+	return result
+}
+
+// penalty computes the score used to choose between masks, lower is better.
+func (c *Code) penalty() int {
+	const (
+		n1 = 3
+		n2 = 3
+		n3 = 40
+		n4 = 10
+	)
+
+	result := 0
+	at := func(horizontal bool, i, j int) bool {
+		if horizontal {
+			return c.modules[i][j]
+		}
+		return c.modules[j][i]
+	}
+
+	// rule 1 and 3, on rows and columns
+	for _, horizontal := range []bool{true, false} {
+		for i := 0; i < c.Size; i++ {
+			run := 1
+			for j := 1; j < c.Size; j++ {
+				if at(horizontal, i, j) == at(horizontal, i, j-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += n1 + run - 5
+				}
+				run = 1
+			}
+			if run >= 5 {
+				result += n1 + run - 5
+			}
+
+			for j := 0; j+11 <= c.Size; j++ {
+				if matchFinderLike(func(k int) bool { return at(horizontal, i, j+k) }) {
+					result += n3
+				}
+			}
+		}
+	}
+
+	// rule 2, 2x2 blocks
+	for y := 0; y < c.Size-1; y++ {
+		for x := 0; x < c.Size-1; x++ {
+			v := c.modules[y][x]
+			if v == c.modules[y][x+1] && v == c.modules[y+1][x] && v == c.modules[y+1][x+1] {
+				result += n2
+			}
+		}
+	}
+
+	// rule 4, balance of dark modules
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+		}
+	}
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	if k > 0 {
+		result += k * n4
+	}
+
+	return result
+}
+
+var (
+	finderLike1 = [11]bool{true, false, true, true, true, false, true, false, false, false, false}
+	finderLike2 = [11]bool{false, false, false, false, true, false, true, true, true, false, true}
+)
+
+func matchFinderLike(at func(k int) bool) bool {
+	match1, match2 := true, true
+	for k := 0; k < 11; k++ {
+		v := at(k)
+		match1 = match1 && v == finderLike1[k]
+		match2 = match2 && v == finderLike2[k]
+	}
+	return match1 || match2
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(val, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, bit(val, i))
+	}
+}
+
+func (bb bitBuffer) bytes() []byte {
+	result := make([]byte, (len(bb)+7)/8)
+	for i, b := range bb {
+		if b {
+			result[i>>3] |= 1 << uint(7-i&7)
+		}
+	}
+	return result
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
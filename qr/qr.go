@@ -0,0 +1,362 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package qr encodes and decodes QR codes (model 2).
+//
+// Only what alfred needs is implemented: encoding uses the byte mode and
+// decoding expects a clean, axis-aligned image such as a screenshot or a
+// code generated by a wallet.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Level is the error correction level of a QR code
+type Level int
+
+const (
+	L Level = iota // recovers 7% of data
+	M              // recovers 15% of data
+	Q              // recovers 25% of data
+	H              // recovers 30% of data
+)
+
+// formatBits returns the two bits used to store the level in the format information
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+const (
+	minVersion = 1
+	maxVersion = 40
+)
+
+var (
+	eccCodewordsPerBlock = [4][41]int{
+		{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+		{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	}
+
+	numErrorCorrectionBlocks = [4][41]int{
+		{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+		{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+		{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+		{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+	}
+)
+
+// ErrTooLong is returned when the content does not fit in the largest QR code
+var ErrTooLong = errors.New("qr: content too long")
+
+// Code is an encoded QR code
+type Code struct {
+	Version int
+	Level   Level
+	Size    int
+
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newCode(version int, level Level) *Code {
+	size := version*4 + 17
+	c := &Code{
+		Version:    version,
+		Level:      level,
+		Size:       size,
+		modules:    make([][]bool, size),
+		isFunction: make([][]bool, size),
+	}
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.isFunction[i] = make([]bool, size)
+	}
+
+	return c
+}
+
+// Black reports whether the module at column x and row y is dark.
+// Coordinates outside of the code are light (quiet zone).
+func (c *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// ANSI renders the code using terminal background colors, two columns per module.
+func (c *Code) ANSI(w io.Writer) error {
+	const (
+		quiet = 2
+		black = "\x1b[40m  \x1b[0m"
+		white = "\x1b[47m  \x1b[0m"
+	)
+
+	var b bytes.Buffer
+	for y := -quiet; y < c.Size+quiet; y++ {
+		for x := -quiet; x < c.Size+quiet; x++ {
+			if c.Black(x, y) {
+				b.WriteString(black)
+			} else {
+				b.WriteString(white)
+			}
+		}
+		b.WriteByte('\n')
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// String renders the code with unicode half blocks, useful where colors are not supported.
+func (c *Code) String() string {
+	const quiet = 2
+
+	var b bytes.Buffer
+	for y := -quiet; y < c.Size+quiet; y += 2 {
+		for x := -quiet; x < c.Size+quiet; x++ {
+			top, bottom := !c.Black(x, y), !c.Black(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.isFunction[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	// timing patterns
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// finder patterns
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	// alignment patterns
+	pos := alignmentPositions(c.Version)
+	n := len(pos)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue
+			}
+			c.drawAlignment(pos[i], pos[j])
+		}
+	}
+
+	// reserve format areas, real values are drawn once the mask is known
+	c.drawFormat(0)
+	c.drawVersion()
+}
+
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func formatInfo(level Level, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormat(bits int) {
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(bits, i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+
+	rem := c.Version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := c.Version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		dark := bit(bits, i)
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// walk calls fn for every data module in placement order
+func (c *Code) walk(fn func(x, y int)) {
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				upward := (right+1)&2 == 0
+				y := vert
+				if upward {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunction[y][x] {
+					fn(x, y)
+				}
+			}
+		}
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.isFunction[y][x] && masked(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	case 7:
+		return ((x+y)%2+x*y%3)%2 == 0
+	default:
+		panic(fmt.Sprintf("qr: invalid mask %d", mask))
+	}
+}
+
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+
+	return result
+}
+
+// numRawDataModules returns the number of modules available for data and ecc
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+
+	return result
+}
+
+func numDataCodewords(version int, level Level) int {
+	return numRawDataModules(version)/8 -
+		eccCodewordsPerBlock[level][version]*numErrorCorrectionBlocks[level][version]
+}
+
+func bit(x, i int) bool {
+	return (x>>uint(i))&1 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,93 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qr
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func render(c *Code, scale, quiet int) *image.Gray {
+	size := (c.Size + 2*quiet) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v := color.Gray{Y: 0xff}
+			if c.Black(x/scale-quiet, y/scale-quiet) {
+				v = color.Gray{Y: 0}
+			}
+			img.SetGray(x, y, v)
+		}
+	}
+	return img
+}
+
+func TestRoundTrip(t *testing.T) {
+	var tests = []struct {
+		content string
+		level   Level
+	}{
+		{"GCDMBL2SDMM74I2EOM5XHF7LMMDXFEJQIZ5N2ORK6HBSHM5INLALFRED", M},
+		{"web+stellar:pay?destination=GCDMBL2SDMM74I2EOM5XHF7LMMDXFEJQIZ5N2ORK6HBSHM5INLALFRED&amount=10", L},
+		{"hello", H},
+		{strings.Repeat("alfred ", 60), Q},
+	}
+
+	for _, test := range tests {
+		t.Run(test.content, func(t *testing.T) {
+			c, err := Encode(test.content, test.level)
+			require.NoError(t, err)
+			require.Equal(t, c.Version*4+17, c.Size)
+
+			got, err := Decode(render(c, 3, 4))
+			require.NoError(t, err)
+			require.Equal(t, test.content, got)
+		})
+	}
+}
+
+func TestDecodeWithErrors(t *testing.T) {
+	content := "GCDMBL2SDMM74I2EOM5XHF7LMMDXFEJQIZ5N2ORK6HBSHM5INLALFRED"
+	c, err := Encode(content, H)
+	require.NoError(t, err)
+
+	// damage a few data modules
+	n := 0
+	c.walk(func(x, y int) {
+		if n < 6 && (x+y)%11 == 0 {
+			c.modules[y][x] = !c.modules[y][x]
+			n++
+		}
+	})
+
+	got, err := Decode(render(c, 2, 4))
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+}
+
+func TestTooLong(t *testing.T) {
+	_, err := Encode(strings.Repeat("a", 3000), H)
+	require.Equal(t, ErrTooLong, err)
+}
+
+func TestDecodeEmpty(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 10, 10))
+	_, err := Decode(img)
+	require.Error(t, err)
+}
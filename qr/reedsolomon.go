@@ -0,0 +1,245 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qr
+
+import "errors"
+
+// Reed-Solomon over GF(2^8) with the 0x11D polynomial, as used by QR codes.
+// Polynomials are stored with the highest degree coefficient first.
+
+var (
+	gfExp [512]byte
+	gfLog [256]int
+)
+
+var errTooManyErrors = errors.New("qr: too many errors to correct")
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(x, y byte) byte {
+	if x == 0 || y == 0 {
+		return 0
+	}
+	return gfExp[gfLog[x]+gfLog[y]]
+}
+
+func gfDiv(x, y byte) byte {
+	if y == 0 {
+		panic("qr: division by zero")
+	}
+	if x == 0 {
+		return 0
+	}
+	return gfExp[(gfLog[x]+255-gfLog[y])%255]
+}
+
+func gfPow(x byte, power int) byte {
+	e := (gfLog[x] * power) % 255
+	if e < 0 {
+		e += 255
+	}
+	return gfExp[e]
+}
+
+func gfInverse(x byte) byte {
+	return gfExp[255-gfLog[x]]
+}
+
+// rsDivisor returns the generator polynomial of the given degree without its
+// leading coefficient.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMul(coef, factor)
+		}
+	}
+
+	return result
+}
+
+func polyEval(poly []byte, x byte) byte {
+	y := poly[0]
+	for _, c := range poly[1:] {
+		y = gfMul(y, x) ^ c
+	}
+	return y
+}
+
+func polyScale(p []byte, x byte) []byte {
+	r := make([]byte, len(p))
+	for i, c := range p {
+		r[i] = gfMul(c, x)
+	}
+	return r
+}
+
+func polyAdd(p, q []byte) []byte {
+	r := make([]byte, max(len(p), len(q)))
+	for i, c := range p {
+		r[i+len(r)-len(p)] = c
+	}
+	for i, c := range q {
+		r[i+len(r)-len(q)] ^= c
+	}
+	return r
+}
+
+func polyMul(p, q []byte) []byte {
+	r := make([]byte, len(p)+len(q)-1)
+	for j, pc := range p {
+		for i, qc := range q {
+			r[i+j] ^= gfMul(pc, qc)
+		}
+	}
+	return r
+}
+
+func reverse(p []byte) []byte {
+	r := make([]byte, len(p))
+	for i, c := range p {
+		r[len(p)-1-i] = c
+	}
+	return r
+}
+
+// rsCorrect corrects msg (data followed by nsym ecc codewords) in place.
+func rsCorrect(msg []byte, nsym int) error {
+	synd := make([]byte, nsym+1) // synd[0] is padding
+	clean := true
+	for i := 0; i < nsym; i++ {
+		synd[i+1] = polyEval(msg, gfPow(2, i))
+		if synd[i+1] != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return nil
+	}
+
+	// Berlekamp-Massey
+	errLoc, oldLoc := []byte{1}, []byte{1}
+	for i := 0; i < nsym; i++ {
+		k := i + 1
+		delta := synd[k]
+		for j := 1; j < len(errLoc); j++ {
+			delta ^= gfMul(errLoc[len(errLoc)-1-j], synd[k-j])
+		}
+		oldLoc = append(oldLoc, 0)
+		if delta != 0 {
+			if len(oldLoc) > len(errLoc) {
+				newLoc := polyScale(oldLoc, delta)
+				oldLoc = polyScale(errLoc, gfInverse(delta))
+				errLoc = newLoc
+			}
+			errLoc = polyAdd(errLoc, polyScale(oldLoc, delta))
+		}
+	}
+	for len(errLoc) > 0 && errLoc[0] == 0 {
+		errLoc = errLoc[1:]
+	}
+	numErrs := len(errLoc) - 1
+	if numErrs*2 > nsym {
+		return errTooManyErrors
+	}
+
+	// Chien search
+	revLoc := reverse(errLoc)
+	var errPos []int
+	for i := 0; i < len(msg); i++ {
+		if polyEval(revLoc, gfPow(2, i)) == 0 {
+			errPos = append(errPos, len(msg)-1-i)
+		}
+	}
+	if len(errPos) != numErrs {
+		return errTooManyErrors
+	}
+
+	// Forney
+	coefPos := make([]int, len(errPos))
+	for i, p := range errPos {
+		coefPos[i] = len(msg) - 1 - p
+	}
+	loc := []byte{1}
+	for _, p := range coefPos {
+		loc = polyMul(loc, []byte{gfPow(2, p), 1})
+	}
+
+	// error evaluator: (S(x) * Λ(x)) mod x^len(Λ)
+	product := polyMul(reverse(synd), loc)
+	eval := product[len(product)-len(loc):]
+
+	x := make([]byte, len(coefPos))
+	for i, p := range coefPos {
+		x[i] = gfPow(2, -(255 - p))
+	}
+
+	for i, xi := range x {
+		xiInv := gfInverse(xi)
+		locPrime := byte(1)
+		for j, xj := range x {
+			if j != i {
+				locPrime = gfMul(locPrime, 1^gfMul(xiInv, xj))
+			}
+		}
+		if locPrime == 0 {
+			return errTooManyErrors
+		}
+		y := gfMul(xi, polyEval(eval, xiInv))
+		msg[errPos[i]] ^= gfDiv(y, locPrime)
+	}
+
+	for i := 0; i < nsym; i++ {
+		if polyEval(msg, gfPow(2, i)) != 0 {
+			return errTooManyErrors
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+// Package ratelimit keeps the requests to horizon under its rate limit.
+// Horizon tells how many requests are left until the limit resets in the
+// X-RateLimit-* headers of its responses, and answers 429 Too Many Requests
+// once none is left.
+//
+// The requests are paced as the remaining requests run low, held until the
+// limit resets once there are none left, and retried after a 429. At most
+// Concurrency requests are in flight at once, so that commands loading many
+// accounts do not burst.
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultConcurrency is the number of requests in flight at once
+	DefaultConcurrency = 8
+	// DefaultMaxWait is the longest a request waits for the limit to reset
+	DefaultMaxWait = time.Minute
+	// DefaultRetries is the number of times a request answered with 429 is
+	// sent again
+	DefaultRetries = 3
+)
+
+// Doer sends http requests, such as a *http.Client or a *failover.Client
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client is an http client keeping the requests sent through Next under the
+// rate limit of horizon. It implements the HTTP interface of horizon clients.
+type Client struct {
+	// Next sends the requests
+	Next Doer
+	// Concurrency is the number of requests in flight at once,
+	// DefaultConcurrency if zero
+	Concurrency int
+	// MaxWait is the longest a request waits for the limit to reset,
+	// DefaultMaxWait if zero. The 429 response is returned when the limit
+	// resets later.
+	MaxWait time.Duration
+	// Retries is the number of times a request answered with 429 is sent
+	// again, DefaultRetries if zero
+	Retries int
+	// Notify is called before a request waits for the limit, if set
+	Notify func(wait time.Duration)
+
+	once  sync.Once
+	slots chan struct{}
+
+	mu sync.Mutex
+	// limit and remaining are the requests allowed until reset, according to
+	// the last response with the headers
+	limit, remaining int
+	reset            time.Time
+}
+
+// New returns a client sending its requests through next
+func New(next Doer) *Client {
+	return &Client{Next: next}
+}
+
+// Do sends req once the rate limit allows it, and again after a 429 while
+// the limit resets within MaxWait. Streams are sent right away, they are
+// not counted as in flight since they stay open.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept") == "text/event-stream" {
+		return c.Next.Do(req)
+	}
+
+	ctx := req.Context()
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+
+	for attempt := 0; ; attempt++ {
+		if err := c.sleep(ctx, c.pace()); err != nil {
+			return nil, err
+		}
+
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		resp, err := c.Next.Do(r)
+		if err != nil {
+			return nil, err
+		}
+		c.update(resp.Header)
+
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= c.retries() || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		wait := c.retryAfter(resp.Header, attempt)
+		if wait > c.maxWait() {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		if err := c.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) Get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *Client) PostForm(rawURL string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, rawURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(req)
+}
+
+// Remaining returns the requests left until the limit resets and when it
+// resets, false if horizon did not tell yet
+func (c *Client) Remaining() (int, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remaining, c.reset, c.limit > 0
+}
+
+func (c *Client) acquire(ctx context.Context) error {
+	c.once.Do(func() {
+		n := c.Concurrency
+		if n <= 0 {
+			n = DefaultConcurrency
+		}
+		c.slots = make(chan struct{}, n)
+	})
+
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) release() {
+	<-c.slots
+}
+
+// pace returns how long to wait before the next request: until the limit
+// resets when no request is left, or the time until then spread over the
+// requests left once less than a tenth of the limit is.
+func (c *Client) pace() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limit == 0 {
+		return 0
+	}
+	until := time.Until(c.reset)
+	if until <= 0 {
+		c.limit = 0
+		return 0
+	}
+
+	if c.remaining <= 0 {
+		if until > c.maxWait() {
+			// not worth holding the request, horizon answers 429
+			return 0
+		}
+		return until
+	}
+	if c.remaining < c.limit/10 {
+		wait := until / time.Duration(c.remaining+1)
+		c.remaining--
+		return wait
+	}
+
+	c.remaining--
+	return 0
+}
+
+// update records the rate limit of the headers of a response
+func (c *Client) update(h http.Header) {
+	limit, err := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	if err != nil || limit <= 0 {
+		return
+	}
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.Atoi(h.Get("X-RateLimit-Reset"))
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limit, c.remaining = limit, remaining
+	c.reset = time.Now().Add(time.Duration(reset) * time.Second)
+}
+
+// retryAfter returns how long to wait before sending again a request
+// answered with 429: the Retry-After header, the reset of the limit, or an
+// exponential backoff from one second.
+func (c *Client) retryAfter(h http.Header, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(h.Get("Retry-After")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if seconds, err := strconv.Atoi(h.Get("X-RateLimit-Reset")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Second << uint(attempt)
+}
+
+// sleep waits for d unless ctx is done first, telling Notify when it is
+// noticeable
+func (c *Client) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	if d >= time.Second && c.Notify != nil {
+		c.Notify(d)
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) maxWait() time.Duration {
+	if c.MaxWait > 0 {
+		return c.MaxWait
+	}
+	return DefaultMaxWait
+}
+
+func (c *Client) retries() int {
+	if c.Retries > 0 {
+		return c.Retries
+	}
+	return DefaultRetries
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryAfterTooManyRequests(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	c := New(http.DefaultClient)
+	resp, err := c.PostForm(srv.URL, url.Values{"tx": {"AAAA"}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "tx=AAAA", string(body))
+	require.EqualValues(t, 3, hits)
+}
+
+func TestGiveUpWhenTheLimitResetsLate(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("X-RateLimit-Limit", "3600")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1800")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := New(http.DefaultClient)
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.EqualValues(t, 1, hits)
+
+	remaining, reset, ok := c.Remaining()
+	require.True(t, ok)
+	require.Equal(t, 0, remaining)
+	require.WithinDuration(t, time.Now().Add(30*time.Minute), reset, time.Minute)
+}
+
+func TestWaitForTheReset(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "2")
+	}))
+	defer srv.Close()
+
+	var waits []time.Duration
+	c := New(http.DefaultClient)
+	c.Notify = func(wait time.Duration) { waits = append(waits, wait) }
+
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Empty(t, waits)
+
+	start := time.Now()
+	resp, err = c.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.True(t, time.Since(start) > time.Second)
+	require.Len(t, waits, 1)
+}
+
+func TestConcurrency(t *testing.T) {
+	var inFlight, max int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	c := New(http.DefaultClient)
+	c.Concurrency = 2
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Get(srv.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	require.EqualValues(t, 2, max)
+}
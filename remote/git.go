@@ -0,0 +1,136 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// git is a file of a git repository, cloned for every read and write. Its
+// version is the commit of the read, a write is a commit pushed on it.
+type git struct {
+	remote string
+	file   string
+}
+
+func newGit(u *url.URL) (*git, error) {
+	if u.Fragment == "" {
+		return nil, fmt.Errorf("remote: '%s' should end with #path/of/the/file", u)
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("remote: git is needed for '%s': %v", u, err)
+	}
+
+	stripped := *u
+	stripped.Scheme = strings.TrimPrefix(u.Scheme, "git+")
+	stripped.Fragment = ""
+	remote := stripped.String()
+	if stripped.Scheme == "file" {
+		// git expects a path for local repositories
+		remote = stripped.Path
+	}
+
+	return &git{remote: remote, file: filepath.FromSlash(u.Fragment)}, nil
+}
+
+func (g *git) Get() ([]byte, string, error) {
+	dir, head, err := g.clone()
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(dir)
+
+	if head == "" {
+		return nil, "", ErrNotExist
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, g.file))
+	if os.IsNotExist(err) {
+		return nil, "", ErrNotExist
+	}
+	return data, head, err
+}
+
+func (g *git) Put(data []byte, version string) error {
+	dir, head, err := g.clone()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, g.file)
+	if version == "" {
+		if _, err := os.Stat(path); err == nil {
+			return ErrChanged
+		}
+	} else if head != version {
+		return ErrChanged
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	if _, err := run(dir, "add", g.file); err != nil {
+		return err
+	}
+	if _, err := run(dir, "commit", "--quiet", "--allow-empty", "-m", "alfred: sync "+filepath.ToSlash(g.file)); err != nil {
+		return err
+	}
+
+	out, err := run(dir, "push", "--quiet", "origin", "HEAD")
+	if err != nil && strings.Contains(out, "rejected") {
+		return ErrChanged
+	}
+	return err
+}
+
+// clone clones the repository in a temporary directory, returning the commit
+// of its HEAD, empty when nothing was ever pushed
+func (g *git) clone() (string, string, error) {
+	dir, err := ioutil.TempDir("", "alfred-sync")
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := run("", "clone", "--quiet", "--depth", "1", g.remote, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+
+	head, err := run(dir, "rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil {
+		// the repository is empty
+		return dir, "", nil
+	}
+	return dir, strings.TrimSpace(head), nil
+}
+
+func run(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		if os.Getenv(name) == "" {
+			cmd.Env = append(cmd.Env, name+"=alfred")
+		}
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		if os.Getenv(name) == "" {
+			cmd.Env = append(cmd.Env, name+"=alfred@localhost")
+		}
+	}
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("remote: git %s: %v: %s", args[0], err, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,200 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// credentials sign the requests to S3 with AWS Signature Version 4
+type credentials struct {
+	accessKey, secretKey, token string
+	region                      string
+	// endpoint is the URL of the S3 service, such as the one of a MinIO server
+	endpoint string
+}
+
+// envCredentials returns the credentials of the environment variables used
+// by the AWS tools
+func envCredentials() credentials {
+	c := credentials{
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+		region:    os.Getenv("AWS_REGION"),
+		endpoint:  os.Getenv("AWS_ENDPOINT_URL"),
+	}
+	if c.region == "" {
+		c.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	if c.endpoint == "" {
+		c.endpoint = "https://s3." + c.region + ".amazonaws.com"
+	}
+
+	return c
+}
+
+// s3 is an object of a bucket, addressed with the path style supported by
+// every S3 compatible service
+type s3 struct {
+	url   string
+	creds credentials
+	// now is the time of the signatures, replaced in tests
+	now func() time.Time
+}
+
+func newS3(u *url.URL, creds credentials) (*s3, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	switch {
+	case bucket == "" || key == "":
+		return nil, fmt.Errorf("remote: '%s' should be s3://bucket/key", u)
+	case creds.accessKey == "" || creds.secretKey == "":
+		return nil, errors.New("remote: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY should be set")
+	}
+
+	return &s3{
+		url:   strings.TrimRight(creds.endpoint, "/") + "/" + awsEscape(bucket) + "/" + awsEscape(key),
+		creds: creds,
+		now:   time.Now,
+	}, nil
+}
+
+func (s *s3) Get() ([]byte, string, error) {
+	resp, err := s.do("GET", nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, "", ErrNotExist
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", fmt.Errorf("remote: GET %s: %s", s.url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+func (s *s3) Put(data []byte, version string) error {
+	header := make(http.Header)
+	if version == "" {
+		header.Set("If-None-Match", "*")
+	} else {
+		header.Set("If-Match", version)
+	}
+
+	resp, err := s.do("PUT", header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	// 409 is returned when another conditional write is in progress
+	case resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusConflict:
+		return ErrChanged
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("remote: PUT %s: %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+func (s *s3) do(method string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.creds.sign(req, body, s.now())
+
+	return client.Do(req)
+}
+
+// sign adds the Authorization header of req, whose body is payload, signing
+// its host and all its headers
+func (c credentials) sign(req *http.Request, payload []byte, now time.Time) {
+	hash := sha256Hex(payload)
+	date := now.UTC().Format("20060102T150405Z")
+	day := date[:8]
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", hash)
+	if c.token != "" {
+		req.Header.Set("X-Amz-Security-Token", c.token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders.String(), signed, hash}, "\n")
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{day, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%x",
+		c.accessKey, scope, signed, hmacSHA256(key, toSign)))
+}
+
+// awsEscape encodes s as the URIs signed by AWS, every byte but the
+// unreserved characters and the slashes being escaped
+func awsEscape(s string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,92 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// unversioned is the version of a file served without an ETag, which is
+// then written without condition
+const unversioned = "*"
+
+// webDAV is a file on a WebDAV server, read with GET and written with PUT
+type webDAV struct {
+	url  string
+	user *url.Userinfo
+}
+
+func newWebDAV(u *url.URL) *webDAV {
+	stripped := *u
+	stripped.User = nil
+	return &webDAV{url: stripped.String(), user: u.User}
+}
+
+func (w *webDAV) Get() ([]byte, string, error) {
+	resp, err := w.do("GET", nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, "", ErrNotExist
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", fmt.Errorf("remote: GET %s: %s", w.url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	version := resp.Header.Get("ETag")
+	if version == "" {
+		version = unversioned
+	}
+	return data, version, nil
+}
+
+func (w *webDAV) Put(data []byte, version string) error {
+	header := make(http.Header)
+	switch version {
+	case "":
+		header.Set("If-None-Match", "*")
+	case unversioned:
+	default:
+		header.Set("If-Match", version)
+	}
+
+	resp, err := w.do("PUT", header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return ErrChanged
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("remote: PUT %s: %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+func (w *webDAV) do(method string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if w.user != nil {
+		password, _ := w.user.Password()
+		req.SetBasicAuth(w.user.Username(), password)
+	}
+
+	return client.Do(req)
+}
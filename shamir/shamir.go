@@ -0,0 +1,233 @@
+// Package shamir splits a secret into shares with Shamir's secret sharing over
+// GF(2^8): any threshold of the shares recover the secret, fewer reveal nothing.
+package shamir
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// checksumSize is the number of bytes of the SHA-256 of the secret split along
+// with it, so that recovering from wrong shares fails
+const checksumSize = 4
+
+// ErrChecksum is returned when shares recover something else than the secret
+var ErrChecksum = errors.New("the shares do not recover the secret, some of them are wrong or from another secret")
+
+// Split divides secret into parts shares, any threshold of which are needed to
+// recover it with Combine. The last byte of a share is its x coordinate.
+func Split(secret []byte, parts, threshold int) ([][]byte, error) {
+	switch {
+	case len(secret) == 0:
+		return nil, errors.New("the secret should not be empty")
+	case parts < 2 || parts > 255:
+		return nil, errors.New("the number of shares should be between 2 and 255")
+	case threshold < 2 || threshold > parts:
+		return nil, errors.New("the threshold should be between 2 and the number of shares")
+	}
+
+	shares := make([][]byte, parts)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+
+	// a random polynomial of degree threshold-1 per byte, the secret is at x = 0
+	coefficients := make([]byte, threshold)
+	for i, b := range secret {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+
+		for _, share := range shares {
+			share[i] = evaluate(coefficients, share[len(secret)])
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine recovers the secret from shares returned by Split. With fewer shares
+// than the threshold, the result is not the secret.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least two shares are needed")
+	}
+
+	size := len(shares[0])
+	if size < 2 {
+		return nil, errors.New("invalid share")
+	}
+
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]bool)
+	for i, share := range shares {
+		if len(share) != size {
+			return nil, errors.New("the shares should all have the same length")
+		}
+
+		xs[i] = share[size-1]
+		if xs[i] == 0 || seen[xs[i]] {
+			return nil, errors.New("the shares should be distinct")
+		}
+		seen[xs[i]] = true
+	}
+
+	secret := make([]byte, size-1)
+	ys := make([]byte, len(shares))
+	for i := range secret {
+		for j, share := range shares {
+			ys[j] = share[i]
+		}
+		secret[i] = interpolate(xs, ys)
+	}
+
+	return secret, nil
+}
+
+// SplitText is Split with a checksum of the secret, writing the shares as
+// threshold-index-hex, for example 3-1-9f2c05...
+func SplitText(secret []byte, parts, threshold int) ([]string, error) {
+	shares, err := Split(append(append([]byte(nil), secret...), checksum(secret)...), parts, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, len(shares))
+	for i, share := range shares {
+		last := len(share) - 1
+		texts[i] = fmt.Sprintf("%d-%d-%s", threshold, share[last], hex.EncodeToString(share[:last]))
+	}
+
+	return texts, nil
+}
+
+// CombineText recovers the secret from shares written by SplitText and checks it
+func CombineText(texts []string) ([]byte, error) {
+	threshold := 0
+	shares := make([][]byte, len(texts))
+	for i, text := range texts {
+		t, share, err := parseText(text)
+		if err != nil {
+			return nil, err
+		}
+		if threshold != 0 && t != threshold {
+			return nil, errors.New("the shares have different thresholds, they are from different secrets")
+		}
+		threshold, shares[i] = t, share
+	}
+
+	if len(shares) < threshold {
+		return nil, fmt.Errorf("%d shares are needed, %d given", threshold, len(shares))
+	}
+
+	secret, err := Combine(shares)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secret) <= checksumSize {
+		return nil, ErrChecksum
+	}
+	secret, sum := secret[:len(secret)-checksumSize], secret[len(secret)-checksumSize:]
+	if subtle.ConstantTimeCompare(sum, checksum(secret)) != 1 {
+		return nil, ErrChecksum
+	}
+
+	return secret, nil
+}
+
+// Threshold returns the number of shares needed to recover the secret of a
+// share written by SplitText
+func Threshold(text string) (int, error) {
+	threshold, _, err := parseText(text)
+	return threshold, err
+}
+
+func parseText(text string) (int, []byte, error) {
+	fields := strings.Split(strings.TrimSpace(text), "-")
+	if len(fields) != 3 {
+		return 0, nil, fmt.Errorf("invalid share '%s', should be threshold-index-hex", text)
+	}
+
+	threshold, err := strconv.Atoi(fields[0])
+	if err != nil || threshold < 2 {
+		return 0, nil, fmt.Errorf("invalid threshold in share '%s'", text)
+	}
+
+	x, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil || x == 0 {
+		return 0, nil, fmt.Errorf("invalid index in share '%s'", text)
+	}
+
+	y, err := hex.DecodeString(fields[2])
+	if err != nil || len(y) == 0 {
+		return 0, nil, fmt.Errorf("invalid share '%s'", text)
+	}
+
+	return threshold, append(y, byte(x)), nil
+}
+
+func checksum(secret []byte) []byte {
+	sum := sha256.Sum256(secret)
+	return sum[:checksumSize]
+}
+
+// evaluate returns the value at x of the polynomial with coefficients, lowest degree first
+func evaluate(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+	return y
+}
+
+// interpolate returns the value at 0 of the polynomial going through the points
+func interpolate(xs, ys []byte) byte {
+	var y byte
+	for i := range xs {
+		basis := byte(1)
+		for j := range xs {
+			if i != j {
+				// x_j / (x_j - x_i), subtraction is xor
+				basis = mul(basis, div(xs[j], xs[j]^xs[i]))
+			}
+		}
+		y ^= mul(ys[i], basis)
+	}
+	return y
+}
+
+// mul multiplies in GF(2^8) with the AES polynomial x^8 + x^4 + x^3 + x + 1
+func mul(a, b byte) byte {
+	var p byte
+	for b != 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1B
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// div divides a by b, b should not be zero
+func div(a, b byte) byte {
+	// b^254 is the inverse of b as b^255 = 1
+	inverse := byte(1)
+	for i := 0; i < 254; i++ {
+		inverse = mul(inverse, b)
+	}
+	return mul(a, inverse)
+}
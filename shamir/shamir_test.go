@@ -0,0 +1,75 @@
+package shamir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestField(t *testing.T) {
+	// example of FIPS 197
+	require.Equal(t, byte(0xc1), mul(0x57, 0x83))
+	for b := 1; b < 256; b++ {
+		require.Equal(t, byte(1), mul(byte(b), div(1, byte(b))))
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("SBQWY3DNPFWGSZTFNZSW45DFOJ2GK3TJNZ2A")
+
+	shares, err := Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var parts [][]byte
+		for _, i := range subset {
+			parts = append(parts, shares[i])
+		}
+
+		got, err := Combine(parts)
+		require.NoError(t, err)
+		require.Equal(t, secret, got)
+	}
+
+	got, err := Combine(shares[:2])
+	require.NoError(t, err)
+	require.NotEqual(t, secret, got)
+
+	_, err = Combine([][]byte{shares[0], shares[0]})
+	require.Error(t, err)
+
+	_, err = Split(secret, 3, 4)
+	require.Error(t, err)
+	_, err = Split(nil, 3, 2)
+	require.Error(t, err)
+}
+
+func TestText(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+
+	shares, err := SplitText(secret, 3, 2)
+	require.NoError(t, err)
+	require.Regexp(t, "^2-1-[0-9a-f]+$", shares[0])
+
+	got, err := CombineText([]string{shares[2], " " + shares[0] + "\n"})
+	require.NoError(t, err)
+	require.Equal(t, secret, got)
+
+	_, err = CombineText(shares[:1])
+	require.EqualError(t, err, "2 shares are needed, 1 given")
+
+	other, err := SplitText([]byte("another secret of the same len"[:len(secret)]), 3, 2)
+	require.NoError(t, err)
+	_, err = CombineText([]string{shares[0], other[1]})
+	require.Equal(t, ErrChecksum, err)
+
+	_, err = CombineText([]string{shares[0], "2-2-zz"})
+	require.Error(t, err)
+
+	threshold, err := Threshold(shares[1])
+	require.NoError(t, err)
+	require.Equal(t, 2, threshold)
+	_, err = Threshold("SBQWY3DNPFWGSZTF")
+	require.Error(t, err)
+}
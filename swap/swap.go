@@ -0,0 +1,127 @@
+// Package swap checks and signs atomic swaps between two accounts: a single
+// transaction with a payment in each direction, so that either both payments
+// are made or none of them and neither party has to trust the other.
+//
+// The proposer is the source of the transaction and signs it first, the
+// counterparty checks what it pays and receives, then signs and submits it.
+package swap
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/celrenheit/alfred/explain"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// Leg is one of the payments of a swap
+type Leg struct {
+	From, To string
+	Amount   string
+	Asset    xdr.Asset
+}
+
+// Swap is a transaction exchanging payments between its source account, the
+// proposer, and a counterparty
+type Swap struct {
+	Proposer, Counterparty string
+	// Give is paid by the proposer and Take by the counterparty
+	Give, Take Leg
+	// Expires is the time after which the transaction is invalid, zero if never
+	Expires time.Time
+}
+
+// Parse returns the swap made by txe. It fails unless txe holds exactly a
+// payment from its source account to the counterparty followed by a payment
+// back from the counterparty, so that nothing else can be slipped in.
+func Parse(txe xdr.TransactionEnvelope) (*Swap, error) {
+	ops := txe.Tx.Operations
+	if len(ops) != 2 {
+		return nil, fmt.Errorf("a swap has 2 operations, not %d", len(ops))
+	}
+
+	s := &Swap{Proposer: txe.Tx.SourceAccount.Address()}
+	if tb := txe.Tx.TimeBounds; tb != nil && tb.MaxTime != 0 {
+		s.Expires = time.Unix(int64(tb.MaxTime), 0)
+	}
+
+	var err error
+	if s.Give, err = leg(ops[0], s.Proposer); err != nil {
+		return nil, fmt.Errorf("operation 1: %v", err)
+	}
+	s.Counterparty = s.Give.To
+	if s.Counterparty == s.Proposer {
+		return nil, errors.New("operation 1: the proposer pays itself")
+	}
+
+	if ops[1].SourceAccount == nil {
+		return nil, errors.New("operation 2: the payment should be made by the counterparty")
+	}
+	if s.Take, err = leg(ops[1], ops[1].SourceAccount.Address()); err != nil {
+		return nil, fmt.Errorf("operation 2: %v", err)
+	}
+	if s.Take.From != s.Counterparty || s.Take.To != s.Proposer {
+		return nil, errors.New("operation 2: the payment should be made by the counterparty to the proposer")
+	}
+
+	return s, nil
+}
+
+// leg returns the payment made by op, whose source is from unless set
+func leg(op xdr.Operation, from string) (Leg, error) {
+	payment, ok := op.Body.GetPaymentOp()
+	if !ok {
+		return Leg{}, fmt.Errorf("a swap is made of payments, not %s", explain.OperationName(op.Body.Type))
+	}
+
+	if op.SourceAccount != nil && op.SourceAccount.Address() != from {
+		return Leg{}, errors.New("the payment should be made by the proposer")
+	}
+
+	return Leg{
+		From:   from,
+		To:     payment.Destination.Address(),
+		Amount: amount.String(payment.Amount),
+		Asset:  payment.Asset,
+	}, nil
+}
+
+// SignedBy reports whether txe holds a valid signature of address for the network of passphrase
+func SignedBy(txe xdr.TransactionEnvelope, address, passphrase string) bool {
+	kp, err := keypair.Parse(address)
+	if err != nil {
+		return false
+	}
+
+	hash, err := network.HashTransaction(&txe.Tx, passphrase)
+	if err != nil {
+		return false
+	}
+
+	for _, sig := range txe.Signatures {
+		if [4]byte(sig.Hint) == kp.Hint() && kp.Verify(hash[:], sig.Signature) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign adds the signature of kp to txe for the network of passphrase
+func Sign(txe *xdr.TransactionEnvelope, kp *keypair.Full, passphrase string) error {
+	hash, err := network.HashTransaction(&txe.Tx, passphrase)
+	if err != nil {
+		return err
+	}
+
+	sig, err := kp.SignDecorated(hash[:])
+	if err != nil {
+		return err
+	}
+
+	txe.Signatures = append(txe.Signatures, sig)
+	return nil
+}
@@ -0,0 +1,84 @@
+package swap
+
+import (
+	"testing"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+func randomKP(t *testing.T) *keypair.Full {
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	return kp
+}
+
+func envelope(t *testing.T, src *keypair.Full, muts ...build.TransactionMutator) xdr.TransactionEnvelope {
+	muts = append([]build.TransactionMutator{build.SourceAccount{AddressOrSeed: src.Address()}, build.Sequence{Sequence: 1}, build.TestNetwork}, muts...)
+	tx, err := build.Transaction(muts...)
+	require.NoError(t, err)
+
+	txe, err := tx.Sign(src.Seed())
+	require.NoError(t, err)
+	return *txe.E
+}
+
+func TestParse(t *testing.T) {
+	alice, bob, carol := randomKP(t), randomKP(t), randomKP(t)
+	mobi := build.CreditAmount{Code: "MOBI", Issuer: carol.Address(), Amount: "100"}
+
+	txe := envelope(t, alice,
+		build.Payment(build.Destination{AddressOrSeed: bob.Address()}, mobi),
+		build.Payment(build.SourceAccount{AddressOrSeed: bob.Address()}, build.Destination{AddressOrSeed: alice.Address()}, build.NativeAmount{Amount: "50"}),
+	)
+	s, err := Parse(txe)
+	require.NoError(t, err)
+	require.Equal(t, alice.Address(), s.Proposer)
+	require.Equal(t, bob.Address(), s.Counterparty)
+	require.Equal(t, "100.0000000", s.Give.Amount)
+	require.Equal(t, "50.0000000", s.Take.Amount)
+	require.Equal(t, xdr.AssetTypeAssetTypeNative, s.Take.Asset.Type)
+
+	tests := map[string]xdr.TransactionEnvelope{
+		"one payment": envelope(t, alice,
+			build.Payment(build.Destination{AddressOrSeed: bob.Address()}, mobi),
+		),
+		"paid to a third account": envelope(t, alice,
+			build.Payment(build.Destination{AddressOrSeed: bob.Address()}, mobi),
+			build.Payment(build.SourceAccount{AddressOrSeed: bob.Address()}, build.Destination{AddressOrSeed: carol.Address()}, build.NativeAmount{Amount: "50"}),
+		),
+		"paid by a third account": envelope(t, alice,
+			build.Payment(build.Destination{AddressOrSeed: bob.Address()}, mobi),
+			build.Payment(build.SourceAccount{AddressOrSeed: carol.Address()}, build.Destination{AddressOrSeed: alice.Address()}, build.NativeAmount{Amount: "50"}),
+		),
+		"not a payment": envelope(t, alice,
+			build.Payment(build.Destination{AddressOrSeed: bob.Address()}, mobi),
+			build.SetOptions(build.SourceAccount{AddressOrSeed: bob.Address()}, build.MasterWeight(0)),
+		),
+	}
+	for name, txe := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(txe)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	alice, bob := randomKP(t), randomKP(t)
+	txe := envelope(t, alice,
+		build.Payment(build.Destination{AddressOrSeed: bob.Address()}, build.NativeAmount{Amount: "1"}),
+		build.Payment(build.SourceAccount{AddressOrSeed: bob.Address()}, build.Destination{AddressOrSeed: alice.Address()}, build.NativeAmount{Amount: "2"}),
+	)
+
+	require.True(t, SignedBy(txe, alice.Address(), network.TestNetworkPassphrase))
+	require.False(t, SignedBy(txe, alice.Address(), network.PublicNetworkPassphrase))
+	require.False(t, SignedBy(txe, bob.Address(), network.TestNetworkPassphrase))
+
+	require.NoError(t, Sign(&txe, bob, network.TestNetworkPassphrase))
+	require.True(t, SignedBy(txe, bob.Address(), network.TestNetworkPassphrase))
+	require.Len(t, txe.Signatures, 2)
+}
@@ -0,0 +1,176 @@
+// Package telegram is a minimal client of the Telegram Bot API, covering what
+// the telegram command needs: receiving messages by long polling, answering
+// them and asking for confirmations with inline buttons.
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the URL of the Bot API
+const DefaultURL = "https://api.telegram.org"
+
+// Client calls the methods of a bot
+type Client struct {
+	// Token is the token of the bot, given by @BotFather
+	Token string
+	// URL is the URL of the Bot API, DefaultURL if empty
+	URL  string
+	HTTP *http.Client
+}
+
+// Error is an error returned by the Bot API
+type Error struct {
+	Method      string
+	Code        int
+	Description string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("telegram %s: %s (%d)", e.Method, e.Description, e.Code)
+}
+
+// Update is an incoming message or a button pressed
+type Update struct {
+	ID            int            `json:"update_id"`
+	Message       *Message       `json:"message,omitempty"`
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+}
+
+// Message is a message of a chat
+type Message struct {
+	ID   int    `json:"message_id"`
+	Chat Chat   `json:"chat"`
+	From *User  `json:"from,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+// Chat is a conversation with the bot
+type Chat struct {
+	ID int64 `json:"id"`
+}
+
+// User is a user or a bot
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+// CallbackQuery is sent when an inline button is pressed
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	From    User     `json:"from"`
+	Message *Message `json:"message,omitempty"`
+	Data    string   `json:"data,omitempty"`
+}
+
+// Keyboard is an inline keyboard attached to a message
+type Keyboard struct {
+	Buttons [][]Button `json:"inline_keyboard"`
+}
+
+// Button is a button of an inline keyboard, Data is sent back in a
+// CallbackQuery when it is pressed
+type Button struct {
+	Text string `json:"text"`
+	Data string `json:"callback_data"`
+}
+
+type response struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+// call posts params to method and decodes the result in result, if not nil
+func (c *Client) call(method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	url := c.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	endpoint := fmt.Sprintf("%s/bot%s/%s", strings.TrimRight(url, "/"), c.Token, method)
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// the error contains the URL, and so the token
+		return fmt.Errorf("telegram %s: %v", method, strings.Replace(err.Error(), c.Token, "<token>", -1))
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("telegram %s: %s", method, resp.Status)
+	}
+
+	if !r.OK {
+		return &Error{Method: method, Code: r.ErrorCode, Description: r.Description}
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
+
+// Updates waits up to timeout for the updates after offset, which is the id
+// of the last update handled plus one
+func (c *Client) Updates(offset int, timeout time.Duration) ([]Update, error) {
+	var updates []Update
+	err := c.call("getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(timeout / time.Second),
+		"allowed_updates": []string{"message", "callback_query"},
+	}, &updates)
+	return updates, err
+}
+
+// Send sends text to a chat, with buttons if keyboard is not nil
+func (c *Client) Send(chat int64, text string, keyboard *Keyboard) (*Message, error) {
+	params := map[string]interface{}{
+		"chat_id": chat,
+		"text":    text,
+	}
+	if keyboard != nil {
+		params["reply_markup"] = keyboard
+	}
+
+	var msg Message
+	if err := c.call("sendMessage", params, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// Edit replaces the text of a message sent by the bot, removing its buttons
+func (c *Client) Edit(chat int64, message int, text string) error {
+	return c.call("editMessageText", map[string]interface{}{
+		"chat_id":    chat,
+		"message_id": message,
+		"text":       text,
+	}, nil)
+}
+
+// Answer acknowledges a pressed button, text is shown briefly if not empty
+func (c *Client) Answer(query string, text string) error {
+	params := map[string]interface{}{"callback_query_id": query}
+	if text != "" {
+		params["text"] = text
+	}
+	return c.call("answerCallbackQuery", params, nil)
+}
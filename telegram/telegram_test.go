@@ -0,0 +1,62 @@
+package telegram
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+
+		var params map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+
+		switch r.URL.Path {
+		case "/bottoken/getUpdates":
+			require.Equal(t, float64(43), params["offset"])
+			require.Equal(t, float64(30), params["timeout"])
+			io.WriteString(w, `{"ok": true, "result": [
+				{"update_id": 43, "message": {"message_id": 1, "chat": {"id": 12}, "text": "balance master"}},
+				{"update_id": 44, "callback_query": {"id": "q", "from": {"id": 12}, "data": "confirm:1", "message": {"message_id": 2, "chat": {"id": 12}}}}
+			]}`)
+		case "/bottoken/sendMessage":
+			require.Equal(t, float64(12), params["chat_id"])
+			require.Equal(t, map[string]interface{}{
+				"inline_keyboard": []interface{}{[]interface{}{
+					map[string]interface{}{"text": "Confirm", "callback_data": "confirm:1"},
+				}},
+			}, params["reply_markup"])
+			io.WriteString(w, `{"ok": true, "result": {"message_id": 2, "chat": {"id": 12}, "text": "Sure?"}}`)
+		default:
+			io.WriteString(w, `{"ok": false, "error_code": 400, "description": "Bad Request: message is not modified"}`)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{Token: "token", URL: srv.URL}
+
+	updates, err := c.Updates(43, 30*time.Second)
+	require.NoError(t, err)
+	require.Len(t, updates, 2)
+	require.Equal(t, "balance master", updates[0].Message.Text)
+	require.Equal(t, int64(12), updates[0].Message.Chat.ID)
+	require.Equal(t, "confirm:1", updates[1].CallbackQuery.Data)
+	require.Equal(t, 2, updates[1].CallbackQuery.Message.ID)
+
+	msg, err := c.Send(12, "Sure?", &Keyboard{Buttons: [][]Button{{{Text: "Confirm", Data: "confirm:1"}}}})
+	require.NoError(t, err)
+	require.Equal(t, 2, msg.ID)
+
+	err = c.Edit(12, 2, "Done")
+	require.EqualError(t, err, "telegram editMessageText: Bad Request: message is not modified (400)")
+
+	require.Equal(t, []string{"/bottoken/getUpdates", "/bottoken/sendMessage", "/bottoken/editMessageText"}, calls)
+}
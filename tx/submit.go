@@ -0,0 +1,317 @@
+package tx
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/celrenheit/alfred/explain"
+	"github.com/pkg/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+const (
+	// retryBaseDelay is the delay before the first retry, it doubles on every attempt
+	retryBaseDelay = time.Second
+	// retryMaxDelay caps the delay between two attempts
+	retryMaxDelay = 30 * time.Second
+)
+
+// retry reasons
+const (
+	retryExpired = "transaction expired"
+	retryTooLate = "tx_too_late"
+	retryBadSeq  = "tx_bad_seq"
+	retryTimeout = "timeout"
+)
+
+// sleep waits between two attempts, replaced in tests
+var sleep = time.Sleep
+
+// Request describes a transaction to build, sign and submit
+type Request struct {
+	Builder   Builder
+	Submitter Submitter
+	// Accounts explain why a transaction failed, if set
+	Accounts AccountLoader
+
+	Seeds []string
+	// Signers sign the transaction after Seeds
+	Signers []Signer
+	Opts    []build.TransactionMutator
+
+	// ValidFor limits the validity of the transaction, zero means no limit
+	ValidFor time.Duration
+	// NotBefore is the time before which the transaction is invalid, zero means no limit
+	NotBefore time.Time
+	// Presign returns the signed transaction instead of submitting it
+	Presign bool
+	// Retries is the number of times a failed submission is retried
+	Retries int
+
+	// Confirm is called with the envelope of the transaction, without its
+	// signatures, before it is submitted, and again when it is rebuilt after
+	// expiring. An error aborts the submission.
+	Confirm func(unsigned string) error
+	// Log receives the progress of the retries, os.Stdout if nil
+	Log io.Writer
+	// Trace receives the envelope of each transaction built, before it is
+	// signed, if set
+	Trace func(unsigned string)
+	// Preflight checks the envelope of each transaction built before it is
+	// signed and confirmed, if set. An error aborts the submission.
+	Preflight func(unsigned string) error
+	// Pending is called with the hash and the signed envelope of each
+	// transaction before it is first submitted, if set, so that it can be
+	// resumed when alfred stops before knowing whether it went through. An
+	// error aborts the submission.
+	Pending func(hash, txeB64 string) error
+}
+
+// Result is a transaction submitted, or only signed if Presigned is set
+type Result struct {
+	Hash     string
+	Envelope string
+	// Presigned is set when the transaction was signed but not submitted
+	Presigned bool
+	Built     Built
+}
+
+// Submit builds, signs and submits the transaction described by req.
+//
+// Failed submissions are retried with an exponential backoff, up to req.Retries times:
+//   - an expired transaction (typically after waiting at the confirmation prompt)
+//     or one rejected with tx_too_late is rebuilt with fresh time bounds and confirmed again
+//   - a transaction rejected with tx_bad_seq is rebuilt with a refreshed sequence number
+//   - a transaction that timed out is submitted again as is
+//
+// Before any retry, previous attempts are looked up on horizon so that a
+// transaction which made it to the ledger despite a timeout is not sent twice.
+func Submit(req Request) (Result, error) {
+	tb, txeB64, err := req.prepare(true)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if req.Presign && !tb.Expired() {
+		return Result{Envelope: txeB64, Presigned: true, Built: tb}, nil
+	}
+
+	// envelopes of the submitted transactions, by hash
+	envelopes := make(map[string]string)
+	var submitted []string
+	for attempt := 1; ; attempt++ {
+		var reason string
+		if tb.Expired() {
+			reason, err = retryExpired, errors.New("transaction expired before being submitted")
+		} else {
+			hash, herr := tb.HashHex()
+			if herr != nil {
+				return Result{}, herr
+			}
+			if _, ok := envelopes[hash]; !ok {
+				if req.Pending != nil {
+					if err := req.Pending(hash, txeB64); err != nil {
+						return Result{}, err
+					}
+				}
+				submitted = append(submitted, hash)
+				envelopes[hash] = txeB64
+			}
+
+			var resp horizon.TransactionSuccess
+			resp, err = req.Submitter.SubmitTransaction(txeB64)
+			if err == nil {
+				return Result{Hash: resp.Hash, Envelope: txeB64, Built: tb}, nil
+			}
+
+			reason = retryReason(err)
+			if reason == "" {
+				return Result{}, err
+			}
+		}
+
+		// an attempt which timed out may have made it to the ledger since,
+		// retrying or rebuilding the transaction would then send it twice
+		for _, hash := range submitted {
+			if req.Submitter.Landed(hash) {
+				return Result{Hash: hash, Envelope: envelopes[hash], Built: tb}, nil
+			}
+		}
+
+		if attempt > req.Retries {
+			if reason == retryExpired || reason == retryTooLate {
+				return Result{}, fmt.Errorf("transaction expired %d times, try again with a longer --valid-for", attempt)
+			}
+			return Result{}, fmt.Errorf("transaction not submitted after %d attempts: %s", attempt, explain.Error(err, req.Accounts))
+		}
+
+		switch reason {
+		case retryExpired, retryTooLate:
+			req.logf("Attempt %d/%d failed (%s), rebuilding it valid for %v\n", attempt, req.Retries+1, reason, req.ValidFor)
+			tb, txeB64, err = req.prepare(true)
+		case retryBadSeq:
+			delay := retryDelay(attempt)
+			req.logf("Attempt %d/%d failed (%s), refreshing the sequence number and retrying in %v\n", attempt, req.Retries+1, reason, delay)
+			sleep(delay)
+			tb, txeB64, err = req.prepare(false)
+		case retryTimeout:
+			delay := retryDelay(attempt)
+			req.logf("Attempt %d/%d failed (%s), retrying in %v\n", attempt, req.Retries+1, reason, delay)
+			sleep(delay)
+			err = nil
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+}
+
+// Build returns the transaction described by req, with its time bounds
+func (req Request) Build() (Built, error) {
+	var bounds TimeBounds
+	bounds.MinTime = req.NotBefore
+	if req.ValidFor > 0 {
+		start := time.Now()
+		if req.NotBefore.After(start) {
+			start = req.NotBefore
+		}
+		bounds.MaxTime = start.Add(req.ValidFor)
+	}
+
+	opts := req.Opts
+	if !bounds.IsZero() {
+		opts = append(opts[:len(opts):len(opts)], bounds)
+	}
+
+	builder := req.Builder
+	if builder == nil {
+		builder = StellarBuilder{}
+	}
+
+	tx, err := builder.Build(opts)
+	if err != nil {
+		return Built{}, err
+	}
+
+	return Built{Tx: tx, Bounds: bounds}, nil
+}
+
+// prepare builds and signs the transaction, asking for confirmation if needed
+func (req Request) prepare(confirm bool) (Built, string, error) {
+	tb, err := req.Build()
+	if err != nil {
+		return Built{}, "", err
+	}
+
+	var unsigned string
+	if req.Trace != nil || req.Preflight != nil || (confirm && req.Confirm != nil) {
+		if unsigned, err = tb.Unsigned(); err != nil {
+			return Built{}, "", err
+		}
+		if req.Trace != nil {
+			req.Trace(unsigned)
+		}
+		if req.Preflight != nil {
+			if err := req.Preflight(unsigned); err != nil {
+				return Built{}, "", err
+			}
+		}
+	}
+
+	txeB64, err := tb.Sign(req.Seeds...)
+	if err != nil {
+		return Built{}, "", err
+	}
+	if len(req.Signers) > 0 {
+		if txeB64, err = addSignatures(tb, txeB64, req.Signers); err != nil {
+			return Built{}, "", err
+		}
+	}
+
+	if confirm && req.Confirm != nil {
+		if err := req.Confirm(unsigned); err != nil {
+			return Built{}, "", err
+		}
+	}
+
+	return tb, txeB64, nil
+}
+
+// addSignatures adds the signatures of signers to txeB64, the envelope of tb
+func addSignatures(tb Built, txeB64 string, signers []Signer) (string, error) {
+	hashHex, err := tb.HashHex()
+	if err != nil {
+		return "", err
+	}
+	var hash [32]byte
+	if _, err := hex.Decode(hash[:], []byte(hashHex)); err != nil {
+		return "", err
+	}
+
+	var txe xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(txeB64, &txe); err != nil {
+		return "", err
+	}
+	for _, signer := range signers {
+		sig, err := signer.Sign(hash)
+		if err != nil {
+			return "", err
+		}
+		txe.Signatures = append(txe.Signatures, sig)
+	}
+
+	return xdr.MarshalBase64(txe)
+}
+
+func (req Request) logf(format string, args ...interface{}) {
+	w := req.Log
+	if w == nil {
+		w = os.Stdout
+	}
+
+	fmt.Fprintf(w, format, args...)
+}
+
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << uint(attempt-1)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+
+	return delay
+}
+
+// retryReason returns why a failed submission can be retried, or an empty string
+func retryReason(err error) string {
+	if nerr, ok := errors.Cause(err).(net.Error); ok && nerr.Timeout() {
+		return retryTimeout
+	}
+
+	herr, ok := err.(*horizon.Error)
+	if !ok {
+		return ""
+	}
+
+	if herr.Response != nil && herr.Response.StatusCode == http.StatusGatewayTimeout {
+		return retryTimeout
+	}
+
+	codes, err := herr.ResultCodes()
+	if err != nil {
+		return ""
+	}
+
+	switch codes.TransactionCode {
+	case retryTooLate, retryBadSeq:
+		return codes.TransactionCode
+	}
+
+	return ""
+}
@@ -0,0 +1,302 @@
+package tx
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHorizon answers the submissions with errs, in order, then succeeds
+type fakeHorizon struct {
+	errs      []error
+	submitted []string
+	landed    map[string]bool
+}
+
+func (h *fakeHorizon) SubmitTransaction(txeB64 string) (horizon.TransactionSuccess, error) {
+	h.submitted = append(h.submitted, txeB64)
+	if len(h.errs) > 0 {
+		err := h.errs[0]
+		h.errs = h.errs[1:]
+		return horizon.TransactionSuccess{}, err
+	}
+
+	return horizon.TransactionSuccess{Hash: "success"}, nil
+}
+
+func (h *fakeHorizon) Landed(hash string) bool {
+	return h.landed[hash]
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func resultError(code string) error {
+	return &horizon.Error{Problem: horizon.Problem{
+		Title: "Transaction Failed",
+		Extras: map[string]json.RawMessage{
+			"result_codes": json.RawMessage(`{"transaction":"` + code + `"}`),
+		},
+	}}
+}
+
+func newRequest(t *testing.T, h *fakeHorizon) (Request, *int) {
+	src, err := keypair.Random()
+	require.NoError(t, err)
+	dest, err := keypair.Random()
+	require.NoError(t, err)
+
+	sleep = func(time.Duration) {}
+	confirms := 0
+	return Request{
+		Submitter: h,
+		Seeds:     []string{src.Seed()},
+		Opts: []build.TransactionMutator{
+			build.SourceAccount{AddressOrSeed: src.Address()},
+			build.Sequence{Sequence: 1},
+			build.TestNetwork,
+			build.Payment(build.Destination{AddressOrSeed: dest.Address()}, build.NativeAmount{Amount: "10"}),
+		},
+		ValidFor: time.Minute,
+		Retries:  2,
+		Confirm: func(string) error {
+			confirms++
+			return nil
+		},
+		Log: ioutil.Discard,
+	}, &confirms
+}
+
+func TestSubmit(t *testing.T) {
+	h := &fakeHorizon{}
+	req, confirms := newRequest(t, h)
+
+	res, err := Submit(req)
+	require.NoError(t, err)
+	require.Equal(t, "success", res.Hash)
+	require.Equal(t, h.submitted[0], res.Envelope)
+	require.False(t, res.Built.Bounds.MaxTime.IsZero())
+	require.Equal(t, 1, *confirms)
+}
+
+func TestSubmitTrace(t *testing.T) {
+	h := &fakeHorizon{}
+	req, _ := newRequest(t, h)
+
+	var traced []string
+	req.Trace = func(unsigned string) {
+		traced = append(traced, unsigned)
+	}
+
+	_, err := Submit(req)
+	require.NoError(t, err)
+	require.Len(t, traced, 1)
+
+	var unsigned, signed xdr.TransactionEnvelope
+	require.NoError(t, xdr.SafeUnmarshalBase64(traced[0], &unsigned))
+	require.NoError(t, xdr.SafeUnmarshalBase64(h.submitted[0], &signed))
+	require.Empty(t, unsigned.Signatures)
+	require.Len(t, signed.Signatures, 1)
+	require.Equal(t, signed.Tx, unsigned.Tx)
+}
+
+// keySigner signs with a key, like the agent
+type keySigner struct{ kp *keypair.Full }
+
+func (s keySigner) Sign(hash [32]byte) (xdr.DecoratedSignature, error) {
+	return s.kp.SignDecorated(hash[:])
+}
+
+func TestSubmitSigners(t *testing.T) {
+	h := &fakeHorizon{}
+	req, _ := newRequest(t, h)
+
+	cosigner, err := keypair.Random()
+	require.NoError(t, err)
+	req.Signers = []Signer{keySigner{cosigner}}
+
+	res, err := Submit(req)
+	require.NoError(t, err)
+
+	var signed xdr.TransactionEnvelope
+	require.NoError(t, xdr.SafeUnmarshalBase64(res.Envelope, &signed))
+	require.Len(t, signed.Signatures, 2)
+
+	hash, err := res.Built.HashHex()
+	require.NoError(t, err)
+	raw, err := hex.DecodeString(hash)
+	require.NoError(t, err)
+	require.NoError(t, cosigner.Verify(raw, signed.Signatures[1].Signature))
+}
+
+func TestSubmitPreflight(t *testing.T) {
+	h := &fakeHorizon{}
+	req, confirms := newRequest(t, h)
+
+	req.Preflight = func(unsigned string) error {
+		var env xdr.TransactionEnvelope
+		require.NoError(t, xdr.SafeUnmarshalBase64(unsigned, &env))
+		require.Len(t, env.Tx.Operations, 1)
+		return errors.New("underfunded")
+	}
+
+	_, err := Submit(req)
+	require.EqualError(t, err, "underfunded")
+	require.Empty(t, h.submitted)
+	require.Equal(t, 0, *confirms)
+}
+
+func TestSubmitRetries(t *testing.T) {
+	h := &fakeHorizon{errs: []error{resultError("tx_bad_seq"), timeoutError{}}}
+	req, confirms := newRequest(t, h)
+
+	res, err := Submit(req)
+	require.NoError(t, err)
+	require.Equal(t, "success", res.Hash)
+	require.Len(t, h.submitted, 3)
+	// the sequence number is refreshed without asking again
+	require.Equal(t, 1, *confirms)
+
+	h = &fakeHorizon{errs: []error{resultError("tx_bad_seq"), resultError("tx_bad_seq"), resultError("tx_bad_seq")}}
+	req, _ = newRequest(t, h)
+	_, err = Submit(req)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not submitted after 3 attempts")
+
+	h = &fakeHorizon{errs: []error{resultError("tx_failed")}}
+	req, _ = newRequest(t, h)
+	_, err = Submit(req)
+	require.IsType(t, &horizon.Error{}, err)
+	require.Len(t, h.submitted, 1)
+}
+
+func TestSubmitPending(t *testing.T) {
+	h := &fakeHorizon{errs: []error{timeoutError{}, timeoutError{}}}
+	req, _ := newRequest(t, h)
+	pending := make(map[string]string)
+	req.Pending = func(hash, txeB64 string) error {
+		require.Len(t, h.submitted, len(pending), "recorded before being submitted")
+		pending[hash] = txeB64
+		return nil
+	}
+
+	tb, err := req.Build()
+	require.NoError(t, err)
+	hash, err := tb.HashHex()
+	require.NoError(t, err)
+
+	res, err := Submit(req)
+	require.NoError(t, err)
+	require.Len(t, h.submitted, 3)
+	// submitted again as is after the timeouts, it is recorded once
+	require.Equal(t, map[string]string{hash: res.Envelope}, pending)
+
+	h = &fakeHorizon{}
+	req, _ = newRequest(t, h)
+	req.Pending = func(string, string) error { return errors.New("disk full") }
+	_, err = Submit(req)
+	require.EqualError(t, err, "disk full")
+	require.Empty(t, h.submitted)
+}
+
+func TestSubmitLanded(t *testing.T) {
+	h := &fakeHorizon{errs: []error{timeoutError{}}}
+	req, _ := newRequest(t, h)
+
+	tb, err := req.Build()
+	require.NoError(t, err)
+	hash, err := tb.HashHex()
+	require.NoError(t, err)
+	h.landed = map[string]bool{hash: true}
+
+	res, err := Submit(req)
+	require.NoError(t, err)
+	require.Equal(t, hash, res.Hash)
+	require.Len(t, h.submitted, 1)
+}
+
+func TestSubmitLandedBeforeRebuild(t *testing.T) {
+	// the first attempt times out and lands while waiting to retry, the
+	// retry is rejected with tx_too_late, or expires before being submitted
+	for _, tc := range []struct {
+		errs  []error
+		delay time.Duration
+	}{
+		{errs: []error{timeoutError{}, resultError("tx_too_late")}},
+		{errs: []error{timeoutError{}}, delay: 20 * time.Millisecond},
+	} {
+		h := &fakeHorizon{errs: tc.errs}
+		req, confirms := newRequest(t, h)
+		req.ValidFor = 10 * time.Millisecond
+
+		var first string
+		req.Pending = func(hash, _ string) error {
+			if first == "" {
+				first = hash
+			}
+			return nil
+		}
+		sleep = func(time.Duration) {
+			time.Sleep(tc.delay)
+			h.landed = map[string]bool{first: true}
+		}
+
+		res, err := Submit(req)
+		require.NoError(t, err)
+		require.Equal(t, first, res.Hash)
+		// not rebuilt, so not confirmed nor sent again
+		require.Equal(t, 1, *confirms)
+		require.Len(t, h.submitted, len(tc.errs))
+	}
+}
+
+func TestSubmitExpired(t *testing.T) {
+	h := &fakeHorizon{}
+	req, _ := newRequest(t, h)
+	req.ValidFor = 10 * time.Millisecond
+
+	confirms := 0
+	req.Confirm = func(string) error {
+		confirms++
+		if confirms == 1 {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return nil
+	}
+
+	res, err := Submit(req)
+	require.NoError(t, err)
+	require.Equal(t, "success", res.Hash)
+	require.Equal(t, 2, confirms)
+	require.Len(t, h.submitted, 1)
+
+	req.Confirm = func(string) error { return errors.New("aborted") }
+	_, err = Submit(req)
+	require.EqualError(t, err, "aborted")
+}
+
+func TestSubmitPresign(t *testing.T) {
+	h := &fakeHorizon{}
+	req, _ := newRequest(t, h)
+	req.Presign = true
+	req.NotBefore = time.Now().Add(time.Hour)
+
+	res, err := Submit(req)
+	require.NoError(t, err)
+	require.True(t, res.Presigned)
+	require.NotEmpty(t, res.Envelope)
+	require.Equal(t, req.NotBefore.Add(time.Minute), res.Built.Bounds.MaxTime)
+	require.Empty(t, h.submitted)
+}
@@ -0,0 +1,136 @@
+// Package tx builds, signs and submits the transactions of alfred.
+//
+// It only depends on the network through the Submitter and AccountLoader
+// interfaces, so that the submission logic can be tested against a fake
+// horizon. NewHorizon returns the implementation backed by a horizon client.
+package tx
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// Builder constructs transactions from mutators
+type Builder interface {
+	Build(opts []build.TransactionMutator) (Tx, error)
+}
+
+// Tx is a transaction built by a Builder
+type Tx interface {
+	// Sign returns the envelope signed by seeds, encoded in base64
+	Sign(seeds ...string) (string, error)
+	// Unsigned returns the envelope without signatures, encoded in base64
+	Unsigned() (string, error)
+	HashHex() (string, error)
+}
+
+// Signer signs transactions with a key held elsewhere, such as by alfred
+// agent
+type Signer interface {
+	// Sign returns the signature of hash, the hash of a transaction
+	Sign(hash [32]byte) (xdr.DecoratedSignature, error)
+}
+
+// AccountLoader loads accounts from the network
+type AccountLoader interface {
+	LoadAccount(id string) (horizon.Account, error)
+}
+
+// Submitter sends transactions to the network
+type Submitter interface {
+	SubmitTransaction(txeB64 string) (horizon.TransactionSuccess, error)
+	// Landed reports whether the transaction with hash is in the ledger
+	Landed(hash string) bool
+}
+
+// StellarBuilder builds transactions with the build package of stellar/go
+type StellarBuilder struct{}
+
+// Build implements Builder
+func (StellarBuilder) Build(opts []build.TransactionMutator) (Tx, error) {
+	tx, err := build.Transaction(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return stellarTx{tx}, nil
+}
+
+type stellarTx struct {
+	*build.TransactionBuilder
+}
+
+func (t stellarTx) Sign(seeds ...string) (string, error) {
+	txe, err := t.TransactionBuilder.Sign(seeds...)
+	if err != nil {
+		return "", err
+	}
+
+	return txe.Base64()
+}
+
+func (t stellarTx) Unsigned() (string, error) {
+	return xdr.MarshalBase64(xdr.TransactionEnvelope{Tx: *t.TX})
+}
+
+// Horizon is the Submitter and AccountLoader backed by a horizon client
+type Horizon struct {
+	*horizon.Client
+}
+
+// NewHorizon returns the Submitter and AccountLoader backed by client
+func NewHorizon(client *horizon.Client) *Horizon {
+	return &Horizon{Client: client}
+}
+
+// Landed implements Submitter
+func (h *Horizon) Landed(hash string) bool {
+	resp, err := h.HTTP.Get(strings.TrimRight(h.URL, "/") + "/transactions/" + hash)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
+
+// TimeBounds is a transaction mutator setting the time bounds of a transaction.
+// A zero MaxTime means the transaction never expires.
+type TimeBounds struct {
+	MinTime, MaxTime time.Time
+}
+
+// MutateTransaction implements build.TransactionMutator
+func (t TimeBounds) MutateTransaction(b *build.TransactionBuilder) error {
+	tb := &xdr.TimeBounds{}
+	if !t.MinTime.IsZero() {
+		tb.MinTime = xdr.Uint64(t.MinTime.Unix())
+	}
+	if !t.MaxTime.IsZero() {
+		tb.MaxTime = xdr.Uint64(t.MaxTime.Unix())
+	}
+
+	b.TX.TimeBounds = tb
+	return nil
+}
+
+// IsZero reports whether no bound is set
+func (t TimeBounds) IsZero() bool {
+	return t.MinTime.IsZero() && t.MaxTime.IsZero()
+}
+
+// Built is a transaction ready to be signed
+type Built struct {
+	Tx
+	Bounds TimeBounds
+}
+
+// Expired reports whether the transaction can no longer be submitted
+func (b Built) Expired() bool {
+	return !b.Bounds.MaxTime.IsZero() && time.Now().After(b.Bounds.MaxTime)
+}
@@ -4,17 +4,30 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
-	"io/ioutil"
-	"os"
-
-	"gopkg.in/yaml.v2"
 
+	"github.com/celrenheit/alfred/hardware"
 	"github.com/stellar/go/keypair"
 )
 
 type Alfred struct {
 	Stellar WalletsManager `yaml:"stellar,omitempty"`
-	secret  []byte
+	// Hardware is the hardware key the secret is derived from, if any
+	Hardware *hardware.Key `yaml:"hardware,omitempty"`
+	secret   []byte
+
+	// compartment is the index, from 1, of the compartment of a decoy
+	compartment int
+	// decoy is set when m was opened from a compartment other than the one
+	// of the main content
+	decoy bool
+	// stored is the secret the compartment of m was read or last written
+	// with, which differs from secret once the secret is changed
+	stored []byte
+	// private is set when the names and addresses of the wallets are not
+	// stored in clear, see IsPrivate
+	private bool
+	// index is the reverse index of the names, built when first needed
+	index *names
 }
 
 func (a *Alfred) Unlock(secret []byte) error {
@@ -31,16 +44,45 @@ func (a *Alfred) Unlock(secret []byte) error {
 func (a *Alfred) IsUnlocked() bool { return a.secret != nil }
 
 type alfredyaml struct {
-	Stellar struct {
-		Wallets  []walletyaml       `yaml:"wallets,omitempty"`
-		Contacts map[string]Contact `yaml:"contacts,omitempty"`
+	Version  int           `yaml:"version,omitempty"`
+	Hardware *hardware.Key `yaml:"hardware,omitempty"`
+	Stellar  struct {
+		Wallets    []walletyaml       `yaml:"wallets,omitempty"`
+		Contacts   map[string]Contact `yaml:"contacts,omitempty"`
+		Synced     map[string]Contact `yaml:"synced,omitempty"`
+		Violations []Violation        `yaml:"violations,omitempty"`
+		Log        []LogEntry         `yaml:"log,omitempty"`
+		Tokens     []AuthToken        `yaml:"tokens,omitempty"`
+		KYC        []kycyaml          `yaml:"kyc,omitempty"`
+		Strategies []Strategy         `yaml:"strategies,omitempty"`
+		Offers     []ExpiringOffer    `yaml:"offers,omitempty"`
+		Escrows    []Escrow           `yaml:"escrows,omitempty"`
+		Templates  []Template         `yaml:"templates,omitempty"`
+		Invoices   []Invoice          `yaml:"invoices,omitempty"`
+		Pending    []PendingTx        `yaml:"pending,omitempty"`
+		Archived   []walletyaml       `yaml:"archived,omitempty"`
 	} `yaml:"stellar,omitempty"`
+	// Public is the content read without the secret, stored outside of the
+	// compartments unless the database is private
+	Public       *alfredyaml `yaml:"public,omitempty"`
+	Compartments []string    `yaml:"compartments,omitempty"`
+	// Main is set in the compartment of the main content
+	Main bool `yaml:"main,omitempty"`
+	// Private is set in the compartment of a private database
+	Private bool `yaml:"private,omitempty"`
 }
 
 type walletyaml struct {
 	Name    string `yaml:"name,omitempty"`
 	Address string `yaml:"address,omitempty"`
 	Seed    string `yaml:"seed,omitempty"`
+	Policy  Policy `yaml:"policy,omitempty"`
+	Cold    string `yaml:"cold,omitempty"`
+	// Protected is set when the seed is also encrypted with the passphrase
+	// of the wallet
+	Protected bool     `yaml:"protected,omitempty"`
+	Aliases   []string `yaml:"aliases,omitempty"`
+	Tags      []string `yaml:"tags,omitempty"`
 }
 
 func (a Alfred) MarshalYAML() (interface{}, error) {
@@ -48,28 +90,81 @@ func (a Alfred) MarshalYAML() (interface{}, error) {
 		return nil, errors.New("no secret set")
 	}
 
-	j := alfredyaml{}
+	// the hardware key is stored outside of the compartments, see Save
+	j := alfredyaml{Version: CurrentVersion, Main: !a.decoy, Private: a.private}
 	j.Stellar.Contacts = a.Stellar.Contacts
-	for _, w := range a.Stellar.Wallets {
-		kp, ok := w.Keypair.(*keypair.Full)
-		if !ok || a.secret == nil {
+	j.Stellar.Synced = a.Stellar.Synced
+	j.Stellar.Violations = a.Stellar.Violations
+	j.Stellar.Log = a.Stellar.Log
+	j.Stellar.Tokens = a.Stellar.Tokens
+	j.Stellar.Strategies = a.Stellar.Strategies
+	j.Stellar.Offers = a.Stellar.Offers
+	j.Stellar.Escrows = a.Stellar.Escrows
+	j.Stellar.Templates = a.Stellar.Templates
+	j.Stellar.Invoices = a.Stellar.Invoices
+	j.Stellar.Pending = a.Stellar.Pending
+
+	kyc, err := encryptKYC(a.secret, a.Stellar.KYC)
+	if err != nil {
+		return nil, err
+	}
+	j.Stellar.KYC = kyc
+
+	if j.Stellar.Wallets, err = a.encodeWallets(a.Stellar.Wallets); err != nil {
+		return nil, err
+	}
+	if j.Stellar.Archived, err = a.encodeWallets(a.Stellar.Archived); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// encodeWallets returns ws with their seeds encrypted
+func (a Alfred) encodeWallets(ws []*Wallet) ([]walletyaml, error) {
+	var encoded []walletyaml
+	for _, w := range ws {
+		if w.IsCold() {
+			encoded = append(encoded, walletyaml{
+				Name:    w.Name,
+				Address: w.Keypair.Address(),
+				Policy:  w.Policy,
+				Cold:    w.Cold,
+				Aliases: w.Aliases,
+				Tags:    w.Tags,
+			})
+			continue
+		}
+
+		if a.secret == nil {
 			return nil, errors.New("you should unlock alfred for writing")
 		}
-		seed := getSeed(kp.Seed())
+
+		seed := w.wrapped
+		if !w.IsProtected() {
+			kp, ok := w.Keypair.(*keypair.Full)
+			if !ok {
+				return nil, errors.New("you should unlock alfred for writing")
+			}
+			seed = getSeed(kp.Seed())
+		}
 		encrypted, err := encrypt(a.secret, seed)
 		if err != nil {
 			return nil, err
 		}
 
-		encoded := base64.RawStdEncoding.EncodeToString(encrypted)
-		j.Stellar.Wallets = append(j.Stellar.Wallets, walletyaml{
-			Name:    w.Name,
-			Address: kp.Address(),
-			Seed:    encoded,
+		encoded = append(encoded, walletyaml{
+			Name:      w.Name,
+			Address:   w.Keypair.Address(),
+			Seed:      base64.RawStdEncoding.EncodeToString(encrypted),
+			Policy:    w.Policy,
+			Protected: w.IsProtected(),
+			Aliases:   w.Aliases,
+			Tags:      w.Tags,
 		})
 	}
 
-	return j, nil
+	return encoded, nil
 }
 
 func (a *Alfred) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -78,56 +173,144 @@ func (a *Alfred) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal(&aj); err != nil {
 		return err
 	}
+	// without the secret, the wallets are read from the public content
+	if a.secret == nil && aj.Public != nil {
+		hardware := aj.Hardware
+		aj = *aj.Public
+		aj.Hardware = hardware
+	}
+	a.private = aj.Private
 
-	for _, j := range aj.Stellar.Wallets {
-		w := &Wallet{}
-		w.Name = j.Name
-		if a.secret == nil {
-			var err error
-			w.Keypair, err = keypair.Parse(j.Address)
-			if err != nil {
-				return err
-			}
-		} else {
-			decoded, err := base64.RawStdEncoding.DecodeString(j.Seed)
-			if err != nil {
-				return err
-			}
+	var err error
+	if a.Stellar.Wallets, err = a.decodeWallets(aj.Stellar.Wallets); err != nil {
+		return err
+	}
+	if a.Stellar.Archived, err = a.decodeWallets(aj.Stellar.Archived); err != nil {
+		return err
+	}
+	a.setUnencrypted(aj)
 
-			seed, err := decrypt(a.secret, decoded)
-			if err != nil {
-				return err
-			}
+	// without the secret the answers can not be read, nor written back
+	if a.secret != nil {
+		kyc, err := decryptKYC(a.secret, aj.Stellar.KYC)
+		if err != nil {
+			return err
+		}
+		a.Stellar.KYC = kyc
+	}
 
-			var seed32 [32]byte
-			copy(seed32[:], seed)
-			kp, err := keypair.FromRawSeed(seed32)
-			if err != nil {
-				return err
-			}
+	return nil
+}
 
-			if kp.Address() != j.Address {
-				return errors.New("address mismatch, password may be incorrect")
-			}
+// setUnencrypted sets the entries of aj stored in clear
+func (a *Alfred) setUnencrypted(aj alfredyaml) {
+	a.Hardware = aj.Hardware
+	a.Stellar.Contacts = aj.Stellar.Contacts
+	a.Stellar.Synced = aj.Stellar.Synced
+	a.Stellar.Violations = aj.Stellar.Violations
+	a.Stellar.Log = aj.Stellar.Log
+	a.Stellar.Tokens = aj.Stellar.Tokens
+	a.Stellar.Strategies = aj.Stellar.Strategies
+	a.Stellar.Offers = aj.Stellar.Offers
+	a.Stellar.Escrows = aj.Stellar.Escrows
+	a.Stellar.Templates = aj.Stellar.Templates
+	a.Stellar.Invoices = aj.Stellar.Invoices
+	a.Stellar.Pending = aj.Stellar.Pending
+}
 
-			w.Name = j.Name
-			w.Keypair = kp
+// decodeWallets returns the wallets of js, with only their addresses when
+// alfred is locked or when they are cold
+func (a *Alfred) decodeWallets(js []walletyaml) ([]*Wallet, error) {
+	var ws []*Wallet
+	for _, j := range js {
+		w, err := a.decodeWallet(j)
+		if err != nil {
+			return nil, err
 		}
+		ws = append(ws, w)
+	}
 
-		a.Stellar.Wallets = append(a.Stellar.Wallets, w)
+	return ws, nil
+}
+
+// decodeWallet returns the wallet of j, see decodeWallets
+func (a *Alfred) decodeWallet(j walletyaml) (*Wallet, error) {
+	w := &Wallet{}
+	w.Name = j.Name
+	w.Policy = j.Policy
+	w.Cold = j.Cold
+	w.Aliases, w.Tags = j.Aliases, j.Tags
+	if a.secret == nil || w.IsCold() {
+		var err error
+		w.Keypair, err = keypair.Parse(j.Address)
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
 	}
-	a.Stellar.Contacts = aj.Stellar.Contacts
-	return nil
+
+	decoded, err := base64.RawStdEncoding.DecodeString(j.Seed)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := decrypt(a.secret, decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	// the seed is still encrypted with the passphrase of the wallet
+	if j.Protected {
+		if w.Keypair, err = keypair.Parse(j.Address); err != nil {
+			return nil, err
+		}
+		w.wrapped = seed
+		return w, nil
+	}
+
+	var seed32 [32]byte
+	copy(seed32[:], seed)
+	kp, err := keypair.FromRawSeed(seed32)
+	if err != nil {
+		return nil, err
+	}
+
+	if kp.Address() != j.Address {
+		return nil, errors.New("address mismatch, password may be incorrect")
+	}
+
+	w.Keypair = kp
+	return w, nil
 }
 
 type WalletsManager struct {
-	Wallets  []*Wallet          `yaml:"wallets,omitempty"`
-	Contacts map[string]Contact `yaml:"contacts,omitempty"`
+	Wallets    []*Wallet          `yaml:"wallets,omitempty"`
+	Contacts   map[string]Contact `yaml:"contacts,omitempty"`
+	Synced     map[string]Contact `yaml:"synced,omitempty"`
+	Violations []Violation        `yaml:"violations,omitempty"`
+	Log        []LogEntry         `yaml:"log,omitempty"`
+	Tokens     []AuthToken        `yaml:"tokens,omitempty"`
+	KYC        []KYC              `yaml:"kyc,omitempty"`
+	Strategies []Strategy         `yaml:"strategies,omitempty"`
+	Offers     []ExpiringOffer    `yaml:"offers,omitempty"`
+	Escrows    []Escrow           `yaml:"escrows,omitempty"`
+	Templates  []Template         `yaml:"templates,omitempty"`
+	Invoices   []Invoice          `yaml:"invoices,omitempty"`
+	// Pending are the transactions submitted without knowing yet whether
+	// they went through
+	Pending []PendingTx `yaml:"pending,omitempty"`
+	// Archived are the wallets replaced by alfred rotate
+	Archived []*Wallet `yaml:"archived,omitempty"`
 }
 
 type Contact struct {
-	Address string `yaml:"address,omitempty"`
-	Memo    *Memo  `yaml:"memo,omitempty"`
+	Address  string        `yaml:"address,omitempty"`
+	Memo     *Memo         `yaml:"memo,omitempty"`
+	Verified *Verification `yaml:"verified,omitempty"`
+	// Aliases are the other names of the contact
+	Aliases []string `yaml:"aliases,omitempty"`
+	// Tags group the contact with other wallets and contacts, such as exchange
+	Tags []string `yaml:"tags,omitempty"`
 }
 
 func OpenSecretString(path string, secretStr string) (*Alfred, error) {
@@ -139,32 +322,36 @@ func OpenSecretString(path string, secretStr string) (*Alfred, error) {
 	return Open(path, secret)
 }
 
+// Open opens the database at path, a file path or a location of a store
+// registered with RegisterStore, see OpenStore
 func Open(path string, secret []byte) (*Alfred, error) {
-	b, err := ioutil.ReadFile(path)
-	if os.IsNotExist(err) {
-
-	} else if err != nil {
+	s, err := OpenStore(path)
+	if err != nil {
 		return nil, err
 	}
 
-	var a Alfred
-	a.Unlock(secret)
-	if len(b) > 0 {
-		err = yaml.Unmarshal(b, &a)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return &a, nil
+	return Load(s, secret)
 }
 
+// Write saves m to the database at path, see Open
 func Write(path string, m *Alfred) error {
-	ciphertext, err := yaml.Marshal(m)
+	s, err := OpenStore(path)
 	if err != nil {
 		return err
 	}
 
-	return ioutil.WriteFile(path, ciphertext, 0600)
+	return Save(s, m)
+}
+
+// Update applies fn to the database at path, unlocked with secret, and
+// saves it, see UpdateStore
+func Update(path string, secret []byte, fn func(m *Alfred) error) (*Alfred, error) {
+	s, err := OpenStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return UpdateStore(s, secret, fn)
 }
 
 func (m *Alfred) AddWallet(w *Wallet) error {
@@ -173,6 +360,7 @@ func (m *Alfred) AddWallet(w *Wallet) error {
 	}
 
 	m.Stellar.Wallets = append(m.Stellar.Wallets, w)
+	m.ForgetNames()
 	return nil
 }
 
@@ -193,6 +381,7 @@ func (m *Alfred) AddContact(name, addr string, memo *Memo) error {
 		Address: addr,
 		Memo:    memo,
 	}
+	m.ForgetNames()
 
 	return nil
 }
@@ -207,6 +396,7 @@ func (m *Alfred) WalletByAddress(address string) *Wallet {
 	return nil
 }
 
+// WalletByName returns the wallet named name, or having the alias name
 func (m *Alfred) WalletByName(name string) *Wallet {
 	for _, w := range m.Stellar.Wallets {
 		if w.Name == name {
@@ -214,5 +404,5 @@ func (m *Alfred) WalletByName(name string) *Wallet {
 		}
 	}
 
-	return nil
+	return m.names().walletAliases[name]
 }
@@ -3,10 +3,17 @@ package wallet
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/celrenheit/alfred/hardware"
 	"github.com/stellar/go/keypair"
 	"github.com/stellar/go/xdr"
 	"github.com/stretchr/testify/require"
@@ -48,6 +55,9 @@ func TestManager(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, kp.Address(), gotKP.Address())
 
+	_, err = Open(path, []byte("wrong"))
+	require.Error(t, err)
+
 	//
 
 	m, err = Open(path, []byte(secret))
@@ -92,6 +102,34 @@ func TestMemo(t *testing.T) {
 	require.Equal(t, want, got)
 }
 
+func TestDetectPersonalData(t *testing.T) {
+	var tests = []struct {
+		input string
+		want  []string
+	}{
+		{"dinner", nil},
+		{"invoice 2018-42", nil},
+		{"123456789", nil},
+		{"alice@example.com", []string{"an email address"}},
+		{"2018-01-01", nil},
+		{"call +33 6 12 34 56 78", []string{"a phone number"}},
+		{"555-123-4567", []string{"a phone number"}},
+		{"06.12.34.56.78", []string{"a phone number"}},
+		{"rent John Smith", []string{"a name"}},
+		{"Dr Who", []string{"a name"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			require.Equal(t, test.want, DetectPersonalData(test.input))
+		})
+	}
+
+	memo, err := MemoFromString(MEMO_ID, "123456789")
+	require.NoError(t, err)
+	require.Empty(t, memo.PersonalData())
+}
+
 func BenchmarkRandom(b *testing.B) {
 	b.ReportAllocs()
 
@@ -101,3 +139,1015 @@ func BenchmarkRandom(b *testing.B) {
 		}
 	})
 }
+
+func TestPolicy(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	w := New("master", kp)
+	w.Policy = Policy{MaxPerDay: 500, ConfirmAbove: 100, ContactsOnly: true}
+	require.NoError(t, m.AddWallet(w))
+	m.LogViolation(Violation{Wallet: "master", Rule: "destination is not a contact", Destination: kp.Address()})
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, nil)
+	require.NoError(t, err)
+	require.Equal(t, w.Policy, m.Stellar.Wallets[0].Policy)
+	require.Len(t, m.Stellar.Violations, 1)
+	require.Equal(t, "max 500 XLM per day, always require confirmation above 100 XLM, deny destinations not in contacts", w.Policy.String())
+
+	violations, confirm := w.Policy.Check(Payment{Amount: 50, Destination: kp.Address(), Known: true}, 400)
+	require.Empty(t, violations)
+	require.False(t, confirm)
+
+	violations, confirm = w.Policy.Check(Payment{Amount: 150, Destination: kp.Address(), Known: false}, 400)
+	require.Len(t, violations, 2)
+	require.True(t, confirm)
+
+	require.True(t, Policy{}.IsZero())
+	require.Equal(t, "no policy", Policy{}.String())
+}
+
+func TestLog(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	for _, hash := range []string{"aaaa", "bbbb", "cccc"} {
+		m.AppendLog(LogEntry{Time: time.Now(), Network: "testnet", Hash: hash, Envelope: "AAAA", Operations: []string{"payment"}})
+	}
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, nil)
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Log, 3)
+	i, err := m.VerifyLog()
+	require.NoError(t, err)
+	require.Equal(t, -1, i)
+
+	m.Stellar.Log[1].Envelope = "BBBB"
+	i, err = m.VerifyLog()
+	require.Error(t, err)
+	require.Equal(t, 2, i)
+
+	m.Stellar.Log = append(m.Stellar.Log[:1], m.Stellar.Log[2:]...)
+	i, err = m.VerifyLog()
+	require.Error(t, err)
+	require.Equal(t, 1, i)
+}
+
+func TestLogByIdempotencyKey(t *testing.T) {
+	m := &Alfred{}
+	m.AppendLog(LogEntry{Network: "testnet", Hash: "aaaa", IdempotencyKey: "rent-june"})
+	m.AppendLog(LogEntry{Network: "testnet", Hash: "bbbb"})
+	m.AppendLog(LogEntry{Network: "public", Hash: "cccc", IdempotencyKey: "rent-june"})
+	m.AppendLog(LogEntry{Network: "testnet", Hash: "dddd", IdempotencyKey: "rent-june"})
+
+	var hashes []string
+	for _, e := range m.LogByIdempotencyKey("testnet", "rent-june") {
+		hashes = append(hashes, e.Hash)
+	}
+	require.Equal(t, []string{"aaaa", "dddd"}, hashes)
+	require.Empty(t, m.LogByIdempotencyKey("testnet", "rent-july"))
+	require.Empty(t, m.LogByIdempotencyKey("testnet", ""))
+
+	i, err := m.VerifyLog()
+	require.NoError(t, err)
+	require.Equal(t, -1, i)
+	m.Stellar.Log[0].IdempotencyKey = "rent-july"
+	i, err = m.VerifyLog()
+	require.Error(t, err)
+	require.Equal(t, 1, i)
+}
+
+func TestAuthToken(t *testing.T) {
+	m := &Alfred{}
+	m.SetAuthToken(AuthToken{Domain: "anchor.com", Account: "GA", JWT: "old", Expires: time.Now().Add(time.Hour)})
+	m.SetAuthToken(AuthToken{Domain: "other.com", Account: "GA", JWT: "expired", Expires: time.Now().Add(-time.Hour)})
+
+	jwt, ok := m.AuthToken("anchor.com", "GA")
+	require.True(t, ok)
+	require.Equal(t, "old", jwt)
+
+	_, ok = m.AuthToken("anchor.com", "GB")
+	require.False(t, ok)
+	_, ok = m.AuthToken("other.com", "GA")
+	require.False(t, ok)
+
+	m.SetAuthToken(AuthToken{Domain: "anchor.com", Account: "GA", JWT: "new", Expires: time.Now().Add(time.Hour)})
+	require.Len(t, m.Stellar.Tokens, 1)
+	jwt, _ = m.AuthToken("anchor.com", "GA")
+	require.Equal(t, "new", jwt)
+
+	// tokens about to expire are not used
+	m.SetAuthToken(AuthToken{Domain: "anchor.com", Account: "GA", JWT: "soon", Expires: time.Now().Add(10 * time.Second)})
+	_, ok = m.AuthToken("anchor.com", "GA")
+	require.False(t, ok)
+}
+
+func TestKYC(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	m.SetKYCAnswers("GA", map[string]string{"first_name": "Jennifer", "last_name": "Doe"})
+	m.SetKYCAnswers("GA", map[string]string{"last_name": "Smith"})
+	m.SetKYCAnswers("GB", map[string]string{"first_name": "Bob"})
+	require.NoError(t, Write(path, m))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "Jennifer")
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"first_name": "Jennifer", "last_name": "Smith"}, m.KYCAnswers("GA"))
+	require.Equal(t, map[string]string{"first_name": "Bob"}, m.KYCAnswers("GB"))
+	require.Empty(t, m.KYCAnswers("GC"))
+
+	m, err = Open(path, nil)
+	require.NoError(t, err)
+	require.Empty(t, m.KYCAnswers("GA"))
+}
+
+func TestStrategy(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	start := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	require.Equal(t, 1, m.AddStrategy(Strategy{Kind: StrategyDCA, Amount: "50", SellingCode: "XLM", BuyingCode: "MOBI", Every: week, Next: start}))
+	require.Equal(t, 2, m.AddStrategy(Strategy{Kind: StrategyDCA, Amount: "10", SellingCode: "XLM", BuyingCode: "SLT", Every: time.Hour, Next: start.Add(time.Hour)}))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Strategies, 2)
+	require.Equal(t, []int{1}, m.DueStrategies(start))
+	require.Equal(t, []int{1, 2}, m.DueStrategies(start.Add(time.Hour)))
+
+	// missed runs are not caught up
+	s, err := m.Strategy(1)
+	require.NoError(t, err)
+	s.Record(StrategyRun{Time: start.Add(2 * week), Status: RunSkipped, Reason: "thin order book"})
+	require.Equal(t, start.Add(3*week), s.Next)
+	require.Len(t, s.Runs, 1)
+
+	require.NoError(t, m.RemoveStrategy(1))
+	require.Error(t, m.RemoveStrategy(1))
+	_, err = m.Strategy(1)
+	require.Error(t, err)
+	require.Equal(t, 3, m.AddStrategy(Strategy{Kind: StrategyDCA}))
+}
+
+func TestExpiringOffer(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	now := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+	m.TrackOffer(ExpiringOffer{ID: 1, Network: "testnet", Wallet: "master", Expires: now.Add(time.Hour)})
+	m.TrackOffer(ExpiringOffer{ID: 2, Network: "testnet", Wallet: "master", Expires: now.Add(-time.Minute)})
+	m.TrackOffer(ExpiringOffer{ID: 2, Network: "public", Wallet: "master", Expires: now.Add(-time.Minute)})
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Offers, 3)
+
+	expires, ok := m.OfferExpiry("testnet", 1)
+	require.True(t, ok)
+	require.True(t, expires.Equal(now.Add(time.Hour)))
+	_, ok = m.OfferExpiry("public", 1)
+	require.False(t, ok)
+
+	expired := m.ExpiredOffers("testnet", now)
+	require.Len(t, expired, 1)
+	require.Equal(t, int64(2), expired[0].ID)
+
+	m.ForgetOffer("testnet", 2)
+	require.Empty(t, m.ExpiredOffers("testnet", now))
+	require.Len(t, m.ExpiredOffers("public", now), 1)
+}
+
+func TestEscrow(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	unlock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := Escrow{Network: "testnet", Wallet: "master", Amount: "1000", UnlockAfter: unlock, RecoverAfter: unlock.Add(30 * 24 * time.Hour), Unlock: "AAAA", Recovery: "BBBB"}
+	require.Equal(t, 1, m.AddEscrow(e))
+	require.Equal(t, 2, m.AddEscrow(e))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Escrows, 2)
+
+	got, err := m.Escrow(2)
+	require.NoError(t, err)
+	require.Equal(t, "BBBB", got.Recovery)
+	require.True(t, got.UnlockAfter.Equal(unlock))
+
+	_, err = m.Escrow(3)
+	require.Error(t, err)
+}
+
+func TestTemplate(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	require.NoError(t, m.AddTemplate("rent", "send {amount} XLM from master to landlord memo \"rent {month}\""))
+	require.Error(t, m.AddTemplate("rent", "send 1 XLM from master to landlord"))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	tpl, err := m.Template("rent")
+	require.NoError(t, err)
+	require.Equal(t, []string{"amount", "month"}, tpl.Variables())
+
+	_, err = tpl.Expand(map[string]string{"amount": "500"})
+	require.Error(t, err)
+	got, err := tpl.Expand(map[string]string{"amount": "500", "month": "may"})
+	require.NoError(t, err)
+	require.Equal(t, "send 500 XLM from master to landlord memo \"rent may\"", got)
+
+	require.NoError(t, m.RemoveTemplate("rent"))
+	require.Error(t, m.RemoveTemplate("rent"))
+	_, err = m.Template("rent")
+	require.Error(t, err)
+}
+
+func TestInvoice(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	inv := Invoice{Network: "testnet", Wallet: "master", Address: "GA", Amount: "120", Code: "USDC", Issuer: "GB", Memo: "inv-1a2b3c4d", Note: "design work"}
+	require.Equal(t, 1, m.AddInvoice(inv))
+	require.Equal(t, 2, m.AddInvoice(inv))
+
+	got, err := m.Invoice(1)
+	require.NoError(t, err)
+	require.True(t, got.Matches("120.0000000", "USDC", "GB", "inv-1a2b3c4d"))
+	require.False(t, got.Matches("119.9999999", "USDC", "GB", "inv-1a2b3c4d"))
+	require.False(t, got.Matches("120", "USDC", "GC", "inv-1a2b3c4d"))
+	require.False(t, got.Matches("120", "USDC", "GB", ""))
+
+	paid := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+	got.MarkPaid("abcd", paid)
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	got, err = m.Invoice(1)
+	require.NoError(t, err)
+	require.NotNil(t, got.Paid)
+	require.True(t, got.Paid.Equal(paid))
+	require.Equal(t, "abcd", got.Transaction)
+
+	got, err = m.Invoice(2)
+	require.NoError(t, err)
+	require.Nil(t, got.Paid)
+
+	_, err = m.Invoice(3)
+	require.Error(t, err)
+}
+
+func TestPending(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	created := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, 1, m.AddPending(PendingTx{Network: "testnet", Hash: "aaaa", Envelope: "AAAA", Created: created, IdempotencyKey: "rent"}))
+	require.Equal(t, 2, m.AddPending(PendingTx{Network: "testnet", Hash: "bbbb", Envelope: "BBBB", Created: created}))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	p, err := m.PendingTx(1)
+	require.NoError(t, err)
+	require.Equal(t, "aaaa", p.Hash)
+	require.Equal(t, "rent", p.IdempotencyKey)
+	require.True(t, p.Created.Equal(created))
+
+	m.RemovePending(1)
+	_, err = m.PendingTx(1)
+	require.Error(t, err)
+	require.Len(t, m.Stellar.Pending, 1)
+	require.Equal(t, 3, m.AddPending(PendingTx{Network: "testnet", Hash: "cccc"}))
+}
+
+func TestRotate(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	old, err := keypair.Random()
+	require.NoError(t, err)
+	next, err := keypair.Random()
+	require.NoError(t, err)
+
+	w := New("master", old)
+	w.Policy = Policy{MaxPerDay: 100}
+	require.NoError(t, m.AddWallet(w))
+	require.NoError(t, m.AddWallet(New("master-next", next)))
+
+	require.Error(t, m.Rotate("master", "unknown"))
+	require.NoError(t, m.Rotate("master", "master-next"))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Wallets, 1)
+	require.Equal(t, "master", m.Stellar.Wallets[0].Name)
+	require.Equal(t, next.Seed(), m.Stellar.Wallets[0].Keypair.(*keypair.Full).Seed())
+	require.Equal(t, 100.0, m.Stellar.Wallets[0].Policy.MaxPerDay)
+
+	require.Len(t, m.Stellar.Archived, 1)
+	require.Equal(t, old.Seed(), m.Stellar.Archived[0].Keypair.(*keypair.Full).Seed())
+}
+
+func TestAccountName(t *testing.T) {
+	m := &Alfred{}
+
+	own, err := keypair.Random()
+	require.NoError(t, err)
+	next, err := keypair.Random()
+	require.NoError(t, err)
+	other, err := keypair.Random()
+	require.NoError(t, err)
+
+	_, ok := m.AccountName(own.Address())
+	require.False(t, ok)
+
+	require.NoError(t, m.AddContact("me", own.Address(), nil))
+	require.NoError(t, m.AddContact("jennifer", other.Address(), nil))
+	require.NoError(t, m.AddContact("jenny", other.Address(), nil))
+	name, ok := m.AccountName(own.Address())
+	require.True(t, ok)
+	require.Equal(t, "me", name)
+	require.False(t, m.IsWallet(own.Address()))
+	name, _ = m.AccountName(other.Address())
+	require.Equal(t, "jennifer", name)
+
+	// a wallet takes precedence over a contact
+	require.NoError(t, m.AddWallet(New("master", own)))
+	name, _ = m.AccountName(own.Address())
+	require.Equal(t, "master", name)
+	require.True(t, m.IsWallet(own.Address()))
+
+	require.NoError(t, m.AddWallet(New("master-next", next)))
+	require.NoError(t, m.Rotate("master", "master-next"))
+	name, _ = m.AccountName(next.Address())
+	require.Equal(t, "master", name)
+	name, _ = m.AccountName(own.Address())
+	require.Equal(t, "me", name)
+	require.False(t, m.IsWallet(own.Address()))
+}
+
+func TestAliasesAndTags(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("master", kp)))
+	require.NoError(t, m.AddContact("jennifer", kp.Address(), nil))
+
+	require.NoError(t, m.AddAlias("master", "main"))
+	require.NoError(t, m.AddAlias("jennifer", "jen"))
+	require.Error(t, m.AddAlias("master", "jen"))
+	require.Error(t, m.AddAlias("jennifer", "master"))
+	require.Error(t, m.AddAlias("master", "#cold"))
+	require.Error(t, m.AddAlias("master", kp.Address()))
+	require.Error(t, m.AddAlias("unknown", "other"))
+
+	require.NoError(t, m.Tag("main", "#Cold", "savings"))
+	require.NoError(t, m.Tag("jen", "exchange"))
+	require.Error(t, m.Tag("master", "#"))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, "master", m.WalletByName("main").Name)
+	name, c, ok := m.Contact("jen")
+	require.True(t, ok)
+	require.Equal(t, "jennifer", name)
+	require.Equal(t, []string{"exchange"}, c.Tags)
+	require.Equal(t, []string{"cold", "savings"}, m.WalletByName("master").Tags)
+	require.Len(t, m.WalletsTagged("cold"), 1)
+	require.Len(t, m.WalletsTagged("#cold", "savings"), 1)
+	require.Empty(t, m.WalletsTagged("cold", "exchange"))
+	require.Len(t, m.WalletsTagged(), 1)
+
+	require.NoError(t, m.Untag("master", "#cold"))
+	require.Empty(t, m.WalletsTagged("cold"))
+	require.NoError(t, m.RemoveAlias("main"))
+	require.Nil(t, m.WalletByName("main"))
+	require.Error(t, m.RemoveAlias("main"))
+}
+
+func TestColdWallet(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+
+	_, err = NewCold("vault", kp.Seed(), "paper in the safe")
+	require.Error(t, err)
+	_, err = NewCold("vault", kp.Address(), "")
+	require.Error(t, err)
+
+	w, err := NewCold("vault", kp.Address(), "paper in the safe")
+	require.NoError(t, err)
+	require.True(t, w.IsCold())
+	require.NoError(t, m.AddWallet(w))
+	require.NoError(t, Write(path, m))
+
+	raw, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "seed")
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	got := m.WalletByName("vault")
+	require.NotNil(t, got)
+	require.Equal(t, "paper in the safe", got.Cold)
+	require.IsType(t, &keypair.FromAddress{}, got.Keypair)
+	require.Equal(t, kp.Address(), got.Keypair.Address())
+
+	b, err := m.Backup(nil)
+	require.NoError(t, err)
+	require.Equal(t, []BackupWallet{{Name: "vault", Address: kp.Address(), Cold: "paper in the safe"}}, b.Wallets)
+
+	var restored Alfred
+	_, err = restored.Restore(b, nil)
+	require.NoError(t, err)
+	require.True(t, restored.WalletByName("vault").IsCold())
+
+	hot, err := keypair.Random()
+	require.NoError(t, err)
+	w = New("savings", hot)
+	require.Error(t, w.MarkCold(""))
+	require.NoError(t, w.MarkCold("ledger"))
+	require.True(t, w.IsCold())
+	require.Equal(t, hot.Address(), w.Keypair.Address())
+	require.IsType(t, &keypair.FromAddress{}, w.Keypair)
+}
+
+func TestProtect(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+	defer os.Remove(path)
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	w := New("savings", kp)
+	require.Error(t, w.Protect(""))
+	require.NoError(t, w.Protect("correct horse"))
+	require.True(t, w.IsProtected())
+	require.IsType(t, &keypair.FromAddress{}, w.Keypair)
+	require.Error(t, w.Protect("correct horse"))
+	require.NoError(t, m.AddWallet(w))
+	require.NoError(t, Write(path, m))
+
+	// the secret of the database alone does not give the seed
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	w = m.WalletByName("savings")
+	require.True(t, w.IsProtected())
+	require.Equal(t, kp.Address(), w.Keypair.Address())
+	require.IsType(t, &keypair.FromAddress{}, w.Keypair)
+
+	_, err = w.Unwrap("wrong")
+	require.Error(t, err)
+	full, err := w.Unwrap("correct horse")
+	require.NoError(t, err)
+	require.Equal(t, kp.Seed(), full.Seed())
+
+	// the seed stays protected once the database is written again
+	require.NoError(t, Write(path, m))
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	w = m.WalletByName("savings")
+	require.True(t, w.IsProtected())
+
+	b, err := m.Backup(nil)
+	require.NoError(t, err)
+	require.Empty(t, b.Wallets[0].Seed)
+	var restored Alfred
+	_, err = restored.Restore(b, nil)
+	require.NoError(t, err)
+	full, err = restored.WalletByName("savings").Unwrap("correct horse")
+	require.NoError(t, err)
+	require.Equal(t, kp.Seed(), full.Seed())
+
+	require.Error(t, w.Unprotect("wrong"))
+	require.NoError(t, w.Unprotect("correct horse"))
+	require.False(t, w.IsProtected())
+	require.Equal(t, kp.Seed(), w.Keypair.(*keypair.Full).Seed())
+}
+
+func TestHardwareKey(t *testing.T) {
+	mem := &memoryStore{}
+	m, err := Load(mem, []byte("derived"))
+	require.NoError(t, err)
+
+	key, err := hardware.NewYubiKey(2)
+	require.NoError(t, err)
+	m.Hardware = key
+	require.NoError(t, Save(mem, m))
+
+	// the key is read without the secret, which is derived from its response
+	m, err = Load(mem, nil)
+	require.NoError(t, err)
+	require.Equal(t, key, m.Hardware)
+}
+
+func TestDecoy(t *testing.T) {
+	mem := &memoryStore{}
+	main, err := Load(mem, []byte("main"))
+	require.NoError(t, err)
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, main.AddWallet(New("savings", kp)))
+	require.NoError(t, Save(mem, main))
+
+	// a database without decoy holds a filler besides its main content, of
+	// the same size when small
+	compartments, err := storedCompartments(mem.b)
+	require.NoError(t, err)
+	require.Len(t, compartments, 2)
+	require.Equal(t, len(compartments[0]), len(compartments[1]))
+	filler := 0
+	if !isFiller(main.secret, compartments[0]) {
+		filler = 1
+	}
+	require.True(t, isFiller(main.secret, compartments[filler]))
+	filled := compartments[filler]
+
+	_, err = AddDecoy(mem, main, []byte("main"))
+	require.Error(t, err)
+	decoy, err := AddDecoy(mem, main, []byte("duress"))
+	require.NoError(t, err)
+	require.True(t, decoy.IsDecoy())
+	_, err = AddDecoy(mem, main, []byte("duress"))
+	require.Error(t, err)
+
+	// the database turns private, nothing is readable without a secret
+	require.True(t, main.IsPrivate())
+	require.NotContains(t, string(mem.b), kp.Address())
+	require.NotContains(t, string(mem.b), "savings")
+	needs, err := NeedsSecret(mem)
+	require.NoError(t, err)
+	require.True(t, needs)
+
+	// the decoy replaces the filler, of the same size when small
+	compartments, err = storedCompartments(mem.b)
+	require.NoError(t, err)
+	require.Len(t, compartments, 2)
+	require.NotEqual(t, filled, compartments[filler])
+	require.Equal(t, len(filled), len(compartments[filler]))
+
+	small, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, decoy.AddWallet(New("pocket", small)))
+	require.NoError(t, Save(mem, decoy))
+
+	decoy, err = Load(mem, []byte("duress"))
+	require.NoError(t, err)
+	require.True(t, decoy.IsDecoy())
+	require.Len(t, decoy.Stellar.Wallets, 1)
+	require.Equal(t, small.Seed(), decoy.WalletByName("pocket").Keypair.(*keypair.Full).Seed())
+
+	// the main content is untouched by the decoy, and keeps it when written
+	main, err = Load(mem, []byte("main"))
+	require.NoError(t, err)
+	require.False(t, main.IsDecoy())
+	require.Nil(t, main.WalletByName("pocket"))
+	require.Equal(t, kp.Seed(), main.WalletByName("savings").Keypair.(*keypair.Full).Seed())
+	require.NoError(t, Save(mem, main))
+
+	decoy, err = Load(mem, []byte("duress"))
+	require.NoError(t, err)
+	require.NotNil(t, decoy.WalletByName("pocket"))
+
+	_, err = Load(mem, []byte("wrong"))
+	require.Error(t, err)
+	locked, err := Load(mem, nil)
+	require.NoError(t, err)
+	require.Empty(t, locked.Stellar.Wallets)
+}
+
+func TestChangeSecret(t *testing.T) {
+	mem := &memoryStore{}
+	m, err := Load(mem, []byte("old"))
+	require.NoError(t, err)
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("master", kp)))
+	require.NoError(t, Save(mem, m))
+
+	// the main content moves to the new secret, with its filler, and
+	// nothing opens with the old one anymore
+	m.Unlock([]byte("new"))
+	require.NoError(t, Save(mem, m))
+	compartments, err := storedCompartments(mem.b)
+	require.NoError(t, err)
+	require.Len(t, compartments, 2)
+	_, err = Load(mem, []byte("old"))
+	require.Error(t, err)
+
+	m, err = Load(mem, []byte("new"))
+	require.NoError(t, err)
+	require.Equal(t, kp.Seed(), m.WalletByName("master").Keypair.(*keypair.Full).Seed())
+	require.NoError(t, Save(mem, m))
+	compartments, err = storedCompartments(mem.b)
+	require.NoError(t, err)
+	require.Len(t, compartments, 2)
+
+	// a decoy is kept when the main secret changes
+	_, err = AddDecoy(mem, m, []byte("duress"))
+	require.NoError(t, err)
+	m.Unlock([]byte("newer"))
+	require.NoError(t, Save(mem, m))
+	compartments, err = storedCompartments(mem.b)
+	require.NoError(t, err)
+	require.Len(t, compartments, 2)
+	decoy, err := Load(mem, []byte("duress"))
+	require.NoError(t, err)
+	require.True(t, decoy.IsDecoy())
+	_, err = Load(mem, []byte("new"))
+	require.Error(t, err)
+}
+
+func TestClearDatabase(t *testing.T) {
+	m := &Alfred{}
+	m.Unlock([]byte("hello"))
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("master", kp)))
+	require.NoError(t, m.AddContact("bob", kp.Address(), nil))
+
+	// a database written in clear by an older alfred, with a filler
+	f, err := filler(m.secret)
+	require.NoError(t, err)
+	b, err := yaml.Marshal(m)
+	require.NoError(t, err)
+	mem := &memoryStore{b: append(b, []byte("compartments:\n- "+f+"\n")...)}
+
+	m, err = Load(mem, []byte("hello"))
+	require.NoError(t, err)
+	require.False(t, m.IsDecoy())
+	require.Equal(t, kp.Seed(), m.WalletByName("master").Keypair.(*keypair.Full).Seed())
+
+	// it is encrypted once written, but for its public content
+	require.NoError(t, Save(mem, m))
+	require.NotContains(t, string(mem.b), kp.Seed())
+	require.NotContains(t, string(mem.b), "seed:")
+	locked, err := Load(mem, nil)
+	require.NoError(t, err)
+	require.Equal(t, kp.Address(), locked.WalletByName("master").Keypair.Address())
+	require.Equal(t, kp.Address(), locked.Stellar.Contacts["bob"].Address)
+	compartments, err := storedCompartments(mem.b)
+	require.NoError(t, err)
+	require.Len(t, compartments, 2)
+	require.Contains(t, compartments, f)
+
+	m, err = Load(mem, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, kp.Address(), m.Stellar.Contacts["bob"].Address)
+	require.NoError(t, Save(mem, m))
+	compartments, err = storedCompartments(mem.b)
+	require.NoError(t, err)
+	require.Len(t, compartments, 2, "the main content keeps its compartment")
+}
+
+// memoryStore is a store kept in memory
+type memoryStore struct{ b []byte }
+
+func (s *memoryStore) Read() ([]byte, error) { return s.b, nil }
+func (s *memoryStore) Write(b []byte) error  { s.b = b; return nil }
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "alfred.yaml")
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+	require.Empty(t, m.Stellar.Wallets)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("master", kp)))
+	require.NoError(t, Write(path, m))
+	require.NoError(t, Write(path, m))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1) // neither temporary file nor lock left
+
+	// a write waits for the one in progress
+	require.NoError(t, ioutil.WriteFile(path+".lock", nil, 0600))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.Remove(path + ".lock")
+	}()
+	require.NoError(t, Write(path, m))
+
+	m, err = Open("file://"+path, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, kp.Address(), m.WalletByName("master").Keypair.Address())
+
+	_, err = Open("sqlite://"+path, []byte("hello"))
+	require.EqualError(t, err, "unknown database scheme sqlite")
+
+	mem := &memoryStore{}
+	RegisterStore("memory", func(string) (Store, error) { return mem, nil })
+	require.NoError(t, Write("memory://", m))
+	m, err = Open("memory://", []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, kp.Address(), m.WalletByName("master").Keypair.Address())
+}
+
+func TestUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "alfred.yaml")
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	_, err = Update(path, []byte("hello"), func(m *Alfred) error {
+		return m.AddWallet(New("master", kp))
+	})
+	require.NoError(t, err)
+
+	// the writers run meanwhile do not lose the changes of each other
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := Update(path, []byte("hello"), func(m *Alfred) error {
+				return m.AddContact(fmt.Sprintf("contact%d", i), kp.Address(), nil)
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Contacts, 10)
+	require.NotNil(t, m.WalletByName("master"))
+
+	// nothing is written when fn fails, and the lock is released
+	_, err = Update(path, []byte("hello"), func(m *Alfred) error {
+		m.Stellar.Contacts = nil
+		return errors.New("failed")
+	})
+	require.EqualError(t, err, "failed")
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Contacts, 10)
+	_, err = os.Stat(path + ".lock")
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "alfred.yaml")
+
+	m, err := Open(path, []byte("hello"))
+	require.NoError(t, err)
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("master", kp)))
+	require.NoError(t, m.AddContact("bob", kp.Address(), nil))
+	require.NoError(t, Write(path, m))
+
+	version, err := StoredVersion(FileStore{Path: path})
+	require.NoError(t, err)
+	require.Equal(t, CurrentVersion, version)
+
+	// a database written before versioning
+	raw, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	unversioned := strings.Replace(string(raw), fmt.Sprintf("version: %d\n", CurrentVersion), "", 1)
+	require.NoError(t, ioutil.WriteFile(path, []byte(unversioned), 0600))
+
+	defer func(migrations []Migration, current int) {
+		Migrations, CurrentVersion = migrations, current
+	}(Migrations, CurrentVersion)
+	Migrations = append(Migrations, Migration{
+		Version:     CurrentVersion + 1,
+		Description: "rename bob to robert",
+		Migrate: func(doc map[interface{}]interface{}) error {
+			contacts := doc["stellar"].(map[interface{}]interface{})["contacts"].(map[interface{}]interface{})
+			contacts["robert"] = contacts["bob"]
+			delete(contacts, "bob")
+			return nil
+		},
+	})
+	CurrentVersion++
+
+	m, err = Open(path, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, kp.Address(), m.WalletByName("master").Keypair.Address())
+	require.Equal(t, kp.Address(), m.Stellar.Contacts["robert"].Address)
+
+	backup, err := ioutil.ReadFile(path + ".v0.bak")
+	require.NoError(t, err)
+	require.Equal(t, unversioned, string(backup))
+
+	version, err = StoredVersion(FileStore{Path: path})
+	require.NoError(t, err)
+	require.Equal(t, CurrentVersion, version)
+
+	// a database written by a newer alfred
+	CurrentVersion--
+	_, err = Open(path, []byte("hello"))
+	require.Error(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	m := &Alfred{}
+	m.Unlock([]byte("hello"))
+
+	var kps []*keypair.Full
+	for _, name := range []string{"master", "savings", "vault"} {
+		kp, err := keypair.Random()
+		require.NoError(t, err)
+		require.NoError(t, m.AddWallet(New(name, kp)))
+		kps = append(kps, kp)
+	}
+	m.Stellar.Contacts = map[string]Contact{"bob": {Address: "GBOB"}}
+
+	store := &memoryStore{}
+	require.NoError(t, Save(store, m))
+
+	checked, err := Check(store, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, 3, checked.Wallets)
+	require.Equal(t, []Problem{{Kind: "contact", Name: "bob", Address: "GBOB", Issue: "invalid address", Repair: "removed"}}, checked.Problems)
+	_, err = Check(store, []byte("wrong"))
+	require.Error(t, err)
+
+	// savings is renamed master, the seed of vault is corrupted, in a
+	// database written in clear by an older alfred
+	b, err := yaml.Marshal(m)
+	require.NoError(t, err)
+	doc := string(b)
+	doc = strings.Replace(doc, "name: savings", "name: master", 1)
+	i := strings.Index(doc, "name: vault")
+	j := i + strings.Index(doc[i:], "seed: ") + len("seed: ")
+	doc = doc[:j] + "AAAA" + doc[j+4:]
+	store.b = []byte(doc)
+
+	_, err = Load(store, []byte("hello"))
+	require.Error(t, err)
+
+	checked, err = Check(store, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, 1, checked.Undecryptable)
+	require.Len(t, checked.Problems, 3)
+	require.Equal(t, "renamed master-2", checked.Problems[0].Repair)
+	require.Equal(t, "vault", checked.Problems[1].Name)
+
+	require.NoError(t, Save(store, checked.Salvaged))
+	repaired, err := Load(store, []byte("hello"))
+	require.NoError(t, err)
+	require.Len(t, repaired.Stellar.Wallets, 2)
+	require.Equal(t, kps[1].Address(), repaired.WalletByName("master-2").Keypair.Address())
+	require.Empty(t, repaired.Stellar.Contacts)
+
+	store.b = b
+	checked, err = Check(store, []byte("wrong"))
+	require.NoError(t, err)
+	require.Equal(t, 3, checked.Undecryptable)
+}
+
+func TestPrivate(t *testing.T) {
+	mem := &memoryStore{}
+	m, err := Load(mem, []byte("hello"))
+	require.NoError(t, err)
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("master", kp)))
+	require.NoError(t, Save(mem, m))
+
+	needs, err := NeedsSecret(mem)
+	require.NoError(t, err)
+	require.False(t, needs)
+	require.Contains(t, string(mem.b), kp.Address())
+
+	// a private database keeps the names and addresses in its compartment
+	m.SetPrivate(true)
+	require.NoError(t, Save(mem, m))
+	require.NotContains(t, string(mem.b), kp.Address())
+	require.NotContains(t, string(mem.b), "master")
+	needs, err = NeedsSecret(mem)
+	require.NoError(t, err)
+	require.True(t, needs)
+	locked, err := Load(mem, nil)
+	require.NoError(t, err)
+	require.Empty(t, locked.Stellar.Wallets)
+
+	m, err = Load(mem, []byte("hello"))
+	require.NoError(t, err)
+	require.True(t, m.IsPrivate())
+	m.SetPrivate(false)
+	require.NoError(t, Save(mem, m))
+	locked, err = Load(mem, nil)
+	require.NoError(t, err)
+	require.Equal(t, kp.Address(), locked.WalletByName("master").Keypair.Address())
+
+	// with a decoy, it stays private
+	_, err = AddDecoy(mem, m, []byte("duress"))
+	require.NoError(t, err)
+	m, err = Load(mem, []byte("hello"))
+	require.NoError(t, err)
+	m.SetPrivate(false)
+	require.NoError(t, Save(mem, m))
+	require.True(t, m.IsPrivate())
+	require.NotContains(t, string(mem.b), kp.Address())
+}
@@ -0,0 +1,483 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// BackupVersion is the version of the backups written by EncodeBackup
+	BackupVersion = 1
+
+	backupFormat = "alfred-backup"
+	backupKDF    = "pbkdf2-sha256"
+	// backupIterations is the number of iterations deriving the key of a backup from its password
+	backupIterations = 100000
+)
+
+var (
+	// ErrNotBackup is returned when decoding a file which is not a backup
+	ErrNotBackup = errors.New("not an alfred backup")
+	// ErrBackupCorrupted is returned when the content of a backup was modified
+	ErrBackupCorrupted = errors.New("the backup is corrupted")
+	// ErrBackupPassword is returned when a backup can not be decrypted with a password
+	ErrBackupPassword = errors.New("unable to decrypt the backup, the password is probably incorrect")
+)
+
+// Backup is the content of a backup: the wallets with their seeds, the
+// contacts and the settings. The log, auth tokens and KYC answers are not included.
+type Backup struct {
+	Created  time.Time              `yaml:"created"`
+	Wallets  []BackupWallet         `yaml:"wallets,omitempty"`
+	Contacts map[string]Contact     `yaml:"contacts,omitempty"`
+	Settings map[string]interface{} `yaml:"settings,omitempty"`
+}
+
+// BackupWallet is a wallet in a backup, a cold wallet has an address instead
+// of a seed, and a wallet with a passphrase its seed encrypted with it
+type BackupWallet struct {
+	Name      string   `yaml:"name"`
+	Seed      string   `yaml:"seed,omitempty"`
+	Address   string   `yaml:"address,omitempty"`
+	Cold      string   `yaml:"cold,omitempty"`
+	Protected string   `yaml:"protected,omitempty"`
+	Policy    Policy   `yaml:"policy,omitempty"`
+	Aliases   []string `yaml:"aliases,omitempty"`
+	Tags      []string `yaml:"tags,omitempty"`
+}
+
+// backupArchive is the file of a backup, the backup is encrypted in Data
+type backupArchive struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	// Checksum is the SHA-256 of Data, it tells a corrupted backup from a wrong password
+	Checksum string `json:"checksum"`
+	Data     []byte `json:"data"`
+}
+
+// header is authenticated along with the encrypted backup
+func (a backupArchive) header() []byte {
+	return []byte(fmt.Sprintf("%s:%d:%s:%d:%x", a.Format, a.Version, a.KDF, a.Iterations, a.Salt))
+}
+
+// Backup returns the wallets and contacts of m with settings, m should be unlocked
+func (m *Alfred) Backup(settings map[string]interface{}) (*Backup, error) {
+	b := &Backup{
+		Created:  time.Now().UTC(),
+		Contacts: m.Stellar.Contacts,
+		Settings: settings,
+	}
+
+	for _, w := range m.Stellar.Wallets {
+		if w.IsCold() {
+			b.Wallets = append(b.Wallets, BackupWallet{Name: w.Name, Address: w.Keypair.Address(), Cold: w.Cold, Policy: w.Policy, Aliases: w.Aliases, Tags: w.Tags})
+			continue
+		}
+		if w.IsProtected() {
+			b.Wallets = append(b.Wallets, BackupWallet{Name: w.Name, Address: w.Keypair.Address(), Protected: base64.StdEncoding.EncodeToString(w.wrapped), Policy: w.Policy, Aliases: w.Aliases, Tags: w.Tags})
+			continue
+		}
+
+		kp, ok := w.Keypair.(*keypair.Full)
+		if !ok {
+			return nil, errors.New("you should unlock alfred for a backup")
+		}
+
+		b.Wallets = append(b.Wallets, BackupWallet{Name: w.Name, Seed: kp.Seed(), Policy: w.Policy, Aliases: w.Aliases, Tags: w.Tags})
+	}
+
+	return b, nil
+}
+
+// EncodeBackup writes b to w, encrypted with a key derived from password
+func EncodeBackup(w io.Writer, b *Backup, password []byte) error {
+	plaintext, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	archive := backupArchive{
+		Format:     backupFormat,
+		Version:    BackupVersion,
+		KDF:        backupKDF,
+		Iterations: backupIterations,
+		Salt:       make([]byte, 16),
+	}
+	if _, err := io.ReadFull(rand.Reader, archive.Salt); err != nil {
+		return err
+	}
+
+	gcm, err := backupCipher(password, archive)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	archive.Data = gcm.Seal(nonce, nonce, plaintext, archive.header())
+
+	sum := sha256.Sum256(archive.Data)
+	archive.Checksum = hex.EncodeToString(sum[:])
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(archive)
+}
+
+// DecodeBackup reads a backup written by EncodeBackup from r
+func DecodeBackup(r io.Reader, password []byte) (*Backup, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var archive backupArchive
+	if err := json.Unmarshal(raw, &archive); err != nil || archive.Format != backupFormat {
+		return nil, ErrNotBackup
+	}
+
+	if archive.Version > BackupVersion {
+		return nil, fmt.Errorf("backup version %d is not supported, upgrade alfred to import it", archive.Version)
+	}
+	if archive.KDF != backupKDF || archive.Iterations <= 0 {
+		return nil, fmt.Errorf("unsupported key derivation '%s'", archive.KDF)
+	}
+
+	sum := sha256.Sum256(archive.Data)
+	if hex.EncodeToString(sum[:]) != archive.Checksum {
+		return nil, ErrBackupCorrupted
+	}
+
+	gcm, err := backupCipher(password, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	size := gcm.NonceSize()
+	if len(archive.Data) < size {
+		return nil, ErrBackupCorrupted
+	}
+	plaintext, err := gcm.Open(nil, archive.Data[:size], archive.Data[size:], archive.header())
+	if err != nil {
+		return nil, ErrBackupPassword
+	}
+
+	var b Backup
+	if err := yaml.Unmarshal(plaintext, &b); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func backupCipher(password []byte, archive backupArchive) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2(password, archive.Salt, archive.Iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 derives a key from password with PBKDF2-HMAC-SHA256 (RFC 8018)
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+		u := prf.Sum(nil)
+
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
+
+// Resolution is how a conflict between a backup and the database is resolved
+type Resolution int
+
+const (
+	// Skip keeps the entry of the database
+	Skip Resolution = iota
+	// Rename imports the entry of the backup under another name
+	Rename
+	// Overwrite replaces the entry of the database by the one of the backup
+	Overwrite
+)
+
+var resolutionNames = [...]string{Skip: "skip", Rename: "rename", Overwrite: "overwrite"}
+
+// ParseResolution returns the resolution named s: skip, rename or overwrite
+func ParseResolution(s string) (Resolution, error) {
+	for r, name := range resolutionNames {
+		if name == s {
+			return Resolution(r), nil
+		}
+	}
+	return Skip, fmt.Errorf("unknown resolution '%s', should be skip, rename or overwrite", s)
+}
+
+func (r Resolution) String() string {
+	if r < 0 || int(r) >= len(resolutionNames) {
+		return fmt.Sprintf("Resolution(%d)", int(r))
+	}
+	return resolutionNames[r]
+}
+
+// Conflict is an entry of a backup which differs from the one of the database
+// with the same name or address
+type Conflict struct {
+	// Kind is either wallet or contact
+	Kind string
+	Name string
+	// Existing and Incoming describe both entries
+	Existing, Incoming string
+}
+
+// Resolver decides how a conflict is resolved
+type Resolver func(c Conflict) (Resolution, error)
+
+// Restored is the outcome of the import of an entry of a backup
+type Restored struct {
+	Kind string
+	// Name is the name of the entry in the database
+	Name   string
+	Action string
+}
+
+// Restore imports the wallets and contacts of b into m, resolve is called for each conflict.
+// Renaming a wallet which is already in the database is skipping it, as an address
+// can only be imported once.
+func (m *Alfred) Restore(b *Backup, resolve Resolver) ([]Restored, error) {
+	defer m.ForgetNames()
+
+	var restored []Restored
+	for _, bw := range b.Wallets {
+		r, err := m.restoreWallet(bw, resolve)
+		if err != nil {
+			return restored, err
+		}
+		restored = append(restored, r)
+	}
+
+	var names []string
+	for name := range b.Contacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		r, err := m.restoreContact(name, b.Contacts[name], resolve)
+		if err != nil {
+			return restored, err
+		}
+		restored = append(restored, r)
+	}
+
+	return restored, nil
+}
+
+func (m *Alfred) restoreWallet(bw BackupWallet, resolve Resolver) (Restored, error) {
+	r := Restored{Kind: "wallet", Name: bw.Name}
+
+	w, err := bw.wallet()
+	if err != nil {
+		return r, err
+	}
+	kp := w.Keypair
+	// the aliases taken by other wallets or contacts are dropped
+	w.Aliases = m.freeAliases(w.Aliases)
+
+	if existing := m.WalletByAddress(kp.Address()); existing != nil {
+		if existing.Name == bw.Name && existing.Policy == bw.Policy && existing.Cold == bw.Cold {
+			r.Action = "unchanged"
+			return r, nil
+		}
+
+		res, err := resolve(Conflict{
+			Kind:     r.Kind,
+			Name:     bw.Name,
+			Existing: fmt.Sprintf("%s, %s", existing, existing.Policy),
+			Incoming: fmt.Sprintf("%s (%s), %s", bw.Name, TrimAddress(kp.Address()), bw.Policy),
+		})
+		if err != nil {
+			return r, err
+		}
+
+		if res != Overwrite || (bw.Name != existing.Name && m.WalletByName(bw.Name) != nil) {
+			r.Name, r.Action = existing.Name, "skipped, already in the database"
+			return r, nil
+		}
+
+		existing.Name, existing.Policy = bw.Name, bw.Policy
+		existing.Aliases, existing.Tags = append(existing.Aliases, w.Aliases...), w.Tags
+		if !w.IsCold() {
+			existing.Keypair, existing.Cold, existing.wrapped = kp, "", w.wrapped
+		}
+		r.Action = "overwritten"
+		return r, nil
+	}
+
+	if existing := m.WalletByName(bw.Name); existing != nil {
+		res, err := resolve(Conflict{
+			Kind:     r.Kind,
+			Name:     bw.Name,
+			Existing: existing.String(),
+			Incoming: fmt.Sprintf("%s (%s)", bw.Name, TrimAddress(kp.Address())),
+		})
+		if err != nil {
+			return r, err
+		}
+
+		switch res {
+		case Skip:
+			r.Action = "skipped"
+			return r, nil
+		case Rename:
+			w.Name = freeName(bw.Name, func(name string) bool { return m.WalletByName(name) != nil })
+			r.Name, r.Action = w.Name, "renamed from "+bw.Name
+			return r, m.AddWallet(w)
+		case Overwrite:
+			existing.Keypair, existing.Policy, existing.Cold, existing.wrapped = kp, bw.Policy, bw.Cold, w.wrapped
+			existing.Aliases, existing.Tags = append(existing.Aliases, w.Aliases...), w.Tags
+			r.Action = "overwritten"
+			return r, nil
+		}
+	}
+
+	r.Action = "added"
+	return r, m.AddWallet(w)
+}
+
+// wallet returns the wallet of bw
+func (bw BackupWallet) wallet() (*Wallet, error) {
+	if bw.Cold != "" {
+		w, err := NewCold(bw.Name, bw.Address, bw.Cold)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cold wallet '%s': %v", bw.Name, err)
+		}
+		w.Policy, w.Aliases, w.Tags = bw.Policy, bw.Aliases, bw.Tags
+		return w, nil
+	}
+
+	if bw.Protected != "" {
+		address, err := keypair.Parse(bw.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address for wallet '%s': %v", bw.Name, err)
+		}
+		wrapped, err := base64.StdEncoding.DecodeString(bw.Protected)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed for wallet '%s': %v", bw.Name, err)
+		}
+		return &Wallet{Name: bw.Name, Keypair: address, Policy: bw.Policy, Aliases: bw.Aliases, Tags: bw.Tags, wrapped: wrapped}, nil
+	}
+
+	kp, err := keypair.Parse(bw.Seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seed for wallet '%s': %v", bw.Name, err)
+	}
+	full, ok := kp.(*keypair.Full)
+	if !ok {
+		return nil, fmt.Errorf("no seed for wallet '%s'", bw.Name)
+	}
+
+	return &Wallet{Name: bw.Name, Keypair: full, Policy: bw.Policy, Aliases: bw.Aliases, Tags: bw.Tags}, nil
+}
+
+func (m *Alfred) restoreContact(name string, c Contact, resolve Resolver) (Restored, error) {
+	r := Restored{Kind: "contact", Name: name}
+
+	existing, ok := m.Stellar.Contacts[name]
+	// the aliases taken by other wallets or contacts are dropped
+	var aliases []string
+	for _, alias := range c.Aliases {
+		if contains(existing.Aliases, alias) || len(m.freeAliases([]string{alias})) > 0 {
+			aliases = append(aliases, alias)
+		}
+	}
+	c.Aliases = aliases
+
+	switch {
+	case !ok:
+		r.Action = "added"
+		if err := m.AddContact(name, c.Address, c.Memo); err != nil {
+			return r, err
+		}
+		added := m.Stellar.Contacts[name]
+		added.Aliases, added.Tags = c.Aliases, c.Tags
+		m.Stellar.Contacts[name] = added
+		return r, nil
+	case sameContact(existing, c):
+		r.Action = "unchanged"
+		return r, nil
+	}
+
+	res, err := resolve(Conflict{Kind: r.Kind, Name: name, Existing: existing.Address, Incoming: c.Address})
+	if err != nil {
+		return r, err
+	}
+
+	switch res {
+	case Rename:
+		r.Name = freeName(name, func(name string) bool { _, ok := m.Stellar.Contacts[name]; return ok })
+		r.Action = "renamed from " + name
+		c.Aliases = m.freeAliases(c.Aliases)
+	case Overwrite:
+		r.Action = "overwritten"
+	default:
+		r.Action = "skipped"
+		return r, nil
+	}
+
+	m.Stellar.Contacts[r.Name] = c
+	return r, nil
+}
+
+func sameContact(a, b Contact) bool {
+	if a.Address != b.Address || (a.Memo == nil) != (b.Memo == nil) {
+		return false
+	}
+	return a.Memo == nil || *a.Memo == *b.Memo
+}
+
+// freeName returns name followed by the first number for which taken is false
+func freeName(name string, taken func(string) bool) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
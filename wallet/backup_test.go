@@ -0,0 +1,161 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// test vectors of RFC 7914
+	require.Equal(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783",
+		hex.EncodeToString(pbkdf2([]byte("passwd"), []byte("salt"), 1, 64)))
+	require.Equal(t, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b",
+		hex.EncodeToString(pbkdf2([]byte("password"), []byte("salt"), 1, 32)))
+}
+
+func TestBackup(t *testing.T) {
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+
+	var m Alfred
+	w := New("master", kp)
+	w.Policy.MaxPerDay = 100
+	require.NoError(t, m.AddWallet(w))
+	require.NoError(t, m.AddContact("jennifer", kp.Address(), &Memo{Type: MEMO_ID, Value: MemoValue{IntValue: 42}}))
+
+	b, err := m.Backup(map[string]interface{}{"memo-guard": "warn"})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeBackup(&buf, b, []byte("password")))
+	require.NotContains(t, buf.String(), kp.Seed())
+
+	got, err := DecodeBackup(bytes.NewReader(buf.Bytes()), []byte("password"))
+	require.NoError(t, err)
+	require.Equal(t, kp.Seed(), got.Wallets[0].Seed)
+	require.Equal(t, 100.0, got.Wallets[0].Policy.MaxPerDay)
+	require.Equal(t, uint64(42), got.Contacts["jennifer"].Memo.Value.IntValue)
+	require.Equal(t, "warn", got.Settings["memo-guard"])
+
+	_, err = DecodeBackup(bytes.NewReader(buf.Bytes()), []byte("wrong"))
+	require.Equal(t, ErrBackupPassword, err)
+
+	_, err = DecodeBackup(bytes.NewBufferString("stellar:\n  wallets: []\n"), []byte("password"))
+	require.Equal(t, ErrNotBackup, err)
+
+	var archive backupArchive
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &archive))
+
+	corrupted := archive
+	corrupted.Data = append([]byte(nil), archive.Data...)
+	corrupted.Data[len(corrupted.Data)-1] ^= 1
+	raw, err := json.Marshal(corrupted)
+	require.NoError(t, err)
+	_, err = DecodeBackup(bytes.NewReader(raw), []byte("password"))
+	require.Equal(t, ErrBackupCorrupted, err)
+
+	// the header is authenticated
+	tampered := archive
+	tampered.Iterations = 1
+	raw, err = json.Marshal(tampered)
+	require.NoError(t, err)
+	_, err = DecodeBackup(bytes.NewReader(raw), []byte("password"))
+	require.Error(t, err)
+
+	newer := archive
+	newer.Version = BackupVersion + 1
+	raw, err = json.Marshal(newer)
+	require.NoError(t, err)
+	_, err = DecodeBackup(bytes.NewReader(raw), []byte("password"))
+	require.EqualError(t, err, "backup version 2 is not supported, upgrade alfred to import it")
+}
+
+func TestRestore(t *testing.T) {
+	master, err := keypair.Random()
+	require.NoError(t, err)
+	savings, err := keypair.Random()
+	require.NoError(t, err)
+	other, err := keypair.Random()
+	require.NoError(t, err)
+
+	b := &Backup{
+		Wallets: []BackupWallet{
+			{Name: "master", Seed: master.Seed()},
+			{Name: "savings", Seed: savings.Seed()},
+		},
+		Contacts: map[string]Contact{
+			"alice": {Address: master.Address()},
+			"bob":   {Address: savings.Address()},
+		},
+	}
+
+	newDB := func() *Alfred {
+		var m Alfred
+		require.NoError(t, m.AddWallet(New("master", master)))
+		require.NoError(t, m.AddWallet(New("savings", other)))
+		require.NoError(t, m.AddContact("alice", master.Address(), nil))
+		require.NoError(t, m.AddContact("bob", other.Address(), nil))
+		return &m
+	}
+
+	var conflicts []Conflict
+	resolver := func(r Resolution) Resolver {
+		conflicts = nil
+		return func(c Conflict) (Resolution, error) {
+			conflicts = append(conflicts, c)
+			return r, nil
+		}
+	}
+
+	m := newDB()
+	restored, err := m.Restore(b, resolver(Skip))
+	require.NoError(t, err)
+	require.Equal(t, []Restored{
+		{Kind: "wallet", Name: "master", Action: "unchanged"},
+		{Kind: "wallet", Name: "savings", Action: "skipped"},
+		{Kind: "contact", Name: "alice", Action: "unchanged"},
+		{Kind: "contact", Name: "bob", Action: "skipped"},
+	}, restored)
+	require.Len(t, conflicts, 2)
+	require.Equal(t, "wallet", conflicts[0].Kind)
+	require.Equal(t, other.Address(), m.WalletByName("savings").Keypair.Address())
+
+	m = newDB()
+	restored, err = m.Restore(b, resolver(Rename))
+	require.NoError(t, err)
+	require.Equal(t, "savings-2", restored[1].Name)
+	require.Equal(t, savings.Address(), m.WalletByName("savings-2").Keypair.Address())
+	require.Equal(t, other.Address(), m.WalletByName("savings").Keypair.Address())
+	require.Equal(t, savings.Address(), m.Stellar.Contacts["bob-2"].Address)
+
+	m = newDB()
+	restored, err = m.Restore(b, resolver(Overwrite))
+	require.NoError(t, err)
+	require.Equal(t, "overwritten", restored[1].Action)
+	require.Len(t, m.Stellar.Wallets, 2)
+	require.Equal(t, savings.Address(), m.WalletByName("savings").Keypair.Address())
+	require.Equal(t, savings.Address(), m.Stellar.Contacts["bob"].Address)
+
+	// a wallet already in the database under another name
+	m = newDB()
+	b.Wallets[0].Name = "main"
+	restored, err = m.Restore(b, resolver(Rename))
+	require.NoError(t, err)
+	require.Equal(t, Restored{Kind: "wallet", Name: "master", Action: "skipped, already in the database"}, restored[0])
+	restored, err = m.Restore(b, resolver(Overwrite))
+	require.NoError(t, err)
+	require.Equal(t, Restored{Kind: "wallet", Name: "main", Action: "overwritten"}, restored[0])
+	require.Nil(t, m.WalletByName("master"))
+
+	_, err = ParseResolution("merge")
+	require.Error(t, err)
+	r, err := ParseResolution("rename")
+	require.NoError(t, err)
+	require.Equal(t, Rename, r)
+	require.Equal(t, "rename", r.String())
+}
@@ -0,0 +1,408 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// compartmentBlock is the size the content of the compartments is padded to a
+// multiple of, so that a small decoy can not be told from the filler
+const compartmentBlock = 4096
+
+// A database holds its content in compartments, each encrypted with its own
+// secret: the main content, and the other databases such as a decoy opened
+// with a duress passphrase. Every database holds at least one compartment
+// besides the main one, a filler encrypted with the main secret when it has
+// no decoy, so that one can not tell which compartment is the main one
+// without its secret. The hardware key, the version of the schema and,
+// unless the database is private, its public content are stored in clear:
+// the names and addresses of the wallets, read without the secret. A
+// database with a decoy is always private, the public content would show
+// the main wallets to whoever was given the duress passphrase.
+
+// errWrongSecret is returned when no compartment of the database opens with
+// the secret
+var errWrongSecret = errors.New("the secret does not open the database, it may be incorrect")
+
+// IsDecoy reports whether m was opened from a compartment of the database
+// other than the one of its main content
+func (m *Alfred) IsDecoy() bool { return m.decoy }
+
+// IsPrivate reports whether the names and addresses of the wallets of m are
+// only stored in its compartment, so that they can not be read without the
+// secret
+func (m *Alfred) IsPrivate() bool { return m.private }
+
+// SetPrivate sets whether m is private, see IsPrivate. It stays private
+// when saved if the database holds a compartment its secret does not open,
+// such as a decoy.
+func (m *Alfred) SetPrivate(private bool) { m.private = private }
+
+// AddDecoy adds a compartment to the database of s, opened with secret, and
+// returns it empty. main is the main content of the database, whose filler
+// compartment the decoy replaces.
+func AddDecoy(s Store, main *Alfred, secret []byte) (*Alfred, error) {
+	if main.IsDecoy() || main.secret == nil {
+		return nil, errors.New("a decoy is added with the main secret of the database")
+	}
+
+	// the main content takes its compartment first, if stored in clear, and
+	// its public content is removed
+	main.private = true
+	if err := Save(s, main); err != nil {
+		return nil, err
+	}
+	b, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+	if m, err := openCompartment(b, secret); err == nil && m != nil {
+		return nil, errors.New("the passphrase already opens a decoy")
+	}
+
+	decoy := &Alfred{decoy: true, private: true}
+	decoy.Unlock(secret)
+	if string(decoy.secret) == string(main.secret) {
+		return nil, errors.New("the passphrase of the decoy should not be the secret of the database")
+	}
+
+	compartments, err := storedCompartments(b)
+	if err != nil {
+		return nil, err
+	}
+	decoy.compartment = len(compartments) + 1
+	for i, c := range compartments {
+		if isFiller(main.secret, c) {
+			decoy.compartment = i + 1
+			break
+		}
+	}
+
+	if err := Save(s, decoy); err != nil {
+		return nil, err
+	}
+	return decoy, nil
+}
+
+// openCompartment returns the content of b, the database, which secret
+// decrypts, nil if there is none
+func openCompartment(b []byte, secret []byte) (*Alfred, error) {
+	var a Alfred
+	a.Unlock(secret)
+	content, i, err := decryptContent(b, a.secret)
+	if err != nil || content == nil {
+		return nil, err
+	}
+
+	version, err := storedVersion(content)
+	if err != nil {
+		return nil, err
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("the database was written by a newer alfred (schema version %d, this one knows up to %d), upgrade alfred", version, CurrentVersion)
+	}
+	if version < CurrentVersion {
+		if content, err = upgrade(content, version); err != nil {
+			return nil, err
+		}
+	}
+
+	var aj alfredyaml
+	if err := yaml.Unmarshal(content, &aj); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, &a); err != nil {
+		return nil, err
+	}
+	a.compartment, a.decoy, a.stored = i, !aj.Main, a.secret
+
+	// the secret of a decoy is derived from the same hardware key
+	var outer alfredyaml
+	if err := yaml.Unmarshal(b, &outer); err != nil {
+		return nil, err
+	}
+	a.Hardware = outer.Hardware
+	return &a, nil
+}
+
+// decryptContent returns the content of the compartment of b, the database,
+// which secret decrypts, with its index from 1. It returns no content if
+// secret only decrypts a filler, or nothing.
+func decryptContent(b []byte, secret []byte) ([]byte, int, error) {
+	compartments, err := storedCompartments(b)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i, c := range compartments {
+		content, err := decryptCompartment(secret, c)
+		if err == nil && content != nil {
+			return content, i + 1, nil
+		}
+	}
+
+	return nil, 0, nil
+}
+
+// checkOpens returns errWrongSecret when b, the database, is encrypted and
+// secret decrypts none of its compartments, not even a filler
+func checkOpens(b []byte, secret []byte) error {
+	compartments, err := storedCompartments(b)
+	if err != nil || len(compartments) == 0 || storedClear(b) {
+		return err
+	}
+
+	for _, c := range compartments {
+		if _, err := decryptCompartment(secret, c); err == nil {
+			return nil
+		}
+	}
+	return errWrongSecret
+}
+
+// NeedsSecret reports whether the wallets of the database of s can only be
+// read with its secret, which is the case once it is private, see IsPrivate
+func NeedsSecret(s Store) (bool, error) {
+	b, err := s.Read()
+	if err != nil || len(b) == 0 || storedClear(b) {
+		return false, err
+	}
+
+	var doc struct {
+		Public       yaml.MapSlice `yaml:"public"`
+		Compartments []string      `yaml:"compartments"`
+	}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return false, err
+	}
+	return len(doc.Compartments) > 0 && doc.Public == nil, nil
+}
+
+// storedClear reports whether b, the database, holds its main content in
+// clear, as written before it was stored in a compartment
+func storedClear(b []byte) bool {
+	var doc struct {
+		Stellar yaml.MapSlice `yaml:"stellar"`
+	}
+	return yaml.Unmarshal(b, &doc) == nil && len(doc.Stellar) > 0
+}
+
+// saveCompartment replaces the compartment of m in b, the database, and
+// returns the database. The main content is stored in the compartment its
+// secret was stored with decrypts, or else at a random place among the
+// others, with a filler if it would be alone.
+func saveCompartment(b []byte, m *Alfred) ([]byte, error) {
+	if !m.private {
+		other, err := holdsOther(b, m.secret, m.stored)
+		if err != nil {
+			return nil, err
+		}
+		m.private = other
+	}
+
+	content, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptCompartment(m.secret, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	compartments, err := storedCompartments(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.IsDecoy() {
+		i := m.compartment - 1
+		if i < len(compartments) {
+			compartments[i] = encrypted
+		} else {
+			compartments = append(compartments, encrypted)
+		}
+	} else {
+		// the main content is found with the secret it was stored with, and
+		// the filler of that secret is replaced when the secret changed
+		stored := m.stored
+		if stored == nil {
+			stored = m.secret
+		}
+		_, i, err := decryptContent(b, stored)
+		if err != nil {
+			return nil, err
+		}
+		if string(stored) != string(m.secret) {
+			for j, c := range compartments {
+				if !isFiller(stored, c) {
+					continue
+				}
+				if compartments[j], err = filler(m.secret); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if i > 0 {
+			compartments[i-1] = encrypted
+		} else {
+			if len(compartments) == 0 {
+				f, err := filler(m.secret)
+				if err != nil {
+					return nil, err
+				}
+				compartments = []string{f}
+			}
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(compartments)+1)))
+			if err != nil {
+				return nil, err
+			}
+			i := int(n.Int64())
+			compartments = append(compartments[:i], append([]string{encrypted}, compartments[i:]...)...)
+		}
+
+		// the main content, stored in clear before, is replaced by its
+		// compartment and its public content
+		doc = yaml.MapSlice{{Key: "version", Value: CurrentVersion}}
+		if m.Hardware != nil {
+			doc = append(doc, yaml.MapItem{Key: "hardware", Value: m.Hardware})
+		}
+		if !m.private {
+			public, err := publicContent(m)
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, yaml.MapItem{Key: "public", Value: public})
+		}
+	}
+
+	found := false
+	for j := range doc {
+		if doc[j].Key == "compartments" {
+			doc[j].Value, found = compartments, true
+		}
+	}
+	if !found {
+		doc = append(doc, yaml.MapItem{Key: "compartments", Value: compartments})
+	}
+
+	return yaml.Marshal(doc)
+}
+
+// publicContent returns the content of m read without the secret: the
+// wallets without their seeds, and the other entries but the answers to KYC
+func publicContent(m *Alfred) (*alfredyaml, error) {
+	v, err := m.MarshalYAML()
+	if err != nil {
+		return nil, err
+	}
+
+	j := v.(alfredyaml)
+	j.Main, j.Private, j.Stellar.KYC = false, false, nil
+	for _, ws := range [][]walletyaml{j.Stellar.Wallets, j.Stellar.Archived} {
+		for i := range ws {
+			ws[i].Seed = ""
+		}
+	}
+	return &j, nil
+}
+
+// holdsOther reports whether b, the database, holds a compartment none of
+// secrets opens, such as a decoy
+func holdsOther(b []byte, secrets ...[]byte) (bool, error) {
+	compartments, err := storedCompartments(b)
+	if err != nil {
+		return false, err
+	}
+
+	for _, c := range compartments {
+		opened := false
+		for _, secret := range secrets {
+			if secret == nil {
+				continue
+			}
+			if _, err := decryptCompartment(secret, c); err == nil {
+				opened = true
+				break
+			}
+		}
+		if !opened {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func storedCompartments(b []byte) ([]string, error) {
+	var doc struct {
+		Compartments []string `yaml:"compartments"`
+	}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc.Compartments, nil
+}
+
+// filler returns a compartment without content, encrypted with secret
+func filler(secret []byte) (string, error) {
+	return encryptCompartment(secret, nil)
+}
+
+// isFiller reports whether c is the filler of the database with secret
+func isFiller(secret []byte, c string) bool {
+	content, err := decryptCompartment(secret, c)
+	return err == nil && content == nil
+}
+
+// encryptCompartment pads content, prefixed by its length, to a multiple of
+// compartmentBlock and encrypts it
+func encryptCompartment(secret, content []byte) (string, error) {
+	size := 4 + len(content)
+	padded := make([]byte, (size/compartmentBlock+1)*compartmentBlock)
+	binary.BigEndian.PutUint32(padded, uint32(len(content)))
+	copy(padded[4:], content)
+	if _, err := io.ReadFull(rand.Reader, padded[size:]); err != nil {
+		return "", err
+	}
+
+	encrypted, err := encrypt(secret, padded)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(encrypted), nil
+}
+
+// decryptCompartment returns the content of c, nil for a filler
+func decryptCompartment(secret []byte, c string) ([]byte, error) {
+	decoded, err := base64.RawStdEncoding.DecodeString(c)
+	if err != nil {
+		return nil, err
+	}
+	padded, err := decrypt(secret, decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(padded) < 4 {
+		return nil, errors.New("the compartment is corrupted")
+	}
+	size := binary.BigEndian.Uint32(padded)
+	if int(size) > len(padded)-4 {
+		return nil, errors.New("the compartment is corrupted")
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	return padded[4 : 4+size], nil
+}
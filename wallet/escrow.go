@@ -0,0 +1,51 @@
+package wallet
+
+import (
+	"fmt"
+	"time"
+)
+
+// Escrow is an escrow account created by alfred escrow create. Its own key
+// is stored as a wallet, and the destination is an additional signer: both
+// are needed to move the funds until one of the pre-signed transactions is
+// submitted.
+type Escrow struct {
+	ID      int    `yaml:"id"`
+	Network string `yaml:"network"`
+	// Wallet funded the escrow, Account is the escrow account
+	Wallet      string `yaml:"wallet"`
+	Account     string `yaml:"account"`
+	Destination string `yaml:"destination"`
+	Amount      string `yaml:"amount"`
+	// Unlock gives the control of the account to the destination after
+	// UnlockAfter, Recovery gives it back to the wallet after RecoverAfter.
+	// They share a sequence number so only one of them can be submitted.
+	UnlockAfter  time.Time `yaml:"unlock_after"`
+	RecoverAfter time.Time `yaml:"recover_after"`
+	Unlock       string    `yaml:"unlock"`
+	Recovery     string    `yaml:"recovery"`
+}
+
+// AddEscrow stores e with a new id, which is returned
+func (m *Alfred) AddEscrow(e Escrow) int {
+	e.ID = 1
+	for _, existing := range m.Stellar.Escrows {
+		if existing.ID >= e.ID {
+			e.ID = existing.ID + 1
+		}
+	}
+
+	m.Stellar.Escrows = append(m.Stellar.Escrows, e)
+	return e.ID
+}
+
+// Escrow returns the escrow with id
+func (m *Alfred) Escrow(id int) (*Escrow, error) {
+	for i := range m.Stellar.Escrows {
+		if m.Stellar.Escrows[i].ID == id {
+			return &m.Stellar.Escrows[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("escrow %d not found", id)
+}
@@ -0,0 +1,174 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/stellar/go/keypair"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Problem is an entry of the database which can not be read, or conflicts
+// with another one
+type Problem struct {
+	// Kind is wallet, archived, kyc or contact
+	Kind    string
+	Name    string
+	Address string
+	Issue   string
+	// Repair is what repairing the database does to the entry
+	Repair string
+}
+
+// Checked is the result of the check of a database
+type Checked struct {
+	Problems []Problem
+	// Wallets is the number of wallets and archived keys checked, Undecryptable
+	// the number of them whose seed could not be decrypted
+	Wallets, Undecryptable int
+	// Salvaged holds the entries without problem, and the conflicting ones
+	// repaired. It can be written as is to repair the database.
+	Salvaged *Alfred
+}
+
+// Check decodes the database of s entry by entry with secret, without
+// migrating nor writing it. The content checked is the one of the
+// compartment secret opens, the public content without a secret, or the
+// main content of a database written in clear by an older alfred. Unlike Load, it does not stop at the first
+// entry which can not be read.
+func Check(s Store, secret []byte) (*Checked, error) {
+	b, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var outer alfredyaml
+	if err := yaml.Unmarshal(b, &outer); err != nil {
+		return nil, fmt.Errorf("the database is not valid YAML, nothing can be salvaged automatically: %v", err)
+	}
+
+	salvaged := &Alfred{}
+	salvaged.Unlock(secret)
+	aj := outer
+	if outer.Public != nil {
+		aj = *outer.Public
+	}
+	if secret != nil && !storedClear(b) {
+		aj = alfredyaml{}
+		content, i, err := decryptContent(b, salvaged.secret)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			if err := checkOpens(b, salvaged.secret); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := yaml.Unmarshal(content, &aj); err != nil {
+				return nil, fmt.Errorf("the content of the database is not valid YAML, nothing can be salvaged automatically: %v", err)
+			}
+			salvaged.compartment, salvaged.decoy = i, !aj.Main
+			salvaged.private = aj.Private
+		}
+	}
+	salvaged.setUnencrypted(aj)
+	salvaged.Hardware = outer.Hardware
+	c := &Checked{Salvaged: salvaged}
+
+	names, addresses := map[string]bool{}, map[string]bool{}
+	check := func(kind string, js []walletyaml) []*Wallet {
+		var ws []*Wallet
+		for _, j := range js {
+			c.Wallets++
+			w, issue := salvaged.checkWallet(j)
+			if w == nil {
+				if issue == issueUndecryptable {
+					c.Undecryptable++
+				}
+				c.Problems = append(c.Problems, Problem{Kind: kind, Name: j.Name, Address: j.Address, Issue: issue, Repair: "removed, a copy of the database is kept"})
+				continue
+			}
+
+			address := w.Keypair.Address()
+			if addresses[address] {
+				c.Problems = append(c.Problems, Problem{Kind: kind, Name: j.Name, Address: address, Issue: "the address is stored twice", Repair: "the copy is removed"})
+				continue
+			}
+			addresses[address] = true
+
+			if kind == "wallet" {
+				if w.Name == "" {
+					w.Name = TrimAddress(address)
+					c.Problems = append(c.Problems, Problem{Kind: kind, Address: address, Issue: "the wallet has no name", Repair: "named " + w.Name})
+				}
+				if names[w.Name] {
+					name := w.Name
+					for i := 2; names[w.Name]; i++ {
+						w.Name = fmt.Sprintf("%s-%d", name, i)
+					}
+					c.Problems = append(c.Problems, Problem{Kind: kind, Name: name, Address: address, Issue: "another wallet has the same name", Repair: "renamed " + w.Name})
+				}
+				names[w.Name] = true
+			}
+
+			ws = append(ws, w)
+		}
+		return ws
+	}
+	salvaged.Stellar.Wallets = check("wallet", aj.Stellar.Wallets)
+	salvaged.Stellar.Archived = check("archived", aj.Stellar.Archived)
+
+	if secret != nil {
+		for _, k := range aj.Stellar.KYC {
+			kyc, err := decryptKYC(salvaged.secret, []kycyaml{k})
+			if err != nil {
+				c.Problems = append(c.Problems, Problem{Kind: "kyc", Address: k.Account, Issue: "the answers can not be decrypted", Repair: "removed, they will be asked again"})
+				continue
+			}
+			salvaged.Stellar.KYC = append(salvaged.Stellar.KYC, kyc...)
+		}
+	}
+
+	for name, contact := range aj.Stellar.Contacts {
+		if _, err := keypair.Parse(contact.Address); err != nil {
+			c.Problems = append(c.Problems, Problem{Kind: "contact", Name: name, Address: contact.Address, Issue: "invalid address", Repair: "removed"})
+			delete(salvaged.Stellar.Contacts, name)
+		}
+	}
+
+	return c, nil
+}
+
+const issueUndecryptable = "the seed can not be decrypted, it is corrupted or encrypted with another password"
+
+// checkWallet decodes j like decodeWallet, it returns why it can not instead
+// of an error
+func (a *Alfred) checkWallet(j walletyaml) (*Wallet, string) {
+	if _, err := keypair.Parse(j.Address); err != nil {
+		return nil, "invalid address"
+	}
+	if a.secret == nil || j.Cold != "" {
+		w, err := a.decodeWallet(j)
+		if err != nil {
+			return nil, err.Error()
+		}
+		return w, ""
+	}
+
+	if j.Seed == "" {
+		return nil, "the seed is missing"
+	}
+	decoded, err := base64.RawStdEncoding.DecodeString(j.Seed)
+	if err != nil {
+		return nil, "the encrypted seed is not valid base64"
+	}
+	if _, err := decrypt(a.secret, decoded); err != nil {
+		return nil, issueUndecryptable
+	}
+
+	w, err := a.decodeWallet(j)
+	if err != nil {
+		return nil, "the seed does not match the address"
+	}
+	return w, ""
+}
@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/celrenheit/alfred/decimal"
+)
+
+// Invoice is a payment requested to a wallet by alfred invoice create. The
+// payment is told apart from the others by its memo, unique to the invoice.
+type Invoice struct {
+	ID      int    `yaml:"id"`
+	Network string `yaml:"network"`
+	// Wallet receives the payment at Address
+	Wallet  string `yaml:"wallet"`
+	Address string `yaml:"address"`
+	Amount  string `yaml:"amount"`
+	Code    string `yaml:"code"`
+	Issuer  string `yaml:"issuer,omitempty"`
+	Memo    string `yaml:"memo"`
+	// Note describes what is paid, it is only shown to the payer
+	Note    string    `yaml:"note,omitempty"`
+	Created time.Time `yaml:"created"`
+	// Paid is the time of the transaction paying the invoice, if any
+	Paid        *time.Time `yaml:"paid,omitempty"`
+	Transaction string     `yaml:"transaction,omitempty"`
+}
+
+// Matches reports whether a payment of amnt of the asset code and issuer, with
+// memo, pays i
+func (i Invoice) Matches(amnt, code, issuer, memo string) bool {
+	if code != i.Code || issuer != i.Issuer || memo != i.Memo {
+		return false
+	}
+
+	want, err := decimal.Parse(i.Amount)
+	if err != nil {
+		return false
+	}
+	got, err := decimal.Parse(amnt)
+	return err == nil && got == want
+}
+
+// MarkPaid records that the transaction hash paid i at t
+func (i *Invoice) MarkPaid(hash string, t time.Time) {
+	i.Paid = &t
+	i.Transaction = hash
+}
+
+// AddInvoice stores i with a new id, which is returned
+func (m *Alfred) AddInvoice(i Invoice) int {
+	i.ID = 1
+	for _, existing := range m.Stellar.Invoices {
+		if existing.ID >= i.ID {
+			i.ID = existing.ID + 1
+		}
+	}
+
+	m.Stellar.Invoices = append(m.Stellar.Invoices, i)
+	return i.ID
+}
+
+// Invoice returns the invoice with id
+func (m *Alfred) Invoice(id int) (*Invoice, error) {
+	for i := range m.Stellar.Invoices {
+		if m.Stellar.Invoices[i].ID == id {
+			return &m.Stellar.Invoices[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("invoice %d not found", id)
+}
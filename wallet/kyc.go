@@ -0,0 +1,95 @@
+package wallet
+
+import (
+	"encoding/base64"
+)
+
+// KYC holds the information given to anchors about the owner of an account
+// (SEP-12). The values are encrypted in the database.
+type KYC struct {
+	Account string
+	// Fields are the answers by SEP-12 field name, documents are stored as
+	// the path of the file
+	Fields map[string]string
+}
+
+type kycyaml struct {
+	Account string            `yaml:"account"`
+	Fields  map[string]string `yaml:"fields,omitempty"`
+}
+
+// KYCAnswers returns the information stored for the owner of account
+func (m *Alfred) KYCAnswers(account string) map[string]string {
+	answers := make(map[string]string)
+	for _, k := range m.Stellar.KYC {
+		if k.Account == account {
+			for name, value := range k.Fields {
+				answers[name] = value
+			}
+		}
+	}
+
+	return answers
+}
+
+// SetKYCAnswers stores information about the owner of account, replacing
+// the previous values of the same fields
+func (m *Alfred) SetKYCAnswers(account string, answers map[string]string) {
+	for i, k := range m.Stellar.KYC {
+		if k.Account != account {
+			continue
+		}
+
+		for name, value := range answers {
+			m.Stellar.KYC[i].Fields[name] = value
+		}
+		return
+	}
+
+	fields := make(map[string]string)
+	for name, value := range answers {
+		fields[name] = value
+	}
+	m.Stellar.KYC = append(m.Stellar.KYC, KYC{Account: account, Fields: fields})
+}
+
+func encryptKYC(secret []byte, list []KYC) ([]kycyaml, error) {
+	var out []kycyaml
+	for _, k := range list {
+		fields := make(map[string]string)
+		for name, value := range k.Fields {
+			encrypted, err := encrypt(secret, []byte(value))
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = base64.RawStdEncoding.EncodeToString(encrypted)
+		}
+
+		out = append(out, kycyaml{Account: k.Account, Fields: fields})
+	}
+
+	return out, nil
+}
+
+func decryptKYC(secret []byte, list []kycyaml) ([]KYC, error) {
+	var out []KYC
+	for _, k := range list {
+		fields := make(map[string]string)
+		for name, value := range k.Fields {
+			decoded, err := base64.RawStdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, err
+			}
+
+			plaintext, err := decrypt(secret, decoded)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = string(plaintext)
+		}
+
+		out = append(out, KYC{Account: k.Account, Fields: fields})
+	}
+
+	return out, nil
+}
@@ -0,0 +1,81 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LogEntry is a transaction submitted by alfred
+type LogEntry struct {
+	Time         time.Time `yaml:"time"`
+	Network      string    `yaml:"network"`
+	Hash         string    `yaml:"hash"`
+	Envelope     string    `yaml:"envelope"`
+	Operations   []string  `yaml:"operations,omitempty"`
+	Destinations []string  `yaml:"destinations,omitempty"`
+	// IdempotencyKey is the key given to the command which submitted the
+	// transaction, so that running it again does not submit another one
+	IdempotencyKey string `yaml:"idempotency_key,omitempty"`
+	// Prev chains the entry to the previous one, so that removing or editing
+	// an entry is detected by VerifyLog
+	Prev string `yaml:"prev,omitempty"`
+}
+
+// digest returns the hash chaining the next entry to e
+func (e LogEntry) digest() string {
+	h := sha256.New()
+	fmt.Fprintln(h, e.Time.UTC().Format(time.RFC3339Nano))
+	fmt.Fprintln(h, e.Network)
+	fmt.Fprintln(h, e.Hash)
+	fmt.Fprintln(h, e.Envelope)
+	fmt.Fprintln(h, strings.Join(e.Operations, ","))
+	fmt.Fprintln(h, strings.Join(e.Destinations, ","))
+	// left out without a key, so that the older entries keep their digest
+	if e.IdempotencyKey != "" {
+		fmt.Fprintln(h, e.IdempotencyKey)
+	}
+	fmt.Fprintln(h, e.Prev)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AppendLog adds a submitted transaction at the end of the log
+func (m *Alfred) AppendLog(e LogEntry) {
+	e.Prev = ""
+	if n := len(m.Stellar.Log); n > 0 {
+		e.Prev = m.Stellar.Log[n-1].digest()
+	}
+
+	m.Stellar.Log = append(m.Stellar.Log, e)
+}
+
+// LogByIdempotencyKey returns the entries of the transactions submitted on
+// network with the idempotency key, in order
+func (m *Alfred) LogByIdempotencyKey(network, key string) []LogEntry {
+	var entries []LogEntry
+	for _, e := range m.Stellar.Log {
+		if key != "" && e.IdempotencyKey == key && e.Network == network {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// VerifyLog checks that no entry of the log was edited or removed,
+// it returns the index of the first entry that does not match its predecessor.
+func (m *Alfred) VerifyLog() (int, error) {
+	for i, e := range m.Stellar.Log {
+		want := ""
+		if i > 0 {
+			want = m.Stellar.Log[i-1].digest()
+		}
+
+		if e.Prev != want {
+			return i, fmt.Errorf("log entry %d (%s) does not follow the previous entry, the log was modified", i+1, e.Hash)
+		}
+	}
+
+	return -1, nil
+}
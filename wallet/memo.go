@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 
 	"github.com/stellar/go/build"
@@ -73,6 +74,38 @@ func (m Memo) ToTransactionMutator() build.TransactionMutator {
 	}
 }
 
+var personalDataPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"an email address", regexp.MustCompile(`[[:alnum:]._%+-]+@[[:alnum:].-]+\.[[:alpha:]]{2,}`)},
+	{"a phone number", regexp.MustCompile(`\+[0-9][0-9 ().-]{6,}[0-9]|\(?\b[0-9]{3}\)?[ .-][0-9]{3}[ .-][0-9]{4}\b|\b0[1-9]([ .-]?[0-9]{2}){4}\b`)},
+	{"a name", regexp.MustCompile(`\b(Mr|Mrs|Ms|Dr)\.? [A-Z][a-z]+|\b[A-Z][a-z]+ [A-Z][a-z]+\b`)},
+}
+
+// DetectPersonalData returns the kinds of personal data that text seems to contain.
+// It relies on simple patterns and is only meant to warn before a memo is
+// written permanently on the public ledger.
+func DetectPersonalData(text string) []string {
+	var kinds []string
+	for _, p := range personalDataPatterns {
+		if p.re.MatchString(text) {
+			kinds = append(kinds, p.kind)
+		}
+	}
+
+	return kinds
+}
+
+// PersonalData returns the kinds of personal data found in a text memo
+func (m Memo) PersonalData() []string {
+	if m.Type != MEMO_TEXT {
+		return nil
+	}
+
+	return DetectPersonalData(m.Value.StringValue)
+}
+
 func (v Memo) MarshalYAML() (interface{}, error) {
 	o := map[string]interface{}{
 		"type": v.Type.String(),
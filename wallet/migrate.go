@@ -0,0 +1,155 @@
+package wallet
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Migration upgrades the database to its version. It works on the decoded
+// YAML document of a content of the database, before its seeds are
+// decrypted: the database itself when written in clear by an older alfred,
+// or else each compartment once decrypted.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(doc map[interface{}]interface{}) error
+}
+
+// Migrations are the migrations of the database, by version
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "record the schema version in the database",
+		Migrate:     func(doc map[interface{}]interface{}) error { return nil },
+	},
+}
+
+// CurrentVersion is the version of the schema of the database written by
+// this alfred
+var CurrentVersion = Migrations[len(Migrations)-1].Version
+
+// Backuper is implemented by the stores keeping a copy of the database
+// before it is migrated
+type Backuper interface {
+	// Backup keeps b, the database at version, and returns where
+	Backup(b []byte, version int) (string, error)
+}
+
+// StoredVersion returns the version of the schema of the database of s, 0
+// for the databases written before versioning and CurrentVersion for a new
+// one
+func StoredVersion(s Store) (int, error) {
+	b, err := s.Read()
+	if err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return CurrentVersion, nil
+	}
+
+	return storedVersion(b)
+}
+
+func storedVersion(b []byte) (int, error) {
+	var doc struct {
+		Version int `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return 0, err
+	}
+
+	return doc.Version, nil
+}
+
+// migrate upgrades b, the database of s, to CurrentVersion. The database is
+// backed up first when s is a Backuper, then replaced by the upgraded one.
+func migrate(s Store, b []byte) ([]byte, error) {
+	version, err := storedVersion(b)
+	if err != nil {
+		return nil, err
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("the database was written by a newer alfred (schema version %d, this one knows up to %d), upgrade alfred", version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return b, nil
+	}
+
+	// the compartments are migrated once decrypted, see openCompartment
+	var migrated []byte
+	if storedClear(b) {
+		migrated, err = upgrade(b, version)
+	} else {
+		migrated, err = setVersion(b, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if backuper, ok := s.(Backuper); ok {
+		if _, err := backuper.Backup(b, version); err != nil {
+			return nil, fmt.Errorf("backup before migration: %v", err)
+		}
+	}
+	if err := s.Write(migrated); err != nil {
+		return nil, err
+	}
+
+	return migrated, nil
+}
+
+// upgrade applies to b, a content of the database at version, the
+// migrations after it
+func upgrade(b []byte, version int) ([]byte, error) {
+	doc := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	for _, m := range Migrations {
+		if m.Version <= version {
+			continue
+		}
+		if err := m.Migrate(doc); err != nil {
+			return nil, fmt.Errorf("migration to version %d (%s): %v", m.Version, m.Description, err)
+		}
+	}
+	doc["version"] = CurrentVersion
+
+	return yaml.Marshal(doc)
+}
+
+// setVersion returns b, the database at version, at CurrentVersion, with its
+// public content upgraded
+func setVersion(b []byte, version int) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	found := false
+	for i := range doc {
+		switch doc[i].Key {
+		case "version":
+			doc[i].Value, found = CurrentVersion, true
+		case "public":
+			content, err := yaml.Marshal(doc[i].Value)
+			if err != nil {
+				return nil, err
+			}
+			if content, err = upgrade(content, version); err != nil {
+				return nil, err
+			}
+			var public yaml.MapSlice
+			if err := yaml.Unmarshal(content, &public); err != nil {
+				return nil, err
+			}
+			doc[i].Value = public
+		}
+	}
+	if !found {
+		doc = append(yaml.MapSlice{{Key: "version", Value: CurrentVersion}}, doc...)
+	}
+
+	return yaml.Marshal(doc)
+}
@@ -0,0 +1,63 @@
+package wallet
+
+import "sort"
+
+// names is the reverse index of the wallets and contacts, by address and by
+// alias
+type names struct {
+	byAddress      map[string]string
+	wallets        map[string]bool
+	walletAliases  map[string]*Wallet
+	contactAliases map[string]string
+}
+
+// AccountName returns the name of the wallet or contact owning address, a
+// wallet taking precedence over a contact with the same address
+func (m *Alfred) AccountName(address string) (string, bool) {
+	name, ok := m.names().byAddress[address]
+	return name, ok
+}
+
+// IsWallet reports whether address is the one of a wallet
+func (m *Alfred) IsWallet(address string) bool {
+	return m.names().wallets[address]
+}
+
+// ForgetNames drops the reverse index of the names, to be called after
+// changing the wallets or the contacts without the methods of m
+func (m *Alfred) ForgetNames() { m.index = nil }
+
+func (m *Alfred) names() *names {
+	if m.index != nil {
+		return m.index
+	}
+
+	n := &names{
+		byAddress:      map[string]string{},
+		wallets:        map[string]bool{},
+		walletAliases:  map[string]*Wallet{},
+		contactAliases: map[string]string{},
+	}
+	contacts := make([]string, 0, len(m.Stellar.Contacts))
+	for name := range m.Stellar.Contacts {
+		contacts = append(contacts, name)
+	}
+	// the first name in order for the addresses of several contacts
+	sort.Sort(sort.Reverse(sort.StringSlice(contacts)))
+	for _, name := range contacts {
+		n.byAddress[m.Stellar.Contacts[name].Address] = name
+		for _, alias := range m.Stellar.Contacts[name].Aliases {
+			n.contactAliases[alias] = name
+		}
+	}
+	for _, w := range m.Stellar.Wallets {
+		n.byAddress[w.Keypair.Address()] = w.Name
+		n.wallets[w.Keypair.Address()] = true
+		for _, alias := range w.Aliases {
+			n.walletAliases[alias] = w
+		}
+	}
+
+	m.index = n
+	return n
+}
@@ -0,0 +1,57 @@
+package wallet
+
+import "time"
+
+// ExpiringOffer is an offer created with EXPIRES IN, alfred daemon cancels it
+// once expired since the network has no expiry for offers
+type ExpiringOffer struct {
+	ID      int64     `yaml:"id"`
+	Network string    `yaml:"network"`
+	Wallet  string    `yaml:"wallet"`
+	Expires time.Time `yaml:"expires"`
+}
+
+// TrackOffer records the expiry of an offer
+func (m *Alfred) TrackOffer(o ExpiringOffer) {
+	for i, existing := range m.Stellar.Offers {
+		if existing.ID == o.ID && existing.Network == o.Network {
+			m.Stellar.Offers[i] = o
+			return
+		}
+	}
+
+	m.Stellar.Offers = append(m.Stellar.Offers, o)
+}
+
+// OfferExpiry returns the expiry of the offer with id on network, if it was created with EXPIRES IN
+func (m *Alfred) OfferExpiry(network string, id int64) (time.Time, bool) {
+	for _, o := range m.Stellar.Offers {
+		if o.ID == id && o.Network == network {
+			return o.Expires, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// ExpiredOffers returns the offers of network expired at now
+func (m *Alfred) ExpiredOffers(network string, now time.Time) []ExpiringOffer {
+	var expired []ExpiringOffer
+	for _, o := range m.Stellar.Offers {
+		if o.Network == network && !o.Expires.After(now) {
+			expired = append(expired, o)
+		}
+	}
+
+	return expired
+}
+
+// ForgetOffer stops tracking the offer with id on network, once cancelled or filled
+func (m *Alfred) ForgetOffer(network string, id int64) {
+	for i, o := range m.Stellar.Offers {
+		if o.ID == id && o.Network == network {
+			m.Stellar.Offers = append(m.Stellar.Offers[:i], m.Stellar.Offers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/stellar/go/keypair"
+)
+
+const (
+	// passphraseIterations is the number of PBKDF2 iterations deriving the
+	// key of a wallet from its passphrase
+	passphraseIterations = 100000
+	passphraseSaltSize   = 16
+)
+
+// errWrongPassphrase is returned when the seed of a wallet can not be
+// decrypted with the passphrase given
+var errWrongPassphrase = errors.New("wrong passphrase")
+
+// Protect encrypts the seed of w with passphrase, on top of the secret of the
+// database. The seed is then only available after Unwrap, w holding its
+// address until then.
+func (w *Wallet) Protect(passphrase string) error {
+	if w.IsCold() {
+		return errors.New("the seed of a cold wallet is not stored")
+	}
+	if w.IsProtected() {
+		return errors.New("the wallet already has a passphrase")
+	}
+	if passphrase == "" {
+		return errors.New("the passphrase should not be empty")
+	}
+	kp, ok := w.Keypair.(*keypair.Full)
+	if !ok {
+		return errors.New("you should unlock alfred to protect a wallet")
+	}
+
+	salt := make([]byte, passphraseSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+
+	encrypted, err := encrypt(pbkdf2([]byte(passphrase), salt, passphraseIterations, 32), getSeed(kp.Seed()))
+	if err != nil {
+		return err
+	}
+
+	address, err := keypair.Parse(kp.Address())
+	if err != nil {
+		return err
+	}
+	w.Keypair, w.wrapped = address, append(salt, encrypted...)
+	return nil
+}
+
+// IsProtected reports whether the seed of w is encrypted with a passphrase
+func (w *Wallet) IsProtected() bool { return len(w.wrapped) > 0 }
+
+// Unwrap decrypts the seed of w with its passphrase. The seed stays
+// encrypted with the passphrase in the database.
+func (w *Wallet) Unwrap(passphrase string) (*keypair.Full, error) {
+	if !w.IsProtected() {
+		return nil, errors.New("the wallet has no passphrase")
+	}
+	if kp, ok := w.Keypair.(*keypair.Full); ok {
+		return kp, nil
+	}
+	if len(w.wrapped) < passphraseSaltSize {
+		return nil, errors.New("the encrypted seed is corrupted")
+	}
+
+	salt, encrypted := w.wrapped[:passphraseSaltSize], w.wrapped[passphraseSaltSize:]
+	seed, err := decrypt(pbkdf2([]byte(passphrase), salt, passphraseIterations, 32), encrypted)
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+
+	var seed32 [32]byte
+	copy(seed32[:], seed)
+	kp, err := keypair.FromRawSeed(seed32)
+	if err != nil {
+		return nil, err
+	}
+	if kp.Address() != w.Keypair.Address() {
+		return nil, errors.New("the seed does not match the address")
+	}
+
+	w.Keypair = kp
+	return kp, nil
+}
+
+// Unprotect removes the passphrase of w, its seed is then only encrypted
+// with the secret of the database
+func (w *Wallet) Unprotect(passphrase string) error {
+	kp, err := w.Unwrap(passphrase)
+	if err != nil {
+		return err
+	}
+
+	w.Keypair, w.wrapped = kp, nil
+	return nil
+}
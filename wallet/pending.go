@@ -0,0 +1,60 @@
+package wallet
+
+import (
+	"fmt"
+	"time"
+)
+
+// PendingTx is a signed transaction submitted to horizon, kept until alfred
+// knows whether it went through so that an interrupted submission can be
+// resumed by alfred pending
+type PendingTx struct {
+	ID       int       `yaml:"id"`
+	Network  string    `yaml:"network"`
+	Hash     string    `yaml:"hash"`
+	Envelope string    `yaml:"envelope"`
+	Created  time.Time `yaml:"created"`
+	// IdempotencyKey is recorded in the log with the transaction once it
+	// went through
+	IdempotencyKey string `yaml:"idempotency_key,omitempty"`
+}
+
+// AddPending stores p with a new id, which is returned
+func (m *Alfred) AddPending(p PendingTx) int {
+	p.ID = 1
+	for _, existing := range m.Stellar.Pending {
+		if existing.ID >= p.ID {
+			p.ID = existing.ID + 1
+		}
+	}
+
+	m.Stellar.Pending = append(m.Stellar.Pending, p)
+	return p.ID
+}
+
+// PendingTx returns the pending transaction with id
+func (m *Alfred) PendingTx(id int) (*PendingTx, error) {
+	for i := range m.Stellar.Pending {
+		if m.Stellar.Pending[i].ID == id {
+			return &m.Stellar.Pending[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("pending transaction %d not found", id)
+}
+
+// RemovePending removes the pending transactions with ids
+func (m *Alfred) RemovePending(ids ...int) {
+	remove := make(map[int]bool)
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	var kept []PendingTx
+	for _, p := range m.Stellar.Pending {
+		if !remove[p.ID] {
+			kept = append(kept, p)
+		}
+	}
+	m.Stellar.Pending = kept
+}
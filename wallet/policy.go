@@ -0,0 +1,88 @@
+package wallet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy restricts the payments sent from a wallet.
+// Amounts are in XLM, payments of other assets only count for ContactsOnly.
+type Policy struct {
+	// MaxPerDay is the maximum amount sent over the last 24 hours, zero means no limit
+	MaxPerDay float64 `yaml:"max_per_day,omitempty"`
+	// ConfirmAbove requires a confirmation for payments above this amount, even with --yes
+	ConfirmAbove float64 `yaml:"confirm_above,omitempty"`
+	// ContactsOnly denies destinations which are neither a contact nor a wallet
+	ContactsOnly bool `yaml:"contacts_only,omitempty"`
+}
+
+// Payment is a payment checked against a policy
+type Payment struct {
+	// Amount of XLM sent, zero for other assets
+	Amount float64
+	// Destination is the address of the recipient
+	Destination string
+	// Known reports whether the destination is a contact or a wallet
+	Known bool
+}
+
+// Violation is a policy rule broken by a payment sent anyway
+type Violation struct {
+	Time        time.Time `yaml:"time"`
+	Wallet      string    `yaml:"wallet"`
+	Rule        string    `yaml:"rule"`
+	Destination string    `yaml:"destination"`
+	Amount      string    `yaml:"amount,omitempty"`
+}
+
+// IsZero reports whether the policy has no rule
+func (p Policy) IsZero() bool {
+	return p == Policy{}
+}
+
+// Check returns the rules broken by payment, given the amount already sent
+// over the last 24 hours, and whether the payment needs a confirmation.
+func (p Policy) Check(payment Payment, spent float64) (violations []string, confirm bool) {
+	if p.ContactsOnly && !payment.Known {
+		violations = append(violations, fmt.Sprintf("destination %s is not a contact", TrimAddress(payment.Destination)))
+	}
+
+	if p.MaxPerDay > 0 && spent+payment.Amount > p.MaxPerDay {
+		violations = append(violations, fmt.Sprintf("max %s XLM per day exceeded, %s XLM already sent today",
+			formatAmount(p.MaxPerDay), formatAmount(spent)))
+	}
+
+	confirm = p.ConfirmAbove > 0 && payment.Amount > p.ConfirmAbove
+	return violations, confirm
+}
+
+// String describes the rules of the policy, such as "max 500 XLM per day"
+func (p Policy) String() string {
+	var rules []string
+	if p.MaxPerDay > 0 {
+		rules = append(rules, fmt.Sprintf("max %s XLM per day", formatAmount(p.MaxPerDay)))
+	}
+	if p.ConfirmAbove > 0 {
+		rules = append(rules, fmt.Sprintf("always require confirmation above %s XLM", formatAmount(p.ConfirmAbove)))
+	}
+	if p.ContactsOnly {
+		rules = append(rules, "deny destinations not in contacts")
+	}
+
+	if len(rules) == 0 {
+		return "no policy"
+	}
+
+	return strings.Join(rules, ", ")
+}
+
+// LogViolation records a policy violation in the database
+func (m *Alfred) LogViolation(v Violation) {
+	m.Stellar.Violations = append(m.Stellar.Violations, v)
+}
+
+func formatAmount(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
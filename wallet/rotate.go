@@ -0,0 +1,29 @@
+package wallet
+
+import "fmt"
+
+// Rotate replaces the key of the wallet named name by the one of the wallet
+// named next, which takes its name and policy. The replaced key is archived.
+func (m *Alfred) Rotate(name, next string) error {
+	old, replacement := m.WalletByName(name), m.WalletByName(next)
+	if old == nil {
+		return fmt.Errorf("wallet %s not found", name)
+	}
+	if replacement == nil {
+		return fmt.Errorf("wallet %s not found", next)
+	}
+
+	for i, w := range m.Stellar.Wallets {
+		if w == old {
+			m.Stellar.Wallets = append(m.Stellar.Wallets[:i], m.Stellar.Wallets[i+1:]...)
+			break
+		}
+	}
+	m.Stellar.Archived = append(m.Stellar.Archived, old)
+
+	replacement.Name = old.Name
+	replacement.Policy = old.Policy
+	replacement.Aliases, replacement.Tags = old.Aliases, old.Tags
+	m.ForgetNames()
+	return nil
+}
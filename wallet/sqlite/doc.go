@@ -0,0 +1,7 @@
+// Package sqlite stores the database of alfred in a SQLite file, for the
+// locations such as sqlite:///path/to/alfred.db. Importing it registers the
+// sqlite scheme, see wallet.RegisterStore.
+//
+// The SQLite driver is written in C, the store is only built with cgo. An
+// alfred built without cgo fails to open the sqlite locations.
+package sqlite
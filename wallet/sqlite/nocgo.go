@@ -0,0 +1,16 @@
+//go:build !cgo
+// +build !cgo
+
+package sqlite
+
+import (
+	"errors"
+
+	"github.com/celrenheit/alfred/wallet"
+)
+
+func init() {
+	wallet.RegisterStore("sqlite", func(location string) (wallet.Store, error) {
+		return nil, errors.New("this alfred was built without cgo, which the SQLite store needs: build it with CGO_ENABLED=1 or store the database in a file")
+	})
+}
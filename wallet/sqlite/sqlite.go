@@ -0,0 +1,127 @@
+//go:build cgo
+// +build cgo
+
+package sqlite
+
+import (
+	"database/sql"
+	"os"
+	"sync"
+
+	"github.com/celrenheit/alfred/wallet"
+	// registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// opened keeps the files opened by the scheme, which are opened again for
+// every read and write of the database
+var opened = struct {
+	sync.Mutex
+	byPath map[string]*Store
+}{byPath: map[string]*Store{}}
+
+func init() {
+	wallet.RegisterStore("sqlite", func(location string) (wallet.Store, error) {
+		opened.Lock()
+		defer opened.Unlock()
+		if s, ok := opened.byPath[location]; ok {
+			return s, nil
+		}
+
+		s, err := Open(location)
+		if err != nil {
+			return nil, err
+		}
+		opened.byPath[location] = s
+		return s, nil
+	})
+}
+
+// Store is a database stored in a single row of a SQLite file. Its writes
+// are transactions, so that it is never left truncated, and one writer
+// holds it at a time from the read to the write with Lock, see
+// wallet.UpdateStore.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the SQLite file at path, created if it does not exist
+func Open(path string) (*Store, error) {
+	// SQLite gives its journal the permissions of the file, which only its
+	// user reads
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	if err := os.Chmod(path, 0600); err != nil {
+		return nil, err
+	}
+
+	// the transactions take the write lock when they begin, so that two
+	// updates do not both read the database before writing it
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(`create table if not exists alfred (
+		id integer primary key check (id = 1),
+		content blob not null
+	)`); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the file
+func (s *Store) Close() error { return s.db.Close() }
+
+// Read implements wallet.Store
+func (s *Store) Read() ([]byte, error) { return read(s.db) }
+
+// Write implements wallet.Store
+func (s *Store) Write(b []byte) error { return write(s.db, b) }
+
+// Lock implements wallet.Locker, with a transaction committed by the
+// function it returns
+func (s *Store) Lock() (wallet.Store, func() error, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return txStore{tx}, tx.Commit, nil
+}
+
+// txStore is a Store whose transaction is held, see Store.Lock
+type txStore struct{ tx *sql.Tx }
+
+// Read implements wallet.Store
+func (s txStore) Read() ([]byte, error) { return read(s.tx) }
+
+// Write implements wallet.Store
+func (s txStore) Write(b []byte) error { return write(s.tx, b) }
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func read(q querier) ([]byte, error) {
+	var b []byte
+	err := q.QueryRow(`select content from alfred where id = 1`).Scan(&b)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return b, err
+}
+
+func write(q querier, b []byte) error {
+	_, err := q.Exec(`insert into alfred (id, content) values (1, ?)
+		on conflict (id) do update set content = excluded.content`, b)
+	return err
+}
@@ -0,0 +1,70 @@
+//go:build cgo
+// +build cgo
+
+package sqlite
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "alfred.db")
+
+	m, err := wallet.Open("sqlite://"+path, []byte("hello"))
+	require.NoError(t, err)
+	require.Empty(t, m.Stellar.Wallets)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(wallet.New("master", kp)))
+	require.NoError(t, wallet.Write("sqlite://"+path, m))
+
+	for _, name := range []string{path, path + "-wal"} {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), info.Mode().Perm(), name)
+	}
+
+	// the writers run meanwhile do not lose the changes of each other
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := wallet.Update("sqlite://"+path, []byte("hello"), func(m *wallet.Alfred) error {
+				return m.AddContact(fmt.Sprintf("contact%d", i), kp.Address(), nil)
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// read from another connection
+	s, err := Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+	m, err = wallet.Load(s, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, kp.Address(), m.WalletByName("master").Keypair.Address())
+	require.Len(t, m.Stellar.Contacts, 10)
+
+	_, err = wallet.Load(s, []byte("wrong"))
+	require.Error(t, err)
+}
@@ -0,0 +1,286 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// lockWait is the longest a write waits for another one to finish
+	lockWait = 5 * time.Second
+	// lockStale is the age after which the lock of a write is considered left
+	// by a process which died
+	lockStale = 30 * time.Second
+)
+
+// Store holds the encoded database, whose seeds are encrypted
+type Store interface {
+	// Read returns the database, nil if it does not exist yet
+	Read() ([]byte, error)
+	// Write replaces the database by b
+	Write(b []byte) error
+}
+
+// Locker is implemented by the stores which can be held by a single writer
+// from the read of the database until its write, see UpdateStore
+type Locker interface {
+	// Lock waits for the other writers to finish, and returns the store to
+	// read and write while it is held, and the function releasing it
+	Lock() (locked Store, unlock func() error, err error)
+}
+
+// StoreOpener opens the store at location, the part of a location after
+// its scheme
+type StoreOpener func(location string) (Store, error)
+
+var stores = struct {
+	sync.Mutex
+	byScheme map[string]StoreOpener
+}{byScheme: map[string]StoreOpener{
+	"file": func(location string) (Store, error) { return FileStore{Path: location}, nil },
+}}
+
+// RegisterStore makes the stores of scheme available to OpenStore, for
+// locations such as scheme://location
+func RegisterStore(scheme string, open StoreOpener) {
+	stores.Lock()
+	defer stores.Unlock()
+	stores.byScheme[scheme] = open
+}
+
+// OpenStore opens the store at location: a file path, or scheme://location
+// for a store registered with RegisterStore
+func OpenStore(location string) (Store, error) {
+	scheme := "file"
+	if i := strings.Index(location, "://"); i > 0 {
+		scheme, location = location[:i], location[i+len("://"):]
+	}
+
+	stores.Lock()
+	open, ok := stores.byScheme[scheme]
+	stores.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown database scheme %s", scheme)
+	}
+
+	return open(location)
+}
+
+// Load decodes the database of s, unlocked with secret. Without secret, only
+// the hardware key of the database is read.
+func Load(s Store, secret []byte) (*Alfred, error) {
+	b, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var a Alfred
+	a.Unlock(secret)
+	if len(b) > 0 {
+		if b, err = migrate(s, b); err != nil {
+			return nil, err
+		}
+		if secret != nil {
+			if c, err := openCompartment(b, secret); err != nil || c != nil {
+				return c, err
+			}
+			if err := checkOpens(b, a.secret); err != nil {
+				return nil, err
+			}
+		}
+		if err := yaml.Unmarshal(b, &a); err != nil {
+			return nil, err
+		}
+		a.stored = a.secret
+	}
+	return &a, nil
+}
+
+// Save encodes m into its compartment of s, see saveCompartment. The other
+// compartments are kept as stored.
+func Save(s Store, m *Alfred) error {
+	if m.secret == nil {
+		return errors.New("no secret set")
+	}
+
+	stored, err := s.Read()
+	if err != nil {
+		return err
+	}
+	b, err := saveCompartment(stored, m)
+	if err != nil {
+		return err
+	}
+	if err := s.Write(b); err != nil {
+		return err
+	}
+
+	m.stored = m.secret
+	return nil
+}
+
+// Hold runs fn with the store to read and write while s is held, when it is
+// a Locker, and with s otherwise
+func Hold(s Store, fn func(locked Store) error) (err error) {
+	locker, ok := s.(Locker)
+	if !ok {
+		return fn(s)
+	}
+
+	locked, unlock, err := locker.Lock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	return fn(locked)
+}
+
+// UpdateStore loads the database of s unlocked with secret, applies fn and
+// saves it. No other writer runs from the load to the save, see Hold, so
+// that the changes of fn are applied to the latest database and the ones
+// written meanwhile are not lost. fn should not write to s.
+func UpdateStore(s Store, secret []byte, fn func(m *Alfred) error) (*Alfred, error) {
+	var m *Alfred
+	err := Hold(s, func(s Store) error {
+		var err error
+		if m, err = Load(s, secret); err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+		return Save(s, m)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FileStore is a database stored in a YAML file. It is replaced atomically,
+// so that a command interrupted while writing does not leave it truncated,
+// and one write at a time, so that the daemon and the commands run meanwhile
+// do not interleave their writes. The lock is held from the read to the
+// write with Lock, see UpdateStore.
+type FileStore struct {
+	Path string
+}
+
+// Read implements Store
+func (s FileStore) Read() ([]byte, error) {
+	b, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// Write implements Store
+func (s FileStore) Write(b []byte) error {
+	unlock, err := lockFile(s.Path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.write(b)
+}
+
+// Lock implements Locker, with the same lock file as Write
+func (s FileStore) Lock() (Store, func() error, error) {
+	unlock, err := lockFile(s.Path + ".lock")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return lockedFile{s}, func() error { unlock(); return nil }, nil
+}
+
+// lockedFile is a FileStore whose lock is held, see FileStore.Lock
+type lockedFile struct{ FileStore }
+
+// Write implements Store
+func (s lockedFile) Write(b []byte) error { return s.write(b) }
+
+// write replaces the file by b, see FileStore
+func (s FileStore) write(b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), "."+filepath.Base(s.Path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.Path)
+}
+
+// Backup implements Backuper, the database is copied next to the file. An
+// existing backup of the same version is kept.
+func (s FileStore) Backup(b []byte, version int) (string, error) {
+	path := fmt.Sprintf("%s.v%d.bak", s.Path, version)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if os.IsExist(err) {
+		return path, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// lockFile creates the file at path, waiting for it to be removed if it
+// exists, and returns the function removing it
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the database is being written by another process, remove %s if none is running", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
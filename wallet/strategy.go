@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"fmt"
+	"time"
+)
+
+// StrategyDCA buys an asset with a fixed amount of another one at regular intervals
+const StrategyDCA = "dca"
+
+// maxStrategyRuns is the number of runs kept in the log of a strategy
+const maxStrategyRuns = 100
+
+// Strategy is a trading strategy run by the daemon
+type Strategy struct {
+	ID     int    `yaml:"id"`
+	Kind   string `yaml:"kind"`
+	Wallet string `yaml:"wallet"`
+	// Amount of the selling asset spent at each run
+	Amount        string        `yaml:"amount"`
+	SellingCode   string        `yaml:"selling_code"`
+	SellingIssuer string        `yaml:"selling_issuer,omitempty"`
+	BuyingCode    string        `yaml:"buying_code"`
+	BuyingIssuer  string        `yaml:"buying_issuer,omitempty"`
+	Every         time.Duration `yaml:"every"`
+	// MaxSlippage is the percentage the average price may be worse than the
+	// best one, zero uses the max-slippage setting
+	MaxSlippage float64 `yaml:"max_slippage,omitempty"`
+	// Next is the time of the next run
+	Next time.Time     `yaml:"next"`
+	Runs []StrategyRun `yaml:"runs,omitempty"`
+}
+
+// Status of a run
+const (
+	RunDone    = "done"
+	RunSkipped = "skipped"
+	RunFailed  = "failed"
+)
+
+// StrategyRun is the result of a run of a strategy
+type StrategyRun struct {
+	Time   time.Time `yaml:"time"`
+	Status string    `yaml:"status"`
+	// Reason explains why the run was skipped or failed
+	Reason string `yaml:"reason,omitempty"`
+	Hash   string `yaml:"hash,omitempty"`
+	// Price is the limit price of the offer, in selling asset per buying asset,
+	// and Bought the amount of buying asset estimated from the order book
+	Price  string `yaml:"price,omitempty"`
+	Bought string `yaml:"bought,omitempty"`
+}
+
+// AddStrategy stores s with a new id, which is returned
+func (m *Alfred) AddStrategy(s Strategy) int {
+	s.ID = 1
+	for _, existing := range m.Stellar.Strategies {
+		if existing.ID >= s.ID {
+			s.ID = existing.ID + 1
+		}
+	}
+
+	m.Stellar.Strategies = append(m.Stellar.Strategies, s)
+	return s.ID
+}
+
+// Strategy returns the strategy with id
+func (m *Alfred) Strategy(id int) (*Strategy, error) {
+	for i := range m.Stellar.Strategies {
+		if m.Stellar.Strategies[i].ID == id {
+			return &m.Stellar.Strategies[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("strategy %d not found", id)
+}
+
+// RemoveStrategy removes the strategy with id
+func (m *Alfred) RemoveStrategy(id int) error {
+	for i, s := range m.Stellar.Strategies {
+		if s.ID == id {
+			m.Stellar.Strategies = append(m.Stellar.Strategies[:i], m.Stellar.Strategies[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("strategy %d not found", id)
+}
+
+// DueStrategies returns the ids of the strategies which should run at now
+func (m *Alfred) DueStrategies(now time.Time) []int {
+	var ids []int
+	for _, s := range m.Stellar.Strategies {
+		if !s.Next.After(now) {
+			ids = append(ids, s.ID)
+		}
+	}
+
+	return ids
+}
+
+// Record appends run to the log of s and schedules the next run after it,
+// the runs missed while the daemon was stopped are not caught up
+func (s *Strategy) Record(run StrategyRun) {
+	s.Runs = append(s.Runs, run)
+	if len(s.Runs) > maxStrategyRuns {
+		s.Runs = s.Runs[len(s.Runs)-maxStrategyRuns:]
+	}
+
+	for !s.Next.After(run.Time) {
+		s.Next = s.Next.Add(s.Every)
+	}
+}
@@ -0,0 +1,103 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MergeContacts merges the contacts changed locally and remotely since the
+// last sync, whose contacts are base. A contact added, changed or deleted on
+// one side only is applied to the other, resolve is called when both sides
+// changed it differently: Skip keeps the local contact, Overwrite takes the
+// remote one and Rename keeps both, the remote one under another name.
+// It returns the merged contacts and the changes of the local ones.
+func MergeContacts(base, local, remote map[string]Contact, resolve Resolver) (map[string]Contact, []Restored, error) {
+	names := map[string]bool{}
+	for _, contacts := range []map[string]Contact{base, local, remote} {
+		for name := range contacts {
+			names[name] = true
+		}
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	merged := map[string]Contact{}
+	for name, c := range local {
+		merged[name] = c
+	}
+
+	var changes []Restored
+	for _, name := range sorted {
+		b, inBase := base[name]
+		l, inLocal := local[name]
+		r, inRemote := remote[name]
+		same := func(a Contact, inA bool, b Contact, inB bool) bool {
+			return inA == inB && (!inA || sameContact(a, b))
+		}
+
+		change := Restored{Kind: "contact", Name: name}
+		switch {
+		case same(l, inLocal, r, inRemote):
+			continue
+		case same(l, inLocal, b, inBase):
+			// only changed remotely
+			change.Action = take(merged, name, r, inRemote, inLocal)
+		case same(r, inRemote, b, inBase):
+			// only changed locally, the remote is updated
+			continue
+		default:
+			res, err := resolve(Conflict{Kind: change.Kind, Name: name, Existing: describeContact(l, inLocal), Incoming: describeContact(r, inRemote)})
+			if err != nil {
+				return nil, changes, err
+			}
+
+			switch {
+			case res == Overwrite:
+				change.Action = take(merged, name, r, inRemote, inLocal)
+			case res == Rename && inLocal && inRemote:
+				change.Name = freeName(name, func(name string) bool {
+					_, taken := merged[name]
+					return taken || names[name]
+				})
+				change.Action = "renamed from " + name
+				merged[change.Name] = r
+			case res == Rename && inRemote:
+				change.Action = take(merged, name, r, inRemote, inLocal)
+			default:
+				change.Action = "skipped"
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return merged, changes, nil
+}
+
+// take replaces the contact name of merged by the remote one, deleting it
+// when it is not in the remote, and returns what was done
+func take(merged map[string]Contact, name string, c Contact, inRemote, inLocal bool) string {
+	switch {
+	case !inRemote:
+		delete(merged, name)
+		return "deleted"
+	case !inLocal:
+		merged[name] = c
+		return "added"
+	}
+
+	merged[name] = c
+	return "updated"
+}
+
+func describeContact(c Contact, ok bool) string {
+	switch {
+	case !ok:
+		return "deleted"
+	case c.Memo != nil:
+		return fmt.Sprintf("%s, %s memo", c.Address, c.Memo.Type)
+	}
+	return c.Address
+}
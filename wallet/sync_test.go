@@ -0,0 +1,59 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeContacts(t *testing.T) {
+	alice := Contact{Address: "GALICE"}
+	bob := Contact{Address: "GBOB"}
+	carol := Contact{Address: "GCAROL"}
+	memo := Contact{Address: "GBOB", Memo: &Memo{Type: MEMO_ID, Value: MemoValue{IntValue: 42}}}
+
+	base := map[string]Contact{"alice": alice, "bob": bob, "carol": carol}
+	// alice is deleted locally, bob is changed on both sides, carol is
+	// deleted remotely and dave is added remotely
+	local := map[string]Contact{"bob": memo, "carol": carol}
+	remote := map[string]Contact{"alice": alice, "bob": carol, "dave": alice}
+
+	var conflicts []Conflict
+	resolver := func(r Resolution) Resolver {
+		conflicts = nil
+		return func(c Conflict) (Resolution, error) {
+			conflicts = append(conflicts, c)
+			return r, nil
+		}
+	}
+
+	merged, changes, err := MergeContacts(base, local, remote, resolver(Skip))
+	require.NoError(t, err)
+	require.Equal(t, map[string]Contact{"bob": memo, "dave": alice}, merged)
+	require.Equal(t, []Restored{
+		{Kind: "contact", Name: "bob", Action: "skipped"},
+		{Kind: "contact", Name: "carol", Action: "deleted"},
+		{Kind: "contact", Name: "dave", Action: "added"},
+	}, changes)
+	require.Equal(t, []Conflict{{Kind: "contact", Name: "bob", Existing: "GBOB, id memo", Incoming: "GCAROL"}}, conflicts)
+
+	merged, _, err = MergeContacts(base, local, remote, resolver(Overwrite))
+	require.NoError(t, err)
+	require.Equal(t, map[string]Contact{"bob": carol, "dave": alice}, merged)
+
+	merged, changes, err = MergeContacts(base, local, remote, resolver(Rename))
+	require.NoError(t, err)
+	require.Equal(t, map[string]Contact{"bob": memo, "bob-2": carol, "dave": alice}, merged)
+	require.Equal(t, Restored{Kind: "contact", Name: "bob-2", Action: "renamed from bob"}, changes[0])
+
+	// the first sync adds the contacts of both sides
+	merged, _, err = MergeContacts(nil, map[string]Contact{"alice": alice}, map[string]Contact{"bob": bob}, resolver(Skip))
+	require.NoError(t, err)
+	require.Equal(t, map[string]Contact{"alice": alice, "bob": bob}, merged)
+	require.Empty(t, conflicts)
+
+	// a contact changed remotely and deleted locally conflicts
+	_, _, err = MergeContacts(base, map[string]Contact{"alice": alice, "carol": carol}, remote, resolver(Skip))
+	require.NoError(t, err)
+	require.Equal(t, "deleted", conflicts[0].Existing)
+}
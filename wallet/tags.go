@@ -0,0 +1,183 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/stellar/go/keypair"
+)
+
+// NormalizeTag returns tag as it is stored: without its leading # and in
+// lower case, so that #Cold and cold are the same tag
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// HasTags reports whether tags holds every tag of want
+func HasTags(tags []string, want ...string) bool {
+	for _, t := range want {
+		if !contains(tags, NormalizeTag(t)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contact returns the contact named name, or having the alias name, with its
+// name
+func (m *Alfred) Contact(name string) (string, Contact, bool) {
+	if c, ok := m.Stellar.Contacts[name]; ok {
+		return name, c, true
+	}
+	if contact, ok := m.names().contactAliases[name]; ok {
+		return contact, m.Stellar.Contacts[contact], true
+	}
+	return "", Contact{}, false
+}
+
+// WalletsTagged returns the wallets with every tag of tags, all the wallets
+// when tags is empty
+func (m *Alfred) WalletsTagged(tags ...string) []*Wallet {
+	var ws []*Wallet
+	for _, w := range m.Stellar.Wallets {
+		if HasTags(w.Tags, tags...) {
+			ws = append(ws, w)
+		}
+	}
+	return ws
+}
+
+// AddAlias gives alias to the wallet or contact named name. An alias is
+// unique among the names and aliases of the wallets and contacts.
+func (m *Alfred) AddAlias(name, alias string) error {
+	switch {
+	case alias == "":
+		return errors.New("an alias should not be empty")
+	case strings.HasPrefix(alias, "#"):
+		return fmt.Errorf("invalid alias '%s', # starts the tags", alias)
+	case strings.ContainsAny(alias, " \t\n"):
+		return fmt.Errorf("invalid alias '%s', it should be a single word", alias)
+	}
+	if _, err := keypair.Parse(alias); err == nil {
+		return fmt.Errorf("invalid alias '%s', it is an address", alias)
+	}
+	if m.nameTaken(alias) {
+		return fmt.Errorf("'%s' is already the name or an alias of a wallet or contact", alias)
+	}
+
+	err := m.update(name, func(aliases, tags *[]string) {
+		*aliases = append(*aliases, alias)
+	})
+	if err != nil {
+		return err
+	}
+	m.ForgetNames()
+	return nil
+}
+
+// RemoveAlias removes alias from the wallet or contact it names
+func (m *Alfred) RemoveAlias(alias string) error {
+	w, contact := m.names().walletAliases[alias], m.names().contactAliases[alias]
+	if w == nil && contact == "" {
+		return fmt.Errorf("alias '%s' not found", alias)
+	}
+
+	name := contact
+	if w != nil {
+		name = w.Name
+	}
+	err := m.update(name, func(aliases, tags *[]string) {
+		*aliases = remove(*aliases, alias)
+	})
+	if err != nil {
+		return err
+	}
+	m.ForgetNames()
+	return nil
+}
+
+// Tag adds tags to the wallet or contact named name
+func (m *Alfred) Tag(name string, tags ...string) error {
+	for _, t := range tags {
+		if n := NormalizeTag(t); n == "" || strings.ContainsAny(n, " \t\n") {
+			return fmt.Errorf("invalid tag '%s', it should be a single word", t)
+		}
+	}
+
+	return m.update(name, func(aliases, current *[]string) {
+		for _, t := range tags {
+			if t := NormalizeTag(t); !contains(*current, t) {
+				*current = append(*current, t)
+			}
+		}
+		sort.Strings(*current)
+	})
+}
+
+// Untag removes tags from the wallet or contact named name
+func (m *Alfred) Untag(name string, tags ...string) error {
+	return m.update(name, func(aliases, current *[]string) {
+		for _, t := range tags {
+			*current = remove(*current, NormalizeTag(t))
+		}
+	})
+}
+
+// update calls f with the aliases and tags of the wallet or contact named
+// name, a wallet taking precedence over a contact with the same name
+func (m *Alfred) update(name string, f func(aliases, tags *[]string)) error {
+	if w := m.WalletByName(name); w != nil {
+		f(&w.Aliases, &w.Tags)
+		return nil
+	}
+
+	contact, c, ok := m.Contact(name)
+	if !ok {
+		return fmt.Errorf("no wallet or contact named '%s'", name)
+	}
+	f(&c.Aliases, &c.Tags)
+	m.Stellar.Contacts[contact] = c
+	return nil
+}
+
+// nameTaken reports whether name is the name or an alias of a wallet or contact
+func (m *Alfred) nameTaken(name string) bool {
+	_, _, contact := m.Contact(name)
+	return contact || m.WalletByName(name) != nil
+}
+
+// freeAliases returns the aliases which are not taken yet
+func (m *Alfred) freeAliases(aliases []string) []string {
+	// the wallets and contacts may have changed since the index was built
+	m.ForgetNames()
+
+	var free []string
+	for _, alias := range aliases {
+		if !m.nameTaken(alias) && !contains(free, alias) {
+			free = append(free, alias)
+		}
+	}
+	return free
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// remove returns list without s
+func remove(list []string, s string) []string {
+	var kept []string
+	for _, e := range list {
+		if e != s {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
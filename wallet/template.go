@@ -0,0 +1,81 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// placeholder matches the variables of a template, such as {amount}
+var placeholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_-]*)\}`)
+
+// Template is a please statement saved under a name, whose variables are
+// written between braces and given when it is run
+type Template struct {
+	Name      string `yaml:"name"`
+	Statement string `yaml:"statement"`
+}
+
+// Variables returns the names of the variables of t, in their order of appearance
+func (t Template) Variables() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, match := range placeholder.FindAllStringSubmatch(t.Statement, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+
+	return names
+}
+
+// Expand returns the statement of t with its variables replaced by values
+func (t Template) Expand(values map[string]string) (string, error) {
+	for _, name := range t.Variables() {
+		if _, ok := values[name]; !ok {
+			return "", fmt.Errorf("missing value of {%s}", name)
+		}
+	}
+
+	return placeholder.ReplaceAllStringFunc(t.Statement, func(match string) string {
+		return values[match[1:len(match)-1]]
+	}), nil
+}
+
+// AddTemplate stores statement under name
+func (m *Alfred) AddTemplate(name, statement string) error {
+	if name == "" {
+		return errors.New("the name of a template should not be empty")
+	}
+
+	if _, err := m.Template(name); err == nil {
+		return fmt.Errorf("template %s already exists", name)
+	}
+
+	m.Stellar.Templates = append(m.Stellar.Templates, Template{Name: name, Statement: statement})
+	return nil
+}
+
+// Template returns the template named name
+func (m *Alfred) Template(name string) (*Template, error) {
+	for i := range m.Stellar.Templates {
+		if m.Stellar.Templates[i].Name == name {
+			return &m.Stellar.Templates[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("template %s not found", name)
+}
+
+// RemoveTemplate removes the template named name
+func (m *Alfred) RemoveTemplate(name string) error {
+	for i, t := range m.Stellar.Templates {
+		if t.Name == name {
+			m.Stellar.Templates = append(m.Stellar.Templates[:i], m.Stellar.Templates[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("template %s not found", name)
+}
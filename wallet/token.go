@@ -0,0 +1,40 @@
+package wallet
+
+import "time"
+
+// AuthToken is a token issued by an anchor to an account (SEP-10)
+type AuthToken struct {
+	Domain  string    `yaml:"domain"`
+	Account string    `yaml:"account"`
+	JWT     string    `yaml:"jwt"`
+	Expires time.Time `yaml:"expires"`
+}
+
+// tokenMargin keeps tokens about to expire from being used
+const tokenMargin = time.Minute
+
+// AuthToken returns the token of account for domain, if it is still valid
+func (m *Alfred) AuthToken(domain, account string) (string, bool) {
+	for _, t := range m.Stellar.Tokens {
+		if t.Domain == domain && t.Account == account && time.Now().Add(tokenMargin).Before(t.Expires) {
+			return t.JWT, true
+		}
+	}
+
+	return "", false
+}
+
+// SetAuthToken stores a token, replacing the previous token of the same
+// account and domain. Expired tokens are removed.
+func (m *Alfred) SetAuthToken(token AuthToken) {
+	now := time.Now()
+	tokens := []AuthToken{token}
+	for _, t := range m.Stellar.Tokens {
+		if (t.Domain == token.Domain && t.Account == token.Account) || now.After(t.Expires) {
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+
+	m.Stellar.Tokens = tokens
+}
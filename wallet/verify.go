@@ -0,0 +1,42 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// VerifiedByTOML is a contact found in the ACCOUNTS of the stellar.toml of its domain
+	VerifiedByTOML = "stellar.toml"
+	// VerifiedByDNS is a contact found in a TXT record of _stellar.<domain>
+	VerifiedByDNS = "dns"
+)
+
+// Verification tells where the owner of a contact published its address.
+// A verified contact can not be swapped for another address without it being
+// noticed when paying it.
+type Verification struct {
+	Domain string    `yaml:"domain"`
+	Method string    `yaml:"method"`
+	Time   time.Time `yaml:"time"`
+}
+
+func (v Verification) String() string {
+	if v.Method == VerifiedByDNS {
+		return "the TXT record of _stellar." + v.Domain
+	}
+	return fmt.Sprintf("the %s of %s", v.Method, v.Domain)
+}
+
+// VerifyContact marks the contact name as verified by v, nil removes the mark
+func (m *Alfred) VerifyContact(name string, v *Verification) error {
+	c, ok := m.Stellar.Contacts[name]
+	if !ok {
+		return errors.New("contact not found")
+	}
+
+	c.Verified = v
+	m.Stellar.Contacts[name] = c
+	return nil
+}
@@ -1,16 +1,27 @@
 package wallet
 
 import (
+	"errors"
 	"fmt"
-	"net/http"
 
-	"github.com/stellar/go/clients/horizon"
 	"github.com/stellar/go/keypair"
 )
 
 type Wallet struct {
 	Name    string
 	Keypair keypair.KP
+	Policy  Policy
+	// Cold is where the seed of a cold wallet lives, such as a paper wallet
+	// or another machine. Only the address of a cold wallet is stored.
+	Cold string
+	// Aliases are the other names of the wallet
+	Aliases []string
+	// Tags group the wallet with other wallets and contacts, such as cold
+	Tags []string
+
+	// wrapped is the seed encrypted with the passphrase of the wallet, if it
+	// has one, prefixed by the salt of the passphrase
+	wrapped []byte
 }
 
 func (w *Wallet) String() string {
@@ -31,42 +42,44 @@ func New(name string, keypair *keypair.Full) *Wallet {
 	}
 }
 
-func (w *Wallet) Balances(testnet bool) ([]horizon.Balance, error) {
-	client := getClient(testnet)
-	account, _, err := getAccount(client, w.Keypair.Address())
+// NewCold returns the cold wallet of address, whose seed lives at location
+func NewCold(name, address, location string) (*Wallet, error) {
+	kp, err := keypair.Parse(address)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(account.Balances) == 0 {
-		account.Balances = append(account.Balances, horizon.Balance{
-			Balance: "0",
-			Asset: horizon.Asset{
-				Type: "native",
-			},
-		})
+	if _, ok := kp.(*keypair.FromAddress); !ok {
+		return nil, errors.New("the address of a cold wallet is expected, not its seed")
+	}
+	if location == "" {
+		return nil, errors.New("the location of the seed of a cold wallet should not be empty")
 	}
 
-	return account.Balances, nil
+	if name == "" {
+		name = address
+	}
+	return &Wallet{
+		Name:    name,
+		Keypair: kp,
+		Cold:    location,
+	}, nil
 }
 
-func getAccount(client *horizon.Client, account string) (horizon.Account, bool, error) {
-	hAccount, err := client.LoadAccount(account)
-	if err != nil {
-		if err, ok := err.(*horizon.Error); ok && err.Response.StatusCode == http.StatusNotFound {
-			return hAccount, false, nil
-		}
-		return hAccount, false, err
-	}
+// IsCold reports whether the seed of w is kept out of alfred
+func (w *Wallet) IsCold() bool { return w.Cold != "" }
 
-	return hAccount, true, nil
-}
+// MarkCold turns w into a cold wallet whose seed lives at location, the seed
+// is no longer stored
+func (w *Wallet) MarkCold(location string) error {
+	if location == "" {
+		return errors.New("the location of the seed of a cold wallet should not be empty")
+	}
 
-func getClient(testnet bool) *horizon.Client {
-	client := horizon.DefaultPublicNetClient
-	if testnet {
-		client = horizon.DefaultTestNetClient
+	kp, err := keypair.Parse(w.Keypair.Address())
+	if err != nil {
+		return err
 	}
 
-	return client
+	w.Keypair, w.Cold = kp, location
+	return nil
 }
@@ -0,0 +1,193 @@
+// Package webhook posts notifications of wallet events, such as incoming
+// payments or trades, as JSON to configured URLs. The payloads are signed with
+// HMAC-SHA256 so that the receiver can check they come from alfred.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Types of the events
+const (
+	// PaymentReceived is sent when a wallet receives a payment or is created
+	PaymentReceived = "payment_received"
+	// TradeFilled is sent when an offer of a wallet is filled, even partially
+	TradeFilled = "trade_filled"
+	// LowBalance is sent when the XLM balance of a wallet goes below a threshold
+	LowBalance = "low_balance"
+	// ScheduledPaymentFailed is sent when a transaction scheduled with NOT BEFORE fails to be submitted
+	ScheduledPaymentFailed = "scheduled_payment_failed"
+)
+
+// Headers of the requests
+const (
+	// EventHeader is the type of the event
+	EventHeader = "X-Alfred-Event"
+	// DeliveryHeader is the id of the event, the same for every attempt
+	DeliveryHeader = "X-Alfred-Delivery"
+	// SignatureHeader is sha256= followed by the hex HMAC-SHA256 of the body
+	SignatureHeader = "X-Alfred-Signature"
+)
+
+// Event is the payload posted to the webhooks
+type Event struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Network string    `json:"network"`
+	// Wallet is the name of the wallet, Account its address
+	Wallet  string `json:"wallet,omitempty"`
+	Account string `json:"account"`
+	// Data depends on the type of the event
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Payment is the data of a PaymentReceived event
+type Payment struct {
+	Transaction string `json:"transaction"`
+	From        string `json:"from"`
+	Amount      string `json:"amount"`
+	Asset       string `json:"asset"`
+	Issuer      string `json:"issuer,omitempty"`
+	Memo        string `json:"memo,omitempty"`
+}
+
+// Trade is the data of a TradeFilled event, from the point of view of the wallet
+type Trade struct {
+	ID           string `json:"id"`
+	Counterparty string `json:"counterparty"`
+	SoldAmount   string `json:"sold_amount"`
+	SoldAsset    string `json:"sold_asset"`
+	BoughtAmount string `json:"bought_amount"`
+	BoughtAsset  string `json:"bought_asset"`
+}
+
+// Balance is the data of a LowBalance event
+type Balance struct {
+	Balance   string `json:"balance"`
+	Threshold string `json:"threshold"`
+}
+
+// Failure is the data of a ScheduledPaymentFailed event
+type Failure struct {
+	Transaction string `json:"transaction"`
+	Error       string `json:"error"`
+}
+
+// NewEvent returns an event of type typ with a random id, happening now
+func NewEvent(typ string, data interface{}) Event {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return Event{
+		ID:   hex.EncodeToString(id),
+		Type: typ,
+		Time: time.Now().UTC(),
+		Data: data,
+	}
+}
+
+// Notifier posts events to URLs
+type Notifier struct {
+	URLs []string
+	// Secret signs the payloads, they are not signed when empty
+	Secret []byte
+	// Retries is the number of attempts after the first one failed
+	Retries int
+	// Backoff is the delay before the first retry, doubled for the next ones
+	Backoff time.Duration
+	Client  *http.Client
+}
+
+// Notify posts e to every URL, retrying when the URL can not be reached or
+// answers with a server error. The errors of the URLs which never accepted
+// the event are returned together.
+func (n *Notifier) Notify(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, url := range n.URLs {
+		if err := n.deliver(url, e, body); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", url, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("webhook %s not delivered to %s", e.Type, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (n *Notifier) deliver(url string, e Event, body []byte) error {
+	delay := n.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(url, e, body)
+		if err == nil || !retry || attempt >= n.Retries {
+			return err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post sends body once, it reports whether a failure is worth retrying
+func (n *Notifier) post(url string, e Event, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, e.Type)
+	req.Header.Set(DeliveryHeader, e.ID)
+	if len(n.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(n.Secret, body))
+	}
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("status %s", resp.Status)
+	default:
+		return false, fmt.Errorf("status %s", resp.Status)
+	}
+}
+
+// Sign returns the value of the signature header of body
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature header of body, for receivers written in Go
+func Verify(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
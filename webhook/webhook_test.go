@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotify(t *testing.T) {
+	secret := []byte("secret")
+
+	var (
+		mu       sync.Mutex
+		attempts int
+		received []Event
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.True(t, Verify(secret, body, r.Header.Get(SignatureHeader)))
+		require.Equal(t, PaymentReceived, r.Header.Get(EventHeader))
+
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var e Event
+		require.NoError(t, json.Unmarshal(body, &e))
+		require.Equal(t, e.ID, r.Header.Get(DeliveryHeader))
+		received = append(received, e)
+	}))
+	defer srv.Close()
+
+	n := &Notifier{URLs: []string{srv.URL}, Secret: secret, Retries: 2}
+	e := NewEvent(PaymentReceived, map[string]string{"amount": "10"})
+	e.Account = "GCFXHS4GXL6BVUCXBWXGTITROWLVYXQKQLF4YH5O5JT3YZXCYPAFBJZB"
+	require.NoError(t, n.Notify(e))
+	require.Equal(t, 3, attempts)
+	require.Len(t, received, 1)
+	require.Equal(t, e.ID, received[0].ID)
+	require.Equal(t, map[string]interface{}{"amount": "10"}, received[0].Data)
+
+	// the retries are exhausted
+	attempts = 0
+	n.Retries = 1
+	require.Error(t, n.Notify(e))
+	require.Equal(t, 2, attempts)
+
+	require.False(t, Verify([]byte("other"), []byte("{}"), Sign(secret, []byte("{}"))))
+}
+
+func TestNotifyClientError(t *testing.T) {
+	attempts := 0
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+
+	accepting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.Header.Get(SignatureHeader))
+	}))
+	defer accepting.Close()
+
+	n := &Notifier{URLs: []string{rejecting.URL, accepting.URL}, Retries: 3}
+	err := n.Notify(NewEvent(LowBalance, nil))
+	require.EqualError(t, err, "webhook low_balance not delivered to "+rejecting.URL+": status 400 Bad Request")
+	// a client error is not retried
+	require.Equal(t, 1, attempts)
+}